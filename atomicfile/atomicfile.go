@@ -0,0 +1,52 @@
+package atomicfile
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// For testing purposes: the steps of Write, replaced to fail partway
+var (
+	createTempFunc = os.CreateTemp
+	syncFunc       = (*os.File).Sync
+	renameFunc     = os.Rename
+)
+
+// Write replaces the file at path with data in one step: data goes to a
+// temporary file in the same directory, which is synced to disk and then
+// renamed over path, so owata being killed midway or another owata writing
+// at the same time never leaves a truncated file. An existing file keeps
+// its mode, and a symlink the file it points to; a new file gets mode.
+func Write(path string, data []byte, mode os.FileMode) (err error) {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	if info, err := os.Stat(path); err == nil {
+		mode = info.Mode().Perm()
+	}
+
+	tmp, err := createTempFunc(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}
+	}()
+
+	if _, err = tmp.Write(data); err != nil {
+		return err
+	}
+	if err = os.Chmod(tmp.Name(), mode); err != nil {
+		return err
+	}
+	if err = syncFunc(tmp); err != nil {
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		return err
+	}
+	return renameFunc(tmp.Name(), path)
+}
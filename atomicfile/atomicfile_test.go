@@ -0,0 +1,106 @@
+package atomicfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	failed := errors.New("disk on fire")
+
+	tests := []struct {
+		name   string
+		inject func()
+	}{
+		{name: "Success"},
+		{
+			name: "Creating the temporary file fails",
+			inject: func() {
+				createTempFunc = func(string, string) (*os.File, error) { return nil, failed }
+			},
+		},
+		{
+			name:   "Syncing fails",
+			inject: func() { syncFunc = func(*os.File) error { return failed } },
+		},
+		{
+			// As if owata were killed between writing and renaming
+			name:   "Renaming fails",
+			inject: func() { renameFunc = func(string, string) error { return failed } },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Cleanup(func() {
+				createTempFunc, syncFunc, renameFunc = os.CreateTemp, (*os.File).Sync, os.Rename
+			})
+			dir := t.TempDir()
+			path := filepath.Join(dir, "config.json")
+			os.WriteFile(path, []byte(`{"username": "Old"}`), 0600)
+			if tt.inject != nil {
+				tt.inject()
+			}
+
+			err := Write(path, []byte(`{"username": "New"}`), 0600)
+			expected := `{"username": "New"}`
+			if tt.inject != nil {
+				if !errors.Is(err, failed) {
+					t.Errorf("Expected the injected error, got %v", err)
+				}
+				expected = `{"username": "Old"}`
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if data, _ := os.ReadFile(path); string(data) != expected {
+				t.Errorf("Expected %s, got %s", expected, data)
+			}
+			if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+				t.Errorf("Expected no temporary file left behind, got %v", entries)
+			}
+		})
+	}
+}
+
+func TestWriteKeepsMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't use Unix permissions")
+	}
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte("{}"), 0600)
+	os.Chmod(path, 0640)
+
+	if err := Write(path, []byte(`{"username": "New"}`), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0640 {
+		t.Errorf("Expected the mode to stay 0640, got %04o", info.Mode().Perm())
+	}
+
+	created := filepath.Join(dir, "new.json")
+	if err := Write(created, []byte("{}"), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info, _ := os.Stat(created); info.Mode().Perm() != 0600 {
+		t.Errorf("Expected a new file to get 0600, got %04o", info.Mode().Perm())
+	}
+
+	link := filepath.Join(dir, "link.json")
+	if err := os.Symlink(path, link); err != nil {
+		t.Skipf("Symlinks not supported: %v", err)
+	}
+	if err := Write(link, []byte(`{"username": "Linked"}`), 0600); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info, _ := os.Lstat(link); info.Mode()&os.ModeSymlink == 0 {
+		t.Error("Expected the symlink to be kept")
+	}
+	if data, _ := os.ReadFile(path); string(data) != `{"username": "Linked"}` {
+		t.Errorf("Expected the linked file to be written, got %s", data)
+	}
+}
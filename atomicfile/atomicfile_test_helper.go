@@ -0,0 +1,16 @@
+package atomicfile
+
+import (
+	"os"
+)
+
+// SetTestRenameError makes Write fail with err just before renaming, as if
+// owata were killed between writing and renaming
+func SetTestRenameError(err error) {
+	renameFunc = func(string, string) error { return err }
+}
+
+// ResetTestRenameError restores renaming in Write
+func ResetTestRenameError() {
+	renameFunc = os.Rename
+}
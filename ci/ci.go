@@ -0,0 +1,138 @@
+package ci
+
+import (
+	"cmp"
+	"net/url"
+	"strings"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// Info describes the CI run a notification is sent from
+type Info struct {
+	Provider   string // Such as "GitHub Actions"
+	Repository string
+	Job        string
+	RunURL     string // Link to the run's page
+}
+
+// detector recognizes one CI service from its environment variables,
+// returning nil when they aren't set
+type detector func(getenv func(string) string) *Info
+
+// detectors are tried in order; the first match wins
+var detectors = []detector{
+	githubActions,
+	gitlabCI,
+	jenkins,
+	circleCI,
+}
+
+// Detect recognizes the CI service running owata from the environment read
+// through getenv, normally os.Getenv, or returns nil outside CI
+func Detect(getenv func(string) string) *Info {
+	for _, detect := range detectors {
+		if info := detect(getenv); info != nil {
+			return info
+		}
+	}
+	return nil
+}
+
+func githubActions(getenv func(string) string) *Info {
+	if getenv("GITHUB_ACTIONS") != "true" {
+		return nil
+	}
+
+	info := &Info{
+		Provider:   "GitHub Actions",
+		Repository: getenv("GITHUB_REPOSITORY"),
+		Job:        joinNonEmpty(" / ", getenv("GITHUB_WORKFLOW"), getenv("GITHUB_JOB")),
+	}
+	if runID := getenv("GITHUB_RUN_ID"); runID != "" && info.Repository != "" {
+		server := strings.TrimSuffix(cmp.Or(getenv("GITHUB_SERVER_URL"), "https://github.com"), "/")
+		info.RunURL = server + "/" + info.Repository + "/actions/runs/" + runID
+	}
+	return info
+}
+
+func gitlabCI(getenv func(string) string) *Info {
+	if getenv("GITLAB_CI") != "true" {
+		return nil
+	}
+
+	return &Info{
+		Provider:   "GitLab CI",
+		Repository: getenv("CI_PROJECT_PATH"),
+		Job:        getenv("CI_JOB_NAME"),
+		RunURL:     cmp.Or(getenv("CI_PIPELINE_URL"), getenv("CI_JOB_URL")),
+	}
+}
+
+func jenkins(getenv func(string) string) *Info {
+	if getenv("JENKINS_URL") == "" {
+		return nil
+	}
+
+	job := getenv("JOB_NAME")
+	if build := getenv("BUILD_NUMBER"); build != "" && job != "" {
+		job += " #" + build
+	}
+	return &Info{
+		Provider:   "Jenkins",
+		Repository: withoutCredentials(getenv("GIT_URL")),
+		Job:        job,
+		RunURL:     getenv("BUILD_URL"),
+	}
+}
+
+func circleCI(getenv func(string) string) *Info {
+	if getenv("CIRCLECI") != "true" {
+		return nil
+	}
+
+	return &Info{
+		Provider:   "CircleCI",
+		Repository: joinNonEmpty("/", getenv("CIRCLE_PROJECT_USERNAME"), getenv("CIRCLE_PROJECT_REPONAME")),
+		Job:        getenv("CIRCLE_JOB"),
+		RunURL:     getenv("CIRCLE_BUILD_URL"),
+	}
+}
+
+// withoutCredentials drops any user and password from a clone URL, which
+// Jenkins jobs sometimes embed
+func withoutCredentials(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.User == nil {
+		return raw
+	}
+	u.User = nil
+	return u.String()
+}
+
+// joinNonEmpty joins the non-empty parts with sep
+func joinNonEmpty(sep string, parts ...string) string {
+	var kept []string
+	for _, part := range parts {
+		if part != "" {
+			kept = append(kept, part)
+		}
+	}
+	return strings.Join(kept, sep)
+}
+
+// Fields renders the info as embed fields, leaving out anything unknown. The
+// run link goes last on its own line so it stays readable.
+func (i *Info) Fields() []notifier.Field {
+	fields := []notifier.Field{{Name: "CI", Value: i.Provider, Inline: true}}
+	if i.Repository != "" {
+		fields = append(fields, notifier.Field{Name: "Repository", Value: i.Repository, Inline: true})
+	}
+	if i.Job != "" {
+		fields = append(fields, notifier.Field{Name: "Job", Value: i.Job, Inline: true})
+	}
+	if i.RunURL != "" {
+		fields = append(fields, notifier.Field{Name: "Run", Value: i.RunURL})
+	}
+	return fields
+}
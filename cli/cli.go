@@ -2,7 +2,12 @@ package cli
 
 import (
 	"fmt"
+	"net"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 )
 
 const Version = "2.1.0"
@@ -15,49 +20,361 @@ const (
 	CommandConfig
 	CommandShowHelp
 	CommandShowVersion
+	CommandWebhookInfo
+	CommandCompose
+	CommandDelete
+	CommandTest
+	CommandRun
+	CommandWaitPID
+	CommandWaitPort
+	CommandWatchFile
+	CommandCompletion
+	CommandDoctor
+)
+
+// Compose actions
+const (
+	ComposeAddField = "add-field"
+	ComposeAddLine  = "add-line"
+	ComposeAddFile  = "add-file"
+	ComposeStatus   = "status"
+	ComposeShow     = "show"
+	ComposeClear    = "clear"
+	ComposeSend     = "send"
 )
 
 type Args struct {
-	Command    CommandType
-	Message    string
-	WebhookURL string
-	Source     string
-	Username   string
-	AvatarURL  string
-	Global     bool
+	Command     CommandType
+	Message     string
+	WebhookURL  string
+	WebhookFile string // Read the webhook URL from this file
+	Source      string
+	Title       string
+	Thumbnail   string
+	Author      string
+	AuthorURL   string
+	AuthorIcon  string
+	Footer      string
+	FooterIcon  string
+	Username    string
+	AvatarURL   string
+	Global      bool
+	Quiet       bool   // Silence informational output; errors are still printed
+	NoEmoji     bool   // Print ASCII tags such as [ok] instead of emoji
+	Debug       bool   // Log config resolution and each request to stderr
+	Send        bool   // doctor --send: also post a test message
+	ConfigPath  string // --config: the only config file read or written
+	Force       bool   // init --force: replace an existing config, keeping a backup
+	Interactive bool   // init --interactive: ask for the values rather than writing a template
+	Format      string // init --format: json or toml, the format of a new config file
+	Profile     string // --profile: the config profile to use, or to write with config
+
+	AllowCustomWebhook bool     // Skip checking that the webhook URL is Discord's
+	Targets            []string // Named webhooks from the config's targets
+	Provider           string   // Service the webhook belongs to; detected when empty
+
+	RunID          string
+	Seq            string // "auto" or an explicit step number
+	CheckURLs      bool
+	Strict         bool
+	Mentions       []string
+	Fields         []string
+	Attach         []string
+	ThreadID       string
+	ThreadName     string
+	NoWait         bool
+	NoSplit        bool
+	Plain          bool
+	Code           bool
+	Lang           string
+	Escape         bool
+	NoExpand       bool // Send {{...}} in the message, title and footer as written
+	NoHost         bool
+	NoIdentity     bool // Post with the webhook's own name and avatar
+	Git            bool
+	NoCI           bool
+	Sysinfo        bool
+	EmbedJSON      string
+	TTS            bool
+	SuppressEmbeds bool
+	Silent         bool
+	Loud           bool
+	JSON           bool
+	Verbose        bool
+	DryRun         bool
+	Batch          bool
+	BatchSize      int    // Lines bundled into each notification in batch mode
+	Stdin          bool   // Read the message from stdin, for "-" or --stdin
+	MessageFile    string // Read the message from this file
+	Newline        bool   // Join the message arguments with newlines instead of spaces
+
+	Retries          int // -1 when not given on the command line
+	RateLimitRetries int // -1 when not given on the command line
+
+	ComposeAction string
+	ComposeArgs   []string // Positional arguments of the compose action
+
+	ConfigAction string   // get, set, unset, edit or path; empty to show or update the config
+	ConfigArgs   []string // Key, and for set the value
+	Reveal       bool     // Show secrets such as the webhook URL in config get and config --json
+	Resolved     bool     // config path prints the file Load would read; config shows the merged config
+	All          bool     // config shows each layer side by side with the merged config
+	Session      string
+
+	MessageID string
+
+	RunCommand     []string // Command line run mode executes, from after "--"
+	RunScript      string   // Shell command line run mode executes instead, from -c
+	Tail           int      // Lines of output to include in run mode; 0 for none
+	TailStderrOnly bool     // Capture only stderr for Tail
+	NotifyStart    bool     // Also notify when the run mode command starts
+	NotifyOn       string   // "failure" or "success" to notify only then; empty for the config
+
+	MinDuration          time.Duration // Skip run mode notifications for commands faster than this
+	MinDurationAppliesTo string        // AppliesToSuccess (default) or AppliesToAll
+	Timeout              time.Duration // Stop the run mode command after this long; 0 for no limit
+	Stats                bool          // Report the run mode command's CPU time and peak memory
+
+	PID       int           // Process wait-pid waits for
+	Address   string        // host:port wait-port waits for
+	UntilDown bool          // Have wait-port wait for the port to stop accepting connections
+	Interval  time.Duration // How often wait-pid, wait-port and watch-file check; 0 for the default
+	WatchPath string        // File watch-file watches
+	Events    []string      // watch-file events to notify about; all of them when empty
+	Once      bool          // Have watch-file exit after the first notification
+	Debounce  time.Duration // How long a file has to settle before watch-file notifies; -1 for the default
+	Shell     string        // Shell completion prints a script for, or "targets"
 }
 
+// langPattern matches code block languages such as go, c++ or objective-c
+var langPattern = regexp.MustCompile(`^[A-Za-z0-9_+#.-]+$`)
+
+// Outcomes --min-duration applies to
+const (
+	AppliesToSuccess = "success"
+	AppliesToAll     = "all"
+)
+
+// SeqAuto requests a sequence number persisted per run ID
+const SeqAuto = "auto"
+
 func Parse(args []string) (*Args, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("missing arguments; use --help to see available commands and options")
 	}
 
+	// Everything after "--" is the command run mode executes, or the message
+	// to send, whose own dashes must not be read as owata's flags
+	command := []string{}
+	if i := slices.Index(args, "--"); i >= 0 {
+		args, command = args[:i], args[i:]
+	}
+
+	args, err := joinFlagValues(args)
+	if err != nil {
+		return nil, err
+	}
+
 	for _, arg := range args {
 		if arg == "--help" || arg == "-h" {
 			return &Args{Command: CommandShowHelp}, nil
 		}
 		if arg == "--version" || arg == "-v" {
-			return &Args{Command: CommandShowVersion}, nil
+			return &Args{Command: CommandShowVersion, JSON: slices.Contains(args, "--json")}, nil
 		}
 	}
 
-	var globalFlag bool
+	var globalFlag, quietFlag, noEmojiFlag, debugFlag bool
+	var configPath, profile string
 	var processedArgs []string
 
 	for i := range args {
-		if args[i] == "-g" || args[i] == "--global" {
+		switch args[i] {
+		case "-g", "--global":
 			globalFlag = true
-		} else {
+		case "-q", "--quiet":
+			quietFlag = true
+		case "--no-emoji":
+			noEmojiFlag = true
+		case "--debug":
+			debugFlag = true
+		default:
+			if after, ok := strings.CutPrefix(args[i], "--config="); ok {
+				configPath = strings.Trim(after, "'\"")
+				if configPath == "" {
+					return nil, fmt.Errorf("--config requires a path, as in --config=/etc/owata/prod.json")
+				}
+				continue
+			}
+			if after, ok := strings.CutPrefix(args[i], "--profile="); ok {
+				profile = strings.Trim(after, "'\"")
+				if profile == "" {
+					return nil, fmt.Errorf("--profile requires a name, as in --profile=work")
+				}
+				continue
+			}
 			processedArgs = append(processedArgs, args[i])
 		}
 	}
+	if configPath != "" && globalFlag {
+		return nil, fmt.Errorf("--config cannot be combined with -g/--global; --config already names the file to use")
+	}
+	processedArgs = append(processedArgs, command...)
 
+	result, err := parseCommand(processedArgs, globalFlag)
+	if err == nil && result != nil && result.WebhookURL != "" && result.WebhookFile != "" {
+		return nil, fmt.Errorf("--webhook and --webhook-file cannot be used together")
+	}
+	if err == nil && result != nil {
+		result.Quiet = quietFlag
+		result.NoEmoji = noEmojiFlag
+		result.Debug = debugFlag
+		result.ConfigPath = configPath
+		result.Profile = profile
+	}
+	return result, err
+}
+
+// valueFlags are the options that take a value. Each can be written as
+// --flag=value or --flag value.
+var valueFlags = []string{
+	"--attach", "--author", "--author-icon", "--author-url", "--avatar",
+	"--batch-size", "--config", "--debounce", "--embed-json", "--event", "--field",
+	"--footer", "--footer-icon", "--interval", "--lang", "--mention",
+	"--message-file", "--min-duration", "--min-duration-applies-to",
+	"--profile", "--provider", "--rate-limit-retries", "--retry", "--run-id", "--seq",
+	"--session", "--source", "--tail", "--target", "--thread-id",
+	"--thread-name", "--thumbnail", "--timeout", "--title", "--username",
+	"--webhook", "--webhook-file",
+}
+
+// joinFlagValues rewrites "--flag value" as "--flag=value" for the options in
+// valueFlags, so the parsers only deal with one form. The next argument is
+// the value even if it starts with a dash, as in --title --dry-run.
+func joinFlagValues(args []string) ([]string, error) {
+	joined := make([]string, 0, len(args))
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if slices.Contains(valueFlags, arg) {
+			if i+1 == len(args) {
+				return nil, fmt.Errorf("%s requires a value, as in %s=<value> or %s <value>", arg, arg, arg)
+			}
+			i++
+			arg += "=" + args[i]
+		}
+		joined = append(joined, arg)
+	}
+	return joined, nil
+}
+
+// parseCommand parses the command and its options once the flags every
+// command accepts are taken out
+func parseCommand(processedArgs []string, globalFlag bool) (*Args, error) {
 	if len(processedArgs) == 0 {
 		return nil, fmt.Errorf("missing command; please specify 'init', 'config', or a notification message (use --help for more information)")
 	}
 
 	if processedArgs[0] == "init" {
-		return &Args{Command: CommandInit, Global: globalFlag}, nil
+		result, err := parseInitArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "webhook" || processedArgs[0] == "webhook-info" {
+		webhookArgs := processedArgs[1:]
+		if processedArgs[0] == "webhook-info" {
+			webhookArgs = append([]string{"info"}, webhookArgs...)
+		}
+		result, err := parseWebhookArgs(webhookArgs)
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "delete" {
+		result, err := parseDeleteArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "run" {
+		result, err := parseRunArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "wait-pid" {
+		result, err := parseWaitPIDArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "wait-port" {
+		result, err := parseWaitPortArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "watch-file" {
+		result, err := parseWatchFileArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "completion" {
+		result, err := parseCompletionArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "test" {
+		result, err := parseTestArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "doctor" {
+		result, err := parseDoctorArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
+	}
+
+	if processedArgs[0] == "compose" {
+		result, err := parseComposeArgs(processedArgs[1:])
+		if err == nil && result != nil {
+			// Merge global flag from initial parsing
+			result.Global = globalFlag
+		}
+		return result, err
 	}
 
 	if processedArgs[0] == "config" {
@@ -77,14 +394,19 @@ func Parse(args []string) (*Args, error) {
 	return result, err
 }
 
+// DefaultSource is the source of a notification sent without --source,
+// OWATA_SOURCE or default_source in the config
+const DefaultSource = "Unknown"
+
 func parseNotifyArgs(args []string) (*Args, error) {
 	if len(args) < 1 {
 		return nil, fmt.Errorf("missing required message argument (use --help for correct usage)")
 	}
 
 	result := &Args{
-		Command: CommandNotify,
-		Source:  "Unknown", // Default source
+		Command:          CommandNotify,
+		Retries:          -1,
+		RateLimitRetries: -1,
 	}
 
 	var messageArgs []string
@@ -93,25 +415,272 @@ func parseNotifyArgs(args []string) (*Args, error) {
 	for i := range args {
 		arg := args[i]
 
-		if after, ok := strings.CutPrefix(arg, "--source="); ok {
-			result.Source = strings.Trim(after, "'\"")
-		} else if after, ok := strings.CutPrefix(arg, "--webhook="); ok {
-			result.WebhookURL = strings.Trim(after, "'\"")
-		} else if strings.HasPrefix(arg, "-") {
-			// Unknown flag - return error but suggest using --help
-			return nil, fmt.Errorf("unknown option for notify command: %s (use --help for available options)", arg)
-		} else {
-			messageArgs = append(messageArgs, arg)
-			messageFound = true
+		// "--" ends the options: the rest is the message, as written
+		if arg == "--" {
+			messageArgs = append(messageArgs, args[i+1:]...)
+			messageFound = len(messageArgs) > 0
+			break
+		}
+
+		if arg == "--batch" {
+			result.Batch = true
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--batch-size="); ok {
+			n, err := strconv.Atoi(strings.Trim(after, "'\""))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid --batch-size value: %s (use a positive number)", after)
+			}
+			result.Batch = true
+			result.BatchSize = n
+			continue
+		}
+
+		if arg == "-" || arg == "--stdin" {
+			result.Stdin = true
+			continue
 		}
+		if arg == "--newline" {
+			result.Newline = true
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--message-file="); ok {
+			result.MessageFile = strings.Trim(after, "'\"")
+			if result.MessageFile == "" {
+				return nil, fmt.Errorf("--message-file requires a path")
+			}
+			continue
+		}
+
+		handled, err := parseNotifyOption(result, arg)
+		if err != nil {
+			return nil, err
+		}
+		if handled {
+			continue
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			// Unknown flag - return error but suggest using --help or --
+			return nil, fmt.Errorf("unknown option for notify command: %s (use --help for available options, or put -- before a message starting with a dash, as in owata -- '%s')", arg, arg)
+		}
+		messageArgs = append(messageArgs, arg)
+		messageFound = true
 	}
 
-	if !messageFound {
+	// Batch mode reads the messages from stdin, and a custom embed carries
+	// the content, making the message optional
+	switch {
+	case result.Newline && !messageFound:
+		return nil, fmt.Errorf("--newline joins message arguments, and there are none")
+	case result.Batch && messageFound:
+		return nil, fmt.Errorf("--batch reads messages from stdin and takes no message argument")
+	case result.Batch && result.Stdin:
+		return nil, fmt.Errorf("--batch already reads from stdin; leave out - and --stdin")
+	case result.MessageFile != "" && (messageFound || result.Stdin || result.Batch):
+		return nil, fmt.Errorf("--message-file cannot be combined with a message argument, - or --stdin, or --batch")
+	case result.Batch:
+		result.BatchSize = max(result.BatchSize, 1)
+	case result.Stdin && messageFound:
+		return nil, fmt.Errorf("the message is read from stdin with - or --stdin; leave out the message argument")
+	case result.Stdin && result.EmbedJSON == "-":
+		return nil, fmt.Errorf("the message and --embed-json=- cannot both be read from stdin")
+	case result.Stdin, result.MessageFile != "":
+	case !messageFound && result.EmbedJSON == "":
 		return nil, fmt.Errorf("missing required message argument (use --help for correct usage)")
 	}
 
-	result.Message = strings.Join(messageArgs, " ")
+	separator := " "
+	if result.Newline {
+		separator = "\n"
+	}
+	result.Message = strings.Join(messageArgs, separator)
+
+	if err := validateNotifyOptions(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// validateNotifyOptions checks combinations of notification options
+func validateNotifyOptions(result *Args) error {
+	if result.ThreadID != "" && result.ThreadName != "" {
+		return fmt.Errorf("--thread-id and --thread-name cannot be used together: --thread-id posts into an existing thread, --thread-name creates a new forum post")
+	}
+	if result.WebhookURL != "" && len(result.Targets) > 0 {
+		return fmt.Errorf("--webhook and --target cannot be used together")
+	}
+	if result.WebhookFile != "" && len(result.Targets) > 0 {
+		return fmt.Errorf("--webhook-file and --target cannot be used together")
+	}
+	if result.Silent && result.Loud {
+		return fmt.Errorf("--silent and --loud cannot be used together")
+	}
+	if result.Plain && result.EmbedJSON != "" {
+		return fmt.Errorf("--plain and --embed-json cannot be used together")
+	}
+	if result.NoIdentity && (result.Username != "" || result.AvatarURL != "") {
+		return fmt.Errorf("--no-identity cannot be used with --username or --avatar")
+	}
+	return nil
+}
+
+// parseNotifyOption applies a single notification option to result. It
+// reports whether arg was recognized so commands that build notifications
+// (notify, compose send) share the same options.
+func parseNotifyOption(result *Args, arg string) (bool, error) {
+	if after, ok := strings.CutPrefix(arg, "--source="); ok {
+		result.Source = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--webhook="); ok {
+		result.WebhookURL = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--webhook-file="); ok {
+		result.WebhookFile = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--username="); ok {
+		result.Username = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--avatar="); ok {
+		result.AvatarURL = strings.Trim(after, "'\"")
+	} else if arg == "--allow-custom-webhook" {
+		result.AllowCustomWebhook = true
+	} else if after, ok := strings.CutPrefix(arg, "--provider="); ok {
+		result.Provider = strings.ToLower(strings.Trim(after, "'\""))
+	} else if after, ok := strings.CutPrefix(arg, "--target="); ok {
+		for name := range strings.SplitSeq(strings.Trim(after, "'\""), ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				result.Targets = append(result.Targets, name)
+			}
+		}
+		if len(result.Targets) == 0 {
+			return false, fmt.Errorf("invalid --target value: %s (use one or more comma-separated target names)", after)
+		}
+	} else if after, ok := strings.CutPrefix(arg, "--title="); ok {
+		result.Title = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--run-id="); ok {
+		result.RunID = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--seq="); ok {
+		result.Seq = strings.Trim(after, "'\"")
+		if result.Seq != SeqAuto {
+			if n, err := strconv.Atoi(result.Seq); err != nil || n < 1 {
+				return false, fmt.Errorf("invalid --seq value: %s (use 'auto' or a positive number)", result.Seq)
+			}
+		}
+	} else if after, ok := strings.CutPrefix(arg, "--mention="); ok {
+		result.Mentions = append(result.Mentions, strings.Trim(after, "'\""))
+	} else if after, ok := strings.CutPrefix(arg, "--field="); ok {
+		result.Fields = append(result.Fields, strings.Trim(after, "'\""))
+	} else if after, ok := strings.CutPrefix(arg, "--thread-id="); ok {
+		result.ThreadID = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--thread-name="); ok {
+		result.ThreadName = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--attach="); ok {
+		result.Attach = append(result.Attach, strings.Trim(after, "'\""))
+	} else if after, ok := strings.CutPrefix(arg, "--retry="); ok {
+		n, err := strconv.Atoi(strings.Trim(after, "'\""))
+		if err != nil || n < 0 {
+			return false, fmt.Errorf("invalid --retry value: %s (use a non-negative number)", after)
+		}
+		result.Retries = n
+	} else if after, ok := strings.CutPrefix(arg, "--rate-limit-retries="); ok {
+		n, err := strconv.Atoi(strings.Trim(after, "'\""))
+		if err != nil || n < 0 {
+			return false, fmt.Errorf("invalid --rate-limit-retries value: %s (use a non-negative number)", after)
+		}
+		result.RateLimitRetries = n
+	} else if arg == "--verbose" {
+		result.Verbose = true
+	} else if after, ok := strings.CutPrefix(arg, "--thumbnail="); ok {
+		result.Thumbnail = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--author="); ok {
+		result.Author = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--author-url="); ok {
+		result.AuthorURL = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--author-icon="); ok {
+		result.AuthorIcon = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--embed-json="); ok {
+		result.EmbedJSON = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--footer="); ok {
+		result.Footer = strings.Trim(after, "'\"")
+	} else if after, ok := strings.CutPrefix(arg, "--footer-icon="); ok {
+		result.FooterIcon = strings.Trim(after, "'\"")
+	} else if arg == "--suppress-embeds" {
+		result.SuppressEmbeds = true
+	} else if arg == "--silent" {
+		result.Silent = true
+	} else if arg == "--loud" {
+		result.Loud = true
+	} else if arg == "--tts" {
+		result.TTS = true
+	} else if arg == "--plain" {
+		result.Plain = true
+	} else if arg == "--code" {
+		result.Code = true
+	} else if arg == "--escape" {
+		result.Escape = true
+	} else if arg == "--no-expand" {
+		result.NoExpand = true
+	} else if arg == "--no-host" {
+		result.NoHost = true
+	} else if arg == "--no-identity" {
+		result.NoIdentity = true
+	} else if arg == "--git" {
+		result.Git = true
+	} else if arg == "--no-ci" {
+		result.NoCI = true
+	} else if arg == "--sysinfo" {
+		result.Sysinfo = true
+	} else if after, ok := strings.CutPrefix(arg, "--lang="); ok {
+		lang := strings.Trim(after, "'\"")
+		if !langPattern.MatchString(lang) {
+			return false, fmt.Errorf("invalid --lang value: %s (use a language name such as go or python)", after)
+		}
+		// A language only makes sense for a code block
+		result.Lang = lang
+		result.Code = true
+	} else if arg == "--no-split" {
+		result.NoSplit = true
+	} else if arg == "--no-wait" {
+		result.NoWait = true
+	} else if arg == "--json" {
+		result.JSON = true
+	} else if arg == "--dry-run" {
+		result.DryRun = true
+	} else if arg == "--check-urls" {
+		result.CheckURLs = true
+	} else if arg == "--strict" {
+		result.Strict = true
+	} else {
+		return false, nil
+	}
 
+	return true, nil
+}
+
+func parseInitArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command: CommandInit,
+	}
+
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--force" {
+			result.Force = true
+		} else if arg == "--interactive" {
+			result.Interactive = true
+		} else if arg == "--format" && i+1 < len(args) {
+			i++
+			result.Format = strings.ToLower(args[i])
+		} else if after, ok := strings.CutPrefix(arg, "--format="); ok {
+			result.Format = strings.ToLower(strings.Trim(after, "'\""))
+		} else {
+			return nil, fmt.Errorf("unknown option for init command: %s (use --help for available options)", arg)
+		}
+	}
+
+	switch result.Format {
+	case "", "json", "toml":
+	default:
+		return nil, fmt.Errorf("invalid --format value: %s (use json or toml)", result.Format)
+	}
 	return result, nil
 }
 
@@ -124,6 +693,11 @@ func parseConfigArgs(args []string) (*Args, error) {
 		return result, nil
 	}
 
+	switch args[0] {
+	case "get", "set", "unset", "edit", "path":
+		return parseConfigKeyArgs(result, args[0], args[1:])
+	}
+
 	for i := range args {
 		arg := args[i]
 
@@ -133,6 +707,18 @@ func parseConfigArgs(args []string) (*Args, error) {
 			result.Username = strings.Trim(after, "'\"")
 		} else if after, ok := strings.CutPrefix(arg, "--avatar="); ok {
 			result.AvatarURL = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--source="); ok {
+			result.Source = strings.Trim(after, "'\"")
+		} else if arg == "--allow-custom-webhook" {
+			result.AllowCustomWebhook = true
+		} else if arg == "--resolved" {
+			result.Resolved = true
+		} else if arg == "--all" {
+			result.All = true
+		} else if arg == "--json" {
+			result.JSON = true
+		} else if arg == "--reveal" {
+			result.Reveal = true
 		} else {
 			return nil, fmt.Errorf("unknown config parameter: %s (use --help for available parameters)", arg)
 		}
@@ -141,34 +727,699 @@ func parseConfigArgs(args []string) (*Args, error) {
 	return result, nil
 }
 
+// configKeyArity is how many positional arguments each config key action takes
+var configKeyArity = map[string]int{"get": 1, "set": 2, "unset": 1, "edit": 0, "path": 0}
+
+// parseConfigKeyArgs parses "config get <key> [--reveal]", "config set <key>
+// <value>", "config unset <key>", "config edit" and "config path
+// [--resolved]". A value is taken as is, even if it starts with a dash.
+func parseConfigKeyArgs(result *Args, action string, args []string) (*Args, error) {
+	result.ConfigAction = action
+	usage := map[string]string{
+		"get":   "owata config get <key> [--reveal]",
+		"set":   "owata config set <key> <value>",
+		"unset": "owata config unset <key>",
+		"edit":  "owata config edit",
+		"path":  "owata config path [--resolved]",
+	}[action]
+
+	for _, arg := range args {
+		if arg == "--reveal" && action == "get" {
+			result.Reveal = true
+			continue
+		}
+		if arg == "--resolved" && action == "path" {
+			result.Resolved = true
+			continue
+		}
+		if strings.HasPrefix(arg, "-") && len(result.ConfigArgs) != 1 {
+			return nil, fmt.Errorf("unknown option for config %s: %s (usage: %s)", action, arg, usage)
+		}
+		result.ConfigArgs = append(result.ConfigArgs, arg)
+	}
+
+	if len(result.ConfigArgs) != configKeyArity[action] {
+		return nil, fmt.Errorf("config %s takes %d argument(s), got %d (usage: %s)", action, configKeyArity[action], len(result.ConfigArgs), usage)
+	}
+	return result, nil
+}
+
+func parseWebhookArgs(args []string) (*Args, error) {
+	if len(args) < 1 || args[0] != "info" {
+		return nil, fmt.Errorf("missing webhook action; use 'owata webhook info' (use --help for more information)")
+	}
+
+	result := &Args{
+		Command: CommandWebhookInfo,
+	}
+
+	for _, arg := range args[1:] {
+		if after, ok := strings.CutPrefix(arg, "--webhook="); ok {
+			result.WebhookURL = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--webhook-file="); ok {
+			result.WebhookFile = strings.Trim(after, "'\"")
+		} else if arg == "--allow-custom-webhook" {
+			result.AllowCustomWebhook = true
+		} else if arg == "--json" {
+			result.JSON = true
+		} else {
+			return nil, fmt.Errorf("unknown option for webhook info command: %s (use --help for available options)", arg)
+		}
+	}
+
+	return result, nil
+}
+
+func parseTestArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command:          CommandTest,
+		Retries:          -1,
+		RateLimitRetries: -1,
+	}
+
+	for _, arg := range args {
+		if after, ok := strings.CutPrefix(arg, "--webhook="); ok {
+			result.WebhookURL = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--webhook-file="); ok {
+			result.WebhookFile = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--target="); ok {
+			result.Targets = append(result.Targets, strings.Trim(after, "'\""))
+		} else if after, ok := strings.CutPrefix(arg, "--provider="); ok {
+			result.Provider = strings.ToLower(strings.Trim(after, "'\""))
+		} else if arg == "--allow-custom-webhook" {
+			result.AllowCustomWebhook = true
+		} else if arg == "--verbose" {
+			result.Verbose = true
+		} else {
+			return nil, fmt.Errorf("unknown option for test command: %s (use --help for available options)", arg)
+		}
+	}
+
+	if err := validateNotifyOptions(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parseDoctorArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command:          CommandDoctor,
+		Retries:          -1,
+		RateLimitRetries: -1,
+	}
+
+	for _, arg := range args {
+		if after, ok := strings.CutPrefix(arg, "--webhook="); ok {
+			result.WebhookURL = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--webhook-file="); ok {
+			result.WebhookFile = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--target="); ok {
+			result.Targets = append(result.Targets, strings.Trim(after, "'\""))
+		} else if after, ok := strings.CutPrefix(arg, "--provider="); ok {
+			result.Provider = strings.ToLower(strings.Trim(after, "'\""))
+		} else if arg == "--allow-custom-webhook" {
+			result.AllowCustomWebhook = true
+		} else if arg == "--send" {
+			result.Send = true
+		} else {
+			return nil, fmt.Errorf("unknown option for doctor command: %s (use --help for available options)", arg)
+		}
+	}
+
+	if err := validateNotifyOptions(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parseRunArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command:          CommandRun,
+		Retries:          -1,
+		RateLimitRetries: -1,
+	}
+
+	options := args
+	if i := slices.Index(args, "--"); i >= 0 {
+		options, result.RunCommand = args[:i], args[i+1:]
+		if len(result.RunCommand) == 0 {
+			return nil, fmt.Errorf("missing command to run; use 'owata run [options] -- <command> [args...]'")
+		}
+	}
+
+	for j := 0; j < len(options); j++ {
+		arg := options[j]
+		if arg == "-c" {
+			if j+1 == len(options) || options[j+1] == "" {
+				return nil, fmt.Errorf("-c requires a command string, as in: owata run -c 'make && make test'")
+			}
+			j++
+			result.RunScript = options[j]
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--tail="); ok {
+			n, err := strconv.Atoi(strings.Trim(after, "'\""))
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid --tail value: %s (use a positive number of lines)", after)
+			}
+			result.Tail = n
+			continue
+		}
+		if arg == "--tail-stderr-only" {
+			result.TailStderrOnly = true
+			continue
+		}
+		if arg == "--notify-start" {
+			result.NotifyStart = true
+			continue
+		}
+		if arg == "--stats" {
+			result.Stats = true
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--min-duration="); ok {
+			d, err := time.ParseDuration(strings.Trim(after, "'\""))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --min-duration value: %s (use a duration such as 30s or 5m)", after)
+			}
+			result.MinDuration = d
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--timeout="); ok {
+			d, err := time.ParseDuration(strings.Trim(after, "'\""))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --timeout value: %s (use a duration such as 90s or 2h)", after)
+			}
+			result.Timeout = d
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--min-duration-applies-to="); ok {
+			switch value := strings.Trim(after, "'\""); value {
+			case AppliesToSuccess, AppliesToAll:
+				result.MinDurationAppliesTo = value
+			default:
+				return nil, fmt.Errorf("invalid --min-duration-applies-to value: %s (use success or all)", after)
+			}
+			continue
+		}
+		if arg == "--on-failure" || arg == "--on-success" {
+			notifyOn := strings.TrimPrefix(arg, "--on-")
+			if result.NotifyOn != "" && result.NotifyOn != notifyOn {
+				return nil, fmt.Errorf("--on-failure and --on-success cannot be used together")
+			}
+			result.NotifyOn = notifyOn
+			continue
+		}
+
+		handled, err := parseNotifyOption(result, arg)
+		if err != nil {
+			return nil, err
+		}
+		if !handled {
+			return nil, fmt.Errorf("unknown option for run command: %s (the command to run goes after --)", arg)
+		}
+	}
+
+	switch {
+	case result.RunScript != "" && result.RunCommand != nil:
+		return nil, fmt.Errorf("use either -c '<command>' or -- <command> [args...], not both")
+	case result.RunScript == "" && result.RunCommand == nil:
+		return nil, fmt.Errorf("missing command to run; use 'owata run [options] -- <command> [args...]' or 'owata run [options] -c <command>'")
+	}
+	if result.TailStderrOnly && result.Tail == 0 {
+		return nil, fmt.Errorf("--tail-stderr-only requires --tail=<n>")
+	}
+	if result.MinDurationAppliesTo != "" && result.MinDuration == 0 {
+		return nil, fmt.Errorf("--min-duration-applies-to requires --min-duration=<duration>")
+	}
+
+	if err := validateNotifyOptions(result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func parseWaitPIDArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command:          CommandWaitPID,
+		Retries:          -1,
+		RateLimitRetries: -1,
+	}
+
+	for _, arg := range args {
+		if after, ok := strings.CutPrefix(arg, "--interval="); ok {
+			d, err := time.ParseDuration(strings.Trim(after, "'\""))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --interval value: %s (use a duration such as 500ms or 5s)", after)
+			}
+			result.Interval = d
+			continue
+		}
+
+		handled, err := parseNotifyOption(result, arg)
+		if err != nil {
+			return nil, err
+		}
+		if handled {
+			continue
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unknown option for wait-pid command: %s (use --help for available options)", arg)
+		}
+		if result.PID != 0 {
+			return nil, fmt.Errorf("wait-pid takes a single PID, got extra argument: %s", arg)
+		}
+		pid, err := strconv.Atoi(arg)
+		if err != nil || pid <= 0 {
+			return nil, fmt.Errorf("invalid PID: %s (must be a positive number)", arg)
+		}
+		result.PID = pid
+	}
+
+	if result.PID == 0 {
+		return nil, fmt.Errorf("missing PID; use 'owata wait-pid <pid>' (use --help for more information)")
+	}
+	if err := validateNotifyOptions(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func parseWaitPortArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command:          CommandWaitPort,
+		Retries:          -1,
+		RateLimitRetries: -1,
+	}
+
+	for _, arg := range args {
+		if after, ok := strings.CutPrefix(arg, "--interval="); ok {
+			d, err := time.ParseDuration(strings.Trim(after, "'\""))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --interval value: %s (use a duration such as 500ms or 5s)", after)
+			}
+			result.Interval = d
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--timeout="); ok {
+			d, err := time.ParseDuration(strings.Trim(after, "'\""))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --timeout value: %s (use a duration such as 90s or 2h)", after)
+			}
+			result.Timeout = d
+			continue
+		}
+		if arg == "--until-down" {
+			result.UntilDown = true
+			continue
+		}
+
+		handled, err := parseNotifyOption(result, arg)
+		if err != nil {
+			return nil, err
+		}
+		if handled {
+			continue
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unknown option for wait-port command: %s (use --help for available options)", arg)
+		}
+		if result.Address != "" {
+			return nil, fmt.Errorf("wait-port takes a single address, got extra argument: %s", arg)
+		}
+		host, port, err := net.SplitHostPort(arg)
+		if err != nil || host == "" || port == "" {
+			return nil, fmt.Errorf("invalid address: %s (use host:port, such as localhost:5432 or [::1]:8080)", arg)
+		}
+		result.Address = arg
+	}
+
+	if result.Address == "" {
+		return nil, fmt.Errorf("missing address; use 'owata wait-port <host:port>' (use --help for more information)")
+	}
+	if err := validateNotifyOptions(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// fileEvents are the events watch-file can notify about
+var fileEvents = []string{"create", "modify", "delete"}
+
+func parseWatchFileArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command:          CommandWatchFile,
+		Retries:          -1,
+		RateLimitRetries: -1,
+		Debounce:         -1,
+	}
+
+	for _, arg := range args {
+		if after, ok := strings.CutPrefix(arg, "--event="); ok {
+			for event := range strings.SplitSeq(strings.Trim(after, "'\""), ",") {
+				event = strings.TrimSpace(event)
+				if !slices.Contains(fileEvents, event) {
+					return nil, fmt.Errorf("invalid --event value: %s (use create, modify or delete, separated by commas)", event)
+				}
+				if !slices.Contains(result.Events, event) {
+					result.Events = append(result.Events, event)
+				}
+			}
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--interval="); ok {
+			d, err := time.ParseDuration(strings.Trim(after, "'\""))
+			if err != nil || d <= 0 {
+				return nil, fmt.Errorf("invalid --interval value: %s (use a duration such as 500ms or 5s)", after)
+			}
+			result.Interval = d
+			continue
+		}
+		if after, ok := strings.CutPrefix(arg, "--debounce="); ok {
+			d, err := time.ParseDuration(strings.Trim(after, "'\""))
+			if err != nil || d < 0 {
+				return nil, fmt.Errorf("invalid --debounce value: %s (use a duration such as 0s or 5s)", after)
+			}
+			result.Debounce = d
+			continue
+		}
+		if arg == "--once" {
+			result.Once = true
+			continue
+		}
+
+		handled, err := parseNotifyOption(result, arg)
+		if err != nil {
+			return nil, err
+		}
+		if handled {
+			continue
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unknown option for watch-file command: %s (use --help for available options)", arg)
+		}
+		if result.WatchPath != "" {
+			return nil, fmt.Errorf("watch-file takes a single path, got extra argument: %s", arg)
+		}
+		result.WatchPath = arg
+	}
+
+	if result.WatchPath == "" {
+		return nil, fmt.Errorf("missing path; use 'owata watch-file <path>' (use --help for more information)")
+	}
+	if err := validateNotifyOptions(result); err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func parseCompletionArgs(args []string) (*Args, error) {
+	if len(args) != 1 {
+		return nil, fmt.Errorf("usage: owata completion %s", strings.Join(completionShells, "|"))
+	}
+	if args[0] != "targets" && !slices.Contains(completionShells, args[0]) {
+		return nil, fmt.Errorf("unsupported shell: %s (use %s)", args[0], strings.Join(completionShells, ", "))
+	}
+	return &Args{Command: CommandCompletion, Shell: args[0]}, nil
+}
+
+func parseDeleteArgs(args []string) (*Args, error) {
+	result := &Args{
+		Command: CommandDelete,
+	}
+
+	for _, arg := range args {
+		if after, ok := strings.CutPrefix(arg, "--webhook="); ok {
+			result.WebhookURL = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--webhook-file="); ok {
+			result.WebhookFile = strings.Trim(after, "'\"")
+		} else if after, ok := strings.CutPrefix(arg, "--thread-id="); ok {
+			result.ThreadID = strings.Trim(after, "'\"")
+		} else if arg == "--allow-custom-webhook" {
+			result.AllowCustomWebhook = true
+		} else if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unknown option for delete command: %s (use --help for available options)", arg)
+		} else if result.MessageID != "" {
+			return nil, fmt.Errorf("delete takes a single message ID, got extra argument: %s", arg)
+		} else {
+			result.MessageID = arg
+		}
+	}
+
+	if result.MessageID == "" {
+		return nil, fmt.Errorf("missing message ID; use 'owata delete <message-id>' (use --help for more information)")
+	}
+	for _, r := range result.MessageID {
+		if r < '0' || r > '9' {
+			return nil, fmt.Errorf("invalid message ID: %s (must be numeric)", result.MessageID)
+		}
+	}
+
+	return result, nil
+}
+
+func parseComposeArgs(args []string) (*Args, error) {
+	if len(args) < 1 {
+		return nil, fmt.Errorf("missing compose action; use add-field, add-line, add-file, status, show, clear or send (use --help for more information)")
+	}
+
+	result := &Args{
+		Command:          CommandCompose,
+		ComposeAction:    args[0],
+		Retries:          -1,
+		RateLimitRetries: -1,
+	}
+
+	var positional []string
+	for _, arg := range args[1:] {
+		if after, ok := strings.CutPrefix(arg, "--session="); ok {
+			result.Session = strings.Trim(after, "'\"")
+			continue
+		}
+
+		if result.ComposeAction == ComposeSend {
+			handled, err := parseNotifyOption(result, arg)
+			if err != nil {
+				return nil, err
+			}
+			if handled {
+				continue
+			}
+		}
+
+		if strings.HasPrefix(arg, "-") {
+			return nil, fmt.Errorf("unknown option for compose %s: %s (use --help for available options)", result.ComposeAction, arg)
+		}
+		positional = append(positional, arg)
+	}
+
+	switch result.ComposeAction {
+	case ComposeAddField, ComposeAddFile, ComposeStatus:
+		if len(positional) != 1 {
+			return nil, fmt.Errorf("compose %s takes exactly one argument", result.ComposeAction)
+		}
+	case ComposeAddLine:
+		if len(positional) == 0 {
+			return nil, fmt.Errorf("compose add-line requires the text to add")
+		}
+		positional = []string{strings.Join(positional, " ")}
+	case ComposeShow, ComposeClear, ComposeSend:
+		if len(positional) != 0 {
+			return nil, fmt.Errorf("compose %s takes no arguments, got: %s", result.ComposeAction, strings.Join(positional, " "))
+		}
+		if err := validateNotifyOptions(result); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown compose action: %s (use add-field, add-line, add-file, status, show, clear or send)", result.ComposeAction)
+	}
+
+	result.ComposeArgs = positional
+	return result, nil
+}
+
+// usageEntry is a line of the Commands or Options section of the help. The
+// same tables drive shell completion, so the two can't drift apart.
+type usageEntry struct {
+	Name        string
+	Description string // Lines after the first are indented under it
+}
+
+// usageCommands lists the commands in the help
+var usageCommands = []usageEntry{
+	{"init", "Create local configuration template file"},
+	{"init -g, --global", "Create global configuration template file"},
+	{"init --force", "Back up the existing config to <name>.bak and write a fresh template"},
+	{"init --interactive", "Ask for the webhook URL, username and avatar (the default in a terminal)"},
+	{"init --format=toml", "Write owata-config.toml, with comments, instead of JSON"},
+	{"config", "Show current local configuration"},
+	{"config -g, --global", "Show current global configuration"},
+	{"config --resolved", "Show the merged config notifications use"},
+	{"config --json [--reveal]", "Print the config as JSON, with where each value came from"},
+	{"config --all [--json]", "Show the global and local config, profile and environment side by side with the values used"},
+	{"config --webhook=<url>", "Set Discord webhook URL in local config"},
+	{"config -g --webhook=<url>", "Set Discord webhook URL in global config"},
+	{"config --allow-custom-webhook", "Allow a non-Discord webhook URL in config"},
+	{"config --username=<name>", "Set bot username in local config"},
+	{"config -g --username=<name>", "Set bot username in global config"},
+	{"config --avatar=<url>", "Set avatar URL in local config"},
+	{"config -g --avatar=<url>", "Set avatar URL in global config"},
+	{"config --source=<source>", "Set the default notification source in local config"},
+	{"config get <key> [--reveal]", "Print a config value (secrets masked unless --reveal)"},
+	{"config set <key> <value>", "Set any config value"},
+	{"config unset <key>", "Clear a config value"},
+	{"config edit", "Open the config in $VISUAL or $EDITOR and check it"},
+	{"config path [--resolved]", "Print the config file path (--resolved: the one in use)"},
+	{"run -- <command>", "Run a command and notify when it exits"},
+	{"run -c '<shell command>'", "Run a shell command line through sh -c (cmd.exe /C on Windows)"},
+	{"wait-pid <pid>", "Notify when an already running process exits"},
+	{"wait-port <host:port>", "Notify when a TCP port accepts connections (or stops, with --until-down)"},
+	{"watch-file <path>", "Notify when a file is created, modified or deleted"},
+	{"test", "Send a test message to check the webhook works"},
+	{"doctor [--send]", "Check the config, webhook URL, DNS, connectivity and clock\n(--send: also post a test message)"},
+	{"webhook info", "Show the webhook's own name, avatar and channel"},
+	{"webhook-info", "Same as webhook info"},
+	{"delete <message-id>", "Delete a message sent through the webhook"},
+	{"compose add-field <name=value>", "Add a field to the compose buffer"},
+	{"compose add-line <text>", "Add a message line to the compose buffer"},
+	{"compose add-file <path>", "Attach a file to the composed notification"},
+	{"compose status <text>", "Set the status of the composed notification"},
+	{"compose show|clear", "Show or discard the compose buffer"},
+	{"compose send [options]", "Send the composed notification and clear it"},
+	{"completion bash|zsh|fish", "Print a shell completion script"},
+	{"completion targets", "List the target names in config (used by completion)"},
+}
+
+// usageOptions lists the options in the help
+var usageOptions = []usageEntry{
+	{"--webhook=<url>", "Discord webhook URL (overrides config)"},
+	{"--webhook-file=<path>", "Read the webhook URL from a file, such as a mounted secret"},
+	{"--target=<name>[,<name>]", "Send to named targets from config (overrides default_target)"},
+	{"--provider=<name>", "Webhook service: discord, slack, ntfy, telegram or generic (default: detected from the URL)"},
+	{"--source=<source>", "Set the source of the notification"},
+	{"--no-host", "Leave out the Host field with this machine's hostname"},
+	{"--git", "Add the git branch, commit and dirty state as fields"},
+	{"--no-ci", "Don't add CI repository, job and run link fields"},
+	{"--sysinfo", "Add the OS, architecture and current user as fields"},
+	{"--username=<name>", "Post as this name (overrides username in config)"},
+	{"--avatar=<url>", "Post with this avatar (overrides avatar_url in config)"},
+	{"--no-identity", "Post with the webhook's own name and avatar, ignoring the config"},
+	{"--title=<title>", "Set the embed title (overrides default_title in config)"},
+	{"--mention=<target>", "Ping user:<id>, role:<id>, @here or @everyone (repeatable)"},
+	{"--field=<name>=<value>", "Add an embed field; append :inline to inline it (repeatable)"},
+	{"--thread-id=<id>", "Post into an existing thread (overrides thread_id in config)"},
+	{"--thread-name=<name>", "Create a forum post with this name (forum channels only)"},
+	{"--attach=<path>", "Upload a file with the notification (repeatable, up to 10)"},
+	{"--session=<name>", "Compose buffer to use (default: $OWATA_SESSION, else per shell)"},
+	{"--retry=<n>", "Times to retry network errors and 5xx responses (default: 0)"},
+	{"--rate-limit-retries=<n>", "Times to retry when Discord rate limits (default: 3)"},
+	{"--verbose", "Log requests, responses and retries on stderr"},
+	{"--json", "Print the sent message's IDs as JSON"},
+	{"--dry-run", "Print the payload that would be sent without sending it"},
+	{"-, --stdin", "Read the message from stdin (up to 64 KB)"},
+	{"--message-file=<path>", "Read the message from a file (up to 64 KB)"},
+	{"--newline", "Put each message argument on its own line instead of joining them with spaces"},
+	{"--batch", "Send one notification per line read from stdin"},
+	{"--batch-size=<n>", "Bundle n lines into each notification (implies --batch)"},
+	{"--tail=<n>", "With run, include the last n lines of output in a code block"},
+	{"--tail-stderr-only", "With run and --tail, include only stderr lines"},
+	{"--notify-start", "With run, also notify when the command starts"},
+	{"--on-failure", "With run, notify only if the command fails (overrides notify_on)"},
+	{"--on-success", "With run, notify only if the command succeeds (overrides notify_on)"},
+	{"--min-duration=<duration>", "With run, skip the notification if a successful command was faster"},
+	{"--min-duration-applies-to=success|all", "Also skip fast failures with 'all' (default: success)"},
+	{"--timeout=<duration>", "With run, stop the command after this long and exit with 124;\nwith wait-port, give up after this long and exit with 1"},
+	{"--stats", "With run, include the command's CPU time and peak memory"},
+	{"--interval=<duration>", "With wait-pid, wait-port and watch-file, how often to check (default: 1s)"},
+	{"--until-down", "With wait-port, wait for the port to stop accepting connections"},
+	{"--event=<events>", "With watch-file, only notify about these: create, modify, delete\n(comma-separated; default: all)"},
+	{"--once", "With watch-file, exit after the first notification"},
+	{"--debounce=<duration>", "With watch-file, how long a file must stay unchanged before\nnotifying, so a burst of writes is one notification (default: 1s)"},
+	{"--thumbnail=<url>", "Show a thumbnail image in the embed"},
+	{"--author=<name>", "Show an author at the top of the embed"},
+	{"--author-url=<url>", "Link for the author name"},
+	{"--author-icon=<url>", "Icon shown next to the author name"},
+	{"--plain", "Send the message as plain text without an embed"},
+	{"--code", "Wrap the message in a code block"},
+	{"--lang=<language>", "Highlight the code block as this language (implies --code)"},
+	{"--escape", "Show markdown characters such as * and _ literally"},
+	{"--no-expand", "Send {{...}} placeholders such as {{host}} and {{date}} as written"},
+	{"--footer=<text>", "Footer text (default: Owata)"},
+	{"--footer-icon=<url>", "Icon shown next to the footer text"},
+	{"--suppress-embeds", "Don't unfurl links in a --plain message"},
+	{"--silent", "Post without a push or desktop notification"},
+	{"--loud", "Notify even when silent is set in config"},
+	{"--tts", "Have Discord read the message aloud"},
+	{"--embed-json=<path>", "Send embeds from a JSON file ('-' for stdin); the message is optional"},
+	{"--no-split", "Fail instead of splitting messages over 4096 characters"},
+	{"--no-wait", "Don't wait for Discord to return the created message"},
+	{"--allow-custom-webhook", "Accept webhook URLs that aren't Discord's (e.g. test servers)"},
+	{"--check-urls", "Warn about unreachable avatar/image URLs before sending"},
+	{"--strict", "With --check-urls, fail instead of warning"},
+	{"--run-id=<id>", "Correlate related notifications (default: $OWATA_RUN_ID; generated by run)"},
+	{"--seq=auto|<n>", "Number notifications within a run; 'auto' keeps a counter per run ID"},
+	{"-g, --global", "Use global configuration (in system config directory)"},
+	{"-q, --quiet", "Print nothing but errors and output you asked for (such as --json);\nthe exit code still tells whether it worked"},
+	{"--debug", "Log which config file and webhook are used, and each request's size,\nretries and timing, to stderr (webhook tokens redacted)"},
+	{"--no-emoji", "Print ASCII tags such as [ok] and [error] instead of emoji (the default\nwith NO_COLOR, TERM=dumb or when stdout isn't a terminal)"},
+	{"--config=<path>", "Read and write this config file instead of the local or global one\n(cannot be combined with -g)"},
+	{"--profile=<name>", "Use this profile from the config's profiles (default: $OWATA_PROFILE,\nthen default_profile); with config, update that profile"},
+	{"--help, -h", "Show this help message"},
+	{"--version, -v", "Show version information"},
+	{"--version --json", "Print the version, commit, Go version and platform as JSON"},
+}
+
+// printUsageEntries prints entries with their names padded to width
+func printUsageEntries(entries []usageEntry, width int) {
+	for _, e := range entries {
+		name := e.Name
+		if len(name) > width {
+			// Keep a wide gap before the description of a long name
+			name += " "
+		}
+		lines := strings.Split(e.Description, "\n")
+		fmt.Printf("  %-*s %s\n", width, name, lines[0])
+		for _, line := range lines[1:] {
+			fmt.Printf("  %*s %s\n", width, "", line)
+		}
+	}
+}
+
 func PrintUsage() {
 	fmt.Printf("Owata v%s - Discord Webhook Notifier\n\n", Version)
 	fmt.Println("Usage:")
-	fmt.Println("  owata <message> [--webhook=<url>] [--source=<source>] [-g|--global]")
+	fmt.Println("  owata <message> [--webhook=<url>] [--source=<source>] [--title=<title>] [--run-id=<id>] [--seq=auto|<n>] [-g|--global]")
+	fmt.Println("  <command> | owata - [options]")
+	fmt.Println("  owata [options] -- <message>  (everything after -- is the message, even if it starts with a dash)")
 	fmt.Println("  owata init [-g|--global]")
 	fmt.Println("  owata config [-g|--global] [--webhook=<url>] [--username=<name>] [--avatar=<url>]")
+	fmt.Println("  owata config [-g|--global] get|set|unset <key> [<value>]")
+	fmt.Println("  owata run [options] -- <command> [args...]")
+	fmt.Println("  owata run [options] -c '<shell command>'")
+	fmt.Println("  owata wait-pid <pid> [--interval=<duration>] [options]")
+	fmt.Println("  owata wait-port <host:port> [--until-down] [--interval=<duration>] [--timeout=<duration>] [options]")
+	fmt.Println("  owata watch-file <path> [--event=<events>] [--once] [--interval=<duration>] [--debounce=<duration>] [options]")
+	fmt.Println("  owata test [-g|--global] [--webhook=<url>] [--target=<name>]")
+	fmt.Println("  owata webhook info [-g|--global] [--webhook=<url>] [--json]")
+	fmt.Println("  owata compose <action> [<args>] [--session=<name>]")
+	fmt.Println("  owata delete <message-id> [-g|--global] [--webhook=<url>] [--thread-id=<id>]")
+	fmt.Println("  owata completion bash|zsh|fish")
 	fmt.Println("")
 	fmt.Println("Commands:")
-	fmt.Printf("  %-30s Create local configuration template file\n", "init")
-	fmt.Printf("  %-30s Create global configuration template file\n", "init -g, --global")
-	fmt.Printf("  %-30s Show current local configuration\n", "config")
-	fmt.Printf("  %-30s Show current global configuration\n", "config -g, --global")
-	fmt.Printf("  %-30s Set Discord webhook URL in local config\n", "config --webhook=<url>")
-	fmt.Printf("  %-30s Set Discord webhook URL in global config\n", "config -g --webhook=<url>")
-	fmt.Printf("  %-30s Set bot username in local config\n", "config --username=<name>")
-	fmt.Printf("  %-30s Set bot username in global config\n", "config -g --username=<name>")
-	fmt.Printf("  %-30s Set avatar URL in local config\n", "config --avatar=<url>")
-	fmt.Printf("  %-30s Set avatar URL in global config\n", "config -g --avatar=<url>")
+	printUsageEntries(usageCommands, 30)
 	fmt.Println("")
 	fmt.Println("Arguments:")
 	fmt.Println("  message                    The notification message to send")
 	fmt.Println("")
 	fmt.Println("Options:")
-	fmt.Println("  --webhook=<url>            Discord webhook URL (overrides config)")
-	fmt.Println("  --source=<source>          Set the source of the notification")
-	fmt.Println("  -g, --global               Use global configuration (in system config directory)")
-	fmt.Println("  --help, -h                 Show this help message")
-	fmt.Println("  --version, -v              Show version information")
+	fmt.Println("  (options that take a value also accept --option <value>)")
+	printUsageEntries(usageOptions, 26)
 	fmt.Println("")
 	fmt.Println("Examples:")
 	fmt.Println("  owata init                 # Create local config template")
@@ -177,11 +1428,35 @@ func PrintUsage() {
 	fmt.Println("  owata config -g            # Show current global settings")
 	fmt.Println("  owata config --webhook='https://discord.com/api/webhooks/...'")
 	fmt.Println("  owata config -g --username='GlobalBot'")
+	fmt.Println("  owata config set notify_on failure # Set any key; 'config get' prints it for scripts")
 	fmt.Println("  owata 'Task completed!'    # Send notification (using config)")
 	fmt.Println("  owata 'Build finished' --webhook='https://...' --source='CI'")
+	fmt.Println("  owata 'All green' --title='Deploy finished'")
+	fmt.Println("  owata 'prod deploy failed' --mention=@here --mention=role:123456789")
+	fmt.Println("  owata 'Compile failed' --attach=build.log")
+	fmt.Println("  owata --title=Deploy -- '--- deploy log ---' # A message starting with a dash")
+	fmt.Println("  make 2>&1 | tail -20 | owata - --code # Send the end of a build log")
+	fmt.Println("  owata --message-file=release-notes.md --title='v2.1 released'")
+	fmt.Println("  owata 'nightly report' --thread-name='2024-06-01 report'")
+	fmt.Println("  owata compose add-field env=prod && owata compose send --title='Release 1.4'")
 	fmt.Println("  owata 'Task completed!' -g # Send notification using global config")
-}
-
-func PrintVersion() {
-	fmt.Printf("Owata v%s\n", Version)
+	fmt.Println("  owata run -- make -j8 test # Notify when the tests finish, passing or failing")
+	fmt.Println("  owata wait-pid $(pgrep -n rsync) # Notify when a transfer you already started ends")
+	fmt.Println("  owata wait-port localhost:5432 --timeout=2h # Notify when postgres is back")
+	fmt.Println("  owata watch-file ./out/report.pdf --event=create --once # Notify when the report appears")
+	fmt.Println("  source <(owata completion bash) # Tab-complete commands, options and targets")
+	fmt.Println("  owata -q 'Backup done' || echo 'notification failed' # For scripts")
+	fmt.Println("  owata run -c 'make && make test' # Quote the whole line: with --, your shell runs")
+	fmt.Println("                             # 'make test' itself, after owata exits")
+	fmt.Println("  owata run -c 'tar czf backup.tgz \"$DIR\"' # Single quotes leave $DIR to sh -c")
+	fmt.Println("  owata 'Tests passed' --run-id=$RUN --seq=auto")
+	fmt.Println("  owata 'heartbeat: backup ok' --silent")
+	fmt.Println("  owata 'db migration done' --target=ops,dev")
+	fmt.Println("  owata 'Deploy done' --webhook='https://hooks.slack.com/services/...'")
+	fmt.Println("  owata 'Backup finished' --webhook='https://ntfy.sh/my-topic'")
+	fmt.Println("")
+	fmt.Println("Exit codes:")
+	fmt.Println("  0 success, 1 other failure, 2 invalid arguments, 3 config missing or invalid,")
+	fmt.Println("  4 network failure or 5xx, 5 request rejected (4xx), 6 rate limited, 130 cancelled;")
+	fmt.Println("  run exits with the command's own code")
 }
@@ -4,8 +4,11 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"reflect"
+	"slices"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParse(t *testing.T) {
@@ -41,6 +44,11 @@ func TestParse(t *testing.T) {
 			args:        []string{"--version"},
 			expectedCmd: CommandShowVersion,
 		},
+		{
+			name:        "Version as JSON",
+			args:        []string{"--version", "--json"},
+			expectedCmd: CommandShowVersion,
+		},
 		{
 			name:           "Init command",
 			args:           []string{"init"},
@@ -71,6 +79,291 @@ func TestParse(t *testing.T) {
 			expectedCmd:    CommandConfig,
 			expectedGlobal: true,
 		},
+		{
+			name:        "Webhook info command",
+			args:        []string{"webhook", "info"},
+			expectedCmd: CommandWebhookInfo,
+		},
+		{
+			name:        "Webhook info command with JSON output",
+			args:        []string{"webhook", "info", "--json"},
+			expectedCmd: CommandWebhookInfo,
+		},
+		{
+			name:        "Webhook info alias",
+			args:        []string{"webhook-info", "--json"},
+			expectedCmd: CommandWebhookInfo,
+		},
+		{
+			name:        "Webhook info alias with unknown option",
+			args:        []string{"webhook-info", "info"},
+			expectedErr: true,
+		},
+		{
+			name:        "Webhook command without action",
+			args:        []string{"webhook"},
+			expectedErr: true,
+		},
+		{
+			name:        "Webhook info with unknown option",
+			args:        []string{"webhook", "info", "--source=x"},
+			expectedErr: true,
+		},
+		{
+			name:        "Test command",
+			args:        []string{"test"},
+			expectedCmd: CommandTest,
+		},
+		{
+			name:        "Test command with webhook and verbose",
+			args:        []string{"test", "--webhook=https://example.com", "--verbose"},
+			expectedCmd: CommandTest,
+		},
+		{
+			name:        "Test command with a message",
+			args:        []string{"test", "hello"},
+			expectedErr: true,
+		},
+		{
+			name:        "Test command with unknown option",
+			args:        []string{"test", "--source=x"},
+			expectedErr: true,
+		},
+		{
+			name:        "Doctor command",
+			args:        []string{"doctor", "--send", "--target=ops"},
+			expectedCmd: CommandDoctor,
+		},
+		{
+			name:        "Doctor command with unknown option",
+			args:        []string{"doctor", "--fix"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command",
+			args:        []string{"run", "--source=CI", "--", "make", "-j8", "test"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command keeps the command's own flags",
+			args:        []string{"run", "--", "gcc", "-g", "--help", "main.c"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command without separator",
+			args:        []string{"run", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command without a command",
+			args:        []string{"run", "--"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with tail",
+			args:        []string{"run", "--tail=30", "--tail-stderr-only", "--", "make"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command with start notification",
+			args:        []string{"run", "--notify-start", "--", "terraform", "apply"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command notifying only on failure",
+			args:        []string{"run", "--on-failure", "--", "backup.sh"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command with both outcome filters",
+			args:        []string{"run", "--on-failure", "--on-success", "--", "backup.sh"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with minimum duration",
+			args:        []string{"run", "--min-duration=30s", "--min-duration-applies-to=all", "--", "make"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command with invalid minimum duration",
+			args:        []string{"run", "--min-duration=30", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with invalid min duration target",
+			args:        []string{"run", "--min-duration=30s", "--min-duration-applies-to=failure", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with min duration target but no min duration",
+			args:        []string{"run", "--min-duration-applies-to=all", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with timeout",
+			args:        []string{"run", "--timeout=2h", "--", "make"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command from a shell string",
+			args:        []string{"run", "-c", "make && make test"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command with -c but no string",
+			args:        []string{"run", "-c"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with both -c and --",
+			args:        []string{"run", "-c", "make", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with nothing after --",
+			args:        []string{"run", "--tail=5", "--"},
+			expectedErr: true,
+		},
+		{
+			name:        "Wait for a PID",
+			args:        []string{"wait-pid", "4242", "--interval=5s", "--title=Backup done"},
+			expectedCmd: CommandWaitPID,
+		},
+		{
+			name:        "Wait for a PID without one",
+			args:        []string{"wait-pid", "--interval=5s"},
+			expectedErr: true,
+		},
+		{
+			name:        "Wait for an invalid PID",
+			args:        []string{"wait-pid", "-1"},
+			expectedErr: true,
+		},
+		{
+			name:        "Wait for two PIDs",
+			args:        []string{"wait-pid", "1", "2"},
+			expectedErr: true,
+		},
+		{
+			name:        "Wait for a PID with an invalid interval",
+			args:        []string{"wait-pid", "4242", "--interval=0s"},
+			expectedErr: true,
+		},
+		{
+			name:        "Wait for a port",
+			args:        []string{"wait-port", "localhost:5432", "--timeout=2h", "--interval=5s"},
+			expectedCmd: CommandWaitPort,
+		},
+		{
+			name:        "Wait for an IPv6 port to go down",
+			args:        []string{"wait-port", "[::1]:8080", "--until-down"},
+			expectedCmd: CommandWaitPort,
+		},
+		{
+			name:        "Wait for a port without an address",
+			args:        []string{"wait-port", "--until-down"},
+			expectedErr: true,
+		},
+		{
+			name:        "Wait for a port without a port number",
+			args:        []string{"wait-port", "localhost"},
+			expectedErr: true,
+		},
+		{
+			name:        "Wait for a port with an invalid timeout",
+			args:        []string{"wait-port", "localhost:5432", "--timeout=soon"},
+			expectedErr: true,
+		},
+		{
+			name:        "Watch a file",
+			args:        []string{"watch-file", "./out/report.pdf", "--event=create,modify", "--once", "--debounce=0s"},
+			expectedCmd: CommandWatchFile,
+		},
+		{
+			name:        "Watch a file without a path",
+			args:        []string{"watch-file", "--once"},
+			expectedErr: true,
+		},
+		{
+			name:        "Watch a file for an unknown event",
+			args:        []string{"watch-file", "report.pdf", "--event=rename"},
+			expectedErr: true,
+		},
+		{
+			name:        "Watch two files",
+			args:        []string{"watch-file", "a.pdf", "b.pdf"},
+			expectedErr: true,
+		},
+		{
+			name:        "Watch a file with a negative debounce",
+			args:        []string{"watch-file", "report.pdf", "--debounce=-1s"},
+			expectedErr: true,
+		},
+		{
+			name:        "Completion script",
+			args:        []string{"completion", "zsh"},
+			expectedCmd: CommandCompletion,
+		},
+		{
+			name:        "Completion target names",
+			args:        []string{"completion", "targets", "-g"},
+			expectedCmd: CommandCompletion,
+		},
+		{
+			name:        "Completion without a shell",
+			args:        []string{"completion"},
+			expectedErr: true,
+		},
+		{
+			name:        "Completion for an unsupported shell",
+			args:        []string{"completion", "powershell"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with stats",
+			args:        []string{"run", "--stats", "--", "make"},
+			expectedCmd: CommandRun,
+		},
+		{
+			name:        "Run command with invalid timeout",
+			args:        []string{"run", "--timeout=0s", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with invalid tail",
+			args:        []string{"run", "--tail=0", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with stderr-only tail but no tail",
+			args:        []string{"run", "--tail-stderr-only", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Run command with unknown option",
+			args:        []string{"run", "--bogus", "--", "make"},
+			expectedErr: true,
+		},
+		{
+			name:        "Delete command",
+			args:        []string{"delete", "1234567890"},
+			expectedCmd: CommandDelete,
+		},
+		{
+			name:        "Delete command without message ID",
+			args:        []string{"delete"},
+			expectedErr: true,
+		},
+		{
+			name:        "Delete command with non-numeric message ID",
+			args:        []string{"delete", "abc"},
+			expectedErr: true,
+		},
+		{
+			name:        "Delete command with two message IDs",
+			args:        []string{"delete", "1", "2"},
+			expectedErr: true,
+		},
 		{
 			name:        "Notify command",
 			args:        []string{"Hello world"},
@@ -86,6 +379,31 @@ func TestParse(t *testing.T) {
 			args:        []string{"Hello world", "--source=Test"},
 			expectedCmd: CommandNotify,
 		},
+		{
+			name:        "Notify command with URL checks",
+			args:        []string{"Hello world", "--check-urls", "--strict"},
+			expectedCmd: CommandNotify,
+		},
+		{
+			name:        "Notify command with attachments",
+			args:        []string{"Compile failed", "--attach=build.log", "--attach=test.log"},
+			expectedCmd: CommandNotify,
+		},
+		{
+			name:        "Notify command with thread ID",
+			args:        []string{"Hello world", "--thread-id=1234567890"},
+			expectedCmd: CommandNotify,
+		},
+		{
+			name:        "Notify command with thread name",
+			args:        []string{"nightly report", "--thread-name='2024-06-01 report'"},
+			expectedCmd: CommandNotify,
+		},
+		{
+			name:        "Notify command with thread ID and thread name",
+			args:        []string{"nightly report", "--thread-id=123", "--thread-name=report"},
+			expectedErr: true,
+		},
 		{
 			name:        "Notify command with invalid option",
 			args:        []string{"Hello world", "--invalid=option"},
@@ -120,6 +438,76 @@ func TestParse(t *testing.T) {
 	}
 }
 
+func TestParseConfigKeyArgs(t *testing.T) {
+	tests := []struct {
+		name             string
+		args             []string
+		expectedErr      bool
+		expectedAction   string
+		expectedArgs     []string
+		expectedReveal   bool
+		expectedResolved bool
+		expectedJSON     bool
+		expectedAll      bool
+	}{
+		{name: "Get", args: []string{"config", "get", "webhook_url"}, expectedAction: "get", expectedArgs: []string{"webhook_url"}},
+		{name: "Get revealed", args: []string{"config", "get", "webhook_url", "--reveal"}, expectedAction: "get", expectedArgs: []string{"webhook_url"}, expectedReveal: true},
+		{name: "Set", args: []string{"config", "set", "notify_on", "failure"}, expectedAction: "set", expectedArgs: []string{"notify_on", "failure"}},
+		{name: "Set a value starting with a dash", args: []string{"config", "set", "default_title", "-- nightly --"}, expectedAction: "set", expectedArgs: []string{"default_title", "-- nightly --"}},
+		{name: "Unset", args: []string{"config", "unset", "username"}, expectedAction: "unset", expectedArgs: []string{"username"}},
+		{name: "Edit", args: []string{"config", "edit"}, expectedAction: "edit"},
+		{name: "Edit global", args: []string{"config", "-g", "edit"}, expectedAction: "edit"},
+		{name: "Edit with a key", args: []string{"config", "edit", "username"}, expectedErr: true},
+		{name: "Path", args: []string{"config", "path"}, expectedAction: "path"},
+		{name: "Path resolved", args: []string{"config", "path", "--resolved"}, expectedAction: "path", expectedResolved: true},
+		{name: "Show resolved", args: []string{"config", "--resolved"}, expectedResolved: true},
+		{name: "Show resolved global", args: []string{"config", "-g", "--resolved"}, expectedResolved: true},
+		{name: "Show as JSON", args: []string{"config", "--json"}, expectedJSON: true},
+		{name: "Show as JSON revealed", args: []string{"config", "--resolved", "--json", "--reveal"}, expectedResolved: true, expectedJSON: true, expectedReveal: true},
+		{name: "All layers", args: []string{"config", "--all", "--json"}, expectedJSON: true, expectedAll: true},
+		{name: "JSON with get", args: []string{"config", "get", "username", "--json"}, expectedErr: true},
+		{name: "All with get", args: []string{"config", "get", "username", "--all"}, expectedErr: true},
+		{name: "Resolved with get", args: []string{"config", "get", "username", "--resolved"}, expectedErr: true},
+		{name: "Get without a key", args: []string{"config", "get"}, expectedErr: true},
+		{name: "Set without a value", args: []string{"config", "set", "username"}, expectedErr: true},
+		{name: "Unset two keys", args: []string{"config", "unset", "username", "avatar_url"}, expectedErr: true},
+		{name: "Reveal with set", args: []string{"config", "set", "--reveal", "x"}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.Command != CommandConfig || args.ConfigAction != tt.expectedAction {
+				t.Errorf("Expected config %s, got %v %q", tt.expectedAction, args.Command, args.ConfigAction)
+			}
+			if !slices.Equal(args.ConfigArgs, tt.expectedArgs) {
+				t.Errorf("Expected args %q, got %q", tt.expectedArgs, args.ConfigArgs)
+			}
+			if args.Reveal != tt.expectedReveal {
+				t.Errorf("Expected Reveal=%v, got %v", tt.expectedReveal, args.Reveal)
+			}
+			if args.Resolved != tt.expectedResolved {
+				t.Errorf("Expected Resolved=%v, got %v", tt.expectedResolved, args.Resolved)
+			}
+			if args.JSON != tt.expectedJSON {
+				t.Errorf("Expected JSON=%v, got %v", tt.expectedJSON, args.JSON)
+			}
+			if args.All != tt.expectedAll {
+				t.Errorf("Expected All=%v, got %v", tt.expectedAll, args.All)
+			}
+		})
+	}
+}
+
 func TestParseConfigArgs(t *testing.T) {
 	tests := []struct {
 		name            string
@@ -129,6 +517,7 @@ func TestParseConfigArgs(t *testing.T) {
 		expectedWebhook string
 		expectedUser    string
 		expectedAvatar  string
+		expectedSource  string
 	}{
 		{
 			name: "Empty args",
@@ -159,6 +548,11 @@ func TestParseConfigArgs(t *testing.T) {
 			args:           []string{"--avatar=https://example.com/avatar.png"},
 			expectedAvatar: "https://example.com/avatar.png",
 		},
+		{
+			name:           "Default source",
+			args:           []string{"--source=backend-ci"},
+			expectedSource: "backend-ci",
+		},
 		{
 			name:            "Multiple arguments",
 			args:            []string{"--webhook=https://example.com", "--username=TestUser"},
@@ -211,92 +605,1017 @@ func TestParseConfigArgs(t *testing.T) {
 			if args.AvatarURL != tt.expectedAvatar {
 				t.Errorf("Expected AvatarURL=%q, got %q", tt.expectedAvatar, args.AvatarURL)
 			}
+
+			if args.Source != tt.expectedSource {
+				t.Errorf("Expected Source=%q, got %q", tt.expectedSource, args.Source)
+			}
 		})
 	}
 }
 
-func TestParseNotifyArgs(t *testing.T) {
+func TestParseComposeArgs(t *testing.T) {
 	tests := []struct {
 		name            string
 		args            []string
 		expectedErr     bool
-		expectedMessage string
-		expectedSource  string
-		expectedWebhook string
+		expectedAction  string
+		expectedArgs    []string
+		expectedSession string
+		expectedTitle   string
 	}{
-		{
-			name:        "Empty args",
-			args:        []string{},
-			expectedErr: true,
-		},
-		{
-			name:            "Message only",
-			args:            []string{"Hello world"},
-			expectedMessage: "Hello world",
-			expectedSource:  "Unknown", // Default source
-		},
-		{
-			name:            "Message with webhook",
-			args:            []string{"Hello world", "--webhook=https://example.com"},
-			expectedMessage: "Hello world",
-			expectedSource:  "Unknown", // Default source
-			expectedWebhook: "https://example.com",
-		},
-		{
-			name:            "Message with source",
-			args:            []string{"Hello world", "--source=Test"},
-			expectedMessage: "Hello world",
-			expectedSource:  "Test",
-		},
-		{
-			name:            "Message with webhook and source",
-			args:            []string{"Hello world", "--webhook=https://example.com", "--source=Test"},
-			expectedMessage: "Hello world",
-			expectedSource:  "Test",
-			expectedWebhook: "https://example.com",
-		},
-		{
-			name:            "Message with quoted source",
-			args:            []string{"Hello world", "--source='Test Source'"},
-			expectedMessage: "Hello world",
-			expectedSource:  "Test Source",
-		},
-		{
-			name:        "Message with unknown flag",
-			args:        []string{"Hello world", "--unknown=value"},
-			expectedErr: true,
-		},
+		{name: "Missing action", args: []string{}, expectedErr: true},
+		{name: "Unknown action", args: []string{"publish"}, expectedErr: true},
+		{name: "Add field", args: []string{"add-field", "env=prod"}, expectedAction: ComposeAddField, expectedArgs: []string{"env=prod"}},
+		{name: "Add field without value", args: []string{"add-field"}, expectedErr: true},
+		{name: "Add line joins words", args: []string{"add-line", "migrations:", "ok"}, expectedAction: ComposeAddLine, expectedArgs: []string{"migrations: ok"}},
+		{name: "Session", args: []string{"show", "--session=release"}, expectedAction: ComposeShow, expectedSession: "release"},
+		{name: "Show with arguments", args: []string{"show", "extra"}, expectedErr: true},
+		{name: "Send with notify options", args: []string{"send", "--title='Release 1.4'"}, expectedAction: ComposeSend, expectedTitle: "Release 1.4"},
+		{name: "Notify options only on send", args: []string{"add-line", "x", "--title=y"}, expectedErr: true},
 	}
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			args, err := parseNotifyArgs(tt.args)
+			args, err := parseComposeArgs(tt.args)
 			if tt.expectedErr {
 				if err == nil {
 					t.Errorf("Expected error, got nil")
 				}
 				return
 			}
-
 			if err != nil {
 				t.Fatalf("Unexpected error: %v", err)
 			}
 
-			if args.Command != CommandNotify {
-				t.Errorf("Expected command type CommandNotify, got %v", args.Command)
+			if args.Command != CommandCompose {
+				t.Errorf("Expected command type CommandCompose, got %v", args.Command)
 			}
-
-			if args.Message != tt.expectedMessage {
-				t.Errorf("Expected Message=%q, got %q", tt.expectedMessage, args.Message)
+			if args.ComposeAction != tt.expectedAction {
+				t.Errorf("Expected action %q, got %q", tt.expectedAction, args.ComposeAction)
 			}
-
-			if args.Source != tt.expectedSource {
-				t.Errorf("Expected Source=%q, got %q", tt.expectedSource, args.Source)
+			if len(args.ComposeArgs) != 0 || len(tt.expectedArgs) != 0 {
+				if !reflect.DeepEqual(args.ComposeArgs, tt.expectedArgs) {
+					t.Errorf("Expected args %q, got %q", tt.expectedArgs, args.ComposeArgs)
+				}
 			}
-
-			if args.WebhookURL != tt.expectedWebhook {
+			if args.Session != tt.expectedSession {
+				t.Errorf("Expected session %q, got %q", tt.expectedSession, args.Session)
+			}
+			if args.Title != tt.expectedTitle {
+				t.Errorf("Expected title %q, got %q", tt.expectedTitle, args.Title)
+			}
+		})
+	}
+}
+
+func TestParseRunArgs(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		expectedCommand []string
+		expectedScript  string
+		expectedSource  string
+		expectedGlobal  bool
+	}{
+		{
+			name:            "Command only",
+			args:            []string{"run", "--", "make", "test"},
+			expectedCommand: []string{"make", "test"},
+		},
+		{
+			name:            "Options before the separator",
+			args:            []string{"run", "-g", "--source=nightly", "--", "./backup.sh", "--full"},
+			expectedCommand: []string{"./backup.sh", "--full"},
+			expectedSource:  "nightly",
+			expectedGlobal:  true,
+		},
+		{
+			name:           "Shell string",
+			args:           []string{"run", "--source=ci", "-c", "make && make test"},
+			expectedScript: "make && make test",
+			expectedSource: "ci",
+		},
+		{
+			name:            "Flags after the separator belong to the command",
+			args:            []string{"run", "--", "go", "test", "-v", "-g", "--version", "--", "x"},
+			expectedCommand: []string{"go", "test", "-v", "-g", "--version", "--", "x"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.Command != CommandRun {
+				t.Fatalf("Expected CommandRun, got %v", args.Command)
+			}
+			if !slices.Equal(args.RunCommand, tt.expectedCommand) {
+				t.Errorf("Expected command %q, got %q", tt.expectedCommand, args.RunCommand)
+			}
+			if args.RunScript != tt.expectedScript {
+				t.Errorf("Expected script %q, got %q", tt.expectedScript, args.RunScript)
+			}
+			if args.Source != tt.expectedSource {
+				t.Errorf("Expected source %q, got %q", tt.expectedSource, args.Source)
+			}
+			if args.Global != tt.expectedGlobal {
+				t.Errorf("Expected Global=%v, got %v", tt.expectedGlobal, args.Global)
+			}
+		})
+	}
+}
+
+func TestParseSpaceSeparatedValues(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     []string
+		expected Args
+	}{
+		{
+			name:     "Notify options",
+			args:     []string{"--webhook", "https://discord.com/api/webhooks/1/abc", "--source", "CI", "Build done", "--username", "'Bot'"},
+			expected: Args{Command: CommandNotify, WebhookURL: "https://discord.com/api/webhooks/1/abc", Source: "CI", Message: "Build done", Username: "Bot"},
+		},
+		{
+			name:     "Both forms mixed",
+			args:     []string{"--title=Deploy", "Done", "--avatar", "https://example.com/a.png"},
+			expected: Args{Command: CommandNotify, Title: "Deploy", Message: "Done", AvatarURL: "https://example.com/a.png"},
+		},
+		{
+			name:     "Config options",
+			args:     []string{"config", "--webhook", "https://discord.com/api/webhooks/1/abc", "--username", "MyBot"},
+			expected: Args{Command: CommandConfig, WebhookURL: "https://discord.com/api/webhooks/1/abc", Username: "MyBot"},
+		},
+		{
+			name:     "Value that looks like a flag",
+			args:     []string{"--title", "--dry-run", "Done"},
+			expected: Args{Command: CommandNotify, Title: "--dry-run", Message: "Done"},
+		},
+		{
+			name:     "Value that looks like a global flag",
+			args:     []string{"--source", "-g", "Done"},
+			expected: Args{Command: CommandNotify, Source: "-g", Message: "Done"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := Args{
+				Command:    args.Command,
+				WebhookURL: args.WebhookURL,
+				Source:     args.Source,
+				Message:    args.Message,
+				Username:   args.Username,
+				AvatarURL:  args.AvatarURL,
+				Title:      args.Title,
+				Global:     args.Global,
+				DryRun:     args.DryRun,
+			}
+			if !reflect.DeepEqual(got, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+
+	// A value flag at the end has nothing to take
+	if _, err := Parse([]string{"Done", "--title"}); err == nil || !strings.Contains(err.Error(), "--title requires a value") {
+		t.Errorf("Expected a missing value error, got %v", err)
+	}
+	// Arguments after -- belong to the command, not to owata
+	args, err := Parse([]string{"run", "--", "git", "commit", "--author", "me"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !slices.Equal(args.RunCommand, []string{"git", "commit", "--author", "me"}) {
+		t.Errorf("Expected the command untouched, got %q", args.RunCommand)
+	}
+}
+
+func TestParseQuiet(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		quiet   bool
+		message string
+	}{
+		{name: "Not given", args: []string{"Build done"}, message: "Build done"},
+		{name: "Short form", args: []string{"-q", "Build done"}, quiet: true, message: "Build done"},
+		{name: "Long form after the message", args: []string{"Build done", "--quiet"}, quiet: true, message: "Build done"},
+		{name: "Before a subcommand", args: []string{"--quiet", "config", "--username=Bot"}, quiet: true},
+		{name: "After -- belongs to the command", args: []string{"run", "--", "grep", "-q", "x"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.Quiet != tt.quiet {
+				t.Errorf("Expected Quiet=%v, got %v", tt.quiet, args.Quiet)
+			}
+			if args.Message != tt.message {
+				t.Errorf("Expected message %q, got %q", tt.message, args.Message)
+			}
+		})
+	}
+}
+
+func TestParseDoctorArgs(t *testing.T) {
+	args, err := Parse([]string{"-g", "doctor", "--send", "--webhook", "https://discord.com/api/webhooks/1/t"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if args.Command != CommandDoctor || !args.Send || !args.Global || args.WebhookURL != "https://discord.com/api/webhooks/1/t" {
+		t.Errorf("Unexpected args: %+v", args)
+	}
+	if args.Retries != -1 || args.RateLimitRetries != -1 {
+		t.Errorf("Expected retries left to the config, got %d and %d", args.Retries, args.RateLimitRetries)
+	}
+}
+
+// TestParseEndOfOptions tests that everything after -- is the message, even
+// when it looks like one of owata's own flags or commands
+func TestParseEndOfOptions(t *testing.T) {
+	tests := []struct {
+		name            string
+		args            []string
+		expectedMessage string
+		expectedTitle   string
+		expectedGlobal  bool
+		expectedErr     string
+	}{
+		{name: "Dashes", args: []string{"--", "--- deploy log ---"}, expectedMessage: "--- deploy log ---"},
+		{name: "Our own flag", args: []string{"--", "--dry-run"}, expectedMessage: "--dry-run"},
+		{name: "Help and version", args: []string{"--", "--help", "-v"}, expectedMessage: "--help -v"},
+		{name: "Global flag", args: []string{"--", "-g"}, expectedMessage: "-g"},
+		{name: "Value flag", args: []string{"--", "--title", "x"}, expectedMessage: "--title x"},
+		{name: "Stdin dash", args: []string{"--", "-"}, expectedMessage: "-"},
+		{name: "Command name", args: []string{"--", "init"}, expectedMessage: "init"},
+		{name: "Options before", args: []string{"-g", "--title=Deploy", "--", "--- done ---"}, expectedMessage: "--- done ---", expectedTitle: "Deploy", expectedGlobal: true},
+		{name: "Message on both sides", args: []string{"build", "--", "--release"}, expectedMessage: "build --release"},
+		{name: "Nothing after", args: []string{"--"}, expectedErr: "missing required message"},
+		{name: "Dash without separator", args: []string{"--- deploy log ---"}, expectedErr: "put -- before a message starting with a dash"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.Command != CommandNotify || args.Message != tt.expectedMessage {
+				t.Errorf("Expected notify with message %q, got %v with %q", tt.expectedMessage, args.Command, args.Message)
+			}
+			if args.Title != tt.expectedTitle || args.Global != tt.expectedGlobal || args.DryRun || args.Stdin {
+				t.Errorf("Expected only the options before -- to apply, got %+v", args)
+			}
+		})
+	}
+}
+
+// TestParseNoEmoji tests the global output flags, --no-emoji and --debug
+func TestParseNoEmoji(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		noEmoji bool
+		debug   bool
+	}{
+		{name: "Not given", args: []string{"Build done"}},
+		{name: "With a message", args: []string{"Build done", "--no-emoji"}, noEmoji: true},
+		{name: "Before a subcommand", args: []string{"--no-emoji", "config"}, noEmoji: true},
+		{name: "After -- belongs to the command", args: []string{"run", "--", "tool", "--no-emoji", "--debug"}},
+		{name: "Debug", args: []string{"--debug", "Build done"}, debug: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.NoEmoji != tt.noEmoji || args.Debug != tt.debug {
+				t.Errorf("Expected NoEmoji=%v Debug=%v, got %v %v", tt.noEmoji, tt.debug, args.NoEmoji, args.Debug)
+			}
+		})
+	}
+}
+
+func TestParseConfigPath(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    string
+		expectedErr string
+		command     CommandType
+	}{
+		{name: "Not given", args: []string{"Build done"}, command: CommandNotify},
+		{name: "With a notification", args: []string{"Build done", "--config=/etc/owata/prod.json"}, expected: "/etc/owata/prod.json", command: CommandNotify},
+		{name: "Space-separated before a subcommand", args: []string{"--config", "prod.json", "config", "--username=Bot"}, expected: "prod.json", command: CommandConfig},
+		{name: "Quoted", args: []string{"config", "--config='my config.json'"}, expected: "my config.json", command: CommandConfig},
+		{name: "With -g", args: []string{"-g", "config", "--config=prod.json"}, expectedErr: "cannot be combined with -g"},
+		{name: "Empty", args: []string{"Build done", "--config="}, expectedErr: "requires a path"},
+		{name: "Missing value", args: []string{"Build done", "--config"}, expectedErr: "requires a value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.ConfigPath != tt.expected {
+				t.Errorf("Expected ConfigPath %q, got %q", tt.expected, args.ConfigPath)
+			}
+			if args.Command != tt.command {
+				t.Errorf("Expected command %v, got %v", tt.command, args.Command)
+			}
+		})
+	}
+}
+
+func TestParseProfile(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    string
+		expectedErr string
+	}{
+		{name: "Not given", args: []string{"Build done"}},
+		{name: "With a notification", args: []string{"Build done", "--profile=work"}, expected: "work"},
+		{name: "Space-separated with config", args: []string{"config", "--profile", "work", "--webhook=https://discord.com/api/webhooks/1/abc"}, expected: "work"},
+		{name: "With run", args: []string{"run", "--profile=home", "--", "make", "--profile=other"}, expected: "home"},
+		{name: "Empty", args: []string{"Build done", "--profile="}, expectedErr: "requires a name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.Profile != tt.expected {
+				t.Errorf("Expected Profile %q, got %q", tt.expected, args.Profile)
+			}
+		})
+	}
+}
+
+func TestParseInitArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		expectedForce  bool
+		expectedGlobal bool
+		expectedAsk    bool
+		expectedFormat string
+		expectedErr    bool
+	}{
+		{name: "Plain", args: []string{"init"}},
+		{name: "TOML", args: []string{"init", "--format=toml"}, expectedFormat: "toml"},
+		{name: "Format as a separate value", args: []string{"init", "--format", "JSON"}, expectedFormat: "json"},
+		{name: "Unknown format", args: []string{"init", "--format=yaml"}, expectedErr: true},
+		{name: "Force", args: []string{"init", "--force"}, expectedForce: true},
+		{name: "Force global", args: []string{"-g", "init", "--force"}, expectedForce: true, expectedGlobal: true},
+		{name: "Interactive", args: []string{"init", "--interactive"}, expectedAsk: true},
+		{name: "Interactive replacing", args: []string{"init", "--interactive", "--force"}, expectedForce: true, expectedAsk: true},
+		{name: "Unknown option", args: []string{"init", "--overwrite"}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.Command != CommandInit || args.Force != tt.expectedForce || args.Global != tt.expectedGlobal || args.Interactive != tt.expectedAsk {
+				t.Errorf("Expected init with Force=%v Global=%v Interactive=%v, got %+v", tt.expectedForce, tt.expectedGlobal, tt.expectedAsk, args)
+			}
+			if args.Format != tt.expectedFormat {
+				t.Errorf("Expected format %q, got %q", tt.expectedFormat, args.Format)
+			}
+		})
+	}
+}
+
+func TestParseWatchFileArgs(t *testing.T) {
+	tests := []struct {
+		name             string
+		args             []string
+		expectedPath     string
+		expectedEvents   []string
+		expectedOnce     bool
+		expectedDebounce time.Duration
+	}{
+		{
+			name:             "Defaults",
+			args:             []string{"watch-file", "report.pdf"},
+			expectedPath:     "report.pdf",
+			expectedDebounce: -1,
+		},
+		{
+			name:             "Events are merged without duplicates",
+			args:             []string{"watch-file", "--event=create, modify", "--event=create", "out/report.pdf", "--once", "--debounce=0s"},
+			expectedPath:     "out/report.pdf",
+			expectedEvents:   []string{"create", "modify"},
+			expectedOnce:     true,
+			expectedDebounce: 0,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.WatchPath != tt.expectedPath {
+				t.Errorf("Expected path %q, got %q", tt.expectedPath, args.WatchPath)
+			}
+			if !slices.Equal(args.Events, tt.expectedEvents) {
+				t.Errorf("Expected events %q, got %q", tt.expectedEvents, args.Events)
+			}
+			if args.Once != tt.expectedOnce {
+				t.Errorf("Expected Once=%v, got %v", tt.expectedOnce, args.Once)
+			}
+			if args.Debounce != tt.expectedDebounce {
+				t.Errorf("Expected debounce %v, got %v", tt.expectedDebounce, args.Debounce)
+			}
+		})
+	}
+}
+
+func TestParseNotifyArgs(t *testing.T) {
+	tests := []struct {
+		name             string
+		args             []string
+		expectedErr      bool
+		expectedMessage  string
+		expectedSource   string
+		expectedWebhook  string
+		expectedTitle    string
+		expectedMentions []string
+		expectedRunID    string
+		expectedSeq      string
+		expectedUser     string
+		expectedAvatar   string
+	}{
+		{
+			name:             "Message with multiple mentions",
+			args:             []string{"Deploy failed", "--mention=@here", "--mention='role:123456789'"},
+			expectedMessage:  "Deploy failed",
+			expectedMentions: []string{"@here", "role:123456789"},
+		},
+		{
+			name:            "Message with title",
+			args:            []string{"Hello world", "--title='Deploy finished'"},
+			expectedMessage: "Hello world",
+			expectedTitle:   "Deploy finished",
+		},
+		{
+			name:        "Empty args",
+			args:        []string{},
+			expectedErr: true,
+		},
+		{
+			name:            "Message with run ID and auto sequence",
+			args:            []string{"Hello world", "--run-id=7f2c", "--seq=auto"},
+			expectedMessage: "Hello world",
+			expectedRunID:   "7f2c",
+			expectedSeq:     "auto",
+		},
+		{
+			name:            "Message with explicit sequence",
+			args:            []string{"Hello world", "--seq=3"},
+			expectedMessage: "Hello world",
+			expectedSeq:     "3",
+		},
+		{
+			name:        "Message with invalid sequence",
+			args:        []string{"Hello world", "--seq=next"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message with zero sequence",
+			args:        []string{"Hello world", "--seq=0"},
+			expectedErr: true,
+		},
+		{
+			name:            "Message only",
+			args:            []string{"Hello world"},
+			expectedMessage: "Hello world",
+		},
+		{
+			name:            "Message with webhook",
+			args:            []string{"Hello world", "--webhook=https://example.com"},
+			expectedMessage: "Hello world",
+			expectedWebhook: "https://example.com",
+		},
+		{
+			name:            "Message with source",
+			args:            []string{"Hello world", "--source=Test"},
+			expectedMessage: "Hello world",
+			expectedSource:  "Test",
+		},
+		{
+			name:            "Message with webhook and source",
+			args:            []string{"Hello world", "--webhook=https://example.com", "--source=Test"},
+			expectedMessage: "Hello world",
+			expectedSource:  "Test",
+			expectedWebhook: "https://example.com",
+		},
+		{
+			name:            "Arguments joined with spaces",
+			args:            []string{"line one", "line two"},
+			expectedMessage: "line one line two",
+		},
+		{
+			name:            "Arguments joined with newlines",
+			args:            []string{"line one", "--newline", "line two", "line three"},
+			expectedMessage: "line one\nline two\nline three",
+		},
+		{
+			name:        "Newline without message arguments",
+			args:        []string{"--stdin", "--newline"},
+			expectedErr: true,
+		},
+		{
+			name:        "Newline with batch",
+			args:        []string{"--batch", "--newline"},
+			expectedErr: true,
+		},
+		{
+			name:            "Message with quoted source",
+			args:            []string{"Hello world", "--source='Test Source'"},
+			expectedMessage: "Hello world",
+			expectedSource:  "Test Source",
+		},
+		{
+			name:        "Plain with embed JSON",
+			args:        []string{"Hello world", "--plain", "--embed-json=-"},
+			expectedErr: true,
+		},
+		{
+			name:        "Silent with loud",
+			args:        []string{"Hello world", "--silent", "--loud"},
+			expectedErr: true,
+		},
+		{
+			name:        "No identity with username",
+			args:        []string{"Hello world", "--no-identity", "--username=Bot"},
+			expectedErr: true,
+		},
+		{
+			name:        "Target with webhook",
+			args:        []string{"Hello world", "--target=ops", "--webhook=https://example.com"},
+			expectedErr: true,
+		},
+		{
+			name:        "Empty target",
+			args:        []string{"Hello world", "--target=,"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message with invalid retry count",
+			args:        []string{"Hello world", "--retry=three"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message with negative rate limit retries",
+			args:        []string{"Hello world", "--rate-limit-retries=-1"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message with non-numeric rate limit retries",
+			args:        []string{"Hello world", "--rate-limit-retries=many"},
+			expectedErr: true,
+		},
+		{
+			name:            "Message with username",
+			args:            []string{"Hello world", "--username=DeployBot"},
+			expectedMessage: "Hello world",
+			expectedUser:    "DeployBot",
+		},
+		{
+			name:            "Message with avatar URL",
+			args:            []string{"Hello world", "--avatar=https://example.com/avatar.png"},
+			expectedMessage: "Hello world",
+			expectedAvatar:  "https://example.com/avatar.png",
+		},
+		{
+			name:            "Message with quoted identity",
+			args:            []string{"Hello world", "--username='Deploy Bot'", "--avatar=\"https://example.com/avatar.png\""},
+			expectedMessage: "Hello world",
+			expectedUser:    "Deploy Bot",
+			expectedAvatar:  "https://example.com/avatar.png",
+		},
+		{
+			name:        "Batch with message",
+			args:        []string{"Hello world", "--batch"},
+			expectedErr: true,
+		},
+		{
+			name:        "Stdin with message",
+			args:        []string{"Hello world", "-"},
+			expectedErr: true,
+		},
+		{
+			name:        "Stdin with batch",
+			args:        []string{"--stdin", "--batch"},
+			expectedErr: true,
+		},
+		{
+			name:        "Stdin with embed JSON from stdin",
+			args:        []string{"-", "--embed-json=-"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message file with message",
+			args:        []string{"Hello world", "--message-file=notes.md"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message file with stdin",
+			args:        []string{"--stdin", "--message-file=notes.md"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message file without a path",
+			args:        []string{"--message-file="},
+			expectedErr: true,
+		},
+		{
+			name:        "Batch with invalid size",
+			args:        []string{"--batch-size=0"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message with invalid code language",
+			args:        []string{"Hello world", "--lang=go lang"},
+			expectedErr: true,
+		},
+		{
+			name:        "Message with unknown flag",
+			args:        []string{"Hello world", "--unknown=value"},
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := parseNotifyArgs(tt.args)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected error, got nil")
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if args.Command != CommandNotify {
+				t.Errorf("Expected command type CommandNotify, got %v", args.Command)
+			}
+
+			if args.Message != tt.expectedMessage {
+				t.Errorf("Expected Message=%q, got %q", tt.expectedMessage, args.Message)
+			}
+
+			if args.Source != tt.expectedSource {
+				t.Errorf("Expected Source=%q, got %q", tt.expectedSource, args.Source)
+			}
+
+			if args.WebhookURL != tt.expectedWebhook {
 				t.Errorf("Expected WebhookURL=%q, got %q", tt.expectedWebhook, args.WebhookURL)
 			}
+
+			if args.Title != tt.expectedTitle {
+				t.Errorf("Expected Title=%q, got %q", tt.expectedTitle, args.Title)
+			}
+
+			if !reflect.DeepEqual(args.Mentions, tt.expectedMentions) {
+				t.Errorf("Expected Mentions=%q, got %q", tt.expectedMentions, args.Mentions)
+			}
+
+			if args.RunID != tt.expectedRunID {
+				t.Errorf("Expected RunID=%q, got %q", tt.expectedRunID, args.RunID)
+			}
+
+			if args.Seq != tt.expectedSeq {
+				t.Errorf("Expected Seq=%q, got %q", tt.expectedSeq, args.Seq)
+			}
+
+			if args.Username != tt.expectedUser {
+				t.Errorf("Expected Username=%q, got %q", tt.expectedUser, args.Username)
+			}
+
+			if args.AvatarURL != tt.expectedAvatar {
+				t.Errorf("Expected AvatarURL=%q, got %q", tt.expectedAvatar, args.AvatarURL)
+			}
+		})
+	}
+}
+
+func TestParseRateLimitRetries(t *testing.T) {
+	args, err := parseNotifyArgs([]string{"Hello world"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if args.RateLimitRetries != -1 {
+		t.Errorf("Expected RateLimitRetries=-1 when unset, got %d", args.RateLimitRetries)
+	}
+	if args.Retries != -1 {
+		t.Errorf("Expected Retries=-1 when unset, got %d", args.Retries)
+	}
+
+	args, err = parseNotifyArgs([]string{"Hello world", "--rate-limit-retries=0", "--retry=2", "--verbose"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if args.RateLimitRetries != 0 {
+		t.Errorf("Expected RateLimitRetries=0, got %d", args.RateLimitRetries)
+	}
+	if args.Retries != 2 {
+		t.Errorf("Expected Retries=2, got %d", args.Retries)
+	}
+	if !args.Verbose {
+		t.Error("Expected Verbose to be set")
+	}
+}
+
+func TestParseNotifyFlags(t *testing.T) {
+	tests := []struct {
+		name  string
+		args  []string
+		check func(t *testing.T, args *Args)
+	}{
+		{
+			name: "Thumbnail",
+			args: []string{"Hello world", "--thumbnail='https://example.com/logo.png'"},
+			check: func(t *testing.T, args *Args) {
+				if args.Thumbnail != "https://example.com/logo.png" {
+					t.Errorf("Expected Thumbnail to be set, got %q", args.Thumbnail)
+				}
+			},
+		},
+		{
+			name: "Repeatable fields",
+			args: []string{"Hello world", "--field='Branch=main:inline'", "--field=Query=a=1"},
+			check: func(t *testing.T, args *Args) {
+				if !reflect.DeepEqual(args.Fields, []string{"Branch=main:inline", "Query=a=1"}) {
+					t.Errorf("Unexpected fields: %q", args.Fields)
+				}
+			},
+		},
+		{
+			name: "Embed JSON without message",
+			args: []string{"--embed-json=embed.json"},
+			check: func(t *testing.T, args *Args) {
+				if args.EmbedJSON != "embed.json" || args.Message != "" {
+					t.Errorf("Unexpected embed JSON %q with message %q", args.EmbedJSON, args.Message)
+				}
+			},
+		},
+		{
+			name: "Suppress embeds",
+			args: []string{"See https://example.com", "--plain", "--suppress-embeds"},
+			check: func(t *testing.T, args *Args) {
+				if !args.SuppressEmbeds || !args.Plain {
+					t.Error("Expected SuppressEmbeds and Plain to be set")
+				}
+			},
+		},
+		{
+			name: "Provider",
+			args: []string{"done", "--provider=Slack"},
+			check: func(t *testing.T, args *Args) {
+				if args.Provider != "slack" {
+					t.Errorf("Expected provider slack, got %q", args.Provider)
+				}
+			},
+		},
+		{
+			name: "Targets",
+			args: []string{"db migration done", "--target=ops, dev", "--target=qa"},
+			check: func(t *testing.T, args *Args) {
+				if !slices.Equal(args.Targets, []string{"ops", "dev", "qa"}) {
+					t.Errorf("Unexpected targets: %q", args.Targets)
+				}
+			},
+		},
+		{
+			name: "Silent",
+			args: []string{"heartbeat", "--silent"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Silent || args.Loud {
+					t.Error("Expected only Silent to be set")
+				}
+			},
+		},
+		{
+			name: "TTS",
+			args: []string{"Hello world", "--tts"},
+			check: func(t *testing.T, args *Args) {
+				if !args.TTS {
+					t.Error("Expected TTS to be set")
+				}
+			},
+		},
+		{
+			name: "Footer",
+			args: []string{"Hello world", "--footer='acme-ci • production'", "--footer-icon=https://example.com/acme.png"},
+			check: func(t *testing.T, args *Args) {
+				if args.Footer != "acme-ci • production" || args.FooterIcon != "https://example.com/acme.png" {
+					t.Errorf("Unexpected footer: %q %q", args.Footer, args.FooterIcon)
+				}
+			},
+		},
+		{
+			name: "Author",
+			args: []string{"Hello world", "--author=alice", "--author-url=https://github.com/alice", "--author-icon='https://github.com/alice.png'"},
+			check: func(t *testing.T, args *Args) {
+				if args.Author != "alice" || args.AuthorURL != "https://github.com/alice" || args.AuthorIcon != "https://github.com/alice.png" {
+					t.Errorf("Unexpected author: %q %q %q", args.Author, args.AuthorURL, args.AuthorIcon)
+				}
+			},
+		},
+		{
+			name: "Code block",
+			args: []string{"panic: oops", "--code"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Code || args.Lang != "" {
+					t.Errorf("Expected Code without a language, got %v %q", args.Code, args.Lang)
+				}
+			},
+		},
+		{
+			name: "Code language",
+			args: []string{"panic: oops", "--lang='c++'"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Code || args.Lang != "c++" {
+					t.Errorf("Expected --lang to imply Code, got %v %q", args.Code, args.Lang)
+				}
+			},
+		},
+		{
+			name: "Escape",
+			args: []string{"src/__init__.py", "--escape"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Escape {
+					t.Error("Expected Escape to be set")
+				}
+			},
+		},
+		{
+			name: "No expand",
+			args: []string{"{{host}}", "--no-expand"},
+			check: func(t *testing.T, args *Args) {
+				if !args.NoExpand || args.Message != "{{host}}" {
+					t.Errorf("Expected NoExpand with the message as written, got %v and %q", args.NoExpand, args.Message)
+				}
+			},
+		},
+		{
+			name: "No host",
+			args: []string{"Hello", "--no-host"},
+			check: func(t *testing.T, args *Args) {
+				if !args.NoHost {
+					t.Error("Expected NoHost to be set")
+				}
+			},
+		},
+		{
+			name: "No identity",
+			args: []string{"Hello", "--no-identity"},
+			check: func(t *testing.T, args *Args) {
+				if !args.NoIdentity {
+					t.Error("Expected NoIdentity to be set")
+				}
+			},
+		},
+		{
+			name: "Git",
+			args: []string{"Hello", "--git"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Git {
+					t.Error("Expected Git to be set")
+				}
+			},
+		},
+		{
+			name: "Stdin dash",
+			args: []string{"-", "--code"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Stdin || args.Message != "" {
+					t.Errorf("Expected Stdin without a message, got %+v", args)
+				}
+			},
+		},
+		{
+			name: "Stdin flag",
+			args: []string{"--stdin"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Stdin {
+					t.Error("Expected Stdin to be set")
+				}
+			},
+		},
+		{
+			name: "Message file",
+			args: []string{"--message-file='release notes.md'", "--title=v2.1 released"},
+			check: func(t *testing.T, args *Args) {
+				if args.MessageFile != "release notes.md" || args.Message != "" {
+					t.Errorf("Expected the message file without a message, got %+v", args)
+				}
+			},
+		},
+		{
+			name: "Sysinfo",
+			args: []string{"Hello", "--sysinfo"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Sysinfo {
+					t.Error("Expected Sysinfo to be set")
+				}
+			},
+		},
+		{
+			name: "No CI",
+			args: []string{"Hello", "--no-ci"},
+			check: func(t *testing.T, args *Args) {
+				if !args.NoCI {
+					t.Error("Expected NoCI to be set")
+				}
+			},
+		},
+		{
+			name: "Batch",
+			args: []string{"--batch", "--source=lint"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Batch || args.BatchSize != 1 || args.Message != "" || args.Source != "lint" {
+					t.Errorf("Unexpected batch args: %+v", args)
+				}
+			},
+		},
+		{
+			name: "Batch size",
+			args: []string{"--batch-size=5"},
+			check: func(t *testing.T, args *Args) {
+				if !args.Batch || args.BatchSize != 5 {
+					t.Errorf("Expected --batch-size to imply --batch, got %v %d", args.Batch, args.BatchSize)
+				}
+			},
+		},
+		{
+			name: "Dry run",
+			args: []string{"Hello world", "--field=Branch=main", "--dry-run"},
+			check: func(t *testing.T, args *Args) {
+				if !args.DryRun {
+					t.Error("Expected DryRun to be set")
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := parseNotifyArgs(tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			tt.check(t, args)
 		})
 	}
 }
@@ -320,6 +1639,7 @@ func TestPrintUsage(t *testing.T) {
 	// Check that important parts are in the usage output
 	expectedParts := []string{
 		fmt.Sprintf("Owata v%s", Version),
+		"Exit codes:",
 		"Discord Webhook Notifier",
 		"Usage:",
 		"owata <message>",
@@ -338,24 +1658,39 @@ func TestPrintUsage(t *testing.T) {
 	}
 }
 
-func TestPrintVersion(t *testing.T) {
-	// Redirect stdout
-	oldStdout := os.Stdout
-	r, w, _ := os.Pipe()
-	os.Stdout = w
-
-	PrintVersion()
-
-	// Restore stdout
-	w.Close()
-	os.Stdout = oldStdout
-
-	var buf bytes.Buffer
-	_, _ = buf.ReadFrom(r)
-	output := buf.String()
+func TestParseWebhookFile(t *testing.T) {
+	tests := []struct {
+		name        string
+		args        []string
+		expected    string
+		expectedErr bool
+	}{
+		{name: "Notify", args: []string{"Done", "--webhook-file=/run/secrets/discord_webhook"}, expected: "/run/secrets/discord_webhook"},
+		{name: "Space-separated", args: []string{"Done", "--webhook-file", "/run/secrets/discord_webhook"}, expected: "/run/secrets/discord_webhook"},
+		{name: "Run", args: []string{"run", "--webhook-file=secret", "--", "make"}, expected: "secret"},
+		{name: "Test", args: []string{"test", "--webhook-file=secret"}, expected: "secret"},
+		{name: "Doctor", args: []string{"doctor", "--webhook-file=secret"}, expected: "secret"},
+		{name: "Webhook info", args: []string{"webhook", "info", "--webhook-file=secret"}, expected: "secret"},
+		{name: "Delete", args: []string{"delete", "123", "--webhook-file=secret"}, expected: "secret"},
+		{name: "With webhook", args: []string{"Done", "--webhook-file=secret", "--webhook=https://example.com"}, expectedErr: true},
+		{name: "With target", args: []string{"Done", "--webhook-file=secret", "--target=ops"}, expectedErr: true},
+	}
 
-	expected := fmt.Sprintf("Owata v%s\n", Version)
-	if output != expected {
-		t.Errorf("Expected %q, got %q", expected, output)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args, err := Parse(tt.args)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %+v", args)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if args.WebhookFile != tt.expected {
+				t.Errorf("Expected webhook file %q, got %q", tt.expected, args.WebhookFile)
+			}
+		})
 	}
 }
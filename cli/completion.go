@@ -0,0 +1,294 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Shells completion scripts are generated for
+var completionShells = []string{"bash", "zsh", "fish"}
+
+// completionChoices are the fixed values of options whose placeholder in the
+// help doesn't spell them out
+var completionChoices = map[string][]string{
+	"--provider": {"discord", "slack", "ntfy", "telegram", "generic"},
+	"--event":    fileEvents,
+}
+
+// completionFiles are the options whose value is a file path
+var completionFiles = []string{"--attach", "--message-file", "--embed-json", "--config"}
+
+// completionTargets is the option completed with the configured target names,
+// which "owata completion targets" lists
+const completionTargets = "--target"
+
+// completionFlag is an option as the completion scripts offer it
+type completionFlag struct {
+	Long        string // Such as --webhook; empty for a short-only option
+	Short       string // Such as -g
+	Value       bool   // Takes a value
+	Choices     []string
+	Description string
+}
+
+// completionCommands returns the commands in the help, and for those with
+// actions (compose add-field, webhook info) the actions that follow them
+func completionCommands() ([]string, map[string][]string) {
+	var commands []string
+	actions := map[string][]string{}
+	for _, e := range usageCommands {
+		words := strings.Fields(e.Name)
+		if !slices.Contains(commands, words[0]) {
+			commands = append(commands, words[0])
+		}
+		if len(words) < 2 || strings.ContainsAny(words[1][:1], "-<[") {
+			continue
+		}
+		for action := range strings.SplitSeq(words[1], "|") {
+			if !slices.Contains(actions[words[0]], action) {
+				actions[words[0]] = append(actions[words[0]], action)
+			}
+		}
+	}
+	return commands, actions
+}
+
+// completionFlags returns the options in the help, such as "-g, --global" or
+// "--seq=auto|<n>"
+func completionFlags() []completionFlag {
+	var flags []completionFlag
+	for _, e := range usageOptions {
+		if strings.Contains(strings.ReplaceAll(e.Name, ", ", ""), " ") {
+			// A combination such as "--version --json" is not an option of its own
+			continue
+		}
+		flag := completionFlag{Description: strings.Split(e.Description, "\n")[0]}
+		for name := range strings.SplitSeq(e.Name, ", ") {
+			name, placeholder, _ := strings.Cut(name, "=")
+			switch {
+			case strings.HasPrefix(name, "--"):
+				flag.Long = name
+			case len(name) == 2:
+				flag.Short = name
+			default:
+				// "-" for stdin is a message, not an option
+				continue
+			}
+			for choice := range strings.SplitSeq(placeholder, "|") {
+				if choice != "" && !strings.ContainsAny(choice, "<[") {
+					flag.Choices = append(flag.Choices, choice)
+				}
+			}
+		}
+		flag.Value = slices.Contains(valueFlags, flag.Long)
+		if choices, ok := completionChoices[flag.Long]; ok {
+			flag.Choices = choices
+		}
+		flags = append(flags, flag)
+	}
+	return flags
+}
+
+// CompletionScript returns the completion script for shell: bash, zsh or fish
+func CompletionScript(shell string) (string, error) {
+	commands, actions := completionCommands()
+	flags := completionFlags()
+
+	switch shell {
+	case "bash":
+		return bashCompletion(commands, actions, flags), nil
+	case "zsh":
+		return zshCompletion(commands, actions, flags), nil
+	case "fish":
+		return fishCompletion(commands, actions, flags), nil
+	default:
+		return "", fmt.Errorf("unsupported shell: %s (use %s)", shell, strings.Join(completionShells, ", "))
+	}
+}
+
+// flagWords lists every spelling of the options, such as --global and -g
+func flagWords(flags []completionFlag) []string {
+	var words []string
+	for _, f := range flags {
+		for _, name := range []string{f.Long, f.Short} {
+			if name != "" {
+				words = append(words, name)
+			}
+		}
+	}
+	return words
+}
+
+// valueCases writes a shell case arm per kind of option value: fixed
+// choices, target names, file paths, and free text that has nothing to offer
+func valueCases(b *strings.Builder, flags []completionFlag, choices, targets, files, free string) {
+	var freeFlags []string
+	for _, f := range flags {
+		switch {
+		case !f.Value:
+		case f.Long == completionTargets:
+			fmt.Fprintf(b, "    %s) %s ;;\n", f.Long, targets)
+		case slices.Contains(completionFiles, f.Long):
+		case len(f.Choices) > 0:
+			fmt.Fprintf(b, "    %s) %s ;;\n", f.Long, fmt.Sprintf(choices, strings.Join(f.Choices, " ")))
+		default:
+			freeFlags = append(freeFlags, f.Long)
+		}
+	}
+	fmt.Fprintf(b, "    %s) %s ;;\n", strings.Join(completionFiles, "|"), files)
+	fmt.Fprintf(b, "    %s) %s ;;\n", strings.Join(freeFlags, "|"), free)
+}
+
+// actionCases writes a shell case arm per command with actions
+func actionCases(b *strings.Builder, commands []string, actions map[string][]string, format string) {
+	for _, command := range commands {
+		if len(actions[command]) > 0 {
+			fmt.Fprintf(b, "        %s) %s ;;\n", command, fmt.Sprintf(format, strings.Join(actions[command], " ")))
+		}
+	}
+}
+
+func bashCompletion(commands []string, actions map[string][]string, flags []completionFlag) string {
+	var b strings.Builder
+	b.WriteString(`# bash completion for owata; load it with:
+#   source <(owata completion bash)
+_owata() {
+    local cur=${COMP_WORDS[COMP_CWORD]} prev=${COMP_WORDS[COMP_CWORD-1]} flag= i
+    COMPREPLY=()
+
+    # Words after -- belong to the command owata run runs
+    for ((i = 1; i < COMP_CWORD; i++)); do
+        [[ ${COMP_WORDS[i]} == -- ]] && return
+    done
+
+    # bash splits --option=value at the =
+    if [[ $cur == = ]]; then
+        flag=$prev cur=
+    elif [[ $prev == = ]]; then
+        flag=${COMP_WORDS[COMP_CWORD-2]}
+    elif [[ $cur != -* ]]; then
+        flag=$prev
+    fi
+
+    case $flag in
+`)
+	valueCases(&b, flags,
+		`COMPREPLY=($(compgen -W "%s" -- "$cur")); return`,
+		`COMPREPLY=($(compgen -W "$(owata completion targets 2>/dev/null)" -- "$cur")); return`,
+		`COMPREPLY=($(compgen -f -- "$cur")); return`,
+		`return`)
+	b.WriteString(`    esac
+
+    if [[ $cur == -* ]]; then
+        COMPREPLY=($(compgen -W "` + strings.Join(flagWords(flags), " ") + `" -- "$cur"))
+    elif ((COMP_CWORD == 1)); then
+        COMPREPLY=($(compgen -W "` + strings.Join(commands, " ") + `" -- "$cur"))
+    elif ((COMP_CWORD == 2)); then
+        case ${COMP_WORDS[1]} in
+`)
+	actionCases(&b, commands, actions, `COMPREPLY=($(compgen -W "%s" -- "$cur"))`)
+	b.WriteString(`        esac
+    fi
+}
+complete -o default -F _owata owata
+`)
+	return b.String()
+}
+
+func zshCompletion(commands []string, actions map[string][]string, flags []completionFlag) string {
+	var b strings.Builder
+	b.WriteString(`#compdef owata
+# zsh completion for owata; load it with:
+#   source <(owata completion zsh)
+# or save it as _owata in a directory on $fpath
+_owata() {
+    local cur=${words[CURRENT]} prev=${words[CURRENT-1]} flag=
+
+    # Words after -- belong to the command owata run runs
+    if (( ${words[(I)--]} > 1 && ${words[(I)--]} < CURRENT )); then
+        _normal
+        return
+    fi
+
+    if [[ $cur == --*=* ]]; then
+        flag=${cur%%=*}
+        compset -P '*='
+    elif [[ $cur != -* ]]; then
+        flag=$prev
+    fi
+
+    case $flag in
+`)
+	valueCases(&b, flags,
+		`compadd -- %s; return`,
+		`compadd -- ${(f)"$(owata completion targets 2>/dev/null)"}; return`,
+		`_files; return`,
+		`return`)
+	b.WriteString(`    esac
+
+    if [[ $cur == -* ]]; then
+        compadd -- ` + strings.Join(flagWords(flags), " ") + `
+    elif (( CURRENT == 2 )); then
+        compadd -- ` + strings.Join(commands, " ") + `
+    elif (( CURRENT == 3 )); then
+        case ${words[2]} in
+`)
+	actionCases(&b, commands, actions, `compadd -- %s`)
+	b.WriteString(`        *) _files ;;
+        esac
+    else
+        _files
+    fi
+}
+
+if [[ $zsh_eval_context[-1] == loadautofunc ]]; then
+    _owata "$@"
+else
+    compdef _owata owata
+fi
+`)
+	return b.String()
+}
+
+func fishCompletion(commands []string, actions map[string][]string, flags []completionFlag) string {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+
+	var b strings.Builder
+	b.WriteString(`# fish completion for owata; load it with:
+#   owata completion fish | source
+# or save it as ~/.config/fish/completions/owata.fish
+complete -c owata -n __fish_use_subcommand -a ` + quote(strings.Join(commands, " ")) + "\n")
+	for _, command := range commands {
+		if len(actions[command]) == 0 {
+			continue
+		}
+		condition := fmt.Sprintf("__fish_seen_subcommand_from %s; and not __fish_seen_subcommand_from %s", command, strings.Join(actions[command], " "))
+		fmt.Fprintf(&b, "complete -c owata -f -n %s -a %s\n", quote(condition), quote(strings.Join(actions[command], " ")))
+	}
+
+	for _, f := range flags {
+		b.WriteString("complete -c owata")
+		if f.Long != "" {
+			b.WriteString(" -l " + strings.TrimPrefix(f.Long, "--"))
+		}
+		if f.Short != "" {
+			b.WriteString(" -s " + strings.TrimPrefix(f.Short, "-"))
+		}
+		switch {
+		case !f.Value:
+		case f.Long == completionTargets:
+			b.WriteString(` -x -a '(owata completion targets 2>/dev/null)'`)
+		case slices.Contains(completionFiles, f.Long):
+			b.WriteString(" -r -F")
+		case len(f.Choices) > 0:
+			b.WriteString(" -x -a " + quote(strings.Join(f.Choices, " ")))
+		default:
+			b.WriteString(" -x")
+		}
+		b.WriteString(" -d " + quote(f.Description) + "\n")
+	}
+	return b.String()
+}
@@ -0,0 +1,127 @@
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestCompletionScript(t *testing.T) {
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			script, err := CompletionScript(shell)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, want := range []string{"wait-port", "add-field", "webhook", "until-down", "owata completion targets", "telegram"} {
+				if !strings.Contains(script, want) {
+					t.Errorf("Expected the %s script to mention %q", shell, want)
+				}
+			}
+		})
+	}
+
+	if _, err := CompletionScript("powershell"); err == nil || !strings.Contains(err.Error(), "bash, zsh, fish") {
+		t.Errorf("Expected an unsupported shell error listing the shells, got %v", err)
+	}
+}
+
+// TestCompletionScriptSyntax checks that each shell can parse its script,
+// skipping shells that aren't installed
+func TestCompletionScriptSyntax(t *testing.T) {
+	checks := map[string][]string{
+		"bash": {"bash", "-n"},
+		"zsh":  {"zsh", "-n"},
+		"fish": {"fish", "--no-execute"},
+	}
+	for _, shell := range completionShells {
+		t.Run(shell, func(t *testing.T) {
+			check := checks[shell]
+			if _, err := exec.LookPath(check[0]); err != nil {
+				t.Skipf("%s is not available", check[0])
+			}
+			script, err := CompletionScript(shell)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			path := filepath.Join(t.TempDir(), "owata."+shell)
+			if err := os.WriteFile(path, []byte(script), 0644); err != nil {
+				t.Fatalf("Failed to write script: %v", err)
+			}
+			if output, err := exec.Command(check[0], append(check[1:], path)...).CombinedOutput(); err != nil {
+				t.Errorf("%s failed to parse the script: %v\n%s", shell, err, output)
+			}
+		})
+	}
+}
+
+func TestCompletionCommands(t *testing.T) {
+	commands, actions := completionCommands()
+
+	for _, want := range []string{"init", "config", "run", "wait-pid", "test", "webhook", "delete", "compose", "completion"} {
+		if !slices.Contains(commands, want) {
+			t.Errorf("Expected command %q in %v", want, commands)
+		}
+	}
+	if want := []string{"add-field", "add-line", "add-file", "status", "show", "clear", "send"}; !slices.Equal(actions["compose"], want) {
+		t.Errorf("Expected compose actions %v, got %v", want, actions["compose"])
+	}
+	if want := []string{"bash", "zsh", "fish", "targets"}; !slices.Equal(actions["completion"], want) {
+		t.Errorf("Expected completion actions %v, got %v", want, actions["completion"])
+	}
+	if want := []string{"get", "set", "unset", "edit", "path"}; !slices.Equal(actions["config"], want) {
+		t.Errorf("Expected config actions %v, got %v", want, actions["config"])
+	}
+	// "run -- <command>" and "run -c" are options, not actions
+	if len(actions["run"]) > 0 {
+		t.Errorf("Expected no actions for run, got %v", actions["run"])
+	}
+}
+
+// TestCompletionFlags checks the options read from the help against the
+// parser, so an option added to one but not the other is caught
+func TestCompletionFlags(t *testing.T) {
+	flags := completionFlags()
+	byName := map[string]completionFlag{}
+	for _, f := range flags {
+		byName[f.Long] = f
+		byName[f.Short] = f
+	}
+
+	for _, name := range valueFlags {
+		if f, ok := byName[name]; !ok || !f.Value {
+			t.Errorf("Expected %s in the help as an option taking a value", name)
+		}
+	}
+	for _, name := range append(completionFiles, completionTargets) {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("Expected %s in the help", name)
+		}
+	}
+	for name := range completionChoices {
+		if _, ok := byName[name]; !ok {
+			t.Errorf("Expected %s in the help", name)
+		}
+	}
+
+	if f := byName["-g"]; f.Long != "--global" || f.Value {
+		t.Errorf("Expected -g to be --global without a value, got %+v", f)
+	}
+	if f := byName["--seq"]; !slices.Equal(f.Choices, []string{"auto"}) {
+		t.Errorf("Expected --seq to offer auto, got %v", f.Choices)
+	}
+	if f := byName["--min-duration-applies-to"]; !slices.Equal(f.Choices, []string{"success", "all"}) {
+		t.Errorf("Expected --min-duration-applies-to to offer success and all, got %v", f.Choices)
+	}
+	for _, f := range flags {
+		if strings.Contains(f.Long, " ") {
+			t.Errorf("Expected no option combination among the flags, got %+v", f)
+		}
+	}
+	if f := byName["--stdin"]; f.Short != "" {
+		t.Errorf("Expected - not to be taken as a short option, got %+v", f)
+	}
+}
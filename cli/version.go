@@ -0,0 +1,111 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strings"
+)
+
+// Release builds set these with -ldflags, as in
+//
+//	-X github.com/yashikota/owata/cli.version=2.1.0 -X github.com/yashikota/owata/cli.commit=...
+//
+// Otherwise they come from the VCS details Go embeds in the binary.
+var (
+	version string
+	commit  string
+	date    string
+)
+
+// For testing purposes
+var readBuildInfo = debug.ReadBuildInfo
+
+// pseudoVersion matches the timestamp and commit Go puts in the version of a
+// build from a checkout, as in v0.0.0-20250703120000-0123456789ab
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}`)
+
+// BuildInfo describes the build of the running binary
+type BuildInfo struct {
+	Version    string `json:"version"`
+	Commit     string `json:"commit,omitempty"`
+	CommitTime string `json:"commit_time,omitempty"`
+	Modified   bool   `json:"modified"`
+	GoVersion  string `json:"go_version"`
+	OS         string `json:"os"`
+	Arch       string `json:"arch"`
+}
+
+// GetBuildInfo returns the version and build details of the running binary.
+// Values set with -ldflags win over what Go recorded at build time.
+func GetBuildInfo() BuildInfo {
+	b := BuildInfo{
+		Version:   Version,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	}
+
+	if info, ok := readBuildInfo(); ok {
+		// go install ...@v2.1.0 records the module version; local builds
+		// record a pseudo-version that says less than Version
+		if v := info.Main.Version; v != "" && v != "(devel)" && !pseudoVersion.MatchString(v) {
+			b.Version = strings.TrimPrefix(v, "v")
+		}
+		for _, s := range info.Settings {
+			switch s.Key {
+			case "vcs.revision":
+				b.Commit = s.Value
+			case "vcs.time":
+				b.CommitTime = s.Value
+			case "vcs.modified":
+				b.Modified = s.Value == "true"
+			}
+		}
+	}
+
+	if version != "" {
+		b.Version = strings.TrimPrefix(version, "v")
+	}
+	if commit != "" {
+		b.Commit, b.Modified = commit, false
+	}
+	if date != "" {
+		b.CommitTime = date
+	}
+	return b
+}
+
+// PrintVersion prints the version with the commit, Go version and platform
+// it was built from, or all of them as JSON for bug reports and tooling
+func PrintVersion(asJSON bool) error {
+	b := GetBuildInfo()
+	if asJSON {
+		data, err := json.Marshal(b)
+		if err != nil {
+			return fmt.Errorf("failed to marshal version: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	fmt.Printf("Owata v%s\n", b.Version)
+	commitLine := "unknown"
+	if b.Commit != "" {
+		commitLine = b.Commit
+		if len(commitLine) > 12 {
+			commitLine = commitLine[:12]
+		}
+		if b.Modified {
+			commitLine += " (modified)"
+		}
+		if b.CommitTime != "" {
+			commitLine += ", " + b.CommitTime
+		}
+	}
+	fmt.Printf("  Commit: %s\n", commitLine)
+	fmt.Printf("  Go: %s %s/%s\n", b.GoVersion, b.OS, b.Arch)
+	return nil
+}
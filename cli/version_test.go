@@ -0,0 +1,114 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"testing"
+)
+
+func TestGetBuildInfo(t *testing.T) {
+	vcs := &debug.BuildInfo{
+		Main: debug.Module{Version: "(devel)"},
+		Settings: []debug.BuildSetting{
+			{Key: "vcs.revision", Value: "0123456789abcdef0123456789abcdef01234567"},
+			{Key: "vcs.time", Value: "2025-07-03T12:00:00Z"},
+			{Key: "vcs.modified", Value: "true"},
+		},
+	}
+
+	tests := []struct {
+		name    string
+		info    *debug.BuildInfo
+		ldflags [3]string
+		want    BuildInfo
+	}{
+		{
+			name: "No build info",
+			want: BuildInfo{Version: Version},
+		},
+		{
+			name: "VCS details",
+			info: vcs,
+			want: BuildInfo{Version: Version, Commit: "0123456789abcdef0123456789abcdef01234567", CommitTime: "2025-07-03T12:00:00Z", Modified: true},
+		},
+		{
+			name: "go install with a version",
+			info: &debug.BuildInfo{Main: debug.Module{Version: "v2.3.0"}},
+			want: BuildInfo{Version: "2.3.0"},
+		},
+		{
+			name: "Pseudo-version ignored",
+			info: &debug.BuildInfo{Main: debug.Module{Version: "v2.1.1-0.20250703120000-0123456789ab+dirty"}},
+			want: BuildInfo{Version: Version},
+		},
+		{
+			name: "Checkout build pseudo-version ignored",
+			info: &debug.BuildInfo{Main: debug.Module{Version: "v0.0.0-20250703120000-0123456789ab+dirty"}},
+			want: BuildInfo{Version: Version},
+		},
+		{
+			name:    "ldflags win",
+			info:    vcs,
+			ldflags: [3]string{"v9.9.9", "abc123", "2025-08-01T00:00:00Z"},
+			want:    BuildInfo{Version: "9.9.9", Commit: "abc123", CommitTime: "2025-08-01T00:00:00Z"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalRead := readBuildInfo
+			defer func() { readBuildInfo = originalRead }()
+			readBuildInfo = func() (*debug.BuildInfo, bool) { return tt.info, tt.info != nil }
+			version, commit, date = tt.ldflags[0], tt.ldflags[1], tt.ldflags[2]
+			defer func() { version, commit, date = "", "", "" }()
+
+			got := GetBuildInfo()
+			tt.want.GoVersion, tt.want.OS, tt.want.Arch = runtime.Version(), runtime.GOOS, runtime.GOARCH
+			if got != tt.want {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestPrintVersion(t *testing.T) {
+	originalRead := readBuildInfo
+	defer func() { readBuildInfo = originalRead }()
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{Settings: []debug.BuildSetting{{Key: "vcs.revision", Value: "0123456789abcdef"}}}, true
+	}
+
+	capture := func(asJSON bool) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		if err := PrintVersion(asJSON); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+		w.Close()
+		os.Stdout = oldStdout
+
+		var buf bytes.Buffer
+		_, _ = buf.ReadFrom(r)
+		return buf.String()
+	}
+
+	output := capture(false)
+	for _, want := range []string{"Owata v" + Version + "\n", "Commit: 0123456789ab\n", "Go: " + runtime.Version() + " " + runtime.GOOS + "/" + runtime.GOARCH} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output %q", want, output)
+		}
+	}
+
+	var got BuildInfo
+	if err := json.Unmarshal([]byte(capture(true)), &got); err != nil {
+		t.Fatalf("Expected JSON output: %v", err)
+	}
+	if got.Version != Version || got.Commit != "0123456789abcdef" || got.OS != runtime.GOOS {
+		t.Errorf("Unexpected JSON version info: %+v", got)
+	}
+}
@@ -0,0 +1,198 @@
+package compose
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+
+	"github.com/yashikota/owata/atomicfile"
+	"github.com/yashikota/owata/lockfile"
+)
+
+// Sentinel errors
+var (
+	ErrInvalidSession = errors.New("invalid compose session name")
+	ErrLocked         = errors.New("compose buffer is locked by another process")
+)
+
+// Session names end up in file names, so keep them to a safe character set
+var sessionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// For testing purposes
+var userCacheDirFunc = os.UserCacheDir
+
+// Field is a name/value pair accumulated for the notification embed
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline,omitempty"`
+}
+
+// Buffer holds the pieces of a notification built up across invocations
+type Buffer struct {
+	Lines  []string `json:"lines,omitempty"`
+	Fields []Field  `json:"fields,omitempty"`
+	Files  []string `json:"files,omitempty"`
+	Status string   `json:"status,omitempty"`
+}
+
+// IsEmpty reports whether nothing has been added to the buffer
+func (b *Buffer) IsEmpty() bool {
+	return len(b.Lines) == 0 && len(b.Fields) == 0 && len(b.Files) == 0 && b.Status == ""
+}
+
+// ValidateSession checks that a session name is safe to use as a file name
+func ValidateSession(session string) error {
+	if !sessionPattern.MatchString(session) {
+		return fmt.Errorf("%w: %q (use up to 64 letters, digits, '.', '_' or '-')", ErrInvalidSession, session)
+	}
+	return nil
+}
+
+// Load returns the current buffer for a session, or an empty one
+func Load(session string) (*Buffer, error) {
+	var buffer *Buffer
+	err := withLock(session, func(path string) error {
+		var err error
+		buffer, err = readBuffer(path)
+		return err
+	})
+	return buffer, err
+}
+
+// Update applies fn to the session's buffer and saves the result. The buffer
+// is locked for the duration so concurrent appends are never lost.
+func Update(session string, fn func(b *Buffer) error) error {
+	return withLock(session, func(path string) error {
+		buffer, err := readBuffer(path)
+		if err != nil {
+			return err
+		}
+		if err := fn(buffer); err != nil {
+			return err
+		}
+		return writeBuffer(path, buffer)
+	})
+}
+
+// Take passes the session's buffer to fn, such as a function sending it,
+// and clears it if fn succeeds. The buffer is only locked while it's read
+// and cleared, so other invocations appending to the session don't wait for
+// fn; what they add starts a new buffer. If fn fails the buffer is put back
+// ahead of anything added meanwhile, so the caller can retry.
+func Take(session string, fn func(b *Buffer) error) error {
+	var taken *Buffer
+	err := withLock(session, func(path string) error {
+		buffer, err := readBuffer(path)
+		if err != nil {
+			return err
+		}
+		taken = buffer
+		return removeBuffer(path)
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := fn(taken); err != nil {
+		if taken.IsEmpty() {
+			return err
+		}
+		if restoreErr := Update(session, taken.prependTo); restoreErr != nil {
+			return fmt.Errorf("%w (and the compose buffer couldn't be restored: %v)", err, restoreErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// prependTo puts the contents of b ahead of those of later, keeping the
+// status of later if it has one
+func (b *Buffer) prependTo(later *Buffer) error {
+	later.Lines = append(slices.Clone(b.Lines), later.Lines...)
+	later.Fields = append(slices.Clone(b.Fields), later.Fields...)
+	later.Files = append(slices.Clone(b.Files), later.Files...)
+	later.Status = cmp.Or(later.Status, b.Status)
+	return nil
+}
+
+// Clear discards the session's buffer
+func Clear(session string) error {
+	return withLock(session, removeBuffer)
+}
+
+func bufferPath(session string) (string, error) {
+	if err := ValidateSession(session); err != nil {
+		return "", err
+	}
+
+	cacheDir, err := userCacheDirFunc()
+	if err != nil {
+		return "", fmt.Errorf("could not determine cache directory: %w", err)
+	}
+
+	dir := filepath.Join(cacheDir, "owata", "compose")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create compose directory: %w", err)
+	}
+
+	return filepath.Join(dir, session+".json"), nil
+}
+
+// withLock runs fn while holding an exclusive lock file next to the buffer
+func withLock(session string, fn func(path string) error) error {
+	path, err := bufferPath(session)
+	if err != nil {
+		return err
+	}
+
+	lockPath := path + ".lock"
+	release, err := lockfile.Acquire(lockPath)
+	if errors.Is(err, lockfile.ErrLocked) {
+		return fmt.Errorf("%w: %s", ErrLocked, lockPath)
+	} else if err != nil {
+		return fmt.Errorf("failed to lock compose buffer: %w", err)
+	}
+	defer release()
+
+	return fn(path)
+}
+
+func readBuffer(path string) (*Buffer, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Buffer{}, nil
+		}
+		return nil, fmt.Errorf("failed to read compose buffer: %v", err)
+	}
+
+	var buffer Buffer
+	if err := json.Unmarshal(data, &buffer); err != nil {
+		return nil, fmt.Errorf("failed to parse compose buffer %s: %v", path, err)
+	}
+	return &buffer, nil
+}
+
+func writeBuffer(path string, buffer *Buffer) error {
+	data, err := json.MarshalIndent(buffer, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal compose buffer: %v", err)
+	}
+	if err := atomicfile.Write(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write compose buffer: %v", err)
+	}
+	return nil
+}
+
+func removeBuffer(path string) error {
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear compose buffer: %v", err)
+	}
+	return nil
+}
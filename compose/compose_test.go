@@ -0,0 +1,195 @@
+package compose
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/lockfile"
+)
+
+func TestBufferLifecycle(t *testing.T) {
+	SetTestCacheDir(t.TempDir())
+	defer ResetTestCacheDir()
+
+	// A missing buffer loads as empty
+	buffer, err := Load("release")
+	if err != nil {
+		t.Fatalf("Failed to load buffer: %v", err)
+	}
+	if !buffer.IsEmpty() {
+		t.Errorf("Expected empty buffer, got %+v", buffer)
+	}
+
+	if err := Update("release", func(b *Buffer) error {
+		b.Fields = append(b.Fields, Field{Name: "env", Value: "prod"})
+		b.Lines = append(b.Lines, "migrations: ok")
+		b.Status = "success"
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to update buffer: %v", err)
+	}
+
+	buffer, err = Load("release")
+	if err != nil {
+		t.Fatalf("Failed to load buffer: %v", err)
+	}
+	if len(buffer.Fields) != 1 || len(buffer.Lines) != 1 || buffer.Status != "success" {
+		t.Errorf("Unexpected buffer contents: %+v", buffer)
+	}
+
+	// A failed Take leaves the buffer in place
+	sendErr := errors.New("send failed")
+	if err := Take("release", func(b *Buffer) error { return sendErr }); !errors.Is(err, sendErr) {
+		t.Errorf("Expected send error, got %v", err)
+	}
+	if buffer, _ := Load("release"); buffer.IsEmpty() {
+		t.Error("Buffer should survive a failed Take")
+	}
+
+	// A successful Take clears it
+	if err := Take("release", func(b *Buffer) error { return nil }); err != nil {
+		t.Fatalf("Failed to take buffer: %v", err)
+	}
+	if buffer, _ := Load("release"); !buffer.IsEmpty() {
+		t.Errorf("Expected buffer to be cleared, got %+v", buffer)
+	}
+}
+
+func TestTakeUnlocked(t *testing.T) {
+	SetTestCacheDir(t.TempDir())
+	defer ResetTestCacheDir()
+
+	add := func(line, status string) error {
+		return Update("deploy", func(b *Buffer) error {
+			b.Lines = append(b.Lines, line)
+			b.Status = cmp.Or(status, b.Status)
+			return nil
+		})
+	}
+	if err := add("build: ok", "running"); err != nil {
+		t.Fatalf("Failed to update buffer: %v", err)
+	}
+
+	// Appending while the buffer is being sent neither waits for the send
+	// nor is lost when the send fails
+	sendErr := errors.New("send failed")
+	err := Take("deploy", func(b *Buffer) error {
+		if err := add("tests: ok", "success"); err != nil {
+			t.Errorf("Expected to append during the send, got %v", err)
+		}
+		return sendErr
+	})
+	if !errors.Is(err, sendErr) {
+		t.Errorf("Expected send error, got %v", err)
+	}
+	buffer, _ := Load("deploy")
+	if !slices.Equal(buffer.Lines, []string{"build: ok", "tests: ok"}) || buffer.Status != "success" {
+		t.Errorf("Expected the taken buffer ahead of the new line, got %+v", buffer)
+	}
+
+	// After a successful send only what was added during it is left
+	err = Take("deploy", func(b *Buffer) error {
+		return add("deploy: ok", "")
+	})
+	if err != nil {
+		t.Fatalf("Failed to take buffer: %v", err)
+	}
+	if buffer, _ := Load("deploy"); !slices.Equal(buffer.Lines, []string{"deploy: ok"}) {
+		t.Errorf("Expected the line added during the send, got %+v", buffer)
+	}
+}
+
+func TestClear(t *testing.T) {
+	SetTestCacheDir(t.TempDir())
+	defer ResetTestCacheDir()
+
+	if err := Update("s", func(b *Buffer) error {
+		b.Lines = append(b.Lines, "line")
+		return nil
+	}); err != nil {
+		t.Fatalf("Failed to update buffer: %v", err)
+	}
+	if err := Clear("s"); err != nil {
+		t.Fatalf("Failed to clear buffer: %v", err)
+	}
+	if buffer, _ := Load("s"); !buffer.IsEmpty() {
+		t.Errorf("Expected empty buffer after clear, got %+v", buffer)
+	}
+
+	// Clearing a buffer that doesn't exist is not an error
+	if err := Clear("never-used"); err != nil {
+		t.Errorf("Unexpected error clearing missing buffer: %v", err)
+	}
+}
+
+func TestConcurrentUpdates(t *testing.T) {
+	SetTestCacheDir(t.TempDir())
+	defer ResetTestCacheDir()
+
+	const writers = 20
+	var wg sync.WaitGroup
+	for i := range writers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := Update("parallel", func(b *Buffer) error {
+				b.Lines = append(b.Lines, fmt.Sprintf("line %d", i))
+				return nil
+			})
+			if err != nil {
+				t.Errorf("Update %d failed: %v", i, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	buffer, err := Load("parallel")
+	if err != nil {
+		t.Fatalf("Failed to load buffer: %v", err)
+	}
+	if len(buffer.Lines) != writers {
+		t.Errorf("Expected %d lines, got %d; concurrent appends were lost", writers, len(buffer.Lines))
+	}
+}
+
+func TestStaleLock(t *testing.T) {
+	SetTestCacheDir(t.TempDir())
+	defer ResetTestCacheDir()
+
+	path, err := bufferPath("locked")
+	if err != nil {
+		t.Fatalf("Failed to get buffer path: %v", err)
+	}
+	lockPath := path + ".lock"
+	if err := os.WriteFile(lockPath, nil, 0644); err != nil {
+		t.Fatalf("Failed to create lock file: %v", err)
+	}
+
+	// A lock left behind by a crashed invocation is broken instead of blocking
+	staleTime := time.Now().Add(-2 * lockfile.StaleAge)
+	if err := os.Chtimes(lockPath, staleTime, staleTime); err != nil {
+		t.Fatalf("Failed to age lock file: %v", err)
+	}
+	if _, err := Load("locked"); err != nil {
+		t.Errorf("Expected stale lock to be ignored, got %v", err)
+	}
+}
+
+func TestValidateSession(t *testing.T) {
+	for _, session := range []string{"default", "shell-1234", "ci_job.1"} {
+		if err := ValidateSession(session); err != nil {
+			t.Errorf("Expected %q to be valid, got %v", session, err)
+		}
+	}
+	for _, session := range []string{"", "../x", "a b", "/abs"} {
+		if err := ValidateSession(session); !errors.Is(err, ErrInvalidSession) {
+			t.Errorf("Expected %q to be invalid, got %v", session, err)
+		}
+	}
+}
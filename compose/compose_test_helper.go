@@ -0,0 +1,17 @@
+package compose
+
+import (
+	"os"
+)
+
+// SetTestCacheDir sets a custom cache directory for compose buffers in tests
+func SetTestCacheDir(dir string) {
+	userCacheDirFunc = func() (string, error) {
+		return dir, nil
+	}
+}
+
+// ResetTestCacheDir restores the default cache directory
+func ResetTestCacheDir() {
+	userCacheDirFunc = os.UserCacheDir
+}
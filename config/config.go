@@ -1,31 +1,205 @@
 package config
 
 import (
+	"cmp"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"log/slog"
+	"maps"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	// Embedded so timezone works on machines without a zoneinfo database,
+	// such as Windows and slim containers
+	_ "time/tzdata"
+
+	"github.com/yashikota/owata/atomicfile"
+	"github.com/yashikota/owata/redact"
 )
 
 const (
-	ConfigFileName  = "owata-config.json"
-	DefaultUsername = "Owata"
+	ConfigFileName    = "owata-config.json"
+	GlobalConfigDir   = "owata"       // Directory of the global config, in the user config directory
+	GlobalFileName    = "config.json" // Name of the global config in GlobalConfigDir
+	DefaultUsername   = "Owata"
+	DefaultTimeFormat = "2006-01-02 15:04 MST"
+)
+
+// Values of notify_on, which picks the run mode outcomes that are notified
+const (
+	NotifyAlways    = "always"
+	NotifyOnFailure = "failure"
+	NotifyOnSuccess = "success"
 )
 
 // Sentinel errors
 var (
 	ErrConfigFileNotFound = errors.New("config file not found")
+	ErrInvalidJSON        = errors.New("invalid JSON")
+	ErrInvalidTOML        = errors.New("invalid TOML")
+	ErrInvalidConfig      = errors.New("invalid config")
+	ErrConfigConflict     = errors.New("conflicting config files")
 )
 
 type Config struct {
 	WebhookURL string `json:"webhook_url"`
 	Username   string `json:"username"`
 	AvatarURL  string `json:"avatar_url"`
+
+	// WebhookURLFile names a file, such as a mounted secret, holding the
+	// webhook URL. When set it's used instead of webhook_url.
+	WebhookURLFile string `json:"webhook_url_file,omitempty"`
+
+	// WebhookURLCommand is a shell command, such as a password manager's
+	// CLI, that prints the webhook URL. It runs for up to
+	// WebhookURLCommandTimeout (a duration such as "30s") and takes
+	// precedence over webhook_url_file and webhook_url.
+	WebhookURLCommand        string `json:"webhook_url_command,omitempty"`
+	WebhookURLCommandTimeout string `json:"webhook_url_command_timeout,omitempty"`
+
+	// Provider is the service webhooks belong to: discord, slack, ntfy,
+	// telegram or generic. Unset means it is detected from each webhook URL's
+	// host.
+	Provider string `json:"provider,omitempty"`
+
+	// ntfy settings: an access token for private servers, and the priority
+	// (1-5 or a name such as "high") and tags of every message
+	NtfyToken    string   `json:"ntfy_token,omitempty"`
+	NtfyPriority string   `json:"ntfy_priority,omitempty"`
+	NtfyTags     []string `json:"ntfy_tags,omitempty"`
+
+	// Telegram bot token and the chat it posts to; with provider telegram
+	// these replace webhook_url
+	TelegramToken  string `json:"telegram_token,omitempty"`
+	TelegramChatID string `json:"telegram_chat_id,omitempty"`
+
+	// GenericTemplate is the text/template that renders the request body for
+	// provider generic; GenericHeaders are sent with every such request
+	GenericTemplate string            `json:"generic_template,omitempty"`
+	GenericHeaders  map[string]string `json:"generic_headers,omitempty"`
+
+	// Targets names webhooks that --target selects; DefaultTarget is used
+	// when no --target is given, before falling back to webhook_url
+	Targets       map[string]string `json:"targets,omitempty"`
+	DefaultTarget string            `json:"default_target,omitempty"`
+
+	// WebhookURLs are more webhooks every notification is mirrored to,
+	// alongside webhook_url
+	WebhookURLs []string `json:"webhook_urls,omitempty"`
+
+	// Embed groups the defaults of every notification's embed; see
+	// EmbedDefaults
+	Embed EmbedDefaults `json:"embed,omitzero"`
+
+	DefaultTitle string `json:"default_title,omitempty"`
+
+	// DefaultSource is the source of notifications sent without --source or
+	// OWATA_SOURCE, instead of "Unknown"
+	DefaultSource string `json:"default_source,omitempty"`
+
+	// DefaultColor is the embed color when a notification doesn't set one,
+	// as hex ("#9b59b6" or "0x9b59b6") or a decimal number
+	DefaultColor string `json:"default_color,omitempty"`
+
+	ThumbnailURL string `json:"thumbnail_url,omitempty"`
+	ThreadID     string `json:"thread_id,omitempty"`
+
+	// Author defaults shown at the top of the embed
+	AuthorName    string `json:"author_name,omitempty"`
+	AuthorURL     string `json:"author_url,omitempty"`
+	AuthorIconURL string `json:"author_icon_url,omitempty"`
+
+	// Footer branding; the text defaults to "Owata"
+	FooterText    string `json:"footer_text,omitempty"`
+	FooterIconURL string `json:"footer_icon_url,omitempty"`
+
+	// Timezone (an IANA name such as "Asia/Tokyo") and TimeFormat (a Go
+	// layout such as "15:04 MST") add a field with the time the notification
+	// was sent, for readers who can't see Discord's own rendering of it.
+	// Either one is enough; the other defaults to local time or
+	// DefaultTimeFormat.
+	Timezone   string `json:"timezone,omitempty"`
+	TimeFormat string `json:"time_format,omitempty"`
+
+	// ThreadNameTemplate names a new forum post for every notification,
+	// with placeholders such as {{date}} and {{time}} expanded
+	ThreadNameTemplate string `json:"thread_name_template,omitempty"`
+
+	// FallbackChain lists webhooks tried in order when webhook_url fails,
+	// all within DeliveryBudget (a duration such as "30s") if set
+	FallbackChain  []string `json:"fallback_chain,omitempty"`
+	DeliveryBudget string   `json:"delivery_budget,omitempty"`
+
+	// RateLimitRetries is how many times a 429 response is retried; unset
+	// means the default
+	RateLimitRetries *int `json:"rate_limit_retries,omitempty"`
+
+	// Retries is how many times network errors and 5xx responses are retried
+	Retries int `json:"retries,omitempty"`
+
+	// Git adds the branch, commit and dirty state of the working tree as
+	// fields, as --git does
+	Git bool `json:"git,omitempty"`
+
+	// NotifyOn limits owata run to notifying on failure or on success;
+	// unset means always
+	NotifyOn string `json:"notify_on,omitempty"`
+
+	// Sysinfo adds the OS, architecture and current user as fields, as
+	// --sysinfo does
+	Sysinfo bool `json:"sysinfo,omitempty"`
+
+	// HideHost leaves the machine's hostname out of notifications
+	HideHost bool `json:"hide_host,omitempty"`
+
+	// TTS has Discord read every notification aloud
+	TTS bool `json:"tts,omitempty"`
+
+	// EscapeMarkdown shows markdown characters in messages literally
+	EscapeMarkdown bool `json:"escape_markdown,omitempty"`
+
+	// SuppressEmbeds stops Discord from unfurling links in plain messages
+	SuppressEmbeds bool `json:"suppress_embeds,omitempty"`
+
+	// Silent posts notifications without a push or desktop notification
+	// unless --loud is given
+	Silent bool `json:"silent,omitempty"`
+
+	// AllowCustomWebhook accepts webhook URLs that aren't Discord's, such as
+	// a local test server or a compatible relay
+	AllowCustomWebhook bool `json:"allow_custom_webhook,omitempty"`
+
+	// UseWebhookDefaults omits username and avatar from payloads so Discord
+	// uses the name and avatar configured on the webhook itself, even when
+	// --username or --avatar is given. use_webhook_identity is read into it.
+	UseWebhookDefaults bool `json:"use_webhook_defaults,omitempty"`
+
+	// Profiles are named sets of settings, such as a webhook and username
+	// per Discord server, picked with --profile, OWATA_PROFILE or
+	// default_profile. The values a profile sets override the rest.
+	Profiles       map[string]Config `json:"profiles,omitempty"`
+	DefaultProfile string            `json:"default_profile,omitempty"`
+
+	// Set by Resolve rather than read from the file
+	fromEnv []string
+	source  string
+	profile string
+	files   []string          // The files read, the one whose values win last
+	origins map[string]string // The origin of each key that is set; see View
 }
 
 type Manager struct {
 	configFileName string
+	configPath     string // Set by --config; replaces the local and global files
+	profile        string // Set by --profile
+	format         string // Set by init --format; the format of a new file
+	logger         *slog.Logger
 }
 
 func NewManager() *Manager {
@@ -34,64 +208,232 @@ func NewManager() *Manager {
 	}
 }
 
+// NewManagerForFile returns a Manager that reads and writes configPath only,
+// ignoring the local and global config files
+func NewManagerForFile(configPath string) *Manager {
+	return &Manager{
+		configFileName: ConfigFileName,
+		configPath:     configPath,
+	}
+}
+
+// SetLogger makes m log how it resolves the config file at debug level
+func (m *Manager) SetLogger(logger *slog.Logger) {
+	m.logger = logger
+}
+
+// debug logs msg at debug level, if a logger is set
+func (m *Manager) debug(msg string, args ...any) {
+	if m.logger != nil {
+		m.logger.Debug(msg, args...)
+	}
+}
+
 // For testing purposes
-var userConfigDirFunc = os.UserConfigDir
+var (
+	userConfigDirFunc = os.UserConfigDir
+	userHomeDirFunc   = os.UserHomeDir
+)
 
+// GetPathWithError returns the path of the local config file, or with
+// global, the global one: owata/config.json in the user config directory
+// ($XDG_CONFIG_HOME or ~/.config on Linux, %AppData% on Windows). A global
+// config still at a legacy location is returned while it's the only one,
+// until the next save moves it.
 func (m *Manager) GetPathWithError(global bool) (string, error) {
-	if global {
-		configDir, err := userConfigDirFunc()
+	if m.configPath != "" {
+		return m.configPath, nil
+	}
+	if !global {
+		return m.pickFormat(m.configFileName)
+	}
+
+	configDir, err := userConfigDirFunc()
+	if err != nil {
+		return "", fmt.Errorf("could not determine config directory: %w", err)
+	}
+	globalPath, err := m.pickFormat(filepath.Join(configDir, GlobalConfigDir, GlobalFileName))
+	if err != nil {
+		return "", err
+	}
+	exists, err := fileExists(globalPath)
+	if err != nil || exists {
+		return globalPath, err
+	}
+
+	for _, legacy := range m.legacyGlobalPaths(configDir) {
+		legacyPath, err := m.pickFormat(legacy)
 		if err != nil {
-			return "", fmt.Errorf("could not determine config directory: %w", err)
+			return "", err
+		}
+		if exists, err := fileExists(legacyPath); err != nil || exists && !m.isLocal(legacyPath) {
+			m.debug("using legacy global config file", "path", legacyPath, "moves_to", globalPath)
+			return legacyPath, err
+		}
+	}
+	return globalPath, nil
+}
+
+// legacyGlobalPaths returns where older versions kept the global config:
+// owata-config.json in the user config directory, and before that in
+// ~/.config, which is the same place on Linux but not on Windows or macOS
+func (m *Manager) legacyGlobalPaths(configDir string) []string {
+	paths := []string{filepath.Join(configDir, m.configFileName)}
+	if home, err := userHomeDirFunc(); err == nil {
+		if path := filepath.Join(home, ".config", m.configFileName); path != paths[0] {
+			paths = append(paths, path)
 		}
-		return filepath.Join(configDir, m.configFileName), nil
 	}
-	return m.configFileName, nil
+	return paths
+}
+
+// migrationTarget returns where the global config at path moves to when it's
+// saved: owata/config.json (or .toml) in the user config directory if path
+// is at a legacy location, otherwise path itself
+func (m *Manager) migrationTarget(path string) string {
+	if m.configPath != "" {
+		return path
+	}
+	configDir, err := userConfigDirFunc()
+	if err != nil {
+		return path
+	}
+	ext := filepath.Ext(path)
+	legacy := slices.ContainsFunc(m.legacyGlobalPaths(configDir), func(legacy string) bool {
+		return strings.TrimSuffix(legacy, filepath.Ext(legacy)) == strings.TrimSuffix(path, ext)
+	})
+	if !legacy || m.isLocal(path) {
+		return path
+	}
+	return filepath.Join(configDir, GlobalConfigDir, strings.TrimSuffix(GlobalFileName, filepath.Ext(GlobalFileName))+ext)
+}
+
+// isLocal reports whether path is the local config file, as the legacy
+// global config is when owata runs in the user config directory. That file
+// is then only the local config.
+func (m *Manager) isLocal(path string) bool {
+	localPath, err := m.pickFormat(m.configFileName)
+	if err != nil {
+		return false
+	}
+	local, err := os.Stat(localPath)
+	if err != nil {
+		return false
+	}
+	info, err := os.Stat(path)
+	return err == nil && os.SameFile(local, info)
+}
+
+// write saves config to path, creating its directory, and returns the path
+// written. A legacy global config is written to its new place instead and
+// then removed.
+func (m *Manager) write(config *Config, path string) (string, error) {
+	target := m.migrationTarget(path)
+
+	// Ensure directory exists - only needed for non-current directories
+	dirPath := filepath.Dir(target)
+	if dirPath != "." {
+		if err := os.MkdirAll(dirPath, 0755); err != nil {
+			return target, fmt.Errorf("failed to create config directory: %w", err)
+		}
+	}
+	if err := m.SaveToPath(config, target); err != nil {
+		return target, err
+	}
+
+	if target != path {
+		if err := os.Remove(path); err != nil {
+			return target, fmt.Errorf("config saved to %s, but failed to remove the old %s: %v", target, path, err)
+		}
+		m.debug("moved legacy global config file", "from", path, "to", target)
+	}
+	return target, nil
 }
 
 func (m *Manager) Load(preferGlobal bool) (*Config, string, error) {
-	localPath, _ := m.GetPathWithError(false)
+	configPath, err := m.ResolvePath(preferGlobal)
+	if err != nil {
+		return nil, "", err
+	}
+
+	config, err := m.LoadFromPath(configPath)
+	if err != nil {
+		return nil, configPath, err
+	}
+
+	return config, configPath, nil
+}
+
+// ResolvePath returns the config file Load reads: the global one when
+// preferGlobal is set, otherwise the local one if it exists, else the global
+// one. A Manager for a single file only ever reads that file.
+func (m *Manager) ResolvePath(preferGlobal bool) (string, error) {
+	if m.configPath != "" {
+		exists, err := fileExists(m.configPath)
+		if err != nil {
+			return "", fmt.Errorf("error checking config file: %w", err)
+		}
+		m.debug("checked config file", "path", m.configPath, "exists", exists, "from", "--config")
+		if !exists {
+			return "", fmt.Errorf("%w: %s", ErrConfigFileNotFound, m.configPath)
+		}
+		return m.configPath, nil
+	}
+
+	localPath, err := m.GetPathWithError(false)
+	if err != nil {
+		return "", err
+	}
 	globalPath, globalPathErr := m.GetPathWithError(true)
+	if errors.Is(globalPathErr, ErrConfigConflict) {
+		return "", globalPathErr
+	}
 
 	// If we can't get global path but it was requested, return the error
 	if preferGlobal && globalPathErr != nil {
-		return nil, "", fmt.Errorf("failed to get global config path: %w", globalPathErr)
+		return "", fmt.Errorf("failed to get global config path: %w", globalPathErr)
 	}
 
 	localExists, localErr := fileExists(localPath)
 	if localErr != nil {
-		return nil, "", fmt.Errorf("error checking local config: %w", localErr)
+		return "", fmt.Errorf("error checking local config: %w", localErr)
 	}
 
 	globalExists, globalErr := fileExists(globalPath)
 	if globalErr != nil {
-		return nil, "", fmt.Errorf("error checking global config: %w", globalErr)
+		return "", fmt.Errorf("error checking global config: %w", globalErr)
 	}
-
-	var configPath string
+	m.debug("checked config files", "local", localPath, "local_exists", localExists, "global", globalPath, "global_exists", globalExists, "prefer_global", preferGlobal)
 
 	if preferGlobal {
 		if !globalExists {
-			return nil, "", fmt.Errorf("%w: global config file not found at %s", ErrConfigFileNotFound, globalPath)
+			return "", fmt.Errorf("%w: global config file not found at %s", ErrConfigFileNotFound, globalPath)
 		}
-		// Only assign configPath if globalExists is true (we wouldn't reach here otherwise)
-		configPath = globalPath
+		m.debug("using global config file", "path", globalPath)
+		return globalPath, nil
 	} else if localExists {
-		configPath = localPath
+		m.debug("using local config file", "path", localPath)
+		return localPath, nil
 	} else if globalExists {
-		configPath = globalPath
-	} else {
-		return nil, "", fmt.Errorf("%w: config file not found: neither %s nor %s exists", ErrConfigFileNotFound, localPath, globalPath)
+		m.debug("using global config file", "path", globalPath)
+		return globalPath, nil
 	}
+	return "", fmt.Errorf("%w: config file not found: neither %s nor %s exists", ErrConfigFileNotFound, localPath, globalPath)
+}
 
-	config, err := m.LoadFromPath(configPath)
+func (m *Manager) LoadFromPath(configPath string) (*Config, error) {
+	config, err := readConfig(configPath)
 	if err != nil {
-		return nil, configPath, err
+		return nil, err
 	}
-
-	return config, configPath, nil
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	return config, nil
 }
 
-func (m *Manager) LoadFromPath(configPath string) (*Config, error) {
+// readConfig parses the config file without validating it
+func readConfig(configPath string) (*Config, error) {
 	exists, err := fileExists(configPath)
 	if err != nil {
 		return nil, fmt.Errorf("error checking config file: %w", err)
@@ -105,42 +447,153 @@ func (m *Manager) LoadFromPath(configPath string) (*Config, error) {
 		return nil, fmt.Errorf("failed to read config file: %v", err)
 	}
 
-	var config Config
-	if err := json.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %v", err)
+	return decodeConfig(data, FormatOf(configPath))
+}
+
+// Validate checks values that can't be caught by parsing the JSON alone, so
+// mistakes show up when the config is loaded rather than when a notification
+// is sent. Its errors match ErrInvalidConfig.
+func (c *Config) Validate() error {
+	return invalid(c.validate())
+}
+
+func (c *Config) validate() error {
+	if _, err := c.BodyTemplate(); err != nil {
+		return fmt.Errorf("invalid generic_template in config: %v", err)
+	}
+	if c.DefaultColor != "" {
+		if _, err := ParseColor(c.DefaultColor); err != nil {
+			return fmt.Errorf("invalid default_color in config: %v", err)
+		}
 	}
+	if err := c.Embed.validate(); err != nil {
+		return err
+	}
+	if c.Timezone != "" {
+		if _, err := time.LoadLocation(c.Timezone); err != nil {
+			return fmt.Errorf("invalid timezone in config: %v (use an IANA name such as Asia/Tokyo, America/New_York or UTC)", err)
+		}
+	}
+	if _, err := c.WebhookCommandTimeout(); err != nil {
+		return err
+	}
+	switch c.NotifyOn {
+	case "", NotifyAlways, NotifyOnFailure, NotifyOnSuccess:
+	default:
+		return fmt.Errorf("invalid notify_on in config: %q (use always, failure or success)", c.NotifyOn)
+	}
+	return c.validateProfiles()
+}
 
-	return &config, nil
+// invalidConfigError marks an error as ErrInvalidConfig without changing its
+// message
+type invalidConfigError struct {
+	err error
 }
 
-func (m *Manager) Save(config *Config, global bool) (string, error) {
-	configPath, pathErr := m.GetPathWithError(global)
-	if pathErr != nil {
-		return "", fmt.Errorf("failed to get config path: %w", pathErr)
+func (e invalidConfigError) Error() string { return e.err.Error() }
+
+func (e invalidConfigError) Unwrap() []error { return []error{e.err, ErrInvalidConfig} }
+
+// invalid marks err, if any, as ErrInvalidConfig
+func invalid(err error) error {
+	if err == nil || errors.Is(err, ErrInvalidConfig) {
+		return err
 	}
+	return invalidConfigError{err}
+}
 
-	// Ensure directory exists - only needed for non-current directories
-	dirPath := filepath.Dir(configPath)
-	if dirPath != "." {
-		if err := os.MkdirAll(dirPath, 0755); err != nil {
-			return "", fmt.Errorf("failed to create config directory: %w", err)
+// Update applies edit to the config file Load would read, or to a new one at
+// the local (or with global, the global) path when there is none, then
+// validates and saves it. The file isn't validated before editing, so a bad
+// value can be fixed this way. The file is locked throughout. It returns the
+// path written.
+func (m *Manager) Update(global bool, edit func(*Config) error) (string, error) {
+	configPath, err := m.ResolvePath(global)
+	if errors.Is(err, ErrConfigFileNotFound) {
+		if configPath, err = m.GetPathWithError(global); err != nil {
+			return "", fmt.Errorf("failed to get config path: %w", err)
 		}
+	} else if err != nil {
+		return "", err
 	}
 
-	if err := m.SaveToPath(config, configPath); err != nil {
-		return configPath, err
+	written := configPath
+	err = m.withWriteLock(configPath, func() error {
+		config, err := readConfig(configPath)
+		if errors.Is(err, ErrConfigFileNotFound) {
+			config = &Config{}
+		} else if err != nil {
+			return err
+		}
+		if err := edit(config); err != nil {
+			return err
+		}
+		if err := config.Validate(); err != nil {
+			return err
+		}
+		written, err = m.write(config, configPath)
+		return err
+	})
+	return written, err
+}
+
+// UpdatePath applies edit to the config file at path, or to a new config
+// when there is none, and saves it, holding the lock throughout. Unlike
+// Update, the file must be valid before editing. It returns the path
+// written.
+func (m *Manager) UpdatePath(path string, edit func(*Config) error) (string, error) {
+	written := path
+	err := m.withWriteLock(path, func() error {
+		config, err := m.LoadFromPath(path)
+		if errors.Is(err, ErrConfigFileNotFound) {
+			config = &Config{}
+		} else if err != nil {
+			return fmt.Errorf("failed to load config: %w", err)
+		}
+		if err := edit(config); err != nil {
+			return err
+		}
+		written, err = m.write(config, path)
+		return err
+	})
+	return written, err
+}
+
+// EditPath returns the config file Load would read, creating the template at
+// the local (or with global, the global) path when there is none. created
+// reports whether the template was created.
+func (m *Manager) EditPath(global bool) (path string, created bool, err error) {
+	configPath, err := m.ResolvePath(global)
+	if errors.Is(err, ErrConfigFileNotFound) {
+		return m.CreateTemplate(global)
 	}
+	return configPath, false, err
+}
 
-	return configPath, nil
+// Save writes config to the local (or with global, the global) config file,
+// waiting for any other owata editing it to finish, and returns the path
+// written
+func (m *Manager) Save(config *Config, global bool) (string, error) {
+	configPath, pathErr := m.GetPathWithError(global)
+	if pathErr != nil {
+		return "", fmt.Errorf("failed to get config path: %w", pathErr)
+	}
+	written := configPath
+	err := m.withWriteLock(configPath, func() (err error) {
+		written, err = m.write(config, configPath)
+		return err
+	})
+	return written, err
 }
 
 func (m *Manager) SaveToPath(config *Config, configPath string) error {
-	data, err := json.MarshalIndent(config, "", "  ")
+	data, err := encodeConfig(config, FormatOf(configPath))
 	if err != nil {
 		return fmt.Errorf("failed to marshal config: %v", err)
 	}
 
-	if err := os.WriteFile(configPath, data, 0644); err != nil {
+	if err := atomicfile.Write(configPath, data, fileMode); err != nil {
 		return fmt.Errorf("failed to write config file: %v", err)
 	}
 
@@ -169,54 +622,325 @@ func (m *Manager) CreateTemplate(global bool) (string, bool, error) {
 		return configPath, false, nil // File already exists, not created
 	}
 
-	templateContent := `{
-  "webhook_url": "",
-  "username": "",
-  "avatar_url": ""
-}`
-
-	if err := os.WriteFile(configPath, []byte(templateContent), 0644); err != nil {
+	if err := atomicfile.Write(configPath, []byte(templates[FormatOf(configPath)]), fileMode); err != nil {
 		return configPath, false, fmt.Errorf("failed to create config template: %v", err)
 	}
 
 	return configPath, true, nil // New file was created
 }
 
+// ForceTemplate writes a fresh template like CreateTemplate, first moving an
+// existing config file aside to <name>.bak, replacing an older backup. backup
+// is empty when there was no file to move.
+func (m *Manager) ForceTemplate(global bool) (path, backup string, err error) {
+	configPath, pathErr := m.GetPathWithError(global)
+	if pathErr != nil {
+		return "", "", fmt.Errorf("failed to get config path: %w", pathErr)
+	}
+
+	exists, err := fileExists(configPath)
+	if err != nil {
+		return "", "", fmt.Errorf("error checking config file: %w", err)
+	}
+	if exists {
+		backup = configPath + ".bak"
+		if err := os.Rename(configPath, backup); err != nil {
+			return configPath, "", fmt.Errorf("failed to back up config file: %v", err)
+		}
+	}
+
+	// With the legacy global config backed up, the template goes to the new place
+	path, _, err = m.CreateTemplate(global)
+	if err != nil {
+		return configPath, backup, err
+	}
+	return path, backup, nil
+}
+
+// DisplayConfig describes the config at path with OWATA_ environment
+// variables applied, as notifications would use it. Without the file, the
+// environment alone is described.
 func (m *Manager) DisplayConfig(path string) (string, error) {
-	config, err := m.LoadFromPath(path)
+	view, err := m.ViewConfig(path)
 	if err != nil {
 		return "", err
 	}
+	if view.Missing != "" && len(view.Env) == 0 {
+		return "", fmt.Errorf("%w: %s", ErrConfigFileNotFound, path)
+	}
+	return view.String(), nil
+}
 
-	var output string
-	output += fmt.Sprintf("\n📋 Current configuration (%s):\n", path)
+// DisplayResolved describes the config notifications use, as Resolve
+// returns it, naming the files it was merged from
+func (m *Manager) DisplayResolved(preferGlobal bool) (string, error) {
+	view, err := m.ViewResolved(preferGlobal)
+	if err != nil {
+		return "", err
+	}
+	return view.String(), nil
+}
 
+// describe lists the values of config, one per line
+func describe(config *Config) string {
+	var output string
 	if config.WebhookURL != "" {
-		// Safely obfuscate the webhook URL - show only last few characters
-		url := config.WebhookURL
-		if len(url) > 10 {
-			// Take last 10 characters only
-			lastTen := url[len(url)-10:]
-			url = "..." + lastTen
+		// Mask the token but keep the host and webhook ID; fall back to the
+		// last few characters when the value isn't a URL
+		url := redact.URL(config.WebhookURL)
+		if url == redact.InvalidURL {
+			url = config.WebhookURL
+			if len(url) > 10 {
+				url = "..." + url[len(url)-10:]
+			}
 		}
 		output += fmt.Sprintf("  🔗 Webhook URL: %s\n", url)
 	} else {
 		output += "  🔗 Webhook URL: (not set)\n"
 	}
+	if config.WebhookURLCommand != "" {
+		output += fmt.Sprintf("  🔑 Webhook URL command: %s\n", config.WebhookURLCommand)
+	} else if config.WebhookURLFile != "" {
+		output += fmt.Sprintf("  📄 Webhook URL file: %s\n", config.WebhookURLFile)
+	}
 
-	if config.Username != "" {
-		output += fmt.Sprintf("  👤 Username: %s\n", config.Username)
+	if config.UseWebhookDefaults {
+		output += "  👤 Username: (webhook default)\n"
+		output += "  🖼️  Avatar URL: (webhook default)\n"
 	} else {
-		output += "  👤 Username: (not set)\n"
+		if config.Username != "" {
+			output += fmt.Sprintf("  👤 Username: %s\n", config.Username)
+		} else {
+			output += "  👤 Username: (not set)\n"
+		}
+
+		if config.AvatarURL != "" {
+			output += fmt.Sprintf("  🖼️  Avatar URL: %s\n", config.AvatarURL)
+		} else {
+			output += "  🖼️  Avatar URL: (not set)\n"
+		}
 	}
 
-	if config.AvatarURL != "" {
-		output += fmt.Sprintf("  🖼️  Avatar URL: %s\n", config.AvatarURL)
-	} else {
-		output += "  🖼️  Avatar URL: (not set)\n"
+	if config.DefaultTitle != "" {
+		output += fmt.Sprintf("  🏷️  Default title: %s\n", config.DefaultTitle)
+	}
+
+	if config.DefaultSource != "" {
+		output += fmt.Sprintf("  📍 Default source: %s\n", config.DefaultSource)
+	}
+
+	if config.DefaultColor != "" {
+		color, _ := ParseColor(config.DefaultColor)
+		output += fmt.Sprintf("  🎨 Default color: #%06x\n", color)
+	}
+
+	if config.Timezone != "" || config.TimeFormat != "" {
+		output += fmt.Sprintf("  🕒 Time field: %q in %s\n", cmp.Or(config.TimeFormat, DefaultTimeFormat), cmp.Or(config.Timezone, "local time"))
+	}
+
+	if config.ThumbnailURL != "" {
+		output += fmt.Sprintf("  🖼️  Thumbnail URL: %s\n", config.ThumbnailURL)
+	}
+
+	if config.AuthorName != "" {
+		output += fmt.Sprintf("  ✍️  Author: %s\n", config.AuthorName)
+	}
+
+	if config.FooterText != "" {
+		output += fmt.Sprintf("  📝 Footer: %s\n", config.FooterText)
+	}
+
+	output += config.Embed.describe()
+
+	if config.ThreadID != "" {
+		output += fmt.Sprintf("  🧵 Thread ID: %s\n", config.ThreadID)
+	}
+
+	if config.Provider != "" {
+		output += fmt.Sprintf("  📡 Provider: %s\n", config.Provider)
+	}
+
+	if config.NtfyToken != "" {
+		output += "  🔑 ntfy token: (set)\n"
+	}
+	if config.NtfyPriority != "" {
+		output += fmt.Sprintf("  📶 ntfy priority: %s\n", config.NtfyPriority)
+	}
+	if len(config.NtfyTags) > 0 {
+		output += fmt.Sprintf("  🏷️  ntfy tags: %s\n", strings.Join(config.NtfyTags, ", "))
+	}
+
+	if config.TelegramToken != "" {
+		output += "  🔑 Telegram token: (set)\n"
+	}
+	if config.TelegramChatID != "" {
+		output += fmt.Sprintf("  💬 Telegram chat ID: %s\n", config.TelegramChatID)
+	}
+
+	if config.GenericTemplate != "" {
+		output += "  🧩 Generic template: (set)\n"
+	}
+	if len(config.GenericHeaders) > 0 {
+		names := slices.Sorted(maps.Keys(config.GenericHeaders))
+		output += fmt.Sprintf("  🧩 Generic headers: %s\n", strings.Join(names, ", "))
+	}
+
+	if len(config.Targets) > 0 {
+		names := slices.Sorted(maps.Keys(config.Targets))
+		output += fmt.Sprintf("  🎯 Targets: %s\n", strings.Join(names, ", "))
 	}
 
-	return output, nil
+	if config.DefaultTarget != "" {
+		output += fmt.Sprintf("  🎯 Default target: %s\n", config.DefaultTarget)
+	}
+
+	if len(config.WebhookURLs) > 0 {
+		output += fmt.Sprintf("  📣 Mirror webhooks: %d\n", len(config.WebhookURLs))
+	}
+
+	if len(config.FallbackChain) > 0 {
+		output += fmt.Sprintf("  🔁 Fallback targets: %d\n", len(config.FallbackChain))
+	}
+
+	if config.DeliveryBudget != "" {
+		output += fmt.Sprintf("  ⏱️  Delivery budget: %s\n", config.DeliveryBudget)
+	}
+
+	if config.Retries > 0 {
+		output += fmt.Sprintf("  🔁 Retries: %d\n", config.Retries)
+	}
+
+	if config.RateLimitRetries != nil {
+		output += fmt.Sprintf("  ⏳ Rate limit retries: %d\n", *config.RateLimitRetries)
+	}
+
+	if config.NotifyOn != "" {
+		output += fmt.Sprintf("  🔔 Notify on: %s\n", config.NotifyOn)
+	}
+
+	if config.Git {
+		output += "  🌿 Git fields: enabled\n"
+	}
+
+	if config.Sysinfo {
+		output += "  🖥️ System info fields: enabled\n"
+	}
+
+	if config.HideHost {
+		output += "  🙈 Host field: hidden\n"
+	}
+
+	if config.TTS {
+		output += "  🔊 Text-to-speech: enabled\n"
+	}
+
+	if config.EscapeMarkdown {
+		output += "  🔤 Escape markdown: enabled\n"
+	}
+
+	if config.SuppressEmbeds {
+		output += "  🔕 Link previews: suppressed\n"
+	}
+
+	if config.Silent {
+		output += "  🤫 Silent: enabled\n"
+	}
+
+	if config.AllowCustomWebhook {
+		output += "  ⚠️  Custom webhook URLs: allowed\n"
+	}
+
+	if config.ThreadNameTemplate != "" {
+		output += fmt.Sprintf("  🧵 Thread name template: %s\n", config.ThreadNameTemplate)
+	}
+
+	if config.source != "" {
+		output += fmt.Sprintf("  🏷️  Source: %s\n", config.source)
+	}
+
+	if len(config.Profiles) > 0 {
+		names := config.ProfileNames()
+		for i, name := range names {
+			if name == config.profile {
+				names[i] += " (active)"
+			}
+		}
+		output += fmt.Sprintf("  👥 Profiles: %s\n", strings.Join(names, ", "))
+	}
+
+	if len(config.fromEnv) > 0 {
+		output += fmt.Sprintf("  🌱 From the environment (overriding the file): %s\n", strings.Join(config.fromEnv, ", "))
+	}
+
+	return output
+}
+
+// templateFuncs are available in generic_template; json renders a value as
+// JSON, so {{json .Message}} yields a quoted, escaped string
+var templateFuncs = template.FuncMap{
+	"json": func(v any) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+}
+
+// BodyTemplate parses generic_template, returning nil if it isn't set
+func (c *Config) BodyTemplate() (*template.Template, error) {
+	if c.GenericTemplate == "" {
+		return nil, nil
+	}
+	return template.New("generic_template").Funcs(templateFuncs).Parse(c.GenericTemplate)
+}
+
+// ParseColor reads an embed color written as hex ("#9b59b6" or "0x9b59b6")
+// or as a decimal number
+func ParseColor(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	digits, base := s, 10
+	if after, ok := strings.CutPrefix(s, "#"); ok {
+		digits, base = after, 16
+	} else if after, ok := strings.CutPrefix(strings.ToLower(s), "0x"); ok {
+		digits, base = after, 16
+	}
+
+	color, err := strconv.ParseInt(digits, base, 64)
+	if err != nil || color < 0 || color > 0xFFFFFF {
+		return 0, fmt.Errorf("%q is not a color; use hex such as #9b59b6 or a decimal number up to 16777215", s)
+	}
+	return int(color), nil
+}
+
+// Color returns the parsed embed color, the embed section's or else
+// default_color, or 0 if it is unset or invalid
+func (c *Config) Color() int {
+	if c == nil {
+		return 0
+	}
+	value := cmp.Or(c.Embed.Color, c.DefaultColor)
+	if value == "" {
+		return 0
+	}
+	color, _ := ParseColor(value)
+	return color
+}
+
+// FormatTime renders t in the configured timezone and layout for the time
+// field, or returns "" if neither timezone nor time_format is set
+func (c *Config) FormatTime(t time.Time) string {
+	if c == nil || (c.Timezone == "" && c.TimeFormat == "") {
+		return ""
+	}
+	return t.In(c.Location()).Format(cmp.Or(c.TimeFormat, DefaultTimeFormat))
+}
+
+// Location returns the configured timezone, or local time if none is set
+func (c *Config) Location() *time.Location {
+	if c != nil && c.Timezone != "" {
+		if l, err := time.LoadLocation(c.Timezone); err == nil {
+			return l
+		}
+	}
+	return time.Local
 }
 
 func fileExists(path string) (bool, error) {
@@ -1,10 +1,18 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/yashikota/owata/atomicfile"
+	"github.com/yashikota/owata/lockfile"
 )
 
 func TestGetPathWithError(t *testing.T) {
@@ -24,7 +32,7 @@ func TestGetPathWithError(t *testing.T) {
 	SetTestConfigDir(tempDir)
 	defer ResetTestConfigDir()
 
-	expectedGlobalPath := filepath.Join(tempDir, ConfigFileName)
+	expectedGlobalPath := filepath.Join(tempDir, GlobalConfigDir, GlobalFileName)
 	globalPath, err := manager.GetPathWithError(true)
 	if err != nil {
 		t.Fatalf("Expected no error for global path, got: %v", err)
@@ -90,6 +98,123 @@ func TestLoadFromPath(t *testing.T) {
 	if err == nil {
 		t.Error("Expected error when loading invalid JSON, got nil")
 	}
+
+	// Test loading a config whose generic template doesn't parse
+	badTemplateFile := filepath.Join(tempDir, "bad-template.json")
+	if err := os.WriteFile(badTemplateFile, []byte(`{"generic_template": "{{.Message"}`), 0644); err != nil {
+		t.Fatalf("Failed to write bad template test file: %v", err)
+	}
+
+	_, err = manager.LoadFromPath(badTemplateFile)
+	if err == nil || !strings.Contains(err.Error(), "invalid generic_template") {
+		t.Errorf("Expected a generic_template error, got %v", err)
+	}
+
+	// Test loading a config whose default color isn't a color
+	badColorFile := filepath.Join(tempDir, "bad-color.json")
+	if err := os.WriteFile(badColorFile, []byte(`{"default_color": "purple"}`), 0644); err != nil {
+		t.Fatalf("Failed to write bad color test file: %v", err)
+	}
+
+	_, err = manager.LoadFromPath(badColorFile)
+	if err == nil || !strings.Contains(err.Error(), "invalid default_color") {
+		t.Errorf("Expected a default_color error, got %v", err)
+	}
+
+	// Test loading a config with an unknown notify_on
+	badNotifyOnFile := filepath.Join(tempDir, "bad-notify-on.json")
+	if err := os.WriteFile(badNotifyOnFile, []byte(`{"notify_on": "sometimes"}`), 0644); err != nil {
+		t.Fatalf("Failed to write bad notify_on test file: %v", err)
+	}
+
+	_, err = manager.LoadFromPath(badNotifyOnFile)
+	if err == nil || !strings.Contains(err.Error(), "invalid notify_on") {
+		t.Errorf("Expected a notify_on error, got %v", err)
+	}
+	if !errors.Is(err, ErrInvalidConfig) {
+		t.Errorf("Expected the error to match ErrInvalidConfig, got %v", err)
+	}
+
+	// Test loading a config with a misspelled timezone
+	badTimezoneFile := filepath.Join(tempDir, "bad-timezone.json")
+	if err := os.WriteFile(badTimezoneFile, []byte(`{"timezone": "Asia/Tokio"}`), 0644); err != nil {
+		t.Fatalf("Failed to write bad timezone test file: %v", err)
+	}
+
+	_, err = manager.LoadFromPath(badTimezoneFile)
+	if err == nil || !strings.Contains(err.Error(), "invalid timezone") || !strings.Contains(err.Error(), "IANA") {
+		t.Errorf("Expected a timezone error with a hint, got %v", err)
+	}
+}
+
+func TestFormatTime(t *testing.T) {
+	at := time.Date(2025, 6, 1, 9, 32, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		config   *Config
+		expected string
+	}{
+		{"Nil config", nil, ""},
+		{"Neither set", &Config{}, ""},
+		{"Timezone only", &Config{Timezone: "Asia/Tokyo"}, "2025-06-01 18:32 JST"},
+		{"Both set", &Config{Timezone: "Asia/Tokyo", TimeFormat: "Finished at 15:04 MST"}, "Finished at 18:32 JST"},
+		{"UTC", &Config{Timezone: "UTC", TimeFormat: time.Kitchen}, "9:32AM"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.config.FormatTime(at); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestLocation(t *testing.T) {
+	if got := (*Config)(nil).Location(); got != time.Local {
+		t.Errorf("Expected local time for a nil config, got %v", got)
+	}
+	if got := (&Config{Timezone: "Asia/Tokyo"}).Location(); got.String() != "Asia/Tokyo" {
+		t.Errorf("Expected Asia/Tokyo, got %v", got)
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	tests := []struct {
+		name        string
+		input       string
+		expected    int
+		expectedErr bool
+	}{
+		{"Hex with hash", "#9b59b6", 0x9b59b6, false},
+		{"Hex with 0x", "0x9B59B6", 0x9b59b6, false},
+		{"Decimal", "10181046", 0x9b59b6, false},
+		{"White", "#ffffff", 0xffffff, false},
+		{"Name", "purple", 0, true},
+		{"Hex without prefix", "9b59b6", 0, true},
+		{"Too large", "#1000000", 0, true},
+		{"Negative", "-1", 0, true},
+		{"Empty", "", 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			color, err := ParseColor(tt.input)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected error, got color %d", color)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if color != tt.expected {
+				t.Errorf("Expected color %d, got %d", tt.expected, color)
+			}
+		})
+	}
 }
 
 func TestSaveToPath(t *testing.T) {
@@ -189,7 +314,7 @@ func TestCreateTemplate(t *testing.T) {
 
 	// Test case 3: Create global template
 	// First, make sure the file doesn't exist already
-	expectedGlobalPath := filepath.Join(tempDir, "owata-config.json")
+	expectedGlobalPath := filepath.Join(tempDir, GlobalConfigDir, GlobalFileName)
 	os.Remove(expectedGlobalPath)
 
 	globalPath, created, err := manager.CreateTemplate(true)
@@ -318,7 +443,8 @@ func TestLoad(t *testing.T) {
 			AvatarURL:  "https://example.com/global-avatar.png",
 		}
 
-		globalPath := filepath.Join(tempDir, ConfigFileName)
+		globalPath := filepath.Join(tempDir, GlobalConfigDir, GlobalFileName)
+		os.MkdirAll(filepath.Dir(globalPath), 0755)
 		globalData, _ := json.MarshalIndent(globalConfig, "", "  ")
 		if err := os.WriteFile(globalPath, globalData, 0644); err != nil {
 			t.Fatalf("Failed to write global config: %v", err)
@@ -433,7 +559,8 @@ func TestLoad(t *testing.T) {
 		}
 
 		// Write it to the expected global path
-		globalPath := filepath.Join(tempDir, ConfigFileName)
+		globalPath := filepath.Join(tempDir, GlobalConfigDir, GlobalFileName)
+		os.MkdirAll(filepath.Dir(globalPath), 0755)
 		globalJSON, _ := json.MarshalIndent(globalConfig, "", "  ")
 		err = os.WriteFile(globalPath, globalJSON, 0644)
 		if err != nil {
@@ -469,7 +596,7 @@ func TestLoad(t *testing.T) {
 		manager := NewManager()
 
 		// Make sure global config doesn't exist
-		globalPath := filepath.Join(tempDir, ConfigFileName)
+		globalPath := filepath.Join(tempDir, GlobalConfigDir, GlobalFileName)
 		os.Remove(globalPath)
 
 		// Create only local config
@@ -487,8 +614,8 @@ func TestLoad(t *testing.T) {
 
 		// Delete any existing global config to make sure it doesn't exist
 		// Important to handle the case where macOS has symlinked /var/folders to /private/var/folders
-		os.Remove(filepath.Join(tempDir, ConfigFileName))
-		os.Remove(filepath.Join("/private"+tempDir, ConfigFileName))
+		os.Remove(filepath.Join(tempDir, GlobalConfigDir, GlobalFileName))
+		os.Remove(filepath.Join("/private"+tempDir, GlobalConfigDir, GlobalFileName))
 
 		// Test loading with global preference - should fail since global doesn't exist
 		_, _, err := manager.Load(true)
@@ -545,7 +672,7 @@ func TestSave(t *testing.T) {
 	}
 
 	// Get expected global path
-	expectedGlobalPath := filepath.Join(tempDir, ConfigFileName)
+	expectedGlobalPath := filepath.Join(tempDir, GlobalConfigDir, GlobalFileName)
 	if globalSavedPath != expectedGlobalPath {
 		t.Errorf("Expected global save path to be %s, got %s", expectedGlobalPath, globalSavedPath)
 	}
@@ -582,3 +709,398 @@ func TestSave(t *testing.T) {
 		t.Errorf("Loaded global config doesn't match original.\nExpected: %+v\nGot: %+v", testConfig, loadedConfig)
 	}
 }
+
+func TestUpdate(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	manager := NewManager()
+
+	// No config yet, so a local one is created
+	path, err := manager.Update(false, func(c *Config) error { return c.Set("username", "Bot") })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != ConfigFileName {
+		t.Errorf("Expected the local config to be created, got %s", path)
+	}
+
+	// An invalid value is rejected and the file is left alone
+	_, err = manager.Update(false, func(c *Config) error { return c.Set("timezone", "Mars/Olympus") })
+	if err == nil || !strings.Contains(err.Error(), "invalid timezone") {
+		t.Errorf("Expected an invalid timezone error, got %v", err)
+	}
+
+	// A file that no longer validates can still be fixed
+	os.WriteFile(ConfigFileName, []byte(`{"username": "Bot", "notify_on": "sometimes"}`), 0644)
+	if _, err := manager.Update(false, func(c *Config) error { return c.Set("notify_on", "failure") }); err != nil {
+		t.Fatalf("Expected fixing notify_on to work, got %v", err)
+	}
+	cfg, err := manager.LoadFromPath(ConfigFileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.Username != "Bot" || cfg.NotifyOn != NotifyOnFailure {
+		t.Errorf("Expected username kept and notify_on fixed, got %+v", cfg)
+	}
+}
+
+func TestEditPath(t *testing.T) {
+	tempDir := t.TempDir()
+	globalDir := t.TempDir()
+	SetTestConfigDir(globalDir)
+	defer ResetTestConfigDir()
+
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	manager := NewManager()
+
+	// No config yet, so the template is created
+	path, created, err := manager.EditPath(true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !created || path != filepath.Join(globalDir, GlobalConfigDir, GlobalFileName) {
+		t.Errorf("Expected the global template to be created, got %s, created=%v", path, created)
+	}
+
+	// Without -g the existing global config is edited rather than a new local one
+	path, created, err = manager.EditPath(false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if created || path != filepath.Join(globalDir, GlobalConfigDir, GlobalFileName) {
+		t.Errorf("Expected the global config, got %s, created=%v", path, created)
+	}
+
+	// Invalid JSON is told apart from invalid values
+	os.WriteFile(path, []byte(`{"username": "Bot",}`), 0644)
+	if _, err := manager.LoadFromPath(path); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("Expected ErrInvalidJSON, got %v", err)
+	}
+	os.WriteFile(path, []byte(`{"notify_on": "sometimes"}`), 0644)
+	if _, err := manager.LoadFromPath(path); err == nil || errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("Expected a validation error other than ErrInvalidJSON, got %v", err)
+	}
+}
+
+func TestManagerDebugLog(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	var log bytes.Buffer
+	manager := NewManager()
+	manager.SetLogger(slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	manager.Save(&Config{Username: "Bot"}, false)
+
+	if _, _, err := manager.Resolve(false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"checked config files", "local_exists=true", "global_exists=false", `msg="using local config file" path=` + ConfigFileName, `msg="resolved config"`} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("Expected %q in the log:\n%s", want, log.String())
+		}
+	}
+}
+
+func TestNewManagerForFile(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	// A local config that --config must not fall back to
+	if _, err := NewManager().Save(&Config{Username: "Local"}, false); err != nil {
+		t.Fatalf("Failed to save local config: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "etc", "prod.json")
+	manager := NewManagerForFile(path)
+
+	if _, _, err := manager.Load(false); !errors.Is(err, ErrConfigFileNotFound) {
+		t.Errorf("Expected ErrConfigFileNotFound for a missing file, got %v", err)
+	}
+
+	saved, err := manager.Update(false, func(c *Config) error { return c.Set("username", "Prod") })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if saved != path {
+		t.Errorf("Expected %s to be written, got %s", path, saved)
+	}
+
+	config, loaded, err := manager.Load(false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if loaded != path || config.Username != "Prod" {
+		t.Errorf("Expected Prod from %s, got %q from %s", path, config.Username, loaded)
+	}
+	if got, _ := manager.GetPathWithError(true); got != path {
+		t.Errorf("Expected the file for the global path too, got %s", got)
+	}
+}
+
+func TestForceTemplate(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	manager := NewManager()
+
+	// Nothing to back up
+	path, backup, err := manager.ForceTemplate(false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if path != ConfigFileName || backup != "" {
+		t.Errorf("Expected a template and no backup, got %s, %q", path, backup)
+	}
+
+	// A corrupted config is moved aside and replaced
+	corrupted := []byte(`{"webhook_url": "https://discord.com/api/webhooks/1/abc",`)
+	os.WriteFile(ConfigFileName, corrupted, 0644)
+	path, backup, err = manager.ForceTemplate(false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if backup != ConfigFileName+".bak" {
+		t.Errorf("Expected a backup at %s.bak, got %q", ConfigFileName, backup)
+	}
+	if data, _ := os.ReadFile(backup); string(data) != string(corrupted) {
+		t.Errorf("Expected the backup to hold the old file, got %q", data)
+	}
+	config, err := manager.LoadFromPath(path)
+	if err != nil {
+		t.Fatalf("Expected a fresh template that loads, got %v", err)
+	}
+	if config.WebhookURL != "" {
+		t.Errorf("Expected an empty template, got %+v", config)
+	}
+}
+
+// TestLegacyGlobalConfig tests that a global config still at the old
+// owata-config.json in the config directory is read, and moved to
+// owata/config.json when it's next saved
+func TestLegacyGlobalConfig(t *testing.T) {
+	setup := func(t *testing.T) (globalDir, legacyPath, newPath string) {
+		currentDir, _ := os.Getwd()
+		t.Cleanup(func() { os.Chdir(currentDir) })
+		os.Chdir(t.TempDir())
+		globalDir = t.TempDir()
+		SetTestConfigDir(globalDir)
+		t.Cleanup(ResetTestConfigDir)
+
+		legacyPath = filepath.Join(globalDir, ConfigFileName)
+		os.WriteFile(legacyPath, []byte(`{"webhook_url": "https://discord.com/api/webhooks/1/legacy", "username": "Legacy"}`), 0644)
+		return globalDir, legacyPath, filepath.Join(globalDir, GlobalConfigDir, GlobalFileName)
+	}
+
+	t.Run("Read while it's the only one", func(t *testing.T) {
+		_, legacyPath, _ := setup(t)
+		config, path, err := NewManager().Load(true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if path != legacyPath || config.Username != "Legacy" {
+			t.Errorf("Expected the legacy config, got %+v from %s", config, path)
+		}
+	})
+
+	t.Run("New location wins", func(t *testing.T) {
+		_, _, newPath := setup(t)
+		os.MkdirAll(filepath.Dir(newPath), 0755)
+		os.WriteFile(newPath, []byte(`{"username": "New"}`), 0644)
+
+		config, path, err := NewManager().Load(true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if path != newPath || config.Username != "New" {
+			t.Errorf("Expected the new config, got %+v from %s", config, path)
+		}
+	})
+
+	t.Run("Save moves it", func(t *testing.T) {
+		_, legacyPath, newPath := setup(t)
+		path, err := NewManager().Save(&Config{Username: "Saved"}, true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if path != newPath {
+			t.Errorf("Expected the config to be saved to %s, got %s", newPath, path)
+		}
+		if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+			t.Errorf("Expected the legacy config to be removed, got %v", err)
+		}
+	})
+
+	t.Run("Update moves it with its values", func(t *testing.T) {
+		_, legacyPath, newPath := setup(t)
+		manager := NewManager()
+		path, err := manager.Update(true, func(c *Config) error {
+			c.AvatarURL = "https://example.com/avatar.png"
+			return nil
+		})
+		if err != nil || path != newPath {
+			t.Fatalf("Expected the update to be saved to %s, got %s, %v", newPath, path, err)
+		}
+		config, err := manager.LoadFromPath(newPath)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Username != "Legacy" || config.AvatarURL == "" {
+			t.Errorf("Expected the legacy values and the update, got %+v", config)
+		}
+		if _, err := os.Stat(legacyPath); !os.IsNotExist(err) {
+			t.Errorf("Expected the legacy config to be removed, got %v", err)
+		}
+	})
+
+	t.Run("Save locks the new location", func(t *testing.T) {
+		originalTimeout := lockfile.Timeout
+		lockfile.Timeout = 50 * time.Millisecond
+		defer func() { lockfile.Timeout = originalTimeout }()
+
+		// Another owata already sees the moved config and is editing it
+		_, legacyPath, newPath := setup(t)
+		os.MkdirAll(filepath.Dir(newPath), 0755)
+		os.WriteFile(newPath+".lock", nil, 0644)
+
+		_, err := NewManager().Save(&Config{Username: "Saved"}, true)
+		if !errors.Is(err, ErrConfigLocked) || !strings.Contains(err.Error(), newPath+".lock") {
+			t.Fatalf("Expected the new location to be locked, got %v", err)
+		}
+		if _, err := os.Stat(legacyPath); err != nil {
+			t.Errorf("Expected the legacy config to be left alone, got %v", err)
+		}
+		if _, err := os.Stat(newPath + ".lock"); err != nil {
+			t.Errorf("Expected the other owata's lock to be kept, got %v", err)
+		}
+	})
+
+	t.Run("TOML keeps its format", func(t *testing.T) {
+		globalDir, legacyPath, _ := setup(t)
+		os.Remove(legacyPath)
+		legacyTOML := filepath.Join(globalDir, "owata-config.toml")
+		os.WriteFile(legacyTOML, []byte("username = \"Legacy\"\n"), 0644)
+
+		path, err := NewManager().Update(true, func(c *Config) error { return nil })
+		want := filepath.Join(globalDir, GlobalConfigDir, "config.toml")
+		if err != nil || path != want {
+			t.Errorf("Expected the config to move to %s, got %s, %v", want, path, err)
+		}
+	})
+
+	t.Run("Force replaces it at the new location", func(t *testing.T) {
+		_, legacyPath, newPath := setup(t)
+		path, backup, err := NewManager().ForceTemplate(true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if path != newPath || backup != legacyPath+".bak" {
+			t.Errorf("Expected a template at %s and a backup of the legacy config, got %s, %s", newPath, path, backup)
+		}
+	})
+
+	t.Run("Home .config location", func(t *testing.T) {
+		// As on Windows, where the user config directory is %AppData%
+		globalDir, legacyPath, _ := setup(t)
+		os.Remove(legacyPath)
+		home := t.TempDir()
+		userHomeDirFunc = func() (string, error) { return home, nil }
+		oldPath := filepath.Join(home, ".config", ConfigFileName)
+		os.MkdirAll(filepath.Dir(oldPath), 0755)
+		os.WriteFile(oldPath, []byte(`{"username": "Home"}`), 0644)
+
+		config, path, err := NewManager().Load(true)
+		if err != nil || path != oldPath || config.Username != "Home" {
+			t.Fatalf("Expected the config in ~/.config, got %+v from %s, %v", config, path, err)
+		}
+		path, err = NewManager().Save(config, true)
+		if want := filepath.Join(globalDir, GlobalConfigDir, GlobalFileName); err != nil || path != want {
+			t.Errorf("Expected the config to move to %s, got %s, %v", want, path, err)
+		}
+		if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+			t.Errorf("Expected the config in ~/.config to be removed, got %v", err)
+		}
+	})
+
+	t.Run("Local config in the config directory stays put", func(t *testing.T) {
+		globalDir, legacyPath, newPath := setup(t)
+		os.Chdir(globalDir)
+
+		if path, err := NewManager().GetPathWithError(true); err != nil || path != newPath {
+			t.Errorf("Expected the local file not to be taken for the global config, got %s, %v", path, err)
+		}
+		if _, err := NewManager().Save(&Config{Username: "Local"}, false); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if _, err := os.Stat(legacyPath); err != nil {
+			t.Errorf("Expected the local config to be kept, got %v", err)
+		}
+	})
+}
+
+func TestDescribeWebhookURL(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		expected string
+	}{
+		{"Discord", "https://discord.com/api/webhooks/123456789/secret-token", "Webhook URL: https://discord.com/api/webhooks/123456789/****\n"},
+		{"discordapp.com", "https://discordapp.com/api/webhooks/123456789/secret-token", "Webhook URL: https://discordapp.com/api/webhooks/123456789/****\n"},
+		{"Not a URL", "not-a-url-but-a-secret", "Webhook URL: ...t-a-secret\n"},
+		{"Short garbage", "garbage", "Webhook URL: garbage\n"},
+		{"Not set", "", "Webhook URL: (not set)\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describe(&Config{WebhookURL: tt.url})
+			if !strings.Contains(got, tt.expected) {
+				t.Errorf("Expected %q in output:\n%s", tt.expected, got)
+			}
+			if strings.Contains(got, "secret-token") {
+				t.Errorf("Expected the token to be hidden, got:\n%s", got)
+			}
+		})
+	}
+}
+
+func TestSaveToPathFailure(t *testing.T) {
+	t.Cleanup(atomicfile.ResetTestRenameError)
+	path := filepath.Join(t.TempDir(), "config.json")
+	manager := NewManagerForFile(path)
+	if err := manager.SaveToPath(&Config{Username: "Old"}, path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	atomicfile.SetTestRenameError(errors.New("interrupted"))
+	err := manager.SaveToPath(&Config{Username: "New"}, path)
+	if err == nil || !strings.Contains(err.Error(), "failed to write config file") {
+		t.Errorf("Expected a write error, got %v", err)
+	}
+	config, err := manager.LoadFromPath(path)
+	if err != nil || config.Username != "Old" {
+		t.Errorf("Expected the old config intact, got %+v, %v", config, err)
+	}
+}
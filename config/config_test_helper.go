@@ -8,9 +8,11 @@ var (
 	configDirMu   sync.RWMutex
 	testConfigDir string
 	originalFunc  = userConfigDirFunc
+	originalHome  = userHomeDirFunc
 )
 
-// SetTestConfigDir sets a custom config directory for testing
+// SetTestConfigDir sets a custom config directory for testing. It's the home
+// directory too, so the legacy ~/.config location is inside it.
 func SetTestConfigDir(dir string) {
 	configDirMu.Lock()
 	defer configDirMu.Unlock()
@@ -22,6 +24,7 @@ func SetTestConfigDir(dir string) {
 	userConfigDirFunc = func() (string, error) {
 		return testConfigDir, nil
 	}
+	userHomeDirFunc = userConfigDirFunc
 }
 
 // ResetTestConfigDir resets to the original function
@@ -34,4 +37,5 @@ func ResetTestConfigDir() {
 
 	// Restore the original function
 	userConfigDirFunc = originalFunc
+	userHomeDirFunc = originalHome
 }
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// EmbedDefaults is the embed section of the config: the appearance every
+// notification's embed starts from, which options given on the command line
+// override. Its title, color and footer take precedence over default_title,
+// default_color and footer_text.
+type EmbedDefaults struct {
+	Title  string `json:"title,omitempty"`
+	Color  string `json:"color,omitempty"` // Written like default_color
+	Footer string `json:"footer,omitempty"`
+
+	// ShowCwd and ShowHost set to false leave out the Working Directory and
+	// Host fields; unset means they are shown
+	ShowCwd  *bool `json:"show_cwd,omitempty"`
+	ShowHost *bool `json:"show_host,omitempty"`
+
+	// Fields are added to every notification, written like --field as
+	// "Name=Value" or "Name=Value:inline". A --field of the same name
+	// replaces one.
+	Fields []string `json:"fields,omitempty"`
+}
+
+// UnmarshalJSON rejects keys the embed section doesn't have, so a typo such
+// as "titel" is an error rather than a setting silently ignored
+func (e *EmbedDefaults) UnmarshalJSON(data []byte) error {
+	type embedDefaults EmbedDefaults
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	var decoded embedDefaults
+	if err := decoder.Decode(&decoded); err != nil {
+		if name, ok := strings.CutPrefix(err.Error(), "json: unknown field "); ok {
+			return fmt.Errorf("unknown key %s in embed (valid keys: %s)", name, strings.Join(embedKeys(), ", "))
+		}
+		return fmt.Errorf("embed: %v", err)
+	}
+	*e = EmbedDefaults(decoded)
+	return nil
+}
+
+// embedKeys returns the keys of the embed section, in the order of
+// EmbedDefaults
+func embedKeys() []string {
+	t := reflect.TypeFor[EmbedDefaults]()
+	keys := make([]string, t.NumField())
+	for i := range t.NumField() {
+		keys[i] = jsonKey(t.Field(i))
+	}
+	return keys
+}
+
+func (e *EmbedDefaults) validate() error {
+	if e.Color != "" {
+		if _, err := ParseColor(e.Color); err != nil {
+			return fmt.Errorf("invalid embed color in config: %v", err)
+		}
+	}
+	for _, spec := range e.Fields {
+		name, value, ok := strings.Cut(spec, "=")
+		if !ok || strings.TrimSpace(name) == "" || value == "" {
+			return fmt.Errorf("invalid embed field in config: %q (use Name=Value or Name=Value:inline)", spec)
+		}
+	}
+	return nil
+}
+
+// describe lists the values set in the embed section under a heading of its
+// own, or returns "" when none are
+func (e *EmbedDefaults) describe() string {
+	if reflect.ValueOf(*e).IsZero() {
+		return ""
+	}
+	shown := map[bool]string{true: "shown", false: "hidden"}
+	output := "  🧱 Embed:\n"
+	if e.Title != "" {
+		output += fmt.Sprintf("      Title: %s\n", e.Title)
+	}
+	if e.Color != "" {
+		color, _ := ParseColor(e.Color)
+		output += fmt.Sprintf("      Color: #%06x\n", color)
+	}
+	if e.Footer != "" {
+		output += fmt.Sprintf("      Footer: %s\n", e.Footer)
+	}
+	if e.ShowCwd != nil {
+		output += fmt.Sprintf("      Working directory field: %s\n", shown[*e.ShowCwd])
+	}
+	if e.ShowHost != nil {
+		output += fmt.Sprintf("      Host field: %s\n", shown[*e.ShowHost])
+	}
+	if len(e.Fields) > 0 {
+		output += fmt.Sprintf("      Fields: %s\n", strings.Join(e.Fields, ", "))
+	}
+	return output
+}
+
+// Title returns the configured embed title: the embed section's, then
+// default_title
+func (c *Config) Title() string {
+	if c == nil {
+		return ""
+	}
+	return cmp.Or(c.Embed.Title, c.DefaultTitle)
+}
+
+// Footer returns the configured footer text: the embed section's, then
+// footer_text
+func (c *Config) Footer() string {
+	if c == nil {
+		return ""
+	}
+	return cmp.Or(c.Embed.Footer, c.FooterText)
+}
+
+// ShowCwd reports whether notifications include the Working Directory field
+func (c *Config) ShowCwd() bool {
+	return c == nil || c.Embed.ShowCwd == nil || *c.Embed.ShowCwd
+}
+
+// ShowHost reports whether notifications include the Host field, which
+// hide_host or show_host set to false in the embed section leave out
+func (c *Config) ShowHost() bool {
+	if c == nil {
+		return true
+	}
+	return !c.HideHost && (c.Embed.ShowHost == nil || *c.Embed.ShowHost)
+}
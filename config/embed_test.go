@@ -0,0 +1,193 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDecodeEmbed(t *testing.T) {
+	tests := []struct {
+		name        string
+		data        string
+		format      string
+		expectedErr error
+		expected    string // A value read back with Get("embed")
+	}{
+		{
+			name:     "JSON",
+			data:     `{"embed": {"title": "Deploy", "show_host": false, "fields": ["Environment=staging"]}}`,
+			format:   FormatJSON,
+			expected: `{"title":"Deploy","show_host":false,"fields":["Environment=staging"]}`,
+		},
+		{
+			name:     "TOML",
+			data:     "[embed]\ncolor = \"#ff0000\"\nshow_cwd = false\n",
+			format:   FormatTOML,
+			expected: `{"color":"#ff0000","show_cwd":false}`,
+		},
+		{
+			name:        "Typo in JSON",
+			data:        `{"embed": {"titel": "Deploy"}}`,
+			format:      FormatJSON,
+			expectedErr: ErrInvalidJSON,
+		},
+		{
+			name:        "Typo in TOML",
+			data:        "[embed]\nshow_hostname = false\n",
+			format:      FormatTOML,
+			expectedErr: ErrInvalidTOML,
+		},
+		{
+			name:        "Wrong type",
+			data:        `{"embed": {"fields": "Environment=staging"}}`,
+			format:      FormatJSON,
+			expectedErr: ErrInvalidJSON,
+		},
+		{
+			name:     "Unknown top-level keys are still ignored",
+			data:     `{"embedd": {"title": "Deploy"}}`,
+			format:   FormatJSON,
+			expected: "",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := decodeConfig([]byte(tt.data), tt.format)
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Fatalf("Expected %v, got %v", tt.expectedErr, err)
+				}
+				if strings.Contains(tt.data, "titel") && !strings.Contains(err.Error(), `unknown key "titel" in embed (valid keys: title, color,`) {
+					t.Errorf("Expected the unknown key and the valid ones, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got, _ := config.Get("embed", nil); got != tt.expected {
+				t.Errorf("Expected %s, got %s", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidateEmbed(t *testing.T) {
+	tests := []struct {
+		name        string
+		embed       EmbedDefaults
+		expectedErr string
+	}{
+		{name: "Empty", embed: EmbedDefaults{}},
+		{name: "Valid", embed: EmbedDefaults{Color: "0x2ecc71", Fields: []string{"Environment=staging", "Team=infra:inline"}}},
+		{name: "Invalid color", embed: EmbedDefaults{Color: "purple"}, expectedErr: "invalid embed color"},
+		{name: "Field without a value", embed: EmbedDefaults{Fields: []string{"Environment="}}, expectedErr: `invalid embed field in config: "Environment="`},
+		{name: "Field without a name", embed: EmbedDefaults{Fields: []string{"staging"}}, expectedErr: "invalid embed field"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := (&Config{Embed: tt.embed}).Validate()
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if !errors.Is(err, ErrInvalidConfig) || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected an invalid config error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestEmbedDefaults(t *testing.T) {
+	shown, hidden := true, false
+	tests := []struct {
+		name     string
+		config   *Config
+		title    string
+		footer   string
+		color    int
+		showCwd  bool
+		showHost bool
+	}{
+		{name: "No config", config: nil, showCwd: true, showHost: true},
+		{
+			name:     "Top-level keys",
+			config:   &Config{DefaultTitle: "Build", FooterText: "acme", DefaultColor: "#9b59b6"},
+			title:    "Build",
+			footer:   "acme",
+			color:    0x9b59b6,
+			showCwd:  true,
+			showHost: true,
+		},
+		{
+			name: "Embed section wins",
+			config: &Config{
+				DefaultTitle: "Build", FooterText: "acme", DefaultColor: "#9b59b6",
+				Embed: EmbedDefaults{Title: "Deploy", Footer: "ops", Color: "#2ecc71"},
+			},
+			title:    "Deploy",
+			footer:   "ops",
+			color:    0x2ecc71,
+			showCwd:  true,
+			showHost: true,
+		},
+		{name: "Hidden fields", config: &Config{Embed: EmbedDefaults{ShowCwd: &hidden, ShowHost: &hidden}}},
+		{name: "Explicitly shown", config: &Config{Embed: EmbedDefaults{ShowCwd: &shown, ShowHost: &shown}}, showCwd: true, showHost: true},
+		{name: "hide_host wins over show_host", config: &Config{HideHost: true, Embed: EmbedDefaults{ShowHost: &shown}}, showCwd: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := tt.config
+			if c.Title() != tt.title || c.Footer() != tt.footer || c.Color() != tt.color {
+				t.Errorf("Expected title %q, footer %q and color %#x, got %q, %q and %#x", tt.title, tt.footer, tt.color, c.Title(), c.Footer(), c.Color())
+			}
+			if c.ShowCwd() != tt.showCwd || c.ShowHost() != tt.showHost {
+				t.Errorf("Expected ShowCwd=%v and ShowHost=%v, got %v and %v", tt.showCwd, tt.showHost, c.ShowCwd(), c.ShowHost())
+			}
+		})
+	}
+}
+
+func TestDescribeEmbed(t *testing.T) {
+	hidden := false
+	got := describe(&Config{Embed: EmbedDefaults{
+		Title:   "Deploy",
+		Color:   "#ff0000",
+		ShowCwd: &hidden,
+		Fields:  []string{"Environment=staging", "Team=infra"},
+	}})
+	for _, want := range []string{"Embed:\n", "Title: Deploy\n", "Color: #ff0000\n", "Working directory field: hidden\n", "Fields: Environment=staging, Team=infra\n"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in output:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, "Host field") || strings.Contains(got, "Footer") {
+		t.Errorf("Expected only the keys set, got:\n%s", got)
+	}
+
+	if got := describe(&Config{}); strings.Contains(got, "Embed") {
+		t.Errorf("Expected no embed section, got:\n%s", got)
+	}
+}
+
+func TestSetEmbed(t *testing.T) {
+	var c Config
+	if err := c.Set("embed", `{"title": "Deploy", "fields": ["Environment=staging"]}`); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c.Embed.Title != "Deploy" || len(c.Embed.Fields) != 1 {
+		t.Errorf("Expected the embed section to be set, got %+v", c.Embed)
+	}
+	if err := c.Set("embed", `{"titel": "Deploy"}`); err == nil || !strings.Contains(err.Error(), `unknown key "titel"`) {
+		t.Errorf("Expected an unknown key error, got %v", err)
+	}
+	if err := c.Unset("embed"); err != nil || c.Embed.Title != "" {
+		t.Errorf("Expected the embed section to be cleared, got %v %+v", err, c.Embed)
+	}
+}
@@ -0,0 +1,120 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables that override config keys, as
+// in OWATA_WEBHOOK_URL for webhook_url
+const EnvPrefix = "OWATA_"
+
+// EnvSource sets the notification source when --source isn't given. It has
+// no config key, since the source usually describes where owata runs.
+const EnvSource = EnvPrefix + "SOURCE"
+
+// EnvVar returns the environment variable that overrides key
+func EnvVar(key string) string {
+	return EnvPrefix + strings.ToUpper(key)
+}
+
+// EnvOverrides returns the environment variables that are set (and not
+// empty) among those owata reads, in the order of the config keys
+func EnvOverrides() []string {
+	var names []string
+	for _, name := range append(envVars(), EnvSource) {
+		if os.Getenv(name) != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+func envVars() []string {
+	keys := append(Keys(), aliases()...)
+	names := make([]string, len(keys))
+	for i, key := range keys {
+		names[i] = EnvVar(key)
+	}
+	return names
+}
+
+// applyEnv overrides c with the OWATA_ environment variables that are set.
+// An empty variable is ignored, as CI systems often set one for a missing
+// secret.
+func (c *Config) applyEnv() error {
+	for _, key := range append(Keys(), aliases()...) {
+		name := EnvVar(key)
+		value := os.Getenv(name)
+		if value == "" {
+			continue
+		}
+		if key != canonicalKey(key) && os.Getenv(EnvVar(canonicalKey(key))) != "" {
+			// The variable named after the key itself wins over its alias
+			continue
+		}
+		if err := c.Set(key, value); err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		c.fromEnv = append(c.fromEnv, name)
+		c.setOrigin(OriginEnv, canonicalKey(key))
+	}
+	if source := os.Getenv(EnvSource); source != "" {
+		c.source = source
+		c.fromEnv = append(c.fromEnv, EnvSource)
+	}
+	return nil
+}
+
+// FromEnv returns the environment variables c took values from
+func (c *Config) FromEnv() []string {
+	return c.fromEnv
+}
+
+// Source returns the notification source from OWATA_SOURCE, if set
+func (c *Config) Source() string {
+	return c.source
+}
+
+// Resolve returns the config to use: the global config with the local one's
+// values over it (see readResolved), the selected profile applied, and
+// OWATA_ environment variables overriding its values, with the webhook URL
+// from webhook_url_command or webhook_url_file if either is set.
+// Command line flags, which the caller applies, override all of them. Without
+// a config file the environment alone is used, so ErrConfigFileNotFound is
+// only returned when neither has anything, or when a file named with --config
+// is missing.
+func (m *Manager) Resolve(preferGlobal bool) (*Config, string, error) {
+	config, configPath, err := m.readResolved(preferGlobal)
+	missing := errors.Is(err, ErrConfigFileNotFound)
+	if err != nil && !missing {
+		return nil, configPath, err
+	}
+	if missing && (m.configPath != "" || len(EnvOverrides()) == 0) {
+		return nil, configPath, err
+	}
+	if missing {
+		config, configPath = &Config{}, ""
+	}
+
+	// Check the profiles before one is applied over the rest
+	if err := config.Validate(); err != nil {
+		return nil, configPath, err
+	}
+	if config, err = config.WithProfile(m.profileName(config)); err != nil {
+		return nil, configPath, err
+	}
+	if err := config.applyEnv(); err != nil {
+		return nil, configPath, invalid(err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, configPath, err
+	}
+	if err := m.fetchWebhookURL(config); err != nil {
+		return nil, configPath, err
+	}
+	m.debug("resolved config", "path", configPath, "profile", config.Profile(), "env", config.FromEnv())
+	return config, configPath, nil
+}
@@ -0,0 +1,208 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestEnvVar(t *testing.T) {
+	if got := EnvVar("webhook_url"); got != "OWATA_WEBHOOK_URL" {
+		t.Errorf("Expected OWATA_WEBHOOK_URL, got %s", got)
+	}
+}
+
+// TestResolvePrecedence checks every combination of local config, global
+// config and environment: the environment wins, then the local config, then
+// the global one
+func TestResolvePrecedence(t *testing.T) {
+	for combo := range 8 {
+		hasLocal, hasGlobal, hasEnv := combo&1 != 0, combo&2 != 0, combo&4 != 0
+		name := fmt.Sprintf("local=%v global=%v env=%v", hasLocal, hasGlobal, hasEnv)
+
+		t.Run(name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			SetTestConfigDir(t.TempDir())
+			defer ResetTestConfigDir()
+			currentDir, _ := os.Getwd()
+			os.Chdir(tempDir)
+			defer os.Chdir(currentDir)
+			t.Setenv("OWATA_USERNAME", "")
+
+			manager := NewManager()
+			want := ""
+			if hasGlobal {
+				manager.Save(&Config{Username: "Global"}, true)
+				want = "Global"
+			}
+			if hasLocal {
+				manager.Save(&Config{Username: "Local"}, false)
+				want = "Local"
+			}
+			if hasEnv {
+				t.Setenv("OWATA_USERNAME", "Env")
+				want = "Env"
+			}
+
+			config, _, err := manager.Resolve(false)
+			if want == "" {
+				if !errors.Is(err, ErrConfigFileNotFound) {
+					t.Errorf("Expected ErrConfigFileNotFound with nothing set, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.Username != want {
+				t.Errorf("Expected username %q, got %q", want, config.Username)
+			}
+			if fromEnv := slices.Contains(config.FromEnv(), "OWATA_USERNAME"); fromEnv != hasEnv {
+				t.Errorf("Expected OWATA_USERNAME in FromEnv to be %v, got %v", hasEnv, config.FromEnv())
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	manager := NewManager()
+	manager.Save(&Config{WebhookURL: "https://discord.com/api/webhooks/1/file", Username: "File", Retries: 2}, false)
+
+	t.Run("Overrides only what is set", func(t *testing.T) {
+		t.Setenv("OWATA_WEBHOOK_URL", "https://discord.com/api/webhooks/2/env")
+		t.Setenv("OWATA_USERNAME", "")
+		t.Setenv("OWATA_NTFY_TAGS", "ci,nightly")
+		t.Setenv("OWATA_SOURCE", "github-actions")
+
+		config, path, err := manager.Resolve(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if path != ConfigFileName {
+			t.Errorf("Expected the local config path, got %s", path)
+		}
+		if config.WebhookURL != "https://discord.com/api/webhooks/2/env" || config.Username != "File" || config.Retries != 2 {
+			t.Errorf("Expected the webhook from the environment and the rest from the file, got %+v", config)
+		}
+		if !slices.Equal(config.NtfyTags, []string{"ci", "nightly"}) {
+			t.Errorf("Expected ntfy_tags from the environment, got %v", config.NtfyTags)
+		}
+		if config.Source() != "github-actions" {
+			t.Errorf("Expected the source from OWATA_SOURCE, got %q", config.Source())
+		}
+		want := []string{"OWATA_WEBHOOK_URL", "OWATA_NTFY_TAGS", "OWATA_SOURCE"}
+		if !slices.Equal(config.FromEnv(), want) {
+			t.Errorf("Expected %v from the environment, got %v", want, config.FromEnv())
+		}
+	})
+
+	t.Run("Invalid value names the variable", func(t *testing.T) {
+		t.Setenv("OWATA_RETRIES", "many")
+		if _, _, err := manager.Resolve(false); err == nil || !strings.Contains(err.Error(), "OWATA_RETRIES") || !errors.Is(err, ErrInvalidConfig) {
+			t.Errorf("Expected an invalid config error naming OWATA_RETRIES, got %v", err)
+		}
+	})
+
+	t.Run("Value checked like the file", func(t *testing.T) {
+		t.Setenv("OWATA_NOTIFY_ON", "sometimes")
+		if _, _, err := manager.Resolve(false); err == nil || !strings.Contains(err.Error(), "invalid notify_on") {
+			t.Errorf("Expected an invalid notify_on error, got %v", err)
+		}
+	})
+
+	t.Run("Environment alone", func(t *testing.T) {
+		os.Chdir(t.TempDir())
+		defer os.Chdir(tempDir)
+		t.Setenv("OWATA_WEBHOOK_URL", "https://discord.com/api/webhooks/2/env")
+
+		config, path, err := manager.Resolve(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if path != "" || config.WebhookURL != "https://discord.com/api/webhooks/2/env" {
+			t.Errorf("Expected the environment without a path, got %q from %q", config.WebhookURL, path)
+		}
+	})
+
+	t.Run("Missing --config file", func(t *testing.T) {
+		t.Setenv("OWATA_WEBHOOK_URL", "https://discord.com/api/webhooks/2/env")
+		if _, _, err := NewManagerForFile("missing.json").Resolve(false); !errors.Is(err, ErrConfigFileNotFound) {
+			t.Errorf("Expected ErrConfigFileNotFound, got %v", err)
+		}
+	})
+
+	t.Run("Load ignores the environment", func(t *testing.T) {
+		t.Setenv("OWATA_USERNAME", "Env")
+		config, _, err := manager.Load(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Username != "File" || len(config.FromEnv()) != 0 {
+			t.Errorf("Expected the file as is, got %+v", config)
+		}
+	})
+}
+
+func TestDisplayConfigEnv(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	manager := NewManager()
+	t.Setenv("OWATA_USERNAME", "EnvBot")
+
+	output, err := manager.DisplayConfig(ConfigFileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"environment only", "Username: EnvBot", "From the environment (overriding the file): OWATA_USERNAME"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+
+	manager.Save(&Config{Username: "FileBot"}, false)
+	output, err = manager.DisplayConfig(ConfigFileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if strings.Contains(output, "environment only") || !strings.Contains(output, "Username: EnvBot") {
+		t.Errorf("Expected the file with the environment's username, got:\n%s", output)
+	}
+}
+
+func TestApplyEnvAlias(t *testing.T) {
+	t.Setenv("OWATA_USE_WEBHOOK_DEFAULTS", "")
+	t.Setenv("OWATA_USE_WEBHOOK_IDENTITY", "true")
+
+	var c Config
+	if err := c.applyEnv(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !c.UseWebhookDefaults || c.origins["use_webhook_defaults"] != OriginEnv {
+		t.Errorf("Expected use_webhook_defaults from the environment, got %v %v", c.UseWebhookDefaults, c.origins)
+	}
+	if !slices.Contains(EnvOverrides(), "OWATA_USE_WEBHOOK_IDENTITY") {
+		t.Errorf("Expected the alias among the overrides, got %v", EnvOverrides())
+	}
+
+	// The variable named after the key itself wins
+	t.Setenv("OWATA_USE_WEBHOOK_DEFAULTS", "false")
+	c = Config{}
+	if err := c.applyEnv(); err != nil || c.UseWebhookDefaults {
+		t.Errorf("Expected OWATA_USE_WEBHOOK_DEFAULTS to win, got %v %v", c.UseWebhookDefaults, err)
+	}
+}
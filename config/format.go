@@ -0,0 +1,176 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config file formats, told apart by the file's extension
+const (
+	FormatJSON = "json"
+	FormatTOML = "toml"
+)
+
+// Formats lists the config file formats, the default first
+var Formats = []string{FormatJSON, FormatTOML}
+
+// FormatOf returns the format of the config file at path: TOML for a .toml
+// extension, otherwise JSON
+func FormatOf(path string) string {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return FormatTOML
+	}
+	return FormatJSON
+}
+
+// templates are the contents of a new config file, by format. TOML can carry
+// comments, so its template explains the keys.
+var templates = map[string]string{
+	FormatJSON: `{
+  "webhook_url": "",
+  "username": "",
+  "avatar_url": ""
+}`,
+	FormatTOML: `# owata configuration. Every key is described in the README at
+# https://github.com/yashikota/owata
+# Saving with "owata config --webhook=..." and the like rewrites this file
+# without these comments.
+
+# Discord webhook URL, from Server Settings > Integrations > Webhooks
+webhook_url = ""
+
+# Name and avatar image URL notifications are posted as (default: Owata)
+username = ""
+avatar_url = ""
+`,
+}
+
+// decodeConfig parses a config file's contents. TOML is read into plain
+// values and passed on as JSON, so both formats use the same keys.
+func decodeConfig(data []byte, format string) (*Config, error) {
+	syntaxErr := ErrInvalidJSON
+	if format == FormatTOML {
+		syntaxErr = ErrInvalidTOML
+		var values map[string]any
+		if err := toml.Unmarshal(data, &values); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w: %v", syntaxErr, err)
+		}
+		var err error
+		if data, err = json.Marshal(values); err != nil {
+			return nil, fmt.Errorf("failed to parse config file: %w: %v", syntaxErr, err)
+		}
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w: %v", syntaxErr, err)
+	}
+	if err := readAliases(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w: %v", syntaxErr, err)
+	}
+	return &config, nil
+}
+
+// readAliases sets each key data gives under an alias in keyAliases, in c
+// and in each of its profiles, unless the key itself is given too
+func readAliases(data []byte, c *Config) error {
+	var keys map[string]json.RawMessage
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return err
+	}
+	if err := c.setAliases(keys); err != nil {
+		return err
+	}
+
+	var profiles map[string]map[string]json.RawMessage
+	if raw, ok := keys["profiles"]; ok {
+		if err := json.Unmarshal(raw, &profiles); err != nil {
+			return err
+		}
+	}
+	for name, keys := range profiles {
+		profile := c.Profiles[name]
+		if err := profile.setAliases(keys); err != nil {
+			return fmt.Errorf("profile %s: %v", name, err)
+		}
+		c.Profiles[name] = profile
+	}
+	return nil
+}
+
+func (c *Config) setAliases(keys map[string]json.RawMessage) error {
+	for _, alias := range aliases() {
+		value, ok := keys[alias]
+		if _, given := keys[canonicalKey(alias)]; !ok || given {
+			continue
+		}
+		f, _ := c.field(alias)
+		if err := json.Unmarshal(value, f.Addr().Interface()); err != nil {
+			return fmt.Errorf("%s: %v", alias, err)
+		}
+	}
+	return nil
+}
+
+// encodeConfig renders config in format. Keys come out in the JSON field
+// order, or sorted for TOML.
+func encodeConfig(config *Config, format string) ([]byte, error) {
+	if format != FormatTOML {
+		return json.MarshalIndent(config, "", "  ")
+	}
+
+	data, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+	// json.Number keeps integers from becoming floats such as 3.0
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var values map[string]any
+	if err := decoder.Decode(&values); err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	encoder := toml.NewEncoder(&b)
+	encoder.Indent = ""
+	if err := encoder.Encode(values); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+// pickFormat returns whichever of the config file at jsonPath and its .toml
+// sibling exists, or when neither does, the one in the format new files are
+// created in. Both existing is an error rather than a guess at which one is
+// meant.
+func (m *Manager) pickFormat(jsonPath string) (string, error) {
+	tomlPath := strings.TrimSuffix(jsonPath, filepath.Ext(jsonPath)) + ".toml"
+	jsonExists, err := fileExists(jsonPath)
+	if err != nil {
+		return "", fmt.Errorf("error checking config file: %w", err)
+	}
+	tomlExists, err := fileExists(tomlPath)
+	if err != nil {
+		return "", fmt.Errorf("error checking config file: %w", err)
+	}
+
+	switch {
+	case jsonExists && tomlExists:
+		return "", fmt.Errorf("%w: both %s and %s exist; keep one of them", ErrConfigConflict, jsonPath, tomlPath)
+	case tomlExists, !jsonExists && m.format == FormatTOML:
+		return tomlPath, nil
+	}
+	return jsonPath, nil
+}
+
+// SetFormat sets the format a new config file is created in: FormatJSON,
+// the default, or FormatTOML. An existing file keeps its own format.
+func (m *Manager) SetFormat(format string) {
+	m.format = format
+}
@@ -0,0 +1,207 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestFormatOf(t *testing.T) {
+	tests := []struct {
+		path     string
+		expected string
+	}{
+		{"owata-config.json", FormatJSON},
+		{"/etc/owata/prod.toml", FormatTOML},
+		{"PROD.TOML", FormatTOML},
+		{"owata.conf", FormatJSON},
+	}
+
+	for _, tt := range tests {
+		if got := FormatOf(tt.path); got != tt.expected {
+			t.Errorf("Expected %s for %s, got %s", tt.expected, tt.path, got)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "prod.toml")
+	data := `# Deploy notifications
+webhook_url = "https://discord.com/api/webhooks/1/token"
+username = "Deploy Bot"
+retries = 3
+rate_limit_retries = 0
+webhook_urls = ["https://discord.com/api/webhooks/2/mirror"]
+
+[targets]
+ops = "https://discord.com/api/webhooks/3/ops"
+
+[profiles.work]
+username = "Work Bot"
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := NewManager().LoadFromPath(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.WebhookURL != "https://discord.com/api/webhooks/1/token" || cfg.Username != "Deploy Bot" || cfg.Retries != 3 {
+		t.Errorf("Unexpected config: %+v", cfg)
+	}
+	if cfg.RateLimitRetries == nil || *cfg.RateLimitRetries != 0 {
+		t.Errorf("Expected rate_limit_retries 0 to be kept, got %v", cfg.RateLimitRetries)
+	}
+	if len(cfg.WebhookURLs) != 1 || cfg.Targets["ops"] != "https://discord.com/api/webhooks/3/ops" || cfg.Profiles["work"].Username != "Work Bot" {
+		t.Errorf("Unexpected lists and tables: %+v", cfg)
+	}
+}
+
+func TestLoadInvalidTOML(t *testing.T) {
+	tests := []struct {
+		name     string
+		data     string
+		expected error
+	}{
+		{"Syntax", "webhook_url = \n", ErrInvalidTOML},
+		{"Wrong type", "retries = \"three\"\n", ErrInvalidTOML},
+		{"Invalid value", "notify_on = \"sometimes\"\n", ErrInvalidConfig},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "owata-config.toml")
+			os.WriteFile(path, []byte(tt.data), 0644)
+
+			_, err := NewManager().LoadFromPath(path)
+			if !errors.Is(err, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, err)
+			}
+			if errors.Is(err, ErrInvalidJSON) {
+				t.Errorf("Expected a TOML file not to be reported as invalid JSON, got %v", err)
+			}
+		})
+	}
+}
+
+func TestSaveTOMLRoundTrip(t *testing.T) {
+	retries := 2
+	original := &Config{
+		WebhookURL:       "https://discord.com/api/webhooks/1/token",
+		Username:         "Bot",
+		NtfyTags:         []string{"rocket", "warning"},
+		GenericHeaders:   map[string]string{"Authorization": "Bearer x"},
+		Retries:          3,
+		RateLimitRetries: &retries,
+		Git:              true,
+		Profiles:         map[string]Config{"work": {WebhookURL: "https://discord.com/api/webhooks/2/work"}},
+	}
+
+	path := filepath.Join(t.TempDir(), "owata-config.toml")
+	manager := NewManagerForFile(path)
+	if err := manager.SaveToPath(original, path); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "retries = 3\n") || strings.HasPrefix(string(data), "{") {
+		t.Errorf("Expected TOML with integers as written, got:\n%s", data)
+	}
+
+	loaded, err := manager.LoadFromPath(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(loaded, original) {
+		t.Errorf("Expected %+v after the round trip, got %+v", original, loaded)
+	}
+}
+
+func TestConfigFormatResolution(t *testing.T) {
+	write := func(t *testing.T, path, data string) {
+		t.Helper()
+		if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name         string
+		json, toml   bool
+		format       string
+		expectedPath string
+		expectedErr  error
+	}{
+		{name: "JSON only", json: true, expectedPath: "owata-config.json"},
+		{name: "TOML only", toml: true, expectedPath: "owata-config.toml"},
+		{name: "Both", json: true, toml: true, expectedErr: ErrConfigConflict},
+		{name: "Neither defaults to JSON", expectedPath: "owata-config.json"},
+		{name: "Neither with TOML format", format: FormatTOML, expectedPath: "owata-config.toml"},
+		{name: "Existing JSON wins over TOML format", json: true, format: FormatTOML, expectedPath: "owata-config.json"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(t.TempDir())
+			SetTestConfigDir(t.TempDir())
+			defer ResetTestConfigDir()
+
+			if tt.json {
+				write(t, "owata-config.json", `{"username": "json"}`)
+			}
+			if tt.toml {
+				write(t, "owata-config.toml", `username = "toml"`)
+			}
+
+			manager := NewManager()
+			manager.SetFormat(tt.format)
+			path, err := manager.GetPathWithError(false)
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if path != tt.expectedPath {
+				t.Errorf("Expected %q, got %q", tt.expectedPath, path)
+			}
+			if _, _, err := manager.Load(false); tt.expectedErr != nil && !errors.Is(err, tt.expectedErr) {
+				t.Errorf("Expected Load to fail with %v, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestCreateTOMLTemplate(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(t.TempDir())
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+
+	manager := NewManager()
+	manager.SetFormat(FormatTOML)
+	path, created, err := manager.CreateTemplate(false)
+	if err != nil || !created || path != "owata-config.toml" {
+		t.Fatalf("Expected owata-config.toml to be created, got %q, %v, %v", path, created, err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(data), "# owata configuration") {
+		t.Errorf("Expected a commented template, got:\n%s", data)
+	}
+
+	// Saving keeps the format of the file it replaces
+	saved, err := manager.Update(false, func(c *Config) error {
+		c.Username = "Bot"
+		return nil
+	})
+	if err != nil || saved != "owata-config.toml" {
+		t.Fatalf("Expected the TOML file to be updated, got %q, %v", saved, err)
+	}
+	if cfg, _, err := NewManager().Load(false); err != nil || cfg.Username != "Bot" {
+		t.Errorf("Expected the update to load back, got %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,231 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// secretKeys hold webhook URLs, tokens or headers that may carry credentials,
+// or profiles that may hold those
+var secretKeys = []string{
+	"webhook_url", "webhook_urls", "targets", "fallback_chain",
+	"ntfy_token", "telegram_token", "generic_headers", "profiles",
+}
+
+// keyAliases are other names for config keys, accepted in config files,
+// config get, set and unset and OWATA_ variables. The config is always saved
+// under the key itself.
+var keyAliases = map[string]string{
+	"use_webhook_identity": "use_webhook_defaults",
+}
+
+// aliases returns the names in keyAliases, sorted
+func aliases() []string {
+	return slices.Sorted(maps.Keys(keyAliases))
+}
+
+// canonicalKey returns the key an alias stands for, or key itself
+func canonicalKey(key string) string {
+	if name, ok := keyAliases[key]; ok {
+		return name
+	}
+	return key
+}
+
+// Keys returns the config keys as written in the file, in the order of the
+// Config struct
+func Keys() []string {
+	t := reflect.TypeFor[Config]()
+	keys := make([]string, 0, t.NumField())
+	for i := range t.NumField() {
+		if t.Field(i).IsExported() {
+			keys = append(keys, jsonKey(t.Field(i)))
+		}
+	}
+	return keys
+}
+
+// IsSecret reports whether the value of key should be masked when shown
+func IsSecret(key string) bool {
+	return slices.Contains(secretKeys, key)
+}
+
+func jsonKey(f reflect.StructField) string {
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	return name
+}
+
+// field returns the settable field of c for key
+func (c *Config) field(key string) (reflect.Value, error) {
+	key = canonicalKey(key)
+	v := reflect.ValueOf(c).Elem()
+	for i := range v.NumField() {
+		if f := v.Type().Field(i); f.IsExported() && jsonKey(f) == key {
+			return v.Field(i), nil
+		}
+	}
+	return reflect.Value{}, fmt.Errorf("unknown config key: %s (valid keys: %s)", key, strings.Join(Keys(), ", "))
+}
+
+// masked returns a copy of c with each URL or token in its secret keys,
+// including those of its profiles, passed through mask
+func (c *Config) masked(mask func(string) string) *Config {
+	copied := *c
+	for _, key := range secretKeys {
+		f, _ := copied.field(key)
+		switch v := f.Interface().(type) {
+		case string:
+			if v != "" {
+				f.SetString(mask(v))
+			}
+		case []string:
+			if len(v) > 0 {
+				items := make([]string, len(v))
+				for i, item := range v {
+					items[i] = mask(item)
+				}
+				f.Set(reflect.ValueOf(items))
+			}
+		case map[string]string:
+			if len(v) > 0 {
+				masked := make(map[string]string, len(v))
+				for name, value := range v {
+					masked[name] = mask(value)
+				}
+				f.Set(reflect.ValueOf(masked))
+			}
+		case map[string]Config:
+			if len(v) > 0 {
+				profiles := make(map[string]Config, len(v))
+				for name, profile := range v {
+					profiles[name] = *profile.masked(mask)
+				}
+				f.Set(reflect.ValueOf(profiles))
+			}
+		}
+	}
+	return &copied
+}
+
+// Get returns the value of key as text: lists one item per line, maps as a
+// JSON object, and an empty string when unset. For secret keys, each URL or
+// token is passed through mask unless mask is nil.
+func (c *Config) Get(key string, mask func(string) string) (string, error) {
+	if _, err := c.field(key); err != nil {
+		return "", err
+	}
+	if mask != nil && IsSecret(key) {
+		c = c.masked(mask)
+	}
+	f, _ := c.field(key)
+
+	switch v := f.Interface().(type) {
+	case string:
+		return v, nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case int:
+		return strconv.Itoa(v), nil
+	case *int:
+		if v == nil {
+			return "", nil
+		}
+		return strconv.Itoa(*v), nil
+	case []string:
+		return strings.Join(v, "\n"), nil
+	case EmbedDefaults:
+		if f.IsZero() {
+			return "", nil
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal %s: %v", key, err)
+		}
+		return string(data), nil
+	case map[string]string, map[string]Config:
+		if f.Len() == 0 {
+			return "", nil
+		}
+		data, err := json.Marshal(v)
+		if err != nil {
+			return "", fmt.Errorf("failed to marshal %s: %v", key, err)
+		}
+		return string(data), nil
+	default:
+		return "", fmt.Errorf("config key %s has an unsupported type %s", key, f.Type())
+	}
+}
+
+// Set parses value for key's type and sets it: true or false for switches, a
+// JSON array or comma-separated items for lists, and a JSON object for maps
+func (c *Config) Set(key, value string) error {
+	f, err := c.field(key)
+	if err != nil {
+		return err
+	}
+
+	switch f.Interface().(type) {
+	case string:
+		f.SetString(value)
+	case bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid value for %s: %s (use true or false)", key, value)
+		}
+		f.SetBool(b)
+	case int, *int:
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return fmt.Errorf("invalid value for %s: %s (must be a non-negative number)", key, value)
+		}
+		if f.Kind() == reflect.Pointer {
+			f.Set(reflect.ValueOf(&n))
+		} else {
+			f.SetInt(int64(n))
+		}
+	case []string:
+		var items []string
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			if err := json.Unmarshal([]byte(value), &items); err != nil {
+				return fmt.Errorf("invalid value for %s: %v (use a JSON array of strings or comma-separated items)", key, err)
+			}
+		} else {
+			for item := range strings.SplitSeq(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		f.Set(reflect.ValueOf(items))
+	case map[string]string, map[string]Config:
+		m := reflect.New(f.Type())
+		if err := json.Unmarshal([]byte(value), m.Interface()); err != nil {
+			return fmt.Errorf("invalid value for %s: %v (use a JSON object such as {\"name\": \"value\"})", key, err)
+		}
+		f.Set(m.Elem())
+	case EmbedDefaults:
+		var embed EmbedDefaults
+		if err := json.Unmarshal([]byte(value), &embed); err != nil {
+			return fmt.Errorf("invalid value for %s: %v (use a JSON object such as {\"title\": \"Deploy\"})", key, err)
+		}
+		f.Set(reflect.ValueOf(embed))
+	default:
+		return fmt.Errorf("config key %s has an unsupported type %s", key, f.Type())
+	}
+	return nil
+}
+
+// Unset clears key, so it's written as empty or left out of the file
+func (c *Config) Unset(key string) error {
+	f, err := c.field(key)
+	if err != nil {
+		return err
+	}
+	f.SetZero()
+	return nil
+}
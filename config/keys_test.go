@@ -0,0 +1,162 @@
+package config
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestKeys(t *testing.T) {
+	keys := Keys()
+	for _, want := range []string{"webhook_url", "username", "avatar_url", "targets", "rate_limit_retries", "use_webhook_defaults"} {
+		if !slices.Contains(keys, want) {
+			t.Errorf("Expected key %q in %v", want, keys)
+		}
+	}
+	if keys[0] != "webhook_url" {
+		t.Errorf("Expected keys in file order, got %v first", keys[0])
+	}
+	for _, key := range secretKeys {
+		if !slices.Contains(keys, key) {
+			t.Errorf("Secret key %q is not a config key", key)
+		}
+	}
+}
+
+func TestSetGet(t *testing.T) {
+	tests := []struct {
+		name    string
+		key     string
+		value   string
+		want    string
+		wantErr string
+	}{
+		{name: "String", key: "username", value: "Bot", want: "Bot"},
+		{name: "Bool", key: "git", value: "true", want: "true"},
+		{name: "Invalid bool", key: "git", value: "yes please", wantErr: "use true or false"},
+		{name: "Int", key: "retries", value: "3", want: "3"},
+		{name: "Pointer int", key: "rate_limit_retries", value: "0", want: "0"},
+		{name: "Negative int", key: "retries", value: "-1", wantErr: "non-negative"},
+		{name: "Comma-separated list", key: "ntfy_tags", value: "ci, backup,", want: "ci\nbackup"},
+		{name: "JSON list", key: "ntfy_tags", value: `["a,b", "c"]`, want: "a,b\nc"},
+		{name: "Map", key: "targets", value: `{"ops": "https://example.com/ops"}`, want: `{"ops":"https://example.com/ops"}`},
+		{name: "Invalid map", key: "targets", value: "ops=https://example.com", wantErr: "use a JSON object"},
+		{name: "Unknown key", key: "webhook", value: "x", wantErr: "valid keys: webhook_url, username"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var c Config
+			err := c.Set(tt.key, tt.value)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got, err := c.Get(tt.key, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestGetMasked(t *testing.T) {
+	c := Config{
+		WebhookURL: "https://discord.com/api/webhooks/1/secret",
+		Username:   "Bot",
+		Targets:    map[string]string{"ops": "https://example.com/ops"},
+	}
+	mask := func(string) string { return "****" }
+
+	if got, _ := c.Get("webhook_url", mask); got != "****" {
+		t.Errorf("Expected the webhook URL masked, got %q", got)
+	}
+	if got, _ := c.Get("targets", mask); got != `{"ops":"****"}` {
+		t.Errorf("Expected target URLs masked, got %q", got)
+	}
+	if got, _ := c.Get("username", mask); got != "Bot" {
+		t.Errorf("Expected username unmasked, got %q", got)
+	}
+	if got, _ := c.Get("webhook_url", nil); got != c.WebhookURL {
+		t.Errorf("Expected the webhook URL revealed without a mask, got %q", got)
+	}
+	if got, _ := c.Get("ntfy_token", mask); got != "" {
+		t.Errorf("Expected an unset token to stay empty, got %q", got)
+	}
+}
+
+func TestUnset(t *testing.T) {
+	n := 2
+	c := Config{Username: "Bot", RateLimitRetries: &n, NtfyTags: []string{"ci"}, Git: true}
+	for _, key := range []string{"username", "rate_limit_retries", "ntfy_tags", "git"} {
+		if err := c.Unset(key); err != nil {
+			t.Fatalf("Unexpected error for %s: %v", key, err)
+		}
+	}
+	if c.Username != "" || c.RateLimitRetries != nil || c.NtfyTags != nil || c.Git {
+		t.Errorf("Expected every key cleared, got %+v", c)
+	}
+	if err := c.Unset("nope"); err == nil {
+		t.Error("Expected an error for an unknown key")
+	}
+}
+
+func TestKeyAliases(t *testing.T) {
+	if slices.Contains(Keys(), "use_webhook_identity") {
+		t.Error("Expected aliases not to be listed as keys")
+	}
+
+	decodeTests := []struct {
+		name     string
+		data     string
+		format   string
+		expected bool
+		profile  bool
+	}{
+		{name: "JSON", data: `{"use_webhook_identity": true}`, format: FormatJSON, expected: true},
+		{name: "TOML", data: "use_webhook_identity = true\n", format: FormatTOML, expected: true},
+		{name: "Key itself wins", data: `{"use_webhook_identity": true, "use_webhook_defaults": false}`, format: FormatJSON},
+		{name: "In a profile", data: `{"profiles": {"work": {"use_webhook_identity": true}}}`, format: FormatJSON, profile: true},
+	}
+	for _, tt := range decodeTests {
+		t.Run(tt.name, func(t *testing.T) {
+			config, err := decodeConfig([]byte(tt.data), tt.format)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if tt.profile {
+				profile := config.Profiles["work"]
+				config = &profile
+				tt.expected = true
+			}
+			if config.UseWebhookDefaults != tt.expected {
+				t.Errorf("Expected use_webhook_defaults=%v, got %v", tt.expected, config.UseWebhookDefaults)
+			}
+		})
+	}
+
+	if _, err := decodeConfig([]byte(`{"use_webhook_identity": "yes"}`), FormatJSON); !errors.Is(err, ErrInvalidJSON) {
+		t.Errorf("Expected %v for a value of the wrong type, got %v", ErrInvalidJSON, err)
+	}
+
+	var c Config
+	if err := c.Set("use_webhook_identity", "true"); err != nil || !c.UseWebhookDefaults {
+		t.Errorf("Expected set to go to use_webhook_defaults, got %v %v", err, c.UseWebhookDefaults)
+	}
+	if got, _ := c.Get("use_webhook_identity", nil); got != "true" {
+		t.Errorf("Expected get to read use_webhook_defaults, got %q", got)
+	}
+	data, _ := encodeConfig(&c, FormatJSON)
+	if !strings.Contains(string(data), `"use_webhook_defaults": true`) || strings.Contains(string(data), "use_webhook_identity") {
+		t.Errorf("Expected the config saved under the key itself, got %s", data)
+	}
+}
@@ -0,0 +1,190 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// Layer is one place config values come from: a config file, the profile
+// applied or the OWATA_ environment variables
+type Layer struct {
+	Origin  string  `json:"origin"`            // global, local, profile or env
+	Name    string  `json:"name,omitempty"`    // The file, profile or variables
+	Missing bool    `json:"missing,omitempty"` // The file doesn't exist
+	Config  *Config `json:"config"`            // The values this layer sets
+}
+
+// Layers are the layers of the config, the lowest precedence first, side by
+// side with the values notifications use, as owata config --all shows them
+type Layers struct {
+	Layers    []Layer `json:"layers"`
+	Effective *View   `json:"effective"`
+}
+
+// ViewLayers gathers each layer Resolve reads and the config it resolves
+// to. Config files that don't exist are included with Missing set, so
+// they show as such rather than disappear.
+func (m *Manager) ViewLayers(preferGlobal bool) (*Layers, error) {
+	effective, err := m.ViewResolved(preferGlobal)
+	if errors.Is(err, ErrConfigFileNotFound) {
+		effective = newView(&Config{}, true)
+	} else if err != nil {
+		return nil, err
+	}
+
+	layers := []Layer{}
+	paths, err := m.layerPaths(preferGlobal)
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range paths {
+		config, err := readConfig(path)
+		missing := errors.Is(err, ErrConfigFileNotFound)
+		if missing {
+			config = &Config{}
+		} else if err != nil {
+			return nil, err
+		}
+		layers = append(layers, Layer{Origin: m.origin(path), Name: path, Missing: missing, Config: config})
+	}
+
+	if name := effective.Profile; name != "" {
+		profile := effective.Config.Profiles[name]
+		layers = append(layers, Layer{Origin: OriginProfile, Name: name, Config: &profile})
+	}
+
+	env := &Config{}
+	if err := env.applyEnv(); err != nil {
+		return nil, invalid(err)
+	}
+	if len(env.fromEnv) > 0 {
+		layers = append(layers, Layer{Origin: OriginEnv, Name: strings.Join(env.fromEnv, ", "), Config: env})
+	}
+
+	return &Layers{Layers: layers, Effective: effective}, nil
+}
+
+// layerPaths returns the config files Resolve reads, the global one first:
+// only one with preferGlobal or --config
+func (m *Manager) layerPaths(preferGlobal bool) ([]string, error) {
+	if m.configPath != "" {
+		return []string{m.configPath}, nil
+	}
+	globalPath, err := m.GetPathWithError(true)
+	if err != nil {
+		return nil, err
+	}
+	if preferGlobal {
+		return []string{globalPath}, nil
+	}
+	localPath, err := m.GetPathWithError(false)
+	if err != nil {
+		return nil, err
+	}
+	if localPath == globalPath {
+		return []string{localPath}, nil
+	}
+	return []string{globalPath, localPath}, nil
+}
+
+// maxCell is how many characters of a value the table shows, enough for a
+// masked Discord webhook URL
+const maxCell = 64
+
+// Table renders l for people: the layers, then a row for each key set in
+// any of them with its value in each layer and the effective value, marked
+// with where it came from. Each URL or token is passed through mask.
+func (l *Layers) Table(mask func(string) string) string {
+	var b strings.Builder
+	b.WriteString("\n📋 All configuration layers (lowest precedence first):\n")
+	for _, layer := range l.Layers {
+		name := layer.Name
+		if layer.Missing {
+			name += " (does not exist)"
+		}
+		fmt.Fprintf(&b, "  %-8s %s\n", layer.Origin+":", name)
+	}
+	if len(l.Layers) == 0 {
+		b.WriteString("  (no config files or environment variables)\n")
+	}
+
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	header := []string{"KEY"}
+	for _, layer := range l.Layers {
+		header = append(header, strings.ToUpper(layer.Origin))
+	}
+	header = append(header, "EFFECTIVE")
+
+	var rows [][]string
+	for _, key := range Keys() {
+		row := []string{key}
+		set := false
+		for _, layer := range l.Layers {
+			value := cell(layer.Config, key, mask)
+			set = set || value != "-"
+			row = append(row, value)
+		}
+		effective := cell(l.Effective.Config, key, mask)
+		if origin := l.Effective.Origins[key]; origin != "" && effective != "-" {
+			effective += " (" + origin + ")"
+		}
+		if !set && effective == "-" {
+			continue
+		}
+		rows = append(rows, append(row, effective))
+	}
+	if len(rows) == 0 {
+		b.WriteString("\n  No values set.\n")
+		return b.String()
+	}
+
+	b.WriteString("\n")
+	fmt.Fprintf(w, "  %s\n", strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintf(w, "  %s\n", strings.Join(row, "\t"))
+	}
+	w.Flush()
+	return b.String()
+}
+
+// cell returns the value of key in c for the table on one line, shortened
+// to maxCell characters, or "-" when unset. Profiles are listed by name.
+func cell(c *Config, key string, mask func(string) string) string {
+	if f, err := c.field(key); err != nil || f.IsZero() {
+		return "-"
+	}
+	value, _ := c.Get(key, mask)
+	if key == "profiles" {
+		value = strings.Join(c.ProfileNames(), ", ")
+	}
+	value = strings.ReplaceAll(value, "\n", ", ")
+	if runes := []rune(value); len(runes) > maxCell {
+		value = string(runes[:maxCell-1]) + "…"
+	}
+	return value
+}
+
+// JSON renders l for scripts, with each URL or token passed through mask
+// unless mask is nil
+func (l *Layers) JSON(mask func(string) string) ([]byte, error) {
+	masked := Layers{Layers: make([]Layer, len(l.Layers)), Effective: l.Effective}
+	for i, layer := range l.Layers {
+		if mask != nil {
+			layer.Config = layer.Config.masked(mask)
+		}
+		masked.Layers[i] = layer
+	}
+	if mask != nil {
+		effective := *l.Effective
+		effective.Config = l.Effective.Config.masked(mask)
+		masked.Effective = &effective
+	}
+	data, err := json.Marshal(masked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+	return data, nil
+}
@@ -0,0 +1,218 @@
+package config
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestViewLayers(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, string) {
+		currentDir, _ := os.Getwd()
+		t.Cleanup(func() { os.Chdir(currentDir) })
+		os.Chdir(t.TempDir())
+		SetTestConfigDir(t.TempDir())
+		t.Cleanup(ResetTestConfigDir)
+		for _, key := range []string{"webhook_url", "username", "avatar_url", "retries", "profile"} {
+			t.Setenv(EnvVar(key), "")
+		}
+		manager := NewManager()
+		globalPath, _ := manager.GetPathWithError(true)
+		return manager, globalPath
+	}
+	origins := func(layers *Layers) []string {
+		var origins []string
+		for _, layer := range layers.Layers {
+			origins = append(origins, layer.Origin)
+		}
+		return origins
+	}
+
+	t.Run("Every layer", func(t *testing.T) {
+		manager, globalPath := setup(t)
+		manager.Save(&Config{
+			Username: "Global",
+			Retries:  1,
+			Profiles: map[string]Config{"work": {AvatarURL: "https://example.com/work.png"}},
+		}, true)
+		manager.Save(&Config{Username: "Local"}, false)
+		manager.UseProfile("work")
+		t.Setenv("OWATA_RETRIES", "5")
+
+		layers, err := manager.ViewLayers(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := []string{OriginGlobal, OriginLocal, OriginProfile, OriginEnv}; !slices.Equal(origins(layers), want) {
+			t.Fatalf("Expected layers %q, got %q", want, origins(layers))
+		}
+		if layers.Layers[0].Name != globalPath || layers.Layers[0].Config.Username != "Global" {
+			t.Errorf("Expected the global config, got %+v", layers.Layers[0])
+		}
+		if layers.Layers[1].Config.Username != "Local" || layers.Layers[1].Config.Retries != 0 {
+			t.Errorf("Expected the local config alone, got %+v", layers.Layers[1].Config)
+		}
+		if layers.Layers[2].Name != "work" || layers.Layers[2].Config.AvatarURL != "https://example.com/work.png" {
+			t.Errorf("Expected the work profile, got %+v", layers.Layers[2])
+		}
+		if layers.Layers[3].Name != "OWATA_RETRIES" || layers.Layers[3].Config.Retries != 5 || layers.Layers[3].Config.Username != "" {
+			t.Errorf("Expected the environment alone, got %+v", layers.Layers[3])
+		}
+		if layers.Effective.Config.Username != "Local" || layers.Effective.Origins["retries"] != OriginEnv {
+			t.Errorf("Expected the effective config, got %+v", layers.Effective)
+		}
+	})
+
+	t.Run("Missing local config", func(t *testing.T) {
+		manager, _ := setup(t)
+		manager.Save(&Config{Username: "Global"}, true)
+
+		layers, err := manager.ViewLayers(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(layers.Layers) != 2 || !layers.Layers[1].Missing || layers.Layers[0].Missing {
+			t.Errorf("Expected the local config to be missing, got %+v", layers.Layers)
+		}
+	})
+
+	t.Run("Nothing set", func(t *testing.T) {
+		manager, _ := setup(t)
+		layers, err := manager.ViewLayers(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !slices.Equal(origins(layers), []string{OriginGlobal, OriginLocal}) || layers.Effective.Config.Username != "" {
+			t.Errorf("Expected both files missing, got %+v", layers)
+		}
+	})
+
+	t.Run("Global only", func(t *testing.T) {
+		manager, _ := setup(t)
+		manager.Save(&Config{Username: "Global"}, true)
+		manager.Save(&Config{Username: "Local"}, false)
+
+		layers, err := manager.ViewLayers(true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !slices.Equal(origins(layers), []string{OriginGlobal}) || layers.Effective.Config.Username != "Global" {
+			t.Errorf("Expected the global config alone, got %+v", layers)
+		}
+	})
+
+	t.Run("Config flag", func(t *testing.T) {
+		setup(t)
+		path := filepath.Join(t.TempDir(), "other.json")
+		os.WriteFile(path, []byte(`{"username": "Other"}`), 0600)
+
+		layers, err := NewManagerForFile(path).ViewLayers(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if len(layers.Layers) != 1 || layers.Layers[0].Name != path || layers.Layers[0].Origin != OriginLocal {
+			t.Errorf("Expected the --config file alone, got %+v", layers.Layers)
+		}
+	})
+}
+
+func TestLayersTable(t *testing.T) {
+	yes := true
+	layers := &Layers{
+		Layers: []Layer{
+			{Origin: OriginGlobal, Name: "global.json", Config: &Config{
+				WebhookURL: "https://discord.com/api/webhooks/1/secret",
+				Username:   "Global",
+				Profiles:   map[string]Config{"work": {}, "home": {}},
+			}},
+			{Origin: OriginLocal, Name: "owata-config.json", Missing: true, Config: &Config{}},
+			{Origin: OriginEnv, Name: "OWATA_USERNAME", Config: &Config{Username: "Env", DefaultTitle: strings.Repeat("x", 100)}},
+		},
+		Effective: &View{
+			Config: &Config{
+				WebhookURL:   "https://discord.com/api/webhooks/1/secret",
+				Username:     "Env",
+				DefaultTitle: strings.Repeat("x", 100),
+				Embed:        EmbedDefaults{ShowHost: &yes},
+			},
+			Origins: map[string]string{"webhook_url": OriginGlobal, "username": OriginEnv},
+		},
+	}
+	got := layers.Table(func(string) string { return "****" })
+
+	for _, want := range []string{
+		"global:  global.json\n",
+		"local:   owata-config.json (does not exist)\n",
+		"env:     OWATA_USERNAME\n",
+		"KEY ",
+		"GLOBAL ",
+		"EFFECTIVE\n",
+		"Env (env)\n",
+		"**** (global)\n",
+		"home, work",
+		strings.Repeat("x", maxCell-1) + "…",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("Expected %q in output:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"secret", "avatar_url", "hide_host"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("Expected no %q in output:\n%s", unwanted, got)
+		}
+	}
+
+	if got := (&Layers{Effective: newView(&Config{}, true)}).Table(nil); !strings.Contains(got, "No values set.") {
+		t.Errorf("Expected nothing to be set, got:\n%s", got)
+	}
+}
+
+func TestLayersJSON(t *testing.T) {
+	layers := &Layers{
+		Layers: []Layer{{Origin: OriginGlobal, Name: "global.json", Config: &Config{WebhookURL: "https://discord.com/api/webhooks/1/secret"}}},
+		Effective: newView(&Config{
+			WebhookURL: "https://discord.com/api/webhooks/1/secret",
+			origins:    map[string]string{"webhook_url": OriginGlobal},
+		}, true),
+	}
+
+	tests := []struct {
+		name        string
+		mask        func(string) string
+		expectedURL string
+	}{
+		{"Masked", func(string) string { return "****" }, "****"},
+		{"Revealed", nil, "https://discord.com/api/webhooks/1/secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := layers.JSON(tt.mask)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var got struct {
+				Layers    []Layer `json:"layers"`
+				Effective struct {
+					Config  Config            `json:"config"`
+					Origins map[string]string `json:"origins"`
+				} `json:"effective"`
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Expected JSON, got %s: %v", data, err)
+			}
+			if len(got.Layers) != 1 || got.Layers[0].Config.WebhookURL != tt.expectedURL || got.Effective.Config.WebhookURL != tt.expectedURL {
+				t.Errorf("Expected webhook URL %q everywhere, got %s", tt.expectedURL, data)
+			}
+			if got.Layers[0].Origin != OriginGlobal || got.Effective.Origins["webhook_url"] != OriginGlobal {
+				t.Errorf("Expected the origins, got %s", data)
+			}
+		})
+	}
+	if layers.Layers[0].Config.WebhookURL != "https://discord.com/api/webhooks/1/secret" || layers.Effective.Config.WebhookURL != "https://discord.com/api/webhooks/1/secret" {
+		t.Error("Expected masking to leave the layers untouched")
+	}
+}
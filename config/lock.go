@@ -0,0 +1,49 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/yashikota/owata/lockfile"
+)
+
+// ErrConfigLocked is returned when another owata holds the lock on a config
+// file for longer than lockfile.Timeout
+var ErrConfigLocked = errors.New("config is locked by another process")
+
+// withLock runs fn while holding an exclusive lock file next to the config
+// file at path, so two owata invocations editing the same config one after
+// the other can't lose either's changes
+func withLock(path string, fn func() error) error {
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create config directory: %w", err)
+		}
+	}
+
+	lockPath := path + ".lock"
+	release, err := lockfile.Acquire(lockPath)
+	if errors.Is(err, lockfile.ErrLocked) {
+		return fmt.Errorf("%w: %s", ErrConfigLocked, lockPath)
+	} else if err != nil {
+		return fmt.Errorf("failed to lock config file: %w", err)
+	}
+	defer release()
+
+	return fn()
+}
+
+// withWriteLock is withLock for a config about to be saved with write. A
+// legacy global config is saved to its new place, which is what another
+// owata that already sees the moved file locks, so that's locked too.
+func (m *Manager) withWriteLock(path string, fn func() error) error {
+	target := m.migrationTarget(path)
+	if target == path {
+		return withLock(path, fn)
+	}
+	return withLock(path, func() error {
+		return withLock(target, fn)
+	})
+}
@@ -0,0 +1,147 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/lockfile"
+)
+
+func TestConcurrentUpdates(t *testing.T) {
+	const workers = 20
+
+	tests := []struct {
+		name   string
+		update func(m *Manager, path string, edit func(*Config) error) error
+	}{
+		{
+			name: "Update",
+			update: func(m *Manager, _ string, edit func(*Config) error) error {
+				_, err := m.Update(false, edit)
+				return err
+			},
+		},
+		{
+			name: "UpdatePath",
+			update: func(m *Manager, path string, edit func(*Config) error) error {
+				_, err := m.UpdatePath(path, edit)
+				return err
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.json")
+			manager := NewManagerForFile(path)
+
+			var wg sync.WaitGroup
+			for i := range workers {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					err := tt.update(manager, path, func(c *Config) error {
+						if c.Profiles == nil {
+							c.Profiles = map[string]Config{}
+						}
+						c.Profiles["p"+strconv.Itoa(i)] = Config{Username: fmt.Sprint("Bot ", i)}
+						return nil
+					})
+					if err != nil {
+						t.Errorf("Unexpected error: %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			config, err := manager.LoadFromPath(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(config.Profiles) != workers {
+				t.Errorf("Expected all %d updates, got %d: %v", workers, len(config.Profiles), config.ProfileNames())
+			}
+			if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+				t.Errorf("Expected the lock file to be removed, got %v", err)
+			}
+		})
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	currentDir, _ := os.Getwd()
+	defer os.Chdir(currentDir)
+	os.Chdir(t.TempDir())
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+
+	manager := NewManager()
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := manager.Save(&Config{Username: fmt.Sprint("Bot ", i), Retries: i}, true); err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Each save replaces the file whole, so it holds one of them intact
+	config, _, err := manager.Load(true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if config.Username != fmt.Sprint("Bot ", config.Retries) {
+		t.Errorf("Expected one save's values, got %+v", config)
+	}
+}
+
+func TestConfigLocked(t *testing.T) {
+	originalTimeout := lockfile.Timeout
+	lockfile.Timeout = 50 * time.Millisecond
+	defer func() { lockfile.Timeout = originalTimeout }()
+
+	path := filepath.Join(t.TempDir(), "config.json")
+	manager := NewManagerForFile(path)
+	lockPath := path + ".lock"
+
+	t.Run("Held by another process", func(t *testing.T) {
+		os.WriteFile(lockPath, nil, 0644)
+		defer os.Remove(lockPath)
+
+		_, err := manager.Save(&Config{Username: "Bot"}, false)
+		if !errors.Is(err, ErrConfigLocked) {
+			t.Fatalf("Expected %v, got %v", ErrConfigLocked, err)
+		}
+		if err.Error() != "config is locked by another process: "+lockPath {
+			t.Errorf("Expected the lock file in the error, got %v", err)
+		}
+		if _, err := os.Stat(path); !os.IsNotExist(err) {
+			t.Error("Expected nothing to be written")
+		}
+	})
+
+	t.Run("Left behind by a crash", func(t *testing.T) {
+		os.WriteFile(lockPath, nil, 0644)
+		staleTime := time.Now().Add(-2 * lockfile.StaleAge)
+		os.Chtimes(lockPath, staleTime, staleTime)
+
+		if _, err := manager.Update(false, func(c *Config) error {
+			c.Username = "Bot"
+			return nil
+		}); err != nil {
+			t.Fatalf("Expected the stale lock to be ignored, got %v", err)
+		}
+		if _, err := os.Stat(lockPath); !os.IsNotExist(err) {
+			t.Error("Expected the lock file to be removed")
+		}
+	})
+}
@@ -0,0 +1,68 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+)
+
+// overlay returns a copy of base with the values set in over replacing its
+// own, field by field. A field over leaves empty keeps base's value; the
+// embed section is merged the same way, while lists and maps are replaced
+// whole rather than merged.
+func overlay(base, over *Config) *Config {
+	merged := *base
+	overlayFields(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(over).Elem())
+	return &merged
+}
+
+func overlayFields(dst, src reflect.Value) {
+	for i := range dst.NumField() {
+		switch {
+		case !dst.Type().Field(i).IsExported() || src.Field(i).IsZero():
+		case dst.Field(i).Kind() == reflect.Struct:
+			overlayFields(dst.Field(i), src.Field(i))
+		default:
+			dst.Field(i).Set(src.Field(i))
+		}
+	}
+}
+
+// readResolved reads the config Resolve starts from: the global config with
+// the local one laid over it, so a local config that only sets a username
+// still sends to the global webhook. With preferGlobal or --config only that
+// one file is read. path is the file ResolvePath picks, which edits go to.
+func (m *Manager) readResolved(preferGlobal bool) (config *Config, path string, err error) {
+	path, err = m.ResolvePath(preferGlobal)
+	if err != nil {
+		return nil, path, err
+	}
+	if config, err = readConfig(path); err != nil {
+		return nil, path, err
+	}
+	config.files = []string{path}
+	config.setOrigin(m.origin(path), config.setKeys()...)
+	if preferGlobal || m.configPath != "" {
+		return config, path, nil
+	}
+
+	globalPath, err := m.GetPathWithError(true)
+	if err != nil || globalPath == path {
+		// No global config to merge, or it's the only one
+		return config, path, nil
+	}
+	global, err := readConfig(globalPath)
+	if errors.Is(err, ErrConfigFileNotFound) {
+		return config, path, nil
+	}
+	if err != nil {
+		return nil, path, fmt.Errorf("global config %s: %w", globalPath, err)
+	}
+	m.debug("merging local config over global", "local", path, "global", globalPath)
+
+	global.setOrigin(OriginGlobal, global.setKeys()...)
+	merged := overlay(global, config)
+	merged.files = []string{globalPath, path}
+	merged.setOrigin(OriginLocal, config.setKeys()...)
+	return merged, path, nil
+}
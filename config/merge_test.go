@@ -0,0 +1,243 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestOverlay(t *testing.T) {
+	zero := 0
+	base := &Config{
+		WebhookURL: "https://discord.com/api/webhooks/1/global",
+		Username:   "Global",
+		NtfyTags:   []string{"global", "ci"},
+		Targets:    map[string]string{"ops": "https://discord.com/api/webhooks/2/ops"},
+		Retries:    3,
+		Git:        true,
+		Embed:      EmbedDefaults{Title: "Global", Fields: []string{"Team=infra"}},
+	}
+	over := &Config{
+		Username:         "Local",
+		NtfyTags:         []string{"local"},
+		Targets:          map[string]string{"dev": "https://discord.com/api/webhooks/3/dev"},
+		RateLimitRetries: &zero,
+		Embed:            EmbedDefaults{Footer: "Local"},
+	}
+
+	got := overlay(base, over)
+	want := &Config{
+		WebhookURL:       "https://discord.com/api/webhooks/1/global",
+		Username:         "Local",
+		NtfyTags:         []string{"local"},
+		Targets:          map[string]string{"dev": "https://discord.com/api/webhooks/3/dev"},
+		Retries:          3,
+		RateLimitRetries: &zero,
+		Git:              true,
+		Embed:            EmbedDefaults{Title: "Global", Footer: "Local", Fields: []string{"Team=infra"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	if base.Username != "Global" || len(base.NtfyTags) != 2 || base.Embed.Footer != "" {
+		t.Errorf("Expected the base to be left as is, got %+v", base)
+	}
+}
+
+// TestResolveMerge checks every combination of a field being set in the
+// global config, the local config and the environment, for a string, a list
+// and a number: the environment wins, then the local config, then the global
+// one, and a field set nowhere stays empty
+func TestResolveMerge(t *testing.T) {
+	fields := []struct {
+		key string
+		set func(c *Config, value string)
+		get func(c *Config) string
+	}{
+		{
+			key: "username",
+			set: func(c *Config, v string) { c.Username = v },
+			get: func(c *Config) string { return c.Username },
+		},
+		{
+			key: "ntfy_tags",
+			set: func(c *Config, v string) { c.NtfyTags = []string{v, v + "-2"} },
+			get: func(c *Config) string { return strings.Join(c.NtfyTags, ",") },
+		},
+		{
+			key: "retries",
+			set: func(c *Config, v string) { c.Retries = len(v) },
+			get: func(c *Config) string {
+				if c.Retries == 0 {
+					return ""
+				}
+				return strings.Repeat("x", c.Retries)
+			},
+		},
+	}
+	// Values of different lengths, so retries tells the layers apart too
+	layers := map[string]string{"global": "g", "local": "lo", "env": "env"}
+
+	for _, field := range fields {
+		for combo := range 8 {
+			hasGlobal, hasLocal, hasEnv := combo&1 != 0, combo&2 != 0, combo&4 != 0
+			name := fmt.Sprintf("%s global=%v local=%v env=%v", field.key, hasGlobal, hasLocal, hasEnv)
+
+			t.Run(name, func(t *testing.T) {
+				currentDir, _ := os.Getwd()
+				defer os.Chdir(currentDir)
+				os.Chdir(t.TempDir())
+				SetTestConfigDir(t.TempDir())
+				defer ResetTestConfigDir()
+				for _, key := range []string{"webhook_url", "username", "ntfy_tags", "retries"} {
+					t.Setenv(EnvVar(key), "")
+				}
+
+				// Both files always exist and set something else, so only
+				// the field under test is absent from them
+				global := &Config{WebhookURL: "https://discord.com/api/webhooks/1/global"}
+				local := &Config{AvatarURL: "https://example.com/local.png"}
+				want := ""
+				if hasGlobal {
+					field.set(global, layers["global"])
+					want = field.get(global)
+				}
+				if hasLocal {
+					field.set(local, layers["local"])
+					want = field.get(local)
+				}
+				manager := NewManager()
+				if _, err := manager.Save(global, true); err != nil {
+					t.Fatal(err)
+				}
+				if _, err := manager.Save(local, false); err != nil {
+					t.Fatal(err)
+				}
+				if hasEnv {
+					var probe Config
+					field.set(&probe, layers["env"])
+					value := field.get(&probe)
+					if field.key == "retries" {
+						value = fmt.Sprint(probe.Retries)
+					}
+					t.Setenv(EnvVar(field.key), value)
+					want = field.get(&probe)
+				}
+
+				config, path, err := manager.Resolve(false)
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if path != ConfigFileName {
+					t.Errorf("Expected edits to go to the local config, got %s", path)
+				}
+				if got := field.get(config); got != want {
+					t.Errorf("Expected %s %q, got %q", field.key, want, got)
+				}
+				if config.WebhookURL != global.WebhookURL || config.AvatarURL != local.AvatarURL {
+					t.Errorf("Expected the fields set in only one file to be kept, got %+v", config)
+				}
+			})
+		}
+	}
+}
+
+func TestResolveMergeSources(t *testing.T) {
+	setup := func(t *testing.T) *Manager {
+		currentDir, _ := os.Getwd()
+		t.Cleanup(func() { os.Chdir(currentDir) })
+		os.Chdir(t.TempDir())
+		SetTestConfigDir(t.TempDir())
+		t.Cleanup(ResetTestConfigDir)
+		return NewManager()
+	}
+
+	t.Run("Global flag reads the global config only", func(t *testing.T) {
+		manager := setup(t)
+		manager.Save(&Config{WebhookURL: "https://discord.com/api/webhooks/1/global"}, true)
+		manager.Save(&Config{Username: "Local"}, false)
+
+		config, _, err := manager.Resolve(true)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Username != "" || config.WebhookURL == "" {
+			t.Errorf("Expected the global config alone, got %+v", config)
+		}
+	})
+
+	t.Run("Config flag reads that file only", func(t *testing.T) {
+		manager := setup(t)
+		manager.Save(&Config{WebhookURL: "https://discord.com/api/webhooks/1/global"}, true)
+		path := filepath.Join(t.TempDir(), "other.json")
+		os.WriteFile(path, []byte(`{"username": "Other"}`), 0644)
+
+		config, _, err := NewManagerForFile(path).Resolve(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Username != "Other" || config.WebhookURL != "" {
+			t.Errorf("Expected the --config file alone, got %+v", config)
+		}
+	})
+
+	t.Run("Invalid global config names the file", func(t *testing.T) {
+		manager := setup(t)
+		globalPath, _ := manager.GetPathWithError(true)
+		os.MkdirAll(filepath.Dir(globalPath), 0755)
+		os.WriteFile(globalPath, []byte(`{"username": `), 0644)
+		manager.Save(&Config{Username: "Local"}, false)
+
+		_, _, err := manager.Resolve(false)
+		if !errors.Is(err, ErrInvalidJSON) || !strings.Contains(err.Error(), "global config "+globalPath) {
+			t.Errorf("Expected an invalid JSON error naming the global config, got %v", err)
+		}
+	})
+
+	t.Run("Profile applies over the merged config", func(t *testing.T) {
+		manager := setup(t)
+		manager.Save(&Config{
+			WebhookURL: "https://discord.com/api/webhooks/1/global",
+			Profiles:   map[string]Config{"work": {Username: "Work"}},
+		}, true)
+		manager.Save(&Config{Username: "Local", AvatarURL: "https://example.com/local.png"}, false)
+		manager.UseProfile("work")
+
+		config, _, err := manager.Resolve(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.Username != "Work" || config.AvatarURL != "https://example.com/local.png" || config.WebhookURL == "" {
+			t.Errorf("Expected the global profile over both files, got %+v", config)
+		}
+	})
+}
+
+func TestDisplayResolved(t *testing.T) {
+	currentDir, _ := os.Getwd()
+	defer os.Chdir(currentDir)
+	os.Chdir(t.TempDir())
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+	t.Setenv("OWATA_USERNAME", "")
+
+	manager := NewManager()
+	manager.Save(&Config{WebhookURL: "https://discord.com/api/webhooks/1/global", Username: "Global"}, true)
+	manager.Save(&Config{Username: "Local"}, false)
+	globalPath, _ := manager.GetPathWithError(true)
+
+	got, err := manager.DisplayResolved(false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.Contains(got, globalPath+", overridden by "+ConfigFileName) {
+		t.Errorf("Expected the files in the header, got:\n%s", got)
+	}
+	if !strings.Contains(got, "Local") || strings.Contains(got, "Global") {
+		t.Errorf("Expected the local username, got:\n%s", got)
+	}
+}
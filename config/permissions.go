@@ -0,0 +1,31 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"runtime"
+)
+
+// fileMode is the mode new config files get. The webhook URL in them lets
+// anyone post to the channel, so only the owner can read them.
+const fileMode fs.FileMode = 0600
+
+// Files returns the config files c was read from, the one whose values win
+// last
+func (c *Config) Files() []string {
+	return c.files
+}
+
+// LoosePermissions reports whether users other than the owner can read the
+// file at path, and its permissions. Windows doesn't use these bits, so
+// nothing is reported there.
+func LoosePermissions(path string) (fs.FileMode, bool) {
+	if runtime.GOOS == "windows" {
+		return 0, false
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0, false
+	}
+	return info.Mode().Perm(), info.Mode().Perm()&0044 != 0
+}
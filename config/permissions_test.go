@@ -0,0 +1,81 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func TestWrittenFileMode(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't use Unix permissions")
+	}
+	currentDir, _ := os.Getwd()
+	defer os.Chdir(currentDir)
+	os.Chdir(t.TempDir())
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+
+	manager := NewManager()
+	saved := filepath.Join(t.TempDir(), "saved.json")
+	if err := manager.SaveToPath(&Config{WebhookURL: "https://discord.com/api/webhooks/1/token"}, saved); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	template, _, err := manager.CreateTemplate(false)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	global, err := manager.Save(&Config{Username: "Bot"}, true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for _, path := range []string{saved, template, global} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := info.Mode().Perm(); got != 0600 {
+			t.Errorf("Expected %s to be written with 0600, got %04o", path, got)
+		}
+	}
+}
+
+func TestLoosePermissions(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		if _, loose := LoosePermissions(os.Args[0]); loose {
+			t.Error("Expected no permission check on Windows")
+		}
+		return
+	}
+
+	tests := []struct {
+		mode     fs.FileMode
+		expected bool
+	}{
+		{0600, false},
+		{0400, false},
+		{0700, false},
+		{0640, true},
+		{0604, true},
+		{0644, true},
+	}
+
+	dir := t.TempDir()
+	for _, tt := range tests {
+		path := filepath.Join(dir, "config.json")
+		os.WriteFile(path, []byte("{}"), 0600)
+		os.Chmod(path, tt.mode)
+
+		mode, loose := LoosePermissions(path)
+		if loose != tt.expected || mode != tt.mode {
+			t.Errorf("Expected %04o to be loose=%v, got %04o loose=%v", tt.mode, tt.expected, mode, loose)
+		}
+	}
+
+	if _, loose := LoosePermissions(filepath.Join(dir, "missing.json")); loose {
+		t.Error("Expected a missing file not to be reported")
+	}
+}
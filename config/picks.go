@@ -0,0 +1,78 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/yashikota/owata/atomicfile"
+)
+
+// PicksFileName is the file next to the global config that remembers which
+// profile or target to send to in each directory
+const PicksFileName = "picks.json"
+
+// RememberedPick returns the profile or target remembered for dir with
+// RememberPick, or "" when there is none
+func RememberedPick(dir string) (string, error) {
+	path, err := picksPath()
+	if err != nil {
+		return "", err
+	}
+	picks, err := readPicks(path)
+	if err != nil {
+		return "", err
+	}
+	return picks[dir], nil
+}
+
+// RememberPick records pick as the profile or target to send to in dir from
+// now on
+func RememberPick(dir, pick string) error {
+	path, err := picksPath()
+	if err != nil {
+		return err
+	}
+	return withLock(path, func() error {
+		picks, err := readPicks(path)
+		if err != nil {
+			return err
+		}
+		picks[dir] = pick
+
+		data, err := json.MarshalIndent(picks, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := atomicfile.Write(path, append(data, '\n'), fileMode); err != nil {
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+		return nil
+	})
+}
+
+func picksPath() (string, error) {
+	configDir, err := userConfigDirFunc()
+	if err != nil {
+		return "", fmt.Errorf("could not determine config directory: %w", err)
+	}
+	return filepath.Join(configDir, GlobalConfigDir, PicksFileName), nil
+}
+
+func readPicks(path string) (map[string]string, error) {
+	picks := map[string]string{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return picks, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	if err := json.Unmarshal(data, &picks); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", path, err)
+	}
+	return picks, nil
+}
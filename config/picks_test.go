@@ -0,0 +1,56 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRememberPick(t *testing.T) {
+	configDir := t.TempDir()
+	SetTestConfigDir(configDir)
+	defer ResetTestConfigDir()
+
+	if pick, err := RememberedPick("/src/app"); err != nil || pick != "" {
+		t.Fatalf("Expected nothing remembered, got %q, %v", pick, err)
+	}
+
+	if err := RememberPick("/src/app", "profile work"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RememberPick("/src/blog", "target home"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := RememberPick("/src/app", "target ops"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	for dir, expected := range map[string]string{"/src/app": "target ops", "/src/blog": "target home", "/src": ""} {
+		if pick, err := RememberedPick(dir); err != nil || pick != expected {
+			t.Errorf("Expected %q for %s, got %q, %v", expected, dir, pick, err)
+		}
+	}
+
+	path := filepath.Join(configDir, GlobalConfigDir, PicksFileName)
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != fileMode {
+		t.Errorf("Expected %s with mode %04o, got %v", path, fileMode, err)
+	}
+}
+
+func TestRememberedPickInvalid(t *testing.T) {
+	configDir := t.TempDir()
+	SetTestConfigDir(configDir)
+	defer ResetTestConfigDir()
+
+	path := filepath.Join(configDir, GlobalConfigDir, PicksFileName)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte("not json"), 0600)
+
+	if _, err := RememberedPick("/src/app"); err == nil || !strings.Contains(err.Error(), "invalid "+path) {
+		t.Errorf("Expected an invalid file error, got %v", err)
+	}
+	if err := RememberPick("/src/app", "profile work"); err == nil {
+		t.Error("Expected the invalid file to be left alone")
+	}
+}
@@ -0,0 +1,87 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"strings"
+)
+
+// EnvProfile picks the profile when --profile isn't given
+const EnvProfile = EnvPrefix + "PROFILE"
+
+// UseProfile makes Resolve and DisplayConfig apply the named profile instead
+// of the one OWATA_PROFILE or default_profile picks
+func (m *Manager) UseProfile(name string) {
+	m.profile = name
+}
+
+// profileName returns the profile to apply to c: --profile, then
+// OWATA_PROFILE, then default_profile, which the environment can also set
+func (m *Manager) profileName(c *Config) string {
+	return cmp.Or(m.profile, os.Getenv(EnvProfile), os.Getenv(EnvVar("default_profile")), c.DefaultProfile)
+}
+
+// ProfileNames returns the names of the profiles in c, sorted
+func (c *Config) ProfileNames() []string {
+	return slices.Sorted(maps.Keys(c.Profiles))
+}
+
+// Profile returns the name of the profile Resolve applied, if any
+func (c *Config) Profile() string {
+	return c.profile
+}
+
+// WithProfile returns a copy of c with the values set in the named profile
+// overriding its own; an empty name returns c as is
+func (c *Config) WithProfile(name string) (*Config, error) {
+	if name == "" {
+		return c, nil
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return nil, fmt.Errorf("unknown profile: %s (no profiles in config)", name)
+		}
+		return nil, fmt.Errorf("unknown profile: %s (available: %s)", name, strings.Join(c.ProfileNames(), ", "))
+	}
+
+	merged := overlay(c, &profile)
+	merged.profile = name
+	merged.setOrigin(OriginProfile, profile.setKeys()...)
+	return merged, nil
+}
+
+// EditProfile applies edit to the named profile, creating it if there is
+// none, or to c itself when name is empty
+func (c *Config) EditProfile(name string, edit func(*Config) error) error {
+	if name == "" {
+		return edit(c)
+	}
+	profile := c.Profiles[name]
+	if err := edit(&profile); err != nil {
+		return err
+	}
+	if c.Profiles == nil {
+		c.Profiles = map[string]Config{}
+	}
+	c.Profiles[name] = profile
+	return nil
+}
+
+// validateProfiles checks each profile like the config itself. Profiles
+// can't hold profiles of their own.
+func (c *Config) validateProfiles() error {
+	for _, name := range c.ProfileNames() {
+		profile := c.Profiles[name]
+		if len(profile.Profiles) > 0 || profile.DefaultProfile != "" {
+			return fmt.Errorf("invalid profile %s in config: profiles and default_profile can't be set inside a profile", name)
+		}
+		if err := profile.Validate(); err != nil {
+			return fmt.Errorf("invalid profile %s in config: %v", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package config
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestWithProfile(t *testing.T) {
+	c := &Config{
+		WebhookURL: "https://discord.com/api/webhooks/1/base",
+		Username:   "Base",
+		Retries:    2,
+		Profiles: map[string]Config{
+			"work": {WebhookURL: "https://discord.com/api/webhooks/2/work", Git: true},
+			"home": {Username: "Home"},
+		},
+	}
+
+	work, err := c.WithProfile("work")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if work.WebhookURL != "https://discord.com/api/webhooks/2/work" || !work.Git {
+		t.Errorf("Expected the profile's values, got %+v", work)
+	}
+	if work.Username != "Base" || work.Retries != 2 {
+		t.Errorf("Expected values the profile leaves unset to come from the rest, got %+v", work)
+	}
+	if work.Profile() != "work" || c.Profile() != "" || c.Git {
+		t.Errorf("Expected a copy marked with the profile and the original untouched")
+	}
+
+	if same, _ := c.WithProfile(""); same != c {
+		t.Error("Expected no profile to return the config as is")
+	}
+
+	_, err = c.WithProfile("play")
+	if err == nil || !strings.Contains(err.Error(), "unknown profile: play (available: home, work)") {
+		t.Errorf("Expected an unknown profile error listing the profiles, got %v", err)
+	}
+	_, err = (&Config{}).WithProfile("play")
+	if err == nil || !strings.Contains(err.Error(), "no profiles in config") {
+		t.Errorf("Expected an error saying there are no profiles, got %v", err)
+	}
+}
+
+func TestEditProfile(t *testing.T) {
+	var c Config
+	if err := c.EditProfile("work", func(p *Config) error { return p.Set("username", "Work") }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c.Profiles["work"].Username != "Work" || c.Username != "" {
+		t.Errorf("Expected the profile to be created and edited, got %+v", c)
+	}
+	if err := c.EditProfile("", func(p *Config) error { return p.Set("username", "Base") }); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if c.Username != "Base" {
+		t.Errorf("Expected no profile to edit the config itself, got %+v", c)
+	}
+}
+
+func TestValidateProfiles(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  Config
+		wantErr string
+	}{
+		{name: "Valid", config: Config{Profiles: map[string]Config{"work": {NotifyOn: NotifyOnFailure}}}},
+		{name: "Invalid value", config: Config{Profiles: map[string]Config{"work": {NotifyOn: "sometimes"}}}, wantErr: "invalid profile work in config: invalid notify_on"},
+		{name: "Nested", config: Config{Profiles: map[string]Config{"work": {DefaultProfile: "home"}}}, wantErr: "can't be set inside a profile"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+// TestResolveProfile checks which profile is picked: --profile, then
+// OWATA_PROFILE, then default_profile, with the environment still overriding
+// the profile's values
+func TestResolveProfile(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	NewManager().Save(&Config{
+		Username:       "Base",
+		DefaultProfile: "home",
+		Profiles: map[string]Config{
+			"home": {Username: "Home"},
+			"work": {Username: "Work"},
+			"ci":   {Username: "CI"},
+		},
+	}, false)
+
+	tests := []struct {
+		name        string
+		flag        string
+		envProfile  string
+		envDefault  string
+		envUsername string
+		want        string
+		wantErr     string
+	}{
+		{name: "default_profile", want: "Home"},
+		{name: "OWATA_DEFAULT_PROFILE over the file", envDefault: "ci", want: "CI"},
+		{name: "OWATA_PROFILE over default_profile", envProfile: "work", envDefault: "ci", want: "Work"},
+		{name: "Flag over OWATA_PROFILE", flag: "ci", envProfile: "work", want: "CI"},
+		{name: "Environment over the profile", flag: "ci", envUsername: "Env", want: "Env"},
+		{name: "Unknown profile", flag: "play", wantErr: "available: ci, home, work"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OWATA_PROFILE", tt.envProfile)
+			t.Setenv("OWATA_DEFAULT_PROFILE", tt.envDefault)
+			t.Setenv("OWATA_USERNAME", tt.envUsername)
+			manager := NewManager()
+			manager.UseProfile(tt.flag)
+
+			config, _, err := manager.Resolve(false)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.Username != tt.want {
+				t.Errorf("Expected username %q, got %q", tt.want, config.Username)
+			}
+		})
+	}
+}
+
+func TestDisplayConfigProfiles(t *testing.T) {
+	tempDir := t.TempDir()
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+	currentDir, _ := os.Getwd()
+	os.Chdir(tempDir)
+	defer os.Chdir(currentDir)
+
+	manager := NewManager()
+	manager.Save(&Config{Profiles: map[string]Config{"home": {}, "work": {Username: "Work"}}}, false)
+	manager.UseProfile("work")
+
+	output, err := manager.DisplayConfig(ConfigFileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"Username: Work", "Profiles: home, work (active)"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output:\n%s", want, output)
+		}
+	}
+}
+
+func TestGetProfilesMasked(t *testing.T) {
+	c := Config{Profiles: map[string]Config{"work": {WebhookURL: "https://discord.com/api/webhooks/2/secret", Username: "Work"}}}
+	got, err := c.Get("profiles", func(string) string { return "****" })
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if want := `{"work":{"webhook_url":"****","username":"Work","avatar_url":""}}`; got != want {
+		t.Errorf("Expected %s, got %s", want, got)
+	}
+	if c.Profiles["work"].WebhookURL != "https://discord.com/api/webhooks/2/secret" {
+		t.Error("Expected masking to leave the config untouched")
+	}
+}
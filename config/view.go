@@ -0,0 +1,164 @@
+package config
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"strings"
+)
+
+// Origins of a config value: the layer that set it last
+const (
+	OriginGlobal  = "global"
+	OriginLocal   = "local" // The local config, or the file named with --config
+	OriginProfile = "profile"
+	OriginEnv     = "env"
+)
+
+// View is a config as owata config shows it: the values notifications use,
+// the files they were read from and where each value came from. The human
+// and JSON renderings are both made from it.
+type View struct {
+	Resolved bool              `json:"resolved"`          // Merged like Resolve, rather than one file shown
+	Files    []string          `json:"files"`             // The files read, the one whose values win last
+	Missing  string            `json:"missing,omitempty"` // The file shown when it doesn't exist
+	Profile  string            `json:"profile,omitempty"`
+	Env      []string          `json:"env,omitempty"` // The OWATA_ variables applied
+	Config   *Config           `json:"config"`
+	Origins  map[string]string `json:"origins"` // The origin of each key that is set
+}
+
+func newView(config *Config, resolved bool) *View {
+	files := config.files
+	if files == nil {
+		files = []string{}
+	}
+	origins := maps.Clone(config.origins)
+	if origins == nil {
+		origins = map[string]string{}
+	}
+	return &View{
+		Resolved: resolved,
+		Files:    files,
+		Profile:  config.profile,
+		Env:      config.fromEnv,
+		Config:   config,
+		Origins:  origins,
+	}
+}
+
+// ViewConfig gathers the config at path with OWATA_ environment variables
+// applied, as notifications would use it. A missing file gives a view of
+// the environment alone, with Missing set.
+func (m *Manager) ViewConfig(path string) (*View, error) {
+	config, err := readConfig(path)
+	missing := errors.Is(err, ErrConfigFileNotFound)
+	if missing {
+		config = &Config{}
+	} else if err != nil {
+		return nil, err
+	} else {
+		config.files = []string{path}
+		config.setOrigin(m.origin(path), config.setKeys()...)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config, err = config.WithProfile(m.profileName(config)); err != nil {
+		return nil, err
+	}
+	if err := config.applyEnv(); err != nil {
+		return nil, invalid(err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	view := newView(config, false)
+	if missing {
+		view.Missing = path
+	}
+	return view, nil
+}
+
+// ViewResolved gathers the config notifications use, as Resolve returns it
+func (m *Manager) ViewResolved(preferGlobal bool) (*View, error) {
+	config, _, err := m.Resolve(preferGlobal)
+	if err != nil {
+		return nil, err
+	}
+	return newView(config, true), nil
+}
+
+// String renders v for people, as owata config prints it
+func (v *View) String() string {
+	var from string
+	switch {
+	case v.Missing != "":
+		from = fmt.Sprintf("environment only; %s does not exist", v.Missing)
+	case len(v.Files) == 0:
+		from = "environment only"
+	default:
+		from = strings.Join(v.Files, ", overridden by ")
+	}
+	heading := "Current configuration"
+	if v.Resolved {
+		heading = "Resolved configuration"
+	}
+	return fmt.Sprintf("\n📋 %s (%s):\n", heading, from) + describe(v.Config)
+}
+
+// JSON renders v for scripts, with each URL or token in the config passed
+// through mask unless mask is nil
+func (v *View) JSON(mask func(string) string) ([]byte, error) {
+	masked := *v
+	if mask != nil {
+		masked.Config = v.Config.masked(mask)
+	}
+	data, err := json.Marshal(masked)
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal config: %v", err)
+	}
+	return data, nil
+}
+
+// origin names the layer the config file at path is
+func (m *Manager) origin(path string) string {
+	if m.configPath != "" || m.isLocal(path) {
+		return OriginLocal
+	}
+	if globalPath, err := m.GetPathWithError(true); err == nil && globalPath == path {
+		return OriginGlobal
+	}
+	return OriginLocal
+}
+
+// setKeys returns the keys c sets, in the order of the Config struct
+func (c *Config) setKeys() []string {
+	v := reflect.ValueOf(c).Elem()
+	var keys []string
+	for i := range v.NumField() {
+		if f := v.Type().Field(i); f.IsExported() && !v.Field(i).IsZero() {
+			keys = append(keys, jsonKey(f))
+		}
+	}
+	return keys
+}
+
+// setOrigin records origin as where each of keys came from. The map is
+// copied first, since copies of c made by overlay share it.
+func (c *Config) setOrigin(origin string, keys ...string) {
+	if origin == "" || len(keys) == 0 {
+		return
+	}
+	origins := maps.Clone(c.origins)
+	if origins == nil {
+		origins = make(map[string]string, len(keys))
+	}
+	for _, key := range keys {
+		origins[key] = origin
+	}
+	c.origins = origins
+}
@@ -0,0 +1,192 @@
+package config
+
+import (
+	"encoding/json"
+	"maps"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestViewOrigins(t *testing.T) {
+	setup := func(t *testing.T) (*Manager, string) {
+		currentDir, _ := os.Getwd()
+		t.Cleanup(func() { os.Chdir(currentDir) })
+		os.Chdir(t.TempDir())
+		SetTestConfigDir(t.TempDir())
+		t.Cleanup(ResetTestConfigDir)
+		for _, key := range []string{"webhook_url", "username", "avatar_url", "retries", "profile"} {
+			t.Setenv(EnvVar(key), "")
+		}
+
+		manager := NewManager()
+		manager.Save(&Config{
+			WebhookURL: "https://discord.com/api/webhooks/1/global",
+			Username:   "Global",
+			Retries:    1,
+			Profiles:   map[string]Config{"work": {AvatarURL: "https://example.com/work.png"}},
+		}, true)
+		manager.Save(&Config{Username: "Local"}, false)
+		globalPath, _ := manager.GetPathWithError(true)
+		return manager, globalPath
+	}
+
+	t.Run("Resolved", func(t *testing.T) {
+		manager, globalPath := setup(t)
+		manager.UseProfile("work")
+		t.Setenv("OWATA_RETRIES", "5")
+
+		view, err := manager.ViewResolved(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		want := map[string]string{
+			"webhook_url": OriginGlobal,
+			"username":    OriginLocal,
+			"avatar_url":  OriginProfile,
+			"retries":     OriginEnv,
+			"profiles":    OriginGlobal,
+		}
+		if !maps.Equal(view.Origins, want) {
+			t.Errorf("Expected origins %v, got %v", want, view.Origins)
+		}
+		if !slices.Equal(view.Files, []string{globalPath, ConfigFileName}) {
+			t.Errorf("Expected both files, got %q", view.Files)
+		}
+		if !view.Resolved || view.Profile != "work" || !slices.Equal(view.Env, []string{"OWATA_RETRIES"}) {
+			t.Errorf("Expected the work profile and OWATA_RETRIES, got %+v", view)
+		}
+	})
+
+	t.Run("Local file", func(t *testing.T) {
+		manager, _ := setup(t)
+		view, err := manager.ViewConfig(ConfigFileName)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := map[string]string{"username": OriginLocal}; !maps.Equal(view.Origins, want) {
+			t.Errorf("Expected origins %v, got %v", want, view.Origins)
+		}
+	})
+
+	t.Run("Global file", func(t *testing.T) {
+		manager, globalPath := setup(t)
+		view, err := manager.ViewConfig(globalPath)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if view.Origins["username"] != OriginGlobal || view.Origins["webhook_url"] != OriginGlobal {
+			t.Errorf("Expected global origins, got %v", view.Origins)
+		}
+	})
+
+	t.Run("Config flag", func(t *testing.T) {
+		setup(t)
+		path := filepath.Join(t.TempDir(), "other.json")
+		os.WriteFile(path, []byte(`{"username": "Other"}`), 0600)
+
+		view, err := NewManagerForFile(path).ViewResolved(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := map[string]string{"username": OriginLocal}; !maps.Equal(view.Origins, want) {
+			t.Errorf("Expected origins %v, got %v", want, view.Origins)
+		}
+	})
+
+	t.Run("Webhook URL file", func(t *testing.T) {
+		manager, _ := setup(t)
+		path := filepath.Join(t.TempDir(), "webhook")
+		os.WriteFile(path, []byte("https://discord.com/api/webhooks/2/file\n"), 0600)
+		manager.Save(&Config{WebhookURLFile: path}, false)
+
+		view, err := manager.ViewResolved(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if view.Origins["webhook_url"] != OriginLocal || view.Config.WebhookURL != "https://discord.com/api/webhooks/2/file" {
+			t.Errorf("Expected the webhook URL from the local file, got %v %q", view.Origins, view.Config.WebhookURL)
+		}
+	})
+
+	t.Run("Missing file", func(t *testing.T) {
+		manager, _ := setup(t)
+		t.Setenv("OWATA_USERNAME", "Env")
+		view, err := manager.ViewConfig("missing.json")
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if view.Missing != "missing.json" || len(view.Files) != 0 || view.Origins["username"] != OriginEnv {
+			t.Errorf("Expected the environment alone, got %+v", view)
+		}
+	})
+}
+
+func TestViewJSON(t *testing.T) {
+	view := newView(&Config{
+		WebhookURL: "https://discord.com/api/webhooks/1/secret",
+		Username:   "Bot",
+		files:      []string{ConfigFileName},
+		origins:    map[string]string{"webhook_url": OriginLocal, "username": OriginLocal},
+	}, false)
+	mask := func(string) string { return "****" }
+
+	tests := []struct {
+		name        string
+		mask        func(string) string
+		expectedURL string
+	}{
+		{"Masked", mask, "****"},
+		{"Revealed", nil, "https://discord.com/api/webhooks/1/secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := view.JSON(tt.mask)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var got struct {
+				Files   []string          `json:"files"`
+				Config  Config            `json:"config"`
+				Origins map[string]string `json:"origins"`
+			}
+			if err := json.Unmarshal(data, &got); err != nil {
+				t.Fatalf("Expected JSON, got %s: %v", data, err)
+			}
+			if got.Config.WebhookURL != tt.expectedURL || got.Config.Username != "Bot" {
+				t.Errorf("Expected webhook URL %q, got %s", tt.expectedURL, data)
+			}
+			if got.Origins["username"] != OriginLocal || !slices.Equal(got.Files, []string{ConfigFileName}) {
+				t.Errorf("Expected the files and origins, got %s", data)
+			}
+		})
+	}
+	if view.Config.WebhookURL != "https://discord.com/api/webhooks/1/secret" {
+		t.Error("Expected masking to leave the view untouched")
+	}
+}
+
+func TestViewString(t *testing.T) {
+	tests := []struct {
+		name     string
+		view     View
+		expected string
+	}{
+		{"File", View{Files: []string{"a.json"}}, "Current configuration (a.json)"},
+		{"Merged", View{Resolved: true, Files: []string{"g.json", "a.json"}}, "Resolved configuration (g.json, overridden by a.json)"},
+		{"Missing", View{Missing: "a.json"}, "Current configuration (environment only; a.json does not exist)"},
+		{"Environment only", View{Resolved: true}, "Resolved configuration (environment only)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.view.Config = &Config{}
+			if got := tt.view.String(); !strings.Contains(got, tt.expected) {
+				t.Errorf("Expected %q in output:\n%s", tt.expected, got)
+			}
+		})
+	}
+}
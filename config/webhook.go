@@ -0,0 +1,111 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultWebhookCommandTimeout is how long webhook_url_command may run when
+// webhook_url_command_timeout isn't set
+const DefaultWebhookCommandTimeout = 10 * time.Second
+
+// ReadWebhookFile returns the webhook URL in the file at path, such as a
+// Docker or Kubernetes secret, with surrounding whitespace trimmed
+func ReadWebhookFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read webhook URL file %s: %v", path, err)
+	}
+	url := strings.TrimSpace(string(data))
+	if url == "" {
+		return "", fmt.Errorf("webhook URL file %s is empty", path)
+	}
+	if strings.ContainsAny(url, "\r\n") {
+		return "", fmt.Errorf("webhook URL file %s holds more than one line; put only the URL in it", path)
+	}
+	return url, nil
+}
+
+// RunWebhookCommand runs command through the system shell, as in
+// "op read op://ci/discord/webhook", and returns what it prints, trimmed, as
+// the webhook URL. Errors include what the command wrote to stderr, but
+// never its output, which is the secret.
+func RunWebhookCommand(command string, timeout time.Duration) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	shell, flag := "sh", "-c"
+	if runtime.GOOS == "windows" {
+		shell, flag = "cmd.exe", "/C"
+	}
+	cmd := exec.CommandContext(ctx, shell, flag, command)
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	// Don't wait on a child the command left holding its output open
+	cmd.WaitDelay = time.Second
+
+	err := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return "", fmt.Errorf("webhook_url_command timed out after %s (raise webhook_url_command_timeout if it needs longer)", timeout)
+	}
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("webhook_url_command failed: %v: %s", err, msg)
+		}
+		return "", fmt.Errorf("webhook_url_command failed: %v", err)
+	}
+
+	url := strings.TrimSpace(stdout.String())
+	if url == "" {
+		return "", fmt.Errorf("webhook_url_command printed nothing; it should print the webhook URL")
+	}
+	if strings.ContainsAny(url, "\r\n") {
+		return "", fmt.Errorf("webhook_url_command printed more than one line; have it print only the webhook URL")
+	}
+	return url, nil
+}
+
+// WebhookCommandTimeout returns how long webhook_url_command may run
+func (c *Config) WebhookCommandTimeout() (time.Duration, error) {
+	if c.WebhookURLCommandTimeout == "" {
+		return DefaultWebhookCommandTimeout, nil
+	}
+	timeout, err := time.ParseDuration(c.WebhookURLCommandTimeout)
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("invalid webhook_url_command_timeout in config: %q (use a positive duration such as \"30s\")", c.WebhookURLCommandTimeout)
+	}
+	return timeout, nil
+}
+
+// fetchWebhookURL sets c's webhook URL from webhook_url_command or, without
+// one, webhook_url_file. Either takes precedence over webhook_url.
+func (m *Manager) fetchWebhookURL(c *Config) error {
+	switch {
+	case c.WebhookURLCommand != "":
+		timeout, err := c.WebhookCommandTimeout()
+		if err != nil {
+			return invalid(err)
+		}
+		start := time.Now()
+		if c.WebhookURL, err = RunWebhookCommand(c.WebhookURLCommand, timeout); err != nil {
+			return err
+		}
+		c.setOrigin(c.origins["webhook_url_command"], "webhook_url")
+		m.debug("ran webhook URL command", "elapsed", time.Since(start).Round(time.Millisecond))
+	case c.WebhookURLFile != "":
+		var err error
+		if c.WebhookURL, err = ReadWebhookFile(c.WebhookURLFile); err != nil {
+			return err
+		}
+		c.setOrigin(c.origins["webhook_url_file"], "webhook_url")
+		m.debug("read webhook URL file", "path", c.WebhookURLFile)
+	}
+	return nil
+}
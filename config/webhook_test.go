@@ -0,0 +1,214 @@
+package config
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadWebhookFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(data), 0600)
+		return path
+	}
+
+	tests := []struct {
+		name        string
+		path        string
+		expected    string
+		expectedErr string
+	}{
+		{name: "Trimmed", path: write("secret", "  https://discord.com/api/webhooks/1/token\n"), expected: "https://discord.com/api/webhooks/1/token"},
+		{name: "Windows line ending", path: write("crlf", "https://discord.com/api/webhooks/1/token\r\n"), expected: "https://discord.com/api/webhooks/1/token"},
+		{name: "Missing", path: filepath.Join(dir, "missing"), expectedErr: "failed to read webhook URL file " + filepath.Join(dir, "missing")},
+		{name: "Directory", path: dir, expectedErr: "failed to read webhook URL file " + dir},
+		{name: "Empty", path: write("empty", "\n"), expectedErr: "is empty"},
+		{name: "Several lines", path: write("lines", "https://a.example/1\nhttps://b.example/2\n"), expectedErr: "more than one line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReadWebhookFile(tt.path)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResolveWebhookFile(t *testing.T) {
+	currentDir, _ := os.Getwd()
+	defer os.Chdir(currentDir)
+	os.Chdir(t.TempDir())
+	SetTestConfigDir(t.TempDir())
+	defer ResetTestConfigDir()
+	t.Setenv("OWATA_WEBHOOK_URL", "")
+	t.Setenv("OWATA_WEBHOOK_URL_FILE", "")
+
+	secret := filepath.Join(t.TempDir(), "discord_webhook")
+	os.WriteFile(secret, []byte("https://discord.com/api/webhooks/2/secret\n"), 0600)
+
+	manager := NewManager()
+	manager.Save(&Config{WebhookURL: "https://discord.com/api/webhooks/1/inline", WebhookURLFile: secret}, false)
+
+	t.Run("File wins over webhook_url", func(t *testing.T) {
+		config, _, err := manager.Resolve(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.WebhookURL != "https://discord.com/api/webhooks/2/secret" {
+			t.Errorf("Expected the URL from the file, got %q", config.WebhookURL)
+		}
+	})
+
+	t.Run("Set from the environment", func(t *testing.T) {
+		other := filepath.Join(t.TempDir(), "other")
+		os.WriteFile(other, []byte("https://discord.com/api/webhooks/3/env"), 0600)
+		t.Setenv("OWATA_WEBHOOK_URL_FILE", other)
+
+		config, _, err := manager.Resolve(false)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if config.WebhookURL != "https://discord.com/api/webhooks/3/env" {
+			t.Errorf("Expected the URL from the file OWATA_WEBHOOK_URL_FILE names, got %q", config.WebhookURL)
+		}
+	})
+
+	t.Run("Missing file names the path", func(t *testing.T) {
+		os.Remove(secret)
+		if _, _, err := manager.Resolve(false); err == nil || !strings.Contains(err.Error(), secret) {
+			t.Errorf("Expected an error naming %s, got %v", secret, err)
+		}
+		// The file itself still loads, so it can be fixed
+		if _, _, err := manager.Load(false); err != nil {
+			t.Errorf("Expected Load not to read the webhook file, got %v", err)
+		}
+	})
+}
+
+func TestRunWebhookCommand(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The commands are written for sh")
+	}
+
+	tests := []struct {
+		name        string
+		command     string
+		timeout     time.Duration
+		expected    string
+		expectedErr string
+	}{
+		{name: "Trimmed output", command: "echo '  https://discord.com/api/webhooks/1/token  '", expected: "https://discord.com/api/webhooks/1/token"},
+		{name: "Shell syntax", command: "printf 'https://discord.com/api/webhooks/' && printf '1/token\\n'", expected: "https://discord.com/api/webhooks/1/token"},
+		{name: "Failure includes stderr", command: "echo 'vault: permission denied' >&2; exit 3", expectedErr: "webhook_url_command failed: exit status 3: vault: permission denied"},
+		{name: "Failure without stderr", command: "exit 1", expectedErr: "webhook_url_command failed: exit status 1"},
+		{name: "Timeout", command: "sleep 5", timeout: 100 * time.Millisecond, expectedErr: "timed out after 100ms"},
+		{name: "No output", command: "true", expectedErr: "printed nothing"},
+		{name: "Several lines", command: "echo https://a.example/1; echo https://b.example/2", expectedErr: "more than one line"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := RunWebhookCommand(tt.command, cmp.Or(tt.timeout, 5*time.Second))
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("Errors never include the output", func(t *testing.T) {
+		_, err := RunWebhookCommand("echo https://discord.com/api/webhooks/1/secret-token; echo failed >&2; exit 1", 5*time.Second)
+		if err == nil || strings.Contains(err.Error(), "secret-token") {
+			t.Errorf("Expected an error without the secret, got %v", err)
+		}
+	})
+}
+
+// TestWebhookURLPrecedence checks every combination of webhook_url,
+// webhook_url_file and webhook_url_command: the command wins, then the file,
+// then webhook_url
+func TestWebhookURLPrecedence(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("The command is written for sh")
+	}
+	secret := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(secret, []byte("https://discord.com/api/webhooks/2/file"), 0600)
+
+	for combo := range 8 {
+		hasURL, hasFile, hasCommand := combo&1 != 0, combo&2 != 0, combo&4 != 0
+		name := fmt.Sprintf("url=%v file=%v command=%v", hasURL, hasFile, hasCommand)
+
+		t.Run(name, func(t *testing.T) {
+			config := &Config{}
+			want := ""
+			if hasURL {
+				config.WebhookURL = "https://discord.com/api/webhooks/1/inline"
+				want = config.WebhookURL
+			}
+			if hasFile {
+				config.WebhookURLFile = secret
+				want = "https://discord.com/api/webhooks/2/file"
+			}
+			if hasCommand {
+				config.WebhookURLCommand = "echo https://discord.com/api/webhooks/3/command"
+				want = "https://discord.com/api/webhooks/3/command"
+			}
+
+			if err := NewManager().fetchWebhookURL(config); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if config.WebhookURL != want {
+				t.Errorf("Expected %q, got %q", want, config.WebhookURL)
+			}
+		})
+	}
+}
+
+func TestWebhookCommandTimeout(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected time.Duration
+		wantErr  bool
+	}{
+		{"", DefaultWebhookCommandTimeout, false},
+		{"30s", 30 * time.Second, false},
+		{"0s", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := (&Config{WebhookURLCommandTimeout: tt.value}).WebhookCommandTimeout()
+		if (err != nil) != tt.wantErr || got != tt.expected {
+			t.Errorf("Expected %s (error %v) for %q, got %s, %v", tt.expected, tt.wantErr, tt.value, got, err)
+		}
+	}
+	if err := (&Config{WebhookURLCommandTimeout: "soon"}).Validate(); err == nil || !strings.Contains(err.Error(), "webhook_url_command_timeout") {
+		t.Errorf("Expected Validate to reject the timeout, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package correlation
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashikota/owata/atomicfile"
+	"github.com/yashikota/owata/lockfile"
+)
+
+const (
+	// ShortIDLength is the number of characters of a run ID shown in notifications
+	ShortIDLength = 4
+
+	// SeqTTL is how long a run's counter is kept after it was last used;
+	// NextSeq removes older ones so the cache doesn't grow with every run
+	SeqTTL = 7 * 24 * time.Hour
+)
+
+// Sentinel errors
+var (
+	ErrInvalidRunID = errors.New("invalid run ID")
+)
+
+// Run IDs end up in file names, so keep them to a safe character set
+var runIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,63}$`)
+
+// For testing purposes
+var userCacheDirFunc = os.UserCacheDir
+
+// GenerateRunID returns a random RFC 4122 version 4 UUID
+func GenerateRunID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate run ID: %w", err)
+	}
+	b[6] = (b[6] & 0x0f) | 0x40 // Version 4
+	b[8] = (b[8] & 0x3f) | 0x80 // Variant 10
+
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
+
+// ValidateRunID checks that a run ID is safe to use as a state file name
+func ValidateRunID(runID string) error {
+	if !runIDPattern.MatchString(runID) {
+		return fmt.Errorf("%w: %q (use up to 64 letters, digits, '.', '_' or '-')", ErrInvalidRunID, runID)
+	}
+	return nil
+}
+
+// ShortID returns the abbreviated form of a run ID used in notifications
+func ShortID(runID string) string {
+	if len(runID) <= ShortIDLength {
+		return runID
+	}
+	return runID[:ShortIDLength]
+}
+
+// NextSeq increments and returns the persisted sequence counter for a run.
+// The first call for a run returns 1. The counter is locked while it's read
+// and written, so steps of a run notifying at the same time each get their
+// own number.
+func NextSeq(runID string) (seq int, err error) {
+	if err := ValidateRunID(runID); err != nil {
+		return 0, err
+	}
+
+	dir, err := stateDir()
+	if err != nil {
+		return 0, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return 0, fmt.Errorf("failed to create sequence directory: %w", err)
+	}
+	pruneSeqs(dir, time.Now())
+
+	path := filepath.Join(dir, runID)
+	release, err := lockfile.Acquire(path + ".lock")
+	if err != nil {
+		return 0, fmt.Errorf("failed to lock sequence file: %w", err)
+	}
+	defer release()
+
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return 0, fmt.Errorf("failed to read sequence file: %w", err)
+	}
+	if err == nil {
+		seq, err = strconv.Atoi(strings.TrimSpace(string(data)))
+		if err != nil {
+			return 0, fmt.Errorf("failed to parse sequence file %s: %w", path, err)
+		}
+	}
+
+	seq++
+	if err := atomicfile.Write(path, []byte(strconv.Itoa(seq)), 0644); err != nil {
+		return 0, fmt.Errorf("failed to write sequence file: %w", err)
+	}
+
+	return seq, nil
+}
+
+// pruneSeqs removes the counters and lock files in dir not used within
+// SeqTTL. It's best effort: a counter that can't be removed now will be on a
+// later call.
+func pruneSeqs(dir string, now time.Time) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return
+	}
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || !info.Mode().IsRegular() || now.Sub(info.ModTime()) < SeqTTL {
+			continue
+		}
+		os.Remove(filepath.Join(dir, entry.Name()))
+	}
+}
+
+func stateDir() (string, error) {
+	cacheDir, err := userCacheDirFunc()
+	if err != nil {
+		return "", fmt.Errorf("could not determine cache directory: %w", err)
+	}
+	return filepath.Join(cacheDir, "owata", "seq"), nil
+}
@@ -0,0 +1,184 @@
+package correlation
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+func setTestCacheDir(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	userCacheDirFunc = func() (string, error) {
+		return dir, nil
+	}
+	t.Cleanup(func() {
+		userCacheDirFunc = os.UserCacheDir
+	})
+	return dir
+}
+
+func TestGenerateRunID(t *testing.T) {
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	first, err := GenerateRunID()
+	if err != nil {
+		t.Fatalf("Failed to generate run ID: %v", err)
+	}
+	if !uuidPattern.MatchString(first) {
+		t.Errorf("Expected a version 4 UUID, got %q", first)
+	}
+
+	second, err := GenerateRunID()
+	if err != nil {
+		t.Fatalf("Failed to generate run ID: %v", err)
+	}
+	if first == second {
+		t.Errorf("Expected distinct run IDs, got %q twice", first)
+	}
+
+	if err := ValidateRunID(first); err != nil {
+		t.Errorf("Generated run ID should be valid, got: %v", err)
+	}
+}
+
+func TestValidateRunID(t *testing.T) {
+	tests := []struct {
+		name        string
+		runID       string
+		expectError bool
+	}{
+		{name: "UUID", runID: "7f2c1a9e-0b6d-4c3e-9f1a-2b3c4d5e6f70"},
+		{name: "Short ID", runID: "build-42"},
+		{name: "Dots and underscores", runID: "nightly_2024.06.01"},
+		{name: "Empty", runID: "", expectError: true},
+		{name: "Path separator", runID: "../etc/passwd", expectError: true},
+		{name: "Leading dot", runID: ".hidden", expectError: true},
+		{name: "Whitespace", runID: "run 1", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateRunID(tt.runID)
+			if tt.expectError {
+				if !errors.Is(err, ErrInvalidRunID) {
+					t.Errorf("Expected ErrInvalidRunID, got %v", err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestShortID(t *testing.T) {
+	if got := ShortID("7f2c1a9e-0b6d-4c3e-9f1a-2b3c4d5e6f70"); got != "7f2c" {
+		t.Errorf("Expected %q, got %q", "7f2c", got)
+	}
+	if got := ShortID("ab"); got != "ab" {
+		t.Errorf("Expected short IDs to be returned unchanged, got %q", got)
+	}
+}
+
+func TestNextSeq(t *testing.T) {
+	setTestCacheDir(t)
+
+	// Counter persists across calls, simulating separate invocations
+	for want := 1; want <= 3; want++ {
+		got, err := NextSeq("run-a")
+		if err != nil {
+			t.Fatalf("Failed to get next sequence: %v", err)
+		}
+		if got != want {
+			t.Errorf("Expected sequence %d, got %d", want, got)
+		}
+	}
+
+	// Each run has its own counter
+	got, err := NextSeq("run-b")
+	if err != nil {
+		t.Fatalf("Failed to get next sequence: %v", err)
+	}
+	if got != 1 {
+		t.Errorf("Expected new run to start at 1, got %d", got)
+	}
+
+	// Invalid run IDs are rejected before touching the filesystem
+	if _, err := NextSeq("../escape"); !errors.Is(err, ErrInvalidRunID) {
+		t.Errorf("Expected ErrInvalidRunID, got %v", err)
+	}
+}
+
+func TestNextSeqPrunesStale(t *testing.T) {
+	cacheDir := setTestCacheDir(t)
+	dir := filepath.Join(cacheDir, "owata", "seq")
+	if _, err := NextSeq("recent"); err != nil {
+		t.Fatalf("Failed to get next sequence: %v", err)
+	}
+
+	// A run last seen longer ago than SeqTTL, along with its lock file
+	old := time.Now().Add(-SeqTTL - time.Hour)
+	for _, name := range []string{"old-run", "old-run.lock"} {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte("3"), 0644); err != nil {
+			t.Fatalf("Failed to write %s: %v", name, err)
+		}
+		if err := os.Chtimes(path, old, old); err != nil {
+			t.Fatalf("Failed to age %s: %v", name, err)
+		}
+	}
+
+	if _, err := NextSeq("current"); err != nil {
+		t.Fatalf("Failed to get next sequence: %v", err)
+	}
+
+	for _, name := range []string{"old-run", "old-run.lock"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("Expected stale %s to be removed, got %v", name, err)
+		}
+	}
+	// Counters in use are kept
+	got, err := NextSeq("recent")
+	if err != nil {
+		t.Fatalf("Failed to get next sequence: %v", err)
+	}
+	if got != 2 {
+		t.Errorf("Expected the recent run to continue at 2, got %d", got)
+	}
+}
+
+func TestNextSeqConcurrent(t *testing.T) {
+	dir := setTestCacheDir(t)
+
+	// Steps of a run notifying at the same time each get their own number
+	const steps = 10
+	seqs := make([]int, steps)
+	var wg sync.WaitGroup
+	for i := range steps {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			seq, err := NextSeq("run-a")
+			if err != nil {
+				t.Errorf("Failed to get next sequence: %v", err)
+			}
+			seqs[i] = seq
+		}()
+	}
+	wg.Wait()
+
+	slices.Sort(seqs)
+	for i, seq := range seqs {
+		if seq != i+1 {
+			t.Fatalf("Expected sequences 1 to %d, got %v", steps, seqs)
+		}
+	}
+	if entries, _ := os.ReadDir(filepath.Join(dir, "owata", "seq")); len(entries) != 1 {
+		t.Errorf("Expected only the sequence file left behind, got %v", entries)
+	}
+}
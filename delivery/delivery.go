@@ -0,0 +1,134 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MinAttemptTimeout is the least time a target is given; a target is skipped
+// rather than attempted with less than this remaining
+const MinAttemptTimeout = 500 * time.Millisecond
+
+// Sentinel errors
+var (
+	ErrNoTargets       = errors.New("no delivery targets")
+	ErrBudgetExhausted = errors.New("delivery budget exhausted")
+)
+
+// SendFunc delivers to a single target within the given timeout
+type SendFunc func(ctx context.Context, target string, timeout time.Duration) error
+
+// Attempt records the outcome of delivering to one target
+type Attempt struct {
+	Target  string
+	Err     error
+	Elapsed time.Duration
+}
+
+// Report summarizes a delivery across a fallback chain
+type Report struct {
+	Attempts  []Attempt
+	Delivered int // Index of the target that succeeded, or -1
+}
+
+// Succeeded reports whether any target accepted the notification
+func (r *Report) Succeeded() bool {
+	return r.Delivered >= 0
+}
+
+// Deliver tries each target in order until one succeeds. With a positive
+// budget, the remaining time is split evenly across the targets still to be
+// tried so a slow primary can't starve its fallbacks; each attempt is also
+// capped at maxTimeout. Each attempt's ctx carries that share as its deadline,
+// so a target that would wait past it (such as for a rate limit) gives up and
+// the next one is tried. Once ctx is cancelled no further targets are tried.
+func Deliver(ctx context.Context, targets []string, budget, maxTimeout time.Duration, send SendFunc) (*Report, error) {
+	report := &Report{Delivered: -1}
+	if len(targets) == 0 {
+		return report, ErrNoTargets
+	}
+
+	start := time.Now()
+	for i, target := range targets {
+		if err := ctx.Err(); err != nil {
+			return report, err
+		}
+
+		timeout := maxTimeout
+		if budget > 0 {
+			remaining := budget - time.Since(start)
+			if remaining < MinAttemptTimeout {
+				return report, &failedError{
+					message: fmt.Sprintf("%v after %d of %d targets: %s", ErrBudgetExhausted, i, len(targets), report.errorSummary()),
+					errs:    append([]error{ErrBudgetExhausted}, report.errors()...),
+				}
+			}
+			if share := remaining / time.Duration(len(targets)-i); share < timeout {
+				timeout = max(share, MinAttemptTimeout)
+			}
+		}
+
+		attemptCtx, cancel := ctx, context.CancelFunc(func() {})
+		if budget > 0 {
+			attemptCtx, cancel = context.WithTimeout(ctx, timeout)
+		}
+		attemptStart := time.Now()
+		err := send(attemptCtx, target, timeout)
+		cancel()
+		report.Attempts = append(report.Attempts, Attempt{
+			Target:  target,
+			Err:     err,
+			Elapsed: time.Since(attemptStart),
+		})
+		if err == nil {
+			report.Delivered = i
+			return report, nil
+		}
+		if ctx.Err() != nil {
+			return report, err
+		}
+	}
+
+	return report, &failedError{
+		message: fmt.Sprintf("all %d targets failed: %s", len(targets), report.errorSummary()),
+		errs:    report.errors(),
+	}
+}
+
+// failedError is returned when every target failed or the budget ran out.
+// It unwraps to each target's error, so callers can still tell what went
+// wrong.
+type failedError struct {
+	message string
+	errs    []error
+}
+
+func (e *failedError) Error() string { return e.message }
+
+func (e *failedError) Unwrap() []error { return e.errs }
+
+func (r *Report) errors() []error {
+	var errs []error
+	for _, attempt := range r.Attempts {
+		if attempt.Err != nil {
+			errs = append(errs, attempt.Err)
+		}
+	}
+	return errs
+}
+
+func (r *Report) errorSummary() string {
+	var parts []string
+	for i, attempt := range r.Attempts {
+		if attempt.Err != nil {
+			parts = append(parts, fmt.Sprintf("target %d: %v", i+1, attempt.Err))
+		}
+	}
+	if len(parts) == 0 {
+		return "no attempts made"
+	}
+	return strings.Join(parts, "; ")
+}
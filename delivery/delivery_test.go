@@ -0,0 +1,169 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestDeliverFailover(t *testing.T) {
+	var tried []string
+	send := func(_ context.Context, target string, timeout time.Duration) error {
+		tried = append(tried, target)
+		if target == "primary" {
+			return errors.New("connection refused")
+		}
+		return nil
+	}
+
+	report, err := Deliver(context.Background(), []string{"primary", "secondary", "tertiary"}, 0, time.Second, send)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !report.Succeeded() || report.Delivered != 1 {
+		t.Errorf("Expected delivery by target index 1, got %d", report.Delivered)
+	}
+	if len(tried) != 2 {
+		t.Errorf("Expected to stop after the secondary succeeded, tried %v", tried)
+	}
+	if len(report.Attempts) != 2 || report.Attempts[0].Err == nil || report.Attempts[1].Err != nil {
+		t.Errorf("Unexpected attempts: %+v", report.Attempts)
+	}
+}
+
+func TestDeliverAllFail(t *testing.T) {
+	errB := errors.New("b down")
+	send := func(_ context.Context, target string, timeout time.Duration) error {
+		if target == "b" {
+			return errB
+		}
+		return errors.New(target + " down")
+	}
+
+	report, err := Deliver(context.Background(), []string{"a", "b"}, 0, time.Second, send)
+	if err == nil {
+		t.Fatal("Expected error when every target fails")
+	}
+	if report.Succeeded() {
+		t.Error("Report should not be successful")
+	}
+	expected := "all 2 targets failed: target 1: a down; target 2: b down"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+	if !errors.Is(err, errB) {
+		t.Error("Expected the error to wrap each target's error")
+	}
+}
+
+func TestDeliverNoTargets(t *testing.T) {
+	_, err := Deliver(context.Background(), nil, 0, time.Second, func(context.Context, string, time.Duration) error { return nil })
+	if !errors.Is(err, ErrNoTargets) {
+		t.Errorf("Expected ErrNoTargets, got %v", err)
+	}
+}
+
+func TestDeliverBudget(t *testing.T) {
+	budget := 3 * time.Second
+
+	var timeouts []time.Duration
+	send := func(_ context.Context, target string, timeout time.Duration) error {
+		timeouts = append(timeouts, timeout)
+		return errors.New("down")
+	}
+
+	_, err := Deliver(context.Background(), []string{"a", "b", "c"}, budget, 10*time.Second, send)
+	if err == nil {
+		t.Fatal("Expected error when every target fails")
+	}
+
+	// The budget is split across the targets instead of each getting maxTimeout
+	if len(timeouts) != 3 {
+		t.Fatalf("Expected 3 attempts, got %d", len(timeouts))
+	}
+	for i, timeout := range timeouts {
+		share := budget / time.Duration(len(timeouts)-i)
+		if timeout > share {
+			t.Errorf("Expected target %d to get at most %v of the remaining budget, got %v", i+1, share, timeout)
+		}
+	}
+
+	// Timeouts are still capped by maxTimeout when the budget is generous
+	timeouts = nil
+	Deliver(context.Background(), []string{"a"}, time.Hour, time.Second, send)
+	if timeouts[0] != time.Second {
+		t.Errorf("Expected timeout capped at 1s, got %v", timeouts[0])
+	}
+}
+
+func TestDeliverBudgetExhausted(t *testing.T) {
+	errTimeout := errors.New("timeout")
+	var tried int
+	send := func(_ context.Context, target string, timeout time.Duration) error {
+		tried++
+		// A slow failure that eats the whole budget
+		time.Sleep(timeout)
+		return errTimeout
+	}
+
+	start := time.Now()
+	_, err := Deliver(context.Background(), []string{"a", "b"}, MinAttemptTimeout+100*time.Millisecond, time.Minute, send)
+	if !errors.Is(err, ErrBudgetExhausted) {
+		t.Errorf("Expected ErrBudgetExhausted, got %v", err)
+	}
+	if tried != 1 {
+		t.Errorf("Expected the second target to be skipped, tried %d", tried)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected delivery to respect the budget, took %v", elapsed)
+	}
+	if !errors.Is(err, errTimeout) {
+		t.Error("Expected the error to wrap the attempt's error")
+	}
+}
+
+func TestDeliverAttemptDeadline(t *testing.T) {
+	send := func(ctx context.Context, target string, timeout time.Duration) error {
+		if target == "primary" {
+			// A target stuck waiting, such as on a long rate limit
+			<-ctx.Done()
+			return ctx.Err()
+		}
+		return nil
+	}
+
+	start := time.Now()
+	report, err := Deliver(context.Background(), []string{"primary", "fallback"}, 2*time.Second, time.Minute, send)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if report.Delivered != 1 {
+		t.Errorf("Expected delivery by the fallback, got %d", report.Delivered)
+	}
+	if !errors.Is(report.Attempts[0].Err, context.DeadlineExceeded) {
+		t.Errorf("Expected the primary's attempt to hit its deadline, got %v", report.Attempts[0].Err)
+	}
+	if elapsed := time.Since(start); elapsed > 1500*time.Millisecond {
+		t.Errorf("Expected the primary to get its share of the budget, took %v", elapsed)
+	}
+}
+
+func TestDeliverCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	var tried []string
+	send := func(_ context.Context, target string, timeout time.Duration) error {
+		tried = append(tried, target)
+		cancel()
+		return context.Canceled
+	}
+
+	_, err := Deliver(ctx, []string{"a", "b"}, 0, time.Second, send)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(tried) != 1 {
+		t.Errorf("Expected no fallback after cancellation, tried %v", tried)
+	}
+}
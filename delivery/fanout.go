@@ -0,0 +1,69 @@
+package delivery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// DefaultWorkers bounds how many targets a fan-out sends to at once
+const DefaultWorkers = 4
+
+// FanOutFunc delivers to the target at index i of a fan-out
+type FanOutFunc func(ctx context.Context, i int, target string) error
+
+// FanOut sends to every target concurrently, at most workers at a time, and
+// returns one attempt per target in the order given. Targets not yet started
+// when ctx is cancelled fail with ctx's error.
+func FanOut(ctx context.Context, targets []string, workers int, send FanOutFunc) []Attempt {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+
+	attempts := make([]Attempt, len(targets))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(workers, len(targets)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				attempts[i] = attempt(ctx, i, targets[i], send)
+			}
+		}()
+	}
+
+	for i := range targets {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return attempts
+}
+
+func attempt(ctx context.Context, i int, target string, send FanOutFunc) Attempt {
+	if err := ctx.Err(); err != nil {
+		return Attempt{Target: target, Err: err}
+	}
+	start := time.Now()
+	err := send(ctx, i, target)
+	return Attempt{Target: target, Err: err, Elapsed: time.Since(start)}
+}
+
+// FanOutError summarizes the failed attempts of a fan-out, or returns nil if
+// every target succeeded
+func FanOutError(attempts []Attempt) error {
+	report := &Report{Attempts: attempts}
+	failed := 0
+	for _, a := range attempts {
+		if a.Err != nil {
+			failed++
+		}
+	}
+	if failed == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d of %d targets failed: %s", failed, len(attempts), report.errorSummary())
+}
@@ -0,0 +1,78 @@
+package delivery
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFanOut(t *testing.T) {
+	var running, peak atomic.Int32
+	send := func(_ context.Context, i int, target string) error {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+		running.Add(-1)
+
+		if target == "ops" {
+			return errors.New("ops down")
+		}
+		return nil
+	}
+
+	targets := []string{"team", "ops", "dev", "qa", "audit"}
+	attempts := FanOut(context.Background(), targets, 2, send)
+
+	if len(attempts) != len(targets) {
+		t.Fatalf("Expected %d attempts, got %d", len(targets), len(attempts))
+	}
+	for i, attempt := range attempts {
+		if attempt.Target != targets[i] {
+			t.Errorf("Expected attempt %d for %q, got %q", i, targets[i], attempt.Target)
+		}
+		if (attempt.Err != nil) != (attempt.Target == "ops") {
+			t.Errorf("Unexpected error for %q: %v", attempt.Target, attempt.Err)
+		}
+	}
+	if got := peak.Load(); got > 2 {
+		t.Errorf("Expected at most 2 concurrent sends, got %d", got)
+	}
+
+	expected := "1 of 5 targets failed: target 2: ops down"
+	if err := FanOutError(attempts); err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
+
+func TestFanOutAllSucceed(t *testing.T) {
+	attempts := FanOut(context.Background(), []string{"a", "b"}, 0, func(context.Context, int, string) error { return nil })
+	if err := FanOutError(attempts); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+}
+
+func TestFanOutCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var sent atomic.Int32
+	attempts := FanOut(ctx, []string{"a", "b"}, 1, func(context.Context, int, string) error {
+		sent.Add(1)
+		return nil
+	})
+	if sent.Load() != 0 {
+		t.Errorf("Expected nothing sent after cancellation, sent %d", sent.Load())
+	}
+	for _, attempt := range attempts {
+		if !errors.Is(attempt.Err, context.Canceled) {
+			t.Errorf("Expected context.Canceled for %q, got %v", attempt.Target, attempt.Err)
+		}
+	}
+}
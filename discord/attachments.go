@@ -0,0 +1,104 @@
+package discord
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+
+	"github.com/yashikota/owata/webhook"
+)
+
+// MaxAttachments is the number of files Discord accepts on a single message
+const MaxAttachments = 10
+
+// validateAttachments checks that every attachment is an existing regular
+// file so problems are reported before any request is made
+func validateAttachments(paths []string) error {
+	if len(paths) > MaxAttachments {
+		return fmt.Errorf("too many attachments: %d (Discord allows at most %d)", len(paths), MaxAttachments)
+	}
+
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				return fmt.Errorf("attachment not found: %s", path)
+			}
+			return fmt.Errorf("cannot access attachment %s: %w", path, err)
+		}
+		if !info.Mode().IsRegular() {
+			return fmt.Errorf("attachment is not a regular file: %s", path)
+		}
+	}
+
+	return nil
+}
+
+// newWebhookRequest builds the POST request for a payload, switching from a
+// plain JSON body to multipart/form-data when files are attached
+func newWebhookRequest(ctx context.Context, webhookURL string, jsonData []byte, attachments []string) (*http.Request, error) {
+	if len(attachments) == 0 {
+		req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, bytes.NewBuffer(jsonData))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", webhook.RedactError(err))
+		}
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
+	}
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+
+	// The JSON payload goes in its own part alongside the files
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", `form-data; name="payload_json"`)
+	header.Set("Content-Type", "application/json")
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return nil, fmt.Errorf("error creating payload part: %v", err)
+	}
+	if _, err := part.Write(jsonData); err != nil {
+		return nil, fmt.Errorf("error writing payload part: %v", err)
+	}
+
+	for i, path := range attachments {
+		if err := writeAttachmentPart(writer, fmt.Sprintf("files[%d]", i), path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error finalizing multipart body: %v", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", webhookURL, body)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", webhook.RedactError(err))
+	}
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	return req, nil
+}
+
+func writeAttachmentPart(writer *multipart.Writer, fieldName, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open attachment: %w", err)
+	}
+	defer file.Close()
+
+	part, err := writer.CreateFormFile(fieldName, filepath.Base(path))
+	if err != nil {
+		return fmt.Errorf("error creating attachment part: %v", err)
+	}
+	if _, err := io.Copy(part, file); err != nil {
+		return fmt.Errorf("failed to read attachment %s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,115 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestSendNotificationWithAttachments(t *testing.T) {
+	dir := t.TempDir()
+	logPath := filepath.Join(dir, "build.log")
+	if err := os.WriteFile(logPath, []byte("error: undefined: foo\n"), 0644); err != nil {
+		t.Fatalf("Failed to write attachment: %v", err)
+	}
+	notesPath := filepath.Join(dir, "notes.txt")
+	if err := os.WriteFile(notesPath, []byte("notes"), 0644); err != nil {
+		t.Fatalf("Failed to write attachment: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+			t.Errorf("Expected multipart/form-data, got %s", r.Header.Get("Content-Type"))
+		}
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("Failed to parse multipart form: %v", err)
+		}
+
+		var payload Webhook
+		if err := json.Unmarshal([]byte(r.FormValue("payload_json")), &payload); err != nil {
+			t.Errorf("Failed to decode payload_json: %v", err)
+		}
+		if len(payload.Embeds) != 1 || payload.Embeds[0].Description != "Compile failed" {
+			t.Errorf("Unexpected payload: %+v", payload)
+		}
+
+		expected := map[string]string{
+			"files[0]": "build.log",
+			"files[1]": "notes.txt",
+		}
+		for field, name := range expected {
+			file, header, err := r.FormFile(field)
+			if err != nil {
+				t.Errorf("Missing %s: %v", field, err)
+				continue
+			}
+			if header.Filename != name {
+				t.Errorf("Expected %s filename %s, got %s", field, name, header.Filename)
+			}
+			file.Close()
+		}
+
+		file, _, err := r.FormFile("files[0]")
+		if err == nil {
+			data, _ := io.ReadAll(file)
+			if string(data) != "error: undefined: foo\n" {
+				t.Errorf("Unexpected attachment content: %q", data)
+			}
+			file.Close()
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	n := notifier.Notification{Message: "Compile failed", Source: "Test", Attachments: []string{logPath, notesPath}}
+	if _, err := SendNotification(context.Background(), server.URL, n, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestAttachmentValidation(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "ok.txt")
+	if err := os.WriteFile(existing, []byte("ok"), 0644); err != nil {
+		t.Fatalf("Failed to write attachment: %v", err)
+	}
+
+	tooMany := make([]string, MaxAttachments+1)
+	for i := range tooMany {
+		tooMany[i] = existing
+	}
+
+	tests := []struct {
+		name        string
+		attachments []string
+		errContains string
+	}{
+		{name: "Missing file", attachments: []string{filepath.Join(dir, "missing.log")}, errContains: "attachment not found"},
+		{name: "Directory", attachments: []string{dir}, errContains: "not a regular file"},
+		{name: "Too many files", attachments: tooMany, errContains: fmt.Sprintf("at most %d", MaxAttachments)},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Request should not be sent when attachments are invalid")
+	}))
+	defer server.Close()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "msg", Attachments: tt.attachments}, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,27 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+// Client talks to a single Discord webhook, sending through a webhook.Client
+// so its connections are reused across requests
+type Client struct {
+	*webhook.Client
+}
+
+// NewClient returns a Client for webhookURL. A nil httpClient uses the default
+// transport; pass your own for custom TLS, instrumentation or test doubles.
+func NewClient(webhookURL string, httpClient *http.Client) *Client {
+	return &Client{webhook.NewClient(webhookURL, httpClient)}
+}
+
+// Send sends a notification with the default delivery options
+func (c *Client) Send(ctx context.Context, n notifier.Notification, cfg *config.Config) (*notifier.Result, error) {
+	return c.SendWithOptions(ctx, n, cfg, webhook.DefaultSendOptions())
+}
@@ -0,0 +1,75 @@
+package discord
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// roundTripFunc lets a test stand in for the network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func stubResponse(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Header:     http.Header{"Content-Type": []string{"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(body)),
+	}
+}
+
+func TestClientCustomHTTPClient(t *testing.T) {
+	var requests []*http.Request
+	httpClient := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r)
+		switch r.Method {
+		case "POST":
+			return stubResponse(http.StatusOK, `{"id": "1234567890", "channel_id": "42"}`), nil
+		case "GET":
+			return stubResponse(http.StatusOK, `{"name": "Hook", "channel_id": "42"}`), nil
+		default:
+			return stubResponse(http.StatusNoContent, ""), nil
+		}
+	})}
+
+	client := NewClient("https://discord.test/api/webhooks/1/token", httpClient)
+	ctx := context.Background()
+
+	result, err := client.Send(ctx, notifier.Notification{Message: "msg"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.MessageID != "1234567890" {
+		t.Errorf("Unexpected result: %+v", result)
+	}
+
+	info, err := client.WebhookInfo(ctx)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.Name != "Hook" {
+		t.Errorf("Unexpected webhook info: %+v", info)
+	}
+
+	if err := client.Delete(ctx, "1234567890", ""); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Every request goes through the injected transport
+	if len(requests) != 3 {
+		t.Fatalf("Expected 3 requests, got %d", len(requests))
+	}
+	if got := requests[2].URL.Path; got != "/api/webhooks/1/token/messages/1234567890" {
+		t.Errorf("Unexpected delete path %q", got)
+	}
+	if httpClient.Timeout != 0 {
+		t.Errorf("Expected the caller's client to be left untouched, got timeout %v", httpClient.Timeout)
+	}
+}
@@ -0,0 +1,38 @@
+package discord
+
+import (
+	"strings"
+	"unicode/utf8"
+)
+
+// zeroWidthSpace separates backticks so they can't close a code block
+const zeroWidthSpace = "\u200b"
+
+// codeFence opens and closes the code block a message is wrapped in
+func codeFence(lang string) (open, close string) {
+	return "```" + lang + "\n", "\n```"
+}
+
+// codeFenceLength is how many characters the fences add to a message
+func codeFenceLength(lang string) int {
+	open, close := codeFence(lang)
+	return utf8.RuneCountInString(open) + utf8.RuneCountInString(close)
+}
+
+// escapeCodeBlock breaks up runs of three or more backticks, which would
+// otherwise end the code block early, with zero-width spaces
+func escapeCodeBlock(message string) string {
+	return strings.ReplaceAll(message, "```", "`"+zeroWidthSpace+"`"+zeroWidthSpace+"`")
+}
+
+// wrapCodeBlock fences an already escaped message as a code block
+func wrapCodeBlock(message, lang string) string {
+	open, close := codeFence(lang)
+	return open + message + close
+}
+
+// CodeBlock fences text as a code block, escaping backticks in it that would
+// end the block early
+func CodeBlock(text, lang string) string {
+	return wrapCodeBlock(escapeCodeBlock(text), lang)
+}
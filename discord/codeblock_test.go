@@ -0,0 +1,80 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestEscapeCodeBlock(t *testing.T) {
+	tests := []struct {
+		name    string
+		message string
+	}{
+		{"No backticks", "plain output"},
+		{"Inline code", "call `main` now"},
+		{"Triple backticks", "before\n```go\nfmt.Println()\n```\nafter"},
+		{"Long run", "``````"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			escaped := escapeCodeBlock(tt.message)
+			if strings.Contains(escaped, "```") {
+				t.Errorf("Expected no triple backticks, got %q", escaped)
+			}
+			if strings.ReplaceAll(escaped, zeroWidthSpace, "") != tt.message {
+				t.Errorf("Expected only zero-width spaces to be added, got %q", escaped)
+			}
+		})
+	}
+}
+
+func TestCodeBlock(t *testing.T) {
+	got := CodeBlock("x := \"```\"", "go")
+	if !strings.HasPrefix(got, "```go\n") || !strings.HasSuffix(got, "\n```") || strings.Count(got, "```") != 2 {
+		t.Errorf("Unexpected code block %q", got)
+	}
+}
+
+func TestBuildPayloadsCodeBlock(t *testing.T) {
+	webhooks, err := BuildPayloads(notifier.Notification{Message: "x := \"```\"", Code: true, Lang: "go"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	description := webhooks[0].Embeds[0].Description
+	if !strings.HasPrefix(description, "```go\n") || !strings.HasSuffix(description, "\n```") || strings.Count(description, "```") != 2 {
+		t.Errorf("Unexpected code block %q", description)
+	}
+}
+
+func TestBuildPayloadsCodeBlockSplit(t *testing.T) {
+	// Fits the limit on its own, but not once fenced
+	message := strings.Repeat("a", MaxDescriptionLength-5) + "\n```"
+
+	webhooks, err := BuildPayloads(notifier.Notification{Message: message, Code: true, Lang: "go"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(webhooks) != 2 {
+		t.Fatalf("Expected the fences to push the message into 2 parts, got %d", len(webhooks))
+	}
+	for i, webhook := range webhooks {
+		description := webhook.Embeds[0].Description
+		if length := utf8.RuneCountInString(description); length > MaxDescriptionLength {
+			t.Errorf("Part %d is %d characters long, over the limit", i+1, length)
+		}
+		if !strings.HasPrefix(description, "```go\n") || !strings.HasSuffix(description, "\n```") || strings.Count(description, "```") != 2 {
+			t.Errorf("Expected part %d to be its own code block, got %q…", i+1, description[:20])
+		}
+	}
+}
+
+func TestCodeBlockOtherProviders(t *testing.T) {
+	n := notifier.Notification{Message: "msg", Code: true}
+	if got := n.DiscordOnly(); got != "code blocks" {
+		t.Errorf("Expected code blocks to be Discord-only, got %q", got)
+	}
+}
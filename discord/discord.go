@@ -2,132 +2,438 @@ package discord
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
 )
 
-const DefaultColor = 3447003 // Blue color
+const (
+	// FlagSuppressEmbeds stops Discord from unfurling links in the message
+	FlagSuppressEmbeds = 1 << 2
+
+	// FlagSuppressNotifications posts the message without a push or desktop
+	// notification
+	FlagSuppressNotifications = 1 << 12
+)
 
 // Webhook represents the Discord webhook payload
 type Webhook struct {
-	Username  string  `json:"username,omitempty"`
-	AvatarURL string  `json:"avatar_url,omitempty"`
-	Embeds    []Embed `json:"embeds"`
+	Content         string           `json:"content,omitempty"`
+	Username        string           `json:"username,omitempty"`
+	AvatarURL       string           `json:"avatar_url,omitempty"`
+	Embeds          []Embed          `json:"embeds,omitempty"`
+	ThreadName      string           `json:"thread_name,omitempty"`
+	AllowedMentions *AllowedMentions `json:"allowed_mentions,omitempty"`
+	TTS             bool             `json:"tts,omitempty"`
+	Flags           int              `json:"flags,omitempty"`
 }
 
 // Embed represents a Discord embed message
 type Embed struct {
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Color       int       `json:"color"`
-	Timestamp   time.Time `json:"timestamp"`
-	Fields      []Field   `json:"fields"`
-	Footer      Footer    `json:"footer"`
+	Title       string           `json:"title"`
+	Description string           `json:"description"`
+	Color       int              `json:"color"`
+	Timestamp   time.Time        `json:"timestamp"`
+	Fields      []notifier.Field `json:"fields"`
+	Footer      notifier.Footer  `json:"footer,omitzero"`
+	Thumbnail   *Thumbnail       `json:"thumbnail,omitempty"`
+	Author      *notifier.Author `json:"author,omitempty"`
+}
+
+// Thumbnail represents the small image in the corner of a Discord embed
+type Thumbnail struct {
+	URL string `json:"url"`
 }
 
-// Field represents a field in a Discord embed
-type Field struct {
-	Name   string `json:"name"`
-	Value  string `json:"value"`
-	Inline bool   `json:"inline"`
+// WebhookInfo represents the metadata Discord returns for a webhook
+type WebhookInfo struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Avatar    string `json:"avatar"`
+	ChannelID string `json:"channel_id"`
+	GuildID   string `json:"guild_id"`
 }
 
-// Footer represents the footer of a Discord embed
-type Footer struct {
-	Text string `json:"text"`
+// AvatarURL returns the CDN URL of the webhook's avatar, or "" if it has none
+func (w *WebhookInfo) AvatarURL() string {
+	if w.Avatar == "" {
+		return ""
+	}
+	return fmt.Sprintf("https://cdn.discordapp.com/avatars/%s/%s.png", w.ID, w.Avatar)
 }
 
-// SendNotification sends a notification to a Discord webhook
-func SendNotification(webhookURL, message, source string, cfg *config.Config) error {
-	// Set default values
-	username := config.DefaultUsername
-	var avatarURL string
+// part is a notification as sent in a single Discord message
+type part struct {
+	notifier.Notification
+	partial bool // A part of a split message before the last, which carries the extras
+}
 
-	// Override with config values if available
-	if cfg != nil {
-		if cfg.Username != "" {
-			username = cfg.Username
-		}
-		if cfg.AvatarURL != "" {
-			avatarURL = cfg.AvatarURL
-		}
+// SendNotification sends a notification to a Discord webhook. Cancelling ctx
+// aborts the request and any wait between retries.
+func SendNotification(ctx context.Context, webhookURL string, n notifier.Notification, cfg *config.Config) (*notifier.Result, error) {
+	return NewClient(webhookURL, nil).Send(ctx, n, cfg)
+}
+
+// SendNotificationWithOptions sends a notification with explicit delivery
+// options; see Client.SendWithOptions
+func SendNotificationWithOptions(ctx context.Context, webhookURL string, n notifier.Notification, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	return NewClient(webhookURL, nil).SendWithOptions(ctx, n, cfg, opts)
+}
+
+// sendMessage sends a notification as a single webhook message
+func (c *Client) sendMessage(ctx context.Context, p part, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	if err := validateAttachments(p.Attachments); err != nil {
+		return nil, err
 	}
 
-	// Get current working directory
-	cwd, err := os.Getwd()
+	payload, threadID, err := buildWebhook(p, cfg, nowFunc())
 	if err != nil {
-		cwd = "Unknown"
+		return nil, err
 	}
 
-	// Create the Discord embed
-	embed := Embed{
-		Title:       "🔔 Notification",
-		Description: message,
-		Color:       DefaultColor,
-		Timestamp:   time.Now(),
-		Fields: []Field{
-			{
-				Name:   "Working Directory",
-				Value:  cwd,
-				Inline: false,
-			},
-			{
-				Name:   "Source",
-				Value:  source,
-				Inline: true,
-			},
-		},
-		Footer: Footer{
-			Text: "Owata",
-		},
-	}
-
-	webhook := Webhook{
-		Username:  username,
-		AvatarURL: avatarURL,
-		Embeds:    []Embed{embed},
+	webhookURL := c.URL()
+	if threadID != "" {
+		threadURL, err := withQuery(webhookURL, "thread_id", threadID)
+		if err != nil {
+			return nil, err
+		}
+		webhookURL = threadURL
+	}
+	if !p.Discord.NoWait {
+		waitURL, err := withQuery(webhookURL, "wait", "true")
+		if err != nil {
+			return nil, err
+		}
+		webhookURL = waitURL
 	}
 
 	// Marshal the webhook payload
-	jsonData, err := json.Marshal(webhook)
+	jsonData, err := json.Marshal(payload)
 	if err != nil {
-		return fmt.Errorf("error marshaling webhook data: %v", err)
+		return nil, fmt.Errorf("error marshaling webhook data: %v", err)
 	}
 
-	// Create HTTP client with timeout to prevent hanging requests
-	client := &http.Client{
-		Timeout: 10 * time.Second,
+	// Send the webhook request within the timeout, waiting out rate limits
+	// and transient errors
+	start := time.Now()
+	resp, attempts, err := c.Do(ctx, func() (*http.Request, error) {
+		return newWebhookRequest(ctx, webhookURL, jsonData, p.Attachments)
+	}, opts)
+	if err != nil {
+		return nil, webhook.WithAttempts(err, attempts)
+	}
+	defer resp.Body.Close()
+
+	// Check the response status
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, webhook.WithAttempts(responseError(resp), attempts)
 	}
 
-	// Create request
-	req, err := http.NewRequest("POST", webhookURL, bytes.NewBuffer(jsonData))
+	result, err := decodeResult(resp)
 	if err != nil {
-		return fmt.Errorf("error creating request: %v", err)
+		return nil, err
 	}
-	req.Header.Set("Content-Type", "application/json")
+	result.Attempts = attempts
+	result.Elapsed = time.Since(start)
+	return result, nil
+}
 
-	// Send the webhook request
-	resp, err := client.Do(req)
+// BuildWebhook creates the payload posted for a notification that fits in a
+// single message, checked against Discord's limits
+func BuildWebhook(n notifier.Notification, cfg *config.Config) (Webhook, error) {
+	payload, _, err := buildWebhook(part{Notification: n}, cfg, nowFunc())
+	return payload, err
+}
+
+// buildWebhook creates the payload for a message along with the ID of the
+// thread it is posted into, if any
+func buildWebhook(p part, cfg *config.Config, now time.Time) (Webhook, string, error) {
+	username, avatarURL := notifier.ResolveIdentity(cfg)
+
+	threadID, threadName, err := resolveThread(p.Notification, cfg)
 	if err != nil {
-		return fmt.Errorf("error sending webhook: %v", err)
+		return Webhook{}, "", err
 	}
-	defer resp.Body.Close()
 
-	// Check the response status
-	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+	content, allowedMentions := buildMentions(p.Discord.Mentions)
+
+	payload := Webhook{
+		Content:         content,
+		Username:        username,
+		AvatarURL:       avatarURL,
+		ThreadName:      threadName,
+		AllowedMentions: allowedMentions,
+		TTS:             p.Discord.TTS,
+	}
+
+	if messageIsContent(p.Notification) {
+		// Bots parsing the channel read the message itself as the content
+		payload.Content = strings.TrimSpace(content + " " + p.Message)
+	}
+	if len(p.Discord.Embeds) > 0 {
+		embeds, err := ParseEmbeds(p.Discord.Embeds)
+		if err != nil {
+			return Webhook{}, "", err
+		}
+		payload.Embeds = customEmbeds(embeds, now)
+	} else if !p.Plain {
+		if p.partial {
+			// The configured fields go with the last part
+			cfg = withoutFields(cfg)
+		}
+		embed, err := BuildEmbed(p.Notification, cfg)
+		if err != nil {
+			return Webhook{}, "", err
+		}
+		payload.Embeds = []Embed{embed}
+	}
+
+	if len(payload.Embeds) == 0 && (p.SuppressEmbeds || (cfg != nil && cfg.SuppressEmbeds)) {
+		payload.Flags |= FlagSuppressEmbeds
+	}
+	if (p.Silent || (cfg != nil && cfg.Silent)) && !p.Loud {
+		payload.Flags |= FlagSuppressNotifications
+	}
+
+	// Catch what Discord would reject before making any request
+	if err := payload.Validate(); err != nil {
+		return Webhook{}, "", err
+	}
+
+	return payload, threadID, nil
+}
+
+// withoutFields returns a copy of cfg without the fields of its embed section
+func withoutFields(cfg *config.Config) *config.Config {
+	if cfg == nil {
 		return nil
 	}
+	c := *cfg
+	c.Embed.Fields = nil
+	return &c
+}
+
+// For testing purposes
+var nowFunc = time.Now
+
+// BuildEmbed creates the embed describing a notification, filling in the
+// appearance from the notification and then the config
+func BuildEmbed(n notifier.Notification, cfg *config.Config) (Embed, error) {
+	card, err := notifier.BuildCard(n, cfg)
+	if err != nil {
+		return Embed{}, err
+	}
+	embed := Embed{
+		Title:       card.Title,
+		Description: card.Message,
+		Color:       card.Color,
+		Timestamp:   card.Timestamp,
+		Fields:      card.Fields,
+		Footer:      card.Footer,
+		Author:      card.Author,
+	}
+	if n.Discord.TTS {
+		// Discord only reads content aloud, so the message lives there
+		embed.Description = ""
+	}
+	if card.Thumbnail != "" {
+		embed.Thumbnail = &Thumbnail{URL: card.Thumbnail}
+	}
+	return embed, nil
+}
+
+// messageIsContent reports whether the message is sent as message content
+// rather than as the generated embed's description
+func messageIsContent(n notifier.Notification) bool {
+	return n.Plain || len(n.Discord.Embeds) > 0 || n.Discord.TTS
+}
+
+// decodeResult extracts the message details Discord returns with wait=true.
+// Without wait Discord replies 204 with no body, giving an empty result.
+func decodeResult(resp *http.Response) (*notifier.Result, error) {
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return &notifier.Result{StatusCode: resp.StatusCode}, nil
+	}
 
+	var message struct {
+		ID        string `json:"id"`
+		ChannelID string `json:"channel_id"`
+	}
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, fmt.Errorf("failed to parse Discord response: %v", err)
+	}
+
+	return &notifier.Result{
+		MessageID:  message.ID,
+		ChannelID:  message.ChannelID,
+		StatusCode: resp.StatusCode,
+	}, nil
+}
+
+// resolveThread picks the thread to post into or the forum post to create.
+// Command-line values override the config, and a thread ID and thread name
+// can't both apply since one targets an existing thread and the other
+// creates a new one.
+func resolveThread(n notifier.Notification, cfg *config.Config) (string, string, error) {
+	if n.Discord.ThreadID != "" && n.Discord.ThreadName != "" {
+		return "", "", fmt.Errorf("a thread ID and a thread name cannot be used together")
+	}
+
+	threadID, threadName := n.Discord.ThreadID, n.Discord.ThreadName
+	if threadID == "" && threadName == "" && cfg != nil {
+		if cfg.ThreadID != "" && cfg.ThreadNameTemplate != "" {
+			return "", "", fmt.Errorf("config sets both thread_id and thread_name_template; keep only one")
+		}
+		threadID = cfg.ThreadID
+		if cfg.ThreadNameTemplate != "" {
+			name, err := n.ExpandConfig("thread name", cfg.ThreadNameTemplate)
+			if err != nil {
+				return "", "", err
+			}
+			threadName = name
+		}
+	}
+
+	if threadID != "" && !isSnowflake(threadID) {
+		return "", "", fmt.Errorf("invalid thread ID %q: must be a numeric Discord ID", threadID)
+	}
+
+	return threadID, threadName, nil
+}
+
+// apiError is the error body Discord returns for rejected requests
+type apiError struct {
+	Message string `json:"message"`
+	Code    int    `json:"code"`
+}
+
+// responseError builds an error for a non-2xx response, using Discord's
+// error message when the body contains one
+func responseError(resp *http.Response) error {
 	// Read response body for better error messages
 	body, readErr := io.ReadAll(resp.Body)
 	if readErr != nil {
-		return fmt.Errorf("discord webhook returned status %d, but failed to read response body: %v", resp.StatusCode, readErr)
+		return &webhook.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("discord webhook returned status %d, but failed to read response body: %v", resp.StatusCode, readErr)}
+	}
+
+	var apiErr apiError
+	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Message != "" {
+		if apiErr.Code != 0 {
+			return &webhook.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("discord webhook returned status %d: %s (code %d)", resp.StatusCode, apiErr.Message, apiErr.Code)}
+		}
+		return &webhook.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("discord webhook returned status %d: %s", resp.StatusCode, apiErr.Message)}
 	}
-	return fmt.Errorf("discord webhook returned status: %d, body: %s", resp.StatusCode, string(body))
+	return &webhook.StatusError{StatusCode: resp.StatusCode, Message: fmt.Sprintf("discord webhook returned status: %d, body: %s", resp.StatusCode, string(body))}
+}
+
+// withQuery returns rawURL with the given query parameter set
+func withQuery(rawURL, key, value string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	q := u.Query()
+	q.Set(key, value)
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
+
+// DeleteNotification deletes a message previously sent through the webhook
+func DeleteNotification(ctx context.Context, webhookURL, messageID, threadID string) error {
+	return NewClient(webhookURL, nil).Delete(ctx, messageID, threadID)
+}
+
+// Delete deletes a message previously sent through the webhook
+func (c *Client) Delete(ctx context.Context, messageID, threadID string) error {
+	if !isSnowflake(messageID) {
+		return fmt.Errorf("invalid message ID %q: must be a numeric Discord ID", messageID)
+	}
+
+	u, err := url.Parse(c.URL())
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/messages/" + messageID
+	if threadID != "" {
+		if !isSnowflake(threadID) {
+			return fmt.Errorf("invalid thread ID %q: must be a numeric Discord ID", threadID)
+		}
+		q := u.Query()
+		q.Set("thread_id", threadID)
+		u.RawQuery = q.Encode()
+	}
+
+	client := c.HTTPClient(0)
+
+	req, err := http.NewRequestWithContext(ctx, "DELETE", u.String(), nil)
+	if err != nil {
+		return fmt.Errorf("error creating request: %v", webhook.RedactError(err))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return &webhook.NetworkError{Op: "deleting message", Err: webhook.RedactError(err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return responseError(resp)
+	}
+	return nil
+}
+
+// GetWebhookInfo fetches the webhook's server-side name, avatar and channel
+func GetWebhookInfo(ctx context.Context, webhookURL string) (*WebhookInfo, error) {
+	return NewClient(webhookURL, nil).WebhookInfo(ctx)
+}
+
+// WebhookInfo fetches the webhook's server-side name, avatar and channel
+func (c *Client) WebhookInfo(ctx context.Context) (*WebhookInfo, error) {
+	client := c.HTTPClient(0)
+
+	req, err := http.NewRequestWithContext(ctx, "GET", c.URL(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %v", webhook.RedactError(err))
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		return nil, &webhook.NetworkError{Op: "fetching webhook info", Err: webhook.RedactError(err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return nil, responseError(resp)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read webhook info response: %v", err)
+	}
+
+	var info WebhookInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, fmt.Errorf("failed to parse webhook info: %v", err)
+	}
+
+	return &info, nil
 }
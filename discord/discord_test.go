@@ -1,13 +1,18 @@
 package discord
 
 import (
+	"context"
 	"encoding/json"
 	"io"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/placeholder"
 )
 
 // Mock HTTP server for testing webhook requests
@@ -52,6 +57,10 @@ func TestSendNotification(t *testing.T) {
 		name        string
 		message     string
 		source      string
+		title       string
+		thumbnail   string
+		author      notifier.Author
+		footer      notifier.Footer
 		config      *config.Config
 		statusCode  int
 		expectError bool
@@ -69,6 +78,12 @@ func TestSendNotification(t *testing.T) {
 				if payload.AvatarURL != "" {
 					t.Errorf("Expected empty avatar URL, got %s", payload.AvatarURL)
 				}
+				if payload.Content != "" {
+					t.Errorf("Expected no content without mentions, got %q", payload.Content)
+				}
+				if payload.AllowedMentions == nil || len(payload.AllowedMentions.Parse) != 0 {
+					t.Errorf("Expected allowed_mentions to parse nothing, got %+v", payload.AllowedMentions)
+				}
 				if len(payload.Embeds) != 1 {
 					t.Fatalf("Expected 1 embed, got %d", len(payload.Embeds))
 				}
@@ -108,6 +123,150 @@ func TestSendNotification(t *testing.T) {
 				}
 			},
 		},
+		{
+			name:       "Default title",
+			message:    "Test message",
+			source:     "Test",
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				if payload.Embeds[0].Title != notifier.DefaultTitle {
+					t.Errorf("Expected title %q, got %q", notifier.DefaultTitle, payload.Embeds[0].Title)
+				}
+			},
+		},
+		{
+			name:       "Title from config",
+			message:    "Test message",
+			source:     "Test",
+			config:     &config.Config{DefaultTitle: "Nightly backup"},
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				if payload.Embeds[0].Title != "Nightly backup" {
+					t.Errorf("Expected title %q, got %q", "Nightly backup", payload.Embeds[0].Title)
+				}
+			},
+		},
+		{
+			name:       "Title flag overrides config",
+			message:    "Test message",
+			source:     "Test",
+			title:      "Deploy finished",
+			config:     &config.Config{DefaultTitle: "Nightly backup"},
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				if payload.Embeds[0].Title != "Deploy finished" {
+					t.Errorf("Expected title %q, got %q", "Deploy finished", payload.Embeds[0].Title)
+				}
+			},
+		},
+		{
+			name:       "No thumbnail by default",
+			message:    "Test message",
+			source:     "Test",
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				if payload.Embeds[0].Thumbnail != nil {
+					t.Errorf("Expected no thumbnail, got %+v", payload.Embeds[0].Thumbnail)
+				}
+			},
+		},
+		{
+			name:       "Thumbnail from config",
+			message:    "Test message",
+			source:     "Test",
+			config:     &config.Config{ThumbnailURL: "https://example.com/logo.png"},
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				if payload.Embeds[0].Thumbnail == nil || payload.Embeds[0].Thumbnail.URL != "https://example.com/logo.png" {
+					t.Errorf("Expected config thumbnail, got %+v", payload.Embeds[0].Thumbnail)
+				}
+			},
+		},
+		{
+			name:       "Thumbnail flag overrides config",
+			message:    "Test message",
+			source:     "Test",
+			thumbnail:  "https://example.com/other.png",
+			config:     &config.Config{ThumbnailURL: "https://example.com/logo.png"},
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				if payload.Embeds[0].Thumbnail == nil || payload.Embeds[0].Thumbnail.URL != "https://example.com/other.png" {
+					t.Errorf("Expected flag thumbnail, got %+v", payload.Embeds[0].Thumbnail)
+				}
+			},
+		},
+		{
+			name:       "Author from config with flag override",
+			message:    "Test message",
+			source:     "Test",
+			author:     notifier.Author{Name: "alice"},
+			config:     &config.Config{AuthorName: "ci", AuthorIconURL: "https://example.com/ci.png"},
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				expected := notifier.Author{Name: "alice", IconURL: "https://example.com/ci.png"}
+				if payload.Embeds[0].Author == nil || *payload.Embeds[0].Author != expected {
+					t.Errorf("Expected author %+v, got %+v", expected, payload.Embeds[0].Author)
+				}
+			},
+		},
+		{
+			name:       "Footer from config with flag override",
+			message:    "Test message",
+			source:     "Test",
+			footer:     notifier.Footer{Text: "acme-ci • production"},
+			config:     &config.Config{FooterText: "acme-ci", FooterIconURL: "https://example.com/acme.png"},
+			statusCode: http.StatusNoContent,
+			validator: func(payload *Webhook) {
+				expected := notifier.Footer{Text: "acme-ci • production", IconURL: "https://example.com/acme.png"}
+				if payload.Embeds[0].Footer != expected {
+					t.Errorf("Expected footer %+v, got %+v", expected, payload.Embeds[0].Footer)
+				}
+			},
+		},
+		{
+			name:        "Footer too long",
+			message:     "Test message",
+			source:      "Test",
+			footer:      notifier.Footer{Text: strings.Repeat("f", MaxFooterLength+1)},
+			statusCode:  http.StatusNoContent,
+			expectError: true,
+			validator: func(payload *Webhook) {
+				t.Error("Request should not be sent when the footer is too long")
+			},
+		},
+		{
+			name:        "Author icon without name",
+			message:     "Test message",
+			source:      "Test",
+			author:      notifier.Author{IconURL: "https://example.com/alice.png"},
+			statusCode:  http.StatusNoContent,
+			expectError: true,
+			validator: func(payload *Webhook) {
+				t.Error("Request should not be sent without an author name")
+			},
+		},
+		{
+			name:        "Author name too long",
+			message:     "Test message",
+			source:      "Test",
+			author:      notifier.Author{Name: strings.Repeat("a", MaxAuthorNameLength+1)},
+			statusCode:  http.StatusNoContent,
+			expectError: true,
+			validator: func(payload *Webhook) {
+				t.Error("Request should not be sent when the author name is too long")
+			},
+		},
+		{
+			name:        "Title too long",
+			message:     "Test message",
+			source:      "Test",
+			title:       strings.Repeat("a", MaxTitleLength+1),
+			statusCode:  http.StatusNoContent,
+			expectError: true,
+			validator: func(payload *Webhook) {
+				t.Error("Request should not be sent when the title is too long")
+			},
+		},
 		{
 			name:        "Failed notification",
 			message:     "Test message",
@@ -124,7 +283,7 @@ func TestSendNotification(t *testing.T) {
 			defer server.Close()
 
 			// Send notification
-			err := SendNotification(server.URL, tt.message, tt.source, tt.config)
+			_, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: tt.message, Source: tt.source, Title: tt.title, Thumbnail: tt.thumbnail, Author: tt.author, Footer: tt.footer}, tt.config)
 			if tt.expectError {
 				if err == nil {
 					t.Error("Expected error, got nil")
@@ -138,6 +297,363 @@ func TestSendNotification(t *testing.T) {
 	}
 }
 
+func TestUseWebhookDefaults(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  *config.Config
+	}{
+		{"Username and avatar in config", &config.Config{UseWebhookDefaults: true, Username: "CustomUser", AvatarURL: "https://example.com/avatar.png"}},
+		{"Nothing else in config", &config.Config{UseWebhookDefaults: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var raw map[string]json.RawMessage
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+					t.Errorf("Failed to decode request body: %v", err)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			if _, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "Test message", Source: "Test"}, tt.cfg); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			for _, key := range []string{"username", "avatar_url"} {
+				if _, ok := raw[key]; ok {
+					t.Errorf("Expected %q to be absent from the payload, got %s", key, raw[key])
+				}
+			}
+			if _, ok := raw["embeds"]; !ok {
+				t.Error("Expected embeds in the payload")
+			}
+		})
+	}
+}
+
+func TestGetWebhookInfo(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "GET" {
+				t.Errorf("Expected GET request, got %s", r.Method)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id":"123","name":"Deploy Bot","avatar":"abc","channel_id":"456","guild_id":"789"}`))
+		}))
+		defer server.Close()
+
+		info, err := GetWebhookInfo(context.Background(), server.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if info.Name != "Deploy Bot" || info.ChannelID != "456" || info.GuildID != "789" {
+			t.Errorf("Unexpected webhook info: %+v", info)
+		}
+		if got := info.AvatarURL(); got != "https://cdn.discordapp.com/avatars/123/abc.png" {
+			t.Errorf("Unexpected avatar URL: %s", got)
+		}
+	})
+
+	t.Run("No avatar", func(t *testing.T) {
+		info := &WebhookInfo{ID: "123"}
+		if got := info.AvatarURL(); got != "" {
+			t.Errorf("Expected empty avatar URL, got %s", got)
+		}
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+			w.Write([]byte(`{"message": "Unknown Webhook", "code": 10015}`))
+		}))
+		defer server.Close()
+
+		_, err := GetWebhookInfo(context.Background(), server.URL)
+		if err == nil || !strings.Contains(err.Error(), "Unknown Webhook") {
+			t.Errorf("Expected error containing Discord's message, got %v", err)
+		}
+	})
+
+	t.Run("Token redacted from errors", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		webhookURL := server.URL + "/api/webhooks/123/secret-token"
+		server.Close()
+
+		_, err := GetWebhookInfo(context.Background(), webhookURL)
+		if err == nil {
+			t.Fatal("Expected error, got nil")
+		}
+		if strings.Contains(err.Error(), "secret-token") {
+			t.Errorf("Expected the webhook token to be redacted, got %v", err)
+		}
+	})
+}
+
+func TestThreadID(t *testing.T) {
+	tests := []struct {
+		name          string
+		threadID      string
+		config        *config.Config
+		expectedQuery string
+		expectError   bool
+	}{
+		{name: "No thread", expectedQuery: ""},
+		{name: "Thread from flag", threadID: "1234567890", expectedQuery: "1234567890"},
+		{name: "Thread from config", config: &config.Config{ThreadID: "555"}, expectedQuery: "555"},
+		{name: "Flag overrides config", threadID: "1234567890", config: &config.Config{ThreadID: "555"}, expectedQuery: "1234567890"},
+		{name: "Non-numeric thread", threadID: "general", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requested bool
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requested = true
+				if got := r.URL.Query().Get("thread_id"); got != tt.expectedQuery {
+					t.Errorf("Expected thread_id=%q, got %q", tt.expectedQuery, got)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			_, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "msg", Discord: notifier.DiscordOptions{ThreadID: tt.threadID}}, tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				if requested {
+					t.Error("Request should not be sent with an invalid thread ID")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSendNotificationResult(t *testing.T) {
+	t.Run("Wait returns message details", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("wait") != "true" {
+				t.Errorf("Expected wait=true, got query %q", r.URL.RawQuery)
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"id": "1234567890", "channel_id": "42", "content": ""}`))
+		}))
+		defer server.Close()
+
+		result, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "msg"}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.MessageID != "1234567890" || result.ChannelID != "42" {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+		if result.StatusCode != http.StatusOK || result.Attempts != 1 || result.Elapsed <= 0 {
+			t.Errorf("Expected status, attempts and elapsed time, got %+v", result)
+		}
+	})
+
+	t.Run("No wait", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Has("wait") {
+				t.Errorf("Expected no wait parameter, got query %q", r.URL.RawQuery)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		result, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "msg", Discord: notifier.DiscordOptions{NoWait: true}}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.MessageID != "" {
+			t.Errorf("Expected no message ID, got %q", result.MessageID)
+		}
+		if result.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected status 204, got %d", result.StatusCode)
+		}
+	})
+
+	t.Run("Malformed response", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte(`not json`))
+		}))
+		defer server.Close()
+
+		if _, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "msg"}, nil); err == nil {
+			t.Error("Expected error for a malformed response")
+		}
+	})
+}
+
+func TestResolveThread(t *testing.T) {
+	now := time.Date(2024, 6, 1, 21, 30, 0, 0, time.UTC)
+	expand := func(name, text string) (string, error) {
+		return placeholder.Expand(name, text, placeholder.Vars{Now: now})
+	}
+
+	tests := []struct {
+		name         string
+		n            notifier.Notification
+		config       *config.Config
+		expectedID   string
+		expectedName string
+		expectError  bool
+	}{
+		{name: "Nothing set"},
+		{name: "Thread name flag", n: notifier.Notification{Discord: notifier.DiscordOptions{ThreadName: "2024-06-01 report"}}, expectedName: "2024-06-01 report"},
+		{name: "Both flags", n: notifier.Notification{Discord: notifier.DiscordOptions{ThreadID: "1", ThreadName: "x"}}, expectError: true},
+		{
+			name:         "Template from config",
+			n:            notifier.Notification{Expand: expand},
+			config:       &config.Config{ThreadNameTemplate: "{{date}} nightly report ({{time}})"},
+			expectedName: "2024-06-01 nightly report (21:30:00)",
+		},
+		{
+			name:         "Template as written without expansion",
+			config:       &config.Config{ThreadNameTemplate: "{{date}} nightly report"},
+			expectedName: "{{date}} nightly report",
+		},
+		{
+			name:        "Invalid placeholder in template",
+			n:           notifier.Notification{Expand: expand},
+			config:      &config.Config{ThreadNameTemplate: "{{nope}} report"},
+			expectError: true,
+		},
+		{
+			name:         "Thread name flag overrides config thread ID",
+			n:            notifier.Notification{Discord: notifier.DiscordOptions{ThreadName: "adhoc"}},
+			config:       &config.Config{ThreadID: "555"},
+			expectedName: "adhoc",
+		},
+		{
+			name:       "Thread ID flag overrides config template",
+			n:          notifier.Notification{Discord: notifier.DiscordOptions{ThreadID: "777"}},
+			config:     &config.Config{ThreadNameTemplate: "{{date}}"},
+			expectedID: "777",
+		},
+		{
+			name:        "Config sets both",
+			config:      &config.Config{ThreadID: "555", ThreadNameTemplate: "{{date}}"},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			id, name, err := resolveThread(tt.n, tt.config)
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if id != tt.expectedID || name != tt.expectedName {
+				t.Errorf("Expected (%q, %q), got (%q, %q)", tt.expectedID, tt.expectedName, id, name)
+			}
+		})
+	}
+}
+
+func TestThreadNamePayload(t *testing.T) {
+	server := setupMockServer(t, http.StatusOK, func(payload *Webhook) {
+		if payload.ThreadName != "2024-06-01 report" {
+			t.Errorf("Expected thread_name %q, got %q", "2024-06-01 report", payload.ThreadName)
+		}
+	})
+	defer server.Close()
+
+	_, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "nightly report", Discord: notifier.DiscordOptions{ThreadName: "2024-06-01 report"}}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestDiscordErrorBody(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"message": "Unknown Channel", "code": 10003}`))
+	}))
+	defer server.Close()
+
+	_, err := SendNotification(context.Background(), server.URL, notifier.Notification{Message: "msg", Discord: notifier.DiscordOptions{ThreadID: "42"}}, nil)
+	if err == nil {
+		t.Fatal("Expected error, got nil")
+	}
+	expected := "discord webhook returned status 400: Unknown Channel (code 10003)"
+	if err.Error() != expected {
+		t.Errorf("Expected %q, got %q", expected, err.Error())
+	}
+}
+
+func TestDeleteNotification(t *testing.T) {
+	tests := []struct {
+		name        string
+		statusCode  int
+		body        string
+		errContains string
+	}{
+		{name: "Deleted", statusCode: http.StatusNoContent},
+		{name: "Unknown message", statusCode: http.StatusNotFound, body: `{"message": "Unknown Message", "code": 10008}`, errContains: "Unknown Message"},
+		{name: "Invalid token", statusCode: http.StatusUnauthorized, body: `{"message": "Invalid Webhook Token", "code": 50027}`, errContains: "status 401"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method != "DELETE" {
+					t.Errorf("Expected DELETE request, got %s", r.Method)
+				}
+				if r.URL.Path != "/api/webhooks/1/token/messages/1234567890" {
+					t.Errorf("Unexpected path: %s", r.URL.Path)
+				}
+				w.WriteHeader(tt.statusCode)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			err := DeleteNotification(context.Background(), server.URL+"/api/webhooks/1/token", "1234567890", "")
+			if tt.errContains == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.errContains) {
+				t.Errorf("Expected error containing %q, got %v", tt.errContains, err)
+			}
+		})
+	}
+
+	t.Run("Thread message", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Query().Get("thread_id") != "555" {
+				t.Errorf("Expected thread_id=555, got query %q", r.URL.RawQuery)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		if err := DeleteNotification(context.Background(), server.URL, "1234567890", "555"); err != nil {
+			t.Errorf("Unexpected error: %v", err)
+		}
+	})
+
+	t.Run("Invalid message ID", func(t *testing.T) {
+		if err := DeleteNotification(context.Background(), "http://127.0.0.1:0", "abc", ""); err == nil {
+			t.Error("Expected error for a non-numeric message ID")
+		}
+	})
+}
+
 // Test marshalling and structure of webhook payload
 func TestWebhookPayload(t *testing.T) {
 	webhook := Webhook{
@@ -148,7 +664,7 @@ func TestWebhookPayload(t *testing.T) {
 				Title:       "Test Title",
 				Description: "Test Description",
 				Color:       12345,
-				Fields: []Field{
+				Fields: []notifier.Field{
 					{
 						Name:   "Field1",
 						Value:  "Value1",
@@ -160,9 +676,12 @@ func TestWebhookPayload(t *testing.T) {
 						Inline: false,
 					},
 				},
-				Footer: Footer{
-					Text: "Test Footer",
+				Footer: notifier.Footer{
+					Text:    "Test Footer",
+					IconURL: "https://example.com/footer.png",
 				},
+				Thumbnail: &Thumbnail{URL: "https://example.com/logo.png"},
+				Author:    &notifier.Author{Name: "alice", URL: "https://github.com/alice", IconURL: "https://github.com/alice.png"},
 			},
 		},
 	}
@@ -213,6 +732,18 @@ func TestWebhookPayload(t *testing.T) {
 		t.Errorf("Footer text mismatch: expected %q, got %q", "Test Footer", embed.Footer.Text)
 	}
 
+	if embed.Footer.IconURL != "https://example.com/footer.png" {
+		t.Errorf("Footer icon mismatch: expected %q, got %q", "https://example.com/footer.png", embed.Footer.IconURL)
+	}
+
+	if embed.Thumbnail == nil || embed.Thumbnail.URL != "https://example.com/logo.png" {
+		t.Errorf("Thumbnail mismatch: got %+v", embed.Thumbnail)
+	}
+
+	if embed.Author == nil || *embed.Author != *webhook.Embeds[0].Author {
+		t.Errorf("Author mismatch: expected %+v, got %+v", webhook.Embeds[0].Author, embed.Author)
+	}
+
 	// Check specific field properties
 	field1 := embed.Fields[0]
 	if field1.Name != "Field1" || field1.Value != "Value1" || !field1.Inline {
@@ -224,3 +755,199 @@ func TestWebhookPayload(t *testing.T) {
 		t.Errorf("Field2 mismatch: expected {Name:Field2, Value:Value2, Inline:false}, got %+v", field2)
 	}
 }
+
+func TestSendNotificationPlain(t *testing.T) {
+	tests := []struct {
+		name            string
+		notification    notifier.Notification
+		expectedContent string
+		expectEmbeds    bool
+	}{
+		{
+			name:         "Embed by default",
+			notification: notifier.Notification{Message: "Build passed", Source: "CI"},
+			expectEmbeds: true,
+		},
+		{
+			name:            "Plain content",
+			notification:    notifier.Notification{Message: "Build passed", Source: "CI", Plain: true},
+			expectedContent: "Build passed",
+		},
+		{
+			name:            "Plain content with mentions",
+			notification:    notifier.Notification{Message: "Build failed", Plain: true, Discord: notifier.DiscordOptions{Mentions: []notifier.Mention{{Type: notifier.MentionHere}}}},
+			expectedContent: "@here Build failed",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("Failed to decode payload: %v", err)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{Username: "CI Bot", AvatarURL: "https://example.com/bot.png"}
+			if _, err := SendNotification(context.Background(), server.URL, tt.notification, cfg); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			_, hasEmbeds := payload["embeds"]
+			if hasEmbeds != tt.expectEmbeds {
+				t.Errorf("Expected embeds present=%v, got payload %v", tt.expectEmbeds, payload)
+			}
+			content, _ := payload["content"].(string)
+			if content != tt.expectedContent {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, content)
+			}
+			if payload["username"] != "CI Bot" || payload["avatar_url"] != "https://example.com/bot.png" {
+				t.Errorf("Expected identity overrides to apply, got %v", payload)
+			}
+		})
+	}
+}
+
+func TestSendNotificationTTS(t *testing.T) {
+	tests := []struct {
+		name                string
+		notification        notifier.Notification
+		config              *config.Config
+		expectedTTS         bool
+		expectedContent     string
+		expectedDescription string
+		expectEmbed         bool
+	}{
+		{
+			name:                "Off by default",
+			notification:        notifier.Notification{Message: "Disk full", Source: "cron"},
+			expectedDescription: "Disk full",
+			expectEmbed:         true,
+		},
+		{
+			name:            "Embed moves the message into content",
+			notification:    notifier.Notification{Message: "Disk full", Source: "cron", Discord: notifier.DiscordOptions{TTS: true}},
+			expectedTTS:     true,
+			expectedContent: "Disk full",
+			expectEmbed:     true,
+		},
+		{
+			name:            "Plain",
+			notification:    notifier.Notification{Message: "Disk full", Plain: true, Discord: notifier.DiscordOptions{TTS: true}},
+			expectedTTS:     true,
+			expectedContent: "Disk full",
+		},
+		{
+			name:            "Enabled in config",
+			notification:    notifier.Notification{Message: "Disk full", Plain: true},
+			config:          &config.Config{TTS: true},
+			expectedTTS:     true,
+			expectedContent: "Disk full",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := setupMockServer(t, http.StatusNoContent, func(payload *Webhook) {
+				if payload.TTS != tt.expectedTTS {
+					t.Errorf("Expected tts=%v, got %v", tt.expectedTTS, payload.TTS)
+				}
+				if payload.Content != tt.expectedContent {
+					t.Errorf("Expected content %q, got %q", tt.expectedContent, payload.Content)
+				}
+				if (len(payload.Embeds) == 1) != tt.expectEmbed {
+					t.Fatalf("Expected embed=%v, got %+v", tt.expectEmbed, payload.Embeds)
+				}
+				if tt.expectEmbed && payload.Embeds[0].Description != tt.expectedDescription {
+					t.Errorf("Expected description %q, got %q", tt.expectedDescription, payload.Embeds[0].Description)
+				}
+			})
+			defer server.Close()
+
+			if _, err := SendNotification(context.Background(), server.URL, tt.notification, tt.config); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+func TestSendNotificationFlags(t *testing.T) {
+	tests := []struct {
+		name          string
+		notification  notifier.Notification
+		config        *config.Config
+		expectedFlags float64
+	}{
+		{
+			name:         "No flags by default",
+			notification: notifier.Notification{Message: "See https://example.com", Plain: true},
+		},
+		{
+			name:          "Suppress embeds in plain mode",
+			notification:  notifier.Notification{Message: "See https://example.com", Plain: true, SuppressEmbeds: true},
+			expectedFlags: FlagSuppressEmbeds,
+		},
+		{
+			name:          "Suppress embeds from config",
+			notification:  notifier.Notification{Message: "See https://example.com", Plain: true},
+			config:        &config.Config{SuppressEmbeds: true},
+			expectedFlags: FlagSuppressEmbeds,
+		},
+		{
+			name:         "Suppress embeds ignored with an embed",
+			notification: notifier.Notification{Message: "See https://example.com", SuppressEmbeds: true},
+		},
+		{
+			name:          "Silent",
+			notification:  notifier.Notification{Message: "heartbeat", Silent: true},
+			expectedFlags: FlagSuppressNotifications,
+		},
+		{
+			name:          "Silent from config",
+			notification:  notifier.Notification{Message: "heartbeat"},
+			config:        &config.Config{Silent: true},
+			expectedFlags: FlagSuppressNotifications,
+		},
+		{
+			name:         "Loud overrides config",
+			notification: notifier.Notification{Message: "prod is down", Loud: true},
+			config:       &config.Config{Silent: true},
+		},
+		{
+			name:          "Flags combine",
+			notification:  notifier.Notification{Message: "See https://example.com", Plain: true, SuppressEmbeds: true, Silent: true},
+			expectedFlags: FlagSuppressEmbeds | FlagSuppressNotifications,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var payload map[string]any
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+					t.Errorf("Failed to decode payload: %v", err)
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			if _, err := SendNotification(context.Background(), server.URL, tt.notification, tt.config); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			flags, present := payload["flags"]
+			if tt.expectedFlags == 0 {
+				if present {
+					t.Errorf("Expected no flags, got %v", flags)
+				}
+				return
+			}
+			if flags != tt.expectedFlags {
+				t.Errorf("Expected flags %v, got %v", tt.expectedFlags, flags)
+			}
+		})
+	}
+}
@@ -0,0 +1,93 @@
+package discord
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// ParseEmbeds decodes a JSON document holding either a single embed object or
+// an array of embeds. Unknown keys are rejected so a typo doesn't silently
+// drop part of the embed, and syntax errors report their line and column.
+func ParseEmbeds(data []byte) ([]Embed, error) {
+	data = bytes.TrimSpace(data)
+	if len(data) == 0 {
+		return nil, fmt.Errorf("embed JSON is empty")
+	}
+
+	var embeds []Embed
+	var err error
+	if data[0] == '[' {
+		err = decodeStrict(data, &embeds)
+	} else {
+		var embed Embed
+		err = decodeStrict(data, &embed)
+		embeds = []Embed{embed}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if len(embeds) == 0 {
+		return nil, fmt.Errorf("embed JSON contains no embeds")
+	}
+	return embeds, nil
+}
+
+func decodeStrict(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+
+	if err := decoder.Decode(v); err != nil {
+		offset := decoder.InputOffset()
+		var syntaxErr *json.SyntaxError
+		var typeErr *json.UnmarshalTypeError
+		if errors.As(err, &syntaxErr) {
+			// The offset is just past the offending character
+			offset = syntaxErr.Offset - 1
+		} else if errors.As(err, &typeErr) {
+			offset = typeErr.Offset
+		} else if errors.Is(err, io.ErrUnexpectedEOF) {
+			offset = int64(len(data))
+		}
+		line, column := position(data, offset)
+		return fmt.Errorf("invalid embed JSON at line %d, column %d: %v", line, column, err)
+	}
+
+	if decoder.More() {
+		line, column := position(data, decoder.InputOffset())
+		return fmt.Errorf("invalid embed JSON at line %d, column %d: unexpected data after the embed", line, column)
+	}
+	return nil
+}
+
+// position converts a byte offset into a 1-based line and column
+func position(data []byte, offset int64) (int, int) {
+	offset = min(max(offset, 0), int64(len(data)))
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// customEmbeds fills in what a user-supplied embed leaves out: the
+// timestamp, which would otherwise be sent as the zero time, and the fields
+// list
+func customEmbeds(embeds []Embed, now time.Time) []Embed {
+	result := make([]Embed, len(embeds))
+	for i, embed := range embeds {
+		if embed.Timestamp.IsZero() {
+			embed.Timestamp = now
+		}
+		if embed.Fields == nil {
+			embed.Fields = []notifier.Field{}
+		}
+		result[i] = embed
+	}
+	return result
+}
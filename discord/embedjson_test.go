@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestParseEmbeds(t *testing.T) {
+	tests := []struct {
+		name          string
+		data          string
+		expectedCount int
+		expectedErr   string
+	}{
+		{
+			name:          "Single embed",
+			data:          `{"title": "Deploy", "description": "v1.2.3 is live", "color": 5763719}`,
+			expectedCount: 1,
+		},
+		{
+			name:          "Array of embeds",
+			data:          `[{"title": "One"}, {"title": "Two", "fields": [{"name": "a", "value": "b", "inline": true}]}]`,
+			expectedCount: 2,
+		},
+		{
+			name:        "Unknown key",
+			data:        `{"titel": "Deploy"}`,
+			expectedErr: `unknown field "titel"`,
+		},
+		{
+			name:        "Syntax error reports position",
+			data:        "{\n  \"title\": \"Deploy\",\n  \"color\": ,\n}",
+			expectedErr: "line 3, column 12",
+		},
+		{
+			name:        "Wrong type reports position",
+			data:        "{\n  \"color\": \"blue\"\n}",
+			expectedErr: "line 2",
+		},
+		{
+			name:        "Truncated document",
+			data:        `{"title": "Deploy"`,
+			expectedErr: "line 1",
+		},
+		{
+			name:        "Trailing data",
+			data:        `{"title": "One"} {"title": "Two"}`,
+			expectedErr: "unexpected data after the embed",
+		},
+		{
+			name:        "Empty array",
+			data:        `[]`,
+			expectedErr: "no embeds",
+		},
+		{
+			name:        "Empty document",
+			data:        "  \n",
+			expectedErr: "empty",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			embeds, err := ParseEmbeds([]byte(tt.data))
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if len(embeds) != tt.expectedCount {
+				t.Errorf("Expected %d embeds, got %d", tt.expectedCount, len(embeds))
+			}
+		})
+	}
+}
+
+func TestSendNotificationCustomEmbeds(t *testing.T) {
+	embeds := json.RawMessage(`[{"title": "Deploy", "description": "v1.2.3 is live"}, {"title": "Changelog"}]`)
+	server := setupMockServer(t, http.StatusNoContent, func(payload *Webhook) {
+		if payload.Username != "Deploy Bot" {
+			t.Errorf("Expected config username, got %q", payload.Username)
+		}
+		if payload.Content != "Released" {
+			t.Errorf("Expected the message as content, got %q", payload.Content)
+		}
+		if len(payload.Embeds) != 2 || payload.Embeds[0].Title != "Deploy" || payload.Embeds[1].Title != "Changelog" {
+			t.Fatalf("Expected the custom embeds, got %+v", payload.Embeds)
+		}
+		for _, embed := range payload.Embeds {
+			if embed.Timestamp.IsZero() {
+				t.Error("Expected a timestamp to be filled in")
+			}
+			if len(embed.Fields) != 0 {
+				t.Errorf("Expected no generated fields, got %+v", embed.Fields)
+			}
+		}
+	})
+	defer server.Close()
+
+	n := notifier.Notification{Message: "Released", Source: "CI", Discord: notifier.DiscordOptions{Embeds: embeds}}
+	if _, err := SendNotification(context.Background(), server.URL, n, &config.Config{Username: "Deploy Bot"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
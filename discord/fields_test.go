@@ -0,0 +1,79 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestSendNotificationCustomFields(t *testing.T) {
+	server := setupMockServer(t, http.StatusNoContent, func(payload *Webhook) {
+		fields := payload.Embeds[0].Fields
+		if len(fields) != 5 {
+			t.Fatalf("Expected 5 fields, got %+v", fields)
+		}
+		if fields[0].Name != "Working Directory" || fields[1].Name != "Source" || fields[2].Name != "Host" {
+			t.Errorf("Expected built-in fields first, got %+v", fields)
+		}
+		if fields[3] != (notifier.Field{Name: "Branch", Value: "main", Inline: true}) || fields[4] != (notifier.Field{Name: "Commit", Value: "abc123"}) {
+			t.Errorf("Expected custom fields in order, got %+v", fields[3:])
+		}
+	})
+	defer server.Close()
+
+	n := notifier.Notification{
+		Message: "Build finished",
+		Source:  "CI",
+		Fields:  []notifier.Field{{Name: "Branch", Value: "main", Inline: true}, {Name: "Commit", Value: "abc123"}},
+	}
+	if _, err := SendNotification(context.Background(), server.URL, n, nil); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+}
+
+func TestSendNotificationTooManyFields(t *testing.T) {
+	server := setupMockServer(t, http.StatusNoContent, func(payload *Webhook) {
+		t.Error("Request should not be sent with too many fields")
+	})
+	defer server.Close()
+
+	// Together with the three built-in fields this is one too many
+	n := notifier.Notification{Message: "Build finished", Source: "CI"}
+	for i := range MaxFields - 2 {
+		n.Fields = append(n.Fields, notifier.Field{Name: fmt.Sprintf("Field %d", i), Value: "v"})
+	}
+
+	_, err := SendNotification(context.Background(), server.URL, n, nil)
+	if err == nil || !strings.Contains(err.Error(), "embed has 26 fields, exceeding Discord's limit of 25") {
+		t.Errorf("Expected field limit error, got %v", err)
+	}
+
+	// Without the Host field the same fields fit
+	n.NoHost = true
+	if _, err := BuildPayloads(n, nil); err != nil {
+		t.Errorf("Expected the fields to fit without the Host field, got %v", err)
+	}
+}
+
+func TestSplitConfigFields(t *testing.T) {
+	cfg := &config.Config{Embed: config.EmbedDefaults{Fields: []string{"Environment=staging"}}}
+	webhooks, err := BuildPayloads(notifier.Notification{Message: strings.Repeat("word ", 1500)}, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(webhooks) < 2 {
+		t.Fatalf("Expected the message to be split, got %d part(s)", len(webhooks))
+	}
+	for i, webhook := range webhooks {
+		fields := webhook.Embeds[0].Fields
+		last := fields[len(fields)-1].Name == "Environment"
+		if last != (i == len(webhooks)-1) {
+			t.Errorf("Expected the configured field on the last part only, part %d has %+v", i+1, fields)
+		}
+	}
+}
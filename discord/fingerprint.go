@@ -0,0 +1,89 @@
+package discord
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// FingerprintVersion is mixed into every fingerprint. It is bumped whenever
+// the canonical form changes, so fingerprints computed by different versions
+// of owata never collide by accident.
+const FingerprintVersion = 1
+
+// fingerprintLength is the number of hex characters returned by PayloadFingerprint
+const fingerprintLength = 16
+
+var mentionMarkupPattern = regexp.MustCompile(`<@&?\d+>|@here|@everyone`)
+
+type fingerprintOptions struct {
+	includeFields   bool
+	includeMentions bool
+}
+
+// FingerprintOption configures what PayloadFingerprint considers significant
+type FingerprintOption func(*fingerprintOptions)
+
+// WithoutFields ignores embed fields, e.g. when the working directory or
+// host should not make otherwise identical notifications distinct
+func WithoutFields() FingerprintOption {
+	return func(o *fingerprintOptions) {
+		o.includeFields = false
+	}
+}
+
+// WithoutMentions ignores mention markup and the allowed_mentions list
+func WithoutMentions() FingerprintOption {
+	return func(o *fingerprintOptions) {
+		o.includeMentions = false
+	}
+}
+
+// PayloadFingerprint returns a short, stable hash identifying "the same
+// notification". The payload is canonicalized first: object keys are sorted
+// and embed timestamps are ignored, so two sends of the same message at
+// different times share a fingerprint.
+//
+// Fingerprints are stable across runs and platforms for a given
+// FingerprintVersion. They may change between owata releases that bump
+// FingerprintVersion, so persisted fingerprints should not be compared across
+// such upgrades.
+func PayloadFingerprint(w Webhook, opts ...FingerprintOption) string {
+	options := fingerprintOptions{
+		includeFields:   true,
+		includeMentions: true,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if !options.includeMentions {
+		w.AllowedMentions = nil
+		w.Content = strings.TrimSpace(mentionMarkupPattern.ReplaceAllString(w.Content, ""))
+	}
+
+	// Work on a copy of the embeds so the caller's payload is untouched
+	embeds := make([]Embed, len(w.Embeds))
+	for i, embed := range w.Embeds {
+		embed.Timestamp = time.Time{}
+		if !options.includeFields {
+			embed.Fields = nil
+		}
+		embeds[i] = embed
+	}
+	w.Embeds = embeds
+
+	// Round-trip through a generic map so keys are emitted in sorted order.
+	// With timestamps zeroed the payload always marshals.
+	data, _ := json.Marshal(w)
+	var canonical map[string]any
+	_ = json.Unmarshal(data, &canonical)
+	canonical["fingerprint_version"] = FingerprintVersion
+	data, _ = json.Marshal(canonical)
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:fingerprintLength]
+}
@@ -0,0 +1,114 @@
+package discord
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+func fingerprintTestWebhook() Webhook {
+	return Webhook{
+		Username: "Owata",
+		Embeds: []Embed{
+			{
+				Title:       "🔔 Notification",
+				Description: "Build finished",
+				Color:       notifier.DefaultColor,
+				Timestamp:   time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC),
+				Fields: []notifier.Field{
+					{Name: "Working Directory", Value: "/src/app"},
+					{Name: "Source", Value: "CI", Inline: true},
+				},
+				Footer: notifier.Footer{Text: "Owata"},
+			},
+		},
+		AllowedMentions: &AllowedMentions{Parse: []string{}},
+	}
+}
+
+func TestPayloadFingerprintStability(t *testing.T) {
+	base := fingerprintTestWebhook()
+	fp := PayloadFingerprint(base)
+
+	if len(fp) != fingerprintLength {
+		t.Errorf("Expected fingerprint of length %d, got %q", fingerprintLength, fp)
+	}
+	if again := PayloadFingerprint(fingerprintTestWebhook()); again != fp {
+		t.Errorf("Expected identical payloads to share a fingerprint, got %s and %s", fp, again)
+	}
+
+	// The same payload decoded from JSON with keys in a different order
+	reordered := `{
+		"allowed_mentions": {"parse": []},
+		"embeds": [{
+			"footer": {"text": "Owata"},
+			"fields": [
+				{"value": "/src/app", "name": "Working Directory", "inline": false},
+				{"inline": true, "value": "CI", "name": "Source"}
+			],
+			"timestamp": "2030-01-01T00:00:00Z",
+			"color": 3447003,
+			"description": "Build finished",
+			"title": "🔔 Notification"
+		}],
+		"username": "Owata"
+	}`
+	var decoded Webhook
+	if err := json.Unmarshal([]byte(reordered), &decoded); err != nil {
+		t.Fatalf("Failed to decode reordered payload: %v", err)
+	}
+	if got := PayloadFingerprint(decoded); got != fp {
+		t.Errorf("Expected reordered payload with a different timestamp to match %s, got %s", fp, got)
+	}
+
+	// Computing a fingerprint must not modify the caller's payload
+	if base.Embeds[0].Timestamp.IsZero() {
+		t.Error("PayloadFingerprint modified the caller's embed timestamp")
+	}
+}
+
+func TestPayloadFingerprintSensitivity(t *testing.T) {
+	fp := PayloadFingerprint(fingerprintTestWebhook())
+
+	tests := []struct {
+		name   string
+		modify func(w *Webhook)
+	}{
+		{name: "Description", modify: func(w *Webhook) { w.Embeds[0].Description = "Build failed" }},
+		{name: "Title", modify: func(w *Webhook) { w.Embeds[0].Title = "Deploy" }},
+		{name: "Color", modify: func(w *Webhook) { w.Embeds[0].Color = 0xff0000 }},
+		{name: "Field value", modify: func(w *Webhook) { w.Embeds[0].Fields[1].Value = "Local" }},
+		{name: "Username", modify: func(w *Webhook) { w.Username = "Other" }},
+		{name: "Mention", modify: func(w *Webhook) {
+			w.Content, w.AllowedMentions = buildMentions([]notifier.Mention{{Type: notifier.MentionHere}})
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := fingerprintTestWebhook()
+			tt.modify(&w)
+			if got := PayloadFingerprint(w); got == fp {
+				t.Errorf("Expected fingerprint to change when %s changes", tt.name)
+			}
+		})
+	}
+}
+
+func TestPayloadFingerprintOptions(t *testing.T) {
+	base := fingerprintTestWebhook()
+
+	otherDir := fingerprintTestWebhook()
+	otherDir.Embeds[0].Fields[0].Value = "/src/other"
+	if PayloadFingerprint(base, WithoutFields()) != PayloadFingerprint(otherDir, WithoutFields()) {
+		t.Error("Expected WithoutFields to ignore field differences")
+	}
+
+	mentioned := fingerprintTestWebhook()
+	mentioned.Content, mentioned.AllowedMentions = buildMentions([]notifier.Mention{{Type: notifier.MentionUser, ID: "123"}})
+	if PayloadFingerprint(base, WithoutMentions()) != PayloadFingerprint(mentioned, WithoutMentions()) {
+		t.Error("Expected WithoutMentions to ignore mention differences")
+	}
+}
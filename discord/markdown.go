@@ -0,0 +1,28 @@
+package discord
+
+import "strings"
+
+// markdownEscaper backslash-escapes the characters Discord reads as markdown,
+// including the backslash itself so existing escapes are shown as typed
+var markdownEscaper = strings.NewReplacer(
+	`\`, `\\`,
+	`*`, `\*`,
+	`_`, `\_`,
+	`~`, `\~`,
+	"`", "\\`",
+	`|`, `\|`,
+	`>`, `\>`,
+)
+
+// escapeMarkdown makes Discord show a message literally, so log lines and
+// paths such as __init__.py aren't reformatted. Headings only start a line,
+// so # is escaped only there.
+func escapeMarkdown(message string) string {
+	lines := strings.Split(markdownEscaper.Replace(message), "\n")
+	for i, line := range lines {
+		if strings.HasPrefix(line, "#") {
+			lines[i] = `\` + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,65 @@
+package discord
+
+import (
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestEscapeMarkdown(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		expected string
+	}{
+		{"Plain text", "Build finished", "Build finished"},
+		{"Dunder path", "src/__init__.py", `src/\_\_init\_\_.py`},
+		{"Bold and italic", "**FAILED** *flaky*", `\*\*FAILED\*\* \*flaky\*`},
+		{"Strikethrough and spoiler", "~~old~~ ||secret||", `\~\~old\~\~ \|\|secret\|\|`},
+		{"Inline code", "run `make`", "run \\`make\\`"},
+		{"Quote", "> quoted\na > b", "\\> quoted\na \\> b"},
+		{"Heading", "# Title\nissue #42", "\\# Title\nissue #42"},
+		{"Already escaped", `\*not bold\*`, `\\\*not bold\\\*`},
+		{"Windows path", `C:\Users\me`, `C:\\Users\\me`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := escapeMarkdown(tt.message); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildPayloadsEscape(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        notifier.Notification
+		cfg      *config.Config
+		expected string
+	}{
+		{"Flag", notifier.Notification{Message: "__init__.py", Escape: true}, nil, `\_\_init\_\_.py`},
+		{"Config", notifier.Notification{Message: "__init__.py"}, &config.Config{EscapeMarkdown: true}, `\_\_init\_\_.py`},
+		{"Off", notifier.Notification{Message: "__init__.py"}, nil, "__init__.py"},
+		{"Code block", notifier.Notification{Message: "__init__.py", Escape: true, Code: true}, nil, "```\n__init__.py\n```"},
+		{"Plain", notifier.Notification{Message: "*ok*", Escape: true, Plain: true}, nil, `\*ok\*`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			webhooks, err := BuildPayloads(tt.n, tt.cfg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			got := webhooks[0].Content
+			if len(webhooks[0].Embeds) > 0 {
+				got = webhooks[0].Embeds[0].Description
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,96 @@
+package discord
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// AllowedMentions controls which mentions in a message actually ping
+type AllowedMentions struct {
+	Parse []string `json:"parse"`
+	Users []string `json:"users,omitempty"`
+	Roles []string `json:"roles,omitempty"`
+}
+
+// ParseMention parses a mention spec: user:<id>, role:<id>, @here or @everyone
+func ParseMention(spec string) (notifier.Mention, error) {
+	switch spec {
+	case "@here":
+		return notifier.Mention{Type: notifier.MentionHere}, nil
+	case "@everyone":
+		return notifier.Mention{Type: notifier.MentionEveryone}, nil
+	}
+
+	if id, ok := strings.CutPrefix(spec, "user:"); ok {
+		if !isSnowflake(id) {
+			return notifier.Mention{}, fmt.Errorf("invalid user ID in mention %q: must be a numeric Discord ID", spec)
+		}
+		return notifier.Mention{Type: notifier.MentionUser, ID: id}, nil
+	}
+	if id, ok := strings.CutPrefix(spec, "role:"); ok {
+		if !isSnowflake(id) {
+			return notifier.Mention{}, fmt.Errorf("invalid role ID in mention %q: must be a numeric Discord ID", spec)
+		}
+		return notifier.Mention{Type: notifier.MentionRole, ID: id}, nil
+	}
+
+	return notifier.Mention{}, fmt.Errorf("invalid mention %q (use user:<id>, role:<id>, @here or @everyone)", spec)
+}
+
+// mentionMarkup returns the message markup that triggers a mention
+func mentionMarkup(m notifier.Mention) string {
+	switch m.Type {
+	case notifier.MentionUser:
+		return "<@" + m.ID + ">"
+	case notifier.MentionRole:
+		return "<@&" + m.ID + ">"
+	case notifier.MentionHere:
+		return "@here"
+	default:
+		return "@everyone"
+	}
+}
+
+// buildMentions returns the message content and an allow-list that permits
+// exactly the requested mentions. With no mentions, nothing is allowed to ping.
+func buildMentions(mentions []notifier.Mention) (string, *AllowedMentions) {
+	allowed := &AllowedMentions{Parse: []string{}}
+
+	var parts []string
+	seen := make(map[notifier.Mention]bool)
+	for _, m := range mentions {
+		if seen[m] {
+			continue
+		}
+		seen[m] = true
+		parts = append(parts, mentionMarkup(m))
+
+		switch m.Type {
+		case notifier.MentionUser:
+			allowed.Users = append(allowed.Users, m.ID)
+		case notifier.MentionRole:
+			allowed.Roles = append(allowed.Roles, m.ID)
+		default:
+			// Discord's "everyone" parse type covers both @here and @everyone
+			if len(allowed.Parse) == 0 {
+				allowed.Parse = append(allowed.Parse, "everyone")
+			}
+		}
+	}
+
+	return strings.Join(parts, " "), allowed
+}
+
+func isSnowflake(id string) bool {
+	if id == "" || len(id) > 20 {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
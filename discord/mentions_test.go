@@ -0,0 +1,100 @@
+package discord
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestParseMention(t *testing.T) {
+	tests := []struct {
+		name        string
+		spec        string
+		expected    notifier.Mention
+		expectError bool
+	}{
+		{name: "Here", spec: "@here", expected: notifier.Mention{Type: notifier.MentionHere}},
+		{name: "Everyone", spec: "@everyone", expected: notifier.Mention{Type: notifier.MentionEveryone}},
+		{name: "User", spec: "user:80351110224678912", expected: notifier.Mention{Type: notifier.MentionUser, ID: "80351110224678912"}},
+		{name: "Role", spec: "role:123456789", expected: notifier.Mention{Type: notifier.MentionRole, ID: "123456789"}},
+		{name: "Non-numeric user ID", spec: "user:alice", expectError: true},
+		{name: "Empty role ID", spec: "role:", expectError: true},
+		{name: "ID too long", spec: "role:123456789012345678901", expectError: true},
+		{name: "Unknown kind", spec: "channel:123", expectError: true},
+		{name: "Bare here", spec: "here", expectError: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseMention(tt.spec)
+			if tt.expectError {
+				if err == nil {
+					t.Errorf("Expected error for %q, got nil", tt.spec)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildMentions(t *testing.T) {
+	tests := []struct {
+		name            string
+		mentions        []notifier.Mention
+		expectedContent string
+		expectedJSON    string
+	}{
+		{
+			name:            "No mentions parses nothing",
+			expectedContent: "",
+			expectedJSON:    `{"parse":[]}`,
+		},
+		{
+			name:            "Here",
+			mentions:        []notifier.Mention{{Type: notifier.MentionHere}},
+			expectedContent: "@here",
+			expectedJSON:    `{"parse":["everyone"]}`,
+		},
+		{
+			name: "Users, roles and everyone combined",
+			mentions: []notifier.Mention{
+				{Type: notifier.MentionUser, ID: "111"},
+				{Type: notifier.MentionRole, ID: "222"},
+				{Type: notifier.MentionEveryone},
+				{Type: notifier.MentionHere},
+				{Type: notifier.MentionUser, ID: "111"},
+			},
+			expectedContent: "<@111> <@&222> @everyone @here",
+			expectedJSON:    `{"parse":["everyone"],"users":["111"],"roles":["222"]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content, allowed := buildMentions(tt.mentions)
+			if content != tt.expectedContent {
+				t.Errorf("Expected content %q, got %q", tt.expectedContent, content)
+			}
+
+			data, err := json.Marshal(allowed)
+			if err != nil {
+				t.Fatalf("Failed to marshal allowed mentions: %v", err)
+			}
+
+			var got, expected any
+			json.Unmarshal(data, &got)
+			json.Unmarshal([]byte(tt.expectedJSON), &expected)
+			if !reflect.DeepEqual(got, expected) {
+				t.Errorf("Expected allowed_mentions %s, got %s", tt.expectedJSON, data)
+			}
+		})
+	}
+}
@@ -0,0 +1,206 @@
+package discord
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+// SendWithOptions sends a notification with explicit delivery options such as
+// the request timeout and rate-limit retries. A message too long for one
+// message is sent as sequential posts, titled "(part i/n)" for embeds, unless
+// n.NoSplit is set; the result describes the first post, with Attempts and
+// Elapsed covering every part.
+func (c *Client) SendWithOptions(ctx context.Context, n notifier.Notification, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	parts, newThread, err := splitNotification(n, cfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(parts) == 1 {
+		return c.sendMessage(ctx, parts[0], cfg, opts)
+	}
+
+	start := time.Now()
+	var first *notifier.Result
+	for i, pn := range parts {
+		if newThread && i > 0 {
+			// Later parts go into the forum post the first one created
+			pn.Discord.ThreadID = first.ChannelID
+		}
+
+		result, err := c.sendMessage(ctx, pn, cfg, opts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send part %d of %d: %w", i+1, len(parts), err)
+		}
+		if i == 0 {
+			first = result
+		} else {
+			first.Attempts += result.Attempts
+		}
+	}
+
+	first.Elapsed = time.Since(start)
+	return first, nil
+}
+
+// BuildPayloads creates the payload of every message a notification is sent
+// as, splitting an over-long message as SendWithOptions would. Later parts of
+// a new forum post show no thread, since its ID is only known once created.
+func BuildPayloads(n notifier.Notification, cfg *config.Config) ([]Webhook, error) {
+	if err := validateAttachments(n.Attachments); err != nil {
+		return nil, err
+	}
+	parts, newThread, err := splitNotification(n, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	now := nowFunc()
+	webhooks := make([]Webhook, len(parts))
+	for i, pn := range parts {
+		webhook, _, err := buildWebhook(pn, cfg, now)
+		if err != nil {
+			return nil, err
+		}
+		if newThread && i > 0 {
+			webhook.ThreadName = ""
+		}
+		webhooks[i] = webhook
+	}
+	return webhooks, nil
+}
+
+// splitNotification applies config defaults and breaks a notification too
+// long for one message into the notifications sent for each part. It
+// reports whether the parts create a new forum post, which later parts must
+// be posted into.
+func splitNotification(n notifier.Notification, cfg *config.Config) ([]part, bool, error) {
+	if cfg != nil && cfg.TTS {
+		n.Discord.TTS = true
+	}
+
+	limit := MaxDescriptionLength
+	if messageIsContent(n) {
+		// The message is the content, which mentions share
+		mentions, _ := buildMentions(n.Discord.Mentions)
+		limit = MaxContentLength - utf8.RuneCountInString(mentions) - 1
+	}
+
+	// A code block already shows the message literally
+	if (n.Escape || (cfg != nil && cfg.EscapeMarkdown)) && !n.Code {
+		n.Message = escapeMarkdown(n.Message)
+	}
+
+	code := n.Code && n.Message != ""
+	if code {
+		// Every part gets its own fences, which count towards the limit
+		n.Message = escapeCodeBlock(n.Message)
+		n.Code = false
+		limit -= codeFenceLength(n.Lang)
+	}
+
+	length := utf8.RuneCountInString(n.Message)
+	if length <= limit {
+		if code {
+			n.Message = wrapCodeBlock(n.Message, n.Lang)
+		}
+		return []part{{Notification: n}}, false, nil
+	}
+	if n.NoSplit {
+		return nil, false, fmt.Errorf("message is %d characters long, exceeding Discord's limit of %d", length, limit)
+	}
+
+	// Check everything that would fail every part before sending the first
+	if err := validateAttachments(n.Attachments); err != nil {
+		return nil, false, err
+	}
+	_, threadName, err := resolveThread(n, cfg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	messages := splitMessage(n.Message, limit)
+	title, err := notifier.ResolveTitle(n, cfg)
+	if err != nil {
+		return nil, false, err
+	}
+
+	parts := make([]part, len(messages))
+	for i, message := range messages {
+		pn := part{Notification: n}
+		pn.Message = message
+		if code {
+			pn.Message = wrapCodeBlock(message, n.Lang)
+		}
+		if !n.Plain && len(n.Discord.Embeds) == 0 {
+			pn.Title = fmt.Sprintf("%s (part %d/%d)", title, i+1, len(messages))
+		}
+
+		// Ping once, and attach extras to the end of the message
+		if i > 0 {
+			pn.Discord.Mentions = nil
+		}
+		if i < len(messages)-1 {
+			pn.partial = true
+			pn.Fields = nil
+			pn.Attachments = nil
+			if len(n.Discord.Embeds) > 0 {
+				pn.Discord.Embeds = nil
+				pn.Plain = true
+			}
+		}
+
+		if threadName != "" {
+			if i == 0 {
+				// The created forum post's ID is needed for the later parts
+				pn.Discord.NoWait = false
+			} else {
+				pn.Discord.ThreadName = ""
+			}
+		}
+		parts[i] = pn
+	}
+
+	return parts, threadName != "", nil
+}
+
+// splitMessage breaks a message into chunks of at most limit characters,
+// preferring line boundaries, then whitespace, and only then cutting mid-word
+func splitMessage(message string, limit int) []string {
+	var parts []string
+	runes := []rune(message)
+
+	for len(runes) > limit {
+		window := runes[:limit]
+
+		cut, skip := -1, 0
+		if i := lastIndexRune(window, func(r rune) bool { return r == '\n' }); i > 0 {
+			cut, skip = i, 1
+		} else if i := lastIndexRune(window, unicode.IsSpace); i > 0 {
+			cut, skip = i, 1
+		} else {
+			cut = limit
+		}
+
+		parts = append(parts, strings.TrimRight(string(runes[:cut]), " \t\r"))
+		runes = runes[cut+skip:]
+	}
+
+	return append(parts, string(runes))
+}
+
+func lastIndexRune(runes []rune, match func(rune) bool) int {
+	for i := len(runes) - 1; i >= 0; i-- {
+		if match(runes[i]) {
+			return i
+		}
+	}
+	return -1
+}
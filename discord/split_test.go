@@ -0,0 +1,189 @@
+package discord
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestSplitMessage(t *testing.T) {
+	tests := []struct {
+		name     string
+		message  string
+		limit    int
+		expected []string
+	}{
+		{
+			name:     "Fits",
+			message:  "short",
+			limit:    10,
+			expected: []string{"short"},
+		},
+		{
+			name:     "Breaks at line boundary",
+			message:  "line one\nline two\nline three",
+			limit:    20,
+			expected: []string{"line one\nline two", "line three"},
+		},
+		{
+			name:     "Breaks at word boundary",
+			message:  "alpha beta gamma delta",
+			limit:    12,
+			expected: []string{"alpha beta", "gamma delta"},
+		},
+		{
+			name:     "Cuts a word with no spaces",
+			message:  "abcdefghij",
+			limit:    4,
+			expected: []string{"abcd", "efgh", "ij"},
+		},
+		{
+			name:     "Counts characters, not bytes",
+			message:  "ああああ いいいい",
+			limit:    5,
+			expected: []string{"ああああ", "いいいい"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parts := splitMessage(tt.message, tt.limit)
+			if strings.Join(parts, "|") != strings.Join(tt.expected, "|") {
+				t.Errorf("Expected %q, got %q", tt.expected, parts)
+			}
+			for _, part := range parts {
+				if utf8.RuneCountInString(part) > tt.limit {
+					t.Errorf("Part %q exceeds limit %d", part, tt.limit)
+				}
+			}
+		})
+	}
+}
+
+func TestSendNotificationSplitsLongMessage(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		payloads []Webhook
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var payload Webhook
+		if err := json.NewDecoder(r.Body).Decode(&payload); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		mu.Lock()
+		payloads = append(payloads, payload)
+		mu.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	line := strings.Repeat("x", 99)
+	var lines []string
+	for range 90 {
+		lines = append(lines, line)
+	}
+	message := strings.Join(lines, "\n") // 8999 characters
+
+	n := notifier.Notification{
+		Message: message,
+		Source:  "Test",
+		Title:   "Build log",
+		Fields:  []notifier.Field{{Name: "Status", Value: "failed"}},
+		Discord: notifier.DiscordOptions{Mentions: []notifier.Mention{{Type: notifier.MentionHere}}},
+	}
+	if _, err := SendNotification(context.Background(), server.URL, n, &config.Config{}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	if len(payloads) != 3 {
+		t.Fatalf("Expected 3 posts, got %d", len(payloads))
+	}
+
+	var rebuilt []string
+	for i, payload := range payloads {
+		embed := payload.Embeds[0]
+		expectedTitle := fmt.Sprintf("Build log (part %d/3)", i+1)
+		if embed.Title != expectedTitle {
+			t.Errorf("Expected title %q, got %q", expectedTitle, embed.Title)
+		}
+		if utf8.RuneCountInString(embed.Description) > MaxDescriptionLength {
+			t.Errorf("Part %d exceeds the description limit", i+1)
+		}
+		if strings.HasPrefix(embed.Description, "\n") || strings.HasSuffix(embed.Description, "\n") {
+			t.Errorf("Part %d was not split at a line boundary", i+1)
+		}
+		if (payload.Content != "") != (i == 0) {
+			t.Errorf("Expected mentions only on the first part, part %d content %q", i+1, payload.Content)
+		}
+		hasStatus := embed.Fields[len(embed.Fields)-1].Name == "Status"
+		if hasStatus != (i == 2) {
+			t.Errorf("Expected extra fields only on the last part, part %d fields %+v", i+1, embed.Fields)
+		}
+		rebuilt = append(rebuilt, embed.Description)
+	}
+
+	if strings.Join(rebuilt, "\n") != message {
+		t.Error("Expected parts to reassemble into the original message in order")
+	}
+}
+
+func TestSendNotificationNoSplit(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := notifier.Notification{
+		Message: strings.Repeat("x", MaxDescriptionLength+1),
+		NoSplit: true,
+	}
+	_, err := SendNotification(context.Background(), server.URL, n, &config.Config{})
+	if err == nil || !strings.Contains(err.Error(), "4097 characters") {
+		t.Errorf("Expected length error, got %v", err)
+	}
+	if requested {
+		t.Error("Expected no request to be made")
+	}
+}
+
+func TestBuildPayloads(t *testing.T) {
+	cfg := &config.Config{ThreadNameTemplate: "Build {{date}}"}
+
+	webhooks, err := BuildPayloads(notifier.Notification{Message: "short"}, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(webhooks) != 1 || webhooks[0].Embeds[0].Description != "short" || !strings.HasPrefix(webhooks[0].ThreadName, "Build ") {
+		t.Errorf("Unexpected payloads: %+v", webhooks)
+	}
+
+	long := strings.Repeat("word ", MaxDescriptionLength/5+10)
+	webhooks, err = BuildPayloads(notifier.Notification{Message: long, Fields: []notifier.Field{{Name: "Exit", Value: "0"}}}, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(webhooks) != 2 {
+		t.Fatalf("Expected 2 payloads, got %d", len(webhooks))
+	}
+	if webhooks[0].ThreadName == "" || webhooks[1].ThreadName != "" {
+		t.Errorf("Expected only the first part to create the forum post, got %q and %q", webhooks[0].ThreadName, webhooks[1].ThreadName)
+	}
+	if !strings.HasSuffix(webhooks[1].Embeds[0].Title, "(part 2/2)") || len(webhooks[1].Embeds[0].Fields) != 4 {
+		t.Errorf("Unexpected last part: %+v", webhooks[1].Embeds[0])
+	}
+
+	if _, err := BuildPayloads(notifier.Notification{Message: long, NoSplit: true}, cfg); err == nil {
+		t.Error("Expected an error for an over-long message with NoSplit")
+	}
+}
@@ -0,0 +1,128 @@
+package discord
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// DefaultURLCheckTimeout bounds each pre-flight URL check
+const DefaultURLCheckTimeout = 3 * time.Second
+
+// URLProblem classifies why a URL could not be reached
+type URLProblem int
+
+const (
+	URLReachable URLProblem = iota
+	URLDNSError
+	URLTimeout
+	URLBadStatus
+	URLRequestError
+)
+
+func (p URLProblem) String() string {
+	switch p {
+	case URLReachable:
+		return "reachable"
+	case URLDNSError:
+		return "DNS lookup failed"
+	case URLTimeout:
+		return "timed out"
+	case URLBadStatus:
+		return "bad status"
+	default:
+		return "request failed"
+	}
+}
+
+// URLCheckResult holds the outcome of checking a single URL
+type URLCheckResult struct {
+	URL        string
+	Problem    URLProblem
+	StatusCode int
+	Err        error
+}
+
+// OK reports whether the URL was reachable
+func (r URLCheckResult) OK() bool {
+	return r.Problem == URLReachable
+}
+
+// Reason describes the problem in a human-readable form
+func (r URLCheckResult) Reason() string {
+	switch r.Problem {
+	case URLReachable:
+		return "reachable"
+	case URLBadStatus:
+		return fmt.Sprintf("HTTP %d", r.StatusCode)
+	default:
+		if r.Err != nil {
+			return fmt.Sprintf("%s: %v", r.Problem, r.Err)
+		}
+		return r.Problem.String()
+	}
+}
+
+// CheckURLs sends a HEAD request to each URL concurrently and reports which
+// ones are unreachable. Results are returned in the same order as urls.
+func CheckURLs(urls []string, timeout time.Duration) []URLCheckResult {
+	client := &http.Client{
+		Timeout: timeout,
+	}
+
+	results := make([]URLCheckResult, len(urls))
+	var wg sync.WaitGroup
+	for i, u := range urls {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = checkURL(client, u)
+		}()
+	}
+	wg.Wait()
+
+	return results
+}
+
+func checkURL(client *http.Client, url string) URLCheckResult {
+	result := URLCheckResult{URL: url}
+
+	req, err := http.NewRequest("HEAD", url, nil)
+	if err != nil {
+		result.Problem = URLRequestError
+		result.Err = err
+		return result
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		result.Err = err
+		result.Problem = classifyURLError(err)
+		return result
+	}
+	resp.Body.Close()
+
+	result.StatusCode = resp.StatusCode
+	if resp.StatusCode >= 400 {
+		result.Problem = URLBadStatus
+	}
+	return result
+}
+
+func classifyURLError(err error) URLProblem {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) && !dnsErr.IsTimeout {
+		return URLDNSError
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return URLTimeout
+	}
+
+	return URLRequestError
+}
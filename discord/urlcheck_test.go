@@ -0,0 +1,61 @@
+package discord
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckURLs(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "HEAD" {
+			t.Errorf("Expected HEAD request, got %s", r.Method)
+		}
+		switch r.URL.Path {
+		case "/ok.png":
+			w.WriteHeader(http.StatusOK)
+		case "/hang.png":
+			<-release
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	defer close(release)
+
+	urls := []string{
+		server.URL + "/ok.png",
+		server.URL + "/missing.png",
+		server.URL + "/hang.png",
+		"http://owata-check.invalid/avatar.png",
+	}
+
+	start := time.Now()
+	results := CheckURLs(urls, 200*time.Millisecond)
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected checks to run concurrently, took %v", elapsed)
+	}
+
+	if len(results) != len(urls) {
+		t.Fatalf("Expected %d results, got %d", len(urls), len(results))
+	}
+
+	expected := []URLProblem{URLReachable, URLBadStatus, URLTimeout, URLDNSError}
+	for i, result := range results {
+		if result.URL != urls[i] {
+			t.Errorf("Result %d: expected URL %s, got %s", i, urls[i], result.URL)
+		}
+		if result.Problem != expected[i] {
+			t.Errorf("Result %d (%s): expected %v, got %v (%v)", i, result.URL, expected[i], result.Problem, result.Err)
+		}
+	}
+
+	if !results[0].OK() || results[1].OK() {
+		t.Error("OK() does not match the classified problem")
+	}
+	if results[1].StatusCode != http.StatusNotFound || results[1].Reason() != "HTTP 404" {
+		t.Errorf("Expected 404 reason, got %q", results[1].Reason())
+	}
+}
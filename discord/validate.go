@@ -0,0 +1,88 @@
+package discord
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// Discord's limits on message content, in characters
+const (
+	MaxContentLength     = 2000
+	MaxTitleLength       = 256
+	MaxAuthorNameLength  = 256
+	MaxDescriptionLength = 4096
+	MaxFields            = 25
+	MaxFieldNameLength   = 256
+	MaxFieldValueLength  = 1024
+	MaxFooterLength      = 2048
+	MaxEmbedTotalLength  = 6000
+	MaxEmbeds            = 10
+)
+
+// Validate checks the payload against Discord's limits so an oversized
+// message is reported clearly instead of as an opaque HTTP 400
+func (w *Webhook) Validate() error {
+	if err := checkLength("content", w.Content, MaxContentLength); err != nil {
+		return err
+	}
+
+	if len(w.Embeds) > MaxEmbeds {
+		return fmt.Errorf("message has %d embeds, exceeding Discord's limit of %d", len(w.Embeds), MaxEmbeds)
+	}
+
+	total := 0
+	for i, embed := range w.Embeds {
+		// Only number the embeds when there is more than one
+		name, prefix := "embed", ""
+		if len(w.Embeds) > 1 {
+			name = fmt.Sprintf("embed %d", i+1)
+			prefix = name + " "
+		}
+
+		if err := checkLength(prefix+"title", embed.Title, MaxTitleLength); err != nil {
+			return err
+		}
+		if err := checkLength(prefix+"description", embed.Description, MaxDescriptionLength); err != nil {
+			return err
+		}
+		if err := checkLength(prefix+"footer", embed.Footer.Text, MaxFooterLength); err != nil {
+			return err
+		}
+		if embed.Author != nil {
+			if err := checkLength(prefix+"author name", embed.Author.Name, MaxAuthorNameLength); err != nil {
+				return err
+			}
+			total += utf8.RuneCountInString(embed.Author.Name)
+		}
+
+		if len(embed.Fields) > MaxFields {
+			return fmt.Errorf("%s has %d fields, exceeding Discord's limit of %d", name, len(embed.Fields), MaxFields)
+		}
+		for _, field := range embed.Fields {
+			if err := checkLength(fmt.Sprintf("%sfield '%s' name", prefix, field.Name), field.Name, MaxFieldNameLength); err != nil {
+				return err
+			}
+			if err := checkLength(fmt.Sprintf("%sfield '%s' value", prefix, field.Name), field.Value, MaxFieldValueLength); err != nil {
+				return err
+			}
+			total += utf8.RuneCountInString(field.Name) + utf8.RuneCountInString(field.Value)
+		}
+
+		total += utf8.RuneCountInString(embed.Title) +
+			utf8.RuneCountInString(embed.Description) +
+			utf8.RuneCountInString(embed.Footer.Text)
+	}
+
+	if total > MaxEmbedTotalLength {
+		return fmt.Errorf("embeds are %d characters long in total, exceeding Discord's limit of %d", total, MaxEmbedTotalLength)
+	}
+
+	return nil
+}
+
+func checkLength(name, value string, limit int) error {
+	if length := utf8.RuneCountInString(value); length > limit {
+		return fmt.Errorf("%s is %d characters long, exceeding Discord's limit of %d", name, length, limit)
+	}
+	return nil
+}
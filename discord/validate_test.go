@@ -0,0 +1,157 @@
+package discord
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestWebhookValidate(t *testing.T) {
+	validEmbed := func() Embed {
+		return Embed{
+			Title:       "Title",
+			Description: "Description",
+			Fields:      []notifier.Field{{Name: "Working Directory", Value: "/tmp"}},
+			Footer:      notifier.Footer{Text: "Owata"},
+		}
+	}
+
+	manyFields := validEmbed()
+	for range MaxFields {
+		manyFields.Fields = append(manyFields.Fields, notifier.Field{Name: "n", Value: "v"})
+	}
+
+	tests := []struct {
+		name        string
+		webhook     Webhook
+		expectedErr string
+	}{
+		{
+			name:    "Valid",
+			webhook: Webhook{Embeds: []Embed{validEmbed()}},
+		},
+		{
+			name:    "At every limit",
+			webhook: Webhook{Content: strings.Repeat("c", MaxContentLength), Embeds: []Embed{{Title: strings.Repeat("t", MaxTitleLength), Description: strings.Repeat("d", MaxDescriptionLength)}}},
+		},
+		{
+			name:        "Content too long",
+			webhook:     Webhook{Content: strings.Repeat("c", MaxContentLength+1)},
+			expectedErr: "content is 2001 characters long",
+		},
+		{
+			name: "Title too long",
+			webhook: Webhook{Embeds: []Embed{func() Embed {
+				e := validEmbed()
+				e.Title = strings.Repeat("t", MaxTitleLength+1)
+				return e
+			}()}},
+			expectedErr: "title is 257 characters long, exceeding Discord's limit of 256",
+		},
+		{
+			name: "Title counts characters, not bytes",
+			webhook: Webhook{Embeds: []Embed{func() Embed {
+				e := validEmbed()
+				e.Title = strings.Repeat("あ", MaxTitleLength)
+				return e
+			}()}},
+		},
+		{
+			name: "Description too long",
+			webhook: Webhook{Embeds: []Embed{func() Embed {
+				e := validEmbed()
+				e.Description = strings.Repeat("d", MaxDescriptionLength+1)
+				return e
+			}()}},
+			expectedErr: "description is 4097 characters long",
+		},
+		{
+			name:        "Too many fields",
+			webhook:     Webhook{Embeds: []Embed{manyFields}},
+			expectedErr: "embed has 26 fields, exceeding Discord's limit of 25",
+		},
+		{
+			name: "Field name too long",
+			webhook: Webhook{Embeds: []Embed{func() Embed {
+				e := validEmbed()
+				e.Fields = append(e.Fields, notifier.Field{Name: strings.Repeat("n", MaxFieldNameLength+1), Value: "v"})
+				return e
+			}()}},
+			expectedErr: "name is 257 characters long",
+		},
+		{
+			name: "Field value too long",
+			webhook: Webhook{Embeds: []Embed{func() Embed {
+				e := validEmbed()
+				e.Fields[0].Value = strings.Repeat("v", MaxFieldValueLength+1)
+				return e
+			}()}},
+			expectedErr: "field 'Working Directory' value is 1025 characters long, exceeding Discord's limit of 1024",
+		},
+		{
+			name: "Footer too long",
+			webhook: Webhook{Embeds: []Embed{func() Embed {
+				e := validEmbed()
+				e.Footer.Text = strings.Repeat("f", MaxFooterLength+1)
+				return e
+			}()}},
+			expectedErr: "footer is 2049 characters long",
+		},
+		{
+			name: "Total too long",
+			webhook: Webhook{Embeds: []Embed{func() Embed {
+				e := validEmbed()
+				e.Description = strings.Repeat("d", MaxDescriptionLength)
+				e.Footer.Text = strings.Repeat("f", MaxFooterLength)
+				return e
+			}()}},
+			expectedErr: "exceeding Discord's limit of 6000",
+		},
+		{
+			name:        "Multiple embeds are numbered",
+			webhook:     Webhook{Embeds: []Embed{validEmbed(), {Title: strings.Repeat("t", MaxTitleLength+1)}}},
+			expectedErr: "embed 2 title is 257 characters long",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.webhook.Validate()
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSendNotificationValidatesBeforeSending(t *testing.T) {
+	requested := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := notifier.Notification{
+		Message: "Hello",
+		Source:  strings.Repeat("s", MaxFieldValueLength+1),
+	}
+	_, err := SendNotification(context.Background(), server.URL, n, &config.Config{})
+	if err == nil || !strings.Contains(err.Error(), "field 'Source' value") {
+		t.Errorf("Expected field value error, got %v", err)
+	}
+	if requested {
+		t.Error("Expected no request to be made")
+	}
+}
@@ -0,0 +1,66 @@
+package discord
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// webhookHosts are the hosts Discord serves webhooks from
+var webhookHosts = map[string]bool{
+	"discord.com":        true,
+	"discordapp.com":     true,
+	"ptb.discord.com":    true,
+	"canary.discord.com": true,
+}
+
+var (
+	// Discord also accepts a versioned API path such as /api/v10/webhooks
+	apiVersionPattern   = regexp.MustCompile(`^v\d+$`)
+	webhookTokenPattern = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+)
+
+// ValidateWebhookURL checks that a URL looks like a Discord webhook:
+// https://discord.com/api/webhooks/<id>/<token>. The error names the part of
+// the URL that looks wrong.
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook URL: scheme must be https, got %q", u.Scheme)
+	}
+	if host := strings.ToLower(u.Hostname()); !webhookHosts[host] {
+		return fmt.Errorf("invalid webhook URL: host must be discord.com or discordapp.com, got %q", u.Host)
+	}
+
+	segments := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if segments[0] == "api" {
+		segments = segments[1:]
+	}
+	if len(segments) > 0 && apiVersionPattern.MatchString(segments[0]) {
+		segments = segments[1:]
+	}
+	if !strings.HasPrefix(u.Path, "/api/") || len(segments) == 0 || segments[0] != "webhooks" {
+		return fmt.Errorf("invalid webhook URL: path must start with /api/webhooks/, got %q", u.Path)
+	}
+	segments = segments[1:]
+
+	switch {
+	case len(segments) == 0 || segments[0] == "":
+		return fmt.Errorf("invalid webhook URL: missing webhook ID after /api/webhooks/")
+	case !isSnowflake(segments[0]):
+		return fmt.Errorf("invalid webhook URL: webhook ID %q must be numeric", segments[0])
+	case len(segments) == 1 || segments[1] == "":
+		return fmt.Errorf("invalid webhook URL: missing token after the webhook ID")
+	case !webhookTokenPattern.MatchString(segments[1]):
+		return fmt.Errorf("invalid webhook URL: token contains invalid characters")
+	case len(segments) > 2:
+		return fmt.Errorf("invalid webhook URL: unexpected path after the token: /%s", strings.Join(segments[2:], "/"))
+	}
+
+	return nil
+}
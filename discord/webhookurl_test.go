@@ -0,0 +1,45 @@
+package discord
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr string
+	}{
+		{name: "Valid", url: "https://discord.com/api/webhooks/123456789012345678/abcDEF_123-xyz"},
+		{name: "Legacy host", url: "https://discordapp.com/api/webhooks/123456789012345678/token"},
+		{name: "Canary host", url: "https://canary.discord.com/api/webhooks/123456789012345678/token"},
+		{name: "Versioned API", url: "https://discord.com/api/v10/webhooks/123456789012345678/token"},
+		{name: "Trailing slash", url: "https://discord.com/api/webhooks/123456789012345678/token/"},
+		{name: "Query string", url: "https://discord.com/api/webhooks/123456789012345678/token?thread_id=1"},
+		{name: "HTTP", url: "http://discord.com/api/webhooks/123456789012345678/token", expectedErr: `scheme must be https, got "http"`},
+		{name: "Other host", url: "https://discord.example.com/api/webhooks/123456789012345678/token", expectedErr: `host must be discord.com or discordapp.com, got "discord.example.com"`},
+		{name: "Wrong path", url: "https://discord.com/webhooks/123456789012345678/token", expectedErr: "path must start with /api/webhooks/"},
+		{name: "Missing ID", url: "https://discord.com/api/webhooks/", expectedErr: "missing webhook ID"},
+		{name: "Non-numeric ID", url: "https://discord.com/api/webhooks/12345abc/token", expectedErr: `webhook ID "12345abc" must be numeric`},
+		{name: "Missing token", url: "https://discord.com/api/webhooks/123456789012345678", expectedErr: "missing token"},
+		{name: "Bad token", url: "https://discord.com/api/webhooks/123456789012345678/tok%20en", expectedErr: "token contains invalid characters"},
+		{name: "Extra path", url: "https://discord.com/api/webhooks/123456789012345678/token/slack", expectedErr: "unexpected path after the token: /slack"},
+		{name: "Not a URL", url: "discord webhook", expectedErr: "scheme must be https"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWebhookURL(tt.url)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,104 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/redact"
+)
+
+// ConfigFile checks that the config file at path parses and holds valid
+// values. A missing file is skipped rather than failed, since the other
+// config file or the environment may be used instead.
+func ConfigFile(path string) Result {
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return Result{Status: Skip, Detail: "none at " + path}
+	} else if err != nil {
+		return Result{Status: Fail, Detail: fmt.Sprintf("can't read %s: %v", path, err)}
+	}
+	if _, err := config.NewManager().LoadFromPath(path); err != nil {
+		return Result{Status: Fail, Detail: fmt.Sprintf("%s: %v", path, err)}
+	}
+	return Result{Status: Pass, Detail: path + " is valid"}
+}
+
+// WebhookURL checks that a webhook URL was found and that validate, which
+// knows the provider's format, accepts it
+func WebhookURL(webhookURL string, validate func(string) error) Result {
+	if webhookURL == "" {
+		return Result{Status: Fail, Detail: "no webhook URL set; run owata config --webhook='https://discord.com/api/webhooks/...'"}
+	}
+	if err := validate(webhookURL); err != nil {
+		return Result{Status: Fail, Detail: err.Error()}
+	}
+	return Result{Status: Pass, Detail: redact.URL(webhookURL)}
+}
+
+// DNS checks that host resolves, using lookup such as
+// net.DefaultResolver.LookupHost
+func DNS(ctx context.Context, host string, lookup func(context.Context, string) ([]string, error)) Result {
+	addrs, err := lookup(ctx, host)
+	if err != nil {
+		return Result{Status: Fail, Detail: fmt.Sprintf("can't resolve %s: %v", host, err)}
+	}
+	if len(addrs) == 0 {
+		return Result{Status: Fail, Detail: fmt.Sprintf("%s resolves to no addresses", host)}
+	}
+	return Result{Status: Pass, Detail: fmt.Sprintf("%s resolves to %s", host, addrs[0])}
+}
+
+// HTTPS checks that a HEAD request to the root of rawURL's host gets an
+// answer; any status will do, since it shows the host can be reached. A
+// proxy set in the environment is named in the detail. It also returns the
+// server's clock from the Date header, or zero if there was none, for Clock.
+func HTTPS(ctx context.Context, client *http.Client, rawURL string) (Result, time.Time) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return Result{Status: Fail, Detail: fmt.Sprintf("invalid URL %q", rawURL)}, time.Time{}
+	}
+	root := &url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, root.String(), nil)
+	if err != nil {
+		return Result{Status: Fail, Detail: err.Error()}, time.Time{}
+	}
+	via := ""
+	if proxy, err := http.ProxyFromEnvironment(req); err == nil && proxy != nil {
+		via = " via proxy " + proxy.Redacted()
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{Status: Fail, Detail: fmt.Sprintf("can't reach %s%s: %v", u.Host, via, err)}, time.Time{}
+	}
+	resp.Body.Close()
+
+	serverTime, _ := http.ParseTime(resp.Header.Get("Date"))
+	return Result{Status: Pass, Detail: fmt.Sprintf("%s answered %s in %s%s", u.Host, resp.Status, time.Since(start).Round(time.Millisecond), via)}, serverTime
+}
+
+// Clock compares the local clock with the server's. Skew beyond maxSkew is
+// a warning: notification timestamps come out wrong, and TLS certificates
+// can look expired or not yet valid.
+func Clock(server, local time.Time, maxSkew time.Duration) Result {
+	if server.IsZero() {
+		return Result{Status: Skip, Detail: "the server's time is unknown"}
+	}
+	skew := local.Sub(server).Round(time.Second)
+	if skew.Abs() > maxSkew {
+		direction := "ahead of"
+		if skew < 0 {
+			direction = "behind"
+		}
+		return Result{Status: Warn, Detail: fmt.Sprintf("local clock is %s %s the server's; sync it with NTP", skew.Abs(), direction)}
+	}
+	return Result{Status: Pass, Detail: fmt.Sprintf("within %s of the server's", maxSkew)}
+}
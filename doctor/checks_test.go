@@ -0,0 +1,150 @@
+package doctor
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		os.WriteFile(path, []byte(data), 0644)
+		return path
+	}
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus Status
+		wantDetail string
+	}{
+		{name: "Valid", path: write("valid.json", `{"username": "Bot"}`), wantStatus: Pass, wantDetail: "is valid"},
+		{name: "Missing", path: filepath.Join(dir, "missing.json"), wantStatus: Skip, wantDetail: "none at"},
+		{name: "Invalid JSON", path: write("broken.json", `{"username": `), wantStatus: Fail, wantDetail: "invalid JSON"},
+		{name: "Invalid value", path: write("bad.json", `{"notify_on": "sometimes"}`), wantStatus: Fail, wantDetail: "invalid notify_on"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ConfigFile(tt.path)
+			if got.Status != tt.wantStatus || !strings.Contains(got.Detail, tt.wantDetail) {
+				t.Errorf("Expected status %d with %q, got %+v", tt.wantStatus, tt.wantDetail, got)
+			}
+		})
+	}
+}
+
+func TestWebhookURL(t *testing.T) {
+	valid := func(string) error { return nil }
+	invalid := func(string) error { return errors.New("invalid webhook URL: missing token") }
+
+	tests := []struct {
+		name       string
+		url        string
+		validate   func(string) error
+		wantStatus Status
+		wantDetail string
+	}{
+		{name: "Valid", url: "https://discord.com/api/webhooks/123456789012345678/secret", validate: valid, wantStatus: Pass, wantDetail: "/api/webhooks/123456789012345678/****"},
+		{name: "Not set", validate: valid, wantStatus: Fail, wantDetail: "no webhook URL set"},
+		{name: "Malformed", url: "https://discord.com/api/webhooks/1", validate: invalid, wantStatus: Fail, wantDetail: "missing token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := WebhookURL(tt.url, tt.validate)
+			if got.Status != tt.wantStatus || !strings.Contains(got.Detail, tt.wantDetail) {
+				t.Errorf("Expected status %d with %q, got %+v", tt.wantStatus, tt.wantDetail, got)
+			}
+			if strings.Contains(got.Detail, "secret") {
+				t.Errorf("Expected the token to be redacted, got %q", got.Detail)
+			}
+		})
+	}
+}
+
+func TestDNS(t *testing.T) {
+	tests := []struct {
+		name       string
+		addrs      []string
+		err        error
+		wantStatus Status
+		wantDetail string
+	}{
+		{name: "Resolves", addrs: []string{"162.159.128.233"}, wantStatus: Pass, wantDetail: "discord.com resolves to 162.159.128.233"},
+		{name: "Lookup fails", err: errors.New("no such host"), wantStatus: Fail, wantDetail: "can't resolve discord.com: no such host"},
+		{name: "No addresses", wantStatus: Fail, wantDetail: "no addresses"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := func(context.Context, string) ([]string, error) { return tt.addrs, tt.err }
+			got := DNS(context.Background(), "discord.com", lookup)
+			if got.Status != tt.wantStatus || !strings.Contains(got.Detail, tt.wantDetail) {
+				t.Errorf("Expected status %d with %q, got %+v", tt.wantStatus, tt.wantDetail, got)
+			}
+		})
+	}
+}
+
+func TestHTTPS(t *testing.T) {
+	date := time.Date(2025, 7, 3, 12, 0, 0, 0, time.UTC)
+	var method, path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		method, path = r.Method, r.URL.Path
+		w.Header().Set("Date", date.Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	got, serverTime := HTTPS(context.Background(), server.Client(), server.URL+"/api/webhooks/1/secret")
+	if got.Status != Pass || !strings.Contains(got.Detail, "answered 404 Not Found") {
+		t.Errorf("Expected any answer to pass, got %+v", got)
+	}
+	if method != http.MethodHead || path != "/" {
+		t.Errorf("Expected HEAD / without the webhook path, got %s %s", method, path)
+	}
+	if !serverTime.Equal(date) {
+		t.Errorf("Expected the server time %s, got %s", date, serverTime)
+	}
+
+	server.Close()
+	got, serverTime = HTTPS(context.Background(), server.Client(), server.URL)
+	if got.Status != Fail || !strings.Contains(got.Detail, "can't reach") || !serverTime.IsZero() {
+		t.Errorf("Expected an unreachable host to fail, got %+v", got)
+	}
+}
+
+func TestClock(t *testing.T) {
+	server := time.Date(2025, 7, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name       string
+		server     time.Time
+		local      time.Time
+		wantStatus Status
+		wantDetail string
+	}{
+		{name: "In sync", server: server, local: server.Add(3 * time.Second), wantStatus: Pass},
+		{name: "Ahead", server: server, local: server.Add(10 * time.Minute), wantStatus: Warn, wantDetail: "10m0s ahead of"},
+		{name: "Behind", server: server, local: server.Add(-2 * time.Hour), wantStatus: Warn, wantDetail: "2h0m0s behind"},
+		{name: "Unknown", local: server, wantStatus: Skip},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := Clock(tt.server, tt.local, time.Minute)
+			if got.Status != tt.wantStatus || !strings.Contains(got.Detail, tt.wantDetail) {
+				t.Errorf("Expected status %d with %q, got %+v", tt.wantStatus, tt.wantDetail, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package doctor
+
+import (
+	"context"
+	"fmt"
+)
+
+// Status is the outcome of a check
+type Status int
+
+const (
+	Pass Status = iota
+	Warn        // Worth knowing about, but notifications can still work
+	Fail        // Notifications won't work until this is fixed
+	Skip        // Not run, usually because an earlier check failed
+)
+
+// Result is what a check found
+type Result struct {
+	Name   string
+	Status Status
+	Detail string
+}
+
+// Check is one item of the checklist. Checks run in order, so a check can
+// skip itself when one it depends on failed.
+type Check struct {
+	Name string
+	Run  func(ctx context.Context) Result
+}
+
+// Run runs the checks in order and returns their results, naming each result
+// after its check
+func Run(ctx context.Context, checks []Check) []Result {
+	results := make([]Result, 0, len(checks))
+	for _, check := range checks {
+		result := check.Run(ctx)
+		result.Name = check.Name
+		results = append(results, result)
+	}
+	return results
+}
+
+// Summary counts the results by status, as in "5 passed, 1 warning, 1
+// failed", and reports whether any check failed
+func Summary(results []Result) (string, bool) {
+	counts := map[Status]int{}
+	for _, r := range results {
+		counts[r.Status]++
+	}
+	summary := fmt.Sprintf("%d passed, %d %s, %d failed", counts[Pass], counts[Warn], plural(counts[Warn], "warning"), counts[Fail])
+	if counts[Skip] > 0 {
+		summary += fmt.Sprintf(", %d skipped", counts[Skip])
+	}
+	return summary, counts[Fail] > 0
+}
+
+func plural(n int, word string) string {
+	if n == 1 {
+		return word
+	}
+	return word + "s"
+}
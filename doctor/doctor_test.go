@@ -0,0 +1,50 @@
+package doctor
+
+import (
+	"context"
+	"testing"
+)
+
+func TestRun(t *testing.T) {
+	var order []string
+	check := func(name string, status Status) Check {
+		return Check{Name: name, Run: func(context.Context) Result {
+			order = append(order, name)
+			return Result{Status: status, Detail: name + " detail"}
+		}}
+	}
+
+	results := Run(context.Background(), []Check{check("first", Pass), check("second", Fail)})
+	if len(results) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Fatalf("Expected both checks run in order, got %v", order)
+	}
+	if results[1].Name != "second" || results[1].Status != Fail || results[1].Detail != "second detail" {
+		t.Errorf("Expected the result named after its check, got %+v", results[1])
+	}
+}
+
+func TestSummary(t *testing.T) {
+	tests := []struct {
+		name       string
+		statuses   []Status
+		want       string
+		wantFailed bool
+	}{
+		{name: "All passed", statuses: []Status{Pass, Pass}, want: "2 passed, 0 warnings, 0 failed"},
+		{name: "One warning", statuses: []Status{Pass, Warn}, want: "1 passed, 1 warning, 0 failed"},
+		{name: "Failed and skipped", statuses: []Status{Pass, Fail, Skip}, want: "1 passed, 0 warnings, 1 failed, 1 skipped", wantFailed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var results []Result
+			for _, status := range tt.statuses {
+				results = append(results, Result{Status: status})
+			}
+			got, failed := Summary(results)
+			if got != tt.want || failed != tt.wantFailed {
+				t.Errorf("Expected %q (failed=%v), got %q (failed=%v)", tt.want, tt.wantFailed, got, failed)
+			}
+		})
+	}
+}
@@ -0,0 +1,120 @@
+package generic
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+// Data is what generic_template is rendered with
+type Data struct {
+	Message   string
+	Title     string
+	Source    string
+	Cwd       string // Empty when hidden with show_cwd in the embed section
+	Host      string // Empty when hidden with --no-host or hide_host
+	Timestamp string // RFC 3339, such as 2025-01-02T15:04:05+09:00
+	Color     int
+	Fields    []notifier.Field // Fields from the config's embed section, --field and compose
+	Username  string
+	RunID     string
+}
+
+// ValidateURL checks that a URL is an absolute http or https URL; generic
+// endpoints have no fixed shape beyond that
+func ValidateURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("invalid webhook URL: scheme must be https or http, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid webhook URL: missing host")
+	}
+	return nil
+}
+
+// Send renders a notification with the configured template and posts it. Any
+// 2xx response counts as delivered.
+func Send(ctx context.Context, webhookURL string, n notifier.Notification, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	request, err := BuildRequest(n, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, result, err := webhook.NewClient(webhookURL, nil).Post(ctx, request, opts)
+	return result, err
+}
+
+// BuildRequest renders generic_template and attaches generic_headers. The
+// body is sent as application/json unless a header sets another Content-Type.
+func BuildRequest(n notifier.Notification, cfg *config.Config) (webhook.Request, error) {
+	if feature := n.DiscordOnly(); feature != "" {
+		return webhook.Request{}, fmt.Errorf("generic webhooks don't support %s", feature)
+	}
+
+	if cfg == nil || cfg.GenericTemplate == "" {
+		return webhook.Request{}, fmt.Errorf("generic_template is not set in config")
+	}
+	tmpl, err := cfg.BodyTemplate()
+	if err != nil {
+		return webhook.Request{}, fmt.Errorf("invalid generic_template in config: %v", err)
+	}
+
+	data, err := NewData(n, cfg)
+	if err != nil {
+		return webhook.Request{}, err
+	}
+	var body bytes.Buffer
+	if err := tmpl.Execute(&body, data); err != nil {
+		return webhook.Request{}, fmt.Errorf("failed to render generic_template: %v", err)
+	}
+
+	header := make(http.Header)
+	for name, value := range cfg.GenericHeaders {
+		header.Set(name, value)
+	}
+
+	return webhook.Request{
+		Service: "generic",
+		Header:  header,
+		Body:    body.Bytes(),
+	}, nil
+}
+
+// NewData collects the template variables for a notification
+func NewData(n notifier.Notification, cfg *config.Config) (Data, error) {
+	fields, err := notifier.ResolveFields(n, cfg)
+	if err != nil {
+		return Data{}, err
+	}
+	title, err := notifier.ResolveTitle(n, cfg)
+	if err != nil {
+		return Data{}, err
+	}
+	username, _ := notifier.ResolveIdentity(cfg)
+
+	return Data{
+		Message:   n.Message,
+		Title:     title,
+		Source:    n.Source,
+		Cwd:       notifier.ResolveCwd(cfg),
+		Host:      notifier.ResolveHost(n, cfg),
+		Timestamp: time.Now().Format(time.RFC3339),
+		Color:     cmp.Or(n.Color, cfg.Color(), notifier.DefaultColor),
+		Fields:    fields,
+		Username:  username,
+		RunID:     n.RunID,
+	}, nil
+}
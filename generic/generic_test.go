@@ -0,0 +1,141 @@
+package generic
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+const testTemplate = `{"summary": {{json .Message}}, "source": {{json .Source}}, "title": {{json .Title}}, "at": {{json .Timestamp}}}`
+
+func TestBuildRequest(t *testing.T) {
+	cfg := &config.Config{
+		GenericTemplate: testTemplate,
+		GenericHeaders:  map[string]string{"X-Routing-Key": "abc123"},
+	}
+	n := notifier.Notification{Message: `Build "main" failed`, Source: "CI"}
+
+	request, err := BuildRequest(n, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	var body map[string]string
+	if err := json.Unmarshal(request.Body, &body); err != nil {
+		t.Fatalf("Rendered body isn't valid JSON: %v\n%s", err, request.Body)
+	}
+	if body["summary"] != n.Message || body["source"] != "CI" || body["title"] != notifier.DefaultTitle || body["at"] == "" {
+		t.Errorf("Unexpected body: %v", body)
+	}
+	if got := request.Header.Get("X-Routing-Key"); got != "abc123" {
+		t.Errorf("Expected the configured header, got %q", got)
+	}
+	if request.ContentType != "" {
+		t.Errorf("Expected the default content type, got %q", request.ContentType)
+	}
+}
+
+func TestNewDataEmbedDefaults(t *testing.T) {
+	hidden := false
+	cfg := &config.Config{
+		DefaultTitle: "Build",
+		Embed:        config.EmbedDefaults{Title: "Deploy", ShowCwd: &hidden, Fields: []string{"Environment=staging"}},
+	}
+	data, err := NewData(notifier.Notification{Message: "Done", Fields: []notifier.Field{{Name: "Branch", Value: "main"}}}, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if data.Title != "Deploy" || data.Cwd != "" {
+		t.Errorf("Expected the embed section's title and no working directory, got %+v", data)
+	}
+	want := []notifier.Field{{Name: "Environment", Value: "staging"}, {Name: "Branch", Value: "main"}}
+	if !slices.Equal(data.Fields, want) {
+		t.Errorf("Expected fields %+v, got %+v", want, data.Fields)
+	}
+}
+
+func TestBuildRequestErrors(t *testing.T) {
+	tests := []struct {
+		name        string
+		n           notifier.Notification
+		cfg         *config.Config
+		expectedErr string
+	}{
+		{"No config", notifier.Notification{Message: "msg"}, nil, "generic_template is not set"},
+		{"No template", notifier.Notification{Message: "msg"}, &config.Config{}, "generic_template is not set"},
+		{"Unknown variable", notifier.Notification{Message: "msg"}, &config.Config{GenericTemplate: "{{.Nope}}"}, "failed to render generic_template"},
+		{"Attachments", notifier.Notification{Message: "msg", Attachments: []string{"log.txt"}}, &config.Config{GenericTemplate: testTemplate}, "don't support file attachments"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildRequest(tt.n, tt.cfg)
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		expectedErr string
+	}{
+		{"Accepted", http.StatusAccepted, ""},
+		{"Rejected", http.StatusBadRequest, "generic webhook returned status 400: bad routing key"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if got := r.Header.Get("Content-Type"); got != "application/json" {
+					t.Errorf("Expected application/json, got %q", got)
+				}
+				w.WriteHeader(tt.status)
+				if tt.status >= 300 {
+					io.WriteString(w, "bad routing key")
+				}
+			}))
+			defer server.Close()
+
+			cfg := &config.Config{GenericTemplate: testTemplate}
+			result, err := Send(context.Background(), server.URL, notifier.Notification{Message: "msg"}, cfg, webhook.DefaultSendOptions())
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.StatusCode != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, result.StatusCode)
+			}
+		})
+	}
+}
+
+func TestValidateURL(t *testing.T) {
+	for _, raw := range []string{"https://events.example.com/v2/enqueue", "http://localhost:8080/hook"} {
+		if err := ValidateURL(raw); err != nil {
+			t.Errorf("ValidateURL(%q) returned %v", raw, err)
+		}
+	}
+	for _, raw := range []string{"ftp://example.com/hook", "https:///hook"} {
+		if err := ValidateURL(raw); err == nil {
+			t.Errorf("Expected ValidateURL(%q) to fail", raw)
+		}
+	}
+}
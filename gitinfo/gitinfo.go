@@ -0,0 +1,97 @@
+package gitinfo
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// Sentinel errors
+var (
+	ErrNotRepository = errors.New("not a git repository")
+)
+
+// Runner runs git with the given arguments in dir and returns its output
+type Runner interface {
+	Run(dir string, args ...string) (string, error)
+}
+
+// ExecRunner runs the git binary found on PATH
+type ExecRunner struct{}
+
+// Run implements Runner
+func (ExecRunner) Run(dir string, args ...string) (string, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("git %s: %w", strings.Join(args, " "), err)
+	}
+	return string(out), nil
+}
+
+// Info describes the state of a working tree
+type Info struct {
+	Branch string // "" when HEAD is detached
+	Commit string // Short SHA of HEAD; "" before the first commit
+	Dirty  bool   // Uncommitted changes, including untracked files
+}
+
+// Read collects the branch, commit and dirty state of the working tree
+// containing dir, returning ErrNotRepository if there isn't one or git isn't
+// installed
+func Read(r Runner, dir string) (*Info, error) {
+	inside, err := r.Run(dir, "rev-parse", "--is-inside-work-tree")
+	if err != nil || strings.TrimSpace(inside) != "true" {
+		return nil, ErrNotRepository
+	}
+
+	var info Info
+	if branch, err := r.Run(dir, "rev-parse", "--abbrev-ref", "HEAD"); err == nil {
+		if branch = strings.TrimSpace(branch); branch != "HEAD" {
+			info.Branch = branch
+		}
+	}
+	if commit, err := r.Run(dir, "rev-parse", "--short", "HEAD"); err == nil {
+		info.Commit = strings.TrimSpace(commit)
+	}
+	if info.Commit == "" {
+		// A new repository has no HEAD yet, but symbolic-ref still names the branch
+		if branch, err := r.Run(dir, "symbolic-ref", "--short", "HEAD"); err == nil {
+			info.Branch = strings.TrimSpace(branch)
+		}
+	}
+
+	status, err := r.Run(dir, "status", "--porcelain")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read git status: %v", err)
+	}
+	info.Dirty = strings.TrimSpace(status) != ""
+
+	return &info, nil
+}
+
+// Fields renders the info as inline embed fields
+func (i *Info) Fields() []notifier.Field {
+	branch := i.Branch
+	if branch == "" {
+		branch = "(detached)"
+	}
+	commit := i.Commit
+	if commit == "" {
+		commit = "(no commits)"
+	}
+	state := "clean"
+	if i.Dirty {
+		state = "dirty"
+	}
+
+	return []notifier.Field{
+		{Name: "Branch", Value: branch, Inline: true},
+		{Name: "Commit", Value: commit, Inline: true},
+		{Name: "Working Tree", Value: state, Inline: true},
+	}
+}
@@ -0,0 +1,153 @@
+package gitinfo
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeRunner answers git commands from a table instead of running git
+type fakeRunner map[string]string
+
+func (f fakeRunner) Run(dir string, args ...string) (string, error) {
+	out, ok := f[strings.Join(args, " ")]
+	if !ok {
+		return "", errors.New("exit status 128")
+	}
+	return out, nil
+}
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name        string
+		runner      fakeRunner
+		expected    Info
+		expectedErr error
+	}{
+		{
+			name: "Clean branch",
+			runner: fakeRunner{
+				"rev-parse --is-inside-work-tree": "true\n",
+				"rev-parse --abbrev-ref HEAD":     "main\n",
+				"rev-parse --short HEAD":          "abc1234\n",
+				"status --porcelain":              "",
+			},
+			expected: Info{Branch: "main", Commit: "abc1234"},
+		},
+		{
+			name: "Dirty tree",
+			runner: fakeRunner{
+				"rev-parse --is-inside-work-tree": "true\n",
+				"rev-parse --abbrev-ref HEAD":     "feature/login\n",
+				"rev-parse --short HEAD":          "abc1234\n",
+				"status --porcelain":              " M main.go\n?? notes.txt\n",
+			},
+			expected: Info{Branch: "feature/login", Commit: "abc1234", Dirty: true},
+		},
+		{
+			name: "Detached HEAD",
+			runner: fakeRunner{
+				"rev-parse --is-inside-work-tree": "true\n",
+				"rev-parse --abbrev-ref HEAD":     "HEAD\n",
+				"rev-parse --short HEAD":          "abc1234\n",
+				"status --porcelain":              "",
+			},
+			expected: Info{Commit: "abc1234"},
+		},
+		{
+			name: "No commits yet",
+			runner: fakeRunner{
+				"rev-parse --is-inside-work-tree": "true\n",
+				"symbolic-ref --short HEAD":       "main\n",
+				"status --porcelain":              "?? README.md\n",
+			},
+			expected: Info{Branch: "main", Dirty: true},
+		},
+		{
+			name:        "Not a repository",
+			runner:      fakeRunner{},
+			expectedErr: ErrNotRepository,
+		},
+		{
+			name: "Inside the .git directory",
+			runner: fakeRunner{
+				"rev-parse --is-inside-work-tree": "false\n",
+			},
+			expectedErr: ErrNotRepository,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info, err := Read(tt.runner, ".")
+			if tt.expectedErr != nil {
+				if !errors.Is(err, tt.expectedErr) {
+					t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if *info != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, *info)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     Info
+		expected []string
+	}{
+		{"Clean", Info{Branch: "main", Commit: "abc1234"}, []string{"main", "abc1234", "clean"}},
+		{"Dirty", Info{Branch: "main", Commit: "abc1234", Dirty: true}, []string{"main", "abc1234", "dirty"}},
+		{"Detached without commits", Info{}, []string{"(detached)", "(no commits)", "clean"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := tt.info.Fields()
+			if len(fields) != len(tt.expected) {
+				t.Fatalf("Expected %d fields, got %+v", len(tt.expected), fields)
+			}
+			for i, field := range fields {
+				if field.Value != tt.expected[i] || !field.Inline {
+					t.Errorf("Expected inline field %q, got %+v", tt.expected[i], field)
+				}
+			}
+		})
+	}
+}
+
+func TestExecRunner(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	// A fresh temporary directory is not inside any repository
+	dir := t.TempDir()
+	if _, err := Read(ExecRunner{}, dir); !errors.Is(err, ErrNotRepository) {
+		t.Errorf("Expected ErrNotRepository outside a repository, got %v", err)
+	}
+
+	if err := exec.Command("git", "init", "-q", "-b", "main", dir).Run(); err != nil {
+		t.Skipf("git init failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("hi"), 0644); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+
+	info, err := Read(ExecRunner{}, dir)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if info.Branch != "main" || info.Commit != "" || !info.Dirty {
+		t.Errorf("Unexpected info for a new repository: %+v", info)
+	}
+}
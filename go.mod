@@ -1,3 +1,5 @@
 module github.com/yashikota/owata
 
 go 1.24.2
+
+require github.com/BurntSushi/toml v1.6.0
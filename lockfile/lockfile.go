@@ -0,0 +1,88 @@
+package lockfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"time"
+)
+
+// ErrLocked is returned when another process holds the lock for longer than
+// Timeout
+var ErrLocked = errors.New("locked by another process")
+
+// For testing purposes
+var (
+	// Timeout bounds how long Acquire waits for another invocation to
+	// release the lock
+	Timeout = 5 * time.Second
+	// StaleAge is the age after which a leftover lock file is ignored
+	StaleAge = time.Minute
+)
+
+// Acquire takes an exclusive lock by creating the file at path, waiting up
+// to Timeout while another invocation holds it. release removes the file
+// again, unless it's no longer ours. Lock files a crashed invocation left
+// behind are broken once they're older than StaleAge.
+func Acquire(path string) (release func(), err error) {
+	deadline := time.Now().Add(Timeout)
+	for {
+		lock, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			owned, err := lock.Stat()
+			lock.Close()
+			if err != nil {
+				os.Remove(path)
+				return nil, err
+			}
+			return func() { removeOwned(path, owned) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+
+		// A crashed invocation may have left its lock behind
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > StaleAge {
+			breakStale(path, info)
+			continue
+		}
+
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("%w: %s", ErrLocked, path)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// breakStale removes the lock file at path that was found stale as stale.
+// Another invocation may have broken it and taken the lock since, so rather
+// than removing whatever is at path, it moves the file aside first, which
+// only one invocation can do, and checks it's still the stale one. A fresh
+// lock moved by mistake is put back; linking fails rather than replace a
+// lock created in the meantime.
+func breakStale(path string, stale os.FileInfo) {
+	aside := fmt.Sprintf("%s.stale-%d-%d", path, os.Getpid(), time.Now().UnixNano())
+	if err := os.Rename(path, aside); err != nil {
+		return // Already broken by another invocation
+	}
+	if info, err := os.Stat(aside); err == nil && !sameLock(info, stale) {
+		os.Link(aside, path)
+	}
+	os.Remove(aside)
+}
+
+// removeOwned removes the lock file at path if it's still the one Acquire
+// created, so a lock broken as stale and taken by another invocation isn't
+// released from under it
+func removeOwned(path string, owned os.FileInfo) {
+	if info, err := os.Stat(path); err == nil && sameLock(info, owned) {
+		os.Remove(path)
+	}
+}
+
+// sameLock reports whether a and b describe the same lock file. The
+// modification time is compared too, since a lock created right after
+// another was removed may get the same inode.
+func sameLock(a, b os.FileInfo) bool {
+	return os.SameFile(a, b) && a.ModTime().Equal(b.ModTime())
+}
@@ -0,0 +1,181 @@
+package lockfile
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestAcquire(t *testing.T) {
+	originalTimeout := Timeout
+	Timeout = 50 * time.Millisecond
+	defer func() { Timeout = originalTimeout }()
+	path := filepath.Join(t.TempDir(), "state.lock")
+
+	release, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := Acquire(path); !errors.Is(err, ErrLocked) || err.Error() != "locked by another process: "+path {
+		t.Errorf("Expected %v naming the lock file, got %v", ErrLocked, err)
+	}
+
+	release()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+	release, err = Acquire(path)
+	if err != nil {
+		t.Fatalf("Expected the lock to be free again, got %v", err)
+	}
+	release()
+}
+
+func TestStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+	os.WriteFile(path, nil, 0644)
+
+	// A lock left behind by a crashed invocation is broken instead of blocking
+	staleTime := time.Now().Add(-2 * StaleAge)
+	os.Chtimes(path, staleTime, staleTime)
+	release, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Expected the stale lock to be ignored, got %v", err)
+	}
+	release()
+}
+
+func TestBreakStale(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "state.lock")
+	os.WriteFile(path, nil, 0644)
+	staleTime := time.Now().Add(-2 * StaleAge)
+	os.Chtimes(path, staleTime, staleTime)
+	stale, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat lock file: %v", err)
+	}
+
+	// Another invocation broke the stale lock and took it before we did
+	os.Remove(path)
+	os.WriteFile(path, nil, 0644)
+	fresh, err := os.Stat(path)
+	if err != nil {
+		t.Fatalf("Failed to stat lock file: %v", err)
+	}
+
+	breakStale(path, stale)
+	if info, err := os.Stat(path); err != nil || !os.SameFile(info, fresh) {
+		t.Errorf("Expected the fresh lock to be kept, got %v", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 1 {
+		t.Errorf("Expected only the lock file to be left, got %v", entries)
+	}
+
+	// The stale lock itself is removed
+	breakStale(path, fresh)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Expected the lock file to be removed, got %v", err)
+	}
+}
+
+func TestReleaseBrokenLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+	release, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// Our lock was broken as stale and another invocation holds it now
+	os.Remove(path)
+	os.WriteFile(path, nil, 0644)
+
+	release()
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Expected the other invocation's lock to be kept, got %v", err)
+	}
+}
+
+func TestAcquireMissingDirectory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "missing", "state.lock")
+	if _, err := Acquire(path); err == nil || errors.Is(err, ErrLocked) {
+		t.Errorf("Expected the error creating the lock file, got %v", err)
+	}
+}
+
+func TestAcquireExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+	var holders, maxHolders int
+	var mu sync.Mutex
+
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := Acquire(path)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			holders++
+			maxHolders = max(maxHolders, holders)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Errorf("Expected one holder at a time, got %d", maxHolders)
+	}
+}
+
+func TestStaleLockExclusive(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.lock")
+	os.WriteFile(path, nil, 0644)
+	staleTime := time.Now().Add(-2 * StaleAge)
+	os.Chtimes(path, staleTime, staleTime)
+
+	// Invocations finding the same stale lock don't all break into it
+	var holders, maxHolders int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			release, err := Acquire(path)
+			if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+				return
+			}
+			mu.Lock()
+			holders++
+			maxHolders = max(maxHolders, holders)
+			mu.Unlock()
+
+			time.Sleep(time.Millisecond)
+
+			mu.Lock()
+			holders--
+			mu.Unlock()
+			release()
+		}()
+	}
+	wg.Wait()
+
+	if maxHolders != 1 {
+		t.Errorf("Expected one holder at a time, got %d", maxHolders)
+	}
+}
@@ -1,26 +1,114 @@
 package main
 
 import (
+	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net"
+	"net/http"
+	"net/url"
 	"os"
+	"os/exec"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/yashikota/owata/ci"
 	"github.com/yashikota/owata/cli"
+	"github.com/yashikota/owata/compose"
 	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/correlation"
+	"github.com/yashikota/owata/delivery"
 	"github.com/yashikota/owata/discord"
+	"github.com/yashikota/owata/doctor"
+	"github.com/yashikota/owata/generic"
+	"github.com/yashikota/owata/gitinfo"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/ntfy"
+	"github.com/yashikota/owata/placeholder"
+	"github.com/yashikota/owata/redact"
+	"github.com/yashikota/owata/run"
+	"github.com/yashikota/owata/slack"
+	"github.com/yashikota/owata/sysinfo"
+	"github.com/yashikota/owata/telegram"
+	"github.com/yashikota/owata/waitfor"
+	"github.com/yashikota/owata/webhook"
 )
 
 func main() {
+	os.Exit(runMain(os.Args[1:]))
+}
+
+// Exit codes, so scripts can tell why owata failed
+const (
+	exitOK          = 0
+	exitFailure     = 1   // Any failure not listed below
+	exitUsage       = 2   // Invalid flags or arguments
+	exitConfig      = 3   // Config file missing or invalid, or no webhook URL set
+	exitNetwork     = 4   // The service couldn't be reached, timed out or failed with a 5xx
+	exitRejected    = 5   // The service rejected the request with a 4xx
+	exitRateLimited = 6   // Still rate limited once the retries ran out
+	exitCancelled   = 130 // Interrupted with Ctrl-C or SIGTERM
+)
+
+// runMain runs owata with the given arguments and returns the exit code
+func runMain(argv []string) int {
 	// Parse command-line arguments
-	args, err := cli.Parse(os.Args[1:])
+	args, err := cli.Parse(argv)
 	if err != nil {
-		fmt.Println(err)
+		fmt.Fprintln(os.Stderr, err)
 		cli.PrintUsage()
-		os.Exit(1)
+		return exitUsage
+	}
+
+	quiet = args.Quiet
+	plain = usePlainOutput(args)
+	debugLog = discardLog
+	if args.Debug {
+		debugLog = newDebugLogger(os.Stderr)
+		start := time.Now()
+		debugLog.Debug("starting", "version", cli.Version, "go", runtime.Version())
+		defer func() { debugLog.Debug("finished", "elapsed", time.Since(start)) }()
+	}
+
+	// --webhook-file stands in for --webhook from here on
+	if args.WebhookFile != "" {
+		if args.WebhookURL, err = config.ReadWebhookFile(args.WebhookFile); err != nil {
+			return exitCode(err)
+		}
 	}
 
-	// Create a new config manager
+	// Create a new config manager, tied to one file with --config
 	configManager := config.NewManager()
+	if args.ConfigPath != "" {
+		configManager = config.NewManagerForFile(args.ConfigPath)
+	}
+	if args.Profile != "" {
+		configManager.UseProfile(args.Profile)
+	}
+	if args.Debug {
+		configManager.SetLogger(debugLog)
+	}
+
+	// Cancel in-flight requests on Ctrl-C or SIGTERM instead of hanging
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
 	// Handle the appropriate command
 	switch args.Command {
@@ -28,72 +116,415 @@ func main() {
 		cli.PrintUsage()
 
 	case cli.CommandShowVersion:
-		cli.PrintVersion()
+		return exitCode(cli.PrintVersion(args.JSON))
 
 	case cli.CommandInit:
-		if err := handleInit(configManager, args.Global); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
-		}
+		return exitCode(handleInit(ctx, configManager, args))
 
 	case cli.CommandConfig:
-		if err := handleConfig(configManager, args); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		return exitCode(handleConfig(configManager, args))
+
+	case cli.CommandWebhookInfo:
+		return exitCode(handleWebhookInfo(ctx, configManager, args))
+
+	case cli.CommandRun:
+		code, err := handleRun(ctx, configManager, args)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
 		}
+		return code
+
+	case cli.CommandWaitPID:
+		return exitCode(handleWaitPID(ctx, configManager, args))
+	case cli.CommandWaitPort:
+		return exitCode(handleWaitPort(ctx, configManager, args))
+	case cli.CommandWatchFile:
+		return exitCode(handleWatchFile(ctx, configManager, args))
+
+	case cli.CommandCompletion:
+		return exitCode(handleCompletion(configManager, args))
+
+	case cli.CommandTest:
+		return exitCode(handleTest(ctx, configManager, args))
+
+	case cli.CommandDoctor:
+		return exitCode(handleDoctor(ctx, configManager, args))
+
+	case cli.CommandDelete:
+		return exitCode(handleDelete(ctx, configManager, args))
+
+	case cli.CommandCompose:
+		return exitCode(handleCompose(ctx, configManager, args))
 
 	case cli.CommandNotify:
-		if err := handleNotify(configManager, args); err != nil {
-			fmt.Printf("Error: %v\n", err)
-			os.Exit(1)
+		return exitCode(handleNotify(ctx, configManager, args))
+	}
+	return exitOK
+}
+
+// exitCode reports a failed command and returns its exit code, telling an
+// interrupted run and the kinds of failure apart
+func exitCode(err error) int {
+	if err == nil {
+		return exitOK
+	}
+	if errors.Is(err, context.Canceled) {
+		warnf("❌ Cancelled\n")
+		return exitCancelled
+	}
+	fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+
+	var statusErr *webhook.StatusError
+	var netErr *webhook.NetworkError
+	var webhookErr *invalidWebhookError
+	switch {
+	case errors.As(err, &webhookErr) && !webhookErr.fromConfig:
+		return exitUsage
+	case errors.As(err, &webhookErr):
+		return exitConfig
+	case errors.Is(err, config.ErrConfigFileNotFound), errors.Is(err, config.ErrInvalidJSON),
+		errors.Is(err, config.ErrInvalidTOML), errors.Is(err, config.ErrInvalidConfig),
+		errors.Is(err, config.ErrConfigConflict), errors.Is(err, errNoWebhook):
+		return exitConfig
+	case errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusTooManyRequests:
+		return exitRateLimited
+	case errors.As(err, &statusErr) && statusErr.StatusCode < 500:
+		return exitRejected
+	case errors.As(err, &statusErr), errors.As(err, &netErr), errors.Is(err, context.DeadlineExceeded):
+		return exitNetwork
+	}
+	return exitFailure
+}
+
+// quiet is set by --quiet to silence informational output
+var quiet bool
+
+// infof prints informational output, such as "✅ ... sent successfully", to
+// stdout unless --quiet is given. Errors and warnings go through warnf, and
+// output a command was asked for (--json, --dry-run, config display) is
+// printed directly.
+func infof(format string, a ...any) {
+	if !quiet {
+		outf(os.Stdout, format, a...)
+	}
+}
+
+// noticef is infof for notes printed to stderr
+func noticef(format string, a ...any) {
+	if !quiet {
+		outf(os.Stderr, format, a...)
+	}
+}
+
+// warnf prints an error or warning, such as "❌ Target 2 failed", to stderr.
+// Unlike noticef it's printed even with --quiet.
+func warnf(format string, a ...any) {
+	outf(os.Stderr, format, a...)
+}
+
+// discardLog drops everything; debugLog is set to it without --debug
+var discardLog = slog.New(slog.DiscardHandler)
+
+// debugLog receives --debug logs
+var debugLog = discardLog
+
+// newDebugLogger returns the --debug logger, writing to w. Any value that
+// looks like a URL is redacted, so webhook tokens never reach the log.
+func newDebugLogger(w io.Writer) *slog.Logger {
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(_ []string, a slog.Attr) slog.Attr {
+			if a.Value.Kind() == slog.KindString && strings.Contains(a.Value.String(), "://") {
+				return slog.String(a.Key, redact.URL(a.Value.String()))
+			}
+			return a
+		},
+	}))
+}
+
+// plain is set when output should be plain ASCII rather than start lines
+// with emoji: with --no-emoji, NO_COLOR or TERM=dumb, or when stdout isn't a
+// terminal, such as when it goes to a log file
+var plain bool
+
+// plainTags are the ASCII tags for the emoji that tell a line's status. Any
+// other emoji starting a line is dropped.
+var plainTags = map[rune]string{
+	'✅': "[ok]",
+	'❌': "[error]",
+	'⚠': "[warn]",
+	'⏳': "[wait]",
+	'ℹ': "[info]",
+	'➖': "[skip]",
+	'→': "->",
+	'←': "<-",
+}
+
+// outf prints owata's own output to w, rendered for the terminal. Everything
+// with emoji goes through it rather than fmt.Fprintf.
+func outf(w io.Writer, format string, a ...any) {
+	fmt.Fprint(w, render(fmt.Sprintf(format, a...)))
+}
+
+// render returns s as it's printed: as is, or with plain set, with the emoji
+// starting each line turned into an ASCII tag or dropped. Emoji elsewhere,
+// such as in a message or a config value, are left alone.
+func render(s string) string {
+	if !plain {
+		return s
+	}
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		text := strings.TrimLeft(line, " ")
+		r, size := utf8.DecodeRuneInString(text)
+		tag, ok := plainTags[r]
+		if !ok && !unicode.Is(unicode.So, r) {
+			continue
 		}
+		rest := strings.TrimPrefix(text[size:], "\uFE0F")
+		if !ok {
+			tag, rest = "", strings.TrimLeft(rest, " ")
+		}
+		lines[i] = line[:len(line)-len(text)] + tag + rest
+	}
+	return strings.Join(lines, "\n")
+}
+
+// renderWriter renders what's written to it, for output such as --verbose
+// logs that other packages write
+type renderWriter struct {
+	w io.Writer
+}
+
+func (rw renderWriter) Write(p []byte) (int, error) {
+	if _, err := io.WriteString(rw.w, render(string(p))); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// usePlainOutput reports whether output should be plain ASCII: when asked
+// with --no-emoji, NO_COLOR or TERM=dumb, or when stdout isn't a terminal
+func usePlainOutput(args *cli.Args) bool {
+	return args.NoEmoji || os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" || !stdoutIsTerminal()
+}
+
+// For testing purposes
+var (
+	stdinIsTerminal  = func() bool { return isTerminal(os.Stdin) }
+	stdoutIsTerminal = func() bool { return isTerminal(os.Stdout) }
+)
+
+// isTerminal reports whether r is a terminal rather than a pipe or a file.
+// The null device is a character device too, but CI often redirects stdin
+// from it, so it doesn't count.
+func isTerminal(r io.Reader) bool {
+	f, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	if err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return false
 	}
+	null, err := os.Stat(os.DevNull)
+	return err != nil || !os.SameFile(info, null)
 }
 
-func handleInit(cm *config.Manager, global bool) error {
-	path, created, err := cm.CreateTemplate(global)
+// handleInit writes a config template, or in a terminal (or with
+// --interactive) asks for the values instead. An existing config is shown
+// and left alone unless --force is given.
+func handleInit(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	if args.ConfigPath != "" && args.Format != "" && args.Format != config.FormatOf(args.ConfigPath) {
+		return fmt.Errorf("--format=%s doesn't match %s; the extension of --config picks the format", args.Format, args.ConfigPath)
+	}
+	cm.SetFormat(args.Format)
+
+	configPath, err := cm.GetPathWithError(args.Global)
+	if err != nil {
+		return fmt.Errorf("failed to get config path: %w", err)
+	}
+	_, statErr := os.Stat(configPath)
+	replacing := errors.Is(statErr, fs.ErrNotExist) || args.Force
+
+	if args.Interactive || (replacing && stdinIsTerminal()) {
+		if !stdinIsTerminal() {
+			return fmt.Errorf("init --interactive asks in a terminal; without one, use owata config --webhook=... instead")
+		}
+		if !replacing {
+			return fmt.Errorf("%s already exists; add --force to replace it (a backup is kept)", configPath)
+		}
+		return initInteractive(ctx, cm, args, os.Stdin, os.Stdout)
+	}
+
+	if args.Force {
+		path, backup, err := cm.ForceTemplate(args.Global)
+		if backup != "" {
+			infof("📦 Existing config backed up to %s\n", backup)
+		}
+		if err != nil {
+			return err
+		}
+		infof("✅ Fresh configuration template written: %s\n", path)
+		return nil
+	}
+
+	path, created, err := cm.CreateTemplate(args.Global)
 	if err != nil {
 		return err
 	}
 
 	if created {
-		fmt.Printf("✅ Configuration template created: %s\n", path)
-		fmt.Println("\nPlease edit the configuration file and set the following values:")
-		fmt.Println("  webhook_url: Your Discord webhook URL")
-		fmt.Println("  username:    Bot display name (optional)")
-		fmt.Println("  avatar_url:  Bot avatar image URL (optional)")
-		fmt.Println("\nOr use the config command with parameters:")
-		fmt.Println("  owata config --webhook='https://discord.com/api/webhooks/...'")
-		fmt.Println("  owata config --username='MyBot' --avatar='https://example.com/avatar.png'")
+		infof("✅ Configuration template created: %s\n", path)
+		infof("\nPlease edit the configuration file and set the following values:\n")
+		infof("  webhook_url: Your Discord webhook URL\n")
+		infof("  username:    Bot display name (optional)\n")
+		infof("  avatar_url:  Bot avatar image URL (optional)\n")
+		infof("\nOr use the config command with parameters:\n")
+		infof("  owata config --webhook='https://discord.com/api/webhooks/...'\n")
+		infof("  owata config --username='MyBot' --avatar='https://example.com/avatar.png'\n")
+		infof("\nThen check that notifications arrive:\n")
+		infof("  owata test\n")
 	} else {
 		// Config file already exists, display it
-		fmt.Printf("ℹ️ Config file already exists: %s\n", path)
+		infof("ℹ️ Config file already exists: %s\n", path)
 		output, err := cm.DisplayConfig(path)
 		if err != nil {
 			return err
 		}
-		fmt.Print(output)
+		infof("%s", output)
 	}
 
 	return nil
 }
 
+// initInteractive asks for the webhook URL, username and avatar URL on out,
+// reading the answers from in, and saves them. Each value can be skipped, and
+// a webhook URL is checked as it's entered. With a webhook URL it offers to
+// send a test message.
+func initInteractive(ctx context.Context, cm *config.Manager, args *cli.Args, in io.Reader, out io.Writer) error {
+	answers := bufio.NewReader(in)
+	ask := func(question string, check func(string) error) (string, error) {
+		for {
+			fmt.Fprint(out, question)
+			line, err := answers.ReadString('\n')
+			answer := strings.TrimSpace(line)
+			if err != nil && answer == "" {
+				fmt.Fprintln(out)
+				return "", fmt.Errorf("init stopped before all questions were answered; nothing was saved")
+			}
+			if answer == "" || check == nil {
+				return answer, nil
+			}
+			if err := check(answer); err != nil {
+				outf(out, "❌ %v; try again, or press Enter to skip\n", err)
+				continue
+			}
+			return answer, nil
+		}
+	}
+
+	fmt.Fprintln(out, "Setting up owata. Press Enter to skip a question.")
+	cfg := &config.Config{}
+	var err error
+	cfg.WebhookURL, err = ask("Webhook URL: ", func(webhookURL string) error {
+		provider, err := resolveProvider(webhookURL, args, cfg)
+		if err != nil {
+			return err
+		}
+		return validateWebhookURL(webhookURL, provider)
+	})
+	if err != nil {
+		return err
+	}
+	if cfg.Username, err = ask(fmt.Sprintf("Username (default %s): ", config.DefaultUsername), nil); err != nil {
+		return err
+	}
+	cfg.AvatarURL, err = ask("Avatar URL (default none): ", func(avatarURL string) error {
+		if u, err := url.Parse(avatarURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("avatar URL must start with http:// or https://")
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	sendTest := false
+	if cfg.WebhookURL != "" {
+		answer, err := ask("Send a test message now? [Y/n]: ", nil)
+		if err != nil {
+			return err
+		}
+		sendTest = answer != "n" && answer != "N" && !strings.EqualFold(answer, "no")
+	}
+
+	if args.Force {
+		if _, backup, err := cm.ForceTemplate(args.Global); err != nil {
+			return err
+		} else if backup != "" {
+			infof("📦 Existing config backed up to %s\n", backup)
+		}
+	}
+	path, err := cm.Save(cfg, args.Global)
+	if err != nil {
+		return err
+	}
+	infof("✅ Configuration saved: %s\n", path)
+
+	if !sendTest {
+		return nil
+	}
+	testArgs := *args
+	testArgs.Command = cli.CommandTest
+	testArgs.Retries, testArgs.RateLimitRetries = -1, -1
+	return handleTest(ctx, cm, &testArgs)
+}
+
 func handleConfig(cm *config.Manager, args *cli.Args) error {
+	switch args.ConfigAction {
+	case "edit":
+		return handleConfigEdit(cm, args.Global, os.Stdin)
+	case "path":
+		return handleConfigPath(cm, args)
+	}
+	if args.ConfigAction != "" {
+		return handleConfigKey(cm, args)
+	}
+
+	show := args.WebhookURL == "" && args.Username == "" && args.AvatarURL == "" && args.Source == "" && !args.AllowCustomWebhook
+	if !show && (args.Resolved || args.All || args.JSON || args.Reveal) {
+		return errors.New("config --resolved, --all, --json and --reveal only show the config; set values without them")
+	}
+	if args.Reveal && !args.JSON {
+		return errors.New("--reveal needs --json; the config it shows always masks secrets")
+	}
+	if args.All {
+		return showConfigLayers(cm, args)
+	}
+	if args.JSON {
+		return showConfigJSON(cm, args)
+	}
+
+	if args.Resolved {
+		output, err := cm.DisplayResolved(args.Global)
+		if err != nil {
+			return err
+		}
+		outf(os.Stdout, "%s", output)
+		return nil
+	}
+
 	// If no parameters were provided, show current configuration
-	if args.WebhookURL == "" && args.Username == "" && args.AvatarURL == "" {
+	if show {
 		configPath, err := cm.GetPathWithError(args.Global)
 		if err != nil {
-			return fmt.Errorf("failed to get config path: %v", err)
+			return fmt.Errorf("failed to get config path: %w", err)
 		}
 
-		// Check if the config file exists
-		if _, err := os.Stat(configPath); os.IsNotExist(err) {
-			globalFlag := ""
-			if args.Global {
-				globalFlag = " -g"
-			}
-			fmt.Printf("❌ No configuration found at %s. Run 'owata init%s' to create a config file.\n",
-				configPath, globalFlag)
+		// Check if the config file exists, or the environment sets anything
+		if _, err := os.Stat(configPath); os.IsNotExist(err) && len(config.EnvOverrides()) == 0 {
+			outf(os.Stdout, "❌ No configuration found at %s. Run '%s' to create a config file.\n",
+				configPath, initCommand(args))
 			return nil
 		}
 
@@ -102,94 +533,2074 @@ func handleConfig(cm *config.Manager, args *cli.Args) error {
 		if err != nil {
 			return err
 		}
-		fmt.Print(output)
+		outf(os.Stdout, "%s", output)
 		return nil
 	}
 
-	// Load existing config or create new one
 	configPath, pathErr := cm.GetPathWithError(args.Global)
 	if pathErr != nil {
-		return fmt.Errorf("failed to get config path: %v", pathErr)
+		return fmt.Errorf("failed to get config path: %w", pathErr)
 	}
-	cfg, err := cm.LoadFromPath(configPath)
+
+	// Update the config, or with --profile that profile, with provided
+	// values, creating it if there is none
+	path, err := cm.UpdatePath(configPath, func(cfg *config.Config) error {
+		return cfg.EditProfile(args.Profile, func(target *config.Config) error {
+			if args.AllowCustomWebhook {
+				target.AllowCustomWebhook = true
+			}
+			if args.WebhookURL != "" {
+				if err := checkConfigWebhook(args.WebhookURL, args, profileView(cfg, target)); err != nil {
+					return err
+				}
+				target.WebhookURL = args.WebhookURL
+			}
+			if args.Username != "" {
+				target.Username = args.Username
+			}
+			if args.AvatarURL != "" {
+				target.AvatarURL = args.AvatarURL
+			}
+			if args.Source != "" {
+				target.DefaultSource = args.Source
+			}
+			return nil
+		})
+	})
 	if err != nil {
-		// Only create a new config if the file doesn't exist
-		if errors.Is(err, config.ErrConfigFileNotFound) {
-			cfg = &config.Config{}
-		} else {
-			// For other errors (permission issues, invalid JSON, etc.), return the error
-			return fmt.Errorf("failed to load config: %w", err)
-		}
+		return err
 	}
 
-	// Update config with provided values
-	if args.WebhookURL != "" {
-		cfg.WebhookURL = args.WebhookURL
+	infof("✅ Configuration updated in %s\n", path)
+
+	// Display updated config
+	output, err := cm.DisplayConfig(path)
+	if err != nil {
+		return err
 	}
-	if args.Username != "" {
-		cfg.Username = args.Username
+	infof("%s", output)
+	return nil
+}
+
+// profileView returns the settings a webhook URL saved into profile is checked
+// with: the profile's, falling back to the rest of cfg
+func profileView(cfg, profile *config.Config) *config.Config {
+	view := *profile
+	view.AllowCustomWebhook = view.AllowCustomWebhook || cfg.AllowCustomWebhook
+	view.Provider = cmp.Or(view.Provider, cfg.Provider)
+	return &view
+}
+
+// checkConfigWebhook rejects a webhook URL about to be saved that doesn't
+// look like its provider's, unless custom webhooks are allowed
+func checkConfigWebhook(webhookURL string, args *cli.Args, cfg *config.Config) error {
+	provider, err := resolveProvider(webhookURL, args, cfg)
+	if err != nil {
+		return err
 	}
-	if args.AvatarURL != "" {
-		cfg.AvatarURL = args.AvatarURL
+	if !cfg.AllowCustomWebhook {
+		if err := validateWebhookURL(webhookURL, provider); err != nil {
+			return fmt.Errorf("%w (not saved; use --allow-custom-webhook for non-%s endpoints)", err, providers[provider].name)
+		}
 	}
+	return nil
+}
 
-	// Save config
-	path, err := cm.Save(cfg, args.Global)
+// showConfigJSON prints the config as owata config shows it, or with
+// --resolved merged, as JSON for scripts. A missing config file isn't an
+// error here: the output says which file is missing.
+func showConfigJSON(cm *config.Manager, args *cli.Args) error {
+	var view *config.View
+	var err error
+	if args.Resolved {
+		view, err = cm.ViewResolved(args.Global)
+	} else {
+		var configPath string
+		if configPath, err = cm.GetPathWithError(args.Global); err != nil {
+			return fmt.Errorf("failed to get config path: %w", err)
+		}
+		view, err = cm.ViewConfig(configPath)
+	}
+	if err != nil {
+		return err
+	}
+
+	mask := maskSecret
+	if args.Reveal {
+		mask = nil
+	}
+	data, err := view.JSON(mask)
 	if err != nil {
 		return err
 	}
+	fmt.Println(string(data))
+	return nil
+}
 
-	fmt.Printf("✅ Configuration updated in %s\n", path)
+// showConfigLayers prints each layer of the config side by side with the
+// values notifications use, for owata config --all
+func showConfigLayers(cm *config.Manager, args *cli.Args) error {
+	layers, err := cm.ViewLayers(args.Global)
+	if err != nil {
+		return err
+	}
+	if !args.JSON {
+		outf(os.Stdout, "%s", layers.Table(maskSecret))
+		return nil
+	}
 
-	// Display updated config
-	output, err := cm.DisplayConfig(path)
+	mask := maskSecret
+	if args.Reveal {
+		mask = nil
+	}
+	data, err := layers.JSON(mask)
 	if err != nil {
 		return err
 	}
-	fmt.Print(output)
+	fmt.Println(string(data))
 	return nil
 }
 
-func handleNotify(cm *config.Manager, args *cli.Args) error {
-	var webhookURL string
-	var configToUse *config.Config
-	preferGlobal := args.Global
+// handleConfigKey handles config get, set and unset on the config file that
+// would be loaded (or with -g, the global one), or with --profile on that
+// profile in it. get prints just the value so it can be used in $(...), with
+// webhook URLs and tokens masked unless --reveal is given.
+func handleConfigKey(cm *config.Manager, args *cli.Args) error {
+	key := args.ConfigArgs[0]
+
+	switch args.ConfigAction {
+	case "get":
+		cfg, _, err := cm.Load(args.Global)
+		if err != nil {
+			return err
+		}
+		if cfg, err = cfg.WithProfile(args.Profile); err != nil {
+			return err
+		}
+		mask := maskSecret
+		if args.Reveal {
+			mask = nil
+		}
+		value, err := cfg.Get(key, mask)
+		if err != nil {
+			return err
+		}
+		fmt.Println(value)
+		return nil
+
+	case "set":
+		path, err := cm.Update(args.Global, func(cfg *config.Config) error {
+			return cfg.EditProfile(args.Profile, func(target *config.Config) error {
+				if err := target.Set(key, args.ConfigArgs[1]); err != nil {
+					return err
+				}
+				if key == "webhook_url" && target.WebhookURL != "" {
+					return checkConfigWebhook(target.WebhookURL, args, profileView(cfg, target))
+				}
+				return nil
+			})
+		})
+		if err != nil {
+			return err
+		}
+		infof("✅ Set %s in %s\n", key, path)
+		return nil
+
+	default:
+		path, err := cm.Update(args.Global, func(cfg *config.Config) error {
+			// Only an existing profile can have a key unset
+			if _, err := cfg.WithProfile(args.Profile); err != nil {
+				return err
+			}
+			return cfg.EditProfile(args.Profile, func(target *config.Config) error {
+				return target.Unset(key)
+			})
+		})
+		if err != nil {
+			return err
+		}
+		infof("✅ Unset %s in %s\n", key, path)
+		return nil
+	}
+}
 
-	cfg, _, err := cm.Load(preferGlobal)
+// handleConfigEdit opens the config file Load would read (or with -g, the
+// global one) in the user's editor, creating the template first if there is
+// none, and checks it once the editor exits. Invalid JSON or TOML leaves the
+// config unusable, so another edit is offered, reading the answer from in.
+func handleConfigEdit(cm *config.Manager, global bool, in io.Reader) error {
+	path, created, err := cm.EditPath(global)
 	if err != nil {
-		// If no config files exist but we have a webhook URL from command line,
-		// we can still proceed
-		if args.WebhookURL == "" {
-			// We only care about errors if we need the config file's webhook URL
-			if !errors.Is(err, config.ErrConfigFileNotFound) {
-				return fmt.Errorf("failed to load configuration: %w", err)
+		return err
+	}
+	if created {
+		infof("✅ Configuration template created: %s\n", path)
+	}
+
+	editor := editorCommand()
+	answers := bufio.NewReader(in)
+	for {
+		cmd := exec.Command(editor[0], append(editor[1:], path)...)
+		// The editor needs the terminal
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		if err := cmd.Run(); err != nil {
+			if errors.Is(err, exec.ErrNotFound) {
+				return fmt.Errorf("editor %s not found; set $VISUAL or $EDITOR", editor[0])
 			}
+			return fmt.Errorf("editor %s failed: %v", editor[0], err)
 		}
-		// Otherwise just silently continue with command line args only
-	} else {
-		configToUse = cfg
-		if configToUse.WebhookURL != "" && args.WebhookURL == "" {
-			webhookURL = configToUse.WebhookURL
+
+		_, err := cm.LoadFromPath(path)
+		if err == nil {
+			infof("✅ Configuration saved: %s\n", path)
+			return nil
+		}
+		if !errors.Is(err, config.ErrInvalidJSON) && !errors.Is(err, config.ErrInvalidTOML) {
+			return fmt.Errorf("%v (saved in %s; run 'owata config edit' again to fix it)", err, path)
+		}
+
+		outf(os.Stderr, "❌ %v\nEdit again? [Y/n] ", err)
+		answer, readErr := answers.ReadString('\n')
+		answer = strings.ToLower(strings.TrimSpace(answer))
+		if readErr != nil {
+			// No terminal to answer from, such as stdin at EOF
+			fmt.Fprintln(os.Stderr)
+		}
+		if answer == "n" || answer == "no" || (readErr != nil && answer == "") {
+			return fmt.Errorf("%v (saved in %s)", err, path)
 		}
 	}
+}
 
-	if args.WebhookURL != "" {
-		webhookURL = args.WebhookURL
+// handleConfigPath prints the local config path, with -g the global one, or
+// with --resolved the one Load would read. It fails when the file doesn't
+// exist, so scripts can tell.
+func handleConfigPath(cm *config.Manager, args *cli.Args) error {
+	var path string
+	var err error
+	if args.Resolved {
+		path, err = cm.ResolvePath(args.Global)
+	} else {
+		path, err = cm.GetPathWithError(args.Global)
+	}
+	if err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
 	}
+	fmt.Println(path)
 
-	if webhookURL == "" {
-		configType := "local"
-		if args.Global {
-			configType = "global"
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%s does not exist; run '%s' to create it", path, initCommand(args))
+	} else if err != nil {
+		return fmt.Errorf("error checking %s: %v", path, err)
+	}
+	return nil
+}
+
+// initCommand returns the init command that creates the config args refer to
+func initCommand(args *cli.Args) string {
+	switch {
+	case args.ConfigPath != "":
+		return "owata init --config=" + args.ConfigPath
+	case args.Global:
+		return "owata init -g"
+	default:
+		return "owata init"
+	}
+}
+
+// editorCommand returns the editor to open the config in: $VISUAL, then
+// $EDITOR, then vi (notepad on Windows). Either variable may include
+// arguments, as in "code --wait".
+func editorCommand() []string {
+	for _, name := range []string{"VISUAL", "EDITOR"} {
+		if editor := strings.Fields(os.Getenv(name)); len(editor) > 0 {
+			return editor
 		}
-		return fmt.Errorf("no webhook URL provided in command line or %s config", configType)
 	}
+	if runtime.GOOS == "windows" {
+		return []string{"notepad"}
+	}
+	return []string{"vi"}
+}
 
-	sendErr := discord.SendNotification(webhookURL, args.Message, args.Source, configToUse)
-	if sendErr != nil {
-		return sendErr
+// maskSecret hides a secret config value: the token part of a URL, or all of
+// anything else
+func maskSecret(value string) string {
+	if strings.Contains(value, "://") {
+		return redact.URL(value)
 	}
+	return "****"
+}
 
-	fmt.Println("✅ Discord notification sent successfully")
-	return nil
+func handleNotify(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	if args.Batch {
+		return handleBatch(ctx, cm, args, os.Stdin)
+	}
+	if args.Stdin {
+		message, err := readStdinMessage(os.Stdin)
+		if err != nil {
+			return err
+		}
+		args.Message = message
+	}
+	if args.MessageFile != "" {
+		message, err := readMessageFile(args.MessageFile)
+		if err != nil {
+			return err
+		}
+		args.Message = message
+	}
+
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	configToUse = withIdentity(args, configToUse)
+
+	notification, err := buildNotification(args, configToUse, cli.DefaultSource)
+	if err != nil {
+		return err
+	}
+
+	if args.CheckURLs {
+		if err := checkAssetURLs(configToUse, notification, args.Strict); err != nil {
+			return err
+		}
+	}
+
+	return deliverNotification(ctx, webhookURL, notification, args, configToUse)
+}
+
+// deliverNotification sends a notification to webhookURL, or to the named
+// targets, mirrors or fallback chain the flags and config call for, and
+// prints the outcome
+func deliverNotification(ctx context.Context, webhookURL string, notification notifier.Notification, args *cli.Args, configToUse *config.Config) error {
+	opts := sendOptions(args, configToUse)
+
+	named, err := namedTargets(args, configToUse)
+	if err != nil {
+		return err
+	}
+	if len(named) > 1 {
+		for _, target := range named {
+			if err := checkWebhookURL(target, args, configToUse); err != nil {
+				return fmt.Errorf("targets: %w", err)
+			}
+		}
+		if args.DryRun {
+			return printDryRun(named, false, notification, args, configToUse)
+		}
+		return fanOutNotification(ctx, named, notification, args, configToUse, opts)
+	}
+
+	// Named targets and an explicit --webhook bypass the mirrored webhooks
+	if args.WebhookURL == "" && len(named) == 0 && configToUse != nil && len(configToUse.WebhookURLs) > 0 {
+		mirrors := uniqueTargets(append([]string{webhookURL}, configToUse.WebhookURLs...))
+		if len(mirrors) > 1 {
+			if len(configToUse.FallbackChain) > 0 {
+				return fmt.Errorf("fallback_chain cannot be combined with webhook_urls")
+			}
+			for _, target := range mirrors {
+				if err := checkWebhookURL(target, args, configToUse); err != nil {
+					return fmt.Errorf("webhook_urls: %w", err)
+				}
+			}
+			if args.DryRun {
+				return printDryRun(mirrors, false, notification, args, configToUse)
+			}
+			return fanOutNotification(ctx, mirrors, notification, args, configToUse, opts)
+		}
+	}
+
+	targets := []string{webhookURL}
+	if args.WebhookURL == "" && len(named) == 0 && configToUse != nil {
+		// An explicit --webhook or named target bypasses the configured fallbacks
+		for _, target := range configToUse.FallbackChain {
+			if err := checkWebhookURL(target, args, configToUse); err != nil {
+				return fmt.Errorf("fallback_chain: %w", err)
+			}
+			targets = append(targets, target)
+		}
+	}
+
+	if args.DryRun {
+		return printDryRun(targets, true, notification, args, configToUse)
+	}
+
+	if len(targets) == 1 {
+		result, sendErr := sendNotification(ctx, webhookURL, notification, args, configToUse, opts)
+		if sendErr != nil {
+			return sendErr
+		}
+
+		return printSendResult(result, args.JSON, providerName(webhookURL, args, configToUse), "")
+	}
+
+	budget, err := deliveryBudget(configToUse)
+	if err != nil {
+		return err
+	}
+
+	var result *notifier.Result
+	report, err := delivery.Deliver(ctx, targets, budget, webhook.DefaultTimeout, func(ctx context.Context, target string, timeout time.Duration) error {
+		attemptOpts := opts
+		attemptOpts.Timeout = timeout
+		// Each target gets shortened retries so a failing one hands over to
+		// the next instead of spending the budget waiting on itself
+		attemptOpts.Retries = min(opts.Retries, fallbackRetries)
+		attemptOpts.RateLimitRetries = min(opts.RateLimitRetries, fallbackRetries)
+		var sendErr error
+		result, sendErr = sendNotification(ctx, target, notification, args, configToUse, attemptOpts)
+		return sendErr
+	})
+	for i, attempt := range report.Attempts {
+		if attempt.Err != nil && report.Succeeded() {
+			noticef("⚠️ Target %d failed after %s: %v\n", i+1, attempt.Elapsed.Round(time.Millisecond), attempt.Err)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	result.Target = report.Delivered + 1
+
+	via := ""
+	if report.Delivered > 0 {
+		via = fmt.Sprintf(" via fallback target %d", report.Delivered+1)
+	}
+	return printSendResult(result, args.JSON, providerName(targets[report.Delivered], args, configToUse), via)
+}
+
+// handleRun runs args.RunCommand, or args.RunScript through the shell, with
+// its output passed straight through, then notifies how it went. It returns
+// the command's exit code for owata to exit with; failing to notify doesn't
+// change it.
+func handleRun(ctx context.Context, cm *config.Manager, args *cli.Args) (int, error) {
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return 1, err
+	}
+	configToUse = withIdentity(args, configToUse)
+
+	// Give the run an ID unless it's part of one already, so its
+	// notifications and those the command sends with owata go together
+	if args.RunID == "" && os.Getenv("OWATA_RUN_ID") == "" {
+		if args.RunID, err = correlation.GenerateRunID(); err != nil {
+			return 1, err
+		}
+	}
+	argv, program := args.RunCommand, ""
+	if args.RunScript != "" {
+		argv = run.Shell(args.RunScript)
+		// Name the source after the script's program rather than the shell
+		if words := strings.Fields(args.RunScript); len(words) > 0 {
+			program = words[0]
+		}
+	}
+	base, err := buildNotification(args, configToUse, filepath.Base(cmp.Or(program, argv[0])))
+	if err != nil {
+		return 1, err
+	}
+
+	if args.NotifyStart {
+		// The command matters more than the heads-up, so run it regardless
+		start := run.StartNotification(base, cmp.Or(args.RunScript, run.ShellQuote(argv)))
+		if err := deliverNotification(ctx, webhookURL, start, args, configToUse); err != nil {
+			warnf("⚠️ Failed to send start notification: %v\n", err)
+		}
+	}
+
+	var stdout, stderr io.Writer = os.Stdout, os.Stderr
+	var tail *run.Tail
+	if args.Tail > 0 {
+		tail = run.NewTail(args.Tail)
+		stderr = io.MultiWriter(os.Stderr, tail)
+		if !args.TailStderrOnly {
+			stdout = io.MultiWriter(os.Stdout, tail)
+		}
+	}
+
+	result, err := run.Exec(argv, run.Options{
+		Stdin:   os.Stdin,
+		Stdout:  stdout,
+		Stderr:  stderr,
+		Timeout: args.Timeout,
+		Stats:   args.Stats,
+		Env:     []string{"OWATA_RUN_ID=" + base.RunID},
+	})
+	if err != nil {
+		// Like a shell, report a command that couldn't be started with 127
+		return 127, err
+	}
+	result.Script = args.RunScript
+	if tail != nil {
+		result.Output = tail.Lines()
+	}
+
+	filter := run.Filter{
+		NotifyOn:       args.NotifyOn,
+		MinDuration:    args.MinDuration,
+		MinDurationAll: args.MinDurationAppliesTo == cli.AppliesToAll,
+	}
+	if filter.NotifyOn == "" && configToUse != nil {
+		filter.NotifyOn = configToUse.NotifyOn
+	}
+	if !filter.ShouldNotify(result) {
+		return result.ExitCode, nil
+	}
+
+	// Ctrl-C reaches the command too and cancels ctx, but then the
+	// notification is what the user is waiting for; sends time out on their own
+	notification := run.Notification(base, result)
+	if err := deliverNotification(context.WithoutCancel(ctx), webhookURL, notification, args, configToUse); err != nil {
+		warnf("⚠️ Failed to send notification: %v\n", err)
+	}
+	return result.ExitCode, nil
+}
+
+// handleWaitPID waits for a process that is already running to exit, then
+// notifies with its command line and how long owata waited
+func handleWaitPID(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	configToUse = withIdentity(args, configToUse)
+
+	// The command line is gone with the process, so read it first
+	argv := waitfor.Cmdline(args.PID)
+	source := "wait-pid"
+	if len(argv) > 0 {
+		source = filepath.Base(argv[0])
+	}
+	base, err := buildNotification(args, configToUse, source)
+	if err != nil {
+		return err
+	}
+
+	start := time.Now()
+	if err := waitfor.PID(ctx, args.PID, cmp.Or(args.Interval, waitfor.DefaultInterval)); err != nil {
+		return err
+	}
+
+	notification := waitfor.PIDNotification(base, args.PID, argv, time.Since(start))
+	return deliverNotification(ctx, webhookURL, notification, args, configToUse)
+}
+
+// handleWaitPort dials a TCP address until it accepts connections, or with
+// --until-down until it stops, then notifies with how long that took. Giving
+// up at --timeout still notifies, but returns an error so owata exits non-zero
+func handleWaitPort(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	configToUse = withIdentity(args, configToUse)
+
+	base, err := buildNotification(args, configToUse, "wait-port")
+	if err != nil {
+		return err
+	}
+
+	result, err := waitfor.Port(ctx, args.Address, args.UntilDown, cmp.Or(args.Interval, waitfor.DefaultInterval), args.Timeout)
+	if err != nil {
+		return err
+	}
+
+	notification := waitfor.PortNotification(base, result)
+	if err := deliverNotification(ctx, webhookURL, notification, args, configToUse); err != nil {
+		return err
+	}
+	if result.TimedOut {
+		return fmt.Errorf("gave up waiting for %s after %s", args.Address, args.Timeout)
+	}
+	return nil
+}
+
+// handleWatchFile notifies about each change to a file until interrupted, or
+// with --once, after the first. Without --once a failed notification is only
+// a warning, so one network hiccup doesn't end a long watch.
+func handleWatchFile(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	configToUse = withIdentity(args, configToUse)
+
+	base, err := buildNotification(args, configToUse, "watch-file")
+	if err != nil {
+		return err
+	}
+	// Each change appends its own fields to a copy of base
+	base.Fields = slices.Clip(base.Fields)
+
+	opts := waitfor.FileOptions{
+		Events:   args.Events,
+		Interval: cmp.Or(args.Interval, waitfor.DefaultInterval),
+		Debounce: args.Debounce,
+		Once:     args.Once,
+	}
+	if opts.Debounce < 0 {
+		opts.Debounce = waitfor.DefaultDebounce
+	}
+
+	return waitfor.File(ctx, args.WatchPath, opts, func(c waitfor.FileChange) error {
+		notification := waitfor.FileNotification(base, c, configToUse)
+		if err := deliverNotification(ctx, webhookURL, notification, args, configToUse); err != nil {
+			if args.Once {
+				return err
+			}
+			warnf("⚠️ Failed to send notification for %s: %v\n", c.Path, err)
+		}
+		return nil
+	})
+}
+
+// handleCompletion prints the completion script for a shell, or for
+// "targets" the target names in config, one per line, which the scripts use
+// to complete --target
+func handleCompletion(cm *config.Manager, args *cli.Args) error {
+	if args.Shell != "targets" {
+		script, err := cli.CompletionScript(args.Shell)
+		if err != nil {
+			return err
+		}
+		fmt.Print(script)
+		return nil
+	}
+
+	cfg, _, err := cm.Resolve(args.Global)
+	if errors.Is(err, config.ErrConfigFileNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Targets)) {
+		fmt.Println(name)
+	}
+	return nil
+}
+
+// maxMessageInput caps how much of stdin or a --message-file becomes the
+// message, so a runaway log can't exhaust memory or turn into hundreds of
+// split posts
+const maxMessageInput = 64 << 10
+
+// readStdinMessage reads the message for "-" or --stdin from r, up to
+// maxMessageInput bytes, without its trailing newline
+func readStdinMessage(r io.Reader) (string, error) {
+	if isTerminal(r) {
+		return "", fmt.Errorf("- and --stdin read the message from stdin; pipe it in, as in: make 2>&1 | tail -20 | owata -")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxMessageInput+1))
+	if err != nil {
+		return "", fmt.Errorf("failed to read stdin: %v", err)
+	}
+	if len(data) > maxMessageInput {
+		noticef("ℹ️ stdin has more than %d KB; sending only the first %d KB\n", maxMessageInput>>10, maxMessageInput>>10)
+		data = data[:maxMessageInput]
+		// Don't leave half a character at the cut
+		for range utf8.UTFMax - 1 {
+			if r, size := utf8.DecodeLastRune(data); r != utf8.RuneError || size != 1 {
+				break
+			}
+			data = data[:len(data)-1]
+		}
+	}
+
+	message := strings.TrimRight(string(data), "\r\n")
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("no message to send: stdin was empty")
+	}
+	return message, nil
+}
+
+// readMessageFile reads the message for --message-file from path, without a
+// UTF-8 BOM or trailing newline. Messages too long for one post are split
+// when sent, like any other.
+func readMessageFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("message file %s does not exist", path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read message file: %v", err)
+	}
+	if info.IsDir() {
+		return "", fmt.Errorf("message file %s is a directory", path)
+	}
+	if info.Size() > maxMessageInput {
+		return "", fmt.Errorf("message file %s is %s, more than the %d KB a message can be; send it with --attach instead", path, webhook.FormatBytes(info.Size()), maxMessageInput>>10)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read message file: %v", err)
+	}
+	message := strings.TrimRight(strings.TrimPrefix(string(data), "\uFEFF"), "\r\n")
+	if strings.TrimSpace(message) == "" {
+		return "", fmt.Errorf("no message to send: %s is empty", path)
+	}
+	return message, nil
+}
+
+// handleBatch sends a notification for every args.BatchSize lines read from
+// r, one after another, and fails if any of them couldn't be sent
+func handleBatch(ctx context.Context, cm *config.Manager, args *cli.Args, r io.Reader) error {
+	if isTerminal(r) {
+		return fmt.Errorf("--batch reads messages from stdin; pipe them in, as in: cat failures.txt | owata --batch")
+	}
+
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	configToUse = withIdentity(args, configToUse)
+
+	named, err := namedTargets(args, configToUse)
+	if err != nil {
+		return err
+	}
+	if len(named) > 1 {
+		return fmt.Errorf("--batch sends to a single target; pick one with --target")
+	}
+
+	messages, err := readBatch(r, args.BatchSize)
+	if err != nil {
+		return err
+	}
+	if len(messages) == 0 {
+		return fmt.Errorf("no messages to send: stdin had no non-blank lines")
+	}
+
+	base, err := buildNotification(args, configToUse, cli.DefaultSource)
+	if err != nil {
+		return err
+	}
+	opts := sendOptions(args, configToUse)
+
+	sent := 0
+	for i, message := range messages {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		n := base
+		n.Message = message
+		if args.DryRun {
+			if err := printDryRun([]string{webhookURL}, false, n, args, configToUse); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if _, err := sendNotification(ctx, webhookURL, n, args, configToUse, opts); err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return ctxErr
+			}
+			warnf("❌ Message %d failed: %v\n", i+1, err)
+			continue
+		}
+		sent++
+	}
+	if args.DryRun {
+		return nil
+	}
+
+	if failed := len(messages) - sent; failed > 0 {
+		return fmt.Errorf("sent %d/%d, %d failed", sent, len(messages), failed)
+	}
+	infof("✅ Sent %d/%d notifications\n", sent, len(messages))
+	return nil
+}
+
+// readBatch reads the messages of a batch, size lines each, skipping blank
+// lines
+func readBatch(r io.Reader, size int) ([]string, error) {
+	scanner := bufio.NewScanner(r)
+	// Allow lines up to Discord's whole message limit and then some
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+
+	var messages, lines []string
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) == size {
+			messages = append(messages, strings.Join(lines, "\n"))
+			lines = nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read stdin: %v", err)
+	}
+	if len(lines) > 0 {
+		messages = append(messages, strings.Join(lines, "\n"))
+	}
+	return messages, nil
+}
+
+// fanOutNotification sends the notification to every target at once and
+// reports each outcome, failing if any target failed
+func fanOutNotification(ctx context.Context, targets []string, notification notifier.Notification, args *cli.Args, cfg *config.Config, opts webhook.SendOptions) error {
+	results := make([]*notifier.Result, len(targets))
+	attempts := delivery.FanOut(ctx, targets, delivery.DefaultWorkers, func(ctx context.Context, i int, target string) error {
+		result, err := sendNotification(ctx, target, notification, args, cfg, opts)
+		results[i] = result
+		return err
+	})
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if args.JSON {
+		type targetResult struct {
+			Target int              `json:"target"`
+			Result *notifier.Result `json:"result,omitempty"`
+			Error  string           `json:"error,omitempty"`
+		}
+		output := make([]targetResult, len(targets))
+		for i, attempt := range attempts {
+			output[i] = targetResult{Target: i + 1, Result: results[i]}
+			if attempt.Err != nil {
+				output[i].Error = attempt.Err.Error()
+			}
+		}
+		data, err := json.Marshal(output)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result: %v", err)
+		}
+		fmt.Println(string(data))
+	} else {
+		for i, attempt := range attempts {
+			if attempt.Err != nil {
+				warnf("❌ Target %d failed after %s: %v\n", i+1, attempt.Elapsed.Round(time.Millisecond), attempt.Err)
+			} else if err := printSendResult(results[i], false, providerName(targets[i], args, cfg), fmt.Sprintf(" to target %d", i+1)); err != nil {
+				return err
+			}
+		}
+	}
+
+	return delivery.FanOutError(attempts)
+}
+
+// printDryRun shows the requests that would be posted to each target, with
+// secrets redacted, without sending anything. Targets after the first are
+// labelled as fallbacks when fallback is set.
+func printDryRun(targets []string, fallback bool, notification notifier.Notification, args *cli.Args, cfg *config.Config) error {
+	outf(os.Stdout, "🧪 Dry run: nothing was sent\n")
+
+	for i, target := range targets {
+		provider, err := resolveProvider(target, args, cfg)
+		if err != nil {
+			return err
+		}
+		requests, err := providers[provider].build(notification, cfg)
+		if err != nil {
+			return err
+		}
+
+		label := ""
+		switch {
+		case fallback && i > 0:
+			label = fmt.Sprintf("Fallback target %d: ", i+1)
+		case len(targets) > 1:
+			label = fmt.Sprintf("Target %d: ", i+1)
+		}
+
+		for j, request := range requests {
+			part := ""
+			if len(requests) > 1 {
+				part = fmt.Sprintf(", part %d/%d", j+1, len(requests))
+			}
+			fmt.Printf("\n%sPOST %s (%s%s)\n", label, redact.URL(target), providers[provider].name, part)
+			fmt.Printf("Content-Type: %s\n", cmp.Or(request.ContentType, "application/json"))
+			for _, name := range slices.Sorted(maps.Keys(request.Header)) {
+				value := request.Header.Get(name)
+				if isSecretHeader(name) {
+					value = "(redacted)"
+				}
+				fmt.Printf("%s: %s\n", name, value)
+			}
+
+			var body bytes.Buffer
+			if err := json.Indent(&body, request.Body, "", "  "); err != nil {
+				body.Reset()
+				body.Write(request.Body)
+			}
+			fmt.Println(body.String())
+		}
+	}
+
+	if len(notification.Attachments) > 0 {
+		outf(os.Stdout, "\n📎 Attached as multipart form data: %s\n", strings.Join(notification.Attachments, ", "))
+	}
+	return nil
+}
+
+// isSecretHeader reports whether a header likely carries a credential
+func isSecretHeader(name string) bool {
+	name = strings.ToLower(name)
+	for _, word := range []string{"authorization", "token", "key", "secret", "password"} {
+		if strings.Contains(name, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueTargets drops repeated webhook URLs so no channel is posted to twice
+func uniqueTargets(targets []string) []string {
+	seen := make(map[string]bool)
+	var unique []string
+	for _, target := range targets {
+		if target != "" && !seen[target] {
+			seen[target] = true
+			unique = append(unique, target)
+		}
+	}
+	return unique
+}
+
+// printSendResult reports a notification delivered by the named service,
+// either for humans or as JSON for scripts that need the message ID
+func printSendResult(result *notifier.Result, jsonOutput bool, service, via string) error {
+	if jsonOutput {
+		data, err := json.Marshal(result)
+		if err != nil {
+			return fmt.Errorf("failed to marshal result: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	var details []string
+	if result.MessageID != "" {
+		details = append(details, "message id "+result.MessageID)
+	}
+	if result.Attempts > 1 {
+		details = append(details, fmt.Sprintf("%d attempts", result.Attempts))
+	}
+	if result.Elapsed > 0 {
+		details = append(details, result.Elapsed.Round(time.Millisecond).String())
+	}
+
+	if len(details) > 0 {
+		infof("✅ %s notification sent successfully%s (%s)\n", service, via, strings.Join(details, ", "))
+	} else {
+		infof("✅ %s notification sent successfully%s\n", service, via)
+	}
+	return nil
+}
+
+// sendOptions resolves delivery options from flags, then config, then defaults
+func sendOptions(args *cli.Args, cfg *config.Config) webhook.SendOptions {
+	opts := webhook.DefaultSendOptions()
+	if args.RateLimitRetries >= 0 {
+		opts.RateLimitRetries = args.RateLimitRetries
+	} else if cfg != nil && cfg.RateLimitRetries != nil && *cfg.RateLimitRetries >= 0 {
+		opts.RateLimitRetries = *cfg.RateLimitRetries
+	}
+	if args.Retries >= 0 {
+		opts.Retries = args.Retries
+	} else if cfg != nil && cfg.Retries > 0 {
+		opts.Retries = cfg.Retries
+	}
+	if args.Verbose {
+		opts.Log = renderWriter{os.Stderr}
+	}
+	if args.Debug {
+		opts.Logger = debugLog
+	}
+	return opts
+}
+
+// fallbackRetries caps the transient and rate limit retries of each target in
+// a fallback chain
+const fallbackRetries = 1
+
+// deliveryBudget parses the configured overall time limit for a fallback chain
+func deliveryBudget(cfg *config.Config) (time.Duration, error) {
+	if cfg == nil || cfg.DeliveryBudget == "" {
+		return 0, nil
+	}
+	budget, err := time.ParseDuration(cfg.DeliveryBudget)
+	if err != nil || budget <= 0 {
+		return 0, fmt.Errorf("invalid delivery_budget %q: use a positive duration such as \"30s\"", cfg.DeliveryBudget)
+	}
+	return budget, nil
+}
+
+func handleDelete(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	if err := requireDiscord(webhookURL, "delete", args, configToUse); err != nil {
+		return err
+	}
+
+	if err := discord.DeleteNotification(ctx, webhookURL, args.MessageID, args.ThreadID); err != nil {
+		return err
+	}
+
+	infof("✅ Discord message %s deleted successfully\n", args.MessageID)
+	return nil
+}
+
+func handleCompose(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	session := composeSession(args)
+
+	switch args.ComposeAction {
+	case cli.ComposeAddField:
+		name, value, ok := strings.Cut(args.ComposeArgs[0], "=")
+		if !ok || name == "" {
+			return fmt.Errorf("invalid field %q (use name=value)", args.ComposeArgs[0])
+		}
+		return compose.Update(session, func(b *compose.Buffer) error {
+			b.Fields = append(b.Fields, compose.Field{Name: name, Value: value})
+			return nil
+		})
+
+	case cli.ComposeAddLine:
+		return compose.Update(session, func(b *compose.Buffer) error {
+			b.Lines = append(b.Lines, args.ComposeArgs[0])
+			return nil
+		})
+
+	case cli.ComposeAddFile:
+		path, err := filepath.Abs(args.ComposeArgs[0])
+		if err != nil {
+			return fmt.Errorf("failed to resolve file path: %w", err)
+		}
+		if _, err := os.Stat(path); err != nil {
+			return fmt.Errorf("cannot attach %s: %w", args.ComposeArgs[0], err)
+		}
+		return compose.Update(session, func(b *compose.Buffer) error {
+			b.Files = append(b.Files, path)
+			return nil
+		})
+
+	case cli.ComposeStatus:
+		return compose.Update(session, func(b *compose.Buffer) error {
+			b.Status = args.ComposeArgs[0]
+			return nil
+		})
+
+	case cli.ComposeShow:
+		buffer, err := compose.Load(session)
+		if err != nil {
+			return err
+		}
+		outf(os.Stdout, "%s", formatComposeBuffer(session, buffer))
+		return nil
+
+	case cli.ComposeClear:
+		if err := compose.Clear(session); err != nil {
+			return err
+		}
+		infof("✅ Compose buffer cleared (session %s)\n", session)
+		return nil
+
+	case cli.ComposeSend:
+		webhookURL, configToUse, err := resolveWebhook(cm, args)
+		if err != nil {
+			return err
+		}
+		configToUse = withIdentity(args, configToUse)
+
+		if args.DryRun {
+			// Leave the buffer in place so it can still be sent
+			b, err := compose.Load(session)
+			if err != nil {
+				return err
+			}
+			notification, err := composeNotification(args, configToUse, session, b)
+			if err != nil {
+				return err
+			}
+			return printDryRun([]string{webhookURL}, false, notification, args, configToUse)
+		}
+
+		var result *notifier.Result
+		err = compose.Take(session, func(b *compose.Buffer) error {
+			notification, err := composeNotification(args, configToUse, session, b)
+			if err != nil {
+				return err
+			}
+
+			var sendErr error
+			result, sendErr = sendNotification(ctx, webhookURL, notification, args, configToUse, sendOptions(args, configToUse))
+			return sendErr
+		})
+		if err != nil {
+			return err
+		}
+
+		return printSendResult(result, args.JSON, providerName(webhookURL, args, configToUse), "")
+	}
+
+	return fmt.Errorf("unknown compose action: %s", args.ComposeAction)
+}
+
+// composeNotification builds the notification a compose buffer is sent as
+func composeNotification(args *cli.Args, cfg *config.Config, session string, b *compose.Buffer) (notifier.Notification, error) {
+	if b.IsEmpty() {
+		return notifier.Notification{}, fmt.Errorf("compose buffer is empty (session %s)", session)
+	}
+
+	notification, err := buildNotification(args, cfg, cli.DefaultSource)
+	if err != nil {
+		return notifier.Notification{}, err
+	}
+	notification.Message = strings.Join(b.Lines, "\n")
+	for _, f := range b.Fields {
+		notification.Fields = append(notification.Fields, notifier.Field{Name: f.Name, Value: f.Value, Inline: f.Inline})
+	}
+	if b.Status != "" {
+		notification.Fields = append(notification.Fields, notifier.Field{Name: "Status", Value: b.Status, Inline: true})
+	}
+	notification.Attachments = append(notification.Attachments, b.Files...)
+
+	if args.CheckURLs {
+		if err := checkAssetURLs(cfg, notification, args.Strict); err != nil {
+			return notifier.Notification{}, err
+		}
+	}
+	return notification, nil
+}
+
+// composeSession picks the compose buffer: the --session flag, then
+// $OWATA_SESSION, then one buffer per parent shell
+func composeSession(args *cli.Args) string {
+	if args.Session != "" {
+		return args.Session
+	}
+	if session := os.Getenv("OWATA_SESSION"); session != "" {
+		return session
+	}
+	return fmt.Sprintf("shell-%d", os.Getppid())
+}
+
+func formatComposeBuffer(session string, b *compose.Buffer) string {
+	output := fmt.Sprintf("\n📝 Compose buffer (session %s):\n", session)
+	if b.IsEmpty() {
+		return output + "  (empty)\n"
+	}
+
+	for _, line := range b.Lines {
+		output += fmt.Sprintf("  ✏️  %s\n", line)
+	}
+	for _, f := range b.Fields {
+		output += fmt.Sprintf("  🏷️  %s: %s\n", f.Name, f.Value)
+	}
+	for _, file := range b.Files {
+		output += fmt.Sprintf("  📎 %s\n", file)
+	}
+	if b.Status != "" {
+		output += fmt.Sprintf("  🚦 Status: %s\n", b.Status)
+	}
+	return output
+}
+
+// testNotification is the clearly labelled message sent by owata test
+var testNotification = notifier.Notification{
+	Title:   "🧪 owata test message",
+	Message: "If you can see this, owata is set up correctly.",
+	Source:  "owata test",
+}
+
+func handleTest(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	configToUse = withIdentity(args, configToUse)
+
+	service := providerName(webhookURL, args, configToUse)
+	infof("🧪 Sending a test message to %s...\n", redact.URL(webhookURL))
+
+	result, err := sendNotification(ctx, webhookURL, testNotification, args, configToUse, sendOptions(args, configToUse))
+	if err != nil {
+		return fmt.Errorf("test message failed: %w", err)
+	}
+
+	infof("✅ %s webhook works: test message delivered in %s\n", service, result.Elapsed.Round(time.Millisecond))
+	return nil
+}
+
+// For testing purposes
+var (
+	lookupHost   = net.DefaultResolver.LookupHost
+	doctorClient = &http.Client{Timeout: 10 * time.Second}
+)
+
+// doctorMarks are the marks printed before each doctor result
+var doctorMarks = map[doctor.Status]string{
+	doctor.Pass: "✅",
+	doctor.Warn: "⚠️",
+	doctor.Fail: "❌",
+	doctor.Skip: "➖",
+}
+
+// handleDoctor checks the config files, the webhook URL, DNS, connectivity
+// and the clock, and with --send posts a test message, printing a line per
+// check. It fails when any check did.
+func handleDoctor(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	var checks []doctor.Check
+	if args.ConfigPath != "" {
+		checks = append(checks, doctor.Check{Name: "Config file (--config)", Run: func(context.Context) doctor.Result {
+			return doctor.ConfigFile(args.ConfigPath)
+		}})
+	} else {
+		for _, global := range []bool{false, true} {
+			name := "Local config"
+			if global {
+				name = "Global config"
+			}
+			checks = append(checks, doctor.Check{Name: name, Run: func(context.Context) doctor.Result {
+				path, err := cm.GetPathWithError(global)
+				if err != nil {
+					return doctor.Result{Status: doctor.Fail, Detail: err.Error()}
+				}
+				return doctor.ConfigFile(path)
+			}})
+		}
+	}
+
+	webhookURL, cfg, webhookErr := resolveWebhook(cm, args)
+	host := "discord.com"
+	target := "https://discord.com"
+	if webhookErr == nil {
+		if u, err := url.Parse(webhookURL); err == nil && u.Host != "" {
+			host, target = u.Hostname(), webhookURL
+		}
+	}
+
+	var serverTime time.Time
+	checks = append(checks,
+		doctor.Check{Name: "Webhook URL", Run: func(context.Context) doctor.Result {
+			switch {
+			case errors.Is(webhookErr, errNoWebhook):
+				return doctor.WebhookURL("", nil)
+			case webhookErr != nil:
+				return doctor.Result{Status: doctor.Fail, Detail: webhookErr.Error()}
+			}
+			return doctor.WebhookURL(webhookURL, func(u string) error { return checkWebhookURL(u, args, cfg) })
+		}},
+		doctor.Check{Name: "DNS", Run: func(ctx context.Context) doctor.Result {
+			return doctor.DNS(ctx, host, lookupHost)
+		}},
+		doctor.Check{Name: "HTTPS", Run: func(ctx context.Context) doctor.Result {
+			var result doctor.Result
+			result, serverTime = doctor.HTTPS(ctx, doctorClient, target)
+			return result
+		}},
+		doctor.Check{Name: "Clock", Run: func(context.Context) doctor.Result {
+			return doctor.Clock(serverTime, time.Now(), time.Minute)
+		}},
+		doctor.Check{Name: "Test message", Run: func(ctx context.Context) doctor.Result {
+			switch {
+			case !args.Send:
+				return doctor.Result{Status: doctor.Skip, Detail: "run with --send to post one"}
+			case webhookErr != nil:
+				return doctor.Result{Status: doctor.Skip, Detail: "no usable webhook URL"}
+			}
+			configToUse := withIdentity(args, cfg)
+			result, err := sendNotification(ctx, webhookURL, testNotification, args, configToUse, sendOptions(args, configToUse))
+			if err != nil {
+				return doctor.Result{Status: doctor.Fail, Detail: err.Error()}
+			}
+			return doctor.Result{Status: doctor.Pass, Detail: fmt.Sprintf("delivered in %s", result.Elapsed.Round(time.Millisecond))}
+		}},
+	)
+
+	results := doctor.Run(ctx, checks)
+	for _, r := range results {
+		outf(os.Stdout, "%s %s: %s\n", doctorMarks[r.Status], r.Name, r.Detail)
+	}
+	summary, failed := doctor.Summary(results)
+	outf(os.Stdout, "\n🩺 %s\n", summary)
+	if failed {
+		return errors.New("doctor found problems; see the failed checks above")
+	}
+	return nil
+}
+
+func handleWebhookInfo(ctx context.Context, cm *config.Manager, args *cli.Args) error {
+	webhookURL, configToUse, err := resolveWebhook(cm, args)
+	if err != nil {
+		return err
+	}
+	if err := requireDiscord(webhookURL, "webhook info", args, configToUse); err != nil {
+		return err
+	}
+
+	info, err := discord.GetWebhookInfo(ctx, webhookURL)
+	if err != nil {
+		return err
+	}
+
+	if args.JSON {
+		data, err := json.Marshal(struct {
+			ID        string `json:"id"`
+			Name      string `json:"name"`
+			AvatarURL string `json:"avatar_url,omitempty"`
+			ChannelID string `json:"channel_id"`
+			GuildID   string `json:"guild_id"`
+		}{info.ID, info.Name, info.AvatarURL(), info.ChannelID, info.GuildID})
+		if err != nil {
+			return fmt.Errorf("failed to marshal webhook info: %v", err)
+		}
+		fmt.Println(string(data))
+		return nil
+	}
+
+	avatarURL := info.AvatarURL()
+	if avatarURL == "" {
+		avatarURL = "(Discord default)"
+	}
+
+	outf(os.Stdout, "\n🪝 Webhook defaults:\n")
+	outf(os.Stdout, "  👤 Name: %s\n", info.Name)
+	outf(os.Stdout, "  🖼️  Avatar URL: %s\n", avatarURL)
+	outf(os.Stdout, "  💬 Channel ID: %s\n", info.ChannelID)
+	outf(os.Stdout, "  🏠 Guild ID: %s\n", info.GuildID)
+	return nil
+}
+
+// checkAssetURLs verifies that the image URLs referenced by the notification
+// are reachable, warning about (or with strict, rejecting) any that are not
+func checkAssetURLs(cfg *config.Config, n notifier.Notification, strict bool) error {
+	var urls []string
+	if cfg != nil && cfg.AvatarURL != "" && !cfg.UseWebhookDefaults {
+		urls = append(urls, cfg.AvatarURL)
+	}
+	if n.Thumbnail != "" {
+		urls = append(urls, n.Thumbnail)
+	} else if cfg != nil && cfg.ThumbnailURL != "" {
+		urls = append(urls, cfg.ThumbnailURL)
+	}
+	if n.Footer.IconURL != "" {
+		urls = append(urls, n.Footer.IconURL)
+	} else if cfg != nil && cfg.FooterIconURL != "" {
+		urls = append(urls, cfg.FooterIconURL)
+	}
+	if n.Author.IconURL != "" {
+		urls = append(urls, n.Author.IconURL)
+	} else if cfg != nil && cfg.AuthorIconURL != "" {
+		urls = append(urls, cfg.AuthorIconURL)
+	}
+	if len(urls) == 0 {
+		return nil
+	}
+
+	var unreachable []string
+	for _, result := range discord.CheckURLs(urls, discord.DefaultURLCheckTimeout) {
+		if !result.OK() {
+			unreachable = append(unreachable, fmt.Sprintf("%s (%s)", result.URL, result.Reason()))
+		}
+	}
+	if len(unreachable) == 0 {
+		return nil
+	}
+
+	if strict {
+		return fmt.Errorf("unreachable URLs: %s", strings.Join(unreachable, ", "))
+	}
+	for _, u := range unreachable {
+		warnf("⚠️ Unreachable URL: %s; consider removing it from your config\n", u)
+	}
+	return nil
+}
+
+// webhookSource says where resolveWebhook found webhookURL, for --debug
+func webhookSource(args *cli.Args, cfg *config.Config, webhookURL string) string {
+	switch {
+	case args.WebhookURL != "":
+		return "--webhook"
+	case len(args.Targets) > 0:
+		return "--target"
+	case cfg != nil && slices.Contains(cfg.FromEnv(), config.EnvVar("webhook_url")):
+		return config.EnvVar("webhook_url")
+	case cfg != nil && cfg.WebhookURL == webhookURL:
+		return "config webhook_url"
+	}
+	return "config"
+}
+
+// errNoWebhook is returned when neither the command line, the environment
+// nor the config gives a webhook URL
+var errNoWebhook = errors.New("no webhook URL provided")
+
+// warnLoosePermissions notes each file cfg was read from that other users
+// can read, as the webhook URL in it lets anyone post to the channel
+func warnLoosePermissions(cfg *config.Config) {
+	for _, path := range cfg.Files() {
+		if mode, loose := config.LoosePermissions(path); loose {
+			noticef("⚠️ %s can be read by other users (mode %04o); run 'chmod 600 %s' to keep the webhook URL private\n", path, mode, path)
+		}
+	}
+}
+
+// resolveWebhook determines the webhook URL from the command line or config,
+// returning the loaded config (if any) alongside it
+func resolveWebhook(cm *config.Manager, args *cli.Args) (string, *config.Config, error) {
+	var webhookURL string
+	var configToUse *config.Config
+	preferGlobal := args.Global
+
+	cfg, _, err := cm.Resolve(preferGlobal)
+	if err != nil {
+		// A file named with --config must exist, whatever else is given
+		if args.ConfigPath != "" {
+			return "", nil, fmt.Errorf("failed to load configuration: %w", err)
+		}
+		// If no config files exist but we have a webhook URL from command line,
+		// we can still proceed
+		if args.WebhookURL == "" {
+			// We only care about errors if we need the config file's webhook URL
+			if !errors.Is(err, config.ErrConfigFileNotFound) {
+				return "", nil, fmt.Errorf("failed to load configuration: %w", err)
+			}
+		}
+		// Otherwise just silently continue with command line args only
+	} else {
+		if cfg, err = chooseTarget(cm, args, cfg, os.Stdin, os.Stderr); err != nil {
+			return "", nil, err
+		}
+		configToUse = cfg
+		warnLoosePermissions(cfg)
+		if args.WebhookURL == "" {
+			webhookURL = configToUse.WebhookURL
+			if webhookURL == "" && len(configToUse.WebhookURLs) > 0 {
+				webhookURL = configToUse.WebhookURLs[0]
+			}
+		}
+	}
+
+	if args.WebhookURL == "" {
+		named, err := namedTargets(args, configToUse)
+		if err != nil {
+			return "", nil, err
+		}
+		if len(named) > 1 && args.Command != cli.CommandNotify {
+			return "", nil, fmt.Errorf("only notifications can be sent to several targets; pick one with --target")
+		}
+		if len(named) > 0 {
+			webhookURL = named[0]
+		}
+	}
+
+	if args.WebhookURL != "" {
+		webhookURL = args.WebhookURL
+	}
+
+	// Telegram bots are addressed by token rather than a webhook URL
+	if webhookURL == "" && configToUse != nil && configToUse.TelegramToken != "" &&
+		cmp.Or(args.Provider, configToUse.Provider) == notifier.Telegram {
+		webhookURL = telegram.SendMessageURL(configToUse.TelegramToken)
+	}
+
+	if webhookURL != "" {
+		debugLog.Debug("using webhook", "url", redact.URL(webhookURL), "from", webhookSource(args, configToUse, webhookURL))
+	}
+
+	if webhookURL == "" {
+		configType := "local"
+		if args.Global {
+			configType = "global"
+		}
+		return "", nil, fmt.Errorf("%w in command line, %s or %s config", errNoWebhook, config.EnvVar("webhook_url"), configType)
+	}
+
+	if err := checkWebhookURL(webhookURL, args, configToUse); err != nil {
+		return "", nil, err
+	}
+
+	return webhookURL, configToUse, nil
+}
+
+// targetChoice is a place the target picker offers to send to: a profile,
+// a named target, or with neither the config's own webhook
+type targetChoice struct {
+	Profile string
+	Target  string
+}
+
+func (c targetChoice) String() string {
+	switch {
+	case c.Profile != "":
+		return "profile " + c.Profile
+	case c.Target != "":
+		return "target " + c.Target
+	}
+	return "default webhook"
+}
+
+// targetChoices lists the profiles and then the named targets in cfg, led by
+// the config's own webhook when no default_profile or default_target picks
+// one. It also returns the index of the choice used without picking, or -1
+// when there is none.
+func targetChoices(cfg *config.Config) ([]targetChoice, int) {
+	var choices []targetChoice
+	def := -1
+	if cfg.Profile() == "" && cfg.DefaultTarget == "" && (cfg.WebhookURL != "" || len(cfg.WebhookURLs) > 0) {
+		choices, def = append(choices, targetChoice{}), 0
+	}
+	for _, name := range cfg.ProfileNames() {
+		if name == cfg.Profile() {
+			def = len(choices)
+		}
+		choices = append(choices, targetChoice{Profile: name})
+	}
+	for _, name := range slices.Sorted(maps.Keys(cfg.Targets)) {
+		if name == cfg.DefaultTarget && def < 0 {
+			def = len(choices)
+		}
+		choices = append(choices, targetChoice{Target: name})
+	}
+	return choices, def
+}
+
+// chooseTarget decides where to send when cfg has several profiles or named
+// targets and no flag or environment variable picks one: the choice
+// remembered for the working directory, else in a terminal the one picked
+// from a list on out, reading the answer from in, else the default with a
+// notice. A picked profile is applied to the config returned, and a picked
+// target set as --target.
+func chooseTarget(cm *config.Manager, args *cli.Args, cfg *config.Config, in io.Reader, out io.Writer) (*config.Config, error) {
+	// Doctor reports on the default rather than sending anywhere, and
+	// stdin carrying the message leaves nothing to answer with
+	if args.Command == cli.CommandDoctor || args.Stdin || args.Batch ||
+		args.WebhookURL != "" || len(args.Targets) > 0 || args.Profile != "" ||
+		os.Getenv(config.EnvProfile) != "" || os.Getenv(config.EnvVar("webhook_url")) != "" {
+		return cfg, nil
+	}
+	choices, def := targetChoices(cfg)
+	if len(choices) < 2 {
+		return cfg, nil
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, err
+	}
+	remembered, err := config.RememberedPick(cwd)
+	if err != nil {
+		return nil, err
+	}
+	i := slices.IndexFunc(choices, func(c targetChoice) bool { return c.String() == remembered })
+	var picked targetChoice
+	switch {
+	case i >= 0:
+		debugLog.Debug("using remembered pick", "dir", cwd, "pick", remembered)
+		picked = choices[i]
+	case stdinIsTerminal():
+		var remember bool
+		if picked, remember, err = pickTarget(choices, def, in, out); err != nil {
+			return nil, err
+		}
+		if remember {
+			if err := config.RememberPick(cwd, picked.String()); err != nil {
+				return nil, err
+			}
+			infof("✅ Sending to the %s in %s from now on\n", picked, cwd)
+		}
+	case def >= 0:
+		noticef("ℹ️ Sending to the %s; pick one of the configured profiles or targets with --profile or --target\n", choices[def])
+		return cfg, nil
+	default:
+		return cfg, nil
+	}
+
+	if picked.Target != "" {
+		args.Targets = []string{picked.Target}
+	}
+	if picked.Profile == "" || picked.Profile == cfg.Profile() {
+		return cfg, nil
+	}
+	cm.UseProfile(picked.Profile)
+	cfg, _, err = cm.Resolve(args.Global)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+// pickTarget lists choices on out, marking the default, and asks which one
+// to send to and whether to always use it here, reading the answers from in.
+// Enter takes the default.
+func pickTarget(choices []targetChoice, def int, in io.Reader, out io.Writer) (targetChoice, bool, error) {
+	answers := bufio.NewReader(in)
+	ask := func(question string) (string, bool) {
+		fmt.Fprint(out, question)
+		line, err := answers.ReadString('\n')
+		answer := strings.TrimSpace(line)
+		if err != nil && answer == "" {
+			fmt.Fprintln(out)
+			return "", false
+		}
+		return answer, true
+	}
+
+	fmt.Fprintln(out, "Several profiles or targets are configured:")
+	for i, choice := range choices {
+		mark := " "
+		if i == def {
+			mark = "*"
+		}
+		fmt.Fprintf(out, "%s %d) %s\n", mark, i+1, choice)
+	}
+	question := fmt.Sprintf("Send to [1-%d]: ", len(choices))
+	if def >= 0 {
+		question = fmt.Sprintf("Send to [1-%d, Enter for %d]: ", len(choices), def+1)
+	}
+
+	var picked targetChoice
+	for {
+		answer, ok := ask(question)
+		if !ok {
+			return targetChoice{}, false, fmt.Errorf("nothing picked; choose with --profile or --target")
+		}
+		if answer == "" && def >= 0 {
+			picked = choices[def]
+			break
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+			picked = choices[n-1]
+			break
+		}
+		outf(out, "❌ Enter a number from 1 to %d\n", len(choices))
+	}
+
+	answer, _ := ask("Always use this here? [y/N]: ")
+	return picked, answer == "y" || answer == "Y" || strings.EqualFold(answer, "yes"), nil
+}
+
+// withIdentity returns the config with the username and avatar to post as:
+// --username and --avatar, then the loaded config, which already falls back
+// to the global config for what a local one leaves unset. Unset ones fall
+// back to the defaults when sending. --no-identity, or use_webhook_defaults
+// in the config, posts as the webhook itself instead, which the flags don't
+// change. The loaded config itself is left untouched.
+func withIdentity(args *cli.Args, cfg *config.Config) *config.Config {
+	resolved := &config.Config{}
+	if cfg != nil {
+		copied := *cfg
+		resolved = &copied
+	}
+
+	if args.NoIdentity {
+		resolved.UseWebhookDefaults = true
+		return resolved
+	}
+	if args.Username != "" || args.AvatarURL != "" {
+		if resolved.UseWebhookDefaults {
+			noticef("⚠️ use_webhook_defaults is set in the config, so --username and --avatar are ignored\n")
+			return resolved
+		}
+		resolved.Username = cmp.Or(args.Username, resolved.Username)
+		resolved.AvatarURL = cmp.Or(args.AvatarURL, resolved.AvatarURL)
+	}
+
+	if cfg == nil && resolved.Username == "" && resolved.AvatarURL == "" {
+		return nil
+	}
+	return resolved
+}
+
+// namedTargets resolves the --target names, or else default_target, to
+// webhook URLs. It returns nil when neither is given.
+func namedTargets(args *cli.Args, cfg *config.Config) ([]string, error) {
+	names := args.Targets
+	if len(names) == 0 && cfg != nil && cfg.DefaultTarget != "" {
+		names = []string{cfg.DefaultTarget}
+	}
+
+	var urls []string
+	for _, name := range names {
+		url, ok := "", false
+		if cfg != nil {
+			url, ok = cfg.Targets[name]
+		}
+		if !ok {
+			if cfg == nil || len(cfg.Targets) == 0 {
+				return nil, fmt.Errorf("unknown target %q (no targets are configured)", name)
+			}
+			available := slices.Sorted(maps.Keys(cfg.Targets))
+			return nil, fmt.Errorf("unknown target %q (available: %s)", name, strings.Join(available, ", "))
+		}
+		urls = append(urls, url)
+	}
+	return uniqueTargets(urls), nil
+}
+
+// checkWebhookURL rejects URLs that don't look like webhooks of their
+// provider unless custom endpoints are allowed by flag or config
+func checkWebhookURL(webhookURL string, args *cli.Args, cfg *config.Config) error {
+	provider, err := resolveProvider(webhookURL, args, cfg)
+	if err != nil {
+		return err
+	}
+	if args.AllowCustomWebhook || (cfg != nil && cfg.AllowCustomWebhook) {
+		return nil
+	}
+	if err := validateWebhookURL(webhookURL, provider); err != nil {
+		return &invalidWebhookError{
+			err: fmt.Errorf("%w (use --allow-custom-webhook for non-%s endpoints)", err, providers[provider].name),
+			// Any URL but --webhook's comes from the config or environment
+			fromConfig: webhookURL != args.WebhookURL,
+		}
+	}
+	return nil
+}
+
+// invalidWebhookError is returned for a webhook URL that doesn't look like
+// one of its provider's. Whether it came from --webhook or the config
+// decides the exit code.
+type invalidWebhookError struct {
+	err        error
+	fromConfig bool
+}
+
+func (e *invalidWebhookError) Error() string { return e.err.Error() }
+
+func (e *invalidWebhookError) Unwrap() error { return e.err }
+
+// validateWebhookURL checks a URL against the provider's webhook format
+func validateWebhookURL(webhookURL, provider string) error {
+	switch provider {
+	case notifier.Slack:
+		return slack.ValidateWebhookURL(webhookURL)
+	case notifier.Ntfy:
+		return ntfy.ValidateTopicURL(webhookURL)
+	case notifier.Telegram:
+		return telegram.ValidateAPIURL(webhookURL)
+	case notifier.Generic:
+		return generic.ValidateURL(webhookURL)
+	}
+	return discord.ValidateWebhookURL(webhookURL)
+}
+
+// provider is a service owata can deliver to
+type provider struct {
+	name  string // Shown in messages
+	send  func(ctx context.Context, webhookURL string, n notifier.Notification, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error)
+	build func(n notifier.Notification, cfg *config.Config) ([]webhook.Request, error)
+}
+
+var providers = map[string]provider{
+	notifier.Discord:  {"Discord", discord.SendNotificationWithOptions, discordRequests},
+	notifier.Slack:    {"Slack", slack.Send, single(slack.BuildRequest)},
+	notifier.Ntfy:     {"ntfy", ntfy.Send, single(ntfy.BuildRequest)},
+	notifier.Telegram: {"Telegram", telegram.Send, single(telegram.BuildRequest)},
+	notifier.Generic:  {"Webhook", generic.Send, single(generic.BuildRequest)},
+}
+
+// single adapts a provider that posts one request per notification
+func single(build func(notifier.Notification, *config.Config) (webhook.Request, error)) func(notifier.Notification, *config.Config) ([]webhook.Request, error) {
+	return func(n notifier.Notification, cfg *config.Config) ([]webhook.Request, error) {
+		request, err := build(n, cfg)
+		if err != nil {
+			return nil, err
+		}
+		return []webhook.Request{request}, nil
+	}
+}
+
+// discordRequests marshals the payload of every message a notification is
+// split into. Attachments are sent alongside the payload as multipart form
+// data.
+func discordRequests(n notifier.Notification, cfg *config.Config) ([]webhook.Request, error) {
+	payloads, err := discord.BuildPayloads(n, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	requests := make([]webhook.Request, len(payloads))
+	for i, payload := range payloads {
+		data, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshaling webhook data: %v", err)
+		}
+		requests[i] = webhook.Request{Service: "discord", Body: data}
+	}
+	return requests, nil
+}
+
+// resolveProvider picks the service a webhook belongs to: --provider, then
+// the config's provider, then a guess from the URL's host
+func resolveProvider(webhookURL string, args *cli.Args, cfg *config.Config) (string, error) {
+	provider := args.Provider
+	if provider == "" && cfg != nil {
+		provider = cfg.Provider
+	}
+	if provider == "" {
+		switch {
+		case slack.IsWebhookURL(webhookURL):
+			return notifier.Slack, nil
+		case ntfy.IsTopicURL(webhookURL):
+			return notifier.Ntfy, nil
+		case telegram.IsAPIURL(webhookURL):
+			return notifier.Telegram, nil
+		}
+		return notifier.Discord, nil
+	}
+	if _, ok := providers[provider]; !ok {
+		return "", fmt.Errorf("unknown provider %q (use %s)", provider, strings.Join(slices.Sorted(maps.Keys(providers)), ", "))
+	}
+	return provider, nil
+}
+
+// sendNotification delivers a notification through the webhook's provider
+func sendNotification(ctx context.Context, webhookURL string, n notifier.Notification, args *cli.Args, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	provider, err := resolveProvider(webhookURL, args, cfg)
+	if err != nil {
+		return nil, err
+	}
+	return providers[provider].send(ctx, webhookURL, n, cfg, opts)
+}
+
+// providerName returns the display name of the webhook's provider
+func providerName(webhookURL string, args *cli.Args, cfg *config.Config) string {
+	provider, err := resolveProvider(webhookURL, args, cfg)
+	if err != nil {
+		return providers[notifier.Discord].name
+	}
+	return providers[provider].name
+}
+
+// requireDiscord rejects commands that only exist for Discord webhooks
+func requireDiscord(webhookURL, command string, args *cli.Args, cfg *config.Config) error {
+	provider, err := resolveProvider(webhookURL, args, cfg)
+	if err != nil {
+		return err
+	}
+	if provider != notifier.Discord {
+		return fmt.Errorf("%s is only supported for Discord webhooks, not %s", command, providers[provider].name)
+	}
+	return nil
+}
+
+// For testing purposes
+var (
+	gitRunner   gitinfo.Runner = gitinfo.ExecRunner{}
+	ciGetenv                   = os.Getenv
+	currentUser                = user.Current
+)
+
+// gitFields describes the git working tree of the current directory. Outside
+// a repository it notes that on stderr and returns no fields, so --git can be
+// left on for directories that aren't always repositories.
+func gitFields() ([]notifier.Field, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get working directory: %v", err)
+	}
+	info, err := gitinfo.Read(gitRunner, cwd)
+	if errors.Is(err, gitinfo.ErrNotRepository) {
+		noticef("ℹ️ %s is not a git repository; sending without git fields\n", cwd)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return info.Fields(), nil
+}
+
+// expandPlaceholders expands {{host}}, {{date}} and the other placeholders
+// in the title, footer and message, and sets n.Expand so the configured
+// title, footer and thread name are expanded too when they're used. A
+// message read from stdin or a file is data rather than a template, so it's
+// sent as is.
+func expandPlaceholders(n *notifier.Notification, args *cli.Args, cfg *config.Config) error {
+	vars := placeholder.Vars{
+		Cwd:        "Unknown",
+		Host:       "Unknown",
+		User:       sysinfo.Read(currentUser, os.Getenv).User,
+		Source:     n.Source,
+		Now:        time.Now().In(cfg.Location()),
+		TimeFormat: config.DefaultTimeFormat,
+		LookupEnv:  os.LookupEnv,
+	}
+	if cwd, err := os.Getwd(); err == nil {
+		vars.Cwd = cwd
+	}
+	if host, err := os.Hostname(); err == nil && host != "" {
+		vars.Host = host
+	}
+	if cfg != nil && cfg.TimeFormat != "" {
+		vars.TimeFormat = cfg.TimeFormat
+	}
+	n.Expand = func(name, text string) (string, error) {
+		expanded, err := placeholder.Expand(name, text, vars)
+		if err != nil {
+			return "", fmt.Errorf("%w (use --no-expand to send it as written)", err)
+		}
+		return expanded, nil
+	}
+
+	var err error
+	if n.Title, err = n.Expand("title", n.Title); err != nil {
+		return err
+	}
+	if n.Footer.Text, err = n.Expand("footer", n.Footer.Text); err != nil {
+		return err
+	}
+	if !args.Stdin && args.MessageFile == "" {
+		if n.Message, err = n.Expand("message", n.Message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// buildNotification assembles the notification content, resolving the run ID
+// and sequence number used to correlate related notifications. source is the
+// mode's own source, used when neither --source nor the config sets one.
+func buildNotification(args *cli.Args, cfg *config.Config, source string) (notifier.Notification, error) {
+	n := notifier.Notification{
+		Message:   args.Message,
+		Source:    args.Source,
+		Title:     args.Title,
+		Thumbnail: args.Thumbnail,
+		Author: notifier.Author{
+			Name:    args.Author,
+			URL:     args.AuthorURL,
+			IconURL: args.AuthorIcon,
+		},
+		Footer: notifier.Footer{
+			Text:    args.Footer,
+			IconURL: args.FooterIcon,
+		},
+		RunID:          args.RunID,
+		Attachments:    args.Attach,
+		NoSplit:        args.NoSplit,
+		Plain:          args.Plain,
+		Code:           args.Code,
+		Lang:           args.Lang,
+		Escape:         args.Escape,
+		NoHost:         args.NoHost,
+		SuppressEmbeds: args.SuppressEmbeds,
+		Silent:         args.Silent,
+		Loud:           args.Loud,
+		Discord: notifier.DiscordOptions{
+			ThreadID:   args.ThreadID,
+			ThreadName: args.ThreadName,
+			TTS:        args.TTS,
+			NoWait:     args.NoWait,
+		},
+	}
+
+	// Without --source, OWATA_SOURCE and then default_source from the config
+	// are used. Run and wait modes name the source after what they watch
+	// rather than "Unknown". It's settled before expanding, for {{source}}.
+	if n.Source == "" && cfg != nil {
+		n.Source = cmp.Or(cfg.Source(), cfg.DefaultSource)
+	}
+	if n.Source == "" {
+		n.Source = source
+	}
+
+	if !args.NoExpand {
+		if err := expandPlaceholders(&n, args, cfg); err != nil {
+			return n, err
+		}
+	}
+
+	if args.EmbedJSON != "" {
+		embeds, err := readEmbedJSON(args.EmbedJSON)
+		if err != nil {
+			return n, err
+		}
+		n.Discord.Embeds = embeds
+	}
+
+	if args.Git || (cfg != nil && cfg.Git) {
+		fields, err := gitFields()
+		if err != nil {
+			return n, err
+		}
+		n.Fields = append(n.Fields, fields...)
+	}
+
+	if !args.NoCI {
+		if info := ci.Detect(ciGetenv); info != nil {
+			n.Fields = append(n.Fields, info.Fields()...)
+		}
+	}
+
+	if args.Sysinfo || (cfg != nil && cfg.Sysinfo) {
+		n.Fields = append(n.Fields, sysinfo.Read(currentUser, os.Getenv).Fields()...)
+	}
+
+	for _, spec := range args.Fields {
+		field, err := notifier.ParseField(spec)
+		if err != nil {
+			return n, err
+		}
+		n.Fields = append(n.Fields, field)
+	}
+
+	for _, spec := range args.Mentions {
+		mention, err := discord.ParseMention(spec)
+		if err != nil {
+			return n, err
+		}
+		n.Discord.Mentions = append(n.Discord.Mentions, mention)
+	}
+
+	if n.RunID == "" {
+		n.RunID = os.Getenv("OWATA_RUN_ID")
+	}
+	if n.RunID != "" {
+		if err := correlation.ValidateRunID(n.RunID); err != nil {
+			return n, err
+		}
+	}
+
+	switch args.Seq {
+	case "":
+	case cli.SeqAuto:
+		if n.RunID == "" {
+			return n, fmt.Errorf("--seq=auto requires --run-id or OWATA_RUN_ID")
+		}
+		seq, err := correlation.NextSeq(n.RunID)
+		if err != nil {
+			return n, fmt.Errorf("failed to update sequence counter: %w", err)
+		}
+		n.Seq = seq
+	default:
+		// Already validated by the CLI parser
+		n.Seq, _ = strconv.Atoi(args.Seq)
+	}
+
+	return n, nil
+}
+
+// readEmbedJSON loads custom embeds from a file, or from stdin for "-",
+// checking that they parse before anything is sent
+func readEmbedJSON(path string) (json.RawMessage, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embed JSON: %w", err)
+	}
+
+	if _, err := discord.ParseEmbeds(data); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return data, nil
 }
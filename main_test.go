@@ -2,17 +2,35 @@ package main
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"os/exec"
+	"os/user"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"slices"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/yashikota/owata/cli"
+	"github.com/yashikota/owata/compose"
 	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/correlation"
 	"github.com/yashikota/owata/discord"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/run"
+	"github.com/yashikota/owata/webhook"
 )
 
 // TestInitCommand tests the init command functionality
@@ -102,6 +120,103 @@ func TestConfigCommand(t *testing.T) {
 	}
 }
 
+// TestConfigWebhookValidation checks that malformed webhook URLs are rejected
+// before being saved unless custom endpoints are allowed
+func TestConfigWebhookValidation(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+
+	manager := config.NewManager()
+
+	oldStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() {
+		w.Close()
+		os.Stdout = oldStdout
+	}()
+
+	err := handleConfig(manager, &cli.Args{Command: cli.CommandConfig, WebhookURL: "https://discord.com/api/webhooks/abc/token"})
+	if err == nil || !strings.Contains(err.Error(), `webhook ID "abc" must be numeric`) {
+		t.Errorf("Expected webhook ID error, got %v", err)
+	}
+	if _, statErr := os.Stat(config.ConfigFileName); !os.IsNotExist(statErr) {
+		t.Error("Expected no config file to be written for an invalid URL")
+	}
+
+	valid := "https://discord.com/api/webhooks/123456789012345678/token"
+	if err := handleConfig(manager, &cli.Args{Command: cli.CommandConfig, WebhookURL: valid}); err != nil {
+		t.Fatalf("Unexpected error for a valid URL: %v", err)
+	}
+
+	custom := "http://localhost:8080/hook"
+	if err := handleConfig(manager, &cli.Args{Command: cli.CommandConfig, WebhookURL: custom, AllowCustomWebhook: true}); err != nil {
+		t.Fatalf("Unexpected error with --allow-custom-webhook: %v", err)
+	}
+	cfg, err := manager.LoadFromPath(config.ConfigFileName)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if cfg.WebhookURL != custom || !cfg.AllowCustomWebhook {
+		t.Errorf("Expected custom webhook to be saved and allowed, got %+v", cfg)
+	}
+}
+
+// TestConfigDefaultSource checks that config --source sets default_source
+// in the local config, or with -g the global one
+func TestConfigDefaultSource(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(t.TempDir())
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	oldStdout := os.Stdout
+	_, w, _ := os.Pipe()
+	os.Stdout = w
+	defer func() {
+		w.Close()
+		os.Stdout = oldStdout
+	}()
+
+	manager := config.NewManager()
+	for _, global := range []bool{false, true} {
+		source := fmt.Sprintf("backend-ci-%v", global)
+		if err := handleConfig(manager, &cli.Args{Command: cli.CommandConfig, Source: source, Global: global}); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		cfg, _, err := manager.Load(global)
+		if err != nil {
+			t.Fatalf("Failed to load config: %v", err)
+		}
+		if cfg.DefaultSource != source {
+			t.Errorf("Expected default_source %q with global=%v, got %q", source, global, cfg.DefaultSource)
+		}
+	}
+}
+
+// TestNotifyRejectsInvalidWebhook checks that no request is attempted with a
+// malformed webhook URL
+func TestNotifyRejectsInvalidWebhook(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	err := handleNotify(context.Background(), config.NewManager(), &cli.Args{
+		Command:    cli.CommandNotify,
+		Message:    "Test message",
+		WebhookURL: "https://discord.com/api/webhook/123456789012345678/token",
+	})
+	if err == nil || !strings.Contains(err.Error(), "path must start with /api/webhooks/") {
+		t.Errorf("Expected path error, got %v", err)
+	}
+}
+
 // TestGlobalConfig tests the global config functionality
 func TestGlobalConfig(t *testing.T) {
 	// Create a temp directory for test
@@ -120,8 +235,8 @@ func TestGlobalConfig(t *testing.T) {
 		t.Fatalf("Failed to create global config: %v", err)
 	}
 
-	// Check global path - should be directly in the config dir now
-	expectedPath := filepath.Join(tempDir, config.ConfigFileName)
+	// Check global path - in owata/ under the config dir
+	expectedPath := filepath.Join(tempDir, config.GlobalConfigDir, config.GlobalFileName)
 	if path != expectedPath {
 		t.Errorf("Expected global path to be %q, got %q", expectedPath, path)
 	}
@@ -185,7 +300,7 @@ func TestNotification(t *testing.T) {
 	}
 
 	// Send notification
-	err := discord.SendNotification(server.URL, "Test message", "TestSource", testConfig)
+	_, err := discord.SendNotification(context.Background(), server.URL, notifier.Notification{Message: "Test message", Source: "TestSource"}, testConfig)
 	if err != nil {
 		t.Fatalf("Failed to send notification: %v", err)
 	}
@@ -365,8 +480,9 @@ func TestHandleNotify(t *testing.T) {
 			r, w, _ := os.Pipe()
 			os.Stdout = w
 
-			// Run the test
-			err := handleNotify(manager, tt.args)
+			// Run the test; the test server isn't a Discord URL
+			tt.args.AllowCustomWebhook = true
+			err := handleNotify(context.Background(), manager, tt.args)
 
 			// Restore stdout
 			w.Close()
@@ -399,29 +515,3429 @@ func TestHandleNotify(t *testing.T) {
 	}
 }
 
-// TestPrintUsage tests the help output using the CLI package's PrintUsage function
-func TestPrintUsage(t *testing.T) {
-	// Redirect stdout to capture output
-	oldStdout := os.Stdout
+// TestTargetChoices tests what the target picker offers and its default
+func TestTargetChoices(t *testing.T) {
+	profiles := map[string]config.Config{"work": {}, "home": {}}
+	targets := map[string]string{"ops": "https://example.com/ops"}
+
+	tests := []struct {
+		name        string
+		cfg         *config.Config
+		expected    []string
+		expectedDef int
+	}{
+		{"Own webhook first", &config.Config{WebhookURL: "https://example.com", Profiles: profiles, Targets: targets}, []string{"default webhook", "profile home", "profile work", "target ops"}, 0},
+		{"Default target", &config.Config{WebhookURL: "https://example.com", Profiles: profiles, Targets: targets, DefaultTarget: "ops"}, []string{"profile home", "profile work", "target ops"}, 2},
+		{"No default", &config.Config{Profiles: profiles}, []string{"profile home", "profile work"}, -1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			choices, def := targetChoices(tt.cfg)
+			var got []string
+			for _, choice := range choices {
+				got = append(got, choice.String())
+			}
+			if !slices.Equal(got, tt.expected) || def != tt.expectedDef {
+				t.Errorf("Expected %q with default %d, got %q with default %d", tt.expected, tt.expectedDef, got, def)
+			}
+		})
+	}
+
+	cfg, _ := (&config.Config{Profiles: profiles, DefaultProfile: "work"}).WithProfile("work")
+	if choices, def := targetChoices(cfg); len(choices) != 2 || def != 1 {
+		t.Errorf("Expected the default profile picked, got %v with default %d", choices, def)
+	}
+}
+
+// TestPickTarget tests answering the target picker
+func TestPickTarget(t *testing.T) {
+	choices := []targetChoice{{}, {Profile: "work"}, {Target: "ops"}}
+
+	tests := []struct {
+		name             string
+		def              int
+		input            string
+		expected         targetChoice
+		expectedRemember bool
+		expectedErr      bool
+		expectedOutput   string
+	}{
+		{name: "Selection", def: 0, input: "2\n\n", expected: targetChoice{Profile: "work"}, expectedOutput: "* 1) default webhook\n  2) profile work\n  3) target ops\n"},
+		{name: "Default accepted", def: 0, input: "\nn\n", expected: targetChoice{}},
+		{name: "Remembered", def: 0, input: "3\ny\n", expected: targetChoice{Target: "ops"}, expectedRemember: true},
+		{name: "Invalid answer asked again", def: 0, input: "4\nwork\n2\n", expected: targetChoice{Profile: "work"}, expectedOutput: "Enter a number from 1 to 3"},
+		{name: "No default to accept", def: -1, input: "\n3\nyes\n", expected: targetChoice{Target: "ops"}, expectedRemember: true, expectedOutput: "Send to [1-3]: "},
+		{name: "Stopped", def: 0, input: "", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			picked, remember, err := pickTarget(choices, tt.def, strings.NewReader(tt.input), &out)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected an error, got %v", picked)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if picked != tt.expected || remember != tt.expectedRemember {
+				t.Errorf("Expected %v (remember %v), got %v (remember %v)", tt.expected, tt.expectedRemember, picked, remember)
+			}
+			if !strings.Contains(out.String(), tt.expectedOutput) {
+				t.Errorf("Expected %q in output:\n%s", tt.expectedOutput, out.String())
+			}
+		})
+	}
+}
+
+// TestChooseTarget tests deciding where to send when several profiles or
+// targets are configured
+func TestChooseTarget(t *testing.T) {
+	defer func() { quiet, plain = false, false }()
+	quiet = true
+	originalTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = originalTerminal }()
+
+	setup := func(t *testing.T, terminal bool) (*config.Manager, *config.Config) {
+		currentDir, _ := os.Getwd()
+		t.Cleanup(func() { os.Chdir(currentDir) })
+		os.Chdir(t.TempDir())
+		config.SetTestConfigDir(t.TempDir())
+		t.Cleanup(config.ResetTestConfigDir)
+		t.Setenv(config.EnvProfile, "")
+		t.Setenv(config.EnvVar("webhook_url"), "")
+		stdinIsTerminal = func() bool { return terminal }
+
+		manager := config.NewManager()
+		if _, err := manager.Save(&config.Config{
+			WebhookURL: "https://example.com/personal",
+			Profiles:   map[string]config.Config{"work": {WebhookURL: "https://example.com/work"}},
+			Targets:    map[string]string{"ops": "https://example.com/ops"},
+		}, false); err != nil {
+			t.Fatalf("Failed to save config: %v", err)
+		}
+		cfg, _, err := manager.Resolve(false)
+		if err != nil {
+			t.Fatalf("Failed to resolve config: %v", err)
+		}
+		return manager, cfg
+	}
+
+	t.Run("Profile picked and remembered", func(t *testing.T) {
+		manager, cfg := setup(t, true)
+		args := &cli.Args{Command: cli.CommandNotify}
+		cfg, err := chooseTarget(manager, args, cfg, strings.NewReader("2\ny\n"), io.Discard)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if cfg.Profile() != "work" || cfg.WebhookURL != "https://example.com/work" {
+			t.Errorf("Expected the work profile, got %q sending to %s", cfg.Profile(), cfg.WebhookURL)
+		}
+
+		// Asked no more in this directory, even without a terminal
+		stdinIsTerminal = func() bool { return false }
+		manager = config.NewManager()
+		cfg, _, _ = manager.Resolve(false)
+		if cfg, err = chooseTarget(manager, &cli.Args{Command: cli.CommandNotify}, cfg, strings.NewReader(""), io.Discard); err != nil || cfg.Profile() != "work" {
+			t.Errorf("Expected the remembered work profile, got %q, %v", cfg.Profile(), err)
+		}
+	})
+
+	t.Run("Target picked", func(t *testing.T) {
+		manager, cfg := setup(t, true)
+		args := &cli.Args{Command: cli.CommandNotify}
+		if _, err := chooseTarget(manager, args, cfg, strings.NewReader("3\n\n"), io.Discard); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !slices.Equal(args.Targets, []string{"ops"}) {
+			t.Errorf("Expected --target=ops, got %v", args.Targets)
+		}
+		cwd, _ := os.Getwd()
+		if pick, _ := config.RememberedPick(cwd); pick != "" {
+			t.Errorf("Expected nothing remembered, got %q", pick)
+		}
+	})
+
+	t.Run("Default without a terminal", func(t *testing.T) {
+		manager, cfg := setup(t, false)
+		args := &cli.Args{Command: cli.CommandNotify}
+		got, err := chooseTarget(manager, args, cfg, strings.NewReader("2\n"), io.Discard)
+		if err != nil || got != cfg || len(args.Targets) != 0 {
+			t.Errorf("Expected the default left alone, got %+v, %v", args.Targets, err)
+		}
+	})
+
+	t.Run("Flag picks", func(t *testing.T) {
+		manager, cfg := setup(t, true)
+		args := &cli.Args{Command: cli.CommandNotify, Targets: []string{"ops"}}
+		got, err := chooseTarget(manager, args, cfg, strings.NewReader(""), io.Discard)
+		if err != nil || got != cfg {
+			t.Errorf("Expected no picker with --target, got %v", err)
+		}
+	})
+}
+
+// TestQuiet tests that --quiet leaves stdout empty on success while errors
+// are still returned
+func TestQuiet(t *testing.T) {
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	quiet = true
+	defer func() { quiet = false }()
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
 	r, w, _ := os.Pipe()
-	os.Stdout = w
+	os.Stdout, os.Stderr = w, w
 
-	// Import and use the PrintUsage function from CLI package
-	cli.PrintUsage()
+	manager := config.NewManager()
+	initErr := handleInit(context.Background(), manager, &cli.Args{Command: cli.CommandInit})
+	configErr := handleConfig(manager, &cli.Args{Command: cli.CommandConfig, WebhookURL: server.URL, AllowCustomWebhook: true})
+	notifyErr := handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", Retries: -1, RateLimitRetries: -1})
+	status = http.StatusBadRequest
+	failErr := handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", Retries: -1, RateLimitRetries: -1})
 
-	// Restore stdout
 	w.Close()
-	os.Stdout = oldStdout
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	var output bytes.Buffer
+	output.ReadFrom(r)
 
-	// Read captured output
-	var buf bytes.Buffer
-	buf.ReadFrom(r)
-	output := buf.String()
+	for name, err := range map[string]error{"init": initErr, "config": configErr, "notify": notifyErr} {
+		if err != nil {
+			t.Errorf("Unexpected %s error: %v", name, err)
+		}
+	}
+	if failErr == nil {
+		t.Error("Expected the failed notification to still return an error")
+	}
+	if output.Len() != 0 {
+		t.Errorf("Expected no output with --quiet, got %q", output.String())
+	}
+}
 
-	// Check for expected content
-	if !strings.Contains(output, "Owata") ||
-		!strings.Contains(output, "Discord Webhook Notifier") ||
-		!strings.Contains(output, "Usage:") {
-		t.Errorf("Help output missing expected content")
+// TestOutputHelpers tests which stream infof, noticef and warnf print to,
+// and that only warnf is kept with --quiet
+func TestOutputHelpers(t *testing.T) {
+	tests := []struct {
+		name           string
+		quiet, plain   bool
+		expectedStdout string
+		expectedStderr string
+	}{
+		{
+			name:           "Default",
+			expectedStdout: "✅ info\n",
+			expectedStderr: "ℹ️ notice\n❌ warning\n",
+		},
+		{
+			name:           "Quiet",
+			quiet:          true,
+			expectedStderr: "❌ warning\n",
+		},
+		{
+			name:           "Plain",
+			plain:          true,
+			expectedStdout: "[ok] info\n",
+			expectedStderr: "[info] notice\n[error] warning\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quiet, plain = tt.quiet, tt.plain
+			defer func() { quiet, plain = false, false }()
+
+			oldStdout, oldStderr := os.Stdout, os.Stderr
+			r, w, _ := os.Pipe()
+			er, ew, _ := os.Pipe()
+			os.Stdout, os.Stderr = w, ew
+
+			infof("✅ info\n")
+			noticef("ℹ️ notice\n")
+			warnf("❌ warning\n")
+
+			w.Close()
+			ew.Close()
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+			var stdout, stderr bytes.Buffer
+			stdout.ReadFrom(r)
+			stderr.ReadFrom(er)
+
+			if stdout.String() != tt.expectedStdout {
+				t.Errorf("Expected stdout %q, got %q", tt.expectedStdout, stdout.String())
+			}
+			if stderr.String() != tt.expectedStderr {
+				t.Errorf("Expected stderr %q, got %q", tt.expectedStderr, stderr.String())
+			}
+		})
+	}
+}
+
+// TestConfigFlag tests that --config reads and writes only the named file,
+// skipping the local and global configs
+func TestConfigFlag(t *testing.T) {
+	var localHits, prodHits int
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		localHits++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer local.Close()
+	prod := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		prodHits++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer prod.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	if _, err := config.NewManager().Save(&config.Config{WebhookURL: local.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "prod.json")
+	manager := config.NewManagerForFile(path)
+	notifyArgs := &cli.Args{Command: cli.CommandNotify, Message: "Deployed", ConfigPath: path, Retries: -1, RateLimitRetries: -1}
+
+	// The file doesn't exist yet, which is an error rather than a fallback
+	if err := handleNotify(context.Background(), manager, notifyArgs); err == nil || !strings.Contains(err.Error(), path) {
+		t.Errorf("Expected a missing config error naming %s, got %v", path, err)
+	}
+
+	configArgs := &cli.Args{Command: cli.CommandConfig, WebhookURL: prod.URL, AllowCustomWebhook: true, ConfigPath: path}
+	if err := handleConfig(manager, configArgs); err != nil {
+		t.Fatalf("Unexpected config error: %v", err)
+	}
+	if err := handleNotify(context.Background(), manager, notifyArgs); err != nil {
+		t.Fatalf("Unexpected notify error: %v", err)
+	}
+	if prodHits != 1 || localHits != 0 {
+		t.Errorf("Expected only the --config webhook to be used, got prod=%d local=%d", prodHits, localHits)
+	}
+
+	cfg, err := config.NewManager().LoadFromPath(config.ConfigFileName)
+	if err != nil || cfg.WebhookURL != local.URL {
+		t.Errorf("Expected the local config untouched, got %+v, %v", cfg, err)
+	}
+}
+
+// TestEnvPrecedence checks every combination of flag, environment, local
+// config and global config for the webhook URL and username, and of flag and
+// environment for the source: flags > environment > local > global
+func TestEnvPrecedence(t *testing.T) {
+	for combo := range 16 {
+		hasFlag, hasEnv, hasLocal, hasGlobal := combo&1 != 0, combo&2 != 0, combo&4 != 0, combo&8 != 0
+		name := fmt.Sprintf("flag=%v env=%v local=%v global=%v", hasFlag, hasEnv, hasLocal, hasGlobal)
+
+		t.Run(name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+			t.Setenv("OWATA_WEBHOOK_URL", "")
+			t.Setenv("OWATA_USERNAME", "")
+
+			webhook := func(layer string) string { return "https://discord.com/api/webhooks/1/" + layer }
+			manager := config.NewManager()
+			args := &cli.Args{Command: cli.CommandNotify, Message: "Done"}
+			want := ""
+			if hasGlobal {
+				manager.Save(&config.Config{WebhookURL: webhook("global"), Username: "global"}, true)
+				want = "global"
+			}
+			if hasLocal {
+				manager.Save(&config.Config{WebhookURL: webhook("local"), Username: "local"}, false)
+				want = "local"
+			}
+			if hasEnv {
+				t.Setenv("OWATA_WEBHOOK_URL", webhook("env"))
+				t.Setenv("OWATA_USERNAME", "env")
+				want = "env"
+			}
+			if hasFlag {
+				args.WebhookURL = webhook("flag")
+				args.Username = "flag"
+				want = "flag"
+			}
+
+			webhookURL, cfg, err := resolveWebhook(manager, args)
+			if want == "" {
+				if err == nil || !strings.Contains(err.Error(), "OWATA_WEBHOOK_URL") {
+					t.Errorf("Expected a missing webhook error mentioning OWATA_WEBHOOK_URL, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if webhookURL != webhook(want) {
+				t.Errorf("Expected the %s webhook, got %s", want, webhookURL)
+			}
+			if identity := withIdentity(args, cfg); identity.Username != want {
+				t.Errorf("Expected the %s username, got %q", want, identity.Username)
+			}
+		})
+	}
+
+	// --source, then OWATA_SOURCE, then default_source in the local config,
+	// then in the global one, then "Unknown"
+	for combo := range 16 {
+		hasFlag, hasEnv, hasLocal, hasGlobal := combo&1 != 0, combo&2 != 0, combo&4 != 0, combo&8 != 0
+		t.Run(fmt.Sprintf("source flag=%v env=%v local=%v global=%v", hasFlag, hasEnv, hasLocal, hasGlobal), func(t *testing.T) {
+			t.Setenv("OWATA_SOURCE", "")
+			t.Setenv("OWATA_DEFAULT_SOURCE", "")
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(t.TempDir())
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+
+			args := &cli.Args{Command: cli.CommandNotify, Message: "Done", NoCI: true}
+			want := cli.DefaultSource
+			manager := config.NewManager()
+			if hasGlobal {
+				manager.Save(&config.Config{DefaultSource: "global"}, true)
+				want = "global"
+			}
+			if hasLocal {
+				manager.Save(&config.Config{DefaultSource: "local"}, false)
+				want = "local"
+			}
+			if hasEnv {
+				t.Setenv("OWATA_SOURCE", "env")
+				want = "env"
+			}
+			if hasFlag {
+				args.Source = "flag"
+				want = "flag"
+			}
+
+			// Resolve reads OWATA_SOURCE even without a config file
+			cfg, _, _ := manager.Resolve(false)
+			n, err := buildNotification(args, cfg, cli.DefaultSource)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if n.Source != want {
+				t.Errorf("Expected source %q, got %q", want, n.Source)
+			}
+		})
+	}
+}
+
+// TestProfiles tests writing a profile with config --profile and sending
+// through it
+func TestProfiles(t *testing.T) {
+	var baseHits, workHits int
+	base := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		baseHits++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer base.Close()
+	work := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		workHits++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer work.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+	t.Setenv("OWATA_PROFILE", "")
+
+	manager := config.NewManager()
+	if err := handleConfig(manager, &cli.Args{Command: cli.CommandConfig, WebhookURL: base.URL, AllowCustomWebhook: true}); err != nil {
+		t.Fatalf("Unexpected config error: %v", err)
+	}
+	// allow_custom_webhook from the rest of the config covers the profile
+	if err := handleConfig(manager, &cli.Args{Command: cli.CommandConfig, WebhookURL: work.URL, Username: "WorkBot", Profile: "work"}); err != nil {
+		t.Fatalf("Unexpected config error for the profile: %v", err)
+	}
+
+	cfg, err := manager.LoadFromPath(config.ConfigFileName)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if cfg.WebhookURL != base.URL || cfg.Username != "" {
+		t.Errorf("Expected the base config untouched, got %+v", cfg)
+	}
+	if p := cfg.Profiles["work"]; p.WebhookURL != work.URL || p.Username != "WorkBot" {
+		t.Errorf("Expected the profile to be written, got %+v", p)
+	}
+
+	notifyArgs := func(profile string) *cli.Args {
+		return &cli.Args{Command: cli.CommandNotify, Message: "Done", Profile: profile, NoCI: true, Retries: -1, RateLimitRetries: -1}
+	}
+	if err := handleNotify(context.Background(), manager, notifyArgs("")); err != nil {
+		t.Fatalf("Unexpected notify error: %v", err)
+	}
+	workManager := config.NewManager()
+	workManager.UseProfile("work")
+	if err := handleNotify(context.Background(), workManager, notifyArgs("work")); err != nil {
+		t.Fatalf("Unexpected notify error with the profile: %v", err)
+	}
+	if baseHits != 1 || workHits != 1 {
+		t.Errorf("Expected one notification each, got base=%d work=%d", baseHits, workHits)
+	}
+
+	playManager := config.NewManager()
+	playManager.UseProfile("play")
+	err = handleNotify(context.Background(), playManager, notifyArgs("play"))
+	if err == nil || !strings.Contains(err.Error(), "unknown profile: play (available: work)") {
+		t.Errorf("Expected an unknown profile error, got %v", err)
+	}
+}
+
+func TestHandleInitForce(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	capture := func(force bool) (string, error) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := handleInit(context.Background(), config.NewManager(), &cli.Args{Command: cli.CommandInit, Force: force})
+		w.Close()
+		os.Stdout = oldStdout
+		var output bytes.Buffer
+		output.ReadFrom(r)
+		return output.String(), err
+	}
+
+	os.WriteFile(config.ConfigFileName, []byte(`{"username": "Old"}`), 0644)
+
+	// Without --force the existing config is kept and shown
+	output, err := capture(false)
+	if err != nil || !strings.Contains(output, "already exists") {
+		t.Errorf("Expected the existing config to be kept, got %q, %v", output, err)
+	}
+
+	output, err = capture(true)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"backed up to " + config.ConfigFileName + ".bak", "template written: " + config.ConfigFileName} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in output %q", want, output)
+		}
+	}
+	if data, _ := os.ReadFile(config.ConfigFileName + ".bak"); string(data) != `{"username": "Old"}` {
+		t.Errorf("Expected the old config in the backup, got %q", data)
+	}
+}
+
+// TestHandleInitFormat tests init --format=toml, and that it must agree with
+// the extension of --config
+func TestHandleInitFormat(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	if err := handleInit(context.Background(), config.NewManager(), &cli.Args{Command: cli.CommandInit, Format: "toml"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if _, err := os.Stat("owata-config.toml"); err != nil {
+		t.Errorf("Expected owata-config.toml to be created: %v", err)
+	}
+	if _, err := os.Stat(config.ConfigFileName); err == nil {
+		t.Errorf("Expected no %s alongside the TOML config", config.ConfigFileName)
+	}
+
+	err := handleInit(context.Background(), config.NewManagerForFile("prod.json"), &cli.Args{Command: cli.CommandInit, Format: "toml", ConfigPath: "prod.json"})
+	if err == nil || !strings.Contains(err.Error(), "doesn't match prod.json") {
+		t.Errorf("Expected --format to have to match --config, got %v", err)
+	}
+}
+
+// TestInitInteractive tests the questions init asks in a terminal, fed from a
+// reader
+func TestInitInteractive(t *testing.T) {
+	var hits atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name      string
+		args      cli.Args
+		input     string
+		want      config.Config
+		wantOut   string
+		wantHits  int32
+		expectErr string
+	}{
+		{
+			name:    "Everything skipped",
+			input:   "\n\n\n",
+			want:    config.Config{},
+			wantOut: "Username (default Owata)",
+		},
+		{
+			name:    "Values entered",
+			input:   "https://discord.com/api/webhooks/1/token\nBuildBot\nhttps://example.com/bot.png\nn\n",
+			want:    config.Config{WebhookURL: "https://discord.com/api/webhooks/1/token", Username: "BuildBot", AvatarURL: "https://example.com/bot.png"},
+			wantOut: "Send a test message now?",
+		},
+		{
+			name:    "Invalid answers asked again",
+			input:   "https://example.com/hook\nhttps://discord.com/api/webhooks/1/token\n\nbot.png\n\nno\n",
+			want:    config.Config{WebhookURL: "https://discord.com/api/webhooks/1/token"},
+			wantOut: "avatar URL must start with http:// or https://; try again",
+		},
+		{
+			name:     "Test message sent",
+			args:     cli.Args{Provider: "ntfy"},
+			input:    server.URL + "/builds\n\n\n\n",
+			want:     config.Config{WebhookURL: server.URL + "/builds"},
+			wantHits: 1,
+		},
+		{
+			name:      "Input ends early",
+			input:     "https://discord.com/api/webhooks/1/token\n",
+			expectErr: "nothing was saved",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+			hits.Store(0)
+
+			manager := config.NewManager()
+			var out bytes.Buffer
+			tt.args.Command = cli.CommandInit
+			err := initInteractive(context.Background(), manager, &tt.args, strings.NewReader(tt.input), &out)
+
+			if tt.expectErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectErr, err)
+				}
+				if _, statErr := os.Stat(config.ConfigFileName); statErr == nil {
+					t.Error("Expected no config to be saved")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Errorf("Expected %q in output %q", tt.wantOut, out.String())
+			}
+			saved, _, err := manager.Load(false)
+			if err != nil {
+				t.Fatalf("Failed to load the saved config: %v", err)
+			}
+			if saved.WebhookURL != tt.want.WebhookURL || saved.Username != tt.want.Username || saved.AvatarURL != tt.want.AvatarURL {
+				t.Errorf("Expected %+v saved, got %+v", tt.want, saved)
+			}
+			if got := hits.Load(); got != tt.wantHits {
+				t.Errorf("Expected %d test messages, got %d", tt.wantHits, got)
+			}
+		})
+	}
+}
+
+// TestHandleInitTerminal checks that init only asks in a terminal
+func TestHandleInitTerminal(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	err := handleInit(context.Background(), config.NewManager(), &cli.Args{Command: cli.CommandInit, Interactive: true})
+	if err == nil || !strings.Contains(err.Error(), "asks in a terminal") {
+		t.Errorf("Expected --interactive without a terminal to fail, got %v", err)
+	}
+	if _, err := os.Stat(config.ConfigFileName); err == nil {
+		t.Error("Expected nothing to be written")
+	}
+
+	originalTerminal := stdinIsTerminal
+	defer func() { stdinIsTerminal = originalTerminal }()
+	stdinIsTerminal = func() bool { return true }
+	os.WriteFile(config.ConfigFileName, []byte(`{"username": "Old"}`), 0644)
+	err = handleInit(context.Background(), config.NewManager(), &cli.Args{Command: cli.CommandInit, Interactive: true})
+	if err == nil || !strings.Contains(err.Error(), "add --force") {
+		t.Errorf("Expected an existing config to need --force, got %v", err)
+	}
+}
+
+// TestDebug checks --debug logs config resolution and requests to stderr
+// without the webhook token, and that nothing is logged without it
+func TestDebug(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+	defer func() { quiet, plain, debugLog = false, false, discardLog }()
+
+	webhookURL := server.URL + "/api/webhooks/123456789012345678/secret-token"
+	os.WriteFile(config.ConfigFileName, []byte(`{"webhook_url": "`+webhookURL+`", "allow_custom_webhook": true}`), 0600)
+
+	run := func(args ...string) (string, int) {
+		oldStdout, oldStderr := os.Stdout, os.Stderr
+		r, w, _ := os.Pipe()
+		devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		os.Stdout, os.Stderr = devNull, w
+		code := runMain(append(args, "--no-ci", "--retry=0"))
+		w.Close()
+		os.Stdout, os.Stderr = oldStdout, oldStderr
+		devNull.Close()
+
+		var stderr bytes.Buffer
+		stderr.ReadFrom(r)
+		return stderr.String(), code
+	}
+
+	stderr, code := run("Done", "--debug")
+	if code != exitOK {
+		t.Fatalf("Expected success, got exit code %d:\n%s", code, stderr)
+	}
+	for _, want := range []string{
+		"level=DEBUG",
+		`msg="using local config file" path=` + config.ConfigFileName,
+		`msg="using webhook"`,
+		`from="config webhook_url"`,
+		`msg="sending request" attempt=1 method=POST`,
+		`msg="response received" attempt=1 status=204`,
+		"msg=finished elapsed=",
+	} {
+		if !strings.Contains(stderr, want) {
+			t.Errorf("Expected %q in the debug log:\n%s", want, stderr)
+		}
+	}
+	if strings.Contains(stderr, "secret-token") {
+		t.Errorf("Expected the webhook token to be redacted:\n%s", stderr)
+	}
+
+	if stderr, _ := run("Done"); stderr != "" {
+		t.Errorf("Expected nothing on stderr without --debug, got:\n%s", stderr)
+	}
+}
+
+// TestLoosePermissionsWarning tests that a config file other users can read
+// gets a warning on stderr, unless --quiet is given
+func TestLoosePermissionsWarning(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("Windows doesn't use Unix permissions")
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(t.TempDir())
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+	defer func() { quiet, plain = false, false }()
+
+	webhookURL := server.URL + "/api/webhooks/123456789012345678/token"
+	os.WriteFile(config.ConfigFileName, []byte(`{"webhook_url": "`+webhookURL+`", "allow_custom_webhook": true}`), 0600)
+
+	run := func(args ...string) string {
+		oldStdout, oldStderr := os.Stdout, os.Stderr
+		r, w, _ := os.Pipe()
+		devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		os.Stdout, os.Stderr = devNull, w
+		code := runMain(append(args, "--no-ci", "--retry=0"))
+		w.Close()
+		os.Stdout, os.Stderr = oldStdout, oldStderr
+		devNull.Close()
+
+		var stderr bytes.Buffer
+		stderr.ReadFrom(r)
+		if code != exitOK {
+			t.Fatalf("Expected success, got exit code %d:\n%s", code, stderr.String())
+		}
+		return stderr.String()
+	}
+
+	if stderr := run("Done"); stderr != "" {
+		t.Errorf("Expected no warning for a 0600 config, got:\n%s", stderr)
+	}
+
+	os.Chmod(config.ConfigFileName, 0644)
+	stderr := run("Done")
+	if !strings.Contains(stderr, config.ConfigFileName+" can be read by other users (mode 0644)") || !strings.Contains(stderr, "chmod 600") {
+		t.Errorf("Expected a warning suggesting chmod, got:\n%s", stderr)
+	}
+	if stderr := run("Done", "--quiet"); stderr != "" {
+		t.Errorf("Expected --quiet to silence the warning, got:\n%s", stderr)
+	}
+}
+
+// TestWebhookFile tests sending to the webhook in --webhook-file,
+// webhook_url_file or webhook_url_command, and that a missing file fails
+// naming it
+func TestWebhookFile(t *testing.T) {
+	var received int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received++
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(t.TempDir())
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+	defer func() { quiet, plain = false, false }()
+	t.Setenv("OWATA_WEBHOOK_URL_FILE", "")
+
+	secret := filepath.Join(t.TempDir(), "discord_webhook")
+	os.WriteFile(secret, []byte(server.URL+"/api/webhooks/1/token\n"), 0600)
+
+	run := func(args ...string) (string, int) {
+		oldStdout, oldStderr := os.Stdout, os.Stderr
+		r, w, _ := os.Pipe()
+		devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+		os.Stdout, os.Stderr = devNull, w
+		code := runMain(append(args, "--no-ci", "--retry=0", "--allow-custom-webhook"))
+		w.Close()
+		os.Stdout, os.Stderr = oldStdout, oldStderr
+		devNull.Close()
+
+		var stderr bytes.Buffer
+		stderr.ReadFrom(r)
+		return stderr.String(), code
+	}
+
+	if stderr, code := run("Done", "--webhook-file="+secret); code != exitOK || received != 1 {
+		t.Errorf("Expected --webhook-file to be sent to, got exit code %d and %d requests:\n%s", code, received, stderr)
+	}
+
+	os.WriteFile(config.ConfigFileName, []byte(`{"webhook_url": "https://discord.com/api/webhooks/9/unused", "webhook_url_file": "`+secret+`", "allow_custom_webhook": true}`), 0600)
+	if stderr, code := run("Done"); code != exitOK || received != 2 {
+		t.Errorf("Expected webhook_url_file to win over webhook_url, got exit code %d and %d requests:\n%s", code, received, stderr)
+	}
+
+	if runtime.GOOS != "windows" {
+		os.WriteFile(config.ConfigFileName, []byte(`{"webhook_url_file": "`+secret+`", "webhook_url_command": "echo `+server.URL+`/api/webhooks/1/command", "allow_custom_webhook": true}`), 0600)
+		if stderr, code := run("Done"); code != exitOK || received != 3 {
+			t.Errorf("Expected webhook_url_command to be used, got exit code %d and %d requests:\n%s", code, received, stderr)
+		}
+		os.WriteFile(config.ConfigFileName, []byte(`{"webhook_url_command": "echo denied >&2; exit 1"}`), 0600)
+		if stderr, code := run("Done"); code == exitOK || !strings.Contains(stderr, "webhook_url_command failed: exit status 1: denied") {
+			t.Errorf("Expected the command's error, got exit code %d:\n%s", code, stderr)
+		}
+		os.WriteFile(config.ConfigFileName, []byte(`{"webhook_url_file": "`+secret+`", "allow_custom_webhook": true}`), 0600)
+	}
+
+	missing := filepath.Join(t.TempDir(), "missing")
+	stderr, code := run("Done", "--webhook-file="+missing)
+	if code != exitFailure || !strings.Contains(stderr, "failed to read webhook URL file "+missing) {
+		t.Errorf("Expected an error naming %s, got exit code %d:\n%s", missing, code, stderr)
+	}
+	os.Remove(secret)
+	if stderr, code := run("Done"); code == exitOK || !strings.Contains(stderr, secret) {
+		t.Errorf("Expected a missing webhook_url_file to fail naming it, got exit code %d:\n%s", code, stderr)
+	}
+}
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{name: "Status tag", input: "✅ Configuration saved\n", want: "[ok] Configuration saved\n"},
+		{name: "Variation selector", input: "⚠️ Target 1 failed", want: "[warn] Target 1 failed"},
+		{name: "Other emoji dropped", input: "\n📋 Current configuration:\n  🖼️  Avatar URL: (not set)\n", want: "\nCurrent configuration:\n  Avatar URL: (not set)\n"},
+		{name: "Only at the start of a line", input: "  👤 Username: 🤖 Bot", want: "  Username: 🤖 Bot"},
+		{name: "Plain text untouched", input: "Error: no webhook\n", want: "Error: no webhook\n"},
+		{name: "Log arrows", input: "→ POST https://discord.com/api/webhooks/1/****", want: "-> POST https://discord.com/api/webhooks/1/****"},
+	}
+
+	defer func() { plain = false }()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain = false
+			if got := render(tt.input); got != tt.input {
+				t.Errorf("Expected output untouched without plain, got %q", got)
+			}
+			plain = true
+			if got := render(tt.input); got != tt.want {
+				t.Errorf("Expected %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestUsePlainOutput(t *testing.T) {
+	originalTerminal := stdoutIsTerminal
+	defer func() { stdoutIsTerminal = originalTerminal }()
+
+	tests := []struct {
+		name     string
+		noEmoji  bool
+		noColor  string
+		term     string
+		terminal bool
+		want     bool
+	}{
+		{name: "Terminal", term: "xterm-256color", terminal: true, want: false},
+		{name: "--no-emoji", noEmoji: true, term: "xterm-256color", terminal: true, want: true},
+		{name: "NO_COLOR", noColor: "1", term: "xterm-256color", terminal: true, want: true},
+		{name: "TERM=dumb", term: "dumb", terminal: true, want: true},
+		{name: "Not a terminal", term: "xterm-256color", want: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("NO_COLOR", tt.noColor)
+			t.Setenv("TERM", tt.term)
+			stdoutIsTerminal = func() bool { return tt.terminal }
+			if got := usePlainOutput(&cli.Args{NoEmoji: tt.noEmoji}); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestExitCodes runs owata as main does and checks the exit code tells the
+// kinds of failure apart
+func TestExitCodes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/rejected":
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message": "Cannot send an empty message", "code": 50006}`))
+		case "/limited":
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+		case "/down":
+			w.WriteHeader(http.StatusBadGateway)
+		default:
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	defer server.Close()
+	closed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	closed.Close()
+
+	tests := []struct {
+		name   string
+		config string // Local config file contents; none if empty
+		toml   string // Local owata-config.toml contents, if any
+		args   []string
+		want   int
+	}{
+		{name: "Sent", args: []string{"Done", "--webhook=" + server.URL + "/ok"}, want: exitOK},
+		{name: "Usage error", args: []string{"Done", "--retry=many"}, want: exitUsage},
+		{name: "No webhook anywhere", config: "none", args: []string{"Done"}, want: exitConfig},
+		{name: "Invalid JSON", config: `{"webhook_url": `, args: []string{"Done"}, want: exitConfig},
+		{name: "Invalid value", config: `{"notify_on": "sometimes"}`, args: []string{"Done"}, want: exitConfig},
+		{name: "Invalid TOML", config: "none", toml: `webhook_url = `, args: []string{"Done"}, want: exitConfig},
+		{name: "JSON and TOML configs", toml: `username = "Bot"`, args: []string{"Done"}, want: exitConfig},
+		{name: "Missing --config file", args: []string{"Done", "--config=missing.json"}, want: exitConfig},
+		{name: "Invalid --webhook", config: "none", args: []string{"Done", "--webhook=https://example.com/hook"}, want: exitUsage},
+		{name: "Invalid webhook_url", config: `{"webhook_url": "https://example.com/hook"}`, args: []string{"Done"}, want: exitConfig},
+		{name: "Invalid fallback_chain", config: `{"webhook_url": "https://discord.com/api/webhooks/1/abc", "fallback_chain": ["https://example.com/hook"]}`, args: []string{"Done"}, want: exitConfig},
+		{name: "Unreachable", args: []string{"Done", "--webhook=" + closed.URL + "/ok"}, want: exitNetwork},
+		{name: "Server error", args: []string{"Done", "--webhook=" + server.URL + "/down"}, want: exitNetwork},
+		{name: "Rejected", args: []string{"Done", "--webhook=" + server.URL + "/rejected"}, want: exitRejected},
+		{name: "Rate limited", args: []string{"Done", "--webhook=" + server.URL + "/limited"}, want: exitRateLimited},
+		{name: "Other failure", args: []string{"Done", "--embed-json=missing.json", "--webhook=" + server.URL + "/ok"}, want: exitFailure},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+			defer func() { quiet, plain = false, false }()
+
+			switch tt.config {
+			case "":
+				os.WriteFile(config.ConfigFileName, []byte(`{"allow_custom_webhook": true}`), 0644)
+			case "none":
+			default:
+				os.WriteFile(config.ConfigFileName, []byte(tt.config), 0644)
+			}
+			if tt.toml != "" {
+				os.WriteFile("owata-config.toml", []byte(tt.toml), 0644)
+			}
+
+			oldStdout, oldStderr := os.Stdout, os.Stderr
+			devNull, _ := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+			os.Stdout, os.Stderr = devNull, devNull
+			got := runMain(append(tt.args, "--no-ci", "--retry=0", "--rate-limit-retries=0"))
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+			devNull.Close()
+
+			if got != tt.want {
+				t.Errorf("Expected exit code %d, got %d", tt.want, got)
+			}
+		})
+	}
+}
+
+// TestHandleCompletion tests printing a completion script and the target
+// names it completes --target with
+func TestHandleCompletion(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	capture := func(args *cli.Args) (string, error) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := handleCompletion(config.NewManager(), args)
+		w.Close()
+		os.Stdout = oldStdout
+		var output bytes.Buffer
+		output.ReadFrom(r)
+		return output.String(), err
+	}
+
+	// No config yet, so no targets and no error
+	output, err := capture(&cli.Args{Command: cli.CommandCompletion, Shell: "targets"})
+	if err != nil || output != "" {
+		t.Errorf("Expected no targets without a config, got %q, %v", output, err)
+	}
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{
+		WebhookURL: "https://discord.com/api/webhooks/1/abc",
+		Targets: map[string]string{
+			"ops": "https://discord.com/api/webhooks/2/def",
+			"dev": "https://discord.com/api/webhooks/3/ghi",
+		},
+	}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	output, err = capture(&cli.Args{Command: cli.CommandCompletion, Shell: "targets"})
+	if err != nil || output != "dev\nops\n" {
+		t.Errorf("Expected sorted target names, got %q, %v", output, err)
+	}
+
+	output, err = capture(&cli.Args{Command: cli.CommandCompletion, Shell: "bash"})
+	if err != nil || !strings.Contains(output, "complete -o default -F _owata owata") {
+		t.Errorf("Expected a bash completion script, got %q, %v", output, err)
+	}
+}
+
+func TestHandleConfigKey(t *testing.T) {
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	capture := func(args *cli.Args) (string, error) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := handleConfig(config.NewManager(), args)
+		w.Close()
+		os.Stdout = oldStdout
+		var output bytes.Buffer
+		output.ReadFrom(r)
+		return output.String(), err
+	}
+	configArgs := func(action string, args ...string) *cli.Args {
+		return &cli.Args{Command: cli.CommandConfig, ConfigAction: action, ConfigArgs: args}
+	}
+
+	webhook := "https://discord.com/api/webhooks/123/secret-token"
+	if _, err := capture(configArgs("set", "webhook_url", webhook)); err != nil {
+		t.Fatalf("Unexpected error setting webhook_url: %v", err)
+	}
+	if _, err := capture(configArgs("set", "username", "Build Bot")); err != nil {
+		t.Fatalf("Unexpected error setting username: %v", err)
+	}
+
+	output, err := capture(configArgs("get", "username"))
+	if err != nil || output != "Build Bot\n" {
+		t.Errorf("Expected the raw username, got %q, %v", output, err)
+	}
+	output, err = capture(configArgs("get", "webhook_url"))
+	if err != nil || strings.Contains(output, "secret-token") {
+		t.Errorf("Expected the webhook URL masked, got %q, %v", output, err)
+	}
+	reveal := configArgs("get", "webhook_url")
+	reveal.Reveal = true
+	output, err = capture(reveal)
+	if err != nil || output != webhook+"\n" {
+		t.Errorf("Expected the webhook URL with --reveal, got %q, %v", output, err)
+	}
+
+	if _, err := capture(configArgs("unset", "username")); err != nil {
+		t.Fatalf("Unexpected error unsetting username: %v", err)
+	}
+	output, err = capture(configArgs("get", "username"))
+	if err != nil || output != "\n" {
+		t.Errorf("Expected an empty username after unset, got %q, %v", output, err)
+	}
+
+	if _, err := capture(configArgs("get", "colour")); err == nil || !strings.Contains(err.Error(), "valid keys: webhook_url") {
+		t.Errorf("Expected an unknown key error listing the keys, got %v", err)
+	}
+	if _, err := capture(configArgs("set", "webhook_url", "https://example.com/hook")); err == nil || !strings.Contains(err.Error(), "not saved") {
+		t.Errorf("Expected a non-Discord webhook to be rejected, got %v", err)
+	}
+	if _, err := capture(configArgs("set", "default_color", "not-a-color")); err == nil {
+		t.Error("Expected an invalid color to be rejected")
+	}
+	output, _ = capture(reveal)
+	if output != webhook+"\n" {
+		t.Errorf("Expected rejected changes not to be saved, got %q", output)
+	}
+}
+
+// TestHandleConfigEdit fakes the editor with a script that adds a key, or
+// on its first run breaks the JSON
+func TestHandleConfigEdit(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	scriptDir := t.TempDir()
+	editor := func(name, body string) string {
+		path := filepath.Join(scriptDir, name)
+		if err := os.WriteFile(path, []byte("#!/bin/sh\n"+body+"\n"), 0755); err != nil {
+			t.Fatalf("Failed to write editor script: %v", err)
+		}
+		return path
+	}
+	// Adds a key at the closing brace of the template, dropping anything after it
+	addKey := func(key, value string) string {
+		return fmt.Sprintf(`sed 's/^}.*/, "%s": "%s"}/' "$1" > "$1.tmp" && mv "$1.tmp" "$1"`, key, value)
+	}
+	// Breaks the JSON the first time, then adds a key
+	breakOnce := `if [ ! -e "$1.broken" ]; then touch "$1.broken"; echo oops >> "$1"; else ` + addKey("username", "Fixed") + `; fi`
+
+	tests := []struct {
+		name         string
+		visual       string
+		editor       string
+		input        string
+		wantErr      string
+		wantUsername string
+	}{
+		{name: "Creates the template and adds a key", editor: addKey("username", "Edited"), wantUsername: "Edited"},
+		{name: "VISUAL comes before EDITOR", visual: addKey("username", "Visual"), editor: "exit 1", wantUsername: "Visual"},
+		{name: "Invalid value", editor: addKey("notify_on", "sometimes"), wantErr: "invalid notify_on"},
+		{name: "Invalid JSON edited again", editor: breakOnce, input: "y\n", wantUsername: "Fixed"},
+		{name: "Invalid JSON left as is", editor: breakOnce, input: "n\n", wantErr: "invalid JSON"},
+		{name: "Invalid JSON without an answer", editor: breakOnce, wantErr: "invalid JSON"},
+		{name: "Editor fails", editor: "exit 3", wantErr: "failed"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			os.Remove(config.ConfigFileName)
+			os.Remove(config.ConfigFileName + ".broken")
+			visual := ""
+			if tt.visual != "" {
+				visual = editor(fmt.Sprintf("visual%d", i), tt.visual)
+			}
+			t.Setenv("VISUAL", visual)
+			t.Setenv("EDITOR", editor(fmt.Sprintf("editor%d", i), tt.editor))
+
+			err := handleConfigEdit(config.NewManager(), false, strings.NewReader(tt.input))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			cfg, err := config.NewManager().LoadFromPath(config.ConfigFileName)
+			if err != nil {
+				t.Fatalf("Failed to load the edited config: %v", err)
+			}
+			if cfg.Username != tt.wantUsername {
+				t.Errorf("Expected username %q, got %q", tt.wantUsername, cfg.Username)
+			}
+		})
+	}
+}
+
+func TestHandleConfigPath(t *testing.T) {
+	tempDir := t.TempDir()
+	globalDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(globalDir)
+	defer config.ResetTestConfigDir()
+
+	// The temp dir may be behind a symlink, as on macOS
+	workDir, _ := os.Getwd()
+	localPath := filepath.Join(workDir, config.ConfigFileName)
+	globalPath := filepath.Join(globalDir, config.GlobalConfigDir, config.GlobalFileName)
+
+	capture := func(args *cli.Args) (string, error) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := handleConfig(config.NewManager(), args)
+		w.Close()
+		os.Stdout = oldStdout
+		var output bytes.Buffer
+		output.ReadFrom(r)
+		return strings.TrimSuffix(output.String(), "\n"), err
+	}
+	pathArgs := func(global, resolved bool) *cli.Args {
+		return &cli.Args{Command: cli.CommandConfig, ConfigAction: "path", Global: global, Resolved: resolved}
+	}
+
+	// Nothing exists yet: the paths are printed but it fails
+	output, err := capture(pathArgs(false, false))
+	if output != localPath || err == nil || !strings.Contains(err.Error(), "owata init'") {
+		t.Errorf("Expected %s and a missing file error, got %q, %v", localPath, output, err)
+	}
+	output, err = capture(pathArgs(true, false))
+	if output != globalPath || err == nil || !strings.Contains(err.Error(), "owata init -g'") {
+		t.Errorf("Expected %s and a missing file error, got %q, %v", globalPath, output, err)
+	}
+	if _, err := capture(pathArgs(false, true)); err == nil || !strings.Contains(err.Error(), "neither") {
+		t.Errorf("Expected no config to resolve, got %v", err)
+	}
+
+	// Only the global config exists, so Load falls back to it
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{Username: "Global"}, true); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	output, err = capture(pathArgs(false, true))
+	if output != globalPath || err != nil {
+		t.Errorf("Expected the global fallback %s, got %q, %v", globalPath, output, err)
+	}
+
+	// A local config wins unless -g is given
+	if _, err := manager.Save(&config.Config{Username: "Local"}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	output, err = capture(pathArgs(false, true))
+	if output != localPath || err != nil {
+		t.Errorf("Expected the local config %s, got %q, %v", localPath, output, err)
+	}
+	output, err = capture(pathArgs(true, true))
+	if output != globalPath || err != nil {
+		t.Errorf("Expected the global config %s with -g, got %q, %v", globalPath, output, err)
+	}
+}
+
+func TestHandleConfigResolved(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(t.TempDir())
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+	t.Setenv("OWATA_USERNAME", "")
+
+	manager := config.NewManager()
+	manager.Save(&config.Config{WebhookURL: "https://discord.com/api/webhooks/1/global", Username: "Global", AvatarURL: "https://example.com/global.png"}, true)
+	manager.Save(&config.Config{Username: "Local"}, false)
+
+	capture := func(args *cli.Args) (string, error) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := handleConfig(config.NewManager(), args)
+		w.Close()
+		os.Stdout = oldStdout
+		var output bytes.Buffer
+		output.ReadFrom(r)
+		return output.String(), err
+	}
+
+	output, err := capture(&cli.Args{Command: cli.CommandConfig, Resolved: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{"overridden by " + config.ConfigFileName, "Local", "global.png"} {
+		if !strings.Contains(output, want) {
+			t.Errorf("Expected %q in the merged config, got:\n%s", want, output)
+		}
+	}
+
+	output, err = capture(&cli.Args{Command: cli.CommandConfig, Resolved: true, Global: true})
+	if err != nil || !strings.Contains(output, "Global") || strings.Contains(output, "overridden") {
+		t.Errorf("Expected the global config alone with -g, got %v:\n%s", err, output)
+	}
+
+	if _, err := capture(&cli.Args{Command: cli.CommandConfig, Resolved: true, Username: "New"}); err == nil {
+		t.Error("Expected --resolved with a value to set to fail")
+	}
+}
+
+func TestHandleConfigJSON(t *testing.T) {
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(t.TempDir())
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+	t.Setenv("OWATA_USERNAME", "")
+
+	capture := func(args *cli.Args) (string, error) {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		err := handleConfig(config.NewManager(), args)
+		w.Close()
+		os.Stdout = oldStdout
+		var output bytes.Buffer
+		output.ReadFrom(r)
+		return output.String(), err
+	}
+	type view struct {
+		Files   []string          `json:"files"`
+		Missing string            `json:"missing"`
+		Config  config.Config     `json:"config"`
+		Origins map[string]string `json:"origins"`
+	}
+	parse := func(t *testing.T, output string) view {
+		t.Helper()
+		var v view
+		if err := json.Unmarshal([]byte(output), &v); err != nil {
+			t.Fatalf("Expected JSON, got %q: %v", output, err)
+		}
+		return v
+	}
+
+	output, err := capture(&cli.Args{Command: cli.CommandConfig, JSON: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if v := parse(t, output); v.Missing != config.ConfigFileName || len(v.Files) != 0 {
+		t.Errorf("Expected the missing local config, got %s", output)
+	}
+
+	manager := config.NewManager()
+	manager.Save(&config.Config{WebhookURL: "https://discord.com/api/webhooks/123456789/secret", Username: "Global"}, true)
+	manager.Save(&config.Config{Username: "Local"}, false)
+
+	tests := []struct {
+		name            string
+		args            cli.Args
+		expectedURL     string
+		expectedOrigins map[string]string
+	}{
+		{"Local", cli.Args{JSON: true}, "", map[string]string{"username": "local"}},
+		{"Global", cli.Args{JSON: true, Global: true}, "https://discord.com/api/webhooks/123456789/****", map[string]string{"webhook_url": "global", "username": "global"}},
+		{"Resolved", cli.Args{JSON: true, Resolved: true}, "https://discord.com/api/webhooks/123456789/****", map[string]string{"webhook_url": "global", "username": "local"}},
+		{"Revealed", cli.Args{JSON: true, Resolved: true, Reveal: true}, "https://discord.com/api/webhooks/123456789/secret", map[string]string{"webhook_url": "global", "username": "local"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.args.Command = cli.CommandConfig
+			output, err := capture(&tt.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			v := parse(t, output)
+			if v.Config.WebhookURL != tt.expectedURL {
+				t.Errorf("Expected webhook URL %q, got %q", tt.expectedURL, v.Config.WebhookURL)
+			}
+			if !maps.Equal(v.Origins, tt.expectedOrigins) {
+				t.Errorf("Expected origins %v, got %v", tt.expectedOrigins, v.Origins)
+			}
+		})
+	}
+
+	t.Run("All layers", func(t *testing.T) {
+		output, err := capture(&cli.Args{Command: cli.CommandConfig, All: true, JSON: true})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		var all struct {
+			Layers []struct {
+				Origin string        `json:"origin"`
+				Config config.Config `json:"config"`
+			} `json:"layers"`
+			Effective view `json:"effective"`
+		}
+		if err := json.Unmarshal([]byte(output), &all); err != nil {
+			t.Fatalf("Expected JSON, got %q: %v", output, err)
+		}
+		if len(all.Layers) != 2 || all.Layers[0].Config.Username != "Global" || all.Layers[1].Config.Username != "Local" {
+			t.Errorf("Expected the global and local layers, got %s", output)
+		}
+		if all.Effective.Config.Username != "Local" || all.Effective.Config.WebhookURL != "https://discord.com/api/webhooks/123456789/****" {
+			t.Errorf("Expected the effective values masked, got %s", output)
+		}
+
+		if output, err = capture(&cli.Args{Command: cli.CommandConfig, All: true}); err != nil || !strings.Contains(output, "username") || !strings.Contains(output, "Local (local)") {
+			t.Errorf("Expected the table, got %q %v", output, err)
+		}
+	})
+
+	for _, args := range []cli.Args{{JSON: true, Username: "New"}, {Reveal: true}, {All: true, Username: "New"}} {
+		args.Command = cli.CommandConfig
+		if _, err := capture(&args); err == nil {
+			t.Errorf("Expected %+v to fail", args)
+		}
+	}
+}
+
+// TestPrintUsage tests the help output using the CLI package's PrintUsage function
+func TestPrintUsage(t *testing.T) {
+	// Redirect stdout to capture output
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	// Import and use the PrintUsage function from CLI package
+	cli.PrintUsage()
+
+	// Restore stdout
+	w.Close()
+	os.Stdout = oldStdout
+
+	// Read captured output
+	var buf bytes.Buffer
+	buf.ReadFrom(r)
+	output := buf.String()
+
+	// Check for expected content
+	if !strings.Contains(output, "Owata") ||
+		!strings.Contains(output, "Discord Webhook Notifier") ||
+		!strings.Contains(output, "Usage:") {
+		t.Errorf("Help output missing expected content")
+	}
+}
+
+// TestBuildNotification tests run ID and sequence resolution
+func TestBuildNotification(t *testing.T) {
+	tests := []struct {
+		name          string
+		args          *cli.Args
+		envRunID      string
+		expectError   bool
+		expectedRunID string
+		expectedSeq   int
+	}{
+		{
+			name:          "Explicit run ID and sequence",
+			args:          &cli.Args{Message: "msg", RunID: "7f2c", Seq: "3"},
+			expectedRunID: "7f2c",
+			expectedSeq:   3,
+		},
+		{
+			name:          "Run ID from environment",
+			args:          &cli.Args{Message: "msg"},
+			envRunID:      "env-run",
+			expectedRunID: "env-run",
+		},
+		{
+			name:          "Flag overrides environment",
+			args:          &cli.Args{Message: "msg", RunID: "flag-run"},
+			envRunID:      "env-run",
+			expectedRunID: "flag-run",
+		},
+		{
+			name:        "Auto sequence without run ID",
+			args:        &cli.Args{Message: "msg", Seq: cli.SeqAuto},
+			expectError: true,
+		},
+		{
+			name:        "Invalid run ID",
+			args:        &cli.Args{Message: "msg", RunID: "../bad"},
+			expectError: true,
+		},
+		{
+			name:        "Missing embed JSON file",
+			args:        &cli.Args{EmbedJSON: "does-not-exist.json"},
+			expectError: true,
+		},
+		{
+			name:        "Invalid field",
+			args:        &cli.Args{Message: "msg", Fields: []string{"Branch"}},
+			expectError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("OWATA_RUN_ID", tt.envRunID)
+
+			n, err := buildNotification(tt.args, nil, "")
+			if tt.expectError {
+				if err == nil {
+					t.Error("Expected error, but got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			if n.RunID != tt.expectedRunID {
+				t.Errorf("Expected RunID=%q, got %q", tt.expectedRunID, n.RunID)
+			}
+			if n.Seq != tt.expectedSeq {
+				t.Errorf("Expected Seq=%d, got %d", tt.expectedSeq, n.Seq)
+			}
+		})
+	}
+}
+
+// TestBuildNotificationPlaceholders tests expanding {{...}} in the message,
+// title and footer
+func TestBuildNotificationPlaceholders(t *testing.T) {
+	t.Setenv("DEPLOY_ENV", "prod")
+	host, _ := os.Hostname()
+	cwd, _ := os.Getwd()
+	today := time.Now().In(time.UTC).Format(time.DateOnly)
+
+	tests := []struct {
+		name            string
+		args            *cli.Args
+		expectedMessage string
+		expectedTitle   string
+		expectedFooter  string
+		expectedErr     string
+	}{
+		{
+			name:            "Message, title and footer",
+			args:            &cli.Args{Message: "backup finished on {{host}} at {{date}}", Title: "{{source}} done", Footer: `{{env "DEPLOY_ENV"}} in {{cwd}}`, Source: "cron"},
+			expectedMessage: "backup finished on " + host + " at " + today,
+			expectedTitle:   "cron done",
+			expectedFooter:  "prod in " + cwd,
+		},
+		{
+			name:            "No expand",
+			args:            &cli.Args{Message: "{{host}}", Title: "{{nope}}", NoExpand: true},
+			expectedMessage: "{{host}}",
+			expectedTitle:   "{{nope}}",
+		},
+		{
+			name:            "Message from stdin is left alone",
+			args:            &cli.Args{Message: "{{ .Values.image }}", Stdin: true, Title: "on {{host}}"},
+			expectedMessage: "{{ .Values.image }}",
+			expectedTitle:   "on " + host,
+		},
+		{
+			name:        "Unknown placeholder",
+			args:        &cli.Args{Message: "on {{hots}}"},
+			expectedErr: "use --no-expand",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := buildNotification(tt.args, &config.Config{Timezone: "UTC"}, "")
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if n.Message != tt.expectedMessage || n.Title != tt.expectedTitle || n.Footer.Text != tt.expectedFooter {
+				t.Errorf("Expected %q / %q / %q, got %q / %q / %q", tt.expectedMessage, tt.expectedTitle, tt.expectedFooter, n.Message, n.Title, n.Footer.Text)
+			}
+		})
+	}
+}
+
+// TestConfigPlaceholders tests expanding {{...}} in the configured title,
+// footer and thread name when they're sent
+func TestConfigPlaceholders(t *testing.T) {
+	t.Setenv("DEPLOY_ENV", "prod")
+	host, _ := os.Hostname()
+	today := time.Now().In(time.UTC).Format(time.DateOnly)
+	cfg := &config.Config{
+		Timezone:           "UTC",
+		DefaultTitle:       "Backup on {{host}}",
+		FooterText:         `{{env "DEPLOY_ENV"}}`,
+		ThreadNameTemplate: "{{date}} report",
+	}
+
+	tests := []struct {
+		name           string
+		args           *cli.Args
+		expectedTitle  string
+		expectedFooter string
+		expectedThread string
+	}{
+		{"Expanded", &cli.Args{Message: "done"}, "Backup on " + host, "prod", today + " report"},
+		{"No expand", &cli.Args{Message: "done", NoExpand: true}, "Backup on {{host}}", `{{env "DEPLOY_ENV"}}`, "{{date}} report"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := buildNotification(tt.args, cfg, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			webhooks, err := discord.BuildPayloads(n, cfg)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			embed := webhooks[0].Embeds[0]
+			if embed.Title != tt.expectedTitle || embed.Footer.Text != tt.expectedFooter || webhooks[0].ThreadName != tt.expectedThread {
+				t.Errorf("Expected %q / %q / %q, got %q / %q / %q", tt.expectedTitle, tt.expectedFooter, tt.expectedThread, embed.Title, embed.Footer.Text, webhooks[0].ThreadName)
+			}
+		})
+	}
+
+	n, _ := buildNotification(&cli.Args{Message: "done"}, &config.Config{DefaultTitle: "on {{hots}}"}, "")
+	if _, err := discord.BuildPayloads(n, &config.Config{DefaultTitle: "on {{hots}}"}); err == nil || !strings.Contains(err.Error(), "use --no-expand") {
+		t.Errorf("Expected an error for the unknown placeholder, got %v", err)
+	}
+}
+
+// gitStub answers git commands from a table instead of running git
+type gitStub map[string]string
+
+func (g gitStub) Run(dir string, args ...string) (string, error) {
+	out, ok := g[strings.Join(args, " ")]
+	if !ok {
+		return "", errors.New("exit status 128")
+	}
+	return out, nil
+}
+
+// TestBuildNotificationGit tests adding git fields from --git and the git config key
+func TestBuildNotificationGit(t *testing.T) {
+	original, originalGetenv := gitRunner, ciGetenv
+	defer func() { gitRunner, ciGetenv = original, originalGetenv }()
+	ciGetenv = func(string) string { return "" }
+
+	repo := gitStub{
+		"rev-parse --is-inside-work-tree": "true\n",
+		"rev-parse --abbrev-ref HEAD":     "main\n",
+		"rev-parse --short HEAD":          "abc1234\n",
+		"status --porcelain":              " M main.go\n",
+	}
+
+	tests := []struct {
+		name     string
+		args     *cli.Args
+		config   *config.Config
+		runner   gitStub
+		expected []string
+	}{
+		{"Off", &cli.Args{Message: "msg"}, nil, repo, nil},
+		{"Flag", &cli.Args{Message: "msg", Git: true, Fields: []string{"Env=prod"}}, nil, repo, []string{"Branch=main", "Commit=abc1234", "Working Tree=dirty", "Env=prod"}},
+		{"Config", &cli.Args{Message: "msg"}, &config.Config{Git: true}, repo, []string{"Branch=main", "Commit=abc1234", "Working Tree=dirty"}},
+		{"Not a repository", &cli.Args{Message: "msg", Git: true}, nil, gitStub{}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gitRunner = tt.runner
+
+			n, err := buildNotification(tt.args, tt.config, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var fields []string
+			for _, f := range n.Fields {
+				fields = append(fields, f.Name+"="+f.Value)
+			}
+			if !slices.Equal(fields, tt.expected) {
+				t.Errorf("Expected fields %v, got %v", tt.expected, fields)
+			}
+		})
+	}
+}
+
+// TestBuildNotificationCI tests adding fields for the detected CI run
+func TestBuildNotificationCI(t *testing.T) {
+	original := ciGetenv
+	defer func() { ciGetenv = original }()
+	env := map[string]string{
+		"GITLAB_CI":       "true",
+		"CI_PROJECT_PATH": "group/project",
+		"CI_JOB_NAME":     "build",
+		"CI_PIPELINE_URL": "https://gitlab.com/group/project/-/pipelines/42",
+	}
+	ciGetenv = func(key string) string { return env[key] }
+
+	tests := []struct {
+		name     string
+		args     *cli.Args
+		expected []string
+	}{
+		{"Detected", &cli.Args{Message: "msg", Fields: []string{"Env=prod"}}, []string{"CI", "Repository", "Job", "Run", "Env"}},
+		{"Opted out", &cli.Args{Message: "msg", NoCI: true}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := buildNotification(tt.args, nil, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var names []string
+			for _, f := range n.Fields {
+				names = append(names, f.Name)
+			}
+			if !slices.Equal(names, tt.expected) {
+				t.Errorf("Expected fields %v, got %v", tt.expected, names)
+			}
+		})
+	}
+}
+
+// TestBuildNotificationSysinfo tests adding OS and user fields
+func TestBuildNotificationSysinfo(t *testing.T) {
+	originalUser, originalGetenv := currentUser, ciGetenv
+	defer func() { currentUser, ciGetenv = originalUser, originalGetenv }()
+	currentUser = func() (*user.User, error) { return &user.User{Username: "builder"}, nil }
+	ciGetenv = func(string) string { return "" }
+
+	expected := []string{"OS=" + runtime.GOOS + "/" + runtime.GOARCH, "User=builder"}
+	tests := []struct {
+		name     string
+		args     *cli.Args
+		config   *config.Config
+		expected []string
+	}{
+		{"Off", &cli.Args{Message: "msg"}, nil, nil},
+		{"Flag", &cli.Args{Message: "msg", Sysinfo: true}, nil, expected},
+		{"Config", &cli.Args{Message: "msg"}, &config.Config{Sysinfo: true}, expected},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n, err := buildNotification(tt.args, tt.config, "")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var fields []string
+			for _, f := range n.Fields {
+				fields = append(fields, f.Name+"="+f.Value)
+			}
+			if !slices.Equal(fields, tt.expected) {
+				t.Errorf("Expected fields %v, got %v", tt.expected, fields)
+			}
+		})
+	}
+}
+
+// TestComposeLifecycle drives a compose buffer through separate handler invocations
+func TestComposeLifecycle(t *testing.T) {
+	var received discord.Webhook
+	var requests int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			t.Fatalf("Expected multipart request with attachment: %v", err)
+		}
+		if err := json.Unmarshal([]byte(r.FormValue("payload_json")), &received); err != nil {
+			t.Errorf("Failed to decode payload: %v", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	compose.SetTestCacheDir(tempDir)
+	defer compose.ResetTestCacheDir()
+
+	logPath := filepath.Join(tempDir, "migrate.log")
+	if err := os.WriteFile(logPath, []byte("ok"), 0644); err != nil {
+		t.Fatalf("Failed to write attachment: %v", err)
+	}
+
+	manager := config.NewManager()
+	steps := [][]string{
+		{"compose", "add-field", "env=prod", "--session=release"},
+		{"compose", "add-line", "migrations: ok", "--session=release"},
+		{"compose", "add-line", "cache: warmed", "--session=release"},
+		{"compose", "add-file", logPath, "--session=release"},
+		{"compose", "status", "success", "--session=release"},
+		{"compose", "send", "--title=Release 1.4", "--webhook=" + server.URL, "--allow-custom-webhook", "--session=release"},
+	}
+	for _, step := range steps {
+		args, err := cli.Parse(step)
+		if err != nil {
+			t.Fatalf("Failed to parse %v: %v", step, err)
+		}
+
+		oldStdout := os.Stdout
+		_, w, _ := os.Pipe()
+		os.Stdout = w
+		err = handleCompose(context.Background(), manager, args)
+		w.Close()
+		os.Stdout = oldStdout
+
+		if err != nil {
+			t.Fatalf("Step %v failed: %v", step, err)
+		}
+	}
+
+	if requests != 1 {
+		t.Fatalf("Expected 1 request, got %d", requests)
+	}
+	embed := received.Embeds[0]
+	if embed.Title != "Release 1.4" {
+		t.Errorf("Expected title %q, got %q", "Release 1.4", embed.Title)
+	}
+	if embed.Description != "migrations: ok\ncache: warmed" {
+		t.Errorf("Unexpected description: %q", embed.Description)
+	}
+
+	fields := make(map[string]string)
+	for _, f := range embed.Fields {
+		fields[f.Name] = f.Value
+	}
+	if fields["env"] != "prod" || fields["Status"] != "success" {
+		t.Errorf("Expected env and Status fields, got %+v", embed.Fields)
+	}
+
+	// The buffer is cleared after a successful send
+	buffer, err := compose.Load("release")
+	if err != nil {
+		t.Fatalf("Failed to load buffer: %v", err)
+	}
+	if !buffer.IsEmpty() {
+		t.Errorf("Expected buffer to be cleared after send, got %+v", buffer)
+	}
+
+	// Sending an empty buffer is an error
+	args, _ := cli.Parse([]string{"compose", "send", "--webhook=" + server.URL, "--allow-custom-webhook", "--session=release"})
+	if err := handleCompose(context.Background(), manager, args); err == nil {
+		t.Error("Expected error sending an empty buffer")
+	}
+}
+
+// TestHandleNotifyFallback tests failover from a dead primary to a healthy secondary
+func TestHandleNotifyFallback(t *testing.T) {
+	var primaryHits atomic.Int32
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		primaryHits.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer primary.Close()
+
+	var secondaryHit bool
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		secondaryHit = true
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer secondary.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	_, err := manager.Save(&config.Config{
+		WebhookURL:         primary.URL,
+		FallbackChain:      []string{secondary.URL},
+		DeliveryBudget:     "5s",
+		Retries:            3,
+		AllowCustomWebhook: true,
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	r, w, _ := os.Pipe()
+	er, ew, _ := os.Pipe()
+	os.Stdout, os.Stderr = w, ew
+
+	err = handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", Source: "Test", Retries: -1, RateLimitRetries: -1})
+
+	w.Close()
+	ew.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	var output, errOutput bytes.Buffer
+	output.ReadFrom(r)
+	errOutput.ReadFrom(er)
+
+	if err != nil {
+		t.Fatalf("Expected fallback to succeed, got: %v", err)
+	}
+	if !secondaryHit {
+		t.Error("Secondary target was not used")
+	}
+	// The configured 3 retries are shortened to 1 within the chain
+	if hits := primaryHits.Load(); hits != 1+fallbackRetries {
+		t.Errorf("Expected %d requests to the primary, got %d", 1+fallbackRetries, hits)
+	}
+	if !strings.Contains(output.String(), "via fallback target 2") {
+		t.Errorf("Expected output to report the delivering target, got %q", output.String())
+	}
+	// The warning goes to stderr so it can't corrupt --json output
+	if !strings.Contains(errOutput.String(), "Target 1 failed") || strings.Contains(output.String(), "Target 1 failed") {
+		t.Errorf("Expected stderr to report the failed primary, got stdout %q, stderr %q", output.String(), errOutput.String())
+	}
+
+	// An invalid budget is reported before anything is sent
+	_, err = manager.Save(&config.Config{
+		WebhookURL:         primary.URL,
+		FallbackChain:      []string{secondary.URL},
+		DeliveryBudget:     "soon",
+		AllowCustomWebhook: true,
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	err = handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", Source: "Test"})
+	if err == nil || !strings.Contains(err.Error(), "delivery_budget") {
+		t.Errorf("Expected delivery_budget error, got %v", err)
+	}
+}
+
+// TestHandleNotifyFallbackRateLimited tests that a primary rate limited for
+// longer than its share of the budget hands over to the fallback at once
+func TestHandleNotifyFallbackRateLimited(t *testing.T) {
+	primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "8")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer primary.Close()
+
+	secondary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id": "123", "channel_id": "456"}`))
+	}))
+	defer secondary.Close()
+
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(t.TempDir())
+
+	manager := config.NewManager()
+	_, err := manager.Save(&config.Config{
+		WebhookURL:         primary.URL,
+		FallbackChain:      []string{secondary.URL},
+		DeliveryBudget:     "3s",
+		AllowCustomWebhook: true,
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	r, w, _ := os.Pipe()
+	devNull, _ := os.Open(os.DevNull)
+	defer devNull.Close()
+	os.Stdout, os.Stderr = w, devNull
+
+	start := time.Now()
+	err = handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", Source: "Test", JSON: true, Retries: -1, RateLimitRetries: -1})
+
+	w.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	var output bytes.Buffer
+	output.ReadFrom(r)
+
+	if err != nil {
+		t.Fatalf("Expected the fallback to deliver, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("Expected the primary's rate limit not to be waited out, took %v", elapsed)
+	}
+	var result map[string]any
+	if err := json.Unmarshal(output.Bytes(), &result); err != nil {
+		t.Fatalf("Expected only JSON on stdout, got %q: %v", output.String(), err)
+	}
+	if result["target"] != float64(2) || result["message_id"] != "123" {
+		t.Errorf("Expected the result of target 2, got %v", result)
+	}
+
+	// With nowhere left to go, the rate limit decides the exit code
+	_, err = manager.Save(&config.Config{
+		WebhookURL:         primary.URL,
+		FallbackChain:      []string{primary.URL},
+		DeliveryBudget:     "3s",
+		AllowCustomWebhook: true,
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+	os.Stderr = devNull
+	err = handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", Source: "Test", Retries: -1, RateLimitRetries: -1})
+	code := exitCode(err)
+	os.Stderr = oldStderr
+	if code != exitRateLimited {
+		t.Errorf("Expected exit code %d, got %d (%v)", exitRateLimited, code, err)
+	}
+}
+
+// TestHandleNotifyFanOut tests mirroring a notification to every webhook_urls
+// target and reporting partial failures
+func TestHandleNotifyFanOut(t *testing.T) {
+	var teamHits, opsHits atomic.Int32
+	team := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		teamHits.Add(1)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer team.Close()
+	ops := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opsHits.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer ops.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	_, err := manager.Save(&config.Config{
+		WebhookURL:         team.URL,
+		WebhookURLs:        []string{ops.URL, team.URL},
+		AllowCustomWebhook: true,
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	oldStdout, oldStderr := os.Stdout, os.Stderr
+	r, w, _ := os.Pipe()
+	er, ew, _ := os.Pipe()
+	os.Stdout, os.Stderr = w, ew
+
+	err = handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", Source: "Test"})
+
+	w.Close()
+	ew.Close()
+	os.Stdout, os.Stderr = oldStdout, oldStderr
+	var output, errOutput bytes.Buffer
+	output.ReadFrom(r)
+	errOutput.ReadFrom(er)
+
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 targets failed") {
+		t.Errorf("Expected a partial failure, got %v", err)
+	}
+	// The duplicate of webhook_url is only posted to once
+	if teamHits.Load() != 1 || opsHits.Load() != 1 {
+		t.Errorf("Expected one request per target, got team=%d ops=%d", teamHits.Load(), opsHits.Load())
+	}
+	if !strings.Contains(output.String(), "sent successfully to target 1") {
+		t.Errorf("Expected output to report the delivered target, got %q", output.String())
+	}
+	if !strings.Contains(errOutput.String(), "Target 2 failed") {
+		t.Errorf("Expected stderr to report the failed target, got %q", errOutput.String())
+	}
+
+	// An explicit --webhook sends to that webhook alone
+	err = handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "Test message", WebhookURL: team.URL, AllowCustomWebhook: true})
+	if err != nil {
+		t.Errorf("Expected --webhook to bypass webhook_urls, got %v", err)
+	}
+	if opsHits.Load() != 1 {
+		t.Errorf("Expected no mirrored request, got %d", opsHits.Load())
+	}
+}
+
+// TestNamedTargets tests resolving --target and default_target names
+func TestNamedTargets(t *testing.T) {
+	cfg := &config.Config{
+		WebhookURL: "https://example.com/legacy",
+		Targets: map[string]string{
+			"ops": "https://example.com/ops",
+			"dev": "https://example.com/dev",
+		},
+	}
+
+	tests := []struct {
+		name        string
+		targets     []string
+		cfg         *config.Config
+		expected    []string
+		expectedErr string
+	}{
+		{name: "No targets", cfg: cfg},
+		{name: "Single target", targets: []string{"ops"}, cfg: cfg, expected: []string{"https://example.com/ops"}},
+		{name: "Several targets", targets: []string{"ops", "dev", "ops"}, cfg: cfg, expected: []string{"https://example.com/ops", "https://example.com/dev"}},
+		{name: "Default target", cfg: &config.Config{Targets: cfg.Targets, DefaultTarget: "dev"}, expected: []string{"https://example.com/dev"}},
+		{name: "Flag overrides default", targets: []string{"ops"}, cfg: &config.Config{Targets: cfg.Targets, DefaultTarget: "dev"}, expected: []string{"https://example.com/ops"}},
+		{name: "Unknown target", targets: []string{"prod"}, cfg: cfg, expectedErr: `unknown target "prod" (available: dev, ops)`},
+		{name: "No targets configured", targets: []string{"ops"}, expectedErr: `unknown target "ops" (no targets are configured)`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			urls, err := namedTargets(&cli.Args{Targets: tt.targets}, tt.cfg)
+			if tt.expectedErr != "" {
+				if err == nil || err.Error() != tt.expectedErr {
+					t.Errorf("Expected error %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !slices.Equal(urls, tt.expected) {
+				t.Errorf("Expected %v, got %v", tt.expected, urls)
+			}
+		})
+	}
+}
+
+// TestHandleNotifyTargets tests sending to named targets from config
+func TestHandleNotifyTargets(t *testing.T) {
+	hits := make(map[string]*atomic.Int32)
+	newServer := func(name string) *httptest.Server {
+		hits[name] = &atomic.Int32{}
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[name].Add(1)
+			w.WriteHeader(http.StatusNoContent)
+		}))
+	}
+	legacy, ops, dev := newServer("legacy"), newServer("ops"), newServer("dev")
+	defer legacy.Close()
+	defer ops.Close()
+	defer dev.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	_, err := manager.Save(&config.Config{
+		WebhookURL:         legacy.URL,
+		Targets:            map[string]string{"ops": ops.URL, "dev": dev.URL},
+		DefaultTarget:      "dev",
+		AllowCustomWebhook: true,
+	}, false)
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	send := func(targets ...string) error {
+		oldStdout := os.Stdout
+		_, w, _ := os.Pipe()
+		os.Stdout = w
+		defer func() {
+			w.Close()
+			os.Stdout = oldStdout
+		}()
+		return handleNotify(context.Background(), manager, &cli.Args{Command: cli.CommandNotify, Message: "db migration done", Targets: targets})
+	}
+
+	if err := send(); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if err := send("ops", "dev"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hits["legacy"].Load() != 0 || hits["ops"].Load() != 1 || hits["dev"].Load() != 2 {
+		t.Errorf("Unexpected hits: legacy=%d ops=%d dev=%d", hits["legacy"].Load(), hits["ops"].Load(), hits["dev"].Load())
+	}
+
+	if err := send("prod"); err == nil || !strings.Contains(err.Error(), "available: dev, ops") {
+		t.Errorf("Expected unknown target error, got %v", err)
+	}
+}
+
+// TestHandleNotifyDryRun tests that --dry-run prints the payload without
+// making any request
+func TestHandleNotifyDryRun(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Expected no request to be made in a dry run")
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	webhookURL := server.URL + "/api/webhooks/123456789/secret-token"
+	manager := config.NewManager()
+	_, err := manager.Save(&config.Config{WebhookURL: webhookURL, AllowCustomWebhook: true}, false)
+	if err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	args := &cli.Args{Command: cli.CommandNotify, Message: "Build done", Source: "CI", Fields: []string{"Branch=main"}, DryRun: true, Retries: -1, RateLimitRetries: -1}
+	err = handleNotify(context.Background(), manager, args)
+
+	w.Close()
+	os.Stdout = oldStdout
+	output, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, expected := range []string{
+		"Dry run",
+		"POST " + server.URL + "/api/webhooks/123456789/**** (Discord)",
+		"\n  \"username\": \"Owata\",",
+		"\"name\": \"Branch\",",
+	} {
+		if !strings.Contains(string(output), expected) {
+			t.Errorf("Expected output to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(string(output), "secret-token") {
+		t.Error("Expected the webhook token to be redacted")
+	}
+}
+
+// TestHandleWebhookInfo tests printing the webhook's metadata as JSON
+func TestHandleWebhookInfo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "GET" {
+			t.Errorf("Expected GET request, got %s", r.Method)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"id":"123","name":"Deploy Bot","channel_id":"456","guild_id":"789","token":"secret-token"}`))
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	oldStdout := os.Stdout
+	r, w, _ := os.Pipe()
+	os.Stdout = w
+
+	err := handleWebhookInfo(context.Background(), manager, &cli.Args{Command: cli.CommandWebhookInfo, JSON: true})
+
+	w.Close()
+	os.Stdout = oldStdout
+	output, _ := io.ReadAll(r)
+
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"id":"123","name":"Deploy Bot","channel_id":"456","guild_id":"789"}`
+	if got := strings.TrimSpace(string(output)); got != expected {
+		t.Errorf("Expected %s, got %s", expected, got)
+	}
+}
+
+// TestHandleRun tests running a command and notifying how it went
+func TestHandleRun(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tests := []struct {
+		name          string
+		command       []string
+		status        int
+		tail          int
+		stderrOnly    bool
+		expectedCode  int
+		expectedTitle string
+		expectedColor int
+		expectedTail  string
+		expectedErr   bool
+	}{
+		{"Success", []string{"sh", "-c", "exit 0"}, http.StatusNoContent, 0, false, 0, "✅ Success: sh -c 'exit 0'", run.ColorSuccess, "", false},
+		{"Failure", []string{"sh", "-c", "exit 3"}, http.StatusNoContent, 0, false, 3, "❌ Failed: sh -c 'exit 3'", run.ColorFailure, "", false},
+		{"Exit code kept when the notification fails", []string{"sh", "-c", "exit 2"}, http.StatusBadRequest, 0, false, 2, "❌ Failed: sh -c 'exit 2'", run.ColorFailure, "", false},
+		{"Killed by a signal", []string{"sh", "-c", "kill -KILL $$"}, http.StatusNoContent, 0, false, 137, "⚠️ Killed by SIGKILL: sh -c 'kill -KILL $$'", run.ColorSignaled, "", false},
+		{"Command not found", []string{"owata-no-such-command"}, http.StatusNoContent, 0, false, 127, "", 0, "", true},
+		{"Tail", []string{"sh", "-c", "echo one; echo two; echo three"}, http.StatusNoContent, 2, false, 0, "✅ Success: sh -c 'echo one; echo two; echo three'", run.ColorSuccess, "```\ntwo\nthree\n```", false},
+		{"Tail of stderr only", []string{"sh", "-c", "echo out; echo err >&2"}, http.StatusNoContent, 2, true, 0, "✅ Success: sh -c 'echo out; echo err >&2'", run.ColorSuccess, "```\nerr\n```", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var webhook discord.Webhook
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&webhook)
+				w.WriteHeader(tt.status)
+			}))
+			defer server.Close()
+
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+
+			manager := config.NewManager()
+			if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+				t.Fatalf("Failed to save config: %v", err)
+			}
+
+			args := &cli.Args{Command: cli.CommandRun, RunCommand: tt.command, Tail: tt.tail, TailStderrOnly: tt.stderrOnly, NoCI: true, Retries: -1, RateLimitRetries: -1}
+			code, err := handleRun(context.Background(), manager, args)
+			if (err != nil) != tt.expectedErr {
+				t.Fatalf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			if code != tt.expectedCode {
+				t.Errorf("Expected exit code %d, got %d", tt.expectedCode, code)
+			}
+			if tt.expectedErr {
+				if len(webhook.Embeds) != 0 {
+					t.Error("Expected no notification for a command that couldn't start")
+				}
+				return
+			}
+
+			if len(webhook.Embeds) != 1 {
+				t.Fatalf("Expected one embed, got %+v", webhook)
+			}
+			embed := webhook.Embeds[0]
+			if embed.Title != tt.expectedTitle || embed.Color != tt.expectedColor {
+				t.Errorf("Expected title %q and color %#x, got %q and %#x", tt.expectedTitle, tt.expectedColor, embed.Title, embed.Color)
+			}
+			if tt.expectedTail != "" && !strings.HasSuffix(embed.Description, "\n\n"+tt.expectedTail) {
+				t.Errorf("Expected the output tail %q, got %q", tt.expectedTail, embed.Description)
+			}
+			if embed.Fields[1].Value != "sh" {
+				t.Errorf("Expected the command name as the source, got %+v", embed.Fields[1])
+			}
+		})
+	}
+}
+
+// TestHandleRunNotifyStart tests the notification sent before the command runs
+func TestHandleRunNotifyStart(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tests := []struct {
+		name        string
+		startStatus int
+	}{
+		{"Start delivered", http.StatusNoContent},
+		{"Start failing doesn't stop the command", http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var titles []string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var webhook discord.Webhook
+				json.NewDecoder(r.Body).Decode(&webhook)
+				titles = append(titles, webhook.Embeds[0].Title)
+				if len(titles) == 1 {
+					w.WriteHeader(tt.startStatus)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+
+			manager := config.NewManager()
+			if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+				t.Fatalf("Failed to save config: %v", err)
+			}
+
+			marker := filepath.Join(tempDir, "ran")
+			args := &cli.Args{Command: cli.CommandRun, RunCommand: []string{"touch", marker}, NotifyStart: true, NoCI: true, Retries: -1, RateLimitRetries: -1}
+			code, err := handleRun(context.Background(), manager, args)
+			if err != nil || code != 0 {
+				t.Fatalf("Unexpected result: code %d, error %v", code, err)
+			}
+
+			if _, err := os.Stat(marker); err != nil {
+				t.Error("Expected the command to run")
+			}
+			expected := []string{"▶️ Started: touch " + marker, "✅ Success: touch " + marker}
+			if !slices.Equal(titles, expected) {
+				t.Errorf("Expected notifications %q, got %q", expected, titles)
+			}
+		})
+	}
+}
+
+// TestHandleRunScript tests running a shell command line given with -c
+func TestHandleRunScript(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	var webhook discord.Webhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&webhook)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	args := &cli.Args{Command: cli.CommandRun, RunScript: "true && exit 3", Footer: "via {{source}}", NoCI: true, NoHost: true, Retries: -1, RateLimitRetries: -1}
+	code, err := handleRun(context.Background(), manager, args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code != 3 {
+		t.Errorf("Expected the script's exit code 3, got %d", code)
+	}
+
+	if len(webhook.Embeds) != 1 {
+		t.Fatalf("Expected one embed, got %+v", webhook)
+	}
+	embed := webhook.Embeds[0]
+	if embed.Title != "❌ Failed: true && exit 3" {
+		t.Errorf("Expected the script as given in the title, got %q", embed.Title)
+	}
+	values := map[string]string{}
+	for _, field := range embed.Fields {
+		values[field.Name] = field.Value
+	}
+	if values["Source"] != "true" || values["Command"] != "`true && exit 3`" {
+		t.Errorf("Expected the script's program as the source and the script as the command, got %+v", embed.Fields)
+	}
+	// {{source}} expands to the same source the field shows
+	if !strings.HasPrefix(embed.Footer.Text, "via true") {
+		t.Errorf("Expected {{source}} to expand to the script's program, got %q", embed.Footer.Text)
+	}
+}
+
+// TestHandleRunID tests the run ID given to a command's notifications and
+// passed on to the command
+func TestHandleRunID(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	uuidPattern := regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+	tests := []struct {
+		name     string
+		runID    string
+		envRunID string
+		expected string // Empty for a generated ID
+	}{
+		{"Generated when omitted", "", "", ""},
+		{"Explicit", "deploy-42", "", "deploy-42"},
+		{"From the environment", "", "nightly", "nightly"},
+		{"Explicit over the environment", "deploy-42", "nightly", "deploy-42"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var webhook discord.Webhook
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				json.NewDecoder(r.Body).Decode(&webhook)
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+			t.Setenv("OWATA_RUN_ID", tt.envRunID)
+
+			manager := config.NewManager()
+			if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+				t.Fatalf("Failed to save config: %v", err)
+			}
+
+			// The command sees the run ID, as an owata it runs would
+			seen := filepath.Join(tempDir, "run-id")
+			args := &cli.Args{Command: cli.CommandRun, RunScript: `printf %s "$OWATA_RUN_ID" > ` + seen, RunID: tt.runID, NoCI: true, Retries: -1, RateLimitRetries: -1}
+			if code, err := handleRun(context.Background(), manager, args); err != nil || code != 0 {
+				t.Fatalf("Unexpected result: code %d, error %v", code, err)
+			}
+
+			data, _ := os.ReadFile(seen)
+			runID := string(data)
+			if tt.expected == "" && !uuidPattern.MatchString(runID) {
+				t.Errorf("Expected a generated UUID, got %q", runID)
+			} else if tt.expected != "" && runID != tt.expected {
+				t.Errorf("Expected run ID %q, got %q", tt.expected, runID)
+			}
+			if len(webhook.Embeds) != 1 || !strings.HasSuffix(webhook.Embeds[0].Footer.Text, " • run "+correlation.ShortID(runID)) {
+				t.Errorf("Expected the run ID in the footer, got %+v", webhook.Embeds)
+			}
+		})
+	}
+}
+
+// TestHandleRunTimeout tests stopping a hung command, and that its
+// notification still goes out after owata itself was interrupted
+func TestHandleRunTimeout(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	var webhook discord.Webhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&webhook)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	// Ctrl-C cancels main's context, which must not cancel the final notification
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	command := []string{"sh", "-c", "echo started; exec sleep 10"}
+	args := &cli.Args{Command: cli.CommandRun, RunCommand: command, Tail: 5, Timeout: 100 * time.Millisecond, NoCI: true, Retries: -1, RateLimitRetries: -1}
+	code, err := handleRun(ctx, manager, args)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if code != run.ExitCodeTimeout {
+		t.Errorf("Expected exit code %d, got %d", run.ExitCodeTimeout, code)
+	}
+
+	if len(webhook.Embeds) != 1 {
+		t.Fatalf("Expected one embed, got %+v", webhook)
+	}
+	embed := webhook.Embeds[0]
+	expectedTitle := "⏰ Timed out after 100ms: sh -c 'echo started; exec sleep 10'"
+	if embed.Title != expectedTitle || embed.Color != run.ColorTimedOut {
+		t.Errorf("Expected title %q and color %#x, got %q and %#x", expectedTitle, run.ColorTimedOut, embed.Title, embed.Color)
+	}
+	if !strings.HasSuffix(embed.Description, "```\nstarted\n```") {
+		t.Errorf("Expected the output tail, got %q", embed.Description)
+	}
+}
+
+// TestHandleWaitPID tests notifying when a process owata didn't start exits
+func TestHandleWaitPID(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	var webhooks []discord.Webhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var webhook discord.Webhook
+		json.NewDecoder(r.Body).Decode(&webhook)
+		webhooks = append(webhooks, webhook)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	cmd := exec.Command("sleep", "0.2")
+	if err := cmd.Start(); err != nil {
+		t.Fatalf("Failed to start sleep: %v", err)
+	}
+	go cmd.Wait()
+
+	args := &cli.Args{Command: cli.CommandWaitPID, PID: cmd.Process.Pid, Interval: 20 * time.Millisecond, NoCI: true, Retries: -1, RateLimitRetries: -1}
+	if err := handleWaitPID(context.Background(), manager, args); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(webhooks) != 1 || len(webhooks[0].Embeds) != 1 {
+		t.Fatalf("Expected one notification, got %+v", webhooks)
+	}
+	title := webhooks[0].Embeds[0].Title
+	if title != "🏁 Exited: sleep 0.2" && title != fmt.Sprintf("🏁 Process %d exited", cmd.Process.Pid) {
+		t.Errorf("Unexpected title %q", title)
+	}
+
+	// Gone already, so it fails before anything is sent
+	args.PID = 99999999
+	if err := handleWaitPID(context.Background(), manager, args); err == nil {
+		t.Error("Expected an error for a PID that doesn't exist")
+	}
+	if len(webhooks) != 1 {
+		t.Errorf("Expected no notification for a missing PID, got %d in total", len(webhooks))
+	}
+}
+
+// TestHandleWaitPort tests waiting for a port to come up, and giving up on
+// one that never does
+func TestHandleWaitPort(t *testing.T) {
+	var webhooks []discord.Webhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var webhook discord.Webhook
+		json.NewDecoder(r.Body).Decode(&webhook)
+		webhooks = append(webhooks, webhook)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := listener.Addr().String()
+
+	args := &cli.Args{Command: cli.CommandWaitPort, Address: address, Interval: 20 * time.Millisecond, Timeout: 5 * time.Second, NoCI: true, Retries: -1, RateLimitRetries: -1}
+	if err := handleWaitPort(context.Background(), manager, args); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(webhooks) != 1 || len(webhooks[0].Embeds) != 1 {
+		t.Fatalf("Expected one notification, got %+v", webhooks)
+	}
+	if title := webhooks[0].Embeds[0].Title; title != "🔌 "+address+" is up" {
+		t.Errorf("Unexpected title %q", title)
+	}
+
+	// Still listening, so waiting for it to go down times out
+	args.UntilDown = true
+	args.Timeout = 100 * time.Millisecond
+	err = handleWaitPort(context.Background(), manager, args)
+	listener.Close()
+	if err == nil || !strings.Contains(err.Error(), "gave up waiting for "+address) {
+		t.Errorf("Expected a gave up error, got %v", err)
+	}
+	if len(webhooks) != 2 {
+		t.Fatalf("Expected a notification on timeout too, got %d in total", len(webhooks))
+	}
+	if title := webhooks[1].Embeds[0].Title; title != "⏰ Gave up on "+address {
+		t.Errorf("Unexpected title %q", title)
+	}
+}
+
+// TestHandleWatchFile tests notifying once a watched file appears, and
+// failing up front when its directory doesn't exist
+func TestHandleWatchFile(t *testing.T) {
+	var webhooks []discord.Webhook
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var webhook discord.Webhook
+		json.NewDecoder(r.Body).Decode(&webhook)
+		webhooks = append(webhooks, webhook)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	path := filepath.Join(tempDir, "report.pdf")
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		// Rename a finished file into place, so the poll can't catch it empty
+		os.WriteFile(path+".part", []byte("%PDF"), 0644)
+		os.Rename(path+".part", path)
+	}()
+
+	args := &cli.Args{Command: cli.CommandWatchFile, WatchPath: path, Events: []string{"create"}, Once: true, Interval: 20 * time.Millisecond, Debounce: 0, NoCI: true, Retries: -1, RateLimitRetries: -1}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := handleWatchFile(ctx, manager, args); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(webhooks) != 1 || len(webhooks[0].Embeds) != 1 {
+		t.Fatalf("Expected one notification, got %+v", webhooks)
+	}
+	embed := webhooks[0].Embeds[0]
+	if embed.Title != "📄 report.pdf was created" {
+		t.Errorf("Unexpected title %q", embed.Title)
+	}
+	if !slices.ContainsFunc(embed.Fields, func(f notifier.Field) bool { return f.Name == "Size" && f.Value == "4 B" }) {
+		t.Errorf("Expected a Size field, got %+v", embed.Fields)
+	}
+
+	args.WatchPath = filepath.Join(tempDir, "missing", "report.pdf")
+	err := handleWatchFile(ctx, manager, args)
+	if err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expected a missing directory error, got %v", err)
+	}
+	if len(webhooks) != 1 {
+		t.Errorf("Expected no notification for a missing directory, got %d in total", len(webhooks))
+	}
+}
+
+// TestHandleRunNotifyOn tests notifying only on failure or success, or
+// only for slow commands
+func TestHandleRunNotifyOn(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tests := []struct {
+		name           string
+		flag           string
+		configNotifyOn string
+		minDuration    time.Duration
+		appliesTo      string
+		exitCode       int
+		expectNotified bool
+	}{
+		{"Always, passing", "", "", 0, "", 0, true},
+		{"Always, failing", "", "", 0, "", 1, true},
+		{"On failure, passing", "failure", "", 0, "", 0, false},
+		{"On failure, failing", "failure", "", 0, "", 1, true},
+		{"On success, passing", "success", "", 0, "", 0, true},
+		{"On success, failing", "success", "", 0, "", 1, false},
+		{"Config on failure, passing", "", "failure", 0, "", 0, false},
+		{"Config on failure, failing", "", "failure", 0, "", 1, true},
+		{"Flag overrides config", "success", "failure", 0, "", 0, true},
+		{"Quick success under min duration", "", "", time.Hour, "", 0, false},
+		{"Quick failure under min duration", "", "", time.Hour, "", 1, true},
+		{"Quick failure under min duration for all", "", "", time.Hour, cli.AppliesToAll, 1, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			notified := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				notified = true
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+
+			manager := config.NewManager()
+			if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true, NotifyOn: tt.configNotifyOn}, false); err != nil {
+				t.Fatalf("Failed to save config: %v", err)
+			}
+
+			command := []string{"sh", "-c", fmt.Sprintf("exit %d", tt.exitCode)}
+			args := &cli.Args{Command: cli.CommandRun, RunCommand: command, NotifyOn: tt.flag, MinDuration: tt.minDuration, MinDurationAppliesTo: tt.appliesTo, NoCI: true, Retries: -1, RateLimitRetries: -1}
+			code, err := handleRun(context.Background(), manager, args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if code != tt.exitCode {
+				t.Errorf("Expected exit code %d, got %d", tt.exitCode, code)
+			}
+			if notified != tt.expectNotified {
+				t.Errorf("Expected notified=%v, got %v", tt.expectNotified, notified)
+			}
+		})
+	}
+}
+
+// TestHandleTest tests sending the test message to the configured webhook
+func TestHandleTest(t *testing.T) {
+	tests := []struct {
+		name        string
+		status      int
+		body        string
+		expectedErr string
+	}{
+		{"Delivered", http.StatusNoContent, "", ""},
+		{"Invalid token", http.StatusUnauthorized, `{"message": "Invalid Webhook Token", "code": 50027}`, "Invalid Webhook Token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var title string
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var webhook discord.Webhook
+				json.NewDecoder(r.Body).Decode(&webhook)
+				title = webhook.Embeds[0].Title
+				w.WriteHeader(tt.status)
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+
+			manager := config.NewManager()
+			if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+				t.Fatalf("Failed to save config: %v", err)
+			}
+
+			args := &cli.Args{Command: cli.CommandTest, Retries: -1, RateLimitRetries: -1}
+			err := handleTest(context.Background(), manager, args)
+
+			if title != testNotification.Title {
+				t.Errorf("Expected title %q, got %q", testNotification.Title, title)
+			}
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("Expected error, got nil")
+			}
+			for _, expected := range []string{"test message failed", tt.expectedErr} {
+				if !strings.Contains(err.Error(), expected) {
+					t.Errorf("Expected error to contain %q, got %v", expected, err)
+				}
+			}
+		})
+	}
+}
+
+// TestHandleDoctor tests the doctor checklist against a local server
+func TestHandleDoctor(t *testing.T) {
+	tests := []struct {
+		name        string
+		webhook     bool
+		send        bool
+		expected    []string
+		expectedErr bool
+	}{
+		{"Set up", true, false, []string{"✅ Local config: owata-config.json is valid", "➖ Global config: none at", "✅ DNS: 127.0.0.1 resolves to 127.0.0.1", "✅ Clock:", "➖ Test message: run with --send", "5 passed, 0 warnings, 0 failed, 2 skipped"}, false},
+		{"Send", true, true, []string{"✅ Test message: delivered in", "6 passed, 0 warnings, 0 failed, 1 skipped"}, false},
+		{"No webhook", false, true, []string{"❌ Webhook URL: no webhook URL set", "✅ DNS: discord.com resolves to", "➖ Test message: no usable webhook URL"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			posted := false
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodPost {
+					posted = true
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			originalLookup, originalClient := lookupHost, doctorClient
+			defer func() { lookupHost, doctorClient = originalLookup, originalClient }()
+			lookupHost = func(context.Context, string) ([]string, error) { return []string{"127.0.0.1"}, nil }
+			doctorClient = server.Client()
+
+			tempDir := t.TempDir()
+			originalDir, _ := os.Getwd()
+			defer os.Chdir(originalDir)
+			os.Chdir(tempDir)
+			config.SetTestConfigDir(t.TempDir())
+			defer config.ResetTestConfigDir()
+
+			manager := config.NewManager()
+			cfg := &config.Config{Username: "Bot"}
+			if tt.webhook {
+				cfg = &config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}
+			}
+			if _, err := manager.Save(cfg, false); err != nil {
+				t.Fatalf("Failed to save config: %v", err)
+			}
+
+			oldStdout := os.Stdout
+			r, w, _ := os.Pipe()
+			os.Stdout = w
+
+			args := &cli.Args{Command: cli.CommandDoctor, Send: tt.send, Retries: -1, RateLimitRetries: -1}
+			err := handleDoctor(context.Background(), manager, args)
+
+			w.Close()
+			os.Stdout = oldStdout
+			output, _ := io.ReadAll(r)
+
+			if (err != nil) != tt.expectedErr {
+				t.Errorf("Expected error %v, got %v", tt.expectedErr, err)
+			}
+			for _, expected := range tt.expected {
+				if !strings.Contains(string(output), expected) {
+					t.Errorf("Expected %q in output:\n%s", expected, output)
+				}
+			}
+			if posted != (tt.send && tt.webhook) {
+				t.Errorf("Expected a test message posted only with --send and a webhook, got posted=%v", posted)
+			}
+		})
+	}
+}
+
+// TestReadStdinMessage tests reading the message for "-" from a pipe
+func TestReadStdinMessage(t *testing.T) {
+	long := strings.Repeat("あ", maxMessageInput/3+100)
+
+	tests := []struct {
+		name        string
+		input       string
+		expected    string
+		expectedErr bool
+	}{
+		{"Single line", "build failed\n", "build failed", false},
+		{"Several lines", "line 1\nline 2\r\n\n", "line 1\nline 2", false},
+		{"Leading space kept", "  indented\n", "  indented", false},
+		{"Empty", "", "", true},
+		{"Blank", "\n\n", "", true},
+		{"Cut at the limit on a character boundary", long, long[:maxMessageInput-maxMessageInput%3], false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, w, err := os.Pipe()
+			if err != nil {
+				t.Fatalf("Failed to create pipe: %v", err)
+			}
+			defer r.Close()
+			go func() {
+				w.WriteString(tt.input)
+				w.Close()
+			}()
+
+			message, err := readStdinMessage(r)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected error, got %q", message)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message != tt.expected {
+				t.Errorf("Expected %d bytes %.20q, got %d bytes %.20q", len(tt.expected), tt.expected, len(message), message)
+			}
+		})
+	}
+}
+
+// TestReadMessageFile tests reading the message for --message-file
+func TestReadMessageFile(t *testing.T) {
+	dir := t.TempDir()
+
+	tests := []struct {
+		name        string
+		content     string
+		expected    string
+		expectedErr string
+	}{
+		{"Markdown", "## v2.1\n\n- Faster builds\n", "## v2.1\n\n- Faster builds", ""},
+		{"BOM stripped", "\uFEFFRelease notes\r\n", "Release notes", ""},
+		{"Longer than one post kept for splitting", strings.Repeat("x", 5000), strings.Repeat("x", 5000), ""},
+		{"Empty", "", "", "is empty"},
+		{"Too large", strings.Repeat("x", maxMessageInput+1), "", "--attach"},
+	}
+
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(dir, fmt.Sprintf("message-%d.md", i))
+			if err := os.WriteFile(path, []byte(tt.content), 0644); err != nil {
+				t.Fatalf("Failed to write message file: %v", err)
+			}
+
+			message, err := readMessageFile(path)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected an error mentioning %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if message != tt.expected {
+				t.Errorf("Expected %.30q, got %.30q", tt.expected, message)
+			}
+		})
+	}
+
+	t.Run("Missing file fails before sending", func(t *testing.T) {
+		var requests int
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests++
+			w.WriteHeader(http.StatusNoContent)
+		}))
+		defer server.Close()
+
+		args := &cli.Args{Command: cli.CommandNotify, MessageFile: filepath.Join(dir, "missing.md"), WebhookURL: server.URL, AllowCustomWebhook: true, Retries: -1, RateLimitRetries: -1}
+		err := handleNotify(context.Background(), config.NewManager(), args)
+		if err == nil || !strings.Contains(err.Error(), "does not exist") {
+			t.Errorf("Expected a missing file error, got %v", err)
+		}
+		if requests != 0 {
+			t.Errorf("Expected no request, got %d", requests)
+		}
+	})
+}
+
+// TestHandleBatch tests sending one notification per line read from stdin
+func TestHandleBatch(t *testing.T) {
+	var messages []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var webhook discord.Webhook
+		json.NewDecoder(r.Body).Decode(&webhook)
+		description := webhook.Embeds[0].Description
+		messages = append(messages, description)
+		if strings.Contains(description, "reject") {
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"message": "Invalid Form Body", "code": 50035}`))
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	tempDir := t.TempDir()
+	originalDir, _ := os.Getwd()
+	defer os.Chdir(originalDir)
+	os.Chdir(tempDir)
+	config.SetTestConfigDir(t.TempDir())
+	defer config.ResetTestConfigDir()
+
+	manager := config.NewManager()
+	if _, err := manager.Save(&config.Config{WebhookURL: server.URL, AllowCustomWebhook: true}, false); err != nil {
+		t.Fatalf("Failed to save config: %v", err)
+	}
+
+	tests := []struct {
+		name        string
+		input       string
+		size        int
+		expected    []string
+		expectedErr string
+		stderr      string // Expected on stderr, if anything
+	}{
+		{
+			name:     "One per line",
+			input:    "a.go:1: unused\n\n   \nb.go:2: shadowed\r\n",
+			size:     1,
+			expected: []string{"a.go:1: unused", "b.go:2: shadowed"},
+		},
+		{
+			name:     "Bundled",
+			input:    "one\ntwo\nthree\n",
+			size:     2,
+			expected: []string{"one\ntwo", "three"},
+		},
+		{
+			name:        "Partial failure",
+			input:       "one\nreject me\nthree",
+			size:        1,
+			expected:    []string{"one", "reject me", "three"},
+			expectedErr: "sent 2/3, 1 failed",
+			stderr:      "Message 2 failed",
+		},
+		{
+			name:        "Only blank lines",
+			input:       "\n\n",
+			size:        1,
+			expectedErr: "no messages to send",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages = nil
+
+			r, w, _ := os.Pipe()
+			go func() {
+				w.Write([]byte(tt.input))
+				w.Close()
+			}()
+
+			oldStdout, oldStderr := os.Stdout, os.Stderr
+			_, out, _ := os.Pipe()
+			errIn, errOut, _ := os.Pipe()
+			os.Stdout, os.Stderr = out, errOut
+			args := &cli.Args{Command: cli.CommandNotify, Batch: true, BatchSize: tt.size, Source: "lint", Retries: -1, RateLimitRetries: -1}
+			err := handleBatch(context.Background(), manager, args, r)
+			out.Close()
+			errOut.Close()
+			os.Stdout, os.Stderr = oldStdout, oldStderr
+			var stderr bytes.Buffer
+			stderr.ReadFrom(errIn)
+
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !slices.Equal(messages, tt.expected) {
+				t.Errorf("Expected messages %q, got %q", tt.expected, messages)
+			}
+			if tt.stderr != "" && !strings.Contains(stderr.String(), tt.stderr) {
+				t.Errorf("Expected stderr to contain %q, got %q", tt.stderr, stderr.String())
+			}
+		})
+	}
+}
+
+// TestWithIdentity tests the username and avatar precedence: flag, then the
+// loaded config, then the defaults. The global config is merged into the
+// loaded one before, as config.TestResolveMerge tests.
+func TestWithIdentity(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           *cli.Args
+		cfg            *config.Config
+		expectedUser   string
+		expectedAvatar string
+	}{
+		{
+			name:           "Flags override config",
+			args:           &cli.Args{Username: "FlagBot", AvatarURL: "https://example.com/flag.png"},
+			cfg:            &config.Config{Username: "LocalBot", AvatarURL: "https://example.com/local.png"},
+			expectedUser:   "FlagBot",
+			expectedAvatar: "https://example.com/flag.png",
+		},
+		{
+			name:           "Config only",
+			args:           &cli.Args{},
+			cfg:            &config.Config{Username: "LocalBot"},
+			expectedUser:   "LocalBot",
+			expectedAvatar: "",
+		},
+		{
+			name:           "Flag with the config's avatar",
+			args:           &cli.Args{Username: "FlagBot"},
+			cfg:            &config.Config{AvatarURL: "https://example.com/local.png"},
+			expectedUser:   "FlagBot",
+			expectedAvatar: "https://example.com/local.png",
+		},
+		{
+			name:         "No config",
+			args:         &cli.Args{},
+			expectedUser: config.DefaultUsername,
+		},
+		{
+			name: "Webhook defaults",
+			args: &cli.Args{},
+			cfg:  &config.Config{UseWebhookDefaults: true, Username: "LocalBot"},
+		},
+		{
+			// The config's setting to post as the webhook itself isn't
+			// overridden per notification
+			name: "Flags don't override webhook defaults",
+			args: &cli.Args{Username: "FlagBot", AvatarURL: "https://example.com/flag.png"},
+			cfg:  &config.Config{UseWebhookDefaults: true, Username: "LocalBot", AvatarURL: "https://example.com/local.png"},
+		},
+		{
+			name: "No identity flag",
+			args: &cli.Args{NoIdentity: true},
+			cfg:  &config.Config{Username: "LocalBot", AvatarURL: "https://example.com/local.png"},
+		},
+		{
+			name: "No identity flag without a config",
+			args: &cli.Args{NoIdentity: true},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var original config.Config
+			if tt.cfg != nil {
+				original = *tt.cfg
+			}
+
+			resolved := withIdentity(tt.args, tt.cfg)
+			username, avatarURL := notifier.ResolveIdentity(resolved)
+			if username != tt.expectedUser || avatarURL != tt.expectedAvatar {
+				t.Errorf("Expected %q %q, got %q %q", tt.expectedUser, tt.expectedAvatar, username, avatarURL)
+			}
+			if tt.cfg != nil && (tt.cfg.Username != original.Username || tt.cfg.AvatarURL != original.AvatarURL) {
+				t.Error("Expected the loaded config to be left untouched")
+			}
+		})
+	}
+}
+
+// TestResolveProvider tests picking the webhook service from flags, config
+// and the URL's host
+func TestResolveProvider(t *testing.T) {
+	slackURL := "https://hooks.slack.com/services/T000/B000/XXXX"
+	discordURL := "https://discord.com/api/webhooks/1/token"
+
+	tests := []struct {
+		name        string
+		url         string
+		flag        string
+		cfg         *config.Config
+		expected    string
+		expectedErr bool
+	}{
+		{name: "Detect Discord", url: discordURL, expected: "discord"},
+		{name: "Detect Slack", url: slackURL, expected: "slack"},
+		{name: "Detect ntfy", url: "https://ntfy.sh/my-topic", expected: "ntfy"},
+		{name: "Self-hosted ntfy", url: "https://ntfy.lan/alerts", cfg: &config.Config{Provider: "ntfy"}, expected: "ntfy"},
+		{name: "Detect Telegram", url: "https://api.telegram.org/bot123:abc/sendMessage", expected: "telegram"},
+		{name: "Generic", url: "https://alerts.example.com/v2/enqueue", cfg: &config.Config{Provider: "generic"}, expected: "generic"},
+		{name: "Config", url: "https://relay.example.com/hook", cfg: &config.Config{Provider: "slack"}, expected: "slack"},
+		{name: "Flag overrides config", url: slackURL, flag: "discord", cfg: &config.Config{Provider: "slack"}, expected: "discord"},
+		{name: "Unknown", url: discordURL, flag: "teams", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			provider, err := resolveProvider(tt.url, &cli.Args{Provider: tt.flag}, tt.cfg)
+			if tt.expectedErr {
+				if err == nil {
+					t.Error("Expected error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if provider != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, provider)
+			}
+		})
+	}
+
+	// Slack URLs are validated as Slack webhooks rather than rejected as non-Discord
+	if err := checkWebhookURL(slackURL, &cli.Args{}, nil); err != nil {
+		t.Errorf("Expected a Slack webhook to be accepted, got %v", err)
+	}
+	if err := checkWebhookURL("https://hooks.slack.com/api/x", &cli.Args{}, nil); err == nil || !strings.Contains(err.Error(), "non-Slack endpoints") {
+		t.Errorf("Expected a Slack validation error, got %v", err)
+	}
+	if err := requireDiscord(slackURL, "delete", &cli.Args{}, nil); err == nil {
+		t.Error("Expected delete to be rejected for Slack")
+	}
+}
+
+// TestProviders tests that each provider posts its own payload
+func TestProviders(t *testing.T) {
+	tests := []struct {
+		provider string
+		response string
+		check    func(t *testing.T, body []byte)
+	}{
+		{
+			provider: notifier.Discord,
+			response: `{"id": "1", "channel_id": "2"}`,
+			check: func(t *testing.T, body []byte) {
+				var payload discord.Webhook
+				if err := json.Unmarshal(body, &payload); err != nil || len(payload.Embeds) != 1 {
+					t.Errorf("Expected a Discord payload with one embed, got %s", body)
+				}
+			},
+		},
+		{
+			provider: notifier.Slack,
+			response: "ok",
+			check: func(t *testing.T, body []byte) {
+				if !strings.Contains(string(body), `"attachments"`) {
+					t.Errorf("Expected a Slack payload, got %s", body)
+				}
+			},
+		},
+		{
+			provider: notifier.Ntfy,
+			response: `{"id": "abc"}`,
+			check: func(t *testing.T, body []byte) {
+				if !strings.HasPrefix(string(body), "msg") {
+					t.Errorf("Expected the message as the body, got %s", body)
+				}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.provider, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				tt.check(t, body)
+				io.WriteString(w, tt.response)
+			}))
+			defer server.Close()
+
+			n := notifier.Notification{Message: "msg", Source: "test"}
+			if _, err := sendNotification(context.Background(), server.URL, n, &cli.Args{Provider: tt.provider}, &config.Config{}, webhook.DefaultSendOptions()); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+		})
+	}
+}
+
+// TestProviderRequests tests the requests a provider builds for --dry-run
+func TestProviderRequests(t *testing.T) {
+	cfg := &config.Config{NtfyToken: "tk_secret"}
+	n := notifier.Notification{Message: "msg", Title: "Deploy"}
+
+	requests, err := providers[notifier.Discord].build(n, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	var payload discord.Webhook
+	if len(requests) != 1 || json.Unmarshal(requests[0].Body, &payload) != nil || payload.Embeds[0].Title != "Deploy" {
+		t.Errorf("Unexpected Discord requests: %+v", requests)
+	}
+
+	requests, err = providers[notifier.Ntfy].build(n, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(requests) != 1 || requests[0].Header.Get("Authorization") != "Bearer tk_secret" {
+		t.Errorf("Unexpected ntfy requests: %+v", requests)
+	}
+}
+
+// TestPrintSendResult tests the human and JSON renderings of a sent notification
+func TestPrintSendResult(t *testing.T) {
+	capture := func(fn func()) string {
+		oldStdout := os.Stdout
+		r, w, _ := os.Pipe()
+		os.Stdout = w
+		fn()
+		w.Close()
+		os.Stdout = oldStdout
+		var buf bytes.Buffer
+		buf.ReadFrom(r)
+		return buf.String()
+	}
+
+	result := &notifier.Result{MessageID: "1234567890", ChannelID: "42"}
+
+	human := capture(func() { printSendResult(result, false, "Discord", "") })
+	if human != "✅ Discord notification sent successfully (message id 1234567890)\n" {
+		t.Errorf("Unexpected human output: %q", human)
+	}
+
+	jsonOutput := capture(func() { printSendResult(result, true, "Discord", "") })
+	var decoded notifier.Result
+	if err := json.Unmarshal([]byte(jsonOutput), &decoded); err != nil {
+		t.Fatalf("Expected JSON output, got %q: %v", jsonOutput, err)
+	}
+	if decoded != *result {
+		t.Errorf("Expected %+v, got %+v", *result, decoded)
+	}
+
+	timed := &notifier.Result{MessageID: "1234567890", StatusCode: 200, Attempts: 2, Elapsed: 1234567 * time.Microsecond}
+	human = capture(func() { printSendResult(timed, false, "Discord", "") })
+	if human != "✅ Discord notification sent successfully (message id 1234567890, 2 attempts, 1.235s)\n" {
+		t.Errorf("Unexpected human output: %q", human)
+	}
+
+	jsonOutput = capture(func() { printSendResult(timed, true, "Discord", "") })
+	if !strings.Contains(jsonOutput, `"status_code":200`) || !strings.Contains(jsonOutput, `"attempts":2`) || !strings.Contains(jsonOutput, `"elapsed_ms":1234`) {
+		t.Errorf("Unexpected JSON output: %q", jsonOutput)
 	}
 }
@@ -0,0 +1,204 @@
+package notifier
+
+import (
+	"cmp"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/correlation"
+)
+
+const (
+	DefaultColor = 3447003 // Blue color
+	DefaultTitle = "🔔 Notification"
+
+	// DefaultFooterText brands the footer unless footer_text is configured
+	DefaultFooterText = "Owata"
+)
+
+// Card is a notification with its appearance filled in from the config, as
+// every provider shows it: a title, the message, fields and a footer
+type Card struct {
+	Title     string
+	Message   string
+	Color     int
+	Timestamp time.Time
+	Fields    []Field // The built-in fields, then those of ResolveFields
+	Footer    Footer
+	Thumbnail string  // Empty when there is none
+	Author    *Author // Nil when there is none
+}
+
+// For testing purposes
+var (
+	hostnameFunc = os.Hostname
+	nowFunc      = time.Now
+)
+
+// BuildCard fills in a notification's appearance from the notification and
+// then the config
+func BuildCard(n Notification, cfg *config.Config) (Card, error) {
+	title, err := ResolveTitle(n, cfg)
+	if err != nil {
+		return Card{}, err
+	}
+	footer, err := resolveFooter(n, cfg)
+	if err != nil {
+		return Card{}, err
+	}
+	card := Card{
+		Title:     title,
+		Message:   n.Message,
+		Color:     cmp.Or(n.Color, cfg.Color(), DefaultColor),
+		Timestamp: nowFunc(),
+		Footer:    footer,
+		Thumbnail: resolveThumbnail(n, cfg),
+	}
+	if cwd := ResolveCwd(cfg); cwd != "" {
+		card.Fields = append(card.Fields, Field{Name: "Working Directory", Value: cwd, Inline: false})
+	}
+	card.Fields = append(card.Fields, Field{Name: "Source", Value: n.Source, Inline: true})
+	if host := ResolveHost(n, cfg); host != "" {
+		card.Fields = append(card.Fields, Field{Name: "Host", Value: host, Inline: true})
+	}
+	if sent := cfg.FormatTime(card.Timestamp); sent != "" {
+		card.Fields = append(card.Fields, Field{Name: "Time", Value: sent, Inline: true})
+	}
+	fields, err := ResolveFields(n, cfg)
+	if err != nil {
+		return Card{}, err
+	}
+	card.Fields = append(card.Fields, fields...)
+
+	card.Author, err = resolveAuthor(n, cfg)
+	if err != nil {
+		return Card{}, err
+	}
+	return card, nil
+}
+
+// ResolveHost returns the machine's hostname to show with a notification, or
+// "" when it is hidden by --no-host, hide_host or show_host in the embed
+// section
+func ResolveHost(n Notification, cfg *config.Config) string {
+	if n.NoHost || !cfg.ShowHost() {
+		return ""
+	}
+	host, err := hostnameFunc()
+	if err != nil || host == "" {
+		return "Unknown"
+	}
+	return host
+}
+
+// ResolveCwd returns the working directory to show with a notification, or
+// "" when show_cwd in the embed section hides it
+func ResolveCwd(cfg *config.Config) string {
+	if !cfg.ShowCwd() {
+		return ""
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "Unknown"
+	}
+	return cwd
+}
+
+// ResolveIdentity picks the username and avatar URL to post as. Both are
+// empty when use_webhook_defaults is set so the webhook's own identity shows.
+func ResolveIdentity(cfg *config.Config) (username, avatarURL string) {
+	if cfg == nil {
+		return config.DefaultUsername, ""
+	}
+	if cfg.UseWebhookDefaults {
+		return "", ""
+	}
+	return cmp.Or(cfg.Username, config.DefaultUsername), cfg.AvatarURL
+}
+
+// ResolveTitle picks the title: the notification's own, then the configured
+// one with its placeholders expanded, then DefaultTitle
+func ResolveTitle(n Notification, cfg *config.Config) (string, error) {
+	if n.Title != "" {
+		return n.Title, nil
+	}
+	if title := cfg.Title(); title != "" {
+		return n.ExpandConfig("title", title)
+	}
+	return DefaultTitle, nil
+}
+
+// ExpandConfig expands the placeholders in configured text with n.Expand, if
+// set
+func (n *Notification) ExpandConfig(name, text string) (string, error) {
+	if n.Expand == nil {
+		return text, nil
+	}
+	return n.Expand(name, text)
+}
+
+// resolveFooter picks the footer text and icon: the notification's own, then
+// the configured ones, with the text falling back to DefaultFooterText
+func resolveFooter(n Notification, cfg *config.Config) (Footer, error) {
+	footer := n.Footer
+	if cfg != nil {
+		if footer.Text == "" && cfg.Footer() != "" {
+			text, err := n.ExpandConfig("footer", cfg.Footer())
+			if err != nil {
+				return Footer{}, err
+			}
+			footer.Text = text
+		}
+		footer.IconURL = cmp.Or(footer.IconURL, cfg.FooterIconURL)
+	}
+	footer.Text = footerText(n, footer.Text)
+	return footer, nil
+}
+
+// footerText builds the footer text from base (DefaultFooterText if empty),
+// appending the sequence number and run ID if set
+func footerText(n Notification, base string) string {
+	text := cmp.Or(base, DefaultFooterText)
+	switch {
+	case n.RunID != "" && n.Seq > 0:
+		text += fmt.Sprintf(" • step %d/… (run %s)", n.Seq, correlation.ShortID(n.RunID))
+	case n.RunID != "":
+		text += fmt.Sprintf(" • run %s", correlation.ShortID(n.RunID))
+	case n.Seq > 0:
+		text += fmt.Sprintf(" • step %d/…", n.Seq)
+	}
+	return text
+}
+
+// resolveThumbnail picks the thumbnail URL: the notification's own, then the
+// configured one
+func resolveThumbnail(n Notification, cfg *config.Config) string {
+	if n.Thumbnail != "" {
+		return n.Thumbnail
+	}
+	if cfg != nil {
+		return cfg.ThumbnailURL
+	}
+	return ""
+}
+
+// resolveAuthor merges the notification's author with the configured
+// defaults, returning nil when there is no author to show
+func resolveAuthor(n Notification, cfg *config.Config) (*Author, error) {
+	author := n.Author
+	if cfg != nil {
+		author.Name = cmp.Or(author.Name, cfg.AuthorName)
+		author.URL = cmp.Or(author.URL, cfg.AuthorURL)
+		author.IconURL = cmp.Or(author.IconURL, cfg.AuthorIconURL)
+	}
+
+	if author == (Author{}) {
+		return nil, nil
+	}
+	if author.Name == "" {
+		return nil, fmt.Errorf("an author URL or icon requires an author name")
+	}
+	return &author, nil
+}
@@ -0,0 +1,222 @@
+package notifier
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/placeholder"
+)
+
+func TestFooterText(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        Notification
+		base     string
+		expected string
+	}{
+		{name: "No correlation", n: Notification{}, expected: "Owata"},
+		{name: "Custom base", n: Notification{RunID: "7f2c1a9e-0b6d"}, base: "acme-ci • production", expected: "acme-ci • production • run 7f2c"},
+		{name: "Run ID only", n: Notification{RunID: "7f2c1a9e-0b6d"}, expected: "Owata • run 7f2c"},
+		{name: "Run ID and sequence", n: Notification{RunID: "7f2c1a9e-0b6d", Seq: 3}, expected: "Owata • step 3/… (run 7f2c)"},
+		{name: "Sequence only", n: Notification{Seq: 2}, expected: "Owata • step 2/…"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := footerText(tt.n, tt.base); got != tt.expected {
+				t.Errorf("Expected footer %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestBuildCardColor(t *testing.T) {
+	tests := []struct {
+		name     string
+		color    int
+		config   *config.Config
+		expected int
+	}{
+		{"Default", 0, nil, DefaultColor},
+		{"Custom", 0x2ecc71, nil, 0x2ecc71},
+		{"Config default", 0, &config.Config{DefaultColor: "#9b59b6"}, 0x9b59b6},
+		{"Notification overrides config", 0x2ecc71, &config.Config{DefaultColor: "#9b59b6"}, 0x2ecc71},
+		{"Embed section", 0, &config.Config{DefaultColor: "#9b59b6", Embed: config.EmbedDefaults{Color: "#e74c3c"}}, 0xe74c3c},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := BuildCard(Notification{Message: "msg", Color: tt.color}, tt.config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if card.Color != tt.expected {
+				t.Errorf("Expected color %d, got %d", tt.expected, card.Color)
+			}
+		})
+	}
+}
+
+func TestBuildCardHost(t *testing.T) {
+	original := hostnameFunc
+	defer func() { hostnameFunc = original }()
+	hidden := false
+
+	tests := []struct {
+		name         string
+		notification Notification
+		config       *config.Config
+		hostnameErr  error
+		expected     string
+	}{
+		{"Shown by default", Notification{}, nil, nil, "build-01"},
+		{"Hidden by flag", Notification{NoHost: true}, nil, nil, ""},
+		{"Hidden by config", Notification{}, &config.Config{HideHost: true}, nil, ""},
+		{"Hidden by embed section", Notification{}, &config.Config{Embed: config.EmbedDefaults{ShowHost: &hidden}}, nil, ""},
+		{"Hostname unavailable", Notification{}, nil, errors.New("no hostname"), "Unknown"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			hostnameFunc = func() (string, error) { return "build-01", tt.hostnameErr }
+
+			card, err := BuildCard(tt.notification, tt.config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			var host *Field
+			for i, field := range card.Fields {
+				if field.Name == "Host" {
+					host = &card.Fields[i]
+				}
+			}
+			if tt.expected == "" {
+				if host != nil {
+					t.Errorf("Expected no Host field, got %+v", host)
+				}
+				return
+			}
+			if host == nil || host.Value != tt.expected || !host.Inline {
+				t.Errorf("Expected inline Host field %q, got %+v", tt.expected, host)
+			}
+		})
+	}
+}
+
+func TestBuildCardDefaults(t *testing.T) {
+	hidden := false
+	expand := func(name, text string) (string, error) {
+		return placeholder.Expand(name, text, placeholder.Vars{Host: "build-01"})
+	}
+	tests := []struct {
+		name     string
+		n        Notification
+		config   *config.Config
+		title    string
+		footer   string
+		firstKey string // Name of the first field
+	}{
+		{"Built-in defaults", Notification{}, nil, DefaultTitle, DefaultFooterText, "Working Directory"},
+		{
+			name:     "Embed section",
+			config:   &config.Config{DefaultTitle: "Build", Embed: config.EmbedDefaults{Title: "Deploy", Footer: "ops", ShowCwd: &hidden}},
+			title:    "Deploy",
+			footer:   "ops",
+			firstKey: "Source",
+		},
+		{
+			name:     "Options override the embed section",
+			n:        Notification{Title: "Hotfix", Footer: Footer{Text: "oncall"}},
+			config:   &config.Config{Embed: config.EmbedDefaults{Title: "Deploy", Footer: "ops"}},
+			title:    "Hotfix",
+			footer:   "oncall",
+			firstKey: "Working Directory",
+		},
+		{
+			name:     "Placeholders in the config expanded",
+			n:        Notification{Expand: expand},
+			config:   &config.Config{DefaultTitle: "Backup on {{host}}", FooterText: "{{host}} cron"},
+			title:    "Backup on build-01",
+			footer:   "build-01 cron",
+			firstKey: "Working Directory",
+		},
+		{
+			name:     "Placeholders in the config as written without expansion",
+			config:   &config.Config{Embed: config.EmbedDefaults{Title: "Backup on {{host}}"}},
+			title:    "Backup on {{host}}",
+			footer:   DefaultFooterText,
+			firstKey: "Working Directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := BuildCard(tt.n, tt.config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if card.Title != tt.title || card.Footer.Text != tt.footer {
+				t.Errorf("Expected title %q and footer %q, got %q and %q", tt.title, tt.footer, card.Title, card.Footer.Text)
+			}
+			if card.Fields[0].Name != tt.firstKey {
+				t.Errorf("Expected %s first, got %+v", tt.firstKey, card.Fields)
+			}
+		})
+	}
+}
+
+func TestBuildCardTime(t *testing.T) {
+	original := nowFunc
+	defer func() { nowFunc = original }()
+	nowFunc = func() time.Time { return time.Date(2025, 6, 1, 9, 32, 0, 0, time.UTC) }
+
+	tests := []struct {
+		name     string
+		config   *config.Config
+		expected string
+	}{
+		{"Off by default", nil, ""},
+		{"Timezone and format", &config.Config{Timezone: "Asia/Tokyo", TimeFormat: "Finished at 15:04 MST"}, "Finished at 18:32 JST"},
+		{"Timezone only", &config.Config{Timezone: "America/New_York"}, "2025-06-01 05:32 EDT"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			card, err := BuildCard(Notification{NoHost: true}, tt.config)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !card.Timestamp.Equal(nowFunc()) {
+				t.Errorf("Expected the timestamp from the clock, got %v", card.Timestamp)
+			}
+
+			last := card.Fields[len(card.Fields)-1]
+			if tt.expected == "" {
+				if last.Name == "Time" {
+					t.Errorf("Expected no Time field, got %+v", last)
+				}
+				return
+			}
+			if last.Name != "Time" || last.Value != tt.expected || !last.Inline {
+				t.Errorf("Expected inline Time field %q, got %+v", tt.expected, last)
+			}
+		})
+	}
+}
+
+func TestBuildCardExpandError(t *testing.T) {
+	failed := errors.New("invalid placeholder")
+	n := Notification{Expand: func(string, string) (string, error) { return "", failed }}
+
+	// Only configured text that is used gets expanded
+	if _, err := BuildCard(n, &config.Config{FooterText: "{{nope}}"}); !errors.Is(err, failed) {
+		t.Errorf("Expected the expansion error, got %v", err)
+	}
+	n.Footer.Text = "oncall"
+	if _, err := BuildCard(n, &config.Config{FooterText: "{{nope}}"}); err != nil {
+		t.Errorf("Expected the overridden footer left alone, got %v", err)
+	}
+}
@@ -0,0 +1,57 @@
+package notifier
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/yashikota/owata/config"
+)
+
+// inlineSuffix marks a --field spec whose field should be shown inline
+const inlineSuffix = ":inline"
+
+// ParseField parses a field spec of the form Name=Value, optionally followed
+// by ":inline". Only the first '=' separates the name, so the value may
+// contain further '=' characters.
+func ParseField(spec string) (Field, error) {
+	name, value, ok := strings.Cut(spec, "=")
+	if !ok {
+		return Field{}, fmt.Errorf("invalid field %q (use Name=Value or Name=Value:inline)", spec)
+	}
+
+	value, inline := strings.CutSuffix(value, inlineSuffix)
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return Field{}, fmt.Errorf("invalid field %q: name is empty", spec)
+	}
+	if value == "" {
+		return Field{}, fmt.Errorf("invalid field %q: value is empty", spec)
+	}
+
+	return Field{Name: name, Value: value, Inline: inline}, nil
+}
+
+// ResolveFields returns the fields a notification adds after the built-in
+// ones: those of the config's embed section, less any the notification sets
+// itself, then the notification's own
+func ResolveFields(n Notification, cfg *config.Config) ([]Field, error) {
+	if cfg == nil || len(cfg.Embed.Fields) == 0 {
+		return n.Fields, nil
+	}
+	var fields []Field
+	for _, spec := range cfg.Embed.Fields {
+		field, err := ParseField(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid embed field in config: %w", err)
+		}
+		overridden := slices.ContainsFunc(n.Fields, func(f Field) bool {
+			return strings.EqualFold(f.Name, field.Name)
+		})
+		if !overridden {
+			fields = append(fields, field)
+		}
+	}
+	return append(fields, n.Fields...), nil
+}
@@ -0,0 +1,88 @@
+package notifier
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+)
+
+func TestParseField(t *testing.T) {
+	tests := []struct {
+		spec        string
+		expected    Field
+		expectedErr bool
+	}{
+		{spec: "Branch=main", expected: Field{Name: "Branch", Value: "main"}},
+		{spec: "Branch=main:inline", expected: Field{Name: "Branch", Value: "main", Inline: true}},
+		{spec: "Query=a=1&b=2", expected: Field{Name: "Query", Value: "a=1&b=2"}},
+		{spec: "Time=12:30", expected: Field{Name: "Time", Value: "12:30"}},
+		{spec: "Time=12:30:inline", expected: Field{Name: "Time", Value: "12:30", Inline: true}},
+		{spec: "Branch", expectedErr: true},
+		{spec: "=main", expectedErr: true},
+		{spec: "Branch=", expectedErr: true},
+		{spec: "Branch=:inline", expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			field, err := ParseField(tt.spec)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", field)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if field != tt.expected {
+				t.Errorf("Expected %+v, got %+v", tt.expected, field)
+			}
+		})
+	}
+}
+
+func TestResolveFields(t *testing.T) {
+	cfg := &config.Config{Embed: config.EmbedDefaults{Fields: []string{"Environment=staging", "Team=infra:inline"}}}
+	tests := []struct {
+		name        string
+		n           Notification
+		config      *config.Config
+		expected    []Field
+		expectedErr bool
+	}{
+		{name: "No config", n: Notification{Fields: []Field{{Name: "Branch", Value: "main"}}}, expected: []Field{{Name: "Branch", Value: "main"}}},
+		{
+			name:     "Configured fields first",
+			n:        Notification{Fields: []Field{{Name: "Branch", Value: "main"}}},
+			config:   cfg,
+			expected: []Field{{Name: "Environment", Value: "staging"}, {Name: "Team", Value: "infra", Inline: true}, {Name: "Branch", Value: "main"}},
+		},
+		{
+			name:     "Field of the same name overrides",
+			n:        Notification{Fields: []Field{{Name: "environment", Value: "production"}}},
+			config:   cfg,
+			expected: []Field{{Name: "Team", Value: "infra", Inline: true}, {Name: "environment", Value: "production"}},
+		},
+		{name: "Invalid field", config: &config.Config{Embed: config.EmbedDefaults{Fields: []string{"staging"}}}, expectedErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields, err := ResolveFields(tt.n, tt.config)
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", fields)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !slices.Equal(fields, tt.expected) {
+				t.Errorf("Expected %+v, got %+v", tt.expected, fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Notification is the content of a notification, whichever service it goes
+// to. Each provider renders it in its own format.
+type Notification struct {
+	Message        string
+	Title          string   // Overrides the configured title when set
+	Source         string   // What the notification is about, such as a command
+	Color          int      // The configured color, then DefaultColor, if zero
+	Fields         []Field  // Extra fields after the built-in ones
+	Attachments    []string // Paths of files to upload with the message
+	Thumbnail      string   // Thumbnail URL; overrides the configured one
+	Author         Author   // Each set part overrides the configured author
+	Footer         Footer   // Each set part overrides the configured footer; run info is appended
+	Plain          bool     // Send the message as text without a card
+	Code           bool     // Wrap the message in a code block
+	Lang           string   // Syntax highlighting language of the code block
+	Escape         bool     // Show markdown characters in the message literally
+	NoSplit        bool     // Fail instead of splitting an over-long message
+	NoHost         bool     // Leave out the host; the config's hide_host does too
+	SuppressEmbeds bool     // Stop link previews
+	Silent         bool     // Post without a push notification
+	Loud           bool     // Overrides the config silent default
+	RunID          string   // Correlates related notifications; shown in the footer
+	Seq            int      // Position within the run; 0 means no sequence number
+
+	// Expand expands placeholders in the configured title, footer and
+	// thread name when they're used; nil leaves them as written
+	Expand func(name, text string) (string, error)
+
+	// Discord holds what only Discord webhooks support; other providers
+	// reject it
+	Discord DiscordOptions
+}
+
+// Field is a name and value shown with a notification
+type Field struct {
+	Name   string `json:"name"`
+	Value  string `json:"value"`
+	Inline bool   `json:"inline"` // Shown side by side with its neighbours where supported
+}
+
+// Author names who or what a notification is from
+type Author struct {
+	Name    string `json:"name"`
+	URL     string `json:"url,omitempty"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// Footer is the small text at the bottom of a notification
+type Footer struct {
+	Text    string `json:"text"`
+	IconURL string `json:"icon_url,omitempty"`
+}
+
+// DiscordOptions are the parts of a notification only Discord webhooks
+// support
+type DiscordOptions struct {
+	Mentions   []Mention
+	Embeds     json.RawMessage // Embed JSON sent instead of the generated embed
+	ThreadID   string          // Posts into an existing thread; overrides the config thread_id
+	ThreadName string          // Creates a forum post; overrides the config thread_name_template
+	TTS        bool            // Has Discord read the message aloud
+	NoWait     bool            // Doesn't ask Discord to return the created message
+}
+
+// MentionType identifies what a mention pings
+type MentionType int
+
+const (
+	MentionUser MentionType = iota
+	MentionRole
+	MentionHere
+	MentionEveryone
+)
+
+// Mention is a single user, role or channel-wide ping
+type Mention struct {
+	Type MentionType
+	ID   string // Snowflake ID for user and role mentions
+}
+
+// Result describes a delivered notification
+type Result struct {
+	MessageID  string        `json:"message_id,omitempty"` // Empty when the service doesn't return one
+	ChannelID  string        `json:"channel_id,omitempty"`
+	StatusCode int           `json:"status_code,omitempty"` // Status of the last response
+	Attempts   int           `json:"attempts,omitempty"`    // Requests made, including retries
+	Target     int           `json:"target,omitempty"`      // Fallback chain target that delivered, counting from 1
+	Elapsed    time.Duration `json:"-"`                     // Time taken, including waits between retries
+}
+
+// MarshalJSON reports Elapsed in milliseconds, which is friendlier to scripts
+// than a count of nanoseconds
+func (r Result) MarshalJSON() ([]byte, error) {
+	type result Result
+	return json.Marshal(struct {
+		result
+		ElapsedMS int64 `json:"elapsed_ms"`
+	}{result(r), r.Elapsed.Milliseconds()})
+}
+
+// Notifier delivers notifications to one destination. Implement it to add
+// a sink of your own.
+type Notifier interface {
+	Send(ctx context.Context, n Notification) (*Result, error)
+}
+
+// Func adapts an ordinary function to a Notifier
+type Func func(ctx context.Context, n Notification) (*Result, error)
+
+// Send calls f
+func (f Func) Send(ctx context.Context, n Notification) (*Result, error) {
+	return f(ctx, n)
+}
+
+// Providers with a built-in Notifier
+const (
+	Discord  = "discord"
+	Slack    = "slack"
+	Ntfy     = "ntfy"
+	Telegram = "telegram"
+	Generic  = "generic"
+)
+
+// DiscordOnly names the first option set on the notification that only
+// Discord supports, or returns "" if there is none. Other providers reject
+// these rather than silently dropping them.
+func (n *Notification) DiscordOnly() string {
+	switch {
+	case len(n.Attachments) > 0:
+		return "file attachments"
+	case len(n.Discord.Embeds) > 0:
+		return "custom embeds"
+	case len(n.Discord.Mentions) > 0:
+		return "mentions"
+	case n.Discord.ThreadID != "" || n.Discord.ThreadName != "":
+		return "threads"
+	case n.Discord.TTS:
+		return "text-to-speech"
+	case n.Code:
+		return "code blocks"
+	}
+	return ""
+}
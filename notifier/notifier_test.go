@@ -0,0 +1,59 @@
+package notifier
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestFunc(t *testing.T) {
+	var got Notification
+	var sender Notifier = Func(func(ctx context.Context, n Notification) (*Result, error) {
+		got = n
+		return &Result{MessageID: "42"}, nil
+	})
+
+	result, err := sender.Send(context.Background(), Notification{Message: "custom sink", Color: 0xff0000})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.MessageID != "42" || got.Message != "custom sink" || got.Color != 0xff0000 {
+		t.Errorf("Unexpected result %+v for notification %+v", result, got)
+	}
+}
+
+func TestDiscordOnly(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        Notification
+		expected string
+	}{
+		{"None", Notification{Message: "msg", Plain: true, Silent: true}, ""},
+		{"Attachments", Notification{Attachments: []string{"build.log"}}, "file attachments"},
+		{"Embeds", Notification{Discord: DiscordOptions{Embeds: json.RawMessage(`{"title": "Custom"}`)}}, "custom embeds"},
+		{"Mentions", Notification{Discord: DiscordOptions{Mentions: []Mention{{Type: MentionHere}}}}, "mentions"},
+		{"Thread", Notification{Discord: DiscordOptions{ThreadName: "report"}}, "threads"},
+		{"TTS", Notification{Discord: DiscordOptions{TTS: true}}, "text-to-speech"},
+		{"Code", Notification{Code: true}, "code blocks"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.n.DiscordOnly(); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestResultJSON(t *testing.T) {
+	data, err := json.Marshal(Result{MessageID: "1", StatusCode: 200, Attempts: 2, Elapsed: 1234 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := `{"message_id":"1","status_code":200,"attempts":2,"elapsed_ms":1234}`
+	if string(data) != expected {
+		t.Errorf("Expected %s, got %s", expected, data)
+	}
+}
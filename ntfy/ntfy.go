@@ -0,0 +1,174 @@
+package ntfy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+// publicHost is the public ntfy server; self-hosted servers need the
+// provider set explicitly
+const publicHost = "ntfy.sh"
+
+// priorities maps ntfy's priority names to their numbers
+var priorities = map[string]int{
+	"min":     1,
+	"low":     2,
+	"default": 3,
+	"high":    4,
+	"max":     5,
+	"urgent":  5,
+}
+
+// IsTopicURL reports whether a URL points at the public ntfy server
+func IsTopicURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && strings.EqualFold(u.Hostname(), publicHost)
+}
+
+// ValidateTopicURL checks that a URL looks like an ntfy topic:
+// https://ntfy.sh/<topic>, or the same on a self-hosted server
+func ValidateTopicURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid topic URL: %v", err)
+	}
+
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return fmt.Errorf("invalid topic URL: scheme must be https or http, got %q", u.Scheme)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid topic URL: missing host")
+	}
+	if topic := strings.Trim(u.Path, "/"); topic == "" || strings.Contains(topic, "/") {
+		return fmt.Errorf("invalid topic URL: path must be a single topic name, got %q", u.Path)
+	}
+	return nil
+}
+
+// ParsePriority accepts an ntfy priority as a number from 1 to 5 or a name
+// such as "high"
+func ParsePriority(s string) (int, error) {
+	if p, ok := priorities[strings.ToLower(s)]; ok {
+		return p, nil
+	}
+	if p, err := strconv.Atoi(s); err == nil && p >= 1 && p <= 5 {
+		return p, nil
+	}
+	return 0, fmt.Errorf("invalid ntfy priority %q (use 1-5 or min, low, default, high, max)", s)
+}
+
+// Send publishes a notification to an ntfy topic
+func Send(ctx context.Context, topicURL string, n notifier.Notification, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	request, err := BuildRequest(n, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	body, result, err := webhook.NewClient(topicURL, nil).Post(ctx, request, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var message struct {
+		ID string `json:"id"`
+	}
+	if err := json.Unmarshal(body, &message); err == nil {
+		result.MessageID = message.ID
+	}
+	return result, nil
+}
+
+// BuildRequest renders a notification as an ntfy publish request: the
+// message and card fields form the body, and the title, priority, tags,
+// icon and access token go in headers
+func BuildRequest(n notifier.Notification, cfg *config.Config) (webhook.Request, error) {
+	if feature := n.DiscordOnly(); feature != "" {
+		return webhook.Request{}, fmt.Errorf("ntfy doesn't support %s", feature)
+	}
+
+	card, err := notifier.BuildCard(n, cfg)
+	if err != nil {
+		return webhook.Request{}, err
+	}
+
+	header := make(http.Header)
+	body := n.Message
+	if !n.Plain {
+		header.Set("X-Title", encodeHeader(card.Title))
+
+		var lines []string
+		for _, field := range card.Fields {
+			if field.Value != "" {
+				lines = append(lines, field.Name+": "+field.Value)
+			}
+		}
+		if len(lines) > 0 {
+			body += "\n\n" + strings.Join(lines, "\n")
+		}
+	}
+
+	if _, avatarURL := notifier.ResolveIdentity(cfg); avatarURL != "" {
+		header.Set("X-Icon", avatarURL)
+	}
+
+	var tags []string
+	if cfg != nil {
+		if cfg.NtfyToken != "" {
+			header.Set("Authorization", "Bearer "+cfg.NtfyToken)
+		}
+		if cfg.NtfyPriority != "" {
+			priority, err := ParsePriority(cfg.NtfyPriority)
+			if err != nil {
+				return webhook.Request{}, err
+			}
+			header.Set("X-Priority", strconv.Itoa(priority))
+		}
+		tags = append(tags, cfg.NtfyTags...)
+	}
+	if n.Silent && !n.Loud {
+		header.Set("X-Priority", strconv.Itoa(priorities["low"]))
+	}
+	for _, field := range n.Fields {
+		// A status such as the one set with compose status doubles as a tag
+		if strings.EqualFold(field.Name, "status") && field.Value != "" {
+			tags = append(tags, strings.ToLower(field.Value))
+		}
+	}
+	if len(tags) > 0 {
+		header.Set("X-Tags", encodeHeader(strings.Join(tags, ",")))
+	}
+
+	return webhook.Request{
+		Service:      "ntfy",
+		ContentType:  "text/plain; charset=utf-8",
+		Header:       header,
+		Body:         []byte(body),
+		ErrorMessage: errorMessage,
+	}, nil
+}
+
+// encodeHeader encodes non-ASCII text as ntfy expects in headers
+func encodeHeader(s string) string {
+	return mime.BEncoding.Encode("utf-8", s)
+}
+
+// errorMessage reads the error from ntfy's JSON error responses
+func errorMessage(body []byte) string {
+	var apiErr struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &apiErr); err != nil {
+		return ""
+	}
+	return apiErr.Error
+}
@@ -0,0 +1,162 @@
+package ntfy
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+func TestSend(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/backups" {
+			t.Errorf("Expected the topic path, got %q", r.URL.Path)
+		}
+		if got := r.Header.Get("X-Title"); got != "Backup" {
+			t.Errorf("Expected title header, got %q", got)
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer tk_secret" {
+			t.Errorf("Expected token header, got %q", got)
+		}
+		if got := r.Header.Get("X-Priority"); got != "4" {
+			t.Errorf("Expected priority 4, got %q", got)
+		}
+		if got := r.Header.Get("X-Tags"); got != "floppy_disk,failed" {
+			t.Errorf("Expected tags, got %q", got)
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		if !strings.HasPrefix(string(body), "Nightly backup failed\n\n") || !strings.Contains(string(body), "Source: cron") {
+			t.Errorf("Unexpected body %q", body)
+		}
+		io.WriteString(w, `{"id":"hwQ2YpKdmg6p","topic":"backups"}`)
+	}))
+	defer server.Close()
+
+	cfg := &config.Config{NtfyToken: "tk_secret", NtfyPriority: "high", NtfyTags: []string{"floppy_disk"}}
+	n := notifier.Notification{
+		Message: "Nightly backup failed",
+		Source:  "cron",
+		Title:   "Backup",
+		Fields:  []notifier.Field{{Name: "Status", Value: "Failed"}},
+	}
+
+	result, err := Send(context.Background(), server.URL+"/backups", n, cfg, webhook.DefaultSendOptions())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.MessageID != "hwQ2YpKdmg6p" {
+		t.Errorf("Expected the message ID, got %q", result.MessageID)
+	}
+}
+
+func TestSendError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"code":40301,"http":403,"error":"forbidden","link":"https://ntfy.sh/docs/publish/#authentication"}`)
+	}))
+	defer server.Close()
+
+	_, err := Send(context.Background(), server.URL+"/private", notifier.Notification{Message: "msg"}, nil, webhook.DefaultSendOptions())
+	expected := "ntfy webhook returned status 403: forbidden"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
+
+func TestBuildRequest(t *testing.T) {
+	t.Run("Plain", func(t *testing.T) {
+		request, err := BuildRequest(notifier.Notification{Message: "done", Source: "CI", Plain: true}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if string(request.Body) != "done" || request.Header.Get("X-Title") != "" {
+			t.Errorf("Expected only the message, got %q with title %q", request.Body, request.Header.Get("X-Title"))
+		}
+	})
+
+	t.Run("Non-ASCII title", func(t *testing.T) {
+		request, err := BuildRequest(notifier.Notification{Message: "msg", Title: "完了"}, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := request.Header.Get("X-Title"); got != "=?utf-8?b?5a6M5LqG?=" {
+			t.Errorf("Expected an encoded title, got %q", got)
+		}
+	})
+
+	t.Run("Silent", func(t *testing.T) {
+		request, err := BuildRequest(notifier.Notification{Message: "heartbeat", Silent: true}, &config.Config{NtfyPriority: "high"})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if got := request.Header.Get("X-Priority"); got != "2" {
+			t.Errorf("Expected low priority, got %q", got)
+		}
+	})
+
+	t.Run("Invalid priority", func(t *testing.T) {
+		if _, err := BuildRequest(notifier.Notification{Message: "msg"}, &config.Config{NtfyPriority: "loud"}); err == nil {
+			t.Error("Expected error for an invalid priority")
+		}
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		_, err := BuildRequest(notifier.Notification{Message: "msg", Attachments: []string{"build.log"}}, nil)
+		if err == nil || !strings.Contains(err.Error(), "file attachments") {
+			t.Errorf("Expected an unsupported feature error, got %v", err)
+		}
+	})
+}
+
+func TestValidateTopicURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr string
+	}{
+		{"Public", "https://ntfy.sh/my-topic", ""},
+		{"Self-hosted", "http://ntfy.lan:8080/alerts", ""},
+		{"FTP", "ftp://ntfy.sh/topic", "scheme must be https or http"},
+		{"Missing topic", "https://ntfy.sh/", "single topic name"},
+		{"Nested path", "https://ntfy.sh/a/b", "single topic name"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateTopicURL(tt.url)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+
+	if !IsTopicURL("https://ntfy.sh/my-topic") || IsTopicURL("https://ntfy.lan/alerts") {
+		t.Error("IsTopicURL misidentified a topic")
+	}
+}
+
+func TestParsePriority(t *testing.T) {
+	for input, expected := range map[string]int{"1": 1, "5": 5, "High": 4, "urgent": 5} {
+		if got, err := ParsePriority(input); err != nil || got != expected {
+			t.Errorf("ParsePriority(%q) = %d, %v; expected %d", input, got, err, expected)
+		}
+	}
+	for _, input := range []string{"0", "6", "loud", ""} {
+		if _, err := ParsePriority(input); err == nil {
+			t.Errorf("Expected error for %q", input)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package placeholder
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+	"time"
+)
+
+// Vars are the values placeholders expand to
+type Vars struct {
+	Cwd        string
+	Host       string
+	User       string
+	Source     string
+	Now        time.Time // Already in the configured timezone
+	TimeFormat string    // Layout for {{datetime}}
+	LookupEnv  func(string) (string, bool)
+}
+
+// Expand renders text as a text/template whose functions are the
+// placeholders: {{cwd}}, {{host}}, {{user}}, {{source}}, {{date}},
+// {{time}}, {{datetime}} and {{env "NAME"}}. name says which part of the
+// notification text is, for errors. Text without "{{" is returned as is.
+// Unknown placeholders and unset environment variables are errors rather
+// than passed through, so a typo doesn't go out in a notification.
+func Expand(name, text string, vars Vars) (string, error) {
+	if !strings.Contains(text, "{{") {
+		return text, nil
+	}
+
+	tmpl, err := template.New(name).Funcs(vars.funcs()).Parse(text)
+	if err != nil {
+		return "", fmt.Errorf("invalid placeholder in the %s: %v", name, err)
+	}
+	var b strings.Builder
+	if err := tmpl.Execute(&b, struct{}{}); err != nil {
+		return "", fmt.Errorf("invalid placeholder in the %s: %v", name, err)
+	}
+	return b.String(), nil
+}
+
+func (v Vars) funcs() template.FuncMap {
+	return template.FuncMap{
+		"cwd":      func() string { return v.Cwd },
+		"host":     func() string { return v.Host },
+		"user":     func() string { return v.User },
+		"source":   func() string { return v.Source },
+		"date":     func() string { return v.Now.Format(time.DateOnly) },
+		"time":     func() string { return v.Now.Format(time.TimeOnly) },
+		"datetime": func() string { return v.Now.Format(v.TimeFormat) },
+		"env": func(key string) (string, error) {
+			value, ok := v.LookupEnv(key)
+			if !ok {
+				return "", fmt.Errorf("environment variable %s is not set", key)
+			}
+			return value, nil
+		},
+	}
+}
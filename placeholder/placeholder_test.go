@@ -0,0 +1,61 @@
+package placeholder
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	vars := Vars{
+		Cwd:        "/srv/app",
+		Host:       "build-01",
+		User:       "deploy",
+		Source:     "CI",
+		Now:        time.Date(2025, 7, 3, 9, 5, 30, 0, time.UTC),
+		TimeFormat: "Jan 2 15:04",
+		LookupEnv: func(key string) (string, bool) {
+			if key == "BRANCH" {
+				return "main", true
+			}
+			return "", false
+		},
+	}
+
+	tests := []struct {
+		name        string
+		text        string
+		expected    string
+		expectedErr string
+	}{
+		{name: "No placeholders", text: "Backup finished", expected: "Backup finished"},
+		{name: "Lone braces", text: "map[string]struct{}", expected: "map[string]struct{}"},
+		{name: "Host and date", text: "backup finished on {{host}} at {{date}}", expected: "backup finished on build-01 at 2025-07-03"},
+		{name: "All variables", text: "{{user}}@{{host}}:{{cwd}} from {{source}}", expected: "deploy@build-01:/srv/app from CI"},
+		{name: "Time", text: "{{time}} / {{datetime}}", expected: "09:05:30 / Jul 3 09:05"},
+		{name: "Environment", text: `deployed {{env "BRANCH"}}`, expected: "deployed main"},
+		{name: "Spaces inside braces", text: "{{ host }}", expected: "build-01"},
+		{name: "Unknown placeholder", text: "on {{hots}}", expectedErr: `function "hots" not defined`},
+		{name: "Field access", text: "{{.Host}}", expectedErr: "can't evaluate field Host"},
+		{name: "Unset environment variable", text: `{{env "MISSING"}}`, expectedErr: "environment variable MISSING is not set"},
+		{name: "Unclosed", text: "{{host", expectedErr: "invalid placeholder in the message"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand("message", tt.text, vars)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Fatalf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,80 @@
+package redact
+
+import (
+	"maps"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// InvalidURL is what URL returns for anything that isn't a URL
+const InvalidURL = "(invalid URL)"
+
+// safeQueryKeys are query parameters shown unredacted
+var safeQueryKeys = map[string]bool{"wait": true, "thread_id": true}
+
+// URL hides the secret parts of a webhook URL so it can be shown or logged:
+// the token of a Discord webhook, keeping its ID to tell webhooks apart, the
+// bot token of a Telegram API URL, or otherwise the last path segment, which
+// is the secret of Slack webhooks and ntfy topics. Credentials are dropped
+// and query values other than wait and thread_id are hidden. Anything that
+// doesn't parse as a URL is hidden whole.
+func URL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil || u.Host == "" {
+		return InvalidURL
+	}
+	u.User = nil
+
+	segments := strings.Split(u.EscapedPath(), "/")
+	redacted := false
+	for i, segment := range segments {
+		if segment == "webhooks" && i+2 < len(segments) && isSnowflake(segments[i+1]) {
+			segments[i+2] = "****"
+			redacted = true
+			break
+		}
+		if strings.HasPrefix(segment, "bot") && strings.Contains(segment, ":") {
+			segments[i] = "bot****"
+			redacted = true
+			break
+		}
+	}
+	for i := len(segments) - 1; i >= 0 && !redacted; i-- {
+		if segments[i] != "" {
+			segments[i] = "****"
+			redacted = true
+		}
+	}
+	u.RawPath = strings.Join(segments, "/")
+	u.Path, _ = url.PathUnescape(u.RawPath)
+
+	// url.Values.Encode would escape the asterisks
+	var params []string
+	query := u.Query()
+	for _, key := range slices.Sorted(maps.Keys(query)) {
+		for _, value := range query[key] {
+			value = url.QueryEscape(value)
+			if !safeQueryKeys[key] {
+				value = "****"
+			}
+			params = append(params, url.QueryEscape(key)+"="+value)
+		}
+	}
+	u.RawQuery = strings.Join(params, "&")
+
+	return u.String()
+}
+
+// isSnowflake reports whether id looks like a Discord ID
+func isSnowflake(id string) bool {
+	if id == "" || len(id) > 20 {
+		return false
+	}
+	for _, r := range id {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,25 @@
+//go:build !unix
+
+package run
+
+import "os"
+
+// relayedSignals are passed on to the command while it runs. There are none
+// here: Ctrl-C reaches every process attached to the console on its own.
+var relayedSignals []os.Signal
+
+// terminate stops the process. Without SIGTERM there's no gentler way.
+func terminate(p *os.Process) error {
+	return p.Kill()
+}
+
+// exitStatus reads the exit code from state. Signals are a unix concept, so
+// the signal name is always empty here.
+func exitStatus(state *os.ProcessState) (int, string) {
+	return state.ExitCode(), ""
+}
+
+// usage is nil here, as there's no rusage to read peak memory from
+func usage(state *os.ProcessState) *Usage {
+	return nil
+}
@@ -0,0 +1,86 @@
+//go:build unix
+
+package run
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"syscall"
+	"time"
+)
+
+// signalNames spells out the signals a command is commonly killed by
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:  "SIGHUP",
+	syscall.SIGINT:  "SIGINT",
+	syscall.SIGQUIT: "SIGQUIT",
+	syscall.SIGILL:  "SIGILL",
+	syscall.SIGABRT: "SIGABRT",
+	syscall.SIGBUS:  "SIGBUS",
+	syscall.SIGFPE:  "SIGFPE",
+	syscall.SIGKILL: "SIGKILL",
+	syscall.SIGSEGV: "SIGSEGV",
+	syscall.SIGPIPE: "SIGPIPE",
+	syscall.SIGALRM: "SIGALRM",
+	syscall.SIGTERM: "SIGTERM",
+	syscall.SIGUSR1: "SIGUSR1",
+	syscall.SIGUSR2: "SIGUSR2",
+}
+
+// relayedSignals are passed on to the command while it runs. Ctrl-C isn't
+// among them: the terminal already sends SIGINT to the whole foreground
+// process group, command included, and a second copy makes many tools skip
+// their clean shutdown.
+var relayedSignals = []os.Signal{syscall.SIGTERM, syscall.SIGHUP}
+
+// terminate asks the process to exit
+func terminate(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
+
+// exitStatus reads the exit code from state, along with the name of the
+// signal that killed the process, if any
+func exitStatus(state *os.ProcessState) (int, string) {
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok {
+		return waitStatus(ws)
+	}
+	return state.ExitCode(), ""
+}
+
+// waitStatus decodes ws. A process killed by a signal reports 128 plus the
+// signal number, as shells do.
+func waitStatus(ws syscall.WaitStatus) (int, string) {
+	if !ws.Signaled() {
+		return ws.ExitStatus(), ""
+	}
+	sig := ws.Signal()
+	name, ok := signalNames[sig]
+	if !ok {
+		name = fmt.Sprintf("signal %d", int(sig))
+	}
+	return 128 + int(sig), name
+}
+
+// usage reads the CPU time and peak memory of the process from its rusage
+func usage(state *os.ProcessState) *Usage {
+	ru, ok := state.SysUsage().(*syscall.Rusage)
+	if !ok {
+		return nil
+	}
+	return rusageUsage(ru, runtime.GOOS)
+}
+
+// rusageUsage converts ru as reported on goos. Max RSS comes in bytes on
+// darwin and in kilobytes everywhere else.
+func rusageUsage(ru *syscall.Rusage, goos string) *Usage {
+	maxRSS := int64(ru.Maxrss)
+	if goos != "darwin" && goos != "ios" {
+		maxRSS *= 1024
+	}
+	return &Usage{
+		UserTime:   time.Duration(ru.Utime.Nano()),
+		SystemTime: time.Duration(ru.Stime.Nano()),
+		MaxRSS:     maxRSS,
+	}
+}
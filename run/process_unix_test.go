@@ -0,0 +1,107 @@
+//go:build unix
+
+package run
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWaitStatus(t *testing.T) {
+	tests := []struct {
+		name           string
+		status         syscall.WaitStatus
+		expectedCode   int
+		expectedSignal string
+	}{
+		{"Exited 0", 0, 0, ""},
+		{"Exited 3", 3 << 8, 3, ""},
+		{"Killed", syscall.WaitStatus(syscall.SIGKILL), 137, "SIGKILL"},
+		{"Terminated", syscall.WaitStatus(syscall.SIGTERM), 143, "SIGTERM"},
+		{"Unnamed signal", syscall.WaitStatus(syscall.SIGWINCH), 128 + int(syscall.SIGWINCH), "signal " + strconv.Itoa(int(syscall.SIGWINCH))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, signal := waitStatus(tt.status)
+			if code != tt.expectedCode || signal != tt.expectedSignal {
+				t.Errorf("Expected (%d, %q), got (%d, %q)", tt.expectedCode, tt.expectedSignal, code, signal)
+			}
+		})
+	}
+}
+
+func TestRelaySignals(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	// Sent to owata itself once the command is running; Exec has to pass it on
+	go func() {
+		time.Sleep(300 * time.Millisecond)
+		_ = syscall.Kill(os.Getpid(), syscall.SIGTERM)
+	}()
+
+	result, err := Exec([]string{"sh", "-c", "trap 'exit 7' TERM; while :; do sleep 0.05; done"}, Options{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.ExitCode != 7 {
+		t.Errorf("Expected the command to exit through its TERM trap, got %+v", result)
+	}
+}
+
+func TestRusageUsage(t *testing.T) {
+	ru := &syscall.Rusage{
+		Utime:  syscall.NsecToTimeval(int64(12*time.Second + 300*time.Millisecond)),
+		Stime:  syscall.NsecToTimeval(int64(1500 * time.Millisecond)),
+		Maxrss: 2048,
+	}
+
+	tests := []struct {
+		goos           string
+		expectedMaxRSS int64
+	}{
+		{"linux", 2048 * 1024},
+		{"freebsd", 2048 * 1024},
+		{"darwin", 2048},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			u := rusageUsage(ru, tt.goos)
+			if u.UserTime != 12*time.Second+300*time.Millisecond || u.SystemTime != 1500*time.Millisecond {
+				t.Errorf("Unexpected CPU times: %+v", u)
+			}
+			if u.MaxRSS != tt.expectedMaxRSS {
+				t.Errorf("Expected max RSS %d, got %d", tt.expectedMaxRSS, u.MaxRSS)
+			}
+		})
+	}
+}
+
+func TestExecStats(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	result, err := Exec([]string{"sh", "-c", "exit 0"}, Options{Stats: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Usage == nil || result.Usage.MaxRSS <= 0 {
+		t.Errorf("Expected usage with a peak memory, got %+v", result.Usage)
+	}
+
+	result, err = Exec([]string{"sh", "-c", "exit 0"}, Options{})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Usage != nil {
+		t.Errorf("Expected no usage without Stats, got %+v", result.Usage)
+	}
+}
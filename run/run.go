@@ -0,0 +1,394 @@
+package run
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/discord"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+// Embed colors for finished commands
+const (
+	ColorSuccess  = 0x2ecc71 // Green
+	ColorFailure  = 0xe74c3c // Red
+	ColorSignaled = 0xe67e22 // Orange
+	ColorTimedOut = 0xf1c40f // Yellow
+)
+
+// ExitCodeTimeout is the exit code of a command stopped by Options.Timeout,
+// the same as GNU timeout's
+const ExitCodeTimeout = 124
+
+// killGracePeriod is how long a timed out command gets to exit after SIGTERM
+// before it is killed. A variable for testing purposes.
+var killGracePeriod = 10 * time.Second
+
+// maxTitleCommandLength keeps long command lines from crowding the title
+const maxTitleCommandLength = 100
+
+// Result describes a finished command
+type Result struct {
+	Command  []string
+	Script   string // The shell command line Command runs, when given as one
+	ExitCode int    // 128 plus the signal number when killed by a signal
+	Signal   string // Name of the signal that killed the command, such as "SIGKILL"
+	Duration time.Duration
+	Timeout  time.Duration // The limit the command ran into; zero unless it timed out
+	Output   []string      // Last lines of output, when captured with a Tail
+	Usage    *Usage        // Resources used, when Options.Stats asked for them
+}
+
+// Usage is the CPU time and memory a command used
+type Usage struct {
+	UserTime   time.Duration
+	SystemTime time.Duration
+	MaxRSS     int64 // Peak resident set size in bytes
+}
+
+// Fields renders the usage as inline embed fields
+func (u *Usage) Fields() []notifier.Field {
+	return []notifier.Field{
+		{Name: "CPU Time", Value: fmt.Sprintf("%s user, %s system", FormatDuration(u.UserTime), FormatDuration(u.SystemTime)), Inline: true},
+		{Name: "Peak Memory", Value: webhook.FormatBytes(u.MaxRSS), Inline: true},
+	}
+}
+
+// Options configures how Exec runs a command
+type Options struct {
+	Stdin   io.Reader
+	Stdout  io.Writer
+	Stderr  io.Writer
+	Timeout time.Duration // Stop the command after this long; zero means no limit
+	Stats   bool          // Collect CPU time and peak memory where the platform reports them
+	Env     []string      // Added to owata's own environment, as "KEY=value"
+}
+
+// CommandLine renders the command as the user gave it: the shell command line
+// if there is one, otherwise the quoted argv
+func (r *Result) CommandLine() string {
+	if r.Script != "" {
+		return r.Script
+	}
+	return ShellQuote(r.Command)
+}
+
+// Succeeded reports whether the command exited with code 0
+func (r *Result) Succeeded() bool {
+	return r.ExitCode == 0
+}
+
+// Exec runs argv and waits for it to exit. A command that runs and fails is
+// not an error; its exit code is in the result. The error is only set when
+// the command couldn't be started at all.
+//
+// A command still running after opts.Timeout gets SIGTERM, then SIGKILL if it
+// hasn't exited killGracePeriod later, and its exit code is ExitCodeTimeout.
+// While it runs, signals asking owata to stop are relayed to it.
+func Exec(argv []string, opts Options) (*Result, error) {
+	if len(argv) == 0 {
+		return nil, fmt.Errorf("no command to run")
+	}
+
+	ctx := context.Background()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, argv[0], argv[1:]...)
+	cmd.Stdin = opts.Stdin
+	cmd.Stdout = opts.Stdout
+	cmd.Stderr = opts.Stderr
+	if len(opts.Env) > 0 {
+		cmd.Env = append(os.Environ(), opts.Env...)
+	}
+	cmd.Cancel = func() error { return terminate(cmd.Process) }
+	cmd.WaitDelay = killGracePeriod
+
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to run %s: %v", argv[0], err)
+	}
+	stopRelay := relaySignals(cmd.Process)
+	err := cmd.Wait()
+	stopRelay()
+	result := &Result{Command: argv, Duration: time.Since(start)}
+	if opts.Stats && cmd.ProcessState != nil {
+		result.Usage = usage(cmd.ProcessState)
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case err == nil:
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		result.ExitCode, result.Timeout = ExitCodeTimeout, opts.Timeout
+	case errors.As(err, &exitErr):
+		result.ExitCode, result.Signal = exitStatus(exitErr.ProcessState)
+	default:
+		return nil, fmt.Errorf("failed to wait for %s: %v", argv[0], err)
+	}
+
+	return result, nil
+}
+
+// relaySignals passes relayedSignals sent to owata on to p until the returned
+// function is called
+func relaySignals(p *os.Process) (stop func()) {
+	if len(relayedSignals) == 0 {
+		return func() {}
+	}
+
+	signals := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(signals, relayedSignals...)
+	go func() {
+		for {
+			select {
+			case sig := <-signals:
+				_ = p.Signal(sig)
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// Filter decides which results are worth a notification
+type Filter struct {
+	NotifyOn       string        // One of the config.NotifyAlways family; empty means always
+	MinDuration    time.Duration // Skip successes faster than this
+	MinDurationAll bool          // Skip fast failures too
+}
+
+// ShouldNotify reports whether r passes the filter
+func (f Filter) ShouldNotify(r *Result) bool {
+	switch f.NotifyOn {
+	case config.NotifyOnFailure:
+		if r.Succeeded() {
+			return false
+		}
+	case config.NotifyOnSuccess:
+		if !r.Succeeded() {
+			return false
+		}
+	}
+
+	if r.Duration < f.MinDuration && (r.Succeeded() || f.MinDurationAll) {
+		return false
+	}
+	return true
+}
+
+// FormatDuration renders a duration at a precision that suits its length,
+// such as "350ms", "4.2s" or "1h4m12s"
+func FormatDuration(d time.Duration) string {
+	switch {
+	case d < time.Second:
+		return d.Round(time.Millisecond).String()
+	case d < time.Minute:
+		return d.Round(100 * time.Millisecond).String()
+	default:
+		return d.Round(time.Second).String()
+	}
+}
+
+// Shell returns the argv that runs script through the system shell, so that
+// pipes, && and the like work: sh -c, or cmd.exe /C on Windows
+func Shell(script string) []string {
+	return shellArgv(script, runtime.GOOS)
+}
+
+func shellArgv(script, goos string) []string {
+	if goos == "windows" {
+		return []string{"cmd.exe", "/C", script}
+	}
+	return []string{"sh", "-c", script}
+}
+
+// ShellQuote joins argv into a command line that a POSIX shell would split
+// back into the same arguments, quoting only where needed
+func ShellQuote(argv []string) string {
+	quoted := make([]string, len(argv))
+	for i, arg := range argv {
+		quoted[i] = shellQuoteArg(arg)
+	}
+	return strings.Join(quoted, " ")
+}
+
+// shellQuoteArg single-quotes arg unless it is made only of characters that
+// are safe unquoted
+func shellQuoteArg(arg string) string {
+	if arg == "" {
+		return "''"
+	}
+	safe := strings.IndexFunc(arg, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("@%+=:,./_-", r))
+	}) < 0
+	if safe {
+		return arg
+	}
+	return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+}
+
+// shortDuration renders a whole duration without trailing zero units, such
+// as "2h" rather than "2h0m0s"
+func shortDuration(d time.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = strings.TrimSuffix(s, "0s")
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = strings.TrimSuffix(s, "0m")
+	}
+	return s
+}
+
+// TitleCommand fits a command line into a title, shortening long ones
+func TitleCommand(command string) string {
+	if runes := []rune(command); len(runes) > maxTitleCommandLength {
+		command = string(runes[:maxTitleCommandLength-1]) + "…"
+	}
+	return command
+}
+
+// StartNotification fills in n to announce that the command line is
+// starting. A title or message already set on n is kept.
+func StartNotification(n notifier.Notification, command string) notifier.Notification {
+	if n.Title == "" {
+		n.Title = "▶️ Started: " + TitleCommand(command)
+	}
+	if n.Message == "" {
+		n.Message = "Running; another notification follows when it exits"
+	}
+	return n
+}
+
+// Status picks the embed color and title prefix for a result: green for
+// success, red for a nonzero exit, orange for death by a signal and yellow
+// for a timeout
+func Status(r *Result) (color int, title string) {
+	switch {
+	case r.Timeout > 0:
+		return ColorTimedOut, "⏰ Timed out after " + shortDuration(r.Timeout)
+	case r.Signal != "":
+		return ColorSignaled, "⚠️ Killed by " + r.Signal
+	case r.Succeeded():
+		return ColorSuccess, "✅ Success"
+	default:
+		return ColorFailure, "❌ Failed"
+	}
+}
+
+// exitCodeValue renders the exit code, naming the signal behind it if any
+func exitCodeValue(r *Result) string {
+	if r.Timeout > 0 {
+		return fmt.Sprintf("%d (timed out)", r.ExitCode)
+	}
+	if r.Signal != "" {
+		return fmt.Sprintf("%d (%s)", r.ExitCode, r.Signal)
+	}
+	return strconv.Itoa(r.ExitCode)
+}
+
+// CommandField shows a full command line as inline code in a field
+func CommandField(command string) notifier.Field {
+	if runes := []rune(command); len(runes) > discord.MaxFieldValueLength-2 {
+		command = string(runes[:discord.MaxFieldValueLength-3]) + "…"
+	}
+	if !strings.Contains(command, "`") {
+		command = "`" + command + "`"
+	}
+	return notifier.Field{Name: "Command", Value: command}
+}
+
+// Notification fills in n to report the result: the title and color follow
+// Status, and fields carry the exit code, duration, any usage and the full
+// command line. A title or message already set on n is kept.
+func Notification(n notifier.Notification, r *Result) notifier.Notification {
+	color, status := Status(r)
+	n.Color = color
+	if n.Title == "" {
+		n.Title = status + ": " + TitleCommand(r.CommandLine())
+	}
+
+	if n.Message == "" {
+		switch {
+		case r.Timeout > 0:
+			n.Message = fmt.Sprintf("Stopped after running for %s", FormatDuration(r.Duration))
+		case r.Signal != "":
+			n.Message = fmt.Sprintf("Killed by %s after %s", r.Signal, FormatDuration(r.Duration))
+		default:
+			n.Message = fmt.Sprintf("Exited with code %d after %s", r.ExitCode, FormatDuration(r.Duration))
+		}
+	}
+	if len(r.Output) > 0 {
+		limit := discord.MaxDescriptionLength
+		if n.Plain {
+			limit = discord.MaxContentLength
+		}
+		limit -= utf8.RuneCountInString(n.Message) + len("\n\n")
+		if block := outputBlock(r.Output, limit); block != "" {
+			n.Message += "\n\n" + block
+		}
+	}
+	n.Fields = append(n.Fields,
+		notifier.Field{Name: "Exit Code", Value: exitCodeValue(r), Inline: true},
+		notifier.Field{Name: "Duration", Value: FormatDuration(r.Duration), Inline: true},
+	)
+	if r.Usage != nil {
+		n.Fields = append(n.Fields, r.Usage.Fields()...)
+	}
+	n.Fields = append(n.Fields, CommandField(r.CommandLine()))
+
+	return n
+}
+
+// truncatedMarker heads output that had to be cut to fit
+const truncatedMarker = "(truncated)\n"
+
+// outputBlock renders output lines as a code block of at most limit
+// characters. When they don't fit, the oldest lines are dropped, and failing
+// that the start of the last line, and the block is marked "(truncated)".
+func outputBlock(lines []string, limit int) string {
+	for start := range lines {
+		text := strings.Join(lines[start:], "\n")
+		if start > 0 {
+			text = truncatedMarker + text
+		}
+		if block := discord.CodeBlock(text, ""); utf8.RuneCountInString(block) <= limit {
+			return block
+		}
+	}
+
+	last := []rune(lines[len(lines)-1])
+	budget := limit - utf8.RuneCountInString(discord.CodeBlock(truncatedMarker, ""))
+	for budget > 0 {
+		block := discord.CodeBlock(truncatedMarker+string(last[max(len(last)-budget, 0):]), "")
+		overflow := utf8.RuneCountInString(block) - limit
+		if overflow <= 0 {
+			return block
+		}
+		// Escaped backticks took up the room; try again with less
+		budget -= overflow
+	}
+	return ""
+}
@@ -0,0 +1,457 @@
+package run
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/discord"
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestExec(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	tests := []struct {
+		name           string
+		argv           []string
+		stdin          string
+		expectedCode   int
+		expectedStdout string
+		expectedStderr string
+		expectedErr    bool
+	}{
+		{
+			name:           "Success",
+			argv:           []string{"sh", "-c", "echo out; echo err >&2"},
+			expectedStdout: "out\n",
+			expectedStderr: "err\n",
+		},
+		{
+			name:         "Failure",
+			argv:         []string{"sh", "-c", "exit 3"},
+			expectedCode: 3,
+		},
+		{
+			name:           "Stdin passed through",
+			argv:           []string{"sh", "-c", "cat"},
+			stdin:          "piped",
+			expectedStdout: "piped",
+		},
+		{
+			name:        "Command not found",
+			argv:        []string{"owata-no-such-command"},
+			expectedErr: true,
+		},
+		{
+			name:        "No command",
+			expectedErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			result, err := Exec(tt.argv, Options{Stdin: strings.NewReader(tt.stdin), Stdout: &stdout, Stderr: &stderr})
+			if tt.expectedErr {
+				if err == nil {
+					t.Errorf("Expected error, got %+v", result)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.ExitCode != tt.expectedCode {
+				t.Errorf("Expected exit code %d, got %d", tt.expectedCode, result.ExitCode)
+			}
+			if result.Succeeded() != (tt.expectedCode == 0) {
+				t.Errorf("Unexpected Succeeded() for exit code %d", result.ExitCode)
+			}
+			if stdout.String() != tt.expectedStdout || stderr.String() != tt.expectedStderr {
+				t.Errorf("Unexpected output: stdout %q, stderr %q", stdout.String(), stderr.String())
+			}
+		})
+	}
+}
+
+func TestExecEnv(t *testing.T) {
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+	t.Setenv("OWATA_TEST_INHERITED", "kept")
+
+	var stdout bytes.Buffer
+	_, err := Exec([]string{"sh", "-c", "echo $OWATA_TEST_INHERITED $OWATA_RUN_ID"}, Options{Stdout: &stdout, Env: []string{"OWATA_RUN_ID=7f2c"}})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stdout.String() != "kept 7f2c\n" {
+		t.Errorf("Expected the environment passed on with the added variable, got %q", stdout.String())
+	}
+}
+
+func TestExecSignal(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are unix only")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	result, err := Exec([]string{"sh", "-c", "kill -TERM $$"}, Options{Stdout: io.Discard, Stderr: io.Discard})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.ExitCode != 143 || result.Signal != "SIGTERM" {
+		t.Errorf("Expected exit code 143 from SIGTERM, got %d %q", result.ExitCode, result.Signal)
+	}
+}
+
+func TestExecTimeout(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("signals are unix only")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	original := killGracePeriod
+	killGracePeriod = 200 * time.Millisecond
+	defer func() { killGracePeriod = original }()
+
+	tests := []struct {
+		name string
+		argv []string
+	}{
+		{"Stops on SIGTERM", []string{"sh", "-c", "exec sleep 10"}},
+		{"Killed after the grace period", []string{"sh", "-c", "trap '' TERM; exec sleep 10"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := Exec(tt.argv, Options{Timeout: 100 * time.Millisecond})
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if result.ExitCode != ExitCodeTimeout || result.Timeout != 100*time.Millisecond {
+				t.Errorf("Expected a timeout with exit code %d, got %+v", ExitCodeTimeout, result)
+			}
+			if result.Duration > 5*time.Second {
+				t.Errorf("Expected the command to be stopped promptly, took %s", result.Duration)
+			}
+		})
+	}
+
+	t.Run("Finishing in time", func(t *testing.T) {
+		result, err := Exec([]string{"sh", "-c", "exit 0"}, Options{Timeout: time.Minute})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.ExitCode != 0 || result.Timeout != 0 {
+			t.Errorf("Expected a plain success, got %+v", result)
+		}
+	})
+}
+
+func TestShell(t *testing.T) {
+	tests := []struct {
+		goos     string
+		expected []string
+	}{
+		{"linux", []string{"sh", "-c", "make && make test"}},
+		{"darwin", []string{"sh", "-c", "make && make test"}},
+		{"windows", []string{"cmd.exe", "/C", "make && make test"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.goos, func(t *testing.T) {
+			if got := shellArgv("make && make test", tt.goos); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestExecShell(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the script below is for sh")
+	}
+	if _, err := exec.LookPath("sh"); err != nil {
+		t.Skip("sh is not available")
+	}
+
+	var stdout bytes.Buffer
+	result, err := Exec(Shell("echo one | tr a-z A-Z && false || exit 4"), Options{Stdout: &stdout})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if stdout.String() != "ONE\n" || result.ExitCode != 4 {
+		t.Errorf("Expected the pipeline and conditionals to run in the shell, got %q and exit code %d", stdout.String(), result.ExitCode)
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+	tests := []struct {
+		argv     []string
+		expected string
+	}{
+		{[]string{"make", "-j8", "test"}, "make -j8 test"},
+		{[]string{"git", "commit", "-m", "fix bug"}, "git commit -m 'fix bug'"},
+		{[]string{"echo", "it's"}, `echo 'it'\''s'`},
+		{[]string{"echo", ""}, "echo ''"},
+		{[]string{"sh", "-c", "echo $HOME; ls *"}, "sh -c 'echo $HOME; ls *'"},
+		{[]string{"curl", "https://example.com/a?b=c&d=e"}, "curl 'https://example.com/a?b=c&d=e'"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := ShellQuote(tt.argv); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNotificationFields(t *testing.T) {
+	n := Notification(notifier.Notification{}, &Result{Command: []string{"git", "commit", "-m", "fix bug"}, ExitCode: 137, Signal: "SIGKILL"})
+	if n.Fields[0].Value != "137 (SIGKILL)" {
+		t.Errorf("Expected the signal named in the exit code, got %q", n.Fields[0].Value)
+	}
+	if n.Fields[2].Value != "`git commit -m 'fix bug'`" || n.Fields[2].Inline {
+		t.Errorf("Unexpected Command field: %+v", n.Fields[2])
+	}
+
+	n = Notification(notifier.Notification{}, &Result{Command: []string{"make"}, Usage: &Usage{UserTime: 12 * time.Second, SystemTime: 1500 * time.Millisecond, MaxRSS: 512 << 20}})
+	var names []string
+	for _, field := range n.Fields {
+		names = append(names, field.Name)
+	}
+	if expected := []string{"Exit Code", "Duration", "CPU Time", "Peak Memory", "Command"}; !slices.Equal(names, expected) {
+		t.Errorf("Expected fields %q, got %q", expected, names)
+	}
+	if n.Fields[2].Value != "12s user, 1.5s system" || n.Fields[3].Value != "512.0 MiB" {
+		t.Errorf("Unexpected usage fields: %+v", n.Fields[2:4])
+	}
+
+	n = Notification(notifier.Notification{}, &Result{Command: Shell("make && make test"), Script: "make && make test"})
+	if n.Title != "✅ Success: make && make test" || n.Fields[2].Value != "`make && make test`" {
+		t.Errorf("Expected the shell command line as given, got %q and %+v", n.Title, n.Fields[2])
+	}
+
+	n = Notification(notifier.Notification{}, &Result{Command: []string{"echo", strings.Repeat("z", 2000)}})
+	if got := utf8.RuneCountInString(n.Fields[2].Value); got > discord.MaxFieldValueLength {
+		t.Errorf("Expected the Command field to fit the field limit, got %d characters", got)
+	}
+}
+
+func TestShortDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{2 * time.Hour, "2h"},
+		{90 * time.Minute, "1h30m"},
+		{time.Hour + time.Second, "1h0m1s"},
+		{5 * time.Minute, "5m"},
+		{90 * time.Second, "1m30s"},
+		{100 * time.Millisecond, "100ms"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := shortDuration(tt.duration); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+	tests := []struct {
+		duration time.Duration
+		expected string
+	}{
+		{350*time.Millisecond + 400*time.Microsecond, "350ms"},
+		{4*time.Second + 230*time.Millisecond, "4.2s"},
+		{4*time.Minute + 12*time.Second + 600*time.Millisecond, "4m13s"},
+		{time.Hour + 5*time.Second, "1h0m5s"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.expected, func(t *testing.T) {
+			if got := FormatDuration(tt.duration); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestNotification(t *testing.T) {
+	tests := []struct {
+		name            string
+		base            notifier.Notification
+		result          Result
+		expectedTitle   string
+		expectedMessage string
+		expectedColor   int
+	}{
+		{
+			name:            "Success",
+			result:          Result{Command: []string{"make", "-j8", "test"}, Duration: 4 * time.Second},
+			expectedTitle:   "✅ Success: make -j8 test",
+			expectedMessage: "Exited with code 0 after 4s",
+			expectedColor:   ColorSuccess,
+		},
+		{
+			name:            "Failure",
+			result:          Result{Command: []string{"make"}, ExitCode: 2, Duration: 90 * time.Second},
+			expectedTitle:   "❌ Failed: make",
+			expectedMessage: "Exited with code 2 after 1m30s",
+			expectedColor:   ColorFailure,
+		},
+		{
+			name:            "Killed by a signal",
+			result:          Result{Command: []string{"sleep", "60"}, ExitCode: 143, Signal: "SIGTERM", Duration: 5 * time.Second},
+			expectedTitle:   "⚠️ Killed by SIGTERM: sleep 60",
+			expectedMessage: "Killed by SIGTERM after 5s",
+			expectedColor:   ColorSignaled,
+		},
+		{
+			name:            "Timed out",
+			result:          Result{Command: []string{"make"}, ExitCode: ExitCodeTimeout, Duration: 2*time.Hour + 3*time.Second, Timeout: 2 * time.Hour},
+			expectedTitle:   "⏰ Timed out after 2h: make",
+			expectedMessage: "Stopped after running for 2h0m3s",
+			expectedColor:   ColorTimedOut,
+		},
+		{
+			name:            "Own title and message kept",
+			base:            notifier.Notification{Title: "Nightly build", Message: "See the logs"},
+			result:          Result{Command: []string{"make"}, ExitCode: 1},
+			expectedTitle:   "Nightly build",
+			expectedMessage: "See the logs",
+			expectedColor:   ColorFailure,
+		},
+		{
+			name:            "Long command shortened in title",
+			result:          Result{Command: []string{"echo", strings.Repeat("x", 200)}},
+			expectedTitle:   "✅ Success: echo " + strings.Repeat("x", 94) + "…",
+			expectedMessage: "Exited with code 0 after 0s",
+			expectedColor:   ColorSuccess,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := Notification(tt.base, &tt.result)
+			if n.Title != tt.expectedTitle {
+				t.Errorf("Expected title %q, got %q", tt.expectedTitle, n.Title)
+			}
+			if n.Message != tt.expectedMessage {
+				t.Errorf("Expected message %q, got %q", tt.expectedMessage, n.Message)
+			}
+			if n.Color != tt.expectedColor {
+				t.Errorf("Expected color %#x, got %#x", tt.expectedColor, n.Color)
+			}
+			if len(n.Fields) != 3 || n.Fields[0].Name != "Exit Code" || n.Fields[1].Name != "Duration" || n.Fields[2].Name != "Command" {
+				t.Errorf("Expected Exit Code, Duration and Command fields, got %+v", n.Fields)
+			}
+		})
+	}
+}
+
+func TestNotificationOutput(t *testing.T) {
+	t.Run("Fits", func(t *testing.T) {
+		n := Notification(notifier.Notification{}, &Result{Command: []string{"make"}, ExitCode: 2, Output: []string{"cc main.c", "main.c:3: error"}})
+		expected := "Exited with code 2 after 0s\n\n```\ncc main.c\nmain.c:3: error\n```"
+		if n.Message != expected {
+			t.Errorf("Expected %q, got %q", expected, n.Message)
+		}
+	})
+
+	t.Run("Oldest lines truncated", func(t *testing.T) {
+		var output []string
+		for i := range 500 {
+			output = append(output, fmt.Sprintf("line %03d %s", i, strings.Repeat("x", 20)))
+		}
+		n := Notification(notifier.Notification{}, &Result{Command: []string{"make"}, Output: output})
+
+		if got := utf8.RuneCountInString(n.Message); got > discord.MaxDescriptionLength {
+			t.Errorf("Expected the message to fit the description limit, got %d characters", got)
+		}
+		if !strings.Contains(n.Message, "```\n(truncated)\n") || !strings.HasSuffix(n.Message, output[499]+"\n```") {
+			t.Errorf("Expected the newest lines under a truncated marker, got %q", n.Message[:80])
+		}
+	})
+
+	t.Run("Plain messages use the content limit", func(t *testing.T) {
+		output := []string{strings.Repeat("y", 3000)}
+		n := Notification(notifier.Notification{Plain: true}, &Result{Command: []string{"make"}, Output: output})
+		if got := utf8.RuneCountInString(n.Message); got != discord.MaxContentLength {
+			t.Errorf("Expected the message cut to exactly %d characters, got %d", discord.MaxContentLength, got)
+		}
+		if !strings.Contains(n.Message, "(truncated)") {
+			t.Error("Expected a truncated marker")
+		}
+	})
+
+	t.Run("Backticks escaped", func(t *testing.T) {
+		n := Notification(notifier.Notification{}, &Result{Command: []string{"make"}, Output: []string{"```"}})
+		if strings.Count(n.Message, "```") != 2 {
+			t.Errorf("Expected output backticks not to close the block, got %q", n.Message)
+		}
+	})
+}
+
+func TestStartNotification(t *testing.T) {
+	n := StartNotification(notifier.Notification{Source: "deploy"}, "terraform apply")
+	if n.Title != "▶️ Started: terraform apply" || n.Message == "" || n.Source != "deploy" {
+		t.Errorf("Unexpected start notification: %+v", n)
+	}
+
+	n = StartNotification(notifier.Notification{Title: "Deploy"}, "terraform apply")
+	if n.Title != "Deploy" {
+		t.Errorf("Expected the own title to be kept, got %q", n.Title)
+	}
+}
+
+func TestShouldNotify(t *testing.T) {
+	tests := []struct {
+		name     string
+		filter   Filter
+		result   Result
+		expected bool
+	}{
+		{"No filter, passing", Filter{}, Result{}, true},
+		{"No filter, failing", Filter{}, Result{ExitCode: 1}, true},
+		{"Always", Filter{NotifyOn: config.NotifyAlways}, Result{ExitCode: 1}, true},
+		{"On failure, passing", Filter{NotifyOn: config.NotifyOnFailure}, Result{}, false},
+		{"On failure, failing", Filter{NotifyOn: config.NotifyOnFailure}, Result{ExitCode: 2}, true},
+		{"On success, passing", Filter{NotifyOn: config.NotifyOnSuccess}, Result{}, true},
+		{"On success, failing", Filter{NotifyOn: config.NotifyOnSuccess}, Result{ExitCode: 2}, false},
+		{"Quick success skipped", Filter{MinDuration: 30 * time.Second}, Result{Duration: 5 * time.Second}, false},
+		{"Slow success notified", Filter{MinDuration: 30 * time.Second}, Result{Duration: time.Minute}, true},
+		{"Exactly the threshold notified", Filter{MinDuration: 30 * time.Second}, Result{Duration: 30 * time.Second}, true},
+		{"Quick failure still notified", Filter{MinDuration: 30 * time.Second}, Result{ExitCode: 1, Duration: 5 * time.Second}, true},
+		{"Quick failure skipped when the threshold applies to all", Filter{MinDuration: 30 * time.Second, MinDurationAll: true}, Result{ExitCode: 1, Duration: 5 * time.Second}, false},
+		{"Slow success filtered by outcome", Filter{NotifyOn: config.NotifyOnFailure, MinDuration: 30 * time.Second}, Result{Duration: time.Minute}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.ShouldNotify(&tt.result); got != tt.expected {
+				t.Errorf("Expected %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,94 @@
+package run
+
+import (
+	"bytes"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// maxLineLength caps how much of a single line is kept, so output without
+// newlines can't grow the buffer without bound
+const maxLineLength = 4096
+
+// ansiEscape matches terminal color and cursor sequences
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;?]*[A-Za-z]`)
+
+// Tail keeps the last lines written to it in a ring buffer, so memory stays
+// bounded however much a command prints. It is safe for concurrent writes
+// from a command's stdout and stderr.
+type Tail struct {
+	mu      sync.Mutex
+	lines   []string
+	next    int  // Slot the next complete line goes in
+	full    bool // Whether lines has wrapped around
+	partial []byte
+}
+
+// NewTail returns a Tail keeping the last n lines
+func NewTail(n int) *Tail {
+	return &Tail{lines: make([]string, max(n, 1))}
+}
+
+// Write implements io.Writer
+func (t *Tail) Write(p []byte) (int, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			t.appendPartial(p)
+			break
+		}
+		t.appendPartial(p[:i])
+		t.push(string(t.partial))
+		t.partial = t.partial[:0]
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+func (t *Tail) appendPartial(p []byte) {
+	if room := maxLineLength - len(t.partial); room > 0 {
+		t.partial = append(t.partial, p[:min(len(p), room)]...)
+	}
+}
+
+func (t *Tail) push(line string) {
+	t.lines[t.next] = line
+	t.next = (t.next + 1) % len(t.lines)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Lines returns the kept lines, oldest first, including an unfinished last
+// line. Color codes are removed, and of a line redrawn with carriage returns,
+// such as a progress bar, only the final state is kept.
+func (t *Tail) Lines() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var lines []string
+	if t.full {
+		lines = append(lines, t.lines[t.next:]...)
+	}
+	lines = append(lines, t.lines[:t.next]...)
+	if len(t.partial) > 0 {
+		lines = append(lines, string(t.partial))
+		if len(lines) > len(t.lines) {
+			lines = lines[1:]
+		}
+	}
+
+	for i, line := range lines {
+		line = ansiEscape.ReplaceAllString(line, "")
+		if j := strings.LastIndex(strings.TrimRight(line, "\r"), "\r"); j >= 0 {
+			line = line[j+1:]
+		}
+		lines[i] = strings.TrimRight(line, "\r")
+	}
+	return lines
+}
@@ -0,0 +1,74 @@
+package run
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTail(t *testing.T) {
+	tests := []struct {
+		name     string
+		size     int
+		writes   []string
+		expected []string
+	}{
+		{"Fewer lines than kept", 3, []string{"a\nb\n"}, []string{"a", "b"}},
+		{"Oldest lines dropped", 2, []string{"a\nb\nc\nd\n"}, []string{"c", "d"}},
+		{"Lines split across writes", 3, []string{"hel", "lo\nwor", "ld\n"}, []string{"hello", "world"}},
+		{"Unfinished last line", 2, []string{"a\nb\nc"}, []string{"b", "c"}},
+		{"Blank lines kept", 3, []string{"a\n\nb\n"}, []string{"a", "", "b"}},
+		{"Color codes removed", 2, []string{"\x1b[31mFAIL\x1b[0m pkg\n"}, []string{"FAIL pkg"}},
+		{"Progress bar redraws", 2, []string{"10%\r50%\r100%\r\ndone\r\n"}, []string{"100%", "done"}},
+		{"Nothing written", 2, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tail := NewTail(tt.size)
+			for _, w := range tt.writes {
+				n, err := tail.Write([]byte(w))
+				if err != nil || n != len(w) {
+					t.Fatalf("Write(%q) = %d, %v", w, n, err)
+				}
+			}
+			if got := tail.Lines(); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestTailLongLine(t *testing.T) {
+	tail := NewTail(2)
+	for range 100 {
+		tail.Write([]byte(strings.Repeat("x", 1000)))
+	}
+	tail.Write([]byte("\nend\n"))
+
+	lines := tail.Lines()
+	if len(lines) != 2 || len(lines[0]) != maxLineLength || lines[1] != "end" {
+		t.Errorf("Expected the long line capped at %d bytes, got lengths %d", maxLineLength, len(lines[0]))
+	}
+}
+
+func TestTailConcurrentWrites(t *testing.T) {
+	tail := NewTail(1000)
+	var wg sync.WaitGroup
+	for w := range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range 100 {
+				fmt.Fprintf(tail, "writer %d line %d\n", w, i)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(tail.Lines()); got != 400 {
+		t.Errorf("Expected 400 lines, got %d", got)
+	}
+}
@@ -0,0 +1,160 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+// webhookHost is the host Slack serves incoming webhooks from
+const webhookHost = "hooks.slack.com"
+
+// Message is a Slack incoming-webhook payload
+type Message struct {
+	Text        string       `json:"text,omitempty"`
+	Username    string       `json:"username,omitempty"`
+	IconURL     string       `json:"icon_url,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Attachment is a Slack message attachment, which gives the message a color
+// bar, title, fields and footer like a Discord embed
+type Attachment struct {
+	Fallback   string  `json:"fallback"`
+	Color      string  `json:"color,omitempty"`
+	AuthorName string  `json:"author_name,omitempty"`
+	AuthorLink string  `json:"author_link,omitempty"`
+	AuthorIcon string  `json:"author_icon,omitempty"`
+	Title      string  `json:"title,omitempty"`
+	Text       string  `json:"text,omitempty"`
+	Fields     []Field `json:"fields,omitempty"`
+	ThumbURL   string  `json:"thumb_url,omitempty"`
+	Footer     string  `json:"footer,omitempty"`
+	FooterIcon string  `json:"footer_icon,omitempty"`
+	Ts         int64   `json:"ts,omitempty"`
+}
+
+// Field is a title/value pair shown in an attachment
+type Field struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short,omitempty"`
+}
+
+// IsWebhookURL reports whether a URL points at Slack rather than Discord
+func IsWebhookURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && strings.EqualFold(u.Hostname(), webhookHost)
+}
+
+// ValidateWebhookURL checks that a URL looks like a Slack incoming webhook:
+// https://hooks.slack.com/services/...
+func ValidateWebhookURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid webhook URL: %v", err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("invalid webhook URL: scheme must be https, got %q", u.Scheme)
+	}
+	if !strings.EqualFold(u.Hostname(), webhookHost) {
+		return fmt.Errorf("invalid webhook URL: host must be %s, got %q", webhookHost, u.Host)
+	}
+	if !strings.HasPrefix(u.Path, "/services/") && !strings.HasPrefix(u.Path, "/triggers/") {
+		return fmt.Errorf("invalid webhook URL: path must start with /services/, got %q", u.Path)
+	}
+	return nil
+}
+
+// Send posts a notification to a Slack incoming webhook
+func Send(ctx context.Context, webhookURL string, n notifier.Notification, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	request, err := BuildRequest(n, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	_, result, err := webhook.NewClient(webhookURL, nil).Post(ctx, request, opts)
+	return result, err
+}
+
+// BuildRequest renders a notification as the JSON request posted to Slack
+func BuildRequest(n notifier.Notification, cfg *config.Config) (webhook.Request, error) {
+	message, err := BuildMessage(n, cfg)
+	if err != nil {
+		return webhook.Request{}, err
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return webhook.Request{}, fmt.Errorf("error marshaling slack message: %v", err)
+	}
+
+	return webhook.Request{
+		Service: "slack",
+		Body:    data,
+	}, nil
+}
+
+// BuildMessage renders a notification as a Slack message with an attachment
+// showing its card, or as plain text when n.Plain is set
+func BuildMessage(n notifier.Notification, cfg *config.Config) (Message, error) {
+	if feature := n.DiscordOnly(); feature != "" {
+		return Message{}, fmt.Errorf("slack webhooks don't support %s", feature)
+	}
+
+	username, iconURL := notifier.ResolveIdentity(cfg)
+	message := Message{
+		Username: username,
+		IconURL:  iconURL,
+	}
+	if n.Plain {
+		message.Text = escape(n.Message)
+		return message, nil
+	}
+
+	card, err := notifier.BuildCard(n, cfg)
+	if err != nil {
+		return Message{}, err
+	}
+
+	attachment := Attachment{
+		Fallback: escape(card.Title + ": " + card.Message),
+		Color:    fmt.Sprintf("#%06x", card.Color),
+		Title:    escape(card.Title),
+		Text:     escape(card.Message),
+		ThumbURL: card.Thumbnail,
+		Footer:   escape(card.Footer.Text),
+		Ts:       card.Timestamp.Unix(),
+	}
+	attachment.FooterIcon = card.Footer.IconURL
+	if card.Author != nil {
+		attachment.AuthorName = escape(card.Author.Name)
+		attachment.AuthorLink = card.Author.URL
+		attachment.AuthorIcon = card.Author.IconURL
+	}
+	for _, field := range card.Fields {
+		if field.Value == "" {
+			continue
+		}
+		attachment.Fields = append(attachment.Fields, Field{
+			Title: escape(field.Name),
+			Value: escape(field.Value),
+			Short: field.Inline,
+		})
+	}
+
+	message.Attachments = []Attachment{attachment}
+	return message, nil
+}
+
+// escape encodes the characters Slack treats as control sequences
+func escape(s string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(s)
+}
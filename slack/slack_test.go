@@ -0,0 +1,172 @@
+package slack
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+func TestBuildMessage(t *testing.T) {
+	cfg := &config.Config{Username: "CI Bot", AvatarURL: "https://example.com/bot.png", FooterText: "acme-ci"}
+	n := notifier.Notification{
+		Message:   "Build <main> passed & deployed",
+		Source:    "CI",
+		Title:     "Deploy",
+		Author:    notifier.Author{Name: "octocat", URL: "https://github.com/octocat"},
+		Thumbnail: "https://example.com/thumb.png",
+		Fields:    []notifier.Field{{Name: "env", Value: "prod", Inline: true}},
+	}
+
+	message, err := BuildMessage(n, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.Username != "CI Bot" || message.IconURL != "https://example.com/bot.png" {
+		t.Errorf("Unexpected identity: %q %q", message.Username, message.IconURL)
+	}
+	if len(message.Attachments) != 1 {
+		t.Fatalf("Expected one attachment, got %d", len(message.Attachments))
+	}
+
+	a := message.Attachments[0]
+	if a.Title != "Deploy" || a.Color != "#3498db" {
+		t.Errorf("Unexpected title or color: %q %q", a.Title, a.Color)
+	}
+	if a.Text != "Build &lt;main&gt; passed &amp; deployed" {
+		t.Errorf("Expected escaped text, got %q", a.Text)
+	}
+	if a.AuthorName != "octocat" || a.AuthorLink != "https://github.com/octocat" || a.ThumbURL != "https://example.com/thumb.png" {
+		t.Errorf("Unexpected author or thumbnail: %+v", a)
+	}
+	if a.Footer != "acme-ci" || a.Ts == 0 {
+		t.Errorf("Unexpected footer or timestamp: %q %d", a.Footer, a.Ts)
+	}
+
+	var names []string
+	for _, f := range a.Fields {
+		names = append(names, f.Title)
+	}
+	if strings.Join(names, ",") != "Working Directory,Source,Host,env" {
+		t.Errorf("Unexpected fields: %v", names)
+	}
+	if !a.Fields[3].Short {
+		t.Error("Expected inline fields to be short")
+	}
+}
+
+func TestBuildMessagePlain(t *testing.T) {
+	message, err := BuildMessage(notifier.Notification{Message: "done", Plain: true}, &config.Config{UseWebhookDefaults: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.Text != "done" || len(message.Attachments) != 0 {
+		t.Errorf("Expected a plain text message, got %+v", message)
+	}
+	if message.Username != "" || message.IconURL != "" {
+		t.Errorf("Expected the webhook's own identity, got %q %q", message.Username, message.IconURL)
+	}
+}
+
+func TestBuildMessageUnsupported(t *testing.T) {
+	tests := []struct {
+		name     string
+		n        notifier.Notification
+		expected string
+	}{
+		{"Attachments", notifier.Notification{Message: "msg", Attachments: []string{"build.log"}}, "file attachments"},
+		{"Mentions", notifier.Notification{Message: "msg", Discord: notifier.DiscordOptions{Mentions: []notifier.Mention{{Type: notifier.MentionHere}}}}, "mentions"},
+		{"Thread", notifier.Notification{Message: "msg", Discord: notifier.DiscordOptions{ThreadName: "report"}}, "threads"},
+		{"TTS", notifier.Notification{Message: "msg", Discord: notifier.DiscordOptions{TTS: true}}, "text-to-speech"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := BuildMessage(tt.n, nil)
+			if err == nil || !strings.Contains(err.Error(), tt.expected) {
+				t.Errorf("Expected error about %s, got %v", tt.expected, err)
+			}
+		})
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if ct := r.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("Expected JSON content type, got %q", ct)
+			}
+			var message Message
+			if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+				t.Errorf("Failed to decode message: %v", err)
+			}
+			if len(message.Attachments) != 1 || message.Attachments[0].Text != "Hello" {
+				t.Errorf("Unexpected message: %+v", message)
+			}
+			io.WriteString(w, "ok")
+		}))
+		defer server.Close()
+
+		result, err := Send(context.Background(), server.URL, notifier.Notification{Message: "Hello", Source: "Test"}, nil, webhook.DefaultSendOptions())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.StatusCode != http.StatusOK || result.Attempts != 1 {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, "invalid_payload")
+		}))
+		defer server.Close()
+
+		_, err := Send(context.Background(), server.URL, notifier.Notification{Message: "Hello"}, nil, webhook.DefaultSendOptions())
+		expected := "slack webhook returned status 400: invalid_payload"
+		if err == nil || err.Error() != expected {
+			t.Errorf("Expected %q, got %v", expected, err)
+		}
+	})
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr string
+	}{
+		{"Valid", "https://hooks.slack.com/services/T000/B000/XXXX", ""},
+		{"Workflow trigger", "https://hooks.slack.com/triggers/T000/123/abc", ""},
+		{"HTTP", "http://hooks.slack.com/services/T000/B000/XXXX", "scheme must be https"},
+		{"Wrong host", "https://discord.com/api/webhooks/1/token", "host must be hooks.slack.com"},
+		{"Wrong path", "https://hooks.slack.com/api/T000", "path must start with /services/"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateWebhookURL(tt.url)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+
+	if !IsWebhookURL("https://hooks.slack.com/services/T000/B000/XXXX") || IsWebhookURL("https://discord.com/api/webhooks/1/token") {
+		t.Error("IsWebhookURL misidentified a webhook")
+	}
+}
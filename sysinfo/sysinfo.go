@@ -0,0 +1,54 @@
+package sysinfo
+
+import (
+	"cmp"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// Info describes the machine and account a notification is sent from. The
+// hostname isn't part of it, as every notification already has a Host field.
+type Info struct {
+	OS   string // runtime.GOOS
+	Arch string // runtime.GOARCH
+	User string // "" when it couldn't be found out at all
+}
+
+// Read collects the info, looking the user up through current, normally
+// user.Current, and falling back to the environment read through getenv
+func Read(current func() (*user.User, error), getenv func(string) string) *Info {
+	return &Info{
+		OS:   runtime.GOOS,
+		Arch: runtime.GOARCH,
+		User: username(current, getenv),
+	}
+}
+
+// username names the current user. Containers often run as a UID with no
+// /etc/passwd entry, where the lookup fails; $USER or $USERNAME may still be
+// set then, and failing that the UID itself is better than nothing.
+func username(current func() (*user.User, error), getenv func(string) string) string {
+	if u, err := current(); err == nil && u.Username != "" {
+		return u.Username
+	}
+	if name := cmp.Or(getenv("USER"), getenv("USERNAME")); name != "" {
+		return name
+	}
+	if uid := os.Getuid(); uid >= 0 {
+		return "uid " + strconv.Itoa(uid)
+	}
+	return ""
+}
+
+// Fields renders the info as inline embed fields, leaving out an unknown user
+func (i *Info) Fields() []notifier.Field {
+	fields := []notifier.Field{{Name: "OS", Value: i.OS + "/" + i.Arch, Inline: true}}
+	if i.User != "" {
+		fields = append(fields, notifier.Field{Name: "User", Value: i.User, Inline: true})
+	}
+	return fields
+}
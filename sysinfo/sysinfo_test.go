@@ -0,0 +1,69 @@
+package sysinfo
+
+import (
+	"errors"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"testing"
+)
+
+func TestRead(t *testing.T) {
+	found := func() (*user.User, error) { return &user.User{Username: "builder"}, nil }
+	missing := func() (*user.User, error) { return nil, errors.New("user: unknown userid 1000") }
+
+	uidFallback := ""
+	if uid := os.Getuid(); uid >= 0 {
+		uidFallback = "uid " + strconv.Itoa(uid)
+	}
+
+	tests := []struct {
+		name     string
+		current  func() (*user.User, error)
+		env      map[string]string
+		expected string
+	}{
+		{"Looked up", found, map[string]string{"USER": "someone-else"}, "builder"},
+		{"No passwd entry, USER set", missing, map[string]string{"USER": "ci"}, "ci"},
+		{"No passwd entry, USERNAME set", missing, map[string]string{"USERNAME": "ci-win"}, "ci-win"},
+		{"No passwd entry, nothing set", missing, nil, uidFallback},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			info := Read(tt.current, func(key string) string { return tt.env[key] })
+			if info.User != tt.expected {
+				t.Errorf("Expected user %q, got %q", tt.expected, info.User)
+			}
+			if info.OS != runtime.GOOS || info.Arch != runtime.GOARCH {
+				t.Errorf("Expected %s/%s, got %s/%s", runtime.GOOS, runtime.GOARCH, info.OS, info.Arch)
+			}
+		})
+	}
+}
+
+func TestFields(t *testing.T) {
+	tests := []struct {
+		name     string
+		info     Info
+		expected []string
+	}{
+		{"With user", Info{OS: "linux", Arch: "arm64", User: "builder"}, []string{"linux/arm64", "builder"}},
+		{"Unknown user", Info{OS: "windows", Arch: "amd64"}, []string{"windows/amd64"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fields := tt.info.Fields()
+			if len(fields) != len(tt.expected) {
+				t.Fatalf("Expected %d fields, got %+v", len(tt.expected), fields)
+			}
+			for i, field := range fields {
+				if field.Value != tt.expected[i] || !field.Inline {
+					t.Errorf("Expected inline field %q, got %+v", tt.expected[i], field)
+				}
+			}
+		})
+	}
+}
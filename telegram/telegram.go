@@ -0,0 +1,201 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+// apiHost serves the Telegram Bot API
+const apiHost = "api.telegram.org"
+
+// sendMessagePath matches /bot<token>/sendMessage
+var sendMessagePath = regexp.MustCompile(`^/bot[0-9]+:[A-Za-z0-9_-]+/sendMessage$`)
+
+// Message is a sendMessage request
+type Message struct {
+	ChatID              string              `json:"chat_id"`
+	Text                string              `json:"text"`
+	ParseMode           string              `json:"parse_mode,omitempty"`
+	DisableNotification bool                `json:"disable_notification,omitempty"`
+	LinkPreviewOptions  *LinkPreviewOptions `json:"link_preview_options,omitempty"`
+}
+
+// LinkPreviewOptions controls the preview of links in the message
+type LinkPreviewOptions struct {
+	IsDisabled bool `json:"is_disabled"`
+}
+
+// response is the envelope every Bot API method replies with
+type response struct {
+	OK          bool   `json:"ok"`
+	Description string `json:"description"`
+	Result      struct {
+		MessageID int64 `json:"message_id"`
+		Chat      struct {
+			ID int64 `json:"id"`
+		} `json:"chat"`
+	} `json:"result"`
+}
+
+// SendMessageURL returns the sendMessage endpoint for a bot token
+func SendMessageURL(token string) string {
+	return "https://" + apiHost + "/bot" + token + "/sendMessage"
+}
+
+// IsAPIURL reports whether a URL points at the Telegram Bot API
+func IsAPIURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && strings.EqualFold(u.Hostname(), apiHost)
+}
+
+// ValidateAPIURL checks that a URL looks like a sendMessage endpoint:
+// https://api.telegram.org/bot<token>/sendMessage
+func ValidateAPIURL(raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid Telegram API URL: %v", err)
+	}
+
+	if u.Scheme != "https" {
+		return fmt.Errorf("invalid Telegram API URL: scheme must be https, got %q", u.Scheme)
+	}
+	if !strings.EqualFold(u.Hostname(), apiHost) {
+		return fmt.Errorf("invalid Telegram API URL: host must be %s, got %q", apiHost, u.Host)
+	}
+	if !sendMessagePath.MatchString(u.Path) {
+		return fmt.Errorf("invalid Telegram API URL: path must be /bot<token>/sendMessage (check telegram_token)")
+	}
+	return nil
+}
+
+// Send posts a notification through the Bot API's sendMessage method
+func Send(ctx context.Context, apiURL string, n notifier.Notification, cfg *config.Config, opts webhook.SendOptions) (*notifier.Result, error) {
+	request, err := BuildRequest(n, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	body, result, err := webhook.NewClient(apiURL, nil).Post(ctx, request, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("failed to parse Telegram response: %v", err)
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("telegram API error: %s", resp.Description)
+	}
+	result.MessageID = strconv.FormatInt(resp.Result.MessageID, 10)
+	result.ChannelID = strconv.FormatInt(resp.Result.Chat.ID, 10)
+	return result, nil
+}
+
+// BuildRequest renders a notification as the JSON request posted to
+// sendMessage
+func BuildRequest(n notifier.Notification, cfg *config.Config) (webhook.Request, error) {
+	message, err := BuildMessage(n, cfg)
+	if err != nil {
+		return webhook.Request{}, err
+	}
+
+	data, err := json.Marshal(message)
+	if err != nil {
+		return webhook.Request{}, fmt.Errorf("error marshaling telegram message: %v", err)
+	}
+
+	return webhook.Request{
+		Service:      "telegram",
+		Body:         data,
+		ErrorMessage: errorMessage,
+	}, nil
+}
+
+// BuildMessage renders a notification as MarkdownV2: the title in bold, the
+// message, the fields, and an italic footer line with the source and
+// working directory
+func BuildMessage(n notifier.Notification, cfg *config.Config) (Message, error) {
+	if feature := n.DiscordOnly(); feature != "" {
+		return Message{}, fmt.Errorf("telegram doesn't support %s", feature)
+	}
+	if cfg == nil || cfg.TelegramChatID == "" {
+		return Message{}, fmt.Errorf("telegram_chat_id is not set in config")
+	}
+
+	message := Message{
+		ChatID:              cfg.TelegramChatID,
+		DisableNotification: (n.Silent || cfg.Silent) && !n.Loud,
+	}
+	if n.SuppressEmbeds || cfg.SuppressEmbeds {
+		message.LinkPreviewOptions = &LinkPreviewOptions{IsDisabled: true}
+	}
+	if n.Plain {
+		message.Text = n.Message
+		return message, nil
+	}
+
+	card, err := notifier.BuildCard(n, cfg)
+	if err != nil {
+		return Message{}, err
+	}
+
+	var text strings.Builder
+	fmt.Fprintf(&text, "*%s*\n\n%s", EscapeMarkdownV2(card.Title), EscapeMarkdownV2(n.Message))
+	fields, err := notifier.ResolveFields(n, cfg)
+	if err != nil {
+		return Message{}, err
+	}
+	if len(fields) > 0 {
+		text.WriteString("\n")
+		for _, field := range fields {
+			fmt.Fprintf(&text, "\n*%s:* %s", EscapeMarkdownV2(field.Name), EscapeMarkdownV2(field.Value))
+		}
+	}
+
+	var footer []string
+	if n.Source != "" {
+		footer = append(footer, n.Source)
+	}
+	if cwd := notifier.ResolveCwd(cfg); cwd != "" {
+		footer = append(footer, cwd)
+	}
+	if len(footer) > 0 {
+		fmt.Fprintf(&text, "\n\n_%s_", EscapeMarkdownV2(strings.Join(footer, " • ")))
+	}
+
+	message.Text = text.String()
+	message.ParseMode = "MarkdownV2"
+	return message, nil
+}
+
+// EscapeMarkdownV2 escapes the characters MarkdownV2 reserves so text is
+// shown as written
+func EscapeMarkdownV2(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\_*[]()~`>#+-=|{}.!", r) {
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// errorMessage reads the description from a Bot API error response
+func errorMessage(body []byte) string {
+	var resp response
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return ""
+	}
+	return resp.Description
+}
@@ -0,0 +1,134 @@
+package telegram
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/webhook"
+)
+
+func TestEscapeMarkdownV2(t *testing.T) {
+	tests := map[string]string{
+		"plain text":          "plain text",
+		"v1.2.3 (beta)!":      `v1\.2\.3 \(beta\)\!`,
+		"a_b*c~d`e>f#g+h-i=j": "a\\_b\\*c\\~d\\`e\\>f\\#g\\+h\\-i\\=j",
+		`[x]{y}|z\`:           `\[x\]\{y\}\|z\\`,
+	}
+	for input, expected := range tests {
+		if got := EscapeMarkdownV2(input); got != expected {
+			t.Errorf("EscapeMarkdownV2(%q) = %q, expected %q", input, got, expected)
+		}
+	}
+}
+
+func TestBuildMessage(t *testing.T) {
+	cfg := &config.Config{TelegramChatID: "-1001234", DefaultTitle: "Build #42"}
+	n := notifier.Notification{
+		Message: "Tests passed.",
+		Source:  "CI",
+		Fields:  []notifier.Field{{Name: "env", Value: "prod-1"}},
+		Silent:  true,
+	}
+
+	message, err := BuildMessage(n, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if message.ChatID != "-1001234" || message.ParseMode != "MarkdownV2" || !message.DisableNotification {
+		t.Errorf("Unexpected message settings: %+v", message)
+	}
+	if !strings.HasPrefix(message.Text, "*Build \\#42*\n\nTests passed\\.\n\n*env:* prod\\-1\n\n_CI • ") {
+		t.Errorf("Unexpected text %q", message.Text)
+	}
+
+	hidden := false
+	cfg.Embed = config.EmbedDefaults{ShowCwd: &hidden, Fields: []string{"team=infra"}}
+	if message, err = BuildMessage(n, cfg); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !strings.HasSuffix(message.Text, "*team:* infra\n*env:* prod\\-1\n\n_CI_") {
+		t.Errorf("Expected the configured field and no working directory, got %q", message.Text)
+	}
+
+	if _, err := BuildMessage(n, &config.Config{}); err == nil || !strings.Contains(err.Error(), "telegram_chat_id") {
+		t.Errorf("Expected an error about the missing chat ID, got %v", err)
+	}
+	if _, err := BuildMessage(notifier.Notification{Message: "msg", Discord: notifier.DiscordOptions{ThreadID: "1"}}, cfg); err == nil || !strings.Contains(err.Error(), "threads") {
+		t.Errorf("Expected an unsupported feature error, got %v", err)
+	}
+}
+
+func TestSend(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var message Message
+			if err := json.NewDecoder(r.Body).Decode(&message); err != nil {
+				t.Errorf("Failed to decode message: %v", err)
+			}
+			if message.ChatID != "42" || message.Text != "hello" || message.ParseMode != "" {
+				t.Errorf("Unexpected message: %+v", message)
+			}
+			io.WriteString(w, `{"ok":true,"result":{"message_id":7,"chat":{"id":42}}}`)
+		}))
+		defer server.Close()
+
+		n := notifier.Notification{Message: "hello", Plain: true}
+		result, err := Send(context.Background(), server.URL, n, &config.Config{TelegramChatID: "42"}, webhook.DefaultSendOptions())
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if result.MessageID != "7" || result.ChannelID != "42" {
+			t.Errorf("Unexpected result: %+v", result)
+		}
+	})
+
+	t.Run("Error description", func(t *testing.T) {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusBadRequest)
+			io.WriteString(w, `{"ok":false,"error_code":400,"description":"Bad Request: chat not found"}`)
+		}))
+		defer server.Close()
+
+		_, err := Send(context.Background(), server.URL, notifier.Notification{Message: "hello"}, &config.Config{TelegramChatID: "1"}, webhook.DefaultSendOptions())
+		expected := "telegram webhook returned status 400: Bad Request: chat not found"
+		if err == nil || err.Error() != expected {
+			t.Errorf("Expected %q, got %v", expected, err)
+		}
+	})
+}
+
+func TestValidateAPIURL(t *testing.T) {
+	tests := []struct {
+		name        string
+		url         string
+		expectedErr string
+	}{
+		{"Valid", SendMessageURL("123456:ABC-def_GHI"), ""},
+		{"HTTP", "http://api.telegram.org/bot1:a/sendMessage", "scheme must be https"},
+		{"Wrong host", "https://example.com/bot1:a/sendMessage", "host must be api.telegram.org"},
+		{"Missing token", SendMessageURL(""), "path must be /bot<token>/sendMessage"},
+		{"Other method", "https://api.telegram.org/bot1:a/getMe", "path must be /bot<token>/sendMessage"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateAPIURL(tt.url)
+			if tt.expectedErr == "" {
+				if err != nil {
+					t.Errorf("Unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,180 @@
+package waitfor
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/run"
+	"github.com/yashikota/owata/webhook"
+)
+
+// File events watch-file can report
+const (
+	FileCreated  = "create"
+	FileModified = "modify"
+	FileDeleted  = "delete"
+)
+
+// FileEvents lists every file event, in the order they're documented
+var FileEvents = []string{FileCreated, FileModified, FileDeleted}
+
+// DefaultDebounce is how long a file has to stay unchanged before a change is
+// reported when no --debounce is given
+const DefaultDebounce = time.Second
+
+// FileOptions controls how File watches a path
+type FileOptions struct {
+	Events   []string // Events to report; all of them when empty
+	Interval time.Duration
+	Debounce time.Duration // How long the file has to settle before reporting
+	Once     bool          // Return after the first reported change
+}
+
+// FileChange is a change File reported. Size and ModTime are zero for
+// FileDeleted.
+type FileChange struct {
+	Path    string
+	Event   string
+	Size    int64
+	ModTime time.Time
+}
+
+// fileState is what a stat of the watched path found
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+func (s fileState) equal(o fileState) bool {
+	return s.exists == o.exists && s.size == o.size && s.modTime.Equal(o.modTime)
+}
+
+func statFile(path string) (fileState, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fileState{}, nil
+	}
+	if err != nil {
+		return fileState{}, fmt.Errorf("failed to stat %s: %v", path, err)
+	}
+	return fileState{exists: true, size: info.Size(), modTime: info.ModTime()}, nil
+}
+
+// File polls path every interval and calls handle for each change, until ctx
+// ends, handle fails, or with Once, after the first change. A change is only
+// reported once the file has stayed the same for the debounce period, so a
+// burst of writes becomes a single modify, and a file that is created and
+// removed again within it isn't reported at all. The path doesn't have to
+// exist yet, but its parent directory does.
+func File(ctx context.Context, path string, opts FileOptions, handle func(FileChange) error) error {
+	dir := filepath.Dir(path)
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("cannot watch %s: directory %s does not exist", path, dir)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot watch %s: %v", path, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("cannot watch %s: %s is not a directory", path, dir)
+	}
+
+	reported, err := statFile(path)
+	if err != nil {
+		return err
+	}
+	latest := reported
+	changedAt := time.Now()
+
+	ticker := time.NewTicker(opts.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+
+		current, err := statFile(path)
+		if err != nil {
+			return err
+		}
+		if !current.equal(latest) {
+			latest = current
+			changedAt = time.Now()
+		}
+		if latest.equal(reported) || time.Since(changedAt) < opts.Debounce {
+			continue
+		}
+
+		event := fileEvent(reported, latest)
+		reported = latest
+		if event == "" || (len(opts.Events) > 0 && !slices.Contains(opts.Events, event)) {
+			continue
+		}
+		if err := handle(FileChange{Path: path, Event: event, Size: latest.size, ModTime: latest.modTime}); err != nil {
+			return err
+		}
+		if opts.Once {
+			return nil
+		}
+	}
+}
+
+// fileEvent names the change from one state to the next; a file replaced in
+// between counts as modified
+func fileEvent(from, to fileState) string {
+	switch {
+	case !from.exists && to.exists:
+		return FileCreated
+	case from.exists && !to.exists:
+		return FileDeleted
+	case from.exists && to.exists:
+		return FileModified
+	default:
+		return ""
+	}
+}
+
+// FileNotification fills in n to report the change, with the file's size and
+// modification time (in cfg's timezone and format when set) unless it was
+// deleted. A title or message already set on n is kept.
+func FileNotification(n notifier.Notification, c FileChange, cfg *config.Config) notifier.Notification {
+	var verb string
+	switch c.Event {
+	case FileCreated:
+		verb = "created"
+	case FileModified:
+		verb = "modified"
+	default:
+		verb = "deleted"
+	}
+
+	n.Color = run.ColorSuccess
+	if c.Event == FileDeleted {
+		n.Color = run.ColorSignaled
+	}
+	if n.Title == "" {
+		n.Title = "📄 " + filepath.Base(c.Path) + " was " + verb
+	}
+	if n.Message == "" {
+		n.Message = c.Path + " was " + verb
+	}
+
+	n.Fields = append(n.Fields, notifier.Field{Name: "Event", Value: c.Event, Inline: true})
+	if c.Event != FileDeleted {
+		n.Fields = append(n.Fields,
+			notifier.Field{Name: "Size", Value: webhook.FormatBytes(c.Size), Inline: true},
+			notifier.Field{Name: "Modified", Value: cmp.Or(cfg.FormatTime(c.ModTime), c.ModTime.Format(config.DefaultTimeFormat)), Inline: true},
+		)
+	}
+	return n
+}
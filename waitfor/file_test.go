@@ -0,0 +1,238 @@
+package waitfor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/config"
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/run"
+)
+
+// watchFile runs File in the background and collects what it reports until
+// the returned stop function is called
+func watchFile(t *testing.T, path string, opts FileOptions) (stop func() ([]FileChange, error)) {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	changes := make(chan FileChange, 16)
+	done := make(chan error, 1)
+	go func() {
+		done <- File(ctx, path, opts, func(c FileChange) error {
+			changes <- c
+			return nil
+		})
+	}()
+	// Let File take its first look before the test changes anything
+	time.Sleep(50 * time.Millisecond)
+	return func() ([]FileChange, error) {
+		cancel()
+		err := <-done
+		close(changes)
+		var got []FileChange
+		for c := range changes {
+			got = append(got, c)
+		}
+		return got, err
+	}
+}
+
+func events(changes []FileChange) []string {
+	var got []string
+	for _, c := range changes {
+		got = append(got, c.Event)
+	}
+	return got
+}
+
+func TestFile(t *testing.T) {
+	opts := FileOptions{Interval: 10 * time.Millisecond, Debounce: 60 * time.Millisecond}
+
+	t.Run("Create, modify and delete", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.pdf")
+		stop := watchFile(t, path, opts)
+
+		os.WriteFile(path, []byte("v1"), 0644)
+		time.Sleep(200 * time.Millisecond)
+		os.WriteFile(path, []byte("version 2"), 0644)
+		time.Sleep(200 * time.Millisecond)
+		os.Remove(path)
+		time.Sleep(200 * time.Millisecond)
+
+		got, err := stop()
+		if !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected context.Canceled, got %v", err)
+		}
+		if want := []string{FileCreated, FileModified, FileDeleted}; !slices.Equal(events(got), want) {
+			t.Fatalf("Expected %v, got %v", want, events(got))
+		}
+		if got[1].Size != 9 || got[1].ModTime.IsZero() || got[1].Path != path {
+			t.Errorf("Unexpected modify change %+v", got[1])
+		}
+	})
+
+	t.Run("Debounces a burst of writes", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "out.log")
+		os.WriteFile(path, nil, 0644)
+		stop := watchFile(t, path, opts)
+
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
+		if err != nil {
+			t.Fatalf("Failed to open: %v", err)
+		}
+		for range 5 {
+			f.WriteString("line\n")
+			time.Sleep(20 * time.Millisecond)
+		}
+		f.Close()
+		time.Sleep(200 * time.Millisecond)
+
+		got, _ := stop()
+		if want := []string{FileModified}; !slices.Equal(events(got), want) {
+			t.Fatalf("Expected %v, got %v", want, events(got))
+		}
+		if got[0].Size != 25 {
+			t.Errorf("Expected the size after the last write, got %d", got[0].Size)
+		}
+	})
+
+	t.Run("Filters events", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.pdf")
+		stop := watchFile(t, path, FileOptions{Interval: opts.Interval, Debounce: opts.Debounce, Events: []string{FileDeleted}})
+
+		os.WriteFile(path, []byte("v1"), 0644)
+		time.Sleep(200 * time.Millisecond)
+		os.Remove(path)
+		time.Sleep(200 * time.Millisecond)
+
+		got, _ := stop()
+		if want := []string{FileDeleted}; !slices.Equal(events(got), want) {
+			t.Errorf("Expected %v, got %v", want, events(got))
+		}
+	})
+
+	t.Run("Once", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.pdf")
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			os.WriteFile(path, []byte("v1"), 0644)
+		}()
+
+		var got []FileChange
+		err := File(context.Background(), path, FileOptions{Interval: opts.Interval, Once: true}, func(c FileChange) error {
+			got = append(got, c)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if want := []string{FileCreated}; !slices.Equal(events(got), want) {
+			t.Errorf("Expected %v, got %v", want, events(got))
+		}
+	})
+
+	t.Run("Handler error", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "report.pdf")
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			os.WriteFile(path, []byte("v1"), 0644)
+		}()
+
+		failed := errors.New("send failed")
+		err := File(context.Background(), path, FileOptions{Interval: opts.Interval}, func(FileChange) error {
+			return failed
+		})
+		if !errors.Is(err, failed) {
+			t.Errorf("Expected the handler's error, got %v", err)
+		}
+	})
+
+	t.Run("Missing parent directory", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "out")
+		err := File(context.Background(), filepath.Join(dir, "report.pdf"), opts, func(FileChange) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "directory "+dir+" does not exist") {
+			t.Errorf("Expected a missing directory error, got %v", err)
+		}
+	})
+
+	t.Run("Parent is a file", func(t *testing.T) {
+		parent := filepath.Join(t.TempDir(), "out")
+		os.WriteFile(parent, nil, 0644)
+		err := File(context.Background(), filepath.Join(parent, "report.pdf"), opts, func(FileChange) error { return nil })
+		if err == nil || !strings.Contains(err.Error(), "is not a directory") {
+			t.Errorf("Expected a not a directory error, got %v", err)
+		}
+	})
+}
+
+func TestFileNotification(t *testing.T) {
+	modTime := time.Date(2025, 6, 1, 12, 30, 0, 0, time.UTC)
+	cfg := &config.Config{Timezone: "UTC"}
+
+	tests := []struct {
+		name      string
+		base      notifier.Notification
+		change    FileChange
+		wantTitle string
+		wantMsg   string
+		wantColor int
+		want      []notifier.Field
+	}{
+		{
+			name:      "Created",
+			change:    FileChange{Path: "out/report.pdf", Event: FileCreated, Size: 2048, ModTime: modTime},
+			wantTitle: "📄 report.pdf was created",
+			wantMsg:   "out/report.pdf was created",
+			wantColor: run.ColorSuccess,
+			want: []notifier.Field{
+				{Name: "Event", Value: "create", Inline: true},
+				{Name: "Size", Value: "2.0 KiB", Inline: true},
+				{Name: "Modified", Value: "2025-06-01 12:30 UTC", Inline: true},
+			},
+		},
+		{
+			name:      "Deleted",
+			change:    FileChange{Path: "out/report.pdf", Event: FileDeleted},
+			wantTitle: "📄 report.pdf was deleted",
+			wantMsg:   "out/report.pdf was deleted",
+			wantColor: run.ColorSignaled,
+			want:      []notifier.Field{{Name: "Event", Value: "delete", Inline: true}},
+		},
+		{
+			name:      "Keeps title and message",
+			base:      notifier.Notification{Title: "Report ready", Message: "Go look"},
+			change:    FileChange{Path: "report.pdf", Event: FileModified, Size: 10, ModTime: modTime},
+			wantTitle: "Report ready",
+			wantMsg:   "Go look",
+			wantColor: run.ColorSuccess,
+			want: []notifier.Field{
+				{Name: "Event", Value: "modify", Inline: true},
+				{Name: "Size", Value: "10 B", Inline: true},
+				{Name: "Modified", Value: "2025-06-01 12:30 UTC", Inline: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := FileNotification(tt.base, tt.change, cfg)
+			if n.Title != tt.wantTitle {
+				t.Errorf("Expected title %q, got %q", tt.wantTitle, n.Title)
+			}
+			if n.Message != tt.wantMsg {
+				t.Errorf("Expected message %q, got %q", tt.wantMsg, n.Message)
+			}
+			if n.Color != tt.wantColor {
+				t.Errorf("Expected color %#x, got %#x", tt.wantColor, n.Color)
+			}
+			if !slices.Equal(n.Fields, tt.want) {
+				t.Errorf("Expected fields %+v, got %+v", tt.want, n.Fields)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package waitfor
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/run"
+)
+
+// DefaultInterval is how often a condition is checked when no --interval is
+// given
+const DefaultInterval = time.Second
+
+// For testing purposes
+var procDir = "/proc"
+
+// PID waits until the process with the given PID is gone, checking every
+// interval. It fails straight away if there is no such process, and returns
+// ctx's error if ctx ends first. The process doesn't have to be owata's child
+// or even belong to the same user, so its exit code can't be known.
+func PID(ctx context.Context, pid int, interval time.Duration) error {
+	alive, err := processExists(pid)
+	if err != nil {
+		return err
+	}
+	if !alive {
+		return fmt.Errorf("no process with PID %d", pid)
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+			if alive, err := processExists(pid); err != nil || !alive {
+				return err
+			}
+		}
+	}
+}
+
+// Cmdline returns the command line of the process, or nil where it can't be
+// read: on systems without /proc, or once the process is gone
+func Cmdline(pid int) []string {
+	data, err := os.ReadFile(filepath.Join(procDir, strconv.Itoa(pid), "cmdline"))
+	if err != nil {
+		return nil
+	}
+	// Arguments are NUL-terminated
+	data = []byte(strings.TrimSuffix(string(data), "\x00"))
+	if len(data) == 0 {
+		// Kernel threads have no command line
+		return nil
+	}
+	return strings.Split(string(data), "\x00")
+}
+
+// PIDNotification fills in n to report that the process is gone, naming its
+// command line when known (argv may be nil). A title or message already set
+// on n is kept.
+func PIDNotification(n notifier.Notification, pid int, argv []string, waited time.Duration) notifier.Notification {
+	if n.Title == "" {
+		if len(argv) > 0 {
+			n.Title = "🏁 Exited: " + run.TitleCommand(run.ShellQuote(argv))
+		} else {
+			n.Title = fmt.Sprintf("🏁 Process %d exited", pid)
+		}
+	}
+	if n.Message == "" {
+		n.Message = fmt.Sprintf("PID %d is gone after waiting %s", pid, run.FormatDuration(waited))
+	}
+
+	n.Fields = append(n.Fields,
+		notifier.Field{Name: "PID", Value: strconv.Itoa(pid), Inline: true},
+		notifier.Field{Name: "Waited", Value: run.FormatDuration(waited), Inline: true},
+	)
+	if len(argv) > 0 {
+		n.Fields = append(n.Fields, run.CommandField(run.ShellQuote(argv)))
+	}
+	return n
+}
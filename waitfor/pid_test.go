@@ -0,0 +1,135 @@
+package waitfor
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+func TestPID(t *testing.T) {
+	t.Run("Waits for the process to exit", func(t *testing.T) {
+		if _, err := exec.LookPath("sleep"); err != nil {
+			t.Skip("sleep is not available")
+		}
+		cmd := exec.Command("sleep", "0.3")
+		if err := cmd.Start(); err != nil {
+			t.Fatalf("Failed to start sleep: %v", err)
+		}
+		// Reap it, or it lingers as a zombie that still looks alive
+		go cmd.Wait()
+
+		start := time.Now()
+		if err := PID(context.Background(), cmd.Process.Pid, 20*time.Millisecond); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if waited := time.Since(start); waited < 200*time.Millisecond {
+			t.Errorf("Expected to wait for the process, returned after %s", waited)
+		}
+	})
+
+	t.Run("No such process", func(t *testing.T) {
+		err := PID(context.Background(), 99999999, time.Second)
+		if err == nil {
+			t.Error("Expected an error for a PID that doesn't exist")
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+		defer cancel()
+		// The test itself outlives the wait
+		if err := PID(ctx, os.Getpid(), 20*time.Millisecond); !errors.Is(err, context.DeadlineExceeded) {
+			t.Errorf("Expected the context's error, got %v", err)
+		}
+	})
+}
+
+func TestCmdline(t *testing.T) {
+	original := procDir
+	defer func() { procDir = original }()
+	procDir = t.TempDir()
+
+	write := func(pid, content string) {
+		if err := os.MkdirAll(filepath.Join(procDir, pid), 0755); err != nil {
+			t.Fatalf("Failed to create directory: %v", err)
+		}
+		if err := os.WriteFile(filepath.Join(procDir, pid, "cmdline"), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write cmdline: %v", err)
+		}
+	}
+	write("100", "make\x00-j8\x00test target\x00")
+	write("2", "")
+
+	tests := []struct {
+		name     string
+		pid      int
+		expected []string
+	}{
+		{"Arguments", 100, []string{"make", "-j8", "test target"}},
+		{"Kernel thread", 2, nil},
+		{"Gone", 300, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Cmdline(tt.pid); !slices.Equal(got, tt.expected) {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPIDNotification(t *testing.T) {
+	tests := []struct {
+		name            string
+		base            notifier.Notification
+		argv            []string
+		expectedTitle   string
+		expectedMessage string
+		expectedFields  []string
+	}{
+		{
+			name:            "Command line known",
+			argv:            []string{"make", "test target"},
+			expectedTitle:   "🏁 Exited: make 'test target'",
+			expectedMessage: "PID 4242 is gone after waiting 1m30s",
+			expectedFields:  []string{"PID=4242", "Waited=1m30s", "Command=`make 'test target'`"},
+		},
+		{
+			name:            "Command line unknown",
+			expectedTitle:   "🏁 Process 4242 exited",
+			expectedMessage: "PID 4242 is gone after waiting 1m30s",
+			expectedFields:  []string{"PID=4242", "Waited=1m30s"},
+		},
+		{
+			name:            "Own title and message kept",
+			base:            notifier.Notification{Title: "Backup done", Message: "Check the bucket"},
+			expectedTitle:   "Backup done",
+			expectedMessage: "Check the bucket",
+			expectedFields:  []string{"PID=4242", "Waited=1m30s"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := PIDNotification(tt.base, 4242, tt.argv, 90*time.Second)
+			if n.Title != tt.expectedTitle || n.Message != tt.expectedMessage {
+				t.Errorf("Expected %q / %q, got %q / %q", tt.expectedTitle, tt.expectedMessage, n.Title, n.Message)
+			}
+			var fields []string
+			for _, f := range n.Fields {
+				fields = append(fields, f.Name+"="+f.Value)
+			}
+			if !slices.Equal(fields, tt.expectedFields) {
+				t.Errorf("Expected fields %q, got %q", tt.expectedFields, fields)
+			}
+		})
+	}
+}
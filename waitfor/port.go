@@ -0,0 +1,148 @@
+package waitfor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/run"
+)
+
+// PortResult describes how waiting for a port ended
+type PortResult struct {
+	Address  string
+	Down     bool // Waited for the port to stop accepting connections
+	Waited   time.Duration
+	TimedOut bool  // Gave up before the port came up, or went down
+	LastErr  error // The last failed dial, if any
+}
+
+// Port dials address over TCP every interval until a connection succeeds,
+// or with down set, until one fails. With a timeout it gives up after that
+// long and reports TimedOut. The error is only set when ctx ends first.
+func Port(ctx context.Context, address string, down bool, interval, timeout time.Duration) (*PortResult, error) {
+	waitCtx := ctx
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		waitCtx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// A slow handshake shouldn't stall the schedule, but a remote host may
+	// need more than a short interval to answer
+	dialer := net.Dialer{Timeout: max(interval, time.Second)}
+	result := &PortResult{Address: address, Down: down}
+	start := time.Now()
+
+	giveUp := func() (*PortResult, error) {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.Waited = time.Since(start)
+		result.TimedOut = true
+		return result, nil
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		conn, err := dialer.DialContext(waitCtx, "tcp", address)
+		if err == nil {
+			conn.Close()
+		}
+		if err != nil && expired(waitCtx) {
+			// Cut short by the timeout, which says nothing about the port
+			return giveUp()
+		}
+		result.LastErr = err
+		if (err == nil) != down {
+			result.Waited = time.Since(start)
+			return result, nil
+		}
+
+		select {
+		case <-waitCtx.Done():
+			return giveUp()
+		case <-ticker.C:
+		}
+	}
+}
+
+// expired reports whether ctx is done or past its deadline; a dial bounded by
+// the deadline can fail before ctx itself notices
+func expired(ctx context.Context) bool {
+	if ctx.Err() != nil {
+		return true
+	}
+	deadline, ok := ctx.Deadline()
+	return ok && !time.Now().Before(deadline)
+}
+
+// DescribeDialError explains why a dial failed in a few words, telling a name
+// that doesn't resolve apart from a host that refuses the connection
+func DescribeDialError(err error) string {
+	var dnsErr *net.DNSError
+	var netErr net.Error
+	switch {
+	case errors.As(err, &dnsErr):
+		if dnsErr.IsNotFound {
+			return fmt.Sprintf("DNS lookup failed: %s not found", dnsErr.Name)
+		}
+		return fmt.Sprintf("DNS lookup failed for %s: %s", dnsErr.Name, dnsErr.Err)
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return "connection refused"
+	case errors.As(err, &netErr) && netErr.Timeout():
+		return "connection timed out"
+	default:
+		return err.Error()
+	}
+}
+
+// PortNotification fills in n to report how waiting for the port ended: green
+// when it came up or went down as hoped, yellow when owata gave up. A title
+// or message already set on n is kept.
+func PortNotification(n notifier.Notification, r *PortResult) notifier.Notification {
+	waited := run.FormatDuration(r.Waited)
+
+	var title, message string
+	switch {
+	case r.TimedOut && r.Down:
+		n.Color = run.ColorTimedOut
+		title = "⏰ Gave up on " + r.Address
+		message = fmt.Sprintf("Still accepting connections after %s", waited)
+	case r.TimedOut:
+		n.Color = run.ColorTimedOut
+		title = "⏰ Gave up on " + r.Address
+		message = fmt.Sprintf("Still unreachable after %s", waited)
+		if r.LastErr != nil {
+			message += ": " + DescribeDialError(r.LastErr)
+		}
+	case r.Down:
+		n.Color = run.ColorSuccess
+		title = "🔌 " + r.Address + " is down"
+		message = fmt.Sprintf("Stopped accepting connections after %s", waited)
+		if r.LastErr != nil {
+			message += ": " + DescribeDialError(r.LastErr)
+		}
+	default:
+		n.Color = run.ColorSuccess
+		title = "🔌 " + r.Address + " is up"
+		message = fmt.Sprintf("Accepted a connection after %s", waited)
+	}
+
+	if n.Title == "" {
+		n.Title = title
+	}
+	if n.Message == "" {
+		n.Message = message
+	}
+	n.Fields = append(n.Fields,
+		notifier.Field{Name: "Address", Value: r.Address, Inline: true},
+		notifier.Field{Name: "Waited", Value: waited, Inline: true},
+	)
+	return n
+}
@@ -0,0 +1,169 @@
+package waitfor
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"slices"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+	"github.com/yashikota/owata/run"
+)
+
+// freeAddress finds a local port nothing is listening on
+func freeAddress(t *testing.T) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	address := l.Addr().String()
+	l.Close()
+	return address
+}
+
+func TestPort(t *testing.T) {
+	t.Run("Comes up", func(t *testing.T) {
+		address := freeAddress(t)
+		go func() {
+			time.Sleep(150 * time.Millisecond)
+			l, err := net.Listen("tcp", address)
+			if err != nil {
+				return
+			}
+			time.Sleep(2 * time.Second)
+			l.Close()
+		}()
+
+		r, err := Port(context.Background(), address, false, 20*time.Millisecond, 5*time.Second)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if r.TimedOut || r.LastErr != nil || r.Waited < 100*time.Millisecond {
+			t.Errorf("Expected the port to come up after a while, got %+v", r)
+		}
+	})
+
+	t.Run("Goes down", func(t *testing.T) {
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		go func() {
+			time.Sleep(150 * time.Millisecond)
+			l.Close()
+		}()
+
+		r, err := Port(context.Background(), l.Addr().String(), true, 20*time.Millisecond, 5*time.Second)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if r.TimedOut || !r.Down || r.LastErr == nil {
+			t.Errorf("Expected the port to go down with the dial error, got %+v", r)
+		}
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		r, err := Port(context.Background(), freeAddress(t), false, 20*time.Millisecond, 100*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !r.TimedOut || r.LastErr == nil {
+			t.Errorf("Expected a timeout with the last dial error, got %+v", r)
+		}
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			cancel()
+		}()
+		if _, err := Port(ctx, freeAddress(t), false, 20*time.Millisecond, 0); !errors.Is(err, context.Canceled) {
+			t.Errorf("Expected the context's error, got %v", err)
+		}
+	})
+}
+
+func TestDescribeDialError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected string
+	}{
+		{"Unknown host", &net.OpError{Op: "dial", Err: &net.DNSError{Name: "db.internal", Err: "no such host", IsNotFound: true}}, "DNS lookup failed: db.internal not found"},
+		{"DNS server failure", &net.DNSError{Name: "db.internal", Err: "server misbehaving"}, "DNS lookup failed for db.internal: server misbehaving"},
+		{"Refused", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, "connection refused"},
+		{"Timed out", &net.OpError{Op: "dial", Err: os.ErrDeadlineExceeded}, "connection timed out"},
+		{"Other", errors.New("network is unreachable"), "network is unreachable"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := DescribeDialError(tt.err); got != tt.expected {
+				t.Errorf("Expected %q, got %q", tt.expected, got)
+			}
+		})
+	}
+}
+
+func TestPortNotification(t *testing.T) {
+	refused := &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+
+	tests := []struct {
+		name            string
+		result          PortResult
+		expectedTitle   string
+		expectedMessage string
+		expectedColor   int
+	}{
+		{
+			name:            "Up",
+			result:          PortResult{Address: "db:5432", Waited: 3 * time.Minute},
+			expectedTitle:   "🔌 db:5432 is up",
+			expectedMessage: "Accepted a connection after 3m0s",
+			expectedColor:   run.ColorSuccess,
+		},
+		{
+			name:            "Down",
+			result:          PortResult{Address: "db:5432", Down: true, Waited: 5 * time.Second, LastErr: refused},
+			expectedTitle:   "🔌 db:5432 is down",
+			expectedMessage: "Stopped accepting connections after 5s: connection refused",
+			expectedColor:   run.ColorSuccess,
+		},
+		{
+			name:            "Gave up waiting for up",
+			result:          PortResult{Address: "db:5432", TimedOut: true, Waited: 10 * time.Minute, LastErr: &net.DNSError{Name: "db", IsNotFound: true}},
+			expectedTitle:   "⏰ Gave up on db:5432",
+			expectedMessage: "Still unreachable after 10m0s: DNS lookup failed: db not found",
+			expectedColor:   run.ColorTimedOut,
+		},
+		{
+			name:            "Gave up waiting for down",
+			result:          PortResult{Address: "db:5432", Down: true, TimedOut: true, Waited: time.Minute},
+			expectedTitle:   "⏰ Gave up on db:5432",
+			expectedMessage: "Still accepting connections after 1m0s",
+			expectedColor:   run.ColorTimedOut,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := PortNotification(notifier.Notification{}, &tt.result)
+			if n.Title != tt.expectedTitle || n.Message != tt.expectedMessage || n.Color != tt.expectedColor {
+				t.Errorf("Expected %q / %q / %#x, got %q / %q / %#x", tt.expectedTitle, tt.expectedMessage, tt.expectedColor, n.Title, n.Message, n.Color)
+			}
+			var names []string
+			for _, f := range n.Fields {
+				names = append(names, f.Name)
+			}
+			if !slices.Equal(names, []string{"Address", "Waited"}) {
+				t.Errorf("Unexpected fields %v", names)
+			}
+		})
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package waitfor
+
+import "fmt"
+
+// processExists can't tell on this platform
+func processExists(pid int) (bool, error) {
+	return false, fmt.Errorf("waiting for a process isn't supported on this platform")
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package waitfor
+
+import (
+	"errors"
+	"syscall"
+)
+
+// processExists probes the process with signal 0, which checks for it
+// without signaling it. EPERM means it exists but belongs to someone else.
+func processExists(pid int) (bool, error) {
+	err := syscall.Kill(pid, 0)
+	switch {
+	case err == nil, errors.Is(err, syscall.EPERM):
+		return true, nil
+	case errors.Is(err, syscall.ESRCH):
+		return false, nil
+	default:
+		return false, err
+	}
+}
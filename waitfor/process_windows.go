@@ -0,0 +1,32 @@
+//go:build windows
+
+package waitfor
+
+import (
+	"errors"
+	"syscall"
+)
+
+const (
+	processQueryLimitedInformation = 0x1000
+	stillActive                    = 259
+)
+
+// processExists opens the process and checks that it hasn't exited yet
+func processExists(pid int) (bool, error) {
+	h, err := syscall.OpenProcess(processQueryLimitedInformation, false, uint32(pid))
+	if errors.Is(err, syscall.ERROR_ACCESS_DENIED) {
+		// Only a running process can refuse access
+		return true, nil
+	}
+	if err != nil {
+		return false, nil
+	}
+	defer syscall.CloseHandle(h)
+
+	var code uint32
+	if err := syscall.GetExitCodeProcess(h, &code); err != nil {
+		return false, err
+	}
+	return code == stillActive, nil
+}
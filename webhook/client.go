@@ -0,0 +1,56 @@
+package webhook
+
+import (
+	"context"
+	"net/http"
+	"time"
+)
+
+// DefaultTimeout bounds a single webhook request
+const DefaultTimeout = 10 * time.Second
+
+// Client talks to a single webhook. Its HTTP client, and with it any open
+// connections, is reused across requests.
+type Client struct {
+	url        string
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for url. A nil httpClient uses the default
+// transport; pass your own for custom TLS, instrumentation or test doubles.
+func NewClient(url string, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = &http.Client{}
+	}
+	return &Client{
+		url:        url,
+		httpClient: httpClient,
+	}
+}
+
+// URL returns the webhook the client sends to
+func (c *Client) URL() string {
+	return c.url
+}
+
+// HTTPClient returns a copy of the HTTP client bounded by timeout, or by the
+// client's own timeout when zero, falling back to DefaultTimeout. The copy
+// shares the original's transport, so connections are still reused.
+func (c *Client) HTTPClient(timeout time.Duration) *http.Client {
+	client := *c.httpClient
+	switch {
+	case timeout > 0:
+		client.Timeout = timeout
+	case client.Timeout <= 0:
+		client.Timeout = DefaultTimeout
+	}
+	return &client
+}
+
+// Do sends the request built by newRequest with the timeout and retries of
+// opts, returning the final response and the number of attempts made. The
+// request is rebuilt for each attempt since its body is consumed. Responses
+// outside 2xx that aren't retried are returned as they are.
+func (c *Client) Do(ctx context.Context, newRequest func() (*http.Request, error), opts SendOptions) (*http.Response, int, error) {
+	return doWithRetry(ctx, c.HTTPClient(opts.Timeout), newRequest, opts)
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// roundTripFunc lets a test stand in for the network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func TestClientCustomHTTPClient(t *testing.T) {
+	var requests []*http.Request
+	httpClient := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		requests = append(requests, r)
+		return &http.Response{
+			StatusCode: http.StatusOK,
+			Header:     http.Header{"Content-Type": []string{"application/json"}},
+			Body:       io.NopCloser(strings.NewReader(`{"ok": true}`)),
+		}, nil
+	})}
+
+	client := NewClient("https://hooks.example.test/notify", httpClient)
+	if client.URL() != "https://hooks.example.test/notify" {
+		t.Errorf("Unexpected URL %q", client.URL())
+	}
+	for range 2 {
+		if _, _, err := client.Post(context.Background(), Request{Service: "example", Body: []byte("{}")}, DefaultSendOptions()); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+
+	// Every request goes through the injected transport
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	if httpClient.Timeout != 0 {
+		t.Errorf("Expected the caller's client to be left untouched, got timeout %v", httpClient.Timeout)
+	}
+}
+
+func TestClientTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		client   *http.Client
+		timeout  time.Duration
+		expected time.Duration
+	}{
+		{"Default client", nil, 0, DefaultTimeout},
+		{"Explicit timeout", nil, 3 * time.Second, 3 * time.Second},
+		{"Client timeout", &http.Client{Timeout: time.Minute}, 0, time.Minute},
+		{"Explicit timeout wins", &http.Client{Timeout: time.Minute}, 3 * time.Second, 3 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := NewClient("https://hooks.example.test", tt.client)
+			if got := client.HTTPClient(tt.timeout).Timeout; got != tt.expected {
+				t.Errorf("Expected timeout %v, got %v", tt.expected, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,36 @@
+package webhook
+
+import "fmt"
+
+// StatusError is returned when a webhook answers with a status outside 2xx
+type StatusError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// NetworkError is returned when a request gets no response at all, such as
+// when the host can't be reached or the request times out
+type NetworkError struct {
+	Op  string // What was being done, as in "sending webhook"
+	Err error
+}
+
+func (e *NetworkError) Error() string {
+	return fmt.Sprintf("error %s: %v", e.Op, e.Err)
+}
+
+func (e *NetworkError) Unwrap() error {
+	return e.Err
+}
+
+// WithAttempts notes how many attempts were made when a send was retried
+func WithAttempts(err error, attempts int) error {
+	if attempts <= 1 {
+		return err
+	}
+	return fmt.Errorf("%w (after %d attempts)", err, attempts)
+}
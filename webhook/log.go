@@ -0,0 +1,101 @@
+package webhook
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yashikota/owata/redact"
+)
+
+// loggedHeaders are the response headers shown in verbose output; Discord
+// reports its rate limits in the X-RateLimit ones
+var loggedHeaders = []string{
+	"Retry-After",
+	"X-RateLimit-Limit",
+	"X-RateLimit-Remaining",
+	"X-RateLimit-Reset-After",
+	"X-RateLimit-Bucket",
+	"X-RateLimit-Scope",
+	"X-RateLimit-Global",
+}
+
+// maxLoggedBody caps how much of an error response body is logged
+const maxLoggedBody = 2048
+
+// logRequest writes a request's method, redacted URL and payload size
+func (o SendOptions) logRequest(req *http.Request) {
+	if o.Log == nil {
+		return
+	}
+	size := "unknown size"
+	if req.ContentLength >= 0 {
+		size = FormatBytes(req.ContentLength)
+	}
+	o.logf("→ %s %s (%s)\n", req.Method, redact.URL(req.URL.String()), size)
+}
+
+// logResponse writes a response's status, rate-limit headers and how long it
+// took, and the body of responses outside 2xx. The body is read and then
+// restored so the caller can still decode it.
+func (o SendOptions) logResponse(resp *http.Response, elapsed time.Duration) {
+	if o.Log == nil {
+		return
+	}
+
+	var headers []string
+	for _, name := range loggedHeaders {
+		if value := resp.Header.Get(name); value != "" {
+			headers = append(headers, name+": "+value)
+		}
+	}
+	details := ""
+	if len(headers) > 0 {
+		details = " [" + strings.Join(headers, ", ") + "]"
+	}
+	o.logf("← %s in %s%s\n", resp.Status, elapsed.Round(time.Millisecond), details)
+
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil || len(body) == 0 {
+		return
+	}
+	if len(body) > maxLoggedBody {
+		body = append(body[:maxLoggedBody], "…"...)
+	}
+	o.logf("  %s\n", strings.TrimSpace(string(body)))
+}
+
+// RedactError hides the webhook token in the URL that network errors quote
+func RedactError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		redacted := *urlErr
+		redacted.URL = redact.URL(urlErr.URL)
+		return &redacted
+	}
+	return err
+}
+
+// FormatBytes renders a size such as "512 B", "1.5 KiB" or "2.0 GiB"
+func FormatBytes(n int64) string {
+	switch {
+	case n < 1024:
+		return strconv.FormatInt(n, 10) + " B"
+	case n < 1024*1024:
+		return strconv.FormatFloat(float64(n)/1024, 'f', 1, 64) + " KiB"
+	case n < 1024*1024*1024:
+		return strconv.FormatFloat(float64(n)/(1024*1024), 'f', 1, 64) + " MiB"
+	default:
+		return strconv.FormatFloat(float64(n)/(1024*1024*1024), 'f', 1, 64) + " GiB"
+	}
+}
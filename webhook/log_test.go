@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestVerboseLog(t *testing.T) {
+	stubSleep(t)
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset-After", "0.5")
+		if calls == 1 {
+			w.Header().Set("Retry-After", "0.5")
+			w.WriteHeader(http.StatusTooManyRequests)
+			w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.5}`))
+			return
+		}
+		w.Write([]byte(`{"id": "1", "channel_id": "2"}`))
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	opts := DefaultSendOptions()
+	opts.Log = &log
+
+	webhookURL := server.URL + "/api/webhooks/123456789/secret-token"
+	if _, err := post(context.Background(), webhookURL, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	output := log.String()
+	for _, expected := range []string{
+		"→ POST " + server.URL + "/api/webhooks/123456789/**** (",
+		"← 429 Too Many Requests in ",
+		"Retry-After: 0.5, X-RateLimit-Remaining: 0, X-RateLimit-Reset-After: 0.5",
+		`{"message": "You are being rate limited.", "retry_after": 0.5}`,
+		"⏳ Rate limited, retrying in 500ms",
+		"← 200 OK in ",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("Expected log to contain %q, got:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "secret-token") {
+		t.Errorf("Expected the webhook token to be redacted, got:\n%s", output)
+	}
+}
+
+func TestRedactError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	webhookURL := server.URL + "/api/webhooks/123456789/secret-token"
+	server.Close()
+
+	_, err := post(context.Background(), webhookURL, DefaultSendOptions())
+	if err == nil {
+		t.Fatal("Expected an error from a closed server")
+	}
+	if strings.Contains(err.Error(), "secret-token") {
+		t.Errorf("Expected the webhook token to be redacted, got %v", err)
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	tests := map[int64]string{
+		512:             "512 B",
+		1536:            "1.5 KiB",
+		3 * 1024 * 1024: "3.0 MiB",
+		5 << 29:         "2.5 GiB",
+	}
+	for n, expected := range tests {
+		if got := FormatBytes(n); got != expected {
+			t.Errorf("FormatBytes(%d) = %q, expected %q", n, got, expected)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"bytes"
+	"cmp"
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// Request is a payload for a webhook service. Posting it through a Client
+// gives it the timeout, retry and error handling every provider shares.
+type Request struct {
+	Service     string      // Names the service in errors, such as "slack"
+	ContentType string      // Defaults to application/json
+	Header      http.Header // Extra headers such as Authorization
+	Body        []byte
+
+	// ErrorMessage extracts a readable message from an error response body;
+	// the raw body is reported when it is nil or returns ""
+	ErrorMessage func(body []byte) string
+}
+
+// Post sends r to the client's webhook URL and returns the response body
+// along with the delivery details. Responses outside 2xx are errors.
+func (c *Client) Post(ctx context.Context, r Request, opts SendOptions) ([]byte, *notifier.Result, error) {
+	start := time.Now()
+	resp, attempts, err := c.Do(ctx, func() (*http.Request, error) {
+		req, err := http.NewRequestWithContext(ctx, "POST", c.url, bytes.NewReader(r.Body))
+		if err != nil {
+			return nil, fmt.Errorf("error creating request: %v", RedactError(err))
+		}
+		maps.Copy(req.Header, r.Header)
+		if r.ContentType != "" {
+			req.Header.Set("Content-Type", r.ContentType)
+		} else if req.Header.Get("Content-Type") == "" {
+			req.Header.Set("Content-Type", "application/json")
+		}
+		return req, nil
+	}, opts)
+	if err != nil {
+		return nil, nil, WithAttempts(err, attempts)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		message := strings.TrimSpace(string(body))
+		if r.ErrorMessage != nil {
+			message = cmp.Or(r.ErrorMessage(body), message)
+		}
+		return nil, nil, WithAttempts(&StatusError{resp.StatusCode, fmt.Sprintf("%s webhook returned status %d: %s", r.Service, resp.StatusCode, message)}, attempts)
+	}
+
+	return body, &notifier.Result{
+		StatusCode: resp.StatusCode,
+		Attempts:   attempts,
+		Elapsed:    time.Since(start),
+	}, nil
+}
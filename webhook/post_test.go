@@ -0,0 +1,83 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+func TestClientPost(t *testing.T) {
+	waits := stubSleep(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		if ct := r.Header.Get("Content-Type"); ct != "text/plain" {
+			t.Errorf("Expected text/plain, got %q", ct)
+		}
+		if auth := r.Header.Get("Authorization"); auth != "Bearer secret" {
+			t.Errorf("Expected the extra header, got %q", auth)
+		}
+		body, _ := io.ReadAll(r.Body)
+		if string(body) != "hello" {
+			t.Errorf("Expected the body to be resent on retry, got %q", body)
+		}
+		io.WriteString(w, `{"ok": true}`)
+	}))
+	defer server.Close()
+
+	body, result, err := NewClient(server.URL, nil).Post(context.Background(), Request{
+		Service:     "ntfy",
+		ContentType: "text/plain",
+		Header:      http.Header{"Authorization": []string{"Bearer secret"}},
+		Body:        []byte("hello"),
+	}, DefaultSendOptions())
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if string(body) != `{"ok": true}` {
+		t.Errorf("Unexpected body %q", body)
+	}
+	if result.StatusCode != http.StatusOK || result.Attempts != 2 || len(*waits) != 1 {
+		t.Errorf("Expected one rate-limit retry, got %+v after waits %v", result, *waits)
+	}
+}
+
+func TestClientPostError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, `{"description": "Forbidden: bot was blocked"}`)
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL, nil)
+	_, _, err := client.Post(context.Background(), Request{Service: "example", Body: []byte("{}")}, DefaultSendOptions())
+	if err == nil || !strings.Contains(err.Error(), `example webhook returned status 403: {"description"`) {
+		t.Errorf("Expected the raw body in the error, got %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusForbidden {
+		t.Errorf("Expected a StatusError with 403, got %#v", err)
+	}
+
+	_, _, err = client.Post(context.Background(), Request{
+		Service: "example",
+		Body:    []byte("{}"),
+		ErrorMessage: func(body []byte) string {
+			_, after, _ := strings.Cut(string(body), `"description": "`)
+			return strings.TrimSuffix(after, `"}`)
+		},
+	}, DefaultSendOptions())
+	expected := "example webhook returned status 403: Forbidden: bot was blocked"
+	if err == nil || err.Error() != expected {
+		t.Errorf("Expected %q, got %v", expected, err)
+	}
+}
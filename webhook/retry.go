@@ -0,0 +1,220 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/yashikota/owata/redact"
+)
+
+const (
+	// DefaultRateLimitRetries is how many times a 429 response is retried
+	DefaultRateLimitRetries = 3
+
+	// maxRateLimitWait caps how long we'll sleep for a single 429; longer
+	// waits mean the webhook is heavily throttled and we give up instead
+	maxRateLimitWait = 30 * time.Second
+
+	// defaultRateLimitWait is used when the service doesn't say how long to wait
+	defaultRateLimitWait = time.Second
+
+	// baseBackoff and maxBackoff bound the wait between transient retries
+	baseBackoff = 500 * time.Millisecond
+	maxBackoff  = 5 * time.Second
+)
+
+// For testing purposes
+var (
+	sleepFunc  = sleepContext
+	jitterFunc = func(n int64) int64 { return rand.Int64N(n) }
+)
+
+// SendOptions controls how a notification is delivered
+type SendOptions struct {
+	Timeout          time.Duration // Per-request timeout; DefaultTimeout if zero
+	RateLimitRetries int           // Times to retry after a 429 response
+	Retries          int           // Times to retry network errors and 5xx responses
+	Log              io.Writer     // Receives retry progress; nil discards it
+	Logger           *slog.Logger  // Receives debug logs of each attempt; nil discards them
+}
+
+// DefaultSendOptions returns the options used when none are given
+func DefaultSendOptions() SendOptions {
+	return SendOptions{
+		Timeout:          DefaultTimeout,
+		RateLimitRetries: DefaultRateLimitRetries,
+	}
+}
+
+// debug logs msg at debug level to o.Logger, if set
+func (o SendOptions) debug(msg string, args ...any) {
+	if o.Logger != nil {
+		o.Logger.Debug(msg, args...)
+	}
+}
+
+func (o SendOptions) logf(format string, args ...any) {
+	if o.Log != nil {
+		fmt.Fprintf(o.Log, format, args...)
+	}
+}
+
+// doWithRetry sends the request built by newRequest, waiting out 429
+// responses as instructed by the service and, when opts.Retries is set,
+// backing off after network errors and 5xx responses. Transient retries stay
+// within opts.Timeout overall. The request is rebuilt for each attempt since
+// its body is consumed. It returns the final response along with the number
+// of attempts made; other responses are returned to the caller as-is.
+//
+// Waits between attempts end early if ctx is cancelled, and a cancelled ctx
+// is never retried. A 429 asking to wait past ctx's deadline fails at once.
+func doWithRetry(ctx context.Context, client *http.Client, newRequest func() (*http.Request, error), opts SendOptions) (*http.Response, int, error) {
+	deadline := time.Now().Add(client.Timeout)
+	rateLimited, failed := 0, 0
+
+	for attempt := 1; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, attempt, err
+		}
+
+		var cancel context.CancelFunc
+		if opts.Retries > 0 && client.Timeout > 0 {
+			var attemptCtx context.Context
+			attemptCtx, cancel = context.WithDeadline(req.Context(), deadline)
+			req = req.WithContext(attemptCtx)
+		}
+
+		opts.logRequest(req)
+		opts.debug("sending request", "attempt", attempt, "method", req.Method, "url", redact.URL(req.URL.String()), "bytes", req.ContentLength)
+		sent := time.Now()
+		resp, err := client.Do(req)
+		if err != nil {
+			if cancel != nil {
+				cancel()
+			}
+			err = RedactError(err)
+			opts.debug("request failed", "attempt", attempt, "elapsed", time.Since(sent), "error", err)
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, attempt, ctxErr
+			}
+			if failed < opts.Retries {
+				if wait, ok := backoff(failed, deadline); ok {
+					failed++
+					opts.debug("network error, retrying", "wait", wait, "retry", failed, "max", opts.Retries)
+					opts.logf("⚠️ Request failed (%v), retrying in %s (retry %d/%d)\n", err, wait.Round(time.Millisecond), failed, opts.Retries)
+					if err := sleepFunc(ctx, wait); err != nil {
+						return nil, attempt, err
+					}
+					continue
+				}
+			}
+			return nil, attempt, &NetworkError{Op: "sending webhook", Err: err}
+		}
+		if cancel != nil {
+			resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+		}
+		opts.logResponse(resp, time.Since(sent))
+		opts.debug("response received", "attempt", attempt, "status", resp.StatusCode, "elapsed", time.Since(sent))
+
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests && rateLimited < opts.RateLimitRetries:
+			wait := retryAfter(resp)
+			resp.Body.Close()
+			if wait > maxRateLimitWait {
+				return nil, attempt, &StatusError{resp.StatusCode, fmt.Sprintf("webhook is rate limited for %s, giving up", wait.Round(time.Millisecond))}
+			}
+			// Don't sleep past ctx's deadline only to fail when it hits;
+			// the caller may have somewhere else to send
+			if ctxDeadline, ok := ctx.Deadline(); ok && time.Until(ctxDeadline) <= wait {
+				return nil, attempt, &StatusError{resp.StatusCode, fmt.Sprintf("webhook is rate limited for %s, longer than the time left", wait.Round(time.Millisecond))}
+			}
+
+			rateLimited++
+			opts.debug("rate limited, retrying", "wait", wait, "retry", rateLimited, "max", opts.RateLimitRetries)
+			opts.logf("⏳ Rate limited, retrying in %s (retry %d/%d)\n", wait.Round(time.Millisecond), rateLimited, opts.RateLimitRetries)
+			if err := sleepFunc(ctx, wait); err != nil {
+				return nil, attempt, err
+			}
+
+		case resp.StatusCode >= 500 && failed < opts.Retries:
+			wait, ok := backoff(failed, deadline)
+			if !ok {
+				return resp, attempt, nil
+			}
+			resp.Body.Close()
+
+			failed++
+			opts.debug("server error, retrying", "status", resp.StatusCode, "wait", wait, "retry", failed, "max", opts.Retries)
+			opts.logf("⚠️ Webhook returned status %d, retrying in %s (retry %d/%d)\n", resp.StatusCode, wait.Round(time.Millisecond), failed, opts.Retries)
+			if err := sleepFunc(ctx, wait); err != nil {
+				return nil, attempt, err
+			}
+
+		default:
+			return resp, attempt, nil
+		}
+	}
+}
+
+// sleepContext waits for d, returning ctx's error early if it is cancelled
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+// backoff returns the jittered wait before transient retry n (zero-based),
+// or false if waiting would leave no time before the deadline
+func backoff(n int, deadline time.Time) (time.Duration, bool) {
+	wait := min(baseBackoff<<n, maxBackoff)
+	// Jitter between half and the full wait so concurrent senders spread out
+	wait = wait/2 + time.Duration(jitterFunc(int64(wait/2)+1))
+	if time.Until(deadline) <= wait {
+		return 0, false
+	}
+	return wait, true
+}
+
+// cancelOnClose releases a request's context once its body has been read
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// retryAfter reads how long to wait from the Retry-After header, falling back
+// to the retry_after field Discord includes in the JSON body
+func retryAfter(resp *http.Response) time.Duration {
+	if header := resp.Header.Get("Retry-After"); header != "" {
+		if seconds, err := strconv.ParseFloat(header, 64); err == nil && seconds >= 0 {
+			return time.Duration(seconds * float64(time.Second))
+		}
+	}
+
+	var body struct {
+		RetryAfter float64 `json:"retry_after"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err == nil && body.RetryAfter > 0 {
+		return time.Duration(body.RetryAfter * float64(time.Second))
+	}
+
+	return defaultRateLimitWait
+}
@@ -0,0 +1,443 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/yashikota/owata/notifier"
+)
+
+// post sends a small JSON request to url
+func post(ctx context.Context, url string, opts SendOptions) (*notifier.Result, error) {
+	_, result, err := NewClient(url, nil).Post(ctx, Request{Service: "discord", Body: []byte(`{"content": "Hello"}`)}, opts)
+	return result, err
+}
+
+// stubSleep records requested waits instead of sleeping
+func stubSleep(t *testing.T) *[]time.Duration {
+	t.Helper()
+	var waits []time.Duration
+	sleepFunc = func(_ context.Context, d time.Duration) error {
+		waits = append(waits, d)
+		return nil
+	}
+	t.Cleanup(func() {
+		sleepFunc = sleepContext
+	})
+	return &waits
+}
+
+func TestPostRetriesRateLimit(t *testing.T) {
+	tests := []struct {
+		name         string
+		header       string
+		body         string
+		expectedWait time.Duration
+	}{
+		{
+			name:         "Retry-After header",
+			header:       "1.5",
+			body:         `{"message": "You are being rate limited.", "retry_after": 9}`,
+			expectedWait: 1500 * time.Millisecond,
+		},
+		{
+			name:         "retry_after in body",
+			body:         `{"message": "You are being rate limited.", "retry_after": 0.25, "global": false}`,
+			expectedWait: 250 * time.Millisecond,
+		},
+		{
+			name:         "No hint",
+			expectedWait: defaultRateLimitWait,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := stubSleep(t)
+
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) == 1 {
+					if tt.header != "" {
+						w.Header().Set("Retry-After", tt.header)
+					}
+					w.WriteHeader(http.StatusTooManyRequests)
+					w.Write([]byte(tt.body))
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			var log bytes.Buffer
+			opts := DefaultSendOptions()
+			opts.Log = &log
+
+			result, err := post(context.Background(), server.URL, opts)
+			if err != nil {
+				t.Fatalf("Expected success after retry, got %v", err)
+			}
+			if got := requests.Load(); got != 2 {
+				t.Errorf("Expected 2 requests, got %d", got)
+			}
+			if result.Attempts != 2 {
+				t.Errorf("Expected the result to count 2 attempts, got %d", result.Attempts)
+			}
+			if len(*waits) != 1 || (*waits)[0] != tt.expectedWait {
+				t.Errorf("Expected a single wait of %s, got %v", tt.expectedWait, *waits)
+			}
+			if !strings.Contains(log.String(), "Rate limited") {
+				t.Errorf("Expected retry to be logged, got %q", log.String())
+			}
+		})
+	}
+}
+
+func TestPostRateLimitRetriesExhausted(t *testing.T) {
+	waits := stubSleep(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "0.1")
+		w.WriteHeader(http.StatusTooManyRequests)
+		w.Write([]byte(`{"message": "You are being rate limited.", "retry_after": 0.1}`))
+	}))
+	defer server.Close()
+
+	opts := DefaultSendOptions()
+	opts.RateLimitRetries = 2
+
+	_, err := post(context.Background(), server.URL, opts)
+	if err == nil {
+		t.Fatal("Expected error once retries are exhausted")
+	}
+	if !strings.Contains(err.Error(), "429") {
+		t.Errorf("Expected 429 in error, got %v", err)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected a StatusError with 429, got %#v", err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("Expected 3 requests, got %d", got)
+	}
+	if len(*waits) != 2 {
+		t.Errorf("Expected 2 waits, got %v", *waits)
+	}
+}
+
+func TestPostRateLimitTooLong(t *testing.T) {
+	waits := stubSleep(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3600")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	_, err := post(context.Background(), server.URL, DefaultSendOptions())
+	var statusErr *StatusError
+	if err == nil || !strings.Contains(err.Error(), "rate limited") || !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected rate limit error, got %v", err)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("Expected no waits, got %v", *waits)
+	}
+}
+
+func TestPostRateLimitPastDeadline(t *testing.T) {
+	waits := stubSleep(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "8")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	_, err := post(ctx, server.URL, DefaultSendOptions())
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusTooManyRequests {
+		t.Errorf("Expected rate limit error, got %v", err)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("Expected no wait past the deadline, got %v", *waits)
+	}
+}
+
+func TestPostDoesNotRetryClientErrors(t *testing.T) {
+	for _, status := range []int{http.StatusBadRequest, http.StatusNotFound} {
+		waits := stubSleep(t)
+
+		var requests atomic.Int32
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requests.Add(1)
+			w.WriteHeader(status)
+		}))
+
+		_, err := post(context.Background(), server.URL, DefaultSendOptions())
+		server.Close()
+
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) || statusErr.StatusCode != status {
+			t.Errorf("Expected a StatusError for status %d, got %v", status, err)
+		}
+		if got := requests.Load(); got != 1 {
+			t.Errorf("Expected 1 request for status %d, got %d", status, got)
+		}
+		if len(*waits) != 0 {
+			t.Errorf("Expected no waits for status %d, got %v", status, *waits)
+		}
+	}
+}
+
+// stubJitter makes backoff waits deterministic (half the nominal backoff)
+func stubJitter(t *testing.T) {
+	t.Helper()
+	jitterFunc = func(int64) int64 { return 0 }
+	t.Cleanup(func() {
+		jitterFunc = func(n int64) int64 { return rand.Int64N(n) }
+	})
+}
+
+func TestPostRetriesServerErrors(t *testing.T) {
+	tests := []struct {
+		name             string
+		retries          int
+		failures         int32
+		expectedRequests int32
+		expectedErr      string
+	}{
+		{
+			name:             "Retries disabled by default",
+			retries:          0,
+			failures:         1,
+			expectedRequests: 1,
+			expectedErr:      "502",
+		},
+		{
+			name:             "Recovers after a 502",
+			retries:          2,
+			failures:         1,
+			expectedRequests: 2,
+		},
+		{
+			name:             "Gives up after retries",
+			retries:          2,
+			failures:         10,
+			expectedRequests: 3,
+			expectedErr:      "after 3 attempts",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			waits := stubSleep(t)
+			stubJitter(t)
+
+			var requests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if requests.Add(1) <= tt.failures {
+					w.WriteHeader(http.StatusBadGateway)
+					return
+				}
+				w.WriteHeader(http.StatusNoContent)
+			}))
+			defer server.Close()
+
+			opts := DefaultSendOptions()
+			opts.Retries = tt.retries
+
+			_, err := post(context.Background(), server.URL, opts)
+			if tt.expectedErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.expectedErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.expectedErr, err)
+				}
+			} else if err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+
+			if got := requests.Load(); got != tt.expectedRequests {
+				t.Errorf("Expected %d requests, got %d", tt.expectedRequests, got)
+			}
+			if len(*waits) != int(tt.expectedRequests-1) {
+				t.Errorf("Expected %d waits, got %v", tt.expectedRequests-1, *waits)
+			}
+			for i, wait := range *waits {
+				if expected := baseBackoff << i / 2; wait != expected {
+					t.Errorf("Expected wait %d to be %s, got %s", i, expected, wait)
+				}
+			}
+		})
+	}
+}
+
+func TestPostRetriesNetworkErrors(t *testing.T) {
+	stubSleep(t)
+	stubJitter(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			// Drop the connection without a response
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	opts := DefaultSendOptions()
+	opts.Retries = 1
+
+	if _, err := post(context.Background(), server.URL, opts); err != nil {
+		t.Errorf("Expected success after retry, got %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+}
+
+func TestPostDebugLog(t *testing.T) {
+	stubSleep(t)
+	stubJitter(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	var log bytes.Buffer
+	opts := DefaultSendOptions()
+	opts.Retries = 1
+	opts.Logger = slog.New(slog.NewTextHandler(&log, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	webhookURL := server.URL + "/api/webhooks/123456789012345678/secret-token"
+	if _, err := post(context.Background(), webhookURL, opts); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	for _, want := range []string{
+		`msg="sending request" attempt=1 method=POST`,
+		`msg="response received" attempt=1 status=502`,
+		`msg="server error, retrying" status=502`,
+		`msg="response received" attempt=2 status=204`,
+	} {
+		if !strings.Contains(log.String(), want) {
+			t.Errorf("Expected %q in the log:\n%s", want, log.String())
+		}
+	}
+	if strings.Contains(log.String(), "secret-token") {
+		t.Errorf("Expected the webhook token to be redacted:\n%s", log.String())
+	}
+}
+
+func TestPostNetworkError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.Close()
+
+	opts := DefaultSendOptions()
+	opts.Retries = 0
+
+	_, err := post(context.Background(), server.URL, opts)
+	var netErr *NetworkError
+	if !errors.As(err, &netErr) || !strings.HasPrefix(err.Error(), "error sending webhook: ") {
+		t.Errorf("Expected a NetworkError, got %v", err)
+	}
+}
+
+func TestPostRetriesRespectTimeout(t *testing.T) {
+	waits := stubSleep(t)
+	stubJitter(t)
+
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	// With jitter stubbed the first backoff is 250ms and the second 500ms,
+	// which no longer fits in what remains of the 400ms timeout
+	opts := DefaultSendOptions()
+	opts.Retries = 5
+	opts.Timeout = 400 * time.Millisecond
+
+	_, err := post(context.Background(), server.URL, opts)
+	if err == nil || !strings.Contains(err.Error(), "after 2 attempts") {
+		t.Errorf("Expected error after 2 attempts, got %v", err)
+	}
+	if got := requests.Load(); got != 2 {
+		t.Errorf("Expected 2 requests, got %d", got)
+	}
+	if len(*waits) != 1 {
+		t.Errorf("Expected 1 wait, got %v", *waits)
+	}
+}
+
+func TestPostCancelledDuringWait(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Retry-After", "20")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	_, err := post(ctx, server.URL, DefaultSendOptions())
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected the context error, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("Expected the rate-limit wait to be abandoned, took %v", elapsed)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("Expected 1 request, got %d", got)
+	}
+}
+
+func TestPostCancelled(t *testing.T) {
+	waits := stubSleep(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("No request should be made once cancelled")
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	opts := DefaultSendOptions()
+	opts.Retries = 3
+	_, err := post(ctx, server.URL, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+	if len(*waits) != 0 {
+		t.Errorf("Expected no retries after cancellation, got %v", *waits)
+	}
+}